@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"one-mcp/internal/config"
+)
+
+// runConfigCommand implements `one-mcp config check`: it loads and validates the
+// configuration, prints the effective values and returns the process exit code.
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Println("usage: one-mcp config check [flags]")
+		return 2
+	}
+
+	cfg, err := config.Load(args[1:])
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	for _, kv := range cfg.Summary() {
+		fmt.Printf("%-22s %s\n", kv[0], kv[1])
+	}
+	for _, w := range cfg.Warnings() {
+		fmt.Printf("WARNING: %s\n", w)
+	}
+	fmt.Println("Configuration OK")
+	return 0
+}
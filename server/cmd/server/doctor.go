@@ -0,0 +1,217 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// doctorCheck is one line of the `one-mcp doctor` report.
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // "ok", "warn" or "fail"
+	Detail string `json:"detail"`
+}
+
+// certExpiryWarning is how close to expiry an upstream certificate may get before
+// doctor warns about it.
+const certExpiryWarning = 14 * 24 * time.Hour
+
+// runDoctorCommand implements `one-mcp doctor`: it validates the environment the
+// gateway runs in, prints a report and returns a non-zero exit code if any check failed.
+func runDoctorCommand(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	timeout := fs.Duration("timeout", 5*time.Second, "network check timeout")
+	// Configuration flags are passed through to config.Load
+	passthrough := map[string]*string{}
+	for _, name := range []string{"env-file", "port", "data-dir", "web-dist"} {
+		passthrough[name] = fs.String(name, "", "see one-mcp -h")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	var configArgs []string
+	fs.Visit(func(f *flag.Flag) {
+		if v, ok := passthrough[f.Name]; ok {
+			configArgs = append(configArgs, "-"+f.Name, *v)
+		}
+	})
+
+	var checks []doctorCheck
+	add := func(name, status, detail string) {
+		checks = append(checks, doctorCheck{Name: name, Status: status, Detail: detail})
+	}
+
+	cfg, err := config.Load(configArgs)
+	if err != nil {
+		add("config", "fail", err.Error())
+		return printDoctorReport(checks, *asJSON)
+	}
+	add("config", "ok", "configuration valid")
+	for _, w := range cfg.Warnings() {
+		add("config", "warn", w)
+	}
+
+	db := doctorDatabase(cfg, add)
+	if db == nil {
+		return printDoctorReport(checks, *asJSON)
+	}
+
+	key, _ := cfg.EncryptionKey()
+	if err := model.SetEncryptionKey(key); err != nil {
+		add("encryption", "fail", err.Error())
+		return printDoctorReport(checks, *asJSON)
+	}
+	var servers []model.UpstreamServer
+	if err := db.Find(&servers).Error; err != nil {
+		add("encryption", "fail", err.Error())
+	} else if key != "" {
+		add("encryption", "ok", "credentials decrypted with DB_ENCRYPTION_KEY")
+	}
+	for _, server := range servers {
+		name := "upstream " + server.Name
+		if !server.Enabled {
+			add(name, "ok", "disabled, skipped")
+			continue
+		}
+		switch server.TransportType {
+		case "stdio":
+			path, err := exec.LookPath(server.Command)
+			if err != nil {
+				add(name, "fail", fmt.Sprintf("command %q not found in PATH", server.Command))
+			} else if err := core.ValidateCwd(server.Cwd); err != nil {
+				add(name, "fail", err.Error())
+			} else {
+				add(name, "ok", "command found at "+path)
+			}
+		case "unix":
+			conn, err := net.DialTimeout("unix", server.SocketPath, *timeout)
+			if err != nil {
+				add(name, "fail", fmt.Sprintf("%s unreachable: %v", server.SocketPath, err))
+			} else {
+				conn.Close()
+				add(name, "ok", server.SocketPath+" reachable")
+			}
+		default:
+			status, detail := checkEndpoint(server.URL, *timeout)
+			add(name, status, detail)
+		}
+	}
+
+	return printDoctorReport(checks, *asJSON)
+}
+
+// doctorDatabase opens the database without migrating it and checks
+// that every table exists. It returns nil if the database is unusable.
+func doctorDatabase(cfg *config.Config, add func(name, status, detail string)) *gorm.DB {
+	dbPath := filepath.Join(filepath.Clean(cfg.DataDir), "one-mcp.db")
+	if _, err := os.Stat(dbPath); err != nil {
+		add("database", "fail", fmt.Sprintf("%s: %v", dbPath, err))
+		return nil
+	}
+	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	if err != nil {
+		add("database", "fail", err.Error())
+		return nil
+	}
+	if err := db.Exec("SELECT 1").Error; err != nil {
+		add("database", "fail", err.Error())
+		return nil
+	}
+	add("database", "ok", dbPath)
+
+	var missing []string
+	for _, m := range model.All {
+		if !db.Migrator().HasTable(m) {
+			stmt := &gorm.Statement{DB: db}
+			stmt.Parse(m)
+			missing = append(missing, stmt.Schema.Table)
+		}
+	}
+	if len(missing) > 0 {
+		add("migrations", "fail", fmt.Sprintf("missing tables %v (start the server once to migrate)", missing))
+	} else {
+		add("migrations", "ok", "all tables present")
+	}
+	return db
+}
+
+// checkEndpoint verifies that the host of rawURL is reachable and, for https, that
+// its certificate is valid and not about to expire.
+func checkEndpoint(rawURL string, timeout time.Duration) (string, string) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "fail", fmt.Sprintf("invalid URL %q", rawURL)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "https" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	if u.Scheme != "https" {
+		conn, err := dialer.Dial("tcp", host)
+		if err != nil {
+			return "fail", fmt.Sprintf("%s unreachable: %v", host, err)
+		}
+		conn.Close()
+		return "ok", host + " reachable"
+	}
+
+	conn, err := tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	if err != nil {
+		return "fail", fmt.Sprintf("%s TLS handshake failed: %v", host, err)
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "fail", host + " presented no certificate"
+	}
+	expires := certs[0].NotAfter
+	if time.Until(expires) < certExpiryWarning {
+		return "warn", fmt.Sprintf("%s certificate expires %s", host, expires.Format(time.RFC3339))
+	}
+	return "ok", fmt.Sprintf("%s reachable, certificate valid until %s", host, expires.Format("2006-01-02"))
+}
+
+func printDoctorReport(checks []doctorCheck, asJSON bool) int {
+	failed := false
+	for _, c := range checks {
+		if c.Status == "fail" {
+			failed = true
+		}
+	}
+
+	if asJSON {
+		out, _ := json.MarshalIndent(map[string]interface{}{"ok": !failed, "checks": checks}, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		labels := map[string]string{"ok": "[ OK ]", "warn": "[WARN]", "fail": "[FAIL]"}
+		for _, c := range checks {
+			fmt.Printf("%s %-24s %s\n", labels[c.Status], c.Name, c.Detail)
+		}
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
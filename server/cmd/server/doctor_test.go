@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// captureStdout returns what f prints to stdout, and its result.
+func captureStdout(t *testing.T, f func() int) (string, int) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	code := f()
+	os.Stdout = stdout
+	w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out), code
+}
+
+func TestDoctorDatabase(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{DataDir: dir}
+	var checks []doctorCheck
+	add := func(name, status, detail string) {
+		checks = append(checks, doctorCheck{Name: name, Status: status, Detail: detail})
+	}
+
+	// doctor never creates the database
+	assert.Nil(t, doctorDatabase(cfg, add))
+	assert.Equal(t, "fail", checks[0].Status)
+	assert.NoFileExists(t, filepath.Join(dir, "one-mcp.db"))
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "one-mcp.db")), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.UpstreamServer{}))
+	checks = nil
+	assert.NotNil(t, doctorDatabase(cfg, add))
+	require.Len(t, checks, 2)
+	assert.Equal(t, "ok", checks[0].Status)
+	assert.Equal(t, "fail", checks[1].Status)
+	assert.Contains(t, checks[1].Detail, "missing tables")
+	assert.NotContains(t, checks[1].Detail, "upstream_servers")
+
+	require.NoError(t, db.AutoMigrate(model.All...))
+	checks = nil
+	doctorDatabase(cfg, add)
+	assert.Equal(t, doctorCheck{Name: "migrations", Status: "ok", Detail: "all tables present"}, checks[1])
+}
+
+func TestCheckEndpoint(t *testing.T) {
+	status, detail := checkEndpoint("not a url", time.Second)
+	assert.Equal(t, "fail", status)
+	assert.Contains(t, detail, "invalid URL")
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	status, _ = checkEndpoint(srv.URL+"/sse", time.Second)
+	assert.Equal(t, "ok", status)
+
+	// A port nothing listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closed := l.Addr().String()
+	l.Close()
+	status, detail = checkEndpoint("http://"+closed+"/sse", time.Second)
+	assert.Equal(t, "fail", status)
+	assert.Contains(t, detail, "unreachable")
+
+	// The test server's certificate is not trusted
+	tlsSrv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsSrv.Close()
+	status, detail = checkEndpoint(tlsSrv.URL+"/mcp", time.Second)
+	assert.Equal(t, "fail", status)
+	assert.Contains(t, detail, "TLS handshake failed")
+}
+
+func TestRunDoctorCommand(t *testing.T) {
+	for _, name := range []string{"ENV_FILE", "DATA_DIR", "PORT", "JWT_SECRET", "DB_ENCRYPTION_KEY"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	dir := t.TempDir()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "one-mcp.db")), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(model.All...))
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	db.Create(&model.UpstreamServer{Name: "remote", TransportType: "sse", URL: srv.URL + "/sse", Enabled: true})
+	off := model.UpstreamServer{Name: "off", TransportType: "stdio", Command: "no-such-command", Enabled: true}
+	db.Create(&off)
+	db.Model(&off).Update("enabled", false)
+
+	report := func() (map[string]string, bool, int) {
+		out, code := captureStdout(t, func() int {
+			return runDoctorCommand([]string{"-json", "-data-dir", dir})
+		})
+		var parsed struct {
+			OK     bool          `json:"ok"`
+			Checks []doctorCheck `json:"checks"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(out), &parsed), out)
+		statuses := make(map[string]string)
+		for _, c := range parsed.Checks {
+			if c.Status != "warn" {
+				statuses[c.Name] = c.Status
+			}
+		}
+		return statuses, parsed.OK, code
+	}
+
+	statuses, ok, code := report()
+	assert.True(t, ok)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, map[string]string{
+		"config":          "ok",
+		"database":        "ok",
+		"migrations":      "ok",
+		"upstream remote": "ok",
+		"upstream off":    "ok",
+	}, statuses)
+
+	db.Create(&model.UpstreamServer{Name: "local", TransportType: "stdio", Command: "no-such-command", Enabled: true})
+	statuses, ok, code = report()
+	assert.False(t, ok)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "fail", statuses["upstream local"])
+
+	t.Run("Text Report", func(t *testing.T) {
+		out, code := captureStdout(t, func() int {
+			return printDoctorReport([]doctorCheck{{Name: "database", Status: "ok", Detail: "one-mcp.db"}}, false)
+		})
+		assert.Equal(t, 0, code)
+		assert.Equal(t, fmt.Sprintf("[ OK ] %-24s one-mcp.db\n", "database"), out)
+	})
+}
@@ -1,14 +1,21 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"one-mcp/internal/api"
+	"one-mcp/internal/config"
 	"one-mcp/internal/core"
 	"one-mcp/internal/model"
+	_ "one-mcp/internal/plugins" // Build-time plugins, see the package
+	"one-mcp/internal/report"
+	"one-mcp/internal/telemetry"
 
 	"strings"
+	"syscall"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/static"
@@ -19,12 +26,30 @@ import (
 )
 
 func main() {
-	// Determine data directory
-	dataDir := os.Getenv("DATA_DIR")
-	if dataDir == "" {
-		dataDir = "data"
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		os.Exit(runDoctorCommand(os.Args[2:]))
+	}
+
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, w := range cfg.Warnings() {
+		log.Printf("[WARNING] %s", w)
+	}
+	if err := report.Configure(cfg.SentryDSN, cfg.ErrorReportWebhook); err != nil {
+		log.Fatal(err)
+	}
+	if err := telemetry.Configure(cfg.OTelEndpoint, cfg.OTelServiceName, cfg.OTelHeaders, cfg.OTelExportInterval); err != nil {
+		log.Fatal(err)
 	}
-	dataDir = filepath.Clean(dataDir)
+
+	// Determine data directory
+	dataDir := filepath.Clean(cfg.DataDir)
 
 	// Ensure data directory exists
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
@@ -38,7 +63,26 @@ func main() {
 	}
 
 	// Auto Migrate
-	db.AutoMigrate(&model.UpstreamServer{}, &model.ApiKey{}, &model.Admin{})
+	db.AutoMigrate(model.All...)
+	if err := model.DropStaleIndexes(db); err != nil {
+		log.Printf("failed to drop stale indexes: %v", err)
+	}
+
+	// Optional encryption of upstream credentials and secrets at rest
+	if key, _ := cfg.EncryptionKey(); key != "" {
+		if err := model.SetEncryptionKey(key); err != nil {
+			log.Fatalf("failed to set database encryption key: %v", err)
+		}
+		count, err := model.EncryptExisting(db)
+		if err != nil {
+			log.Fatalf("failed to encrypt existing credentials: %v", err)
+		}
+		if count > 0 {
+			log.Printf("Encrypted %d existing credential values in the database", count)
+		}
+	} else if count, _ := model.EncryptedCount(db); count > 0 {
+		log.Fatalf("database holds %d encrypted credential values: set DB_ENCRYPTION_KEY or DB_ENCRYPTION_KEY_FILE", count)
+	}
 
 	// Initialize Default Admin if not exists
 	var adminCount int64
@@ -63,62 +107,67 @@ func main() {
 	}
 
 	// Init Gateway
-	gateway := core.NewGateway(db)
+	gateway := core.NewGateway(db, cfg)
+	gateway.WatchFlags()
 	gateway.ReloadUpstreams()
+	gateway.StartRetention()
+	gateway.ResumeJobs()
+
+	// Optional content moderation of tool results
+	if cfg.ModerationEndpoint != "" || len(cfg.ModerationKeywords) > 0 {
+		gateway.SetModerator(core.NewModerator(cfg.ModerationEndpoint, cfg.ModerationKeywords, cfg.ModerationAction))
+		log.Println("Content moderation enabled for tool results")
+	}
+
+	// Optional traffic mirroring to a staging gateway
+	if mirror := core.NewMirror(cfg); mirror != nil {
+		mirror.Start()
+		gateway.SetMirror(mirror)
+		log.Printf("Mirroring %d%% of incoming messages to %s", cfg.MirrorPercent, cfg.MirrorURL)
+	}
 
 	// Init Handler
-	handler := api.NewHandler(db, gateway)
+	handler := api.NewHandler(db, gateway, cfg)
 
-	r := gin.Default()
-	
-	// CORS
-	config := cors.DefaultConfig()
-	allowedOrigins := os.Getenv("ALLOWED_ORIGINS")
-	if allowedOrigins != "" {
-		config.AllowOrigins = strings.Split(allowedOrigins, ",")
-	} else {
-		config.AllowAllOrigins = true
-		log.Println("[WARNING] ALLOWED_ORIGINS not set, allowing all origins (CORS). This is insecure for production.")
+	// Optional read replica (e.g. kept in sync by LiteFS or Litestream) for stats,
+	// history and tool snapshots; writes always go to the primary
+	if cfg.DBReplicaPath != "" {
+		if _, err := os.Stat(cfg.DBReplicaPath); err != nil {
+			log.Fatalf("failed to open read replica: %v", err)
+		}
+		replica, err := gorm.Open(sqlite.Open("file:"+cfg.DBReplicaPath+"?mode=ro"), &gorm.Config{})
+		if err != nil {
+			log.Fatalf("failed to open read replica: %v", err)
+		}
+		gateway.SetReadReplica(replica)
+		handler.SetReadReplica(replica)
+		log.Printf("Reading stats and history from replica %s", cfg.DBReplicaPath)
 	}
-	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
-	r.Use(cors.New(config))
 
-	// Routes
+	r := gin.New()
+	r.Use(api.AccessLog())
+	r.Use(gin.CustomRecovery(func(c *gin.Context, err interface{}) {
+		report.Panic("http "+c.Request.Method+" "+c.FullPath(), err)
+		c.AbortWithStatus(500)
+	}))
 	
-	// Public Login API
-	r.POST("/api/login", handler.Login)
-
-	// Protected Admin APIs
-	apiGroup := r.Group("/api/v1")
-	apiGroup.Use(handler.AdminAuthMiddleware())
-	{
-		apiGroup.GET("/servers", handler.ListServers)
-		apiGroup.POST("/servers", handler.CreateServer)
-		apiGroup.PUT("/servers/:id", handler.UpdateServer)
-		apiGroup.DELETE("/servers/:id", handler.DeleteServer)
-
-		apiGroup.GET("/keys", handler.ListKeys)
-		apiGroup.POST("/keys", handler.CreateKey)
-		apiGroup.PUT("/keys/:id", handler.UpdateKey)
-		apiGroup.DELETE("/keys/:id", handler.DeleteKey)
-		
-		apiGroup.GET("/tools", handler.ListAllTools)
-		
-		apiGroup.POST("/change-password", handler.ChangePassword)
+	// CORS
+	corsConfig := cors.DefaultConfig()
+	if len(cfg.AllowedOrigins) > 0 {
+		corsConfig.AllowOrigins = cfg.AllowedOrigins
+	} else {
+		corsConfig.AllowAllOrigins = true
 	}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization", "Mcp-Session-Id", "MCP-Protocol-Version"}
+	corsConfig.ExposeHeaders = []string{"Mcp-Session-Id", "WWW-Authenticate"}
+	r.Use(cors.New(corsConfig))
 
-	mcpGroup := r.Group("/mcp")
-	{
-		mcpGroup.GET("/sse", handler.HandleSSE)
-		mcpGroup.POST("/messages", handler.HandleMessage)
-	}
+	// Routes: login, admin API and MCP endpoints
+	handler.RegisterRoutes(r)
 
 	// Serve Frontend (SPA)
 	// Serve static files from ../web/dist or specified directory
-	webDist := os.Getenv("WEB_DIST")
-	if webDist == "" {
-		webDist = "../web/dist"
-	}
+	webDist := cfg.WebDist
 	r.Use(static.Serve("/", static.LocalFile(webDist, true)))
 	
 	// Fallback for SPA: if not found (and not api), serve index.html
@@ -128,5 +177,15 @@ func main() {
 		}
 	})
 
-	r.Run(":8080")
+	// Let stdio upstreams exit cleanly when the gateway is stopped
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+		<-quit
+		fmt.Println("[Gateway] Shutting down, stopping upstreams...")
+		gateway.StopUpstreams()
+		os.Exit(0)
+	}()
+
+	r.Run(fmt.Sprintf(":%d", cfg.Port))
 }
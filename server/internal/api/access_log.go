@@ -0,0 +1,48 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Credentials that may appear in request URLs: the ?key= query parameter
+// (MCP_KEY_QUERY_PARAM), the ?token= admin JWT or token of the upstream console
+// and key path slugs (see key_paths.go).
+var (
+	keyParamPattern = regexp.MustCompile(`([?&](?:key|token)=)[^&]*`)
+	keyPathPattern  = regexp.MustCompile(`^/mcp/k/[^/?]+`)
+)
+
+// AccessLog logs requests like gin.Logger, with the credentials in their URLs
+// redacted.
+func AccessLog() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		var statusColor, methodColor, resetColor string
+		if param.IsOutputColor() {
+			statusColor = param.StatusCodeColor()
+			methodColor = param.MethodColor()
+			resetColor = param.ResetColor()
+		}
+		if param.Latency > time.Minute {
+			param.Latency = param.Latency.Truncate(time.Second)
+		}
+		return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			statusColor, param.StatusCode, resetColor,
+			param.Latency,
+			param.ClientIP,
+			methodColor, param.Method, resetColor,
+			redactURL(param.Path),
+			param.ErrorMessage,
+		)
+	})
+}
+
+// redactURL masks the API keys and admin tokens in the path and query of a request URL.
+func redactURL(path string) string {
+	path = keyPathPattern.ReplaceAllString(path, "/mcp/k/REDACTED")
+	return keyParamPattern.ReplaceAllString(path, "${1}REDACTED")
+}
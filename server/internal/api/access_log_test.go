@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestRedactURL(t *testing.T) {
+	assert.Equal(t, "/mcp/sse?key=REDACTED", redactURL("/mcp/sse?key=sk-secret"))
+	assert.Equal(t, "/mcp/sse?sessionId=a&key=REDACTED&x=1", redactURL("/mcp/sse?sessionId=a&key=sk-secret&x=1"))
+	assert.Equal(t, "/mcp/k/REDACTED/messages?sessionId=a", redactURL("/mcp/k/my-vanity-path-0001/messages?sessionId=a"))
+	assert.Equal(t, "/api/v1/keys?monkey=1", redactURL("/api/v1/keys?monkey=1"))
+	assert.Equal(t, "/api/v1/servers/1/console?token=REDACTED", redactURL("/api/v1/servers/1/console?token=at-secret"))
+	assert.Equal(t, "/api/v1/servers/1/console?x=1&token=REDACTED", redactURL("/api/v1/servers/1/console?x=1&token=eyJhbGciOi.x.y"))
+	assert.Equal(t, "/api/v1/calls?next_token=1", redactURL("/api/v1/calls?next_token=1"))
+}
+
+func TestKeyFallbacks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	db.AutoMigrate(&model.ApiKey{})
+	db.Create(&model.ApiKey{Key: "sk-fallback"})
+
+	settings := &config.Config{}
+	h := &Handler{db: db, gateway: core.NewGateway(nil, settings), settings: settings}
+	r := gin.New()
+	r.POST("/mcp/stateless", h.HandleStatelessPost)
+
+	request := func(path string, prepare func(req *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+		if prepare != nil {
+			prepare(req)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	basic := func(user, password string) func(req *http.Request) {
+		return func(req *http.Request) { req.SetBasicAuth(user, password) }
+	}
+
+	// Both fallbacks are off by default
+	assert.Equal(t, 401, request("/mcp/stateless?key=sk-fallback", nil).Code)
+	w := request("/mcp/stateless", basic("", "sk-fallback"))
+	assert.Equal(t, 401, w.Code)
+	assert.Empty(t, w.Header().Get("WWW-Authenticate"))
+
+	settings.KeyQueryParam = true
+	assert.Equal(t, 200, request("/mcp/stateless?key=sk-fallback", nil).Code)
+	assert.Equal(t, 401, request("/mcp/stateless?key=sk-wrong", nil).Code)
+	assert.Equal(t, 401, request("/mcp/stateless?key=sk-fallback", func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer sk-wrong")
+	}).Code, "the header takes precedence")
+
+	settings.KeyBasicAuth = true
+	assert.Equal(t, 200, request("/mcp/stateless", basic("mcp", "sk-fallback")).Code)
+	assert.Equal(t, 200, request("/mcp/stateless", basic("sk-fallback", "")).Code)
+	w = request("/mcp/stateless", basic("mcp", "sk-wrong"))
+	assert.Equal(t, 401, w.Code)
+	assert.Equal(t, `Basic realm="one-mcp"`, w.Header().Get("WWW-Authenticate"))
+}
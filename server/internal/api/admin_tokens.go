@@ -0,0 +1,141 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"one-mcp/internal/model"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AdminTokenPrefix marks admin API tokens, telling them apart from login JWTs.
+const AdminTokenPrefix = "at-"
+
+// Admin token scopes
+const (
+	ScopeAdmin       = "admin"        // Everything except managing admin tokens and passwords
+	ScopeReadOnly    = "read-only"    // GET requests only
+	ScopeServersOnly = "servers-only" // Upstream server management
+	ScopeKeysOnly    = "keys-only"    // API key management
+)
+
+// adminTokenUseInterval throttles last_used_at updates.
+const adminTokenUseInterval = time.Minute
+
+func hashAdminToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenScopeAllows reports whether a token scope permits a request to the given
+// route pattern. Tokens can never manage tokens or passwords, so a leaked token
+// cannot be used to mint new ones or take over the login.
+func tokenScopeAllows(scope string, method string, route string) bool {
+	if strings.HasPrefix(route, "/api/v1/admin-tokens") || route == "/api/v1/change-password" {
+		return false
+	}
+	switch scope {
+	case ScopeAdmin:
+		return true
+	case ScopeReadOnly:
+		// The console is a GET but sends arbitrary messages upstream
+		return method == http.MethodGet && !strings.HasSuffix(route, "/console")
+	case ScopeServersOnly:
+		return strings.HasPrefix(route, "/api/v1/servers")
+	case ScopeKeysOnly:
+		return strings.HasPrefix(route, "/api/v1/keys")
+	}
+	return false
+}
+
+// authenticateAdminToken authorizes a request made with an admin API token.
+func (h *Handler) authenticateAdminToken(c *gin.Context, raw string) {
+	var token model.AdminToken
+	if err := h.db.Where("token_hash = ?", hashAdminToken(raw)).First(&token).Error; err != nil {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	if !tokenScopeAllows(token.Scope, c.Request.Method, c.FullPath()) {
+		c.AbortWithStatusJSON(403, gin.H{"error": "Token scope '" + token.Scope + "' does not allow this request"})
+		return
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > adminTokenUseInterval {
+		h.db.Model(&token).UpdateColumn("last_used_at", now)
+	}
+	c.Set("username", "token:"+token.Name)
+	c.Set("adminToken", true)
+	c.Next()
+}
+
+// tokenAuthenticated reports whether a request was made with an admin API token.
+// Credentials are masked in the lists such requests get: tokens are meant for
+// automation, and a read-only one must not hand out the keys it can read.
+func tokenAuthenticated(c *gin.Context) bool {
+	return c.GetBool("adminToken")
+}
+
+func (h *Handler) ListAdminTokens(c *gin.Context) {
+	var tokens []model.AdminToken
+	h.db.Order("id").Find(&tokens)
+	c.JSON(200, tokens)
+}
+
+// CreateAdminToken issues a token. The token is only returned in this response.
+func (h *Handler) CreateAdminToken(c *gin.Context) {
+	var req struct {
+		Name      string     `json:"name" binding:"required"`
+		Scope     string     `json:"scope" binding:"required"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	switch req.Scope {
+	case ScopeAdmin, ScopeReadOnly, ScopeServersOnly, ScopeKeysOnly:
+	default:
+		c.JSON(400, gin.H{"error": "Scope must be one of admin, read-only, servers-only, keys-only"})
+		return
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		c.JSON(400, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	raw := AdminTokenPrefix + uuid.New().String()
+	token := model.AdminToken{
+		Name:      req.Name,
+		TokenHash: hashAdminToken(raw),
+		Prefix:    raw[:len(AdminTokenPrefix)+8],
+		Scope:     req.Scope,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := h.db.Create(&token).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordChange(c, "create", "admin_token", token.ID, token)
+	c.JSON(200, gin.H{"token": raw, "admin_token": token})
+}
+
+func (h *Handler) DeleteAdminToken(c *gin.Context) {
+	res := h.db.Delete(&model.AdminToken{}, c.Param("id"))
+	if res.Error != nil {
+		c.JSON(500, gin.H{"error": res.Error.Error()})
+		return
+	}
+	if res.RowsAffected > 0 {
+		h.recordChange(c, "delete", "admin_token", c.Param("id"), nil)
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
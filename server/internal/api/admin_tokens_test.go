@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestTokenScopeAllows(t *testing.T) {
+	assert.True(t, tokenScopeAllows(ScopeAdmin, "DELETE", "/api/v1/servers/:id"))
+	assert.False(t, tokenScopeAllows(ScopeAdmin, "POST", "/api/v1/admin-tokens"))
+	assert.False(t, tokenScopeAllows(ScopeAdmin, "POST", "/api/v1/change-password"))
+
+	assert.True(t, tokenScopeAllows(ScopeReadOnly, "GET", "/api/v1/keys"))
+	assert.False(t, tokenScopeAllows(ScopeReadOnly, "POST", "/api/v1/keys"))
+	assert.False(t, tokenScopeAllows(ScopeReadOnly, "GET", "/api/v1/servers/:id/console"))
+
+	assert.True(t, tokenScopeAllows(ScopeServersOnly, "PUT", "/api/v1/servers/:id"))
+	assert.False(t, tokenScopeAllows(ScopeServersOnly, "GET", "/api/v1/keys"))
+
+	assert.True(t, tokenScopeAllows(ScopeKeysOnly, "POST", "/api/v1/keys"))
+	assert.False(t, tokenScopeAllows(ScopeKeysOnly, "GET", "/api/v1/servers"))
+
+	assert.False(t, tokenScopeAllows("unknown", "GET", "/api/v1/servers"))
+}
+
+func TestTokenListsRedactCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.ApiKey{}, &model.AdminToken{})
+	db.Create(&model.ApiKey{Key: "sk-live", SigningSecret: "s3cret", Description: "ci"})
+	db.Create(&model.AdminToken{Name: "ro", TokenHash: hashAdminToken("at-ro"), Scope: ScopeReadOnly})
+
+	h := &Handler{db: db, settings: &config.Config{}}
+	r := gin.New()
+	r.GET("/api/v1/keys", func(c *gin.Context) {
+		if token := c.GetHeader("Authorization"); token != "" {
+			h.authenticateAdminToken(c, strings.TrimPrefix(token, "Bearer "))
+		}
+	}, h.ListKeys)
+	list := func(token string) string {
+		req := httptest.NewRequest("GET", "/api/v1/keys", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+		return w.Body.String()
+	}
+
+	body := list("at-ro")
+	assert.NotContains(t, body, "sk-live")
+	assert.NotContains(t, body, "s3cret")
+	assert.Contains(t, body, `"key":"[redacted]"`)
+	assert.Contains(t, body, `"description":"ci"`)
+
+	assert.Contains(t, list(""), "sk-live", "the console login still sees the keys")
+}
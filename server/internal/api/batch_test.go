@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessBatch(t *testing.T) {
+	h := &Handler{gateway: core.NewGateway(nil, &config.Config{}), settings: &config.Config{}}
+	delivered := make(chan []byte, 10)
+	session := &Session{Caller: &core.Caller{Notify: func(msg []byte) bool {
+		delivered <- msg
+		return true
+	}}}
+
+	next := func() string {
+		select {
+		case msg := <-delivered:
+			return string(msg)
+		case <-time.After(2 * time.Second):
+			return ""
+		}
+	}
+
+	t.Run("Responses In Request Order", func(t *testing.T) {
+		var batch []json.RawMessage
+		json.Unmarshal([]byte(`[
+			{"jsonrpc":"2.0","id":1,"method":"ping"},
+			{"jsonrpc":"2.0","method":"notifications/initialized"},
+			42,
+			{"jsonrpc":"2.0","id":"b","method":"ping"}
+		]`), &batch)
+		h.processBatch("s", session, batch)
+		assert.JSONEq(t, `[
+			{"jsonrpc":"2.0","id":1,"result":{}},
+			{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request"}},
+			{"jsonrpc":"2.0","id":"b","result":{}}
+		]`, next())
+	})
+
+	t.Run("Empty Batch", func(t *testing.T) {
+		h.processBatch("s", session, []json.RawMessage{})
+		assert.JSONEq(t, `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request"}}`, next())
+	})
+
+	t.Run("Only Notifications", func(t *testing.T) {
+		h.processBatch("s", session, []json.RawMessage{json.RawMessage(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)})
+		h.processBatch("s", session, []json.RawMessage{json.RawMessage(`{"jsonrpc":"2.0","id":2,"method":"ping"}`)})
+		assert.JSONEq(t, `[{"jsonrpc":"2.0","id":2,"result":{}}]`, next())
+	})
+
+	t.Run("Entries Take Session Slots", func(t *testing.T) {
+		session := &Session{Caller: session.Caller, done: make(chan struct{}), slots: make(chan struct{}, 1)}
+		session.slots <- struct{}{} // Another message is in flight
+		batch := []json.RawMessage{json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"ping"}`), json.RawMessage(`{"jsonrpc":"2.0","id":2,"method":"ping"}`)}
+		done := make(chan []byte)
+		go func() { done <- h.runBatch("s", session, batch) }()
+		select {
+		case <-done:
+			t.Fatal("batch ran without a free slot")
+		case <-time.After(100 * time.Millisecond):
+		}
+		<-session.slots
+		select {
+		case resp := <-done:
+			assert.JSONEq(t, `[{"jsonrpc":"2.0","id":1,"result":{}},{"jsonrpc":"2.0","id":2,"result":{}}]`, string(resp))
+		case <-time.After(2 * time.Second):
+			t.Fatal("batch not run")
+		}
+		assert.Len(t, session.slots, 0, "slots are released")
+
+		// Cancellations do not wait for a slot
+		session.slots <- struct{}{}
+		assert.Nil(t, h.runBatch("s", session, []json.RawMessage{json.RawMessage(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":9}}`)}))
+	})
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) ListToolPrices(c *gin.Context) {
+	var prices []model.ToolPrice
+	h.db.Order("tool").Find(&prices)
+	c.JSON(200, prices)
+}
+
+func (h *Handler) CreateToolPrice(c *gin.Context) {
+	var price model.ToolPrice
+	if err := c.ShouldBindJSON(&price); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := core.ValidateToolPrice(&price); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.db.Create(&price).Error; err != nil {
+		c.JSON(400, gin.H{"error": "Tool already has a price"})
+		return
+	}
+	h.gateway.ReloadPrices()
+	h.recordChange(c, "create", "price", price.ID, price)
+	c.JSON(200, price)
+}
+
+func (h *Handler) UpdateToolPrice(c *gin.Context) {
+	var price model.ToolPrice
+	if err := h.db.First(&price, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+	if err := c.ShouldBindJSON(&price); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := core.ValidateToolPrice(&price); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.db.Save(&price).Error; err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	h.gateway.ReloadPrices()
+	h.recordChange(c, "update", "price", price.ID, price)
+	c.JSON(200, price)
+}
+
+func (h *Handler) DeleteToolPrice(c *gin.Context) {
+	if h.db.Where("id = ?", c.Param("id")).Delete(&model.ToolPrice{}).RowsAffected > 0 {
+		h.gateway.ReloadPrices()
+		h.recordChange(c, "delete", "price", c.Param("id"), nil)
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+// invoiceLine is the cost of one tool's priced calls by one key in a month.
+type invoiceLine struct {
+	TeamID     uint    `json:"team_id"`
+	Team       string  `json:"team"`
+	KeyID      uint    `json:"key_id"`
+	Key        string  `json:"key"` // Description of the key
+	Tool       string  `json:"tool"`
+	Calls      int64   `json:"calls"`
+	CostMicros int64   `json:"cost_micros"`
+	Cost       float64 `json:"cost"`
+}
+
+// GetInvoice exports the cost of priced tool calls in a month for chargeback,
+// per team, key and tool. Parameters: month (YYYY-MM, default the previous
+// month, in the gateway timezone), team_id and key_id to narrow it down, and
+// format (json or csv). Calls are billed at the price when they were made.
+func (h *Handler) GetInvoice(c *gin.Context) {
+	loc := h.gateway.Location("")
+	month := c.Query("month")
+	if month == "" {
+		now := time.Now().In(loc)
+		month = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc).AddDate(0, -1, 0).Format("2006-01")
+	}
+	from, to, err := core.MonthRange(month, loc)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(400, gin.H{"error": "Invalid format: must be json or csv"})
+		return
+	}
+
+	query := h.reads().Model(&model.UsageLog{}).
+		Where("created_at >= ? AND created_at < ? AND cost_micros > 0", from, to)
+	if teamID := c.Query("team_id"); teamID != "" {
+		query = query.Where("team_id = ?", teamID)
+	}
+	if keyID := c.Query("key_id"); keyID != "" {
+		query = query.Where("key_id = ?", keyID)
+	}
+	lines := []invoiceLine{}
+	if err := query.
+		Select("team_id, key_id, tool, COUNT(*) AS calls, SUM(cost_micros) AS cost_micros").
+		Group("team_id, key_id, tool").Order("team_id, key_id, tool").Scan(&lines).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Names, including those of keys and teams deleted since
+	teams := map[uint]string{}
+	keys := map[uint]string{}
+	var totalCalls, totalMicros int64
+	for i := range lines {
+		line := &lines[i]
+		if _, ok := teams[line.TeamID]; !ok && line.TeamID != 0 {
+			var team model.Team
+			h.reads().Unscoped().Select("name").First(&team, line.TeamID)
+			teams[line.TeamID] = team.Name
+		}
+		if _, ok := keys[line.KeyID]; !ok {
+			var key model.ApiKey
+			h.reads().Unscoped().Select("description").First(&key, line.KeyID)
+			keys[line.KeyID] = key.Description
+		}
+		line.Team = teams[line.TeamID]
+		line.Key = keys[line.KeyID]
+		line.Cost = float64(line.CostMicros) / 1e6
+		totalCalls += line.Calls
+		totalMicros += line.CostMicros
+	}
+
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="invoice-%s.csv"`, month))
+		w := csv.NewWriter(c.Writer)
+		w.Write([]string{"month", "team_id", "team", "key_id", "key", "tool", "calls", "cost", "currency"})
+		for _, line := range lines {
+			w.Write([]string{
+				month, strconv.FormatUint(uint64(line.TeamID), 10), line.Team,
+				strconv.FormatUint(uint64(line.KeyID), 10), line.Key, line.Tool,
+				strconv.FormatInt(line.Calls, 10), formatMicros(line.CostMicros), h.settings.BillingCurrency,
+			})
+		}
+		w.Flush()
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"month":        month,
+		"timezone":     loc.String(),
+		"currency":     h.settings.BillingCurrency,
+		"total_calls":  totalCalls,
+		"total_micros": totalMicros,
+		"total":        float64(totalMicros) / 1e6,
+		"lines":        lines,
+	})
+}
+
+// formatMicros formats an amount in millionths as a decimal, e.g. 2500 as "0.002500".
+func formatMicros(micros int64) string {
+	return fmt.Sprintf("%d.%06d", micros/1e6, micros%1e6)
+}
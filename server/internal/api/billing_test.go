@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestInvoice(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.UsageLog{}, &model.ApiKey{}, &model.Team{})
+	db.Create(&model.Team{Name: "Platform"})
+	db.Create(&model.ApiKey{Key: "sk-a", Description: "CI, nightly", TeamID: 1})
+	removed := model.ApiKey{Key: "sk-b", Description: "Old bot"}
+	db.Create(&removed)
+	db.Delete(&removed)
+
+	settings := &config.Config{Timezone: "UTC", BillingCurrency: "EUR"}
+	h := &Handler{db: db, gateway: core.NewGateway(nil, settings), settings: settings}
+
+	september := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+	for _, log := range []model.UsageLog{
+		{CreatedAt: september, KeyID: 1, TeamID: 1, Tool: "gh__search", Success: true, CostMicros: 2500},
+		{CreatedAt: september, KeyID: 1, TeamID: 1, Tool: "gh__search", Success: true, CostMicros: 2500},
+		{CreatedAt: september, KeyID: 1, TeamID: 1, Tool: "gh__free", Success: true},
+		{CreatedAt: september, KeyID: 2, Tool: "gh__search", Success: true, CostMicros: 1000000},
+		{CreatedAt: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), KeyID: 1, TeamID: 1, Tool: "gh__search", Success: true, CostMicros: 2500},
+	} {
+		db.Create(&log)
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/api/v1/billing/invoice?"+query, nil)
+		h.GetInvoice(c)
+		return w
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		w := get("month=2026-09")
+		assert.Equal(t, 200, w.Code)
+		var invoice struct {
+			Currency    string        `json:"currency"`
+			TotalCalls  int64         `json:"total_calls"`
+			TotalMicros int64         `json:"total_micros"`
+			Lines       []invoiceLine `json:"lines"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &invoice))
+		assert.Equal(t, "EUR", invoice.Currency)
+		assert.Equal(t, int64(3), invoice.TotalCalls, "unpriced calls and other months are left out")
+		assert.Equal(t, int64(1005000), invoice.TotalMicros)
+		assert.Equal(t, []invoiceLine{
+			{KeyID: 2, Key: "Old bot", Tool: "gh__search", Calls: 1, CostMicros: 1000000, Cost: 1},
+			{TeamID: 1, Team: "Platform", KeyID: 1, Key: "CI, nightly", Tool: "gh__search", Calls: 2, CostMicros: 5000, Cost: 0.005},
+		}, invoice.Lines)
+
+		w = get("month=2026-09&team_id=1")
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &invoice))
+		assert.Len(t, invoice.Lines, 1)
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		w := get("month=2026-09&format=csv")
+		assert.Equal(t, 200, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Disposition"), "invoice-2026-09.csv")
+		assert.Equal(t, strings.Join([]string{
+			"month,team_id,team,key_id,key,tool,calls,cost,currency",
+			"2026-09,0,,2,Old bot,gh__search,1,1.000000,EUR",
+			`2026-09,1,Platform,1,"CI, nightly",gh__search,2,0.005000,EUR`,
+			"",
+		}, "\n"), w.Body.String())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		assert.Equal(t, 400, get("month=September").Code)
+		assert.Equal(t, 400, get("format=xml").Code)
+	})
+}
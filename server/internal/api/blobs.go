@@ -0,0 +1,29 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleBlob serves content offloaded from a tool result (see core/blobs.go).
+// The token in the URL authorizes the download and works only once. The media
+// type comes from the upstream, so anything but plain images and audio is sent
+// as an opaque download, and nothing can run scripts on the gateway's origin.
+func (h *Handler) HandleBlob(c *gin.Context) {
+	blob, ok := h.gateway.Blobs().Take(c.Param("token"))
+	if !ok {
+		c.JSON(404, gin.H{"error": "Blob not found, expired or already downloaded"})
+		return
+	}
+	mimeType := strings.ToLower(blob.MimeType)
+	inline := (strings.HasPrefix(mimeType, "image/") || strings.HasPrefix(mimeType, "audio/")) && !strings.Contains(mimeType, "svg")
+	if !inline {
+		mimeType = "application/octet-stream"
+		c.Header("Content-Disposition", "attachment")
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+	c.Data(200, mimeType, blob.Data)
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleBlob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	settings := &config.Config{PublicURL: "http://gw", BlobURLTTL: time.Minute}
+	h := &Handler{gateway: core.NewGateway(nil, settings), settings: settings}
+	r := gin.New()
+	r.GET("/mcp/blobs/:token", h.HandleBlob)
+
+	offload := func(mimeType string, data []byte) string {
+		return "/mcp/blobs/" + h.gateway.Blobs().Put(&core.Blob{MimeType: mimeType, Data: data}, time.Minute)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	path := offload("image/png", []byte("a png image, really"))
+	w := get(path)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, "a png image, really", w.Body.String())
+	assert.Equal(t, 404, get(path).Code, "downloaded once")
+
+	w = get(offload("image/svg+xml", []byte("<svg onload=alert(1)></svg>")))
+	assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment", w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"one-mcp/internal/model"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxBulkKeys bounds the number of keys created by one bulk request.
+const maxBulkKeys = 500
+
+// CreateKeysBulk creates count keys sharing the permissions and expiry of a
+// template, e.g. to hand out keys to the participants of a workshop. "{n}" in the
+// description is replaced by the number of the key, from 1 to count. Either all
+// keys are created or none.
+func (h *Handler) CreateKeysBulk(c *gin.Context) {
+	var req struct {
+		Count              int        `json:"count" binding:"required"`
+		Description        string     `json:"description"`
+		AllowedServers     string     `json:"allowed_servers"`
+		AllowedTools       string     `json:"allowed_tools"`
+		AllowedPrompts     string     `json:"allowed_prompts"`
+		AllowedResources   string     `json:"allowed_resources"`
+		TeamID             uint       `json:"team_id"`
+		OutputFormat       string     `json:"output_format"`
+		CatalogVersion     uint       `json:"catalog_version"`
+		Roots              string     `json:"roots"`
+		DeclineElicitation bool       `json:"decline_elicitation"`
+		ReadOnlyTools      bool       `json:"read_only_tools"`
+		Stateless          bool       `json:"stateless"`
+		SyncMessages       bool       `json:"sync_messages"`
+		ExpiresAt          *time.Time `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Count < 1 || req.Count > maxBulkKeys {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("count must be between 1 and %d", maxBulkKeys)})
+		return
+	}
+
+	template := model.ApiKey{
+		AllowedServers:     req.AllowedServers,
+		AllowedTools:       req.AllowedTools,
+		AllowedPrompts:     req.AllowedPrompts,
+		AllowedResources:   req.AllowedResources,
+		TeamID:             req.TeamID,
+		OutputFormat:       req.OutputFormat,
+		CatalogVersion:     req.CatalogVersion,
+		Roots:              req.Roots,
+		DeclineElicitation: req.DeclineElicitation,
+		ReadOnlyTools:      req.ReadOnlyTools,
+		Stateless:          req.Stateless,
+		SyncMessages:       req.SyncMessages,
+		ExpiresAt:          req.ExpiresAt,
+	}
+	if err := h.validateKey(&template); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	keys := make([]model.ApiKey, req.Count)
+	for i := range keys {
+		keys[i] = template
+		keys[i].Key = "sk-" + uuid.New().String()
+		keys[i].Description = strings.ReplaceAll(req.Description, "{n}", strconv.Itoa(i+1))
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(&keys, 100).Error
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	for _, key := range keys {
+		h.recordChange(c, "create", "key", key.ID, key)
+	}
+	fmt.Printf("[Keys] Created %d keys in bulk\n", len(keys))
+	c.JSON(200, gin.H{"keys": keys})
+}
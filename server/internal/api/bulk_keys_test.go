@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestCreateKeysBulk(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.ApiKey{}, &model.ConfigChange{}, &model.CatalogVersion{})
+
+	h := &Handler{db: db, settings: &config.Config{}}
+	r := gin.New()
+	r.POST("/keys/bulk", h.CreateKeysBulk)
+	r.GET("/sse", func(c *gin.Context) {
+		if _, ok := h.authenticateKey(c); ok {
+			c.Status(200)
+		}
+	})
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/keys/bulk", strings.NewReader(body)))
+		return w
+	}
+
+	expires := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	w := post(`{"count": 3, "description": "Workshop seat {n}", "allowed_servers": "[\"1\"]", "read_only_tools": true, "expires_at": "` + expires + `"}`)
+	assert.Equal(t, 200, w.Code)
+	var resp struct {
+		Keys []model.ApiKey `json:"keys"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Len(t, resp.Keys, 3)
+	seen := map[string]bool{}
+	for i, key := range resp.Keys {
+		assert.NotZero(t, key.ID)
+		assert.True(t, strings.HasPrefix(key.Key, "sk-"))
+		seen[key.Key] = true
+		assert.Equal(t, "Workshop seat "+strconv.Itoa(i+1), key.Description)
+		assert.Equal(t, `["1"]`, key.AllowedServers)
+		assert.True(t, key.ReadOnlyTools)
+		assert.NotNil(t, key.ExpiresAt)
+	}
+	assert.Len(t, seen, 3, "every key is unique")
+	var changes int64
+	db.Model(&model.ConfigChange{}).Where("resource = ?", "key").Count(&changes)
+	assert.Equal(t, int64(3), changes)
+
+	assert.Equal(t, 400, post(`{"count": 0}`).Code)
+	assert.Equal(t, 400, post(`{"count": 501}`).Code)
+	assert.Equal(t, 400, post(`{"count": 2, "output_format": "yaml"}`).Code)
+	assert.Equal(t, 400, post(`{"count": 2, "expires_at": "2000-01-01T00:00:00Z"}`).Code)
+	var total int64
+	db.Model(&model.ApiKey{}).Count(&total)
+	assert.Equal(t, int64(3), total, "invalid requests create nothing")
+
+	t.Run("Expired Keys Are Refused", func(t *testing.T) {
+		auth := func(key string) int {
+			req := httptest.NewRequest("GET", "/sse", nil)
+			req.Header.Set("Authorization", "Bearer "+key)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Code
+		}
+		assert.Equal(t, 200, auth(resp.Keys[0].Key))
+		past := time.Now().Add(-time.Minute)
+		db.Model(&resp.Keys[0]).Update("expires_at", past)
+		assert.Equal(t, 401, auth(resp.Keys[0].Key))
+	})
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListCalls searches the call history.
+// Filters: key_id, tool, status (success|error), from/to (RFC3339), q (argument text,
+// only matches recorded calls), limit (default 50, max 500), offset.
+func (h *Handler) ListCalls(c *gin.Context) {
+	query := h.reads().Model(&model.UsageLog{})
+
+	if keyID := c.Query("key_id"); keyID != "" {
+		query = query.Where("key_id = ?", keyID)
+	}
+	if tool := c.Query("tool"); tool != "" {
+		query = query.Where("tool = ?", tool)
+	}
+	switch c.Query("status") {
+	case "":
+	case "success":
+		query = query.Where("success = ?", true)
+	case "error":
+		query = query.Where("success = ?", false)
+	default:
+		c.JSON(400, gin.H{"error": "status must be success or error"})
+		return
+	}
+	for param, op := range map[string]string{"from": ">=", "to": "<="} {
+		if v := c.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Invalid " + param + " time, expected RFC3339"})
+				return
+			}
+			query = query.Where("created_at "+op+" ?", t)
+		}
+	}
+	if q := c.Query("q"); q != "" {
+		query = query.Where("id IN (?)", h.reads().Model(&model.CallRecording{}).Select("usage_log_id").Where("request LIKE ?", "%"+q+"%"))
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var calls []model.UsageLog
+	query.Order("id desc").Limit(limit).Offset(offset).Find(&calls)
+
+	// Mark which calls have a recorded payload
+	ids := make([]uint, len(calls))
+	for i, call := range calls {
+		ids[i] = call.ID
+	}
+	var recorded []uint
+	if len(ids) > 0 {
+		h.reads().Model(&model.CallRecording{}).Where("usage_log_id IN ?", ids).Pluck("usage_log_id", &recorded)
+	}
+	hasRecording := make(map[uint]bool, len(recorded))
+	for _, id := range recorded {
+		hasRecording[id] = true
+	}
+
+	items := make([]gin.H, len(calls))
+	for i, call := range calls {
+		items[i] = gin.H{
+			"id":            call.ID,
+			"created_at":    call.CreatedAt,
+			"key_id":        call.KeyID,
+			"team_id":       call.TeamID,
+			"server":        call.Server,
+			"tool":          call.Tool,
+			"success":       call.Success,
+			"error":         call.Error,
+			"duration_ms":   call.DurationMs,
+			"has_recording": hasRecording[call.ID],
+		}
+	}
+
+	c.JSON(200, gin.H{"total": total, "items": items})
+}
+
+// GetCall returns a call with its recorded request and response, if any.
+func (h *Handler) GetCall(c *gin.Context) {
+	var call model.UsageLog
+	if err := h.reads().First(&call, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+
+	result := gin.H{"call": call, "request": nil, "response": nil}
+	var recording model.CallRecording
+	if err := h.reads().Where("usage_log_id = ?", call.ID).First(&recording).Error; err == nil {
+		result["request"] = json.RawMessage(recording.Request)
+		result["response"] = rawOrString(core.RecordedResponse(h.reads(), &recording))
+	}
+	c.JSON(200, result)
+}
+
+// rawOrString embeds s as JSON if it is valid JSON, otherwise as a string.
+func rawOrString(s string) interface{} {
+	if json.Valid([]byte(s)) {
+		return json.RawMessage(s)
+	}
+	return s
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestCallHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(model.All...))
+
+	day := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	calls := []model.UsageLog{
+		{KeyID: 1, Server: "demo", Tool: "demo__echo", Success: true, CreatedAt: day.Add(time.Hour)},
+		{KeyID: 1, Server: "demo", Tool: "demo__add", Success: false, Error: "bad args", CreatedAt: day.Add(2 * time.Hour)},
+		{KeyID: 2, Server: "demo", Tool: "demo__echo", Success: true, CreatedAt: day.Add(26 * time.Hour)},
+	}
+	for i := range calls {
+		db.Create(&calls[i])
+	}
+	db.Create(&model.CallRecording{UsageLogID: 1, Request: `{"name":"demo__echo","arguments":{"text":"needle"}}`, Response: `{"text":"needle"}`})
+	db.Create(&model.CallRecording{UsageLogID: 2, Request: `{"name":"demo__add","arguments":{"a":"x"}}`, Response: "not json"})
+
+	h := &Handler{db: db, settings: &config.Config{}}
+	r := gin.New()
+	r.GET("/calls", h.ListCalls)
+	r.GET("/calls/:id", h.GetCall)
+	get := func(path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+	ids := func(path string) []float64 {
+		code, body := get(path)
+		require.Equal(t, 200, code, body)
+		var ids []float64
+		for _, item := range body["items"].([]interface{}) {
+			ids = append(ids, item.(map[string]interface{})["id"].(float64))
+		}
+		return ids
+	}
+
+	t.Run("Filters", func(t *testing.T) {
+		assert.Equal(t, []float64{3, 2, 1}, ids("/calls"))
+		assert.Equal(t, []float64{2, 1}, ids("/calls?key_id=1"))
+		assert.Equal(t, []float64{3, 1}, ids("/calls?tool=demo__echo"))
+		assert.Equal(t, []float64{2}, ids("/calls?status=error"))
+		assert.Equal(t, []float64{2, 1}, ids("/calls?from=2026-05-01T00:00:00Z&to=2026-05-01T23:59:59Z"))
+		assert.Equal(t, []float64{1}, ids("/calls?q=needle"))
+		assert.Equal(t, []float64{2}, ids("/calls?limit=1&offset=1"))
+	})
+
+	t.Run("Listing", func(t *testing.T) {
+		_, body := get("/calls?limit=1")
+		assert.Equal(t, float64(3), body["total"])
+		_, body = get("/calls?key_id=1")
+		items := body["items"].([]interface{})
+		assert.Equal(t, true, items[1].(map[string]interface{})["has_recording"])
+		_, body = get("/calls?key_id=2")
+		assert.Equal(t, false, body["items"].([]interface{})[0].(map[string]interface{})["has_recording"])
+	})
+
+	t.Run("Invalid Filters", func(t *testing.T) {
+		code, _ := get("/calls?status=maybe")
+		assert.Equal(t, 400, code)
+		code, body := get("/calls?from=yesterday")
+		assert.Equal(t, 400, code)
+		assert.Contains(t, body["error"], "from")
+	})
+
+	t.Run("Recorded Payloads", func(t *testing.T) {
+		code, body := get("/calls/1")
+		require.Equal(t, 200, code)
+		assert.Equal(t, map[string]interface{}{"name": "demo__echo", "arguments": map[string]interface{}{"text": "needle"}}, body["request"])
+		assert.Equal(t, map[string]interface{}{"text": "needle"}, body["response"])
+
+		_, body = get("/calls/2")
+		assert.Equal(t, "not json", body["response"], "a response that is not JSON is returned as a string")
+
+		_, body = get("/calls/3")
+		assert.Nil(t, body["request"])
+		assert.Nil(t, body["response"])
+
+		code, _ = get("/calls/9")
+		assert.Equal(t, 404, code)
+	})
+}
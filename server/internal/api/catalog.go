@@ -0,0 +1,134 @@
+package api
+
+import (
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListCatalogVersions lists tool catalog snapshots with the number of keys pinned to each.
+func (h *Handler) ListCatalogVersions(c *gin.Context) {
+	var versions []model.CatalogVersion
+	h.reads().Order("id desc").Find(&versions)
+
+	var pins []struct {
+		CatalogVersion uint
+		Count          int64
+	}
+	h.db.Model(&model.ApiKey{}).Select("catalog_version, count(*) as count").
+		Where("catalog_version <> 0").Group("catalog_version").Scan(&pins)
+	pinned := make(map[uint]int64, len(pins))
+	for _, p := range pins {
+		pinned[p.CatalogVersion] = p.Count
+	}
+
+	items := make([]gin.H, len(versions))
+	for i, v := range versions {
+		items[i] = gin.H{
+			"id":          v.ID,
+			"created_at":  v.CreatedAt,
+			"note":        v.Note,
+			"tool_count":  v.ToolCount,
+			"pinned_keys": pinned[v.ID],
+		}
+	}
+	c.JSON(200, items)
+}
+
+// CreateCatalogVersion snapshots the current aggregated tool catalog.
+func (h *Handler) CreateCatalogVersion(c *gin.Context) {
+	var req struct {
+		Note string `json:"note"`
+	}
+	c.ShouldBindJSON(&req)
+
+	version, err := h.gateway.SnapshotCatalog(req.Note)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, version)
+}
+
+// GetCatalogVersion returns a snapshot's tools and how they differ from the live catalog.
+func (h *Handler) GetCatalogVersion(c *gin.Context) {
+	var version model.CatalogVersion
+	if err := h.db.First(&version, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+
+	tools := h.gateway.CatalogVersionTools(version.ID)
+	list := make([]map[string]interface{}, 0, len(tools))
+	for _, tool := range tools {
+		list = append(list, tool)
+	}
+
+	result := gin.H{"version": version, "tools": list}
+	if live, err := h.gateway.GetAllTools(); err == nil {
+		result["diff"] = core.CatalogVersionDiff(tools, live)
+	}
+	c.JSON(200, result)
+}
+
+// PromoteCatalogVersion moves pinned keys to this version. By default all pinned keys
+// are promoted; "from_version" or "key_ids" restrict which keys are moved.
+func (h *Handler) PromoteCatalogVersion(c *gin.Context) {
+	var version model.CatalogVersion
+	if err := h.db.First(&version, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+
+	var req struct {
+		FromVersion uint   `json:"from_version"`
+		KeyIDs      []uint `json:"key_ids"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	query := h.db.Model(&model.ApiKey{})
+	switch {
+	case len(req.KeyIDs) > 0:
+		query = query.Where("id IN ?", req.KeyIDs)
+	case req.FromVersion != 0:
+		query = query.Where("catalog_version = ?", req.FromVersion)
+	default:
+		query = query.Where("catalog_version <> 0")
+	}
+	res := query.Update("catalog_version", version.ID)
+	if res.Error != nil {
+		c.JSON(500, gin.H{"error": res.Error.Error()})
+		return
+	}
+	if res.RowsAffected > 0 {
+		h.recordChange(c, "promote", "catalog_version", version.ID, gin.H{"from_version": req.FromVersion, "key_ids": req.KeyIDs, "promoted": res.RowsAffected})
+	}
+	c.JSON(200, gin.H{"status": "ok", "promoted": res.RowsAffected})
+}
+
+// DeleteCatalogVersion removes a snapshot that no key is pinned to.
+func (h *Handler) DeleteCatalogVersion(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var pinned int64
+	h.db.Model(&model.ApiKey{}).Where("catalog_version = ?", id).Count(&pinned)
+	if pinned > 0 {
+		c.JSON(409, gin.H{"error": "Catalog version is pinned by API keys, promote them first"})
+		return
+	}
+
+	h.db.Delete(&model.CatalogVersion{}, id)
+	h.gateway.ForgetCatalogVersion(uint(id))
+	c.JSON(200, gin.H{"status": "ok"})
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestCatalogVersionAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(model.All...))
+
+	settings := &config.Config{}
+	h := &Handler{db: db, gateway: core.NewGateway(db, settings), settings: settings}
+	r := gin.New()
+	r.GET("/versions", h.ListCatalogVersions)
+	r.POST("/versions", h.CreateCatalogVersion)
+	r.GET("/versions/:id", h.GetCatalogVersion)
+	r.POST("/versions/:id/promote", h.PromoteCatalogVersion)
+	r.DELETE("/versions/:id", h.DeleteCatalogVersion)
+	do := func(method string, path string, body string) (int, string) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w.Code, w.Body.String()
+	}
+	pins := func() []uint {
+		var keys []model.ApiKey
+		db.Order("id").Find(&keys)
+		versions := make([]uint, len(keys))
+		for i, key := range keys {
+			versions[i] = key.CatalogVersion
+		}
+		return versions
+	}
+
+	for i := 0; i < 3; i++ {
+		code, body := do("POST", "/versions", `{"note": "release"}`)
+		require.Equal(t, 200, code, body)
+	}
+	for i, version := range []uint{1, 1, 2, 0} {
+		key := model.ApiKey{Key: fmt.Sprintf("sk-%d", i), CatalogVersion: version}
+		require.NoError(t, db.Create(&key).Error)
+	}
+
+	t.Run("List Counts Pinned Keys", func(t *testing.T) {
+		_, body := do("GET", "/versions", "")
+		var versions []map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(body), &versions))
+		require.Len(t, versions, 3)
+		assert.Equal(t, float64(3), versions[0]["id"], "newest first")
+		assert.Equal(t, float64(0), versions[0]["pinned_keys"])
+		assert.Equal(t, float64(1), versions[1]["pinned_keys"])
+		assert.Equal(t, float64(2), versions[2]["pinned_keys"])
+		assert.Equal(t, "release", versions[2]["note"])
+
+		code, _ := do("GET", "/versions/1", "")
+		assert.Equal(t, 200, code)
+		code, _ = do("GET", "/versions/9", "")
+		assert.Equal(t, 404, code)
+	})
+
+	t.Run("Promote", func(t *testing.T) {
+		code, body := do("POST", "/versions/2/promote", `{"key_ids": [1]}`)
+		assert.Equal(t, 200, code)
+		assert.JSONEq(t, `{"status": "ok", "promoted": 1}`, body)
+		assert.Equal(t, []uint{2, 1, 2, 0}, pins())
+
+		do("POST", "/versions/3/promote", `{"from_version": 1}`)
+		assert.Equal(t, []uint{2, 3, 2, 0}, pins())
+
+		// Unpinned keys follow the live catalog and are never promoted
+		do("POST", "/versions/3/promote", "")
+		assert.Equal(t, []uint{3, 3, 3, 0}, pins())
+
+		code, _ = do("POST", "/versions/9/promote", "")
+		assert.Equal(t, 404, code)
+		var changes int64
+		db.Model(&model.ConfigChange{}).Where("action = ?", "promote").Count(&changes)
+		assert.Equal(t, int64(3), changes)
+	})
+
+	t.Run("Pinned Versions Cannot Be Deleted", func(t *testing.T) {
+		code, _ := do("DELETE", "/versions/3", "")
+		assert.Equal(t, 409, code)
+		code, _ = do("DELETE", "/versions/1", "")
+		assert.Equal(t, 200, code)
+		code, _ = do("GET", "/versions/1", "")
+		assert.Equal(t, 404, code)
+	})
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redactedFields are JSON fields holding credentials. They are masked in changefeed
+// snapshots, where only the fact that they changed is told, and in lists served to
+// admin tokens.
+var redactedFields = map[string]bool{
+	"key":                 true,
+	"auth_token":          true,
+	"signing_secret":      true,
+	"env":                 true,
+	"headers":             true,
+	"path_slug":           true,
+	"proxy_url":           true,
+	"tls_client_key":      true,
+	"oauth_client_secret": true,
+}
+
+// redactSnapshot marshals a resource for the changefeed with credentials masked.
+func redactSnapshot(data interface{}) string {
+	if data == nil {
+		return ""
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return ""
+	}
+	var fields map[string]interface{}
+	if json.Unmarshal(raw, &fields) != nil {
+		return string(raw)
+	}
+	redactFields(fields)
+	raw, _ = json.Marshal(fields)
+	return string(raw)
+}
+
+func redactFields(fields map[string]interface{}) {
+	for name, value := range fields {
+		if redactedFields[name] && value != "" {
+			fields[name] = "[redacted]"
+		}
+	}
+}
+
+// redactList returns the JSON form of a list of resources with credentials masked.
+func redactList(list interface{}) []map[string]interface{} {
+	raw, _ := json.Marshal(list)
+	var items []map[string]interface{}
+	json.Unmarshal(raw, &items)
+	for _, fields := range items {
+		redactFields(fields)
+	}
+	return items
+}
+
+// recordChange appends a configuration mutation to the changefeed. data is the
+// resource after the change, or nil for deletions.
+func (h *Handler) recordChange(c *gin.Context, action string, resource string, id interface{}, data interface{}) {
+	change := model.ConfigChange{
+		Actor:      c.GetString("username"),
+		Action:     action,
+		Resource:   resource,
+		ResourceID: fmt.Sprint(id),
+		Data:       redactSnapshot(data),
+	}
+	if err := h.db.Create(&change).Error; err != nil {
+		fmt.Printf("[Changes] Failed to record %s of %s %v: %v\n", action, resource, id, err)
+	}
+}
+
+// ListChanges returns changefeed entries after a cursor, oldest first, so external
+// systems can follow configuration changes. Filters: after (cursor, default 0),
+// resource, limit (default 100, max 1000). next_cursor is passed as after to
+// fetch the following page; it equals the request cursor when nothing is new.
+func (h *Handler) ListChanges(c *gin.Context) {
+	after, err := strconv.ParseUint(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	query := h.reads().Where("id > ?", after)
+	if resource := c.Query("resource"); resource != "" {
+		query = query.Where("resource = ?", resource)
+	}
+
+	changes := []model.ConfigChange{}
+	query.Order("id").Limit(limit).Find(&changes)
+
+	next := after
+	if len(changes) > 0 {
+		next = uint64(changes[len(changes)-1].ID)
+	}
+	c.JSON(200, gin.H{"changes": changes, "next_cursor": next})
+}
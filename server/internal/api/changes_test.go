@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"one-mcp/internal/model"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestChangefeed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.ConfigChange{})
+	h := &Handler{db: db}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("username", "admin")
+	h.recordChange(c, "create", "key", 1, model.ApiKey{ID: 1, Key: "sk-secret", Description: "ci"})
+	h.recordChange(c, "delete", "team", 2, nil)
+	c.Set("username", "token:deploy")
+	h.recordChange(c, "update", "key", 1, model.ApiKey{ID: 1, Key: "sk-secret", Description: "ci"})
+
+	list := func(query string) (changes []model.ConfigChange, next uint) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/api/v1/changes?"+query, nil)
+		h.ListChanges(c)
+		var resp struct {
+			Changes    []model.ConfigChange `json:"changes"`
+			NextCursor uint                 `json:"next_cursor"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Changes, resp.NextCursor
+	}
+
+	t.Run("Pages With The Cursor", func(t *testing.T) {
+		changes, next := list("limit=2")
+		assert.Len(t, changes, 2)
+		assert.Equal(t, "create", changes[0].Action)
+		assert.Equal(t, "admin", changes[0].Actor)
+		assert.Equal(t, uint(2), next)
+
+		changes, next = list("after=2")
+		assert.Len(t, changes, 1)
+		assert.Equal(t, "token:deploy", changes[0].Actor)
+		assert.Equal(t, uint(3), next)
+
+		changes, next = list("after=3")
+		assert.Empty(t, changes)
+		assert.Equal(t, uint(3), next)
+	})
+
+	t.Run("Filters By Resource", func(t *testing.T) {
+		changes, _ := list("resource=team")
+		assert.Len(t, changes, 1)
+		assert.Equal(t, "2", changes[0].ResourceID)
+		assert.Empty(t, changes[0].Data)
+	})
+
+	t.Run("Redacts Credentials", func(t *testing.T) {
+		changes, _ := list("resource=key")
+		var data map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(changes[0].Data), &data))
+		assert.Equal(t, "[redacted]", data["key"])
+		assert.Equal(t, "ci", data["description"])
+		assert.Equal(t, "", data["signing_secret"])
+	})
+}
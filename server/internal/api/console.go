@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/core"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// UpstreamConsole attaches an interactive JSON-RPC console to an upstream over a
+// websocket. Every text frame from the admin is a raw JSON-RPC message: requests
+// are forwarded and their raw responses returned with the admin's ID, notifications
+// are sent as is. Notifications from the upstream are streamed to the console.
+func (h *Handler) UpstreamConsole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return
+	}
+	client, ok := h.gateway.UpstreamByID(uint(id))
+	if !ok {
+		c.JSON(404, gin.H{"error": "Server is not running"})
+		return
+	}
+	admin, _ := c.Get("username")
+
+	websocket.Handler(func(ws *websocket.Conn) {
+		defer ws.Close()
+		fmt.Printf("[Console] %v attached to upstream %s\n", admin, client.Config.Name)
+		defer fmt.Printf("[Console] %v detached from upstream %s\n", admin, client.Config.Name)
+
+		var writeMu sync.Mutex
+		write := func(msg []byte) {
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			websocket.Message.Send(ws, string(msg))
+		}
+		writeError := func(id *json.RawMessage, code int, message string) {
+			resp, _ := json.Marshal(&core.JSONRPCMessage{
+				JSONRPC: "2.0",
+				ID:      id,
+				Error:   &core.JSONRPCError{Code: code, Message: message},
+			})
+			write(resp)
+		}
+
+		notifications, untap := client.Tap()
+		defer untap()
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			for {
+				select {
+				case msg := <-notifications:
+					write(msg)
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		for {
+			var frame string
+			if err := websocket.Message.Receive(ws, &frame); err != nil {
+				return
+			}
+
+			var msg core.JSONRPCMessage
+			if err := json.Unmarshal([]byte(frame), &msg); err != nil {
+				writeError(nil, -32700, "Parse error: "+err.Error())
+				continue
+			}
+			fmt.Printf("[Console] %v -> %s: %s\n", admin, client.Config.Name, frame)
+
+			if msg.ID == nil {
+				if err := client.SendRaw([]byte(frame)); err != nil {
+					writeError(nil, -32000, err.Error())
+				}
+				continue
+			}
+
+			// Requests run concurrently so a slow call does not block the console
+			go func(msg core.JSONRPCMessage) {
+				var params interface{}
+				if len(msg.Params) > 0 {
+					params = msg.Params
+				}
+				resp, err := client.Call(msg.Method, params)
+				if err != nil {
+					writeError(msg.ID, -32000, err.Error())
+					return
+				}
+				resp.ID = msg.ID
+				out, _ := json.Marshal(resp)
+				write(out)
+			}(msg)
+		}
+	}).ServeHTTP(c.Writer, c.Request)
+}
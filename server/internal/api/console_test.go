@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+	"gorm.io/gorm"
+)
+
+func TestUpstreamConsole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(model.All...))
+	db.Create(&model.UpstreamServer{Name: "demo", TransportType: "demo", Enabled: true})
+	db.Create(&model.AdminToken{Name: "ops", TokenHash: hashAdminToken("at-ops"), Scope: ScopeAdmin})
+
+	settings := config.Default()
+	h := &Handler{db: db, gateway: core.NewGateway(db, settings), settings: settings}
+	h.gateway.ReloadUpstreams()
+	t.Cleanup(h.gateway.StopUpstreams)
+	require.Eventually(t, func() bool {
+		client, ok := h.gateway.UpstreamByID(1)
+		return ok && client.IsReady()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	r := gin.New()
+	h.RegisterRoutes(r)
+	server := httptest.NewServer(r)
+	defer server.Close()
+	dial := func(path string) (*websocket.Conn, error) {
+		return websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+path, "", "http://localhost")
+	}
+
+	_, err = dial("/api/v1/servers/1/console")
+	assert.Error(t, err, "the console needs admin credentials")
+	_, err = dial("/api/v1/servers/2/console?token=at-ops")
+	assert.Error(t, err, "only running servers have a console")
+
+	// Browsers pass the token in the query, as they cannot set headers
+	ws, err := dial("/api/v1/servers/1/console?token=at-ops")
+	require.NoError(t, err)
+	defer ws.Close()
+	receive := func() core.JSONRPCMessage {
+		ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var frame string
+		require.NoError(t, websocket.Message.Receive(ws, &frame))
+		var msg core.JSONRPCMessage
+		require.NoError(t, json.Unmarshal([]byte(frame), &msg))
+		return msg
+	}
+
+	require.NoError(t, websocket.Message.Send(ws, `{"jsonrpc":"2.0","id":"mine","method":"tools/list"}`))
+	resp := receive()
+	assert.Equal(t, `"mine"`, string(*resp.ID), "responses carry the console's request ID")
+	assert.Nil(t, resp.Error)
+	assert.Contains(t, string(resp.Result), "calculator")
+
+	require.NoError(t, websocket.Message.Send(ws, `{"jsonrpc":"2.0","id":7,"method":"nope"}`))
+	resp = receive()
+	assert.Equal(t, "7", string(*resp.ID))
+	require.NotNil(t, resp.Error)
+	assert.Contains(t, resp.Error.Message, "Method not found")
+
+	require.NoError(t, websocket.Message.Send(ws, `not json`))
+	assert.Equal(t, -32700, receive().Error.Code)
+}
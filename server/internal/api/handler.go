@@ -4,15 +4,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"math"
+	"one-mcp/internal/config"
 	"one-mcp/internal/core"
 	"one-mcp/internal/model"
-	"os"
+	"one-mcp/internal/oauth"
+	"one-mcp/internal/report"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
@@ -21,27 +27,40 @@ import (
 
 var jwtSecret []byte
 
-func init() {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		// Use a fixed fallback for development convenience but log warning
-		// In production this should be set
-		secret = "one-mcp-secret-key-change-me"
-		log.Println("[WARNING] JWT_SECRET not set, using default insecure key. Please set JWT_SECRET env var.")
+type Handler struct {
+	db       *gorm.DB
+	replica  *gorm.DB // Optional read-only replica for stats and history
+	gateway  *core.Gateway
+	settings *config.Config
+	oauth    *oauth.Verifier // Validates OAuth access tokens, nil unless OAUTH_ISSUER is set
+}
+
+func NewHandler(db *gorm.DB, gateway *core.Gateway, settings *config.Config) *Handler {
+	jwtSecret = []byte(settings.JWTSecret)
+	h := &Handler{
+		db:       db,
+		gateway:  gateway,
+		settings: settings,
+	}
+	if settings.OAuthIssuer != "" {
+		h.oauth = oauth.NewVerifier(settings.OAuthIssuer, settings.OAuthResource(), settings.OAuthJWKSURL)
 	}
-	jwtSecret = []byte(secret)
+	h.pruneSessionRecords()
+	return h
 }
 
-type Handler struct {
-	db      *gorm.DB
-	gateway *core.Gateway
+// SetReadReplica routes stats and history queries to a read-only replica of the
+// database. They may then lag behind the primary by the replication delay.
+func (h *Handler) SetReadReplica(replica *gorm.DB) {
+	h.replica = replica
 }
 
-func NewHandler(db *gorm.DB, gateway *core.Gateway) *Handler {
-	return &Handler{
-		db:      db,
-		gateway: gateway,
+// reads returns the database for stats and history queries.
+func (h *Handler) reads() *gorm.DB {
+	if h.replica != nil {
+		return h.replica
 	}
+	return h.db
 }
 
 // Admin APIs
@@ -85,6 +104,10 @@ func (h *Handler) Login(c *gin.Context) {
 func (h *Handler) AdminAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
+		// Browsers cannot set headers on websocket connections
+		if authHeader == "" && strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+			authHeader = c.Query("token")
+		}
 		if authHeader == "" {
 			c.JSON(401, gin.H{"error": "Authorization header required"})
 			c.Abort()
@@ -92,6 +115,10 @@ func (h *Handler) AdminAuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		if strings.HasPrefix(tokenString, AdminTokenPrefix) {
+			h.authenticateAdminToken(c, tokenString)
+			return
+		}
 		
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -145,19 +172,113 @@ func (h *Handler) ChangePassword(c *gin.Context) {
 
 	admin.Password = string(hashedPassword)
 	h.db.Save(&admin)
+	h.recordChange(c, "update", "admin_password", admin.ID, nil)
 
 	c.JSON(200, gin.H{"status": "ok", "message": "Password changed successfully"})
 }
 
+// serverView is a server as returned by the admin API. Write-only credentials are
+// replaced by whether they are set.
+type serverView struct {
+	model.UpstreamServer
+	HasTLSClientKey      bool `json:"has_tls_client_key"`
+	HasOAuthClientSecret bool `json:"has_oauth_client_secret"`
+}
+
+func newServerView(server model.UpstreamServer) serverView {
+	return serverView{
+		UpstreamServer:       server,
+		HasTLSClientKey:      server.TLSClientKey != "",
+		HasOAuthClientSecret: server.OAuthClientSecret != "",
+	}
+}
+
+// serverSecrets are the write-only credentials of a server in a create or update
+// request. On update, absent ones are kept and empty ones cleared.
+type serverSecrets struct {
+	TLSClientKey      *string `json:"tls_client_key"`
+	OAuthClientSecret *string `json:"oauth_client_secret"`
+}
+
+// bindServer binds a create or update request onto server, write-only
+// credentials included.
+func bindServer(c *gin.Context, server *model.UpstreamServer) error {
+	if err := c.ShouldBindBodyWith(server, binding.JSON); err != nil {
+		return err
+	}
+	var secrets serverSecrets
+	if err := c.ShouldBindBodyWith(&secrets, binding.JSON); err != nil {
+		return err
+	}
+	if secrets.TLSClientKey != nil {
+		server.TLSClientKey = *secrets.TLSClientKey
+	}
+	if secrets.OAuthClientSecret != nil {
+		server.OAuthClientSecret = *secrets.OAuthClientSecret
+	}
+	return nil
+}
+
+// serverWithStatus is a server as listed by the admin API, with the connection
+// status of its running client.
+type serverWithStatus struct {
+	serverView
+	Status core.UpstreamStatus `json:"status"`
+}
+
+// disabledStatus is the status of a server without a running client.
+func disabledStatus(server model.UpstreamServer) core.UpstreamStatus {
+	return core.UpstreamStatus{ID: server.ID, Name: server.Name, State: core.StateDisabled}
+}
+
 func (h *Handler) ListServers(c *gin.Context) {
 	var servers []model.UpstreamServer
 	h.db.Find(&servers)
-	c.JSON(200, servers)
+
+	filtered := h.gateway.FilteredToolCounts()
+	statuses := make(map[uint]core.UpstreamStatus)
+	for _, status := range h.gateway.UpstreamStatuses() {
+		statuses[status.ID] = status
+	}
+	result := make([]serverWithStatus, len(servers))
+	for i, server := range servers {
+		server.FilteredTools = filtered[server.Name]
+		status, ok := statuses[server.ID]
+		if !ok {
+			status = disabledStatus(server)
+		}
+		result[i] = serverWithStatus{serverView: newServerView(server), Status: status}
+	}
+	if tokenAuthenticated(c) {
+		c.JSON(200, redactList(result))
+		return
+	}
+	c.JSON(200, result)
+}
+
+func (h *Handler) ListServerStatuses(c *gin.Context) {
+	c.JSON(200, h.gateway.UpstreamStatuses())
+}
+
+// GetServerStatus returns the connection status of one server: its state
+// (connecting, ready, error, failed, or disabled if it is not running), last
+// error, last successful call and reconnects.
+func (h *Handler) GetServerStatus(c *gin.Context) {
+	var server model.UpstreamServer
+	if err := h.db.First(&server, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+	status, ok := h.gateway.UpstreamStatusByID(server.ID)
+	if !ok {
+		status = disabledStatus(server)
+	}
+	c.JSON(200, status)
 }
 
 func (h *Handler) CreateServer(c *gin.Context) {
 	var server model.UpstreamServer
-	if err := c.ShouldBindJSON(&server); err != nil {
+	if err := bindServer(c, &server); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
@@ -174,6 +295,79 @@ func (h *Handler) CreateServer(c *gin.Context) {
 			c.JSON(400, gin.H{"error": err.Error()})
 			return
 		}
+		if err := core.ValidateCwd(server.Cwd); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if err := core.ValidateFraming(server.Framing, server.Encoding); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if server.TransportType == "unix" {
+		if err := core.ValidateSocketPath(server.SocketPath); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if err := core.ValidateFraming(server.Framing, server.Encoding); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := core.ValidateServerName(server.Name); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if server.FallbackServer != "" && server.FallbackServer == server.Name {
+		c.JSON(400, gin.H{"error": "Server cannot be its own fallback"})
+		return
+	}
+
+	if _, err := core.ParseToolSelector(server.IncludeTools, server.ExcludeTools, server.MaxTools); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := core.ParseAsyncTools(server.AsyncTools); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := core.ParseToolTimeouts(server); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := core.ParseToolFallbacks(server); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := core.ParseCertPin(server.PinnedCertSHA256); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := core.ParseHeaders(server.Headers); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := core.ParseTLSConfig(server); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := core.ParseProxyURL(server.ProxyURL); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validateSandbox(server); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := core.ParseModelPreferences(server.SamplingModelPreferences); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if server.SamplingMaxTokens < 0 {
+		c.JSON(400, gin.H{"error": "sampling_max_tokens must not be negative"})
+		return
 	}
 
 	fmt.Printf("[Debug] Creating Server: Name=%s Type=%s URL=%s Cmd=%s\n", server.Name, server.TransportType, server.URL, server.Command)
@@ -191,8 +385,9 @@ func (h *Handler) CreateServer(c *gin.Context) {
 	}
 
 	h.db.Create(&server)
+	h.recordChange(c, "create", "server", server.ID, server)
 	h.gateway.ReloadUpstreams()
-	c.JSON(200, server)
+	c.JSON(200, newServerView(server))
 }
 
 func (h *Handler) UpdateServer(c *gin.Context) {
@@ -202,7 +397,7 @@ func (h *Handler) UpdateServer(c *gin.Context) {
 		c.JSON(404, gin.H{"error": "not found"})
 		return
 	}
-	if err := c.ShouldBindJSON(&server); err != nil {
+	if err := bindServer(c, &server); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
@@ -219,18 +414,111 @@ func (h *Handler) UpdateServer(c *gin.Context) {
 			c.JSON(400, gin.H{"error": err.Error()})
 			return
 		}
+		if err := core.ValidateCwd(server.Cwd); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if err := core.ValidateFraming(server.Framing, server.Encoding); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if server.TransportType == "unix" {
+		if err := core.ValidateSocketPath(server.SocketPath); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if err := core.ValidateFraming(server.Framing, server.Encoding); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := core.ValidateServerName(server.Name); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if server.FallbackServer != "" && server.FallbackServer == server.Name {
+		c.JSON(400, gin.H{"error": "Server cannot be its own fallback"})
+		return
+	}
+
+	if _, err := core.ParseToolSelector(server.IncludeTools, server.ExcludeTools, server.MaxTools); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := core.ParseAsyncTools(server.AsyncTools); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := core.ParseToolTimeouts(server); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := core.ParseToolFallbacks(server); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := core.ParseCertPin(server.PinnedCertSHA256); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := core.ParseHeaders(server.Headers); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := core.ParseTLSConfig(server); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := core.ParseProxyURL(server.ProxyURL); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validateSandbox(server); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := core.ParseModelPreferences(server.SamplingModelPreferences); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if server.SamplingMaxTokens < 0 {
+		c.JSON(400, gin.H{"error": "sampling_max_tokens must not be negative"})
+		return
 	}
 
 	fmt.Printf("[Debug] Updating Server %s: Name=%s Type=%s URL=%s Cmd=%s\n", id, server.Name, server.TransportType, server.URL, server.Command)
 
-	h.db.Save(&server)
+	// Another server must not keep the name, or routing by name would be ambiguous
+	var existing model.UpstreamServer
+	if err := h.db.Unscoped().Where("name = ? AND id <> ?", server.Name, id).First(&existing).Error; err == nil {
+		if existing.DeletedAt.Valid {
+			h.db.Unscoped().Delete(&existing)
+		} else {
+			c.JSON(400, gin.H{"error": "Server name already exists"})
+			return
+		}
+	}
+
+	if err := h.db.Save(&server).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordChange(c, "update", "server", server.ID, server)
 	h.gateway.ReloadUpstreams()
-	c.JSON(200, server)
+	c.JSON(200, newServerView(server))
 }
 
 func (h *Handler) DeleteServer(c *gin.Context) {
 	id := c.Param("id")
-	h.db.Unscoped().Where("id = ?", id).Delete(&model.UpstreamServer{})
+	if h.db.Unscoped().Where("id = ?", id).Delete(&model.UpstreamServer{}).RowsAffected > 0 {
+		h.recordChange(c, "delete", "server", id, nil)
+		if serverID, err := strconv.ParseUint(id, 10, 64); err == nil {
+			h.gateway.UpstreamAuth().Forget(uint(serverID))
+		}
+	}
 	h.gateway.ReloadUpstreams()
 	c.JSON(200, gin.H{"status": "ok"})
 }
@@ -238,6 +526,10 @@ func (h *Handler) DeleteServer(c *gin.Context) {
 func (h *Handler) ListKeys(c *gin.Context) {
 	var keys []model.ApiKey
 	h.db.Find(&keys)
+	if tokenAuthenticated(c) {
+		c.JSON(200, redactList(keys))
+		return
+	}
 	c.JSON(200, keys)
 }
 
@@ -247,13 +539,48 @@ func (h *Handler) CreateKey(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	if err := h.validateKey(&key); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 	if key.Key == "" {
 		key.Key = "sk-" + uuid.New().String()
 	}
 	h.db.Create(&key)
+	h.recordChange(c, "create", "key", key.ID, key)
 	c.JSON(200, key)
 }
 
+// validateKey checks the settings of a new API key.
+func (h *Handler) validateKey(key *model.ApiKey) error {
+	if !core.ValidOutputFormat(key.OutputFormat) {
+		return fmt.Errorf("Invalid output format")
+	}
+	if key.CatalogVersion != 0 {
+		if err := h.db.First(&model.CatalogVersion{}, key.CatalogVersion).Error; err != nil {
+			return fmt.Errorf("Catalog version not found")
+		}
+	}
+	if _, err := core.ParseRoots(key.Roots); err != nil {
+		return err
+	}
+	if err := validateExpiry(key.ExpiresAt); err != nil {
+		return err
+	}
+	if err := h.validatePathSlug(key.ID, key.PathSlug); err != nil {
+		return err
+	}
+	return h.validateOAuthBinding(key.ID, key.OAuthSubject, key.OAuthScope)
+}
+
+// validateExpiry rejects an expiry that has already passed.
+func validateExpiry(expiresAt *time.Time) error {
+	if expiresAt != nil && expiresAt.Before(time.Now()) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+	return nil
+}
+
 func (h *Handler) UpdateKey(c *gin.Context) {
 	id := c.Param("id")
 	var key model.ApiKey
@@ -264,76 +591,418 @@ func (h *Handler) UpdateKey(c *gin.Context) {
 	
 	// We only bind specific fields to allow partial updates
 	var updateData struct {
-		Description    string `json:"description"`
-		AllowedServers string `json:"allowed_servers"`
-		AllowedTools   string `json:"allowed_tools"`
+		Description        string `json:"description"`
+		AllowedServers     string `json:"allowed_servers"`
+		AllowedTools       string `json:"allowed_tools"`
+		AllowedPrompts     *string `json:"allowed_prompts"`
+		AllowedResources   *string `json:"allowed_resources"`
+		TeamID             *uint  `json:"team_id"`
+		SigningSecret      *string `json:"signing_secret"` // Kept unless present
+		OutputFormat       *string `json:"output_format"`
+		CatalogVersion     *uint   `json:"catalog_version"`
+		Roots              *string `json:"roots"`
+		DeclineElicitation *bool   `json:"decline_elicitation"`
+		ReadOnlyTools      *bool   `json:"read_only_tools"`
+		Stateless          *bool   `json:"stateless"`
+		SyncMessages       *bool   `json:"sync_messages"`
+		ExpiresAt          json.RawMessage `json:"expires_at"` // Kept if absent, cleared if null
+		OAuthSubject       *string `json:"oauth_subject"`
+		OAuthScope         *string `json:"oauth_scope"`
 	}
 	
 	if err := c.ShouldBindJSON(&updateData); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	if updateData.OutputFormat != nil && !core.ValidOutputFormat(*updateData.OutputFormat) {
+		c.JSON(400, gin.H{"error": "Invalid output format"})
+		return
+	}
+	if updateData.CatalogVersion != nil && *updateData.CatalogVersion != 0 {
+		if err := h.db.First(&model.CatalogVersion{}, *updateData.CatalogVersion).Error; err != nil {
+			c.JSON(400, gin.H{"error": "Catalog version not found"})
+			return
+		}
+	}
+	if updateData.Roots != nil {
+		if _, err := core.ParseRoots(*updateData.Roots); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	clearExpiry := string(updateData.ExpiresAt) == "null"
+	var expiresAt *time.Time
+	if len(updateData.ExpiresAt) > 0 && !clearExpiry {
+		if err := json.Unmarshal(updateData.ExpiresAt, &expiresAt); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid expires_at"})
+			return
+		}
+	}
+	if err := validateExpiry(expiresAt); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	subject, scope := key.OAuthSubject, key.OAuthScope
+	if updateData.OAuthSubject != nil {
+		subject = *updateData.OAuthSubject
+	}
+	if updateData.OAuthScope != nil {
+		scope = *updateData.OAuthScope
+	}
+	if err := h.validateOAuthBinding(key.ID, subject, scope); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 	
 	key.Description = updateData.Description
 	key.AllowedServers = updateData.AllowedServers
 	key.AllowedTools = updateData.AllowedTools
+	if updateData.AllowedPrompts != nil {
+		key.AllowedPrompts = *updateData.AllowedPrompts
+	}
+	if updateData.AllowedResources != nil {
+		key.AllowedResources = *updateData.AllowedResources
+	}
+	if updateData.TeamID != nil {
+		key.TeamID = *updateData.TeamID
+	}
+	if updateData.SigningSecret != nil {
+		key.SigningSecret = *updateData.SigningSecret
+	}
+	if updateData.OutputFormat != nil {
+		key.OutputFormat = *updateData.OutputFormat
+	}
+	if updateData.CatalogVersion != nil {
+		key.CatalogVersion = *updateData.CatalogVersion
+	}
+	if updateData.Roots != nil {
+		key.Roots = *updateData.Roots
+	}
+	if updateData.DeclineElicitation != nil {
+		key.DeclineElicitation = *updateData.DeclineElicitation
+	}
+	if updateData.ReadOnlyTools != nil {
+		key.ReadOnlyTools = *updateData.ReadOnlyTools
+	}
+	if updateData.Stateless != nil {
+		key.Stateless = *updateData.Stateless
+	}
+	if updateData.SyncMessages != nil {
+		key.SyncMessages = *updateData.SyncMessages
+	}
+	if clearExpiry {
+		key.ExpiresAt = nil
+	} else if expiresAt != nil {
+		key.ExpiresAt = expiresAt
+	}
+	key.OAuthSubject = subject
+	key.OAuthScope = scope
 	
 	h.db.Save(&key)
+	h.recordChange(c, "update", "key", key.ID, key)
 	c.JSON(200, key)
 }
 
 func (h *Handler) DeleteKey(c *gin.Context) {
 	id := c.Param("id")
-	h.db.Where("id = ?", id).Delete(&model.ApiKey{})
+	if h.db.Where("id = ?", id).Delete(&model.ApiKey{}).RowsAffected > 0 {
+		h.recordChange(c, "delete", "key", id, nil)
+	}
 	c.JSON(200, gin.H{"status": "ok"})
 }
 
 func (h *Handler) ListAllTools(c *gin.Context) {
-	tools, err := h.gateway.GetAllTools()
+	tools, listed, err := h.gateway.ListAllTools()
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, tools)
+	if err := h.gateway.SyncCatalog(tools, listed); err != nil {
+		fmt.Printf("[Catalog] Failed to sync tool catalog: %v\n", err)
+	}
+
+	// Incremental sync: ?since=<cursor> returns only changes after the cursor
+	if since := c.Query("since"); since != "" {
+		cursor, err := strconv.ParseInt(since, 10, 64)
+		if err != nil || cursor < 0 {
+			c.JSON(400, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		delta, err := h.gateway.CatalogDelta(cursor)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, delta)
+		return
+	}
+
+	c.JSON(200, core.WithAnnotationHints(tools))
+}
+
+// StreamAllTools lists every tool like ListAllTools, but as an SSE stream that
+// does not wait for the slowest upstream: a "server" event carries the tools of
+// each server as soon as it answers, with the time it took or its error, and a
+// "done" event the total number of tools.
+func (h *Handler) StreamAllTools(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(200)
+	c.Writer.Flush()
+
+	tools, listed := h.gateway.StreamAllTools(func(listed core.ServerTools) {
+		listed.Tools = core.WithAnnotationHints(listed.Tools)
+		c.SSEvent("server", listed)
+		c.Writer.Flush()
+	})
+	if err := h.gateway.SyncCatalog(tools, listed); err != nil {
+		fmt.Printf("[Catalog] Failed to sync tool catalog: %v\n", err)
+	}
+	c.SSEvent("done", gin.H{"count": len(tools)})
+	c.Writer.Flush()
+}
+
+func (h *Handler) GetSchedulerStats(c *gin.Context) {
+	c.JSON(200, h.gateway.SchedulerStats())
+}
+
+func (h *Handler) GetRateLimits(c *gin.Context) {
+	c.JSON(200, h.gateway.RateLimits())
+}
+
+func (h *Handler) GetLimitStats(c *gin.Context) {
+	c.JSON(200, h.gateway.Limits().Stats())
+}
+
+func (h *Handler) ListModerationLogs(c *gin.Context) {
+	var logs []model.ModerationLog
+	query := h.reads().Order("id desc").Limit(200)
+	if c.Query("reviewed") == "false" {
+		query = query.Where("reviewed = ?", false)
+	}
+	query.Find(&logs)
+	c.JSON(200, logs)
+}
+
+func (h *Handler) ReviewModerationLog(c *gin.Context) {
+	id := c.Param("id")
+	var entry model.ModerationLog
+	if err := h.db.First(&entry, "id = ?", id).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+	entry.Reviewed = true
+	h.db.Save(&entry)
+	c.JSON(200, entry)
 }
 
 // MCP SSE Endpoints
 
 type Session struct {
-	MsgChan        chan []byte
-	AllowedServers []string
-	AllowedTools   []string
+	MsgChan chan []byte
+	Caller  *core.Caller
+
+	done  chan struct{} // Closed when the SSE stream ends
+	slots chan struct{} // Bounds concurrently processed messages
+
+	// Responses to POST /mcp/messages are returned in its body, not over SSE
+	// (the key's sync_messages, see HandleMessage)
+	syncMessages bool
+
+	// Legacy SSE sessions are persisted so their stream can be reopened after a
+	// gateway restart (see session_store.go), and outlive a dropped stream for
+	// SSE_RESUME_WINDOW (see resume.go)
+	resumable bool
+	end       func() // Ends the session, once it has no stream to reattach (also set for Streamable HTTP)
+	streamMu  sync.Mutex
+	attached  bool        // A stream is connected
+	expiry    *time.Timer // Ends the session while detached
+	seq       uint64      // ID of the last event written
+	sent      []sseEvent  // Last events written, oldest first, for replay
+
+	// Streamable HTTP sessions outlive their connections (see streamable.go)
+	streamable bool
+	lastActive atomic.Int64 // Unix nanoseconds of the last activity
+	active     atomic.Int32 // Requests and streams in progress
+}
+
+// Send writes a message to the SSE stream, waiting for buffer space.
+// It returns false if the stream has ended. It is the session's Caller.Notify;
+// messages go through Caller.Deliver, which keeps them in order.
+func (s *Session) Send(msg []byte) bool {
+	select {
+	case s.MsgChan <- msg:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// acquire waits for a processing slot, returning false if the session ended
+// first. Sessions without slots (stateless requests) do not wait.
+func (s *Session) acquire() bool {
+	if s.slots == nil {
+		return true
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// release frees a slot taken with acquire.
+func (s *Session) release() {
+	if s.slots != nil {
+		<-s.slots
+	}
 }
 
 var sessions sync.Map // map[string]*Session
 
+// authenticateKey resolves the API key of an MCP request, answering 401 if there
+// is none or it has expired. With OAUTH_ISSUER set, the bearer token may also be
+// an OAuth access token, which authenticates as the key bound to it. Under
+// /mcp/k/<slug>, the path slug authenticates instead (see key_paths.go).
+func (h *Handler) authenticateKey(c *gin.Context) (*model.ApiKey, bool) {
+	token := h.requestToken(c)
+
+	var apiKey *model.ApiKey
+	if slug := c.Param("slug"); slug != "" {
+		var ok bool
+		if apiKey, ok = h.keyForPath(c, slug); !ok {
+			return nil, false
+		}
+	} else if h.oauth != nil && oauth.LooksLikeJWT(token) {
+		var ok bool
+		if apiKey, ok = h.authenticateToken(c, token); !ok {
+			return nil, false
+		}
+	} else {
+		apiKey = &model.ApiKey{}
+		if token == "" || h.db.Where("key = ?", token).First(apiKey).Error != nil {
+			h.unauthorized(c, 401, "", "Unauthorized")
+			return nil, false
+		}
+	}
+	if apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt) {
+		h.unauthorized(c, 401, "invalid_token", "API key expired")
+		return nil, false
+	}
+	return apiKey, true
+}
+
+// requestToken returns the bearer token of an MCP request. Failing that, with
+// MCP_KEY_BASIC_AUTH the password (or else the username) of HTTP Basic auth is
+// the key, and with MCP_KEY_QUERY_PARAM the ?key= query parameter.
+func (h *Handler) requestToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+		return token
+	}
+	if user, password, ok := c.Request.BasicAuth(); ok {
+		if !h.settings.KeyBasicAuth {
+			return ""
+		}
+		if password != "" {
+			return password
+		}
+		return user
+	}
+	if header == "" && h.settings.KeyQueryParam {
+		return c.Query("key")
+	}
+	return header
+}
+
+// newCaller builds the caller of a new session, logging keys with full access for auditing.
+func (h *Handler) newCaller(apiKey *model.ApiKey) *core.Caller {
+	caller := core.CallerForKey(h.db, apiKey)
+	if len(caller.AllowedServers) == 0 && len(caller.AllowedTools) == 0 &&
+		len(caller.AllowedPrompts) == 0 && len(caller.AllowedResources) == 0 {
+		fmt.Printf("[Audit] Key '%s' (ID: %d) connected with FULL ACCESS.\n", apiKey.Key, apiKey.ID)
+	}
+	return caller
+}
+
 func (h *Handler) HandleSSE(c *gin.Context) {
-	// Auth
-	token := c.GetHeader("Authorization")
-	token = strings.TrimPrefix(token, "Bearer ")
-	
-	var apiKey model.ApiKey
-	if err := h.db.Where("key = ?", token).First(&apiKey).Error; err != nil {
-		c.JSON(401, gin.H{"error": "Unauthorized"})
+	apiKey, ok := h.authenticateKey(c)
+	if !ok {
 		return
 	}
-	
-	// Parse permissions
-	var allowedServers []string
-	if apiKey.AllowedServers != "" {
-		json.Unmarshal([]byte(apiKey.AllowedServers), &allowedServers)
+
+	// Reattach to a session whose stream dropped less than SSE_RESUME_WINDOW ago,
+	// replaying the events after Last-Event-ID
+	if resumeID := c.Query("sessionId"); resumeID != "" {
+		if val, live := sessions.Load(resumeID); live {
+			session := val.(*Session)
+			if session.resumable && session.Caller.KeyID == apiKey.ID {
+				if !session.attach() {
+					c.JSON(409, gin.H{"error": "Session already has a live stream"})
+					return
+				}
+				fmt.Printf("[Session] Reattached session %s for key %d\n", resumeID, apiKey.ID)
+				h.streamSSE(c, resumeID, session, lastEventID(c))
+				return
+			}
+		}
 	}
 
-	var allowedTools []string
-	if apiKey.AllowedTools != "" {
-		json.Unmarshal([]byte(apiKey.AllowedTools), &allowedTools)
+	release, err := h.gateway.Limits().AcquireSession(apiKey.ID)
+	if err != nil {
+		h.limited(c, err)
+		return
 	}
 
-	// Log connection for auditing
-	if len(allowedServers) == 0 && len(allowedTools) == 0 {
-		fmt.Printf("[Audit] Key '%s' (ID: %d) connected with FULL ACCESS.\n", apiKey.Key, apiKey.ID)
+	caller := h.newCaller(apiKey)
+	sessionID := uuid.New().String()
+
+	// Resume a session that lost its stream (e.g. gateway restart) if the same key asks for it
+	if resumeID := c.Query("sessionId"); resumeID != "" {
+		if _, live := sessions.Load(resumeID); !live {
+			if record, ok := h.resumableSession(resumeID); ok && record.KeyID == apiKey.ID {
+				sessionID = resumeID
+				restoreSession(caller, record)
+				fmt.Printf("[Session] Resuming session %s for key %d\n", sessionID, apiKey.ID)
+			}
+		}
 	}
 
+	msgChan := make(chan []byte, h.settings.SessionBufferSize)
+	
+	session := &Session{
+		MsgChan: msgChan,
+		done:    make(chan struct{}),
+		slots:   make(chan struct{}, h.settings.SessionConcurrency),
+		Caller:  caller,
+
+		syncMessages: apiKey.SyncMessages,
+		resumable:    true,
+		attached:     true,
+	}
+	session.end = func() {
+		sessions.Delete(sessionID)
+		h.forgetSession(sessionID)
+		close(session.done)
+		h.gateway.DropSession(sessionID)
+		release()
+	}
+	caller.SessionID = sessionID
+	caller.Notify = session.Send
+	sessions.Store(sessionID, session)
+	h.gateway.AddSession(caller)
+	h.persistSession(sessionID, session)
+
+	h.streamSSE(c, sessionID, session, 0)
+}
+
+// streamSSE writes the session's messages to the SSE stream until the client
+// disconnects, starting with the endpoint event and, on reattach, the buffered
+// events after lastID. The session then ends, or waits SSE_RESUME_WINDOW for
+// the client to reattach.
+func (h *Handler) streamSSE(c *gin.Context, sessionID string, session *Session, lastID uint64) {
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
@@ -346,71 +1015,277 @@ func (h *Handler) HandleSSE(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 	}
 
-	sessionID := uuid.New().String()
-	msgChan := make(chan []byte, 10)
-	
-	session := &Session{
-		MsgChan:        msgChan,
-		AllowedServers: allowedServers,
-		AllowedTools:   allowedTools,
-	}
-	sessions.Store(sessionID, session)
-	
-	defer func() {
-		sessions.Delete(sessionID)
-		close(msgChan)
-	}()
-
 	host := c.Request.Host
 	scheme := "http"
 	if c.Request.TLS != nil {
 		scheme = "https"
 	}
-	endpoint := fmt.Sprintf("%s://%s/mcp/messages?sessionId=%s", scheme, host, sessionID)
+	endpoint := fmt.Sprintf("%s://%s%s/messages?sessionId=%s", scheme, host, mcpBasePath(c), sessionID)
 	
 	c.SSEvent("endpoint", endpoint)
+	if lastID > 0 {
+		events, complete := session.eventsAfter(lastID)
+		if !complete {
+			fmt.Printf("[Session %s] Events after %d are no longer buffered, replaying %d\n", sessionID, lastID, len(events))
+		}
+		for _, event := range events {
+			c.Render(-1, sse.Event{Id: strconv.FormatUint(event.id, 10), Event: "message", Data: string(event.data)})
+		}
+	}
 	c.Writer.Flush()
 
+	// Messages carry increasing sequence numbers as event IDs, in delivery order.
+	// They are buffered before being written, as a write may be lost with the stream.
 	notify := c.Writer.CloseNotify()
 	for {
 		select {
-		case msg := <-msgChan:
-			c.SSEvent("message", string(msg))
+		case msg := <-session.MsgChan:
+			id := session.buffer(msg, h.settings.SSEReplaySize)
+			c.Render(-1, sse.Event{Id: strconv.FormatUint(id, 10), Event: "message", Data: string(msg)})
 			c.Writer.Flush()
 		case <-notify:
+			session.detach(h.settings.SSEResumeWindow)
 			return
 		}
 	}
 }
 
+// limited answers a request refused by a gateway limit with 429 and Retry-After.
+func (h *Handler) limited(c *gin.Context, err error) {
+	retryAfter := 1
+	if limitErr, ok := err.(*core.ErrLimited); ok && limitErr.RetryAfter > time.Second {
+		retryAfter = int(math.Ceil(limitErr.RetryAfter.Seconds()))
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(429, gin.H{"error": err.Error()})
+}
+
 func (h *Handler) HandleMessage(c *gin.Context) {
 	sessionID := c.Query("sessionId")
 	val, ok := sessions.Load(sessionID)
 	if !ok {
+		if _, known := h.resumableSession(sessionID); known {
+			// The session outlived its SSE stream (e.g. gateway restart): ask the
+			// client to reconnect instead of treating the session as unknown.
+			c.Header("Retry-After", "1")
+			c.JSON(503, gin.H{
+				"error":     "Session stream lost, please reconnect SSE",
+				"code":      "session_reconnect_required",
+				"retriable": true,
+				"reconnect": mcpBasePath(c) + "/sse?sessionId=" + sessionID,
+			})
+			return
+		}
 		c.JSON(404, gin.H{"error": "Session not found"})
 		return
 	}
 	session := val.(*Session)
 
+	// Clients on 2025-06-18 or later announce the negotiated version on every request
+	if version := c.GetHeader("MCP-Protocol-Version"); version != "" && !core.SupportedProtocolVersion(version) {
+		c.JSON(400, gin.H{"error": "Unsupported MCP-Protocol-Version " + version})
+		return
+	}
+
+	if err := h.gateway.Limits().AllowMessage(); err != nil {
+		h.limited(c, err)
+		return
+	}
+
 	body, _ := io.ReadAll(c.Request.Body)
-	
-	resp, err := h.gateway.HandleMessage(body, session.AllowedServers, session.AllowedTools)
-	
-	if err != nil {
-		// Log error but maybe don't return 500 if it's just JSON-RPC error
-		// Ideally we should return JSON-RPC error response via SSE?
-		// But for now, just return HTTP error if internal failure
-		c.JSON(500, gin.H{"error": err.Error()})
+	if !json.Valid(body) {
+		c.JSON(400, gin.H{"error": "Invalid JSON"})
 		return
 	}
 
-	if resp != nil {
-		respBytes, _ := json.Marshal(resp)
-		select {
-		case session.MsgChan <- respBytes:
-		default:
+	// A JSON array is a batch: its entries are processed together and their
+	// responses delivered as one array.
+	var batch []json.RawMessage
+	isBatch := json.Unmarshal(body, &batch) == nil
+	process := func() { h.processMessage(sessionID, session, body) }
+	if isBatch {
+		process = func() { h.processBatch(sessionID, session, batch) }
+	}
+
+	// Responses to gateway-initiated requests (e.g. sampling) and cancellations skip
+	// the slots: the calls they concern may be holding every slot.
+	bypass := !isBatch && bypassesSlots(body)
+	if isBatch && len(batch) > 0 {
+		bypass = true
+		for _, entry := range batch {
+			bypass = bypass && bypassesSlots(entry)
+		}
+	}
+	if bypass {
+		process()
+		c.Status(202)
+		return
+	}
+
+	// Wait for a processing slot; this only blocks when the session already has
+	// SessionConcurrency messages in flight. Batch entries take a slot each as
+	// they run (see runBatch).
+	release := func() {}
+	if !isBatch {
+		if !session.acquire() {
+			c.JSON(404, gin.H{"error": "Session not found"})
+			return
 		}
+		release = session.release
 	}
 
+	// Clients that cannot read responses from the stream get them in the body
+	// instead, for the key's sessions or with ?sync=true. Notifications and
+	// requests from the gateway still go over SSE.
+	if sync, _ := strconv.ParseBool(c.Query("sync")); sync || session.syncMessages {
+		defer release()
+		var respBytes []byte
+		if isBatch {
+			respBytes = h.runBatch(sessionID, session, batch)
+		} else {
+			respBytes = h.runMessage(sessionID, session, body)
+		}
+		if respBytes == nil {
+			c.Status(202)
+			return
+		}
+		c.Data(200, "application/json", respBytes)
+		return
+	}
+
+	// Process asynchronously: the result is delivered over SSE
+	go func() {
+		defer release()
+		process()
+	}()
+
 	c.Status(202) // Accepted
 }
+
+// bypassesSlots reports whether a message is a response to a gateway-initiated
+// request or a cancellation, which are processed without waiting for a slot.
+func bypassesSlots(body []byte) bool {
+	var probe struct {
+		ID     *json.RawMessage `json:"id"`
+		Method string           `json:"method"`
+	}
+	return json.Unmarshal(body, &probe) == nil && ((probe.ID != nil && probe.Method == "") || probe.Method == "notifications/cancelled")
+}
+
+// processMessage runs one message through the gateway and delivers the response over SSE.
+func (h *Handler) processMessage(sessionID string, session *Session, body []byte) {
+	if respBytes := h.runMessage(sessionID, session, body); respBytes != nil {
+		if !session.Caller.Deliver(respBytes) {
+			fmt.Printf("[Session %s] Stream closed, dropping response\n", sessionID)
+		}
+	}
+}
+
+// processBatch runs a JSON-RPC batch and delivers its responses over SSE.
+func (h *Handler) processBatch(sessionID string, session *Session, batch []json.RawMessage) {
+	if respBytes := h.runBatch(sessionID, session, batch); respBytes != nil {
+		if !session.Caller.Deliver(respBytes) {
+			fmt.Printf("[Session %s] Stream closed, dropping batch response\n", sessionID)
+		}
+	}
+}
+
+// runBatch runs the entries of a JSON-RPC batch concurrently and returns their
+// responses as one array, in the order of the requests. It returns nil when
+// every entry is a notification. Each entry takes a processing slot of the
+// session while it runs, unless it bypasses them (see bypassesSlots), so a
+// batch is bound by SESSION_CONCURRENCY like separate messages.
+func (h *Handler) runBatch(sessionID string, session *Session, batch []json.RawMessage) []byte {
+	invalid := func() []byte {
+		null := json.RawMessage("null")
+		errResp, _ := json.Marshal(&core.JSONRPCMessage{
+			JSONRPC: "2.0",
+			ID:      &null,
+			Error:   &core.JSONRPCError{Code: -32600, Message: "Invalid Request"},
+		})
+		return errResp
+	}
+	if len(batch) == 0 {
+		return invalid()
+	}
+
+	results := make([][]byte, len(batch))
+	var wg sync.WaitGroup
+	for i, entry := range batch {
+		var req map[string]json.RawMessage
+		if json.Unmarshal(entry, &req) != nil || req == nil {
+			results[i] = invalid()
+			continue
+		}
+		slotted := !bypassesSlots(entry)
+		if slotted && !session.acquire() {
+			break // The session ended
+		}
+		wg.Add(1)
+		go func(i int, entry []byte) {
+			defer wg.Done()
+			if slotted {
+				defer session.release()
+			}
+			results[i] = h.runMessage(sessionID, session, entry)
+		}(i, entry)
+	}
+	wg.Wait()
+
+	responses := make([]json.RawMessage, 0, len(results))
+	for _, result := range results {
+		if result != nil {
+			responses = append(responses, result)
+		}
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	respBytes, _ := json.Marshal(responses)
+	return respBytes
+}
+
+// runMessage runs one message through the gateway and returns the encoded
+// response, or nil when there is nothing to answer.
+func (h *Handler) runMessage(sessionID string, session *Session, body []byte) (respBytes []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			report.Panic("session message", r)
+			var req core.JSONRPCMessage
+			if json.Unmarshal(body, &req) == nil && req.ID != nil {
+				respBytes, _ = json.Marshal(&core.JSONRPCMessage{
+					JSONRPC: "2.0",
+					ID:      req.ID,
+					Error:   &core.JSONRPCError{Code: -32603, Message: "Internal error"},
+				})
+			}
+		}
+	}()
+
+	version := session.Caller.Protocol()
+	resp, err := h.gateway.HandleMessage(body, session.Caller)
+	if session.Caller.Protocol() != version && session.resumable {
+		h.persistSession(sessionID, session)
+	}
+
+	if err != nil {
+		fmt.Printf("[Session %s] Failed to handle message: %v\n", sessionID, err)
+		report.Error("session message", err)
+		var req core.JSONRPCMessage
+		json.Unmarshal(body, &req)
+		if req.ID == nil {
+			return nil
+		}
+		resp = &core.JSONRPCMessage{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error:   &core.JSONRPCError{Code: -32603, Message: err.Error()},
+		}
+	}
+
+	if resp == nil {
+		return nil
+	}
+	respBytes, _ = json.Marshal(resp)
+	return respBytes
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListJobs lists asynchronous jobs, newest first.
+// Filters: status, key_id, limit (default 50, max 500), offset.
+func (h *Handler) ListJobs(c *gin.Context) {
+	query := h.db.Model(&model.AsyncJob{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if keyID := c.Query("key_id"); keyID != "" {
+		query = query.Where("key_id = ?", keyID)
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var jobs []model.AsyncJob
+	query.Order("created_at desc").Limit(limit).Offset(offset).Find(&jobs)
+
+	items := make([]map[string]interface{}, len(jobs))
+	for i := range jobs {
+		items[i] = core.JobView(&jobs[i])
+	}
+	c.JSON(200, gin.H{"total": total, "items": items})
+}
+
+func (h *Handler) GetJob(c *gin.Context) {
+	var job model.AsyncJob
+	if err := h.db.First(&job, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+	view := core.JobView(&job)
+	view["arguments"] = rawOrString(job.Arguments)
+	c.JSON(200, view)
+}
+
+func (h *Handler) RetryJob(c *gin.Context) {
+	if err := h.gateway.RetryJob(c.Param("id")); err != nil {
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+func (h *Handler) CancelJob(c *gin.Context) {
+	if err := h.gateway.CancelJob(c.Param("id")); err != nil {
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
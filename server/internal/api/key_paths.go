@@ -0,0 +1,104 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"one-mcp/internal/model"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Keys with a path slug also reach the MCP endpoints under /mcp/k/<slug>, e.g.
+// /mcp/k/<slug>/sse, without an Authorization header: the slug authenticates
+// as the key, for clients that only take a URL. Slugs are as secret as keys.
+
+var pathSlugPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{16,128}$`)
+
+// validatePathSlug checks that slug is long enough to be unguessable and not
+// used by another key than id.
+func (h *Handler) validatePathSlug(id uint, slug string) error {
+	if slug == "" {
+		return nil
+	}
+	if !pathSlugPattern.MatchString(slug) {
+		return fmt.Errorf("path_slug must be 16 to 128 letters, digits, '-' or '_'")
+	}
+	var count int64
+	h.db.Model(&model.ApiKey{}).Where("path_slug = ? AND id <> ?", slug, id).Count(&count)
+	if count > 0 {
+		return fmt.Errorf("path_slug is already used by another key")
+	}
+	return nil
+}
+
+// RotateKeyPath gives a key a new path slug, chosen by the admin in "slug" or
+// random, replacing its previous one: the URLs with the old slug stop working,
+// but sessions opened with them go on.
+func (h *Handler) RotateKeyPath(c *gin.Context) {
+	var key model.ApiKey
+	if err := h.db.First(&key, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+	var req struct {
+		Slug string `json:"slug"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Slug == "" {
+		b := make([]byte, 24)
+		rand.Read(b)
+		req.Slug = base64.RawURLEncoding.EncodeToString(b)
+	}
+	if err := h.validatePathSlug(key.ID, req.Slug); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	key.PathSlug = req.Slug
+	h.db.Model(&key).Update("path_slug", key.PathSlug)
+	h.recordChange(c, "update", "key", key.ID, key)
+	c.JSON(200, gin.H{"path_slug": key.PathSlug, "sse_path": "/mcp/k/" + key.PathSlug + "/sse"})
+}
+
+// DeleteKeyPath removes the path slug of a key, which then needs an
+// Authorization header again.
+func (h *Handler) DeleteKeyPath(c *gin.Context) {
+	var key model.ApiKey
+	if err := h.db.First(&key, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+	if key.PathSlug != "" {
+		key.PathSlug = ""
+		h.db.Model(&key).Update("path_slug", "")
+		h.recordChange(c, "update", "key", key.ID, key)
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+// keyForPath resolves the key of the path slug of an MCP request, answering 404
+// for unknown slugs.
+func (h *Handler) keyForPath(c *gin.Context, slug string) (*model.ApiKey, bool) {
+	var apiKey model.ApiKey
+	if h.db.Where("path_slug = ?", slug).First(&apiKey).Error != nil {
+		c.JSON(404, gin.H{"error": "Not found"})
+		return nil, false
+	}
+	return &apiKey, true
+}
+
+// mcpBasePath returns the path the MCP endpoints of a request are served under,
+// for the URLs sent back to clients.
+func mcpBasePath(c *gin.Context) string {
+	if slug := c.Param("slug"); slug != "" {
+		return "/mcp/k/" + slug
+	}
+	return "/mcp"
+}
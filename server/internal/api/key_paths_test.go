@@ -0,0 +1,104 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestKeyPaths(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	db.AutoMigrate(&model.ApiKey{}, &model.ConfigChange{}, &model.SessionRecord{})
+	key := model.ApiKey{Key: "sk-path"}
+	db.Create(&key)
+	db.Create(&model.ApiKey{Key: "sk-other", PathSlug: "taken-taken-taken-1"})
+
+	settings := &config.Config{SessionBufferSize: 8, SessionConcurrency: 2, SSEReplaySize: 8}
+	h := &Handler{db: db, gateway: core.NewGateway(nil, settings), settings: settings}
+	r := gin.New()
+	r.POST("/keys/:id/path", h.RotateKeyPath)
+	r.DELETE("/keys/:id/path", h.DeleteKeyPath)
+	h.registerMCPRoutes(r.Group("/mcp/k/:slug"))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	request := func(method, path, body string) (int, string) {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		req.Header.Set("Accept", "application/json, text/event-stream")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var out strings.Builder
+		bufio.NewReader(resp.Body).WriteTo(&out)
+		return resp.StatusCode, out.String()
+	}
+	ping := `{"jsonrpc":"2.0","id":1,"method":"ping"}`
+
+	code, _ := request("POST", "/keys/1/path", `{"slug": "short"}`)
+	assert.Equal(t, 400, code)
+	code, _ = request("POST", "/keys/1/path", `{"slug": "taken-taken-taken-1"}`)
+	assert.Equal(t, 400, code, "slugs are unique")
+	code, body := request("POST", "/keys/1/path", `{"slug": "my-vanity-path-0001"}`)
+	assert.Equal(t, 200, code)
+	assert.Contains(t, body, `"sse_path":"/mcp/k/my-vanity-path-0001/sse"`)
+
+	code, body = request("POST", "/mcp/k/my-vanity-path-0001/stateless", ping)
+	assert.Equal(t, 200, code)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":{}}`, body)
+	code, _ = request("POST", "/mcp/k/not-a-known-slug-01/stateless", ping)
+	assert.Equal(t, 404, code)
+
+	// The endpoint event of SSE sessions stays under the path
+	req, _ := http.NewRequest("GET", server.URL+"/mcp/k/my-vanity-path-0001/sse", nil)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	scanner := bufio.NewScanner(resp.Body)
+	var endpoint string
+	for endpoint == "" && scanner.Scan() {
+		endpoint = strings.TrimPrefix(scanner.Text(), "data:")
+		if endpoint == scanner.Text() {
+			endpoint = ""
+		}
+	}
+	resp.Body.Close()
+	assert.Contains(t, endpoint, "/mcp/k/my-vanity-path-0001/messages?sessionId=")
+
+	// Rotating replaces the slug with a random one
+	code, body = request("POST", "/keys/1/path", "")
+	assert.Equal(t, 200, code)
+	var rotated struct {
+		PathSlug string `json:"path_slug"`
+	}
+	json.Unmarshal([]byte(body), &rotated)
+	assert.Len(t, rotated.PathSlug, 32)
+	code, _ = request("POST", "/mcp/k/my-vanity-path-0001/stateless", ping)
+	assert.Equal(t, 404, code)
+	code, _ = request("POST", "/mcp/k/"+rotated.PathSlug+"/stateless", ping)
+	assert.Equal(t, 200, code)
+
+	// Expired keys are refused under their path too
+	past := time.Now().Add(-time.Minute)
+	db.Model(&key).Update("expires_at", &past)
+	code, _ = request("POST", "/mcp/k/"+rotated.PathSlug+"/stateless", ping)
+	assert.Equal(t, 401, code)
+
+	code, _ = request("DELETE", "/keys/1/path", "")
+	assert.Equal(t, 200, code)
+	db.First(&key, key.ID)
+	assert.Empty(t, key.PathSlug)
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListUpstreamLogs returns the persisted log messages of a server, newest first.
+// Filters: level (minimum severity), limit (default 100, max 1000).
+func (h *Handler) ListUpstreamLogs(c *gin.Context) {
+	query := h.reads().Where("server_id = ?", c.Param("id"))
+	if level := c.Query("level"); level != "" {
+		levels := core.LogLevelsAtLeast(level)
+		if levels == nil {
+			c.JSON(400, gin.H{"error": "Unknown log level " + level})
+			return
+		}
+		query = query.Where("level IN ?", levels)
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	var logs []model.UpstreamLog
+	query.Order("id desc").Limit(limit).Find(&logs)
+	c.JSON(200, logs)
+}
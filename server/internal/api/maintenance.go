@@ -0,0 +1,88 @@
+package api
+
+import (
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) ListMaintenanceWindows(c *gin.Context) {
+	var windows []model.MaintenanceWindow
+	h.db.Where("server_id = ?", c.Param("id")).Order("starts_at").Find(&windows)
+	c.JSON(200, windows)
+}
+
+func (h *Handler) CreateMaintenanceWindow(c *gin.Context) {
+	var server model.UpstreamServer
+	if err := h.db.First(&server, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+
+	// Times are RFC 3339, or wall-clock times in the window's timezone
+	var req struct {
+		StartsAt string `json:"starts_at"`
+		EndsAt   string `json:"ends_at"`
+		Reason   string `json:"reason"`
+		Timezone string `json:"timezone"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := core.ValidateTimezone(req.Timezone); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	loc := h.gateway.Location(req.Timezone)
+	startsAt, err := core.ParseLocalTime(req.StartsAt, loc)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "starts_at: " + err.Error()})
+		return
+	}
+	endsAt, err := core.ParseLocalTime(req.EndsAt, loc)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "ends_at: " + err.Error()})
+		return
+	}
+	if !endsAt.After(startsAt) {
+		c.JSON(400, gin.H{"error": "ends_at must be after starts_at"})
+		return
+	}
+	window := model.MaintenanceWindow{
+		ServerID: server.ID,
+		StartsAt: startsAt,
+		EndsAt:   endsAt,
+		Reason:   req.Reason,
+		Timezone: req.Timezone,
+	}
+
+	h.db.Create(&window)
+	h.recordChange(c, "create", "maintenance_window", window.ID, window)
+	h.gateway.ReloadMaintenance()
+	c.JSON(200, window)
+}
+
+func (h *Handler) DeleteMaintenanceWindow(c *gin.Context) {
+	if h.db.Where("id = ? AND server_id = ?", c.Param("windowId"), c.Param("id")).Delete(&model.MaintenanceWindow{}).RowsAffected > 0 {
+		h.recordChange(c, "delete", "maintenance_window", c.Param("windowId"), nil)
+	}
+	h.gateway.ReloadMaintenance()
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+// PruneDatabase runs the retention job now. With ?vacuum=true the database file
+// is compacted afterwards.
+func (h *Handler) PruneDatabase(c *gin.Context) {
+	deleted := h.gateway.Prune()
+	vacuumed := false
+	if c.Query("vacuum") == "true" {
+		if err := h.gateway.Vacuum(); err != nil {
+			c.JSON(500, gin.H{"error": "Pruned, but vacuum failed: " + err.Error(), "deleted": deleted})
+			return
+		}
+		vacuumed = true
+	}
+	c.JSON(200, gin.H{"deleted": deleted, "vacuumed": vacuumed})
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMaintenanceWindowAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(model.All...))
+	db.Create(&model.UpstreamServer{Name: "db", TransportType: "demo"})
+
+	settings := &config.Config{}
+	h := &Handler{db: db, gateway: core.NewGateway(db, settings), settings: settings}
+	r := gin.New()
+	r.GET("/servers/:id/maintenance", h.ListMaintenanceWindows)
+	r.POST("/servers/:id/maintenance", h.CreateMaintenanceWindow)
+	r.DELETE("/servers/:id/maintenance/:windowId", h.DeleteMaintenanceWindow)
+	do := func(method string, path string, body string) (int, string) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w.Code, w.Body.String()
+	}
+
+	t.Run("Validation", func(t *testing.T) {
+		code, _ := do("POST", "/servers/9/maintenance", `{"starts_at": "2030-01-01 10:00", "ends_at": "2030-01-01 12:00"}`)
+		assert.Equal(t, 404, code)
+		code, body := do("POST", "/servers/1/maintenance", `{"starts_at": "2030-01-01 10:00", "ends_at": "2030-01-01 12:00", "timezone": "Mars/Olympus"}`)
+		assert.Equal(t, 400, code)
+		assert.Contains(t, body, "unknown timezone")
+		code, body = do("POST", "/servers/1/maintenance", `{"starts_at": "soon", "ends_at": "2030-01-01 12:00"}`)
+		assert.Equal(t, 400, code)
+		assert.Contains(t, body, "starts_at: ")
+		code, body = do("POST", "/servers/1/maintenance", `{"starts_at": "2030-01-01 12:00", "ends_at": "2030-01-01 10:00"}`)
+		assert.Equal(t, 400, code)
+		assert.Contains(t, body, "ends_at must be after starts_at")
+	})
+
+	t.Run("Wall-Clock Times In The Window Zone", func(t *testing.T) {
+		code, body := do("POST", "/servers/1/maintenance", `{"starts_at": "2030-01-01 10:00", "ends_at": "2030-01-01T12:00:00Z", "timezone": "Europe/Berlin", "reason": "upgrade"}`)
+		require.Equal(t, 200, code, body)
+		var window model.MaintenanceWindow
+		require.NoError(t, json.Unmarshal([]byte(body), &window))
+		assert.Equal(t, uint(1), window.ServerID)
+		assert.Equal(t, time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC), window.StartsAt.UTC())
+		assert.Equal(t, time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC), window.EndsAt.UTC())
+
+		_, body = do("GET", "/servers/1/maintenance", "")
+		var windows []model.MaintenanceWindow
+		require.NoError(t, json.Unmarshal([]byte(body), &windows))
+		assert.Len(t, windows, 1)
+		var changes int64
+		db.Model(&model.ConfigChange{}).Where("resource = ?", "maintenance_window").Count(&changes)
+		assert.Equal(t, int64(1), changes)
+	})
+
+	t.Run("Delete Is Scoped To The Server", func(t *testing.T) {
+		db.Create(&model.UpstreamServer{Name: "search", TransportType: "demo"})
+		do("DELETE", "/servers/2/maintenance/1", "")
+		var count int64
+		db.Model(&model.MaintenanceWindow{}).Count(&count)
+		assert.Equal(t, int64(1), count)
+		do("DELETE", "/servers/1/maintenance/1", "")
+		db.Model(&model.MaintenanceWindow{}).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"one-mcp/internal/core"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// upstreamMetrics are the per-upstream series of GET /api/v1/metrics.
+var upstreamMetrics = []struct {
+	name, kind, help string
+	value            func(s core.UpstreamStatus) float64
+}{
+	{"one_mcp_upstream_up", "gauge", "Whether the upstream is connected and initialized.", func(s core.UpstreamStatus) float64 {
+		if s.State == core.StateReady {
+			return 1
+		}
+		return 0
+	}},
+	{"one_mcp_upstream_inflight_requests", "gauge", "Requests sent to the upstream and awaiting a response.", func(s core.UpstreamStatus) float64 {
+		return float64(s.Saturation.InFlight)
+	}},
+	{"one_mcp_upstream_queued_calls", "gauge", "Tool calls waiting for a slot under the upstream's max_concurrency.", func(s core.UpstreamStatus) float64 {
+		return float64(s.Saturation.Queued)
+	}},
+	{"one_mcp_upstream_concurrency_limit", "gauge", "The upstream's max_concurrency, 0 if unlimited.", func(s core.UpstreamStatus) float64 {
+		return float64(s.Saturation.Limit)
+	}},
+	{"one_mcp_upstream_timeouts_total", "counter", "Requests the upstream did not answer within their timeout.", func(s core.UpstreamStatus) float64 {
+		return float64(s.Saturation.Timeouts)
+	}},
+	{"one_mcp_upstream_queue_timeouts_total", "counter", "Tool calls that waited for a slot of the upstream in vain.", func(s core.UpstreamStatus) float64 {
+		return float64(s.Saturation.QueueTimeouts)
+	}},
+	{"one_mcp_upstream_reconnects_total", "counter", "Connection attempts to the upstream after the first.", func(s core.UpstreamStatus) float64 {
+		return float64(s.Saturation.Reconnects)
+	}},
+	{"one_mcp_upstream_error_rate", "gauge", "Share of the upstream's recent tool calls that failed.", func(s core.UpstreamStatus) float64 {
+		return s.Health.ErrorRate
+	}},
+	{"one_mcp_upstream_latency_avg_seconds", "gauge", "Average duration of the upstream's recent tool calls.", func(s core.UpstreamStatus) float64 {
+		return float64(s.Health.AvgLatencyMs) / 1000
+	}},
+}
+
+// GetMetrics serves the saturation of the gateway and of every upstream in
+// the Prometheus text format, for autoscaling and alerting. Scrapers
+// authenticate with a read-only admin token.
+func (h *Handler) GetMetrics(c *gin.Context) {
+	statuses := h.gateway.UpstreamStatuses()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+
+	var buf bytes.Buffer
+	for _, m := range upstreamMetrics {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for _, s := range statuses {
+			fmt.Fprintf(&buf, "%s{server=\"%s\"} %g\n", m.name, escapeLabel(s.Name), m.value(s))
+		}
+	}
+
+	limits := h.gateway.Limits().Stats()
+	gauges := []struct {
+		name, help string
+		value      int
+	}{
+		{"one_mcp_sessions", "Open downstream sessions.", limits.Sessions},
+		{"one_mcp_max_sessions", "Limit of open downstream sessions, 0 if unlimited.", limits.MaxSessions},
+		{"one_mcp_inflight_calls", "Tool calls in flight across all upstreams.", limits.InflightCalls},
+		{"one_mcp_max_inflight_calls", "Limit of tool calls in flight, 0 if unlimited.", limits.MaxInflightCalls},
+	}
+	for _, g := range gauges {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", g.name, g.help, g.name, g.name, g.value)
+	}
+	fmt.Fprintf(&buf, "# HELP one_mcp_rejected_total Requests rejected by the gateway limits.\n# TYPE one_mcp_rejected_total counter\n")
+	reasons := make([]string, 0, len(limits.Rejected))
+	for reason := range limits.Rejected {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(&buf, "one_mcp_rejected_total{reason=\"%s\"} %d\n", escapeLabel(reason), limits.Rejected[reason])
+	}
+
+	c.Data(200, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+}
+
+// escapeLabel escapes a Prometheus label value.
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
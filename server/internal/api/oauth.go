@@ -0,0 +1,105 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"one-mcp/internal/model"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// resourceMetadataURL is where clients find the protected resource metadata
+// (RFC 9728) pointing them to the authorization server.
+func (h *Handler) resourceMetadataURL() string {
+	return strings.TrimSuffix(h.settings.PublicURL, "/") + "/.well-known/oauth-protected-resource"
+}
+
+// unauthorized answers an MCP request that failed authentication. With OAuth
+// enabled, the WWW-Authenticate header tells clients where to get a token;
+// errCode is the RFC 6750 error code, if any.
+func (h *Handler) unauthorized(c *gin.Context, status int, errCode string, message string) {
+	if h.oauth != nil {
+		challenge := fmt.Sprintf(`Bearer resource_metadata="%s"`, h.resourceMetadataURL())
+		if errCode != "" {
+			challenge += fmt.Sprintf(`, error="%s", error_description="%s"`, errCode, message)
+		}
+		c.Header("WWW-Authenticate", challenge)
+	} else if status == 401 && h.settings.KeyBasicAuth {
+		c.Header("WWW-Authenticate", `Basic realm="one-mcp"`)
+	}
+	c.JSON(status, gin.H{"error": message})
+}
+
+// authenticateToken validates an OAuth access token and returns the key it maps
+// to: the key bound to the token's subject, or else the first key bound to one
+// of its scopes. Valid tokens without a key are refused with 403.
+func (h *Handler) authenticateToken(c *gin.Context, token string) (*model.ApiKey, bool) {
+	claims, err := h.oauth.Verify(token)
+	if err != nil {
+		fmt.Printf("[OAuth] Rejected access token: %v\n", err)
+		h.unauthorized(c, 401, "invalid_token", "Invalid access token")
+		return nil, false
+	}
+
+	var apiKey model.ApiKey
+	err = gorm.ErrRecordNotFound
+	if claims.Subject != "" {
+		err = h.db.Where("oauth_subject = ?", claims.Subject).First(&apiKey).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) && len(claims.Scopes) > 0 {
+		err = h.db.Where("oauth_scope IN ?", claims.Scopes).Order("id").First(&apiKey).Error
+	}
+	if err != nil {
+		fmt.Printf("[OAuth] No key for subject %q with scopes %v\n", claims.Subject, claims.Scopes)
+		h.unauthorized(c, 403, "insufficient_scope", "No API key is bound to the token's subject or scopes")
+		return nil, false
+	}
+	return &apiKey, true
+}
+
+// validateOAuthBinding checks that the OAuth subject and scope of key id are not
+// bound to another key, so every token maps to one key.
+func (h *Handler) validateOAuthBinding(id uint, subject string, scope string) error {
+	if strings.ContainsAny(scope, " \t") {
+		return fmt.Errorf("oauth_scope must be a single scope")
+	}
+	var count int64
+	if subject != "" {
+		h.db.Model(&model.ApiKey{}).Where("oauth_subject = ? AND id <> ?", subject, id).Count(&count)
+		if count > 0 {
+			return fmt.Errorf("oauth_subject is already bound to another key")
+		}
+	}
+	if scope != "" {
+		h.db.Model(&model.ApiKey{}).Where("oauth_scope = ? AND id <> ?", scope, id).Count(&count)
+		if count > 0 {
+			return fmt.Errorf("oauth_scope is already bound to another key")
+		}
+	}
+	return nil
+}
+
+// HandleProtectedResourceMetadata serves the OAuth protected resource metadata
+// of the MCP endpoint (RFC 9728), which MCP clients read after a 401 to find the
+// authorization server. The scopes are those bound to keys.
+func (h *Handler) HandleProtectedResourceMetadata(c *gin.Context) {
+	if h.oauth == nil {
+		c.JSON(404, gin.H{"error": "OAuth is not enabled"})
+		return
+	}
+	var scopes []string
+	h.db.Model(&model.ApiKey{}).Where("oauth_scope <> ''").Distinct().Order("oauth_scope").Pluck("oauth_scope", &scopes)
+
+	metadata := gin.H{
+		"resource":                 h.oauth.Audience,
+		"authorization_servers":    []string{h.oauth.Issuer},
+		"bearer_methods_supported": []string{"header"},
+		"resource_name":            "one-mcp",
+	}
+	if len(scopes) > 0 {
+		metadata["scopes_supported"] = scopes
+	}
+	c.JSON(200, metadata)
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"one-mcp/internal/oauth"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestOAuthAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	signingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1",
+			"n": base64.RawURLEncoding.EncodeToString(signingKey.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(signingKey.E)).Bytes()),
+		}}})
+	}))
+	defer jwks.Close()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.ApiKey{})
+	db.Create(&model.ApiKey{Key: "sk-static"})
+	db.Create(&model.ApiKey{Key: "sk-alice", OAuthSubject: "alice"})
+	db.Create(&model.ApiKey{Key: "sk-readers", OAuthScope: "mcp:read"})
+	expired := time.Now().Add(-time.Hour)
+	db.Create(&model.ApiKey{Key: "sk-bob", OAuthSubject: "bob", ExpiresAt: &expired})
+
+	settings := &config.Config{PublicURL: "https://gateway.example.com", OAuthIssuer: "https://auth.example.com"}
+	h := &Handler{db: db, gateway: core.NewGateway(nil, settings), settings: settings}
+	h.oauth = oauth.NewVerifier(settings.OAuthIssuer, settings.OAuthResource(), jwks.URL)
+	r := gin.New()
+	r.POST("/mcp/stateless", h.HandleStatelessPost)
+	r.GET("/.well-known/oauth-protected-resource", h.HandleProtectedResourceMetadata)
+
+	token := func(sub, scope string) string {
+		claims := jwt.MapClaims{
+			"iss": "https://auth.example.com", "aud": "https://gateway.example.com/mcp",
+			"sub": sub, "scope": scope, "exp": time.Now().Add(time.Hour).Unix(),
+		}
+		t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		t.Header["kid"] = "k1"
+		signed, _ := t.SignedString(signingKey)
+		return signed
+	}
+	ping := func(bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/mcp/stateless", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Metadata", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/.well-known/oauth-protected-resource", nil))
+		assert.JSONEq(t, `{
+			"resource": "https://gateway.example.com/mcp",
+			"authorization_servers": ["https://auth.example.com"],
+			"bearer_methods_supported": ["header"],
+			"scopes_supported": ["mcp:read"],
+			"resource_name": "one-mcp"
+		}`, w.Body.String())
+	})
+
+	t.Run("Challenge", func(t *testing.T) {
+		w := ping("")
+		assert.Equal(t, 401, w.Code)
+		assert.Equal(t, `Bearer resource_metadata="https://gateway.example.com/.well-known/oauth-protected-resource"`, w.Header().Get("WWW-Authenticate"))
+
+		w = ping(token("alice", "")[:40] + "x.y.z")
+		assert.Equal(t, 401, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+	})
+
+	t.Run("Mapping To Keys", func(t *testing.T) {
+		assert.Equal(t, 200, ping("sk-static").Code, "static keys keep working")
+		assert.Equal(t, 200, ping(token("alice", "")).Code)
+		assert.Equal(t, 200, ping(token("carol", "openid mcp:read")).Code, "bound by scope")
+		assert.Equal(t, 401, ping(token("bob", "")).Code, "the expiry of the key applies")
+
+		w := ping(token("carol", "openid"))
+		assert.Equal(t, 403, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="insufficient_scope"`)
+	})
+
+	t.Run("Bindings Are Unique", func(t *testing.T) {
+		assert.Error(t, h.validateOAuthBinding(0, "alice", ""))
+		assert.Error(t, h.validateOAuthBinding(0, "", "mcp:read"))
+		assert.Error(t, h.validateOAuthBinding(0, "", "a b"))
+		assert.NoError(t, h.validateOAuthBinding(2, "alice", ""), "a key keeps its own binding")
+	})
+}
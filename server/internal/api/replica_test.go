@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"one-mcp/internal/model"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestReadReplica(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	open := func() *gorm.DB {
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		assert.NoError(t, err)
+		db.AutoMigrate(&model.ConfigChange{})
+		return db
+	}
+	primary, replica := open(), open()
+	h := &Handler{db: primary}
+
+	count := func() int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/api/v1/changes", nil)
+		h.ListChanges(c)
+		var resp struct {
+			Changes []model.ConfigChange `json:"changes"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return len(resp.Changes)
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	h.recordChange(c, "create", "team", 1, nil)
+	assert.Equal(t, 1, count())
+
+	// With a replica, writes still go to the primary and history is read from the
+	// replica, which has not caught up yet
+	h.SetReadReplica(replica)
+	h.recordChange(c, "delete", "team", 1, nil)
+	assert.Equal(t, 0, count())
+	var written int64
+	primary.Model(&model.ConfigChange{}).Count(&written)
+	assert.Equal(t, int64(2), written)
+
+	replica.Create(&model.ConfigChange{Action: "create", Resource: "team"})
+	assert.Equal(t, 1, count())
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A dropped SSE stream does not end its session at once: the session stays for
+// SSE_RESUME_WINDOW, queueing what the gateway sends it, and the client can
+// reattach with GET /mcp/sse?sessionId=<id>. With Last-Event-ID, the events
+// written after that ID are sent again, as the last ones written before the
+// drop may never have arrived.
+
+// sseEvent is a message written to an SSE stream, with its event ID.
+type sseEvent struct {
+	id   uint64
+	data []byte
+}
+
+// lastEventID returns the ID of the last event the client received, from the
+// Last-Event-ID header or the lastEventId query parameter, or 0 if none.
+func lastEventID(c *gin.Context) uint64 {
+	raw := c.GetHeader("Last-Event-ID")
+	if raw == "" {
+		raw = c.Query("lastEventId")
+	}
+	id, _ := strconv.ParseUint(raw, 10, 64)
+	return id
+}
+
+// buffer assigns the next event ID to a message about to be written, keeping
+// the last size messages for replay.
+func (s *Session) buffer(msg []byte, size int) uint64 {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+	s.seq++
+	if size > 0 {
+		if len(s.sent) >= size {
+			s.sent = s.sent[len(s.sent)-size+1:]
+		}
+		s.sent = append(s.sent, sseEvent{id: s.seq, data: msg})
+	}
+	return s.seq
+}
+
+// eventsAfter returns the buffered events after lastID. complete is false if
+// some of them are no longer buffered.
+func (s *Session) eventsAfter(lastID uint64) (events []sseEvent, complete bool) {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+	complete = lastID >= s.seq || (len(s.sent) > 0 && s.sent[0].id <= lastID+1)
+	for _, event := range s.sent {
+		if event.id > lastID {
+			events = append(events, event)
+		}
+	}
+	return events, complete
+}
+
+// attach connects a new stream to the session. It fails if a stream is still
+// connected or the session is ending.
+func (s *Session) attach() bool {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+	if s.attached {
+		return false
+	}
+	if s.expiry != nil && !s.expiry.Stop() {
+		return false
+	}
+	s.expiry = nil
+	s.attached = true
+	return true
+}
+
+// detach disconnects the stream. The session ends after window unless a stream
+// attaches meanwhile, or at once if window is 0.
+func (s *Session) detach(window time.Duration) {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+	s.attached = false
+	if window <= 0 {
+		s.end()
+		return
+	}
+	fmt.Printf("[Session] Stream of session %s dropped, keeping it for %v\n", s.Caller.SessionID, window)
+	s.expiry = time.AfterFunc(window, func() {
+		fmt.Printf("[Session] Session %s was not resumed\n", s.Caller.SessionID)
+		s.end()
+	})
+}
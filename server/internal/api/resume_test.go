@@ -0,0 +1,177 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestSSEResume(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.ApiKey{}, &model.SessionRecord{})
+	db.Create(&model.ApiKey{Key: "sk-a"})
+
+	settings := &config.Config{SessionBufferSize: 8, SessionConcurrency: 2, SSEResumeWindow: 300 * time.Millisecond, SSEReplaySize: 2}
+	h := &Handler{db: db, gateway: core.NewGateway(nil, settings), settings: settings}
+	r := gin.New()
+	r.GET("/mcp/sse", h.HandleSSE)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	type stream struct {
+		status int
+		lines  chan string
+		cancel func()
+	}
+	open := func(query, lastEventID string) *stream {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/mcp/sse"+query, nil)
+		req.Header.Set("Authorization", "Bearer sk-a")
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		s := &stream{status: resp.StatusCode, lines: make(chan string, 100), cancel: cancel}
+		go func() {
+			defer resp.Body.Close()
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				if line := scanner.Text(); line != "" {
+					s.lines <- line
+				}
+			}
+			close(s.lines)
+		}()
+		return s
+	}
+	next := func(s *stream, prefix string) string {
+		for {
+			select {
+			case line := <-s.lines:
+				if strings.HasPrefix(line, prefix) {
+					return strings.TrimPrefix(line, prefix)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("no %q line", prefix)
+			}
+		}
+	}
+	load := func(id string) *Session {
+		val, ok := sessions.Load(id)
+		if !ok {
+			return nil
+		}
+		return val.(*Session)
+	}
+	detached := func(s *Session) func() bool {
+		return func() bool {
+			s.streamMu.Lock()
+			defer s.streamMu.Unlock()
+			return !s.attached
+		}
+	}
+
+	first := open("", "")
+	endpoint := next(first, "data:")
+	sessionID := endpoint[strings.Index(endpoint, "sessionId=")+len("sessionId="):]
+	session := load(sessionID)
+	for _, msg := range []string{`{"n":1}`, `{"n":2}`, `{"n":3}`} {
+		session.Caller.Deliver([]byte(msg))
+	}
+	assert.Equal(t, "3", func() string { next(first, "id:"); next(first, "id:"); return next(first, "id:") }())
+
+	// The stream drops; the session stays and queues new messages
+	first.cancel()
+	assert.Eventually(t, detached(session), time.Second, 10*time.Millisecond)
+	session.Caller.Deliver([]byte(`{"n":4}`))
+
+	second := open("?sessionId="+sessionID, "2")
+	assert.Equal(t, 200, second.status)
+	assert.Contains(t, next(second, "data:"), "sessionId="+sessionID)
+	assert.Equal(t, "3", next(second, "id:"), "events after Last-Event-ID are replayed")
+	assert.Equal(t, `{"n":3}`, next(second, "data:"))
+	assert.Equal(t, "4", next(second, "id:"), "queued messages follow")
+	assert.Equal(t, `{"n":4}`, next(second, "data:"))
+
+	busy := open("?sessionId="+sessionID, "")
+	assert.Equal(t, 409, busy.status, "one stream per session")
+	busy.cancel()
+
+	// Without a reattach in time the session ends
+	second.cancel()
+	assert.Eventually(t, func() bool { return load(sessionID) == nil }, 2*time.Second, 20*time.Millisecond)
+	third := open("?sessionId="+sessionID, "4")
+	assert.NotContains(t, next(third, "data:"), sessionID)
+	third.cancel()
+}
+
+func TestEventsAfter(t *testing.T) {
+	s := &Session{}
+	for i := 0; i < 5; i++ {
+		s.buffer([]byte("m"), 3)
+	}
+	events, complete := s.eventsAfter(3)
+	assert.True(t, complete)
+	assert.Len(t, events, 2)
+	events, complete = s.eventsAfter(1)
+	assert.False(t, complete, "event 2 is no longer buffered")
+	assert.Equal(t, uint64(3), events[0].id)
+	events, complete = s.eventsAfter(5)
+	assert.True(t, complete)
+	assert.Empty(t, events)
+}
+
+func TestResumeAfterRestart(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.ApiKey{}, &model.SessionRecord{})
+	db.Create(&model.ApiKey{Key: "sk-a", AllowedServers: `["github"]`})
+
+	settings := &config.Config{SessionBufferSize: 8, SessionConcurrency: 2}
+	h := &Handler{db: db, gateway: core.NewGateway(nil, settings), settings: settings}
+
+	// The session negotiated before the restart
+	before := &core.Caller{KeyID: 1, AllowedServers: []string{"github", "slack"}}
+	before.Negotiate("2025-03-26", map[string]json.RawMessage{"sampling": json.RawMessage(`{}`)})
+	h.persistSession("s-1", &Session{Caller: before})
+
+	r := gin.New()
+	r.GET("/mcp/sse", h.HandleSSE)
+	server := httptest.NewServer(r)
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/mcp/sse?sessionId=s-1", nil)
+	req.Header.Set("Authorization", "Bearer sk-a")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && !strings.HasPrefix(scanner.Text(), "data:") {
+	}
+	assert.Contains(t, scanner.Text(), "sessionId=s-1")
+
+	val, ok := sessions.Load("s-1")
+	assert.True(t, ok)
+	caller := val.(*Session).Caller
+	assert.Equal(t, "2025-03-26", caller.Protocol(), "the negotiated version is restored")
+	assert.True(t, caller.Supports("sampling"), "the client capabilities are restored")
+	assert.Equal(t, []string{"github"}, caller.AllowedServers, "permissions are those of the key")
+}
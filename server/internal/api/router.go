@@ -0,0 +1,137 @@
+package api
+
+import "github.com/gin-gonic/gin"
+
+// RegisterRoutes adds the login, the admin API and the MCP endpoints to r.
+func (h *Handler) RegisterRoutes(r gin.IRouter) {
+	// Public Login API
+	r.POST("/api/login", h.Login)
+
+	// Redirect of authorization servers after an admin authorized an upstream
+	r.GET(upstreamAuthCallback, h.UpstreamAuthCallback)
+
+	// Protected Admin APIs
+	apiGroup := r.Group("/api/v1")
+	apiGroup.Use(h.AdminAuthMiddleware())
+	{
+		apiGroup.GET("/servers", h.ListServers)
+		apiGroup.GET("/servers/status", h.ListServerStatuses)
+		apiGroup.POST("/servers", h.CreateServer)
+		apiGroup.PUT("/servers/:id", h.UpdateServer)
+		apiGroup.DELETE("/servers/:id", h.DeleteServer)
+		apiGroup.GET("/servers/:id/status", h.GetServerStatus)
+		apiGroup.GET("/servers/:id/console", h.UpstreamConsole)
+		apiGroup.PUT("/servers/:id/trace", h.SetServerTrace)
+		apiGroup.GET("/servers/:id/trace", h.GetServerTrace)
+		apiGroup.GET("/servers/:id/logs", h.ListUpstreamLogs)
+		apiGroup.POST("/servers/:id/oauth/authorize", h.AuthorizeUpstream)
+		apiGroup.GET("/servers/:id/oauth", h.GetUpstreamAuth)
+		apiGroup.DELETE("/servers/:id/oauth", h.RevokeUpstreamAuth)
+		apiGroup.GET("/servers/sandbox-profiles", h.ListSandboxProfiles)
+		apiGroup.POST("/servers/sandbox-profiles", h.CreateSandboxProfile)
+		apiGroup.PUT("/servers/sandbox-profiles/:id", h.UpdateSandboxProfile)
+		apiGroup.DELETE("/servers/sandbox-profiles/:id", h.DeleteSandboxProfile)
+		apiGroup.GET("/servers/:id/maintenance", h.ListMaintenanceWindows)
+		apiGroup.POST("/servers/:id/maintenance", h.CreateMaintenanceWindow)
+		apiGroup.DELETE("/servers/:id/maintenance/:windowId", h.DeleteMaintenanceWindow)
+		apiGroup.POST("/maintenance/prune", h.PruneDatabase)
+
+		apiGroup.GET("/keys", h.ListKeys)
+		apiGroup.POST("/keys", h.CreateKey)
+		apiGroup.POST("/keys/bulk", h.CreateKeysBulk)
+		apiGroup.PUT("/keys/:id", h.UpdateKey)
+		apiGroup.DELETE("/keys/:id", h.DeleteKey)
+		apiGroup.POST("/keys/:id/path", h.RotateKeyPath)
+		apiGroup.DELETE("/keys/:id/path", h.DeleteKeyPath)
+
+		apiGroup.GET("/teams", h.ListTeams)
+		apiGroup.POST("/teams", h.CreateTeam)
+		apiGroup.PUT("/teams/:id", h.UpdateTeam)
+		apiGroup.DELETE("/teams/:id", h.DeleteTeam)
+		apiGroup.GET("/teams/:id/usage", h.GetTeamUsage)
+
+		apiGroup.GET("/tools", h.ListAllTools)
+		apiGroup.GET("/tools/stream", h.StreamAllTools)
+
+		apiGroup.GET("/stats/scheduler", h.GetSchedulerStats)
+		apiGroup.GET("/stats/ratelimits", h.GetRateLimits)
+		apiGroup.GET("/stats/limits", h.GetLimitStats)
+		apiGroup.GET("/stats/heatmap", h.GetUsageHeatmap)
+		apiGroup.GET("/metrics", h.GetMetrics)
+
+		apiGroup.GET("/workflows", h.ListWorkflows)
+		apiGroup.POST("/workflows", h.CreateWorkflow)
+		apiGroup.PUT("/workflows/:id", h.UpdateWorkflow)
+		apiGroup.DELETE("/workflows/:id", h.DeleteWorkflow)
+		apiGroup.GET("/workflows/:id/runs", h.ListWorkflowRuns)
+		apiGroup.GET("/workflows/:id/runs/:runId", h.GetWorkflowRun)
+
+		apiGroup.GET("/routes", h.ListToolRoutes)
+		apiGroup.POST("/routes", h.CreateToolRoute)
+		apiGroup.PUT("/routes/:id", h.UpdateToolRoute)
+		apiGroup.DELETE("/routes/:id", h.DeleteToolRoute)
+
+		apiGroup.GET("/secrets", h.ListSecrets)
+		apiGroup.PUT("/secrets/:name", h.PutSecret)
+		apiGroup.DELETE("/secrets/:name", h.DeleteSecret)
+
+		apiGroup.GET("/catalog/versions", h.ListCatalogVersions)
+		apiGroup.POST("/catalog/versions", h.CreateCatalogVersion)
+		apiGroup.GET("/catalog/versions/:id", h.GetCatalogVersion)
+		apiGroup.POST("/catalog/versions/:id/promote", h.PromoteCatalogVersion)
+		apiGroup.DELETE("/catalog/versions/:id", h.DeleteCatalogVersion)
+
+		apiGroup.GET("/jobs", h.ListJobs)
+		apiGroup.GET("/jobs/:id", h.GetJob)
+		apiGroup.POST("/jobs/:id/retry", h.RetryJob)
+		apiGroup.POST("/jobs/:id/cancel", h.CancelJob)
+
+		apiGroup.GET("/calls", h.ListCalls)
+
+		apiGroup.GET("/prices", h.ListToolPrices)
+		apiGroup.POST("/prices", h.CreateToolPrice)
+		apiGroup.PUT("/prices/:id", h.UpdateToolPrice)
+		apiGroup.DELETE("/prices/:id", h.DeleteToolPrice)
+		apiGroup.GET("/billing/invoice", h.GetInvoice)
+		apiGroup.GET("/calls/:id", h.GetCall)
+
+		apiGroup.GET("/moderation/logs", h.ListModerationLogs)
+		apiGroup.PUT("/moderation/logs/:id/review", h.ReviewModerationLog)
+
+		apiGroup.POST("/change-password", h.ChangePassword)
+
+		apiGroup.GET("/admin-tokens", h.ListAdminTokens)
+		apiGroup.POST("/admin-tokens", h.CreateAdminToken)
+		apiGroup.DELETE("/admin-tokens/:id", h.DeleteAdminToken)
+
+		apiGroup.GET("/changes", h.ListChanges)
+
+		apiGroup.GET("/settings", h.GetSettings)
+		apiGroup.PUT("/settings", h.UpdateSettings)
+	}
+
+	// OAuth protected resource metadata, at the root and suffixed by the resource path (RFC 9728)
+	r.GET("/.well-known/oauth-protected-resource", h.HandleProtectedResourceMetadata)
+	r.GET("/.well-known/oauth-protected-resource/mcp", h.HandleProtectedResourceMetadata)
+
+	mcpGroup := r.Group("/mcp")
+	mcpGroup.GET("/blobs/:token", h.HandleBlob)
+	h.registerMCPRoutes(mcpGroup)
+
+	// The same endpoints for keys with a path slug, authenticated by the path
+	h.registerMCPRoutes(mcpGroup.Group("/k/:slug"))
+}
+
+// registerMCPRoutes adds the MCP transports to g.
+func (h *Handler) registerMCPRoutes(g *gin.RouterGroup) {
+	// Streamable HTTP transport
+	g.POST("", h.HandleStreamablePost)
+	g.GET("", h.HandleStreamableGet)
+	g.DELETE("", h.HandleStreamableDelete)
+	g.POST("/stateless", h.HandleStatelessPost)
+	g.GET("/ws", h.HandleWebSocket)
+
+	// Legacy HTTP+SSE transport
+	g.GET("/sse", h.HandleSSE)
+	g.POST("/messages", h.HandleMessage)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) ListToolRoutes(c *gin.Context) {
+	var routes []model.ToolRoute
+	h.db.Order("name").Find(&routes)
+	c.JSON(200, routes)
+}
+
+func (h *Handler) CreateToolRoute(c *gin.Context) {
+	var route model.ToolRoute
+	if err := c.ShouldBindJSON(&route); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := core.ValidateToolRoute(&route); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.db.Create(&route).Error; err != nil {
+		c.JSON(400, gin.H{"error": "Route name already exists"})
+		return
+	}
+	h.recordChange(c, "create", "route", route.ID, route)
+	c.JSON(200, route)
+}
+
+func (h *Handler) UpdateToolRoute(c *gin.Context) {
+	var route model.ToolRoute
+	if err := h.db.First(&route, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+	id := route.ID
+	if err := c.ShouldBindJSON(&route); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	route.ID = id // An id in the body must not redirect the update to another route
+	if err := core.ValidateToolRoute(&route); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.db.Save(&route).Error; err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordChange(c, "update", "route", route.ID, route)
+	c.JSON(200, route)
+}
+
+func (h *Handler) DeleteToolRoute(c *gin.Context) {
+	if h.db.Where("id = ?", c.Param("id")).Delete(&model.ToolRoute{}).RowsAffected > 0 {
+		h.recordChange(c, "delete", "route", c.Param("id"), nil)
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
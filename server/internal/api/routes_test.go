@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestUpdateToolRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.ToolRoute{}, &model.ConfigChange{})
+	first := model.ToolRoute{Name: "search", DefaultTool: "us__search", Enabled: true}
+	second := model.ToolRoute{Name: "fetch", DefaultTool: "us__fetch", Enabled: true}
+	db.Create(&first)
+	db.Create(&second)
+
+	h := &Handler{db: db, settings: &config.Config{}}
+	r := gin.New()
+	r.PUT("/routes/:id", h.UpdateToolRoute)
+	put := func(id string, body string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("PUT", "/routes/"+id, strings.NewReader(body)))
+		return w.Code
+	}
+
+	assert.Equal(t, 200, put("1", `{"id": 2, "name": "search", "default_tool": "eu__search", "enabled": true}`))
+	var routes []model.ToolRoute
+	db.Order("id").Find(&routes)
+	assert.Equal(t, "eu__search", routes[0].DefaultTool, "the route in the path is updated")
+	assert.Equal(t, "fetch", routes[1].Name, "the id in the body is ignored")
+	assert.Equal(t, "us__fetch", routes[1].DefaultTool)
+
+	assert.Equal(t, 400, put("1", `{"name": "search", "default_tool": "route__other"}`))
+	assert.Equal(t, 404, put("9", `{}`))
+}
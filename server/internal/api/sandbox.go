@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Sandbox profiles are managed under /api/v1/servers/sandbox-profiles, so that
+// servers-only admin tokens can manage them along with the servers.
+
+func (h *Handler) ListSandboxProfiles(c *gin.Context) {
+	var profiles []model.SandboxProfile
+	h.db.Order("name").Find(&profiles)
+	c.JSON(200, profiles)
+}
+
+func (h *Handler) CreateSandboxProfile(c *gin.Context) {
+	var profile model.SandboxProfile
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := core.ValidateSandboxProfile(profile); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.db.Create(&profile).Error; err != nil {
+		c.JSON(400, gin.H{"error": "Sandbox profile name already exists"})
+		return
+	}
+	h.recordChange(c, "create", "sandbox_profile", profile.ID, profile)
+	c.JSON(200, profile)
+}
+
+// UpdateSandboxProfile changes a profile, which must still apply to every server
+// using it. Those servers are restarted under the new profile.
+func (h *Handler) UpdateSandboxProfile(c *gin.Context) {
+	var profile model.SandboxProfile
+	if err := h.db.First(&profile, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+	id := profile.ID
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	profile.ID = id
+	if err := core.ValidateSandboxProfile(profile); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	var servers []model.UpstreamServer
+	h.db.Where("sandbox_profile_id = ?", profile.ID).Find(&servers)
+	for _, server := range servers {
+		if err := sandboxApplies(&profile, server); err != nil {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("Server %s: %v", server.Name, err)})
+			return
+		}
+	}
+	if err := h.db.Save(&profile).Error; err != nil {
+		c.JSON(400, gin.H{"error": "Sandbox profile name already exists"})
+		return
+	}
+	h.recordChange(c, "update", "sandbox_profile", profile.ID, profile)
+	if len(servers) > 0 {
+		h.gateway.ReloadUpstreams()
+	}
+	c.JSON(200, profile)
+}
+
+// DeleteSandboxProfile deletes a profile no server uses: servers are not
+// silently launched without their profile.
+func (h *Handler) DeleteSandboxProfile(c *gin.Context) {
+	id := c.Param("id")
+	var count int64
+	h.db.Model(&model.UpstreamServer{}).Where("sandbox_profile_id = ?", id).Count(&count)
+	if count > 0 {
+		c.JSON(409, gin.H{"error": fmt.Sprintf("Sandbox profile is used by %d server(s)", count)})
+		return
+	}
+	if h.db.Where("id = ?", id).Delete(&model.SandboxProfile{}).RowsAffected > 0 {
+		h.recordChange(c, "delete", "sandbox_profile", id, nil)
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+// validateSandbox checks the sandbox profile of a server exists and applies to
+// its command.
+func (h *Handler) validateSandbox(server model.UpstreamServer) error {
+	if server.SandboxProfileID == 0 {
+		return nil
+	}
+	if server.TransportType != "stdio" {
+		return fmt.Errorf("Sandbox profiles apply to stdio servers only")
+	}
+	var profile model.SandboxProfile
+	if err := h.db.First(&profile, server.SandboxProfileID).Error; err != nil {
+		return fmt.Errorf("Sandbox profile not found")
+	}
+	return sandboxApplies(&profile, server)
+}
+
+func sandboxApplies(profile *model.SandboxProfile, server model.UpstreamServer) error {
+	var args []string
+	if server.Args != "" {
+		json.Unmarshal([]byte(server.Args), &args)
+	}
+	_, _, err := core.SandboxCommand(profile, server.Command, args)
+	return err
+}
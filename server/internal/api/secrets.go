@@ -0,0 +1,56 @@
+package api
+
+import (
+	"one-mcp/internal/model"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ListSecrets lists stored secrets. Values are never returned.
+func (h *Handler) ListSecrets(c *gin.Context) {
+	var secrets []model.Secret
+	h.db.Order("name").Find(&secrets)
+	c.JSON(200, secrets)
+}
+
+// PutSecret creates or replaces the secret with the given name.
+func (h *Handler) PutSecret(c *gin.Context) {
+	name := c.Param("name")
+	if !secretNamePattern.MatchString(name) {
+		c.JSON(400, gin.H{"error": "Secret names may only contain letters, digits, '_', '.' and '-'"})
+		return
+	}
+
+	var req struct {
+		Value string `json:"value" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	var secret model.Secret
+	h.db.Where("name = ?", name).First(&secret)
+	action := "update"
+	if secret.ID == 0 {
+		action = "create"
+	}
+	secret.Name = name
+	secret.Value = req.Value
+	if err := h.db.Save(&secret).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordChange(c, action, "secret", name, secret)
+	c.JSON(200, secret)
+}
+
+func (h *Handler) DeleteSecret(c *gin.Context) {
+	if h.db.Where("name = ?", c.Param("name")).Delete(&model.Secret{}).RowsAffected > 0 {
+		h.recordChange(c, "delete", "secret", c.Param("name"), nil)
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
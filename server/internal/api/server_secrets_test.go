@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestWriteOnlyServerSecrets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(model.All...))
+
+	settings := &config.Config{}
+	h := &Handler{db: db, gateway: core.NewGateway(db, settings), settings: settings}
+	t.Cleanup(h.gateway.StopUpstreams)
+	r := gin.New()
+	r.GET("/servers", h.ListServers)
+	r.POST("/servers", h.CreateServer)
+	r.PUT("/servers/:id", h.UpdateServer)
+	request := func(method, path, body string) string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		assert.Equal(t, 200, w.Code, w.Body.String())
+		return w.Body.String()
+	}
+	stored := func() string {
+		var server model.UpstreamServer
+		db.First(&server)
+		return server.OAuthClientSecret
+	}
+
+	body := request("POST", "/servers", `{"name": "hosted", "transport_type": "demo", "oauth_client_id": "one-mcp", "oauth_client_secret": "cs-123"}`)
+	assert.NotContains(t, body, "cs-123")
+	assert.Contains(t, body, `"has_oauth_client_secret":true`)
+	assert.Contains(t, body, `"has_tls_client_key":false`)
+	assert.Equal(t, "cs-123", stored())
+
+	body = request("GET", "/servers", "")
+	assert.NotContains(t, body, "cs-123")
+	assert.Contains(t, body, `"has_oauth_client_secret":true`)
+
+	request("PUT", "/servers/1", `{"name": "hosted", "transport_type": "demo", "oauth_client_id": "renamed"}`)
+	assert.Equal(t, "cs-123", stored(), "an absent secret is kept")
+	request("PUT", "/servers/1", `{"name": "hosted", "transport_type": "demo", "oauth_client_secret": ""}`)
+	assert.Equal(t, "", stored(), "an empty secret clears it")
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestServerStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(model.All...))
+
+	running := model.UpstreamServer{Name: "running", TransportType: "demo", Enabled: true}
+	stopped := model.UpstreamServer{Name: "stopped", TransportType: "demo", Enabled: true}
+	db.Create(&running)
+	db.Create(&stopped)
+	db.Model(&stopped).Update("enabled", false)
+
+	settings := &config.Config{}
+	h := &Handler{db: db, gateway: core.NewGateway(db, settings), settings: settings}
+	h.gateway.ReloadUpstreams()
+	t.Cleanup(h.gateway.StopUpstreams)
+	r := gin.New()
+	r.GET("/api/v1/servers", h.ListServers)
+	r.GET("/api/v1/servers/:id/status", h.GetServerStatus)
+
+	status := func(id string) (int, core.UpstreamStatus) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/servers/"+id+"/status", nil))
+		var status core.UpstreamStatus
+		json.Unmarshal(w.Body.Bytes(), &status)
+		return w.Code, status
+	}
+
+	assert.Eventually(t, func() bool {
+		_, s := status("1")
+		return s.State == core.StateReady
+	}, 5*time.Second, 10*time.Millisecond)
+	code, s := status("2")
+	assert.Equal(t, 200, code)
+	assert.Equal(t, core.StateDisabled, s.State)
+	assert.Equal(t, "stopped", s.Name)
+	code, _ = status("3")
+	assert.Equal(t, 404, code)
+
+	// Servers are listed with their status
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/servers", nil))
+	var servers []struct {
+		Name          string              `json:"name"`
+		TransportType string              `json:"transport_type"`
+		Status        core.UpstreamStatus `json:"status"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &servers))
+	require.Len(t, servers, 2)
+	assert.Equal(t, "demo", servers[0].TransportType)
+	assert.Equal(t, core.StateReady, servers[0].Status.State)
+	assert.Equal(t, core.StateDisabled, servers[1].Status.State)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"time"
+)
+
+// sessionRecordTTL is how long a session without a live SSE stream can still be resumed.
+const sessionRecordTTL = 24 * time.Hour
+
+// persistSession stores the session state so it survives a gateway restart.
+func (h *Handler) persistSession(id string, session *Session) {
+	version, capabilities := session.Caller.Negotiated()
+	declared, _ := json.Marshal(capabilities)
+	record := model.SessionRecord{
+		ID:              id,
+		KeyID:           session.Caller.KeyID,
+		ProtocolVersion: version,
+		Capabilities:    string(declared),
+	}
+	if err := h.db.Save(&record).Error; err != nil {
+		fmt.Printf("[Session] Failed to persist session %s: %v\n", id, err)
+	}
+}
+
+// restoreSession gives caller the protocol version and client capabilities
+// negotiated before the session lost its stream, so the client need not
+// initialize again.
+func restoreSession(caller *core.Caller, record *model.SessionRecord) {
+	var capabilities map[string]json.RawMessage
+	json.Unmarshal([]byte(record.Capabilities), &capabilities)
+	caller.Negotiate(record.ProtocolVersion, capabilities)
+}
+
+// forgetSession removes the persisted state of a session that ended normally.
+func (h *Handler) forgetSession(id string) {
+	h.db.Delete(&model.SessionRecord{}, "id = ?", id)
+}
+
+// resumableSession returns the persisted record of a session ID that has no live
+// SSE stream, e.g. because the gateway restarted.
+func (h *Handler) resumableSession(id string) (*model.SessionRecord, bool) {
+	if id == "" {
+		return nil, false
+	}
+	var record model.SessionRecord
+	if err := h.db.First(&record, "id = ?", id).Error; err != nil {
+		return nil, false
+	}
+	if time.Since(record.UpdatedAt) > sessionRecordTTL {
+		h.forgetSession(id)
+		return nil, false
+	}
+	return &record, true
+}
+
+// pruneSessionRecords deletes session records too old to be resumed.
+func (h *Handler) pruneSessionRecords() {
+	h.db.Where("updated_at < ?", time.Now().Add(-sessionRecordTTL)).Delete(&model.SessionRecord{})
+}
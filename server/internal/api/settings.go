@@ -0,0 +1,34 @@
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSettings returns the current feature flags.
+func (h *Handler) GetSettings(c *gin.Context) {
+	c.JSON(200, h.gateway.Flags())
+}
+
+// UpdateSettings changes feature flags without a restart. The body holds the
+// flags to change, e.g. {"cache_tools": true}; null resets a flag to its default.
+func (h *Handler) UpdateSettings(c *gin.Context) {
+	var values map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&values); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if len(values) == 0 {
+		c.JSON(400, gin.H{"error": "No flags given"})
+		return
+	}
+
+	flags, err := h.gateway.UpdateFlags(values)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordChange(c, "update", "settings", "flags", flags)
+	c.JSON(200, flags)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Stateless HTTP mode: every POST is answered on its own, with the JSON-RPC
+// response in the body, so a client can call tools with a single curl command.
+// No session is created, so nothing is kept between requests and the gateway
+// cannot send the client notifications or requests (sampling, elicitation).
+// POST /mcp/stateless is stateless for every key; POST /mcp is for keys with
+// Stateless set.
+
+// HandleStatelessPost answers a message or batch posted to /mcp/stateless.
+func (h *Handler) HandleStatelessPost(c *gin.Context) {
+	apiKey, ok := h.authenticateKey(c)
+	if !ok {
+		return
+	}
+	if version := c.GetHeader("MCP-Protocol-Version"); version != "" && !core.SupportedProtocolVersion(version) {
+		rpcError(c, 400, -32600, "Unsupported MCP-Protocol-Version "+version)
+		return
+	}
+	if err := h.gateway.Limits().AllowMessage(); err != nil {
+		h.limited(c, err)
+		return
+	}
+	h.serveStateless(c, apiKey)
+}
+
+// serveStateless runs the posted message or batch without a session. Requests
+// are answered as JSON; notifications and responses alone get 202.
+func (h *Handler) serveStateless(c *gin.Context, apiKey *model.ApiKey) {
+	body, _ := io.ReadAll(c.Request.Body)
+	if !json.Valid(body) {
+		rpcError(c, 400, -32700, "Parse error")
+		return
+	}
+
+	// Requests outside of initialize are assumed to use 2025-03-26, the first
+	// version of Streamable HTTP, unless the client names its version
+	caller := core.CallerForKey(h.db, apiKey)
+	caller.ProtocolVersion = core.Protocol20250326
+	if version := c.GetHeader("MCP-Protocol-Version"); version != "" {
+		caller.ProtocolVersion = version
+	}
+	session := &Session{Caller: caller}
+
+	var resp []byte
+	var batch []json.RawMessage
+	if json.Unmarshal(body, &batch) == nil {
+		resp = h.runBatch("", session, batch)
+	} else {
+		resp = h.runMessage("", session, body)
+	}
+	if resp == nil {
+		c.Status(202)
+		return
+	}
+	c.Data(200, "application/json", resp)
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestStatelessHTTP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.ApiKey{})
+	db.Create(&model.ApiKey{Key: "sk-session"})
+	db.Create(&model.ApiKey{Key: "sk-stateless", Stateless: true})
+
+	settings := &config.Config{SessionBufferSize: 8, SessionConcurrency: 2}
+	h := &Handler{db: db, gateway: core.NewGateway(nil, settings), settings: settings}
+	r := gin.New()
+	r.POST("/mcp", h.HandleStreamablePost)
+	r.GET("/mcp", h.HandleStreamableGet)
+	r.POST("/mcp/stateless", h.HandleStatelessPost)
+
+	request := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		req.Header.Set("Accept", "application/json, text/event-stream")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	// Sessions of earlier tests may end meanwhile, so only new ones are counted
+	before := map[interface{}]bool{}
+	sessions.Range(func(id, _ interface{}) bool { before[id] = true; return true })
+	newSessions := func() int {
+		n := 0
+		sessions.Range(func(id, _ interface{}) bool {
+			if !before[id] {
+				n++
+			}
+			return true
+		})
+		return n
+	}
+
+	for _, tc := range []struct{ path, key string }{
+		{"/mcp/stateless", "sk-session"},
+		{"/mcp", "sk-stateless"},
+	} {
+		t.Run(tc.path+" "+tc.key, func(t *testing.T) {
+			w := request("POST", tc.path, tc.key, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{}}}`)
+			assert.Equal(t, 200, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			assert.Contains(t, w.Body.String(), `"protocolVersion":"2025-06-18"`)
+			assert.Empty(t, w.Header().Get("Mcp-Session-Id"))
+
+			w = request("POST", tc.path, tc.key, `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+			assert.JSONEq(t, `{"jsonrpc":"2.0","id":2,"result":{}}`, w.Body.String(), "no session is needed")
+
+			w = request("POST", tc.path, tc.key, `[{"jsonrpc":"2.0","id":3,"method":"ping"},{"jsonrpc":"2.0","method":"notifications/initialized"}]`)
+			assert.JSONEq(t, `[{"jsonrpc":"2.0","id":3,"result":{}}]`, w.Body.String())
+
+			w = request("POST", tc.path, tc.key, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+			assert.Equal(t, 202, w.Code)
+			assert.Equal(t, 400, request("POST", tc.path, tc.key, `{"jsonrpc"`).Code)
+		})
+	}
+	assert.Zero(t, newSessions())
+
+	assert.Equal(t, 401, request("POST", "/mcp/stateless", "sk-x", `{"jsonrpc":"2.0","id":1,"method":"ping"}`).Code)
+	assert.Equal(t, 400, request("POST", "/mcp", "sk-session", `{"jsonrpc":"2.0","id":1,"method":"ping"}`).Code, "other keys still need a session on /mcp")
+	w := request("GET", "/mcp", "sk-stateless", "")
+	assert.Equal(t, 405, w.Code)
+	assert.Equal(t, "POST", w.Header().Get("Allow"))
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"one-mcp/internal/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// heatmapBuckets maps the bucket sizes of the heatmap to SQLite time formats.
+// Buckets are in UTC and named by their start.
+var heatmapBuckets = map[string]string{
+	"hour": "%Y-%m-%dT%H:00:00Z",
+	"day":  "%Y-%m-%d",
+}
+
+// GetUsageHeatmap returns tool call counts per tool and time bucket for capacity
+// planning. Parameters: days (default 7, max 366), bucket (hour or day, default
+// hour), tool and server to narrow it down. Only buckets with calls are listed.
+func (h *Handler) GetUsageHeatmap(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days < 1 || days > 366 {
+		c.JSON(400, gin.H{"error": "Invalid days"})
+		return
+	}
+	bucket := c.DefaultQuery("bucket", "hour")
+	format, ok := heatmapBuckets[bucket]
+	if !ok {
+		c.JSON(400, gin.H{"error": "Invalid bucket: must be hour or day"})
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	query := h.reads().Model(&model.UsageLog{}).Where("created_at >= ?", since)
+	if tool := c.Query("tool"); tool != "" {
+		query = query.Where("tool = ?", tool)
+	}
+	if server := c.Query("server"); server != "" {
+		query = query.Where("server = ?", server)
+	}
+
+	type cell struct {
+		Tool   string `json:"tool"`
+		Bucket string `json:"bucket"`
+		Calls  int64  `json:"calls"`
+		Errors int64  `json:"errors"`
+	}
+	cells := []cell{}
+	if err := query.
+		Select("tool, strftime(?, created_at) AS bucket, COUNT(*) AS calls, SUM(CASE WHEN success THEN 0 ELSE 1 END) AS errors", format).
+		Group("tool, bucket").Order("tool, bucket").Scan(&cells).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"days":   days,
+		"bucket": bucket,
+		"since":  since,
+		"cells":  cells,
+	})
+}
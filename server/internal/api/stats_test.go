@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestUsageHeatmap(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.UsageLog{})
+	h := &Handler{db: db}
+
+	hour := time.Now().UTC().Truncate(time.Hour)
+	for _, log := range []model.UsageLog{
+		{CreatedAt: hour.Add(5 * time.Minute), Server: "demo", Tool: "demo__echo", Success: true},
+		{CreatedAt: hour.Add(10 * time.Minute), Server: "demo", Tool: "demo__echo", Success: false},
+		{CreatedAt: hour.Add(-time.Hour), Server: "demo", Tool: "demo__echo", Success: true},
+		{CreatedAt: hour, Server: "gh", Tool: "gh__search", Success: true},
+		{CreatedAt: hour.AddDate(0, 0, -30), Server: "demo", Tool: "demo__echo", Success: true},
+	} {
+		db.Create(&log)
+	}
+
+	type cell struct {
+		Tool   string `json:"tool"`
+		Bucket string `json:"bucket"`
+		Calls  int64  `json:"calls"`
+		Errors int64  `json:"errors"`
+	}
+	get := func(query string) (int, []cell) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/api/v1/stats/heatmap?"+query, nil)
+		h.GetUsageHeatmap(c)
+		var resp struct {
+			Cells []cell `json:"cells"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Cells
+	}
+
+	t.Run("Hourly", func(t *testing.T) {
+		code, cells := get("")
+		assert.Equal(t, 200, code)
+		assert.Equal(t, []cell{
+			{Tool: "demo__echo", Bucket: hour.Add(-time.Hour).Format("2006-01-02T15:00:00Z"), Calls: 1},
+			{Tool: "demo__echo", Bucket: hour.Format("2006-01-02T15:00:00Z"), Calls: 2, Errors: 1},
+			{Tool: "gh__search", Bucket: hour.Format("2006-01-02T15:00:00Z"), Calls: 1},
+		}, cells)
+	})
+
+	t.Run("Daily And Filtered", func(t *testing.T) {
+		_, cells := get("bucket=day&days=60&server=demo")
+		assert.Equal(t, hour.AddDate(0, 0, -30).Format("2006-01-02"), cells[0].Bucket)
+		calls := int64(0)
+		for _, cell := range cells {
+			assert.Equal(t, "demo__echo", cell.Tool)
+			calls += cell.Calls
+		}
+		assert.Equal(t, int64(4), calls)
+
+		_, cells = get("tool=gh__search")
+		assert.Len(t, cells, 1)
+	})
+
+	t.Run("Invalid Parameters", func(t *testing.T) {
+		code, _ := get("bucket=week")
+		assert.Equal(t, 400, code)
+		code, _ = get("days=0")
+		assert.Equal(t, 400, code)
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.UpstreamServer{})
+	settings := config.Default()
+	settings.DemoUpstream = true
+	gateway := core.NewGateway(db, settings)
+	gateway.ReloadUpstreams()
+	defer gateway.StopUpstreams()
+	h := &Handler{db: db, gateway: gateway}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/api/v1/metrics", nil)
+	h.GetMetrics(c)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain; version=0.0.4")
+	body := w.Body.String()
+	assert.Contains(t, body, "# TYPE one_mcp_upstream_reconnects_total counter\n")
+	assert.Contains(t, body, `one_mcp_upstream_inflight_requests{server="demo"} 0`+"\n")
+	assert.Contains(t, body, `one_mcp_upstream_concurrency_limit{server="demo"} 0`+"\n")
+	assert.Contains(t, body, "one_mcp_sessions 0\n")
+	assert.Equal(t, `a\"b\\c\n`, escapeLabel("a\"b\\c\n"))
+}
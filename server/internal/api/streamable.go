@@ -0,0 +1,353 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Streamable HTTP transport (MCP 2025-03-26): clients POST messages to /mcp and
+// get the responses in the HTTP body, as JSON or as an SSE stream. The session
+// is named by the Mcp-Session-Id header and is not tied to a connection; GET /mcp
+// opens a stream for messages the gateway sends on its own, and DELETE /mcp ends
+// the session. Sessions also end after SESSION_IDLE_TIMEOUT without requests or
+// open streams, or SESSION_MAX_LIFETIME; their ID is then answered with 404 and
+// the client must initialize a new session.
+
+// mcpSessionHeader carries the session ID of the Streamable HTTP transport.
+const mcpSessionHeader = "Mcp-Session-Id"
+
+// sessionNotFound answers a request naming an unknown, expired or terminated
+// session, telling the client to start over as the protocol requires.
+func sessionNotFound(c *gin.Context) {
+	rpcError(c, 404, -32001, "Session not found or expired; send a new initialize request without "+mcpSessionHeader)
+}
+
+// touch records activity on the session.
+func (s *Session) touch() {
+	s.lastActive.Store(time.Now().UnixNano())
+}
+
+// begin marks a request or stream of the session as in progress. The returned
+// function ends it.
+func (s *Session) begin() func() {
+	s.active.Add(1)
+	s.touch()
+	return func() {
+		s.touch()
+		s.active.Add(-1)
+	}
+}
+
+// idle reports whether the session has nothing in progress and no recent activity.
+func (s *Session) idle(timeout time.Duration) bool {
+	return s.active.Load() == 0 && time.Since(time.Unix(0, s.lastActive.Load())) > timeout
+}
+
+// rpcError answers with a JSON-RPC error that has no ID.
+func rpcError(c *gin.Context, status int, code int, message string) {
+	null := json.RawMessage("null")
+	c.JSON(status, &core.JSONRPCMessage{
+		JSONRPC: "2.0",
+		ID:      &null,
+		Error:   &core.JSONRPCError{Code: code, Message: message},
+	})
+}
+
+func acceptsEventStream(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+}
+
+// HandleStreamablePost receives client messages on the Streamable HTTP transport.
+// An initialize request starts a session; other messages need its Mcp-Session-Id.
+// Notifications and responses are acknowledged with 202. Keys in stateless mode
+// are answered without a session (see stateless.go). Requests are answered
+// as JSON, or over SSE if the client accepts it, which also carries the messages
+// the gateway sends the session meanwhile (e.g. progress or sampling requests).
+func (h *Handler) HandleStreamablePost(c *gin.Context) {
+	apiKey, ok := h.authenticateKey(c)
+	if !ok {
+		return
+	}
+	if version := c.GetHeader("MCP-Protocol-Version"); version != "" && !core.SupportedProtocolVersion(version) {
+		rpcError(c, 400, -32600, "Unsupported MCP-Protocol-Version "+version)
+		return
+	}
+	if err := h.gateway.Limits().AllowMessage(); err != nil {
+		h.limited(c, err)
+		return
+	}
+	if apiKey.Stateless {
+		h.serveStateless(c, apiKey)
+		return
+	}
+
+	body, _ := io.ReadAll(c.Request.Body)
+	if !json.Valid(body) {
+		rpcError(c, 400, -32700, "Parse error")
+		return
+	}
+	var batch []json.RawMessage
+	isBatch := json.Unmarshal(body, &batch) == nil
+	messages := batch
+	if !isBatch {
+		messages = []json.RawMessage{body}
+	}
+
+	hasRequests, initialize := false, false
+	for _, msg := range messages {
+		var probe struct {
+			ID     *json.RawMessage `json:"id"`
+			Method string           `json:"method"`
+		}
+		json.Unmarshal(msg, &probe)
+		hasRequests = hasRequests || (probe.ID != nil && probe.Method != "")
+		initialize = initialize || probe.Method == "initialize"
+	}
+
+	var sessionID string
+	var session *Session
+	if initialize {
+		if isBatch {
+			rpcError(c, 400, -32600, "initialize must not be part of a batch")
+			return
+		}
+		var err error
+		if sessionID, session, err = h.startStreamableSession(apiKey); err != nil {
+			h.limited(c, err)
+			return
+		}
+		c.Header(mcpSessionHeader, sessionID)
+	} else {
+		if sessionID = c.GetHeader(mcpSessionHeader); sessionID == "" {
+			rpcError(c, 400, -32600, "Missing "+mcpSessionHeader+" header")
+			return
+		}
+		if session, ok = streamableSession(sessionID, apiKey.ID); !ok {
+			sessionNotFound(c)
+			return
+		}
+	}
+	defer session.begin()()
+
+	process := func() []byte {
+		if isBatch {
+			return h.runBatch(sessionID, session, batch)
+		}
+		return h.runMessage(sessionID, session, body)
+	}
+	if !hasRequests {
+		process()
+		c.Status(202)
+		return
+	}
+
+	// Batch entries take a slot each as they run (see runBatch)
+	release := func() {}
+	if !isBatch {
+		if !session.acquire() {
+			sessionNotFound(c)
+			return
+		}
+		release = session.release
+	}
+	result := make(chan []byte, 1)
+	go func() {
+		defer release()
+		result <- process()
+	}()
+
+	if acceptsEventStream(c) {
+		h.streamEvents(c, session, result)
+		return
+	}
+	select {
+	case resp := <-result:
+		if resp == nil {
+			// The request was cancelled
+			c.Status(202)
+			return
+		}
+		c.Data(200, "application/json", resp)
+	case <-c.Request.Context().Done():
+	}
+}
+
+// HandleStreamableGet opens a stream for the messages the gateway sends the
+// session outside of a request, such as tools/list_changed notifications.
+func (h *Handler) HandleStreamableGet(c *gin.Context) {
+	apiKey, ok := h.authenticateKey(c)
+	if !ok {
+		return
+	}
+	if apiKey.Stateless {
+		c.Header("Allow", "POST")
+		rpcError(c, 405, -32600, "Stateless keys have no server-initiated stream")
+		return
+	}
+	if !acceptsEventStream(c) {
+		rpcError(c, 406, -32600, "Accept must include text/event-stream")
+		return
+	}
+	session, ok := streamableSession(c.GetHeader(mcpSessionHeader), apiKey.ID)
+	if !ok {
+		sessionNotFound(c)
+		return
+	}
+	defer session.begin()()
+	h.streamEvents(c, session, nil)
+}
+
+// HandleStreamableDelete ends a Streamable HTTP session at the client's request,
+// e.g. when the user quits. Streams of the session close; requests still running
+// complete, but their responses can no longer be delivered.
+func (h *Handler) HandleStreamableDelete(c *gin.Context) {
+	apiKey, ok := h.authenticateKey(c)
+	if !ok {
+		return
+	}
+	if apiKey.Stateless {
+		c.Header("Allow", "POST")
+		rpcError(c, 405, -32600, "Stateless keys have no session to end")
+		return
+	}
+	sessionID := c.GetHeader(mcpSessionHeader)
+	if sessionID == "" {
+		rpcError(c, 400, -32600, "Missing "+mcpSessionHeader+" header")
+		return
+	}
+	session, ok := streamableSession(sessionID, apiKey.ID)
+	if !ok {
+		sessionNotFound(c)
+		return
+	}
+	session.end()
+	fmt.Printf("[Session] Streamable HTTP session %s terminated by the client\n", sessionID)
+	c.Status(204)
+}
+
+// streamEvents writes the session's messages as SSE events until result yields
+// the response, which ends the stream. With a nil result the stream lasts until
+// the client disconnects or the session ends.
+func (h *Handler) streamEvents(c *gin.Context, session *Session, result <-chan []byte) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(200)
+	c.Writer.Flush()
+
+	for {
+		select {
+		case msg := <-session.MsgChan:
+			c.Render(-1, sse.Event{Event: "message", Data: string(msg)})
+			c.Writer.Flush()
+		case resp := <-result:
+			if resp != nil {
+				c.Render(-1, sse.Event{Event: "message", Data: string(resp)})
+				c.Writer.Flush()
+			}
+			return
+		case <-c.Request.Context().Done():
+			return
+		case <-session.done:
+			return
+		}
+	}
+}
+
+// startStreamableSession creates a session for the key, which ends once it has
+// been idle for SESSION_IDLE_TIMEOUT, has lasted SESSION_MAX_LIFETIME or is
+// deleted by the client.
+func (h *Handler) startStreamableSession(apiKey *model.ApiKey) (string, *Session, error) {
+	release, err := h.gateway.Limits().AcquireSession(apiKey.ID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	caller := h.newCaller(apiKey)
+	sessionID := uuid.New().String()
+	session := &Session{
+		MsgChan:    make(chan []byte, h.settings.SessionBufferSize),
+		done:       make(chan struct{}),
+		slots:      make(chan struct{}, h.settings.SessionConcurrency),
+		Caller:     caller,
+		streamable: true,
+	}
+	var once sync.Once
+	session.end = func() {
+		once.Do(func() {
+			sessions.Delete(sessionID)
+			close(session.done)
+			h.gateway.DropSession(sessionID)
+			release()
+		})
+	}
+	session.touch()
+	caller.SessionID = sessionID
+	caller.Notify = session.Send
+	sessions.Store(sessionID, session)
+	h.gateway.AddSession(caller)
+	fmt.Printf("[Session] Started Streamable HTTP session %s for key %d\n", sessionID, apiKey.ID)
+
+	go h.expireSession(sessionID, session, h.settings.SessionIdleTimeout, h.settings.SessionMaxLifetime)
+	return sessionID, session, nil
+}
+
+// expireSession ends a Streamable HTTP session once it has been idle for
+// SESSION_IDLE_TIMEOUT or, if set, has lasted SESSION_MAX_LIFETIME. The timeouts
+// are those in effect when the session started.
+func (h *Handler) expireSession(sessionID string, session *Session, idle time.Duration, lifetime time.Duration) {
+	// Idle sessions are noticed within a fraction of the timeout
+	interval := idle / 4
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var deadline <-chan time.Time
+	if lifetime > 0 {
+		timer := time.NewTimer(lifetime)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		select {
+		case <-session.done:
+			return
+		case <-deadline:
+			session.end()
+			fmt.Printf("[Session] Streamable HTTP session %s reached its max lifetime of %v\n", sessionID, lifetime)
+			return
+		case <-ticker.C:
+			if session.idle(idle) {
+				session.end()
+				fmt.Printf("[Session] Streamable HTTP session %s expired after %v idle\n", sessionID, idle)
+				return
+			}
+		}
+	}
+}
+
+// streamableSession returns the live Streamable HTTP session with the ID if it
+// belongs to the key.
+func streamableSession(id string, keyID uint) (*Session, bool) {
+	val, ok := sessions.Load(id)
+	if !ok {
+		return nil, false
+	}
+	session := val.(*Session)
+	if !session.streamable || session.Caller.KeyID != keyID {
+		return nil, false
+	}
+	return session, true
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestStreamableHTTP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.ApiKey{})
+	db.Create(&model.ApiKey{Key: "sk-a"})
+	db.Create(&model.ApiKey{Key: "sk-b"})
+
+	settings := &config.Config{SessionBufferSize: 8, SessionConcurrency: 2, SessionIdleTimeout: time.Minute}
+	h := &Handler{db: db, gateway: core.NewGateway(nil, settings), settings: settings}
+	r := gin.New()
+	r.POST("/mcp", h.HandleStreamablePost)
+	r.GET("/mcp", h.HandleStreamableGet)
+	r.DELETE("/mcp", h.HandleStreamableDelete)
+
+	post := func(key, session, accept, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/mcp", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		req.Header.Set("Accept", accept)
+		if session != "" {
+			req.Header.Set("Mcp-Session-Id", session)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	const ping = `{"jsonrpc":"2.0","id":2,"method":"ping"}`
+
+	w := post("sk-a", "", "application/json, text/event-stream", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{}}}`)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"protocolVersion":"2025-03-26"`)
+	session := w.Header().Get("Mcp-Session-Id")
+	assert.NotEmpty(t, session)
+
+	t.Run("Notifications Are Accepted", func(t *testing.T) {
+		w := post("sk-a", session, "application/json", `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+		assert.Equal(t, 202, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("JSON Or SSE Response", func(t *testing.T) {
+		w := post("sk-a", session, "application/json", ping)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"jsonrpc":"2.0","id":2,"result":{}}`, w.Body.String())
+
+		w = post("sk-a", session, "application/json, text/event-stream", `[`+ping+`]`)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/event-stream")
+		assert.Contains(t, w.Body.String(), `data:[{"jsonrpc":"2.0","id":2,"result":{}}]`)
+	})
+
+	t.Run("Session Required", func(t *testing.T) {
+		assert.Equal(t, 400, post("sk-a", "", "application/json", ping).Code)
+		assert.Equal(t, 404, post("sk-a", "unknown", "application/json", ping).Code)
+		assert.Equal(t, 404, post("sk-b", session, "application/json", ping).Code, "sessions belong to their key")
+		assert.Equal(t, 401, post("sk-x", session, "application/json", ping).Code)
+	})
+
+	t.Run("Server Messages On GET Stream", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest("GET", "/mcp", nil).WithContext(ctx)
+		req.Header.Set("Authorization", "Bearer sk-a")
+		req.Header.Set("Accept", "text/event-stream")
+		req.Header.Set("Mcp-Session-Id", session)
+		w := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			r.ServeHTTP(w, req)
+			close(done)
+		}()
+
+		val, _ := sessions.Load(session)
+		val.(*Session).Caller.Deliver([]byte(`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`))
+		assert.Eventually(t, func() bool { return len(val.(*Session).MsgChan) == 0 }, time.Second, 10*time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		<-done
+		assert.Contains(t, w.Body.String(), `data:{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`)
+	})
+
+	t.Run("Idle Expiry", func(t *testing.T) {
+		val, _ := sessions.Load(session)
+		s := val.(*Session)
+		assert.False(t, s.idle(time.Minute))
+		end := s.begin()
+		s.lastActive.Store(time.Now().Add(-time.Hour).UnixNano())
+		assert.False(t, s.idle(time.Minute), "requests in progress keep the session")
+		end()
+		s.lastActive.Store(time.Now().Add(-time.Hour).UnixNano())
+		assert.True(t, s.idle(time.Minute))
+	})
+
+	const initialize = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{}}}`
+
+	t.Run("Expiry Timers", func(t *testing.T) {
+		defer func() { settings.SessionIdleTimeout, settings.SessionMaxLifetime = time.Minute, 0 }()
+
+		settings.SessionIdleTimeout = 40 * time.Millisecond
+		idle := post("sk-a", "", "application/json", initialize).Header().Get("Mcp-Session-Id")
+		assert.Eventually(t, func() bool {
+			_, live := sessions.Load(idle)
+			return !live
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, 404, post("sk-a", idle, "application/json", ping).Code)
+
+		settings.SessionIdleTimeout, settings.SessionMaxLifetime = time.Minute, 100*time.Millisecond
+		short := post("sk-a", "", "application/json", initialize).Header().Get("Mcp-Session-Id")
+		assert.Equal(t, 200, post("sk-a", short, "application/json", ping).Code)
+		assert.Eventually(t, func() bool { return post("sk-a", short, "application/json", ping).Code == 404 },
+			time.Second, 10*time.Millisecond, "ends despite activity")
+
+		w := post("sk-a", short, "application/json", ping)
+		assert.Contains(t, w.Body.String(), "send a new initialize request")
+		w = post("sk-a", short, "application/json", initialize)
+		assert.Equal(t, 200, w.Code, "initialize with a stale session ID starts a new session")
+		assert.NotEqual(t, short, w.Header().Get("Mcp-Session-Id"))
+	})
+
+	t.Run("Client Termination", func(t *testing.T) {
+		del := func(key, session string) int {
+			req := httptest.NewRequest("DELETE", "/mcp", nil)
+			req.Header.Set("Authorization", "Bearer "+key)
+			if session != "" {
+				req.Header.Set("Mcp-Session-Id", session)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Code
+		}
+		assert.Equal(t, 400, del("sk-a", ""))
+		assert.Equal(t, 404, del("sk-b", session), "sessions belong to their key")
+
+		val, _ := sessions.Load(session)
+		done := val.(*Session).done
+		assert.Equal(t, 204, del("sk-a", session))
+		<-done
+		assert.Equal(t, 404, post("sk-a", session, "application/json", ping).Code)
+		assert.Equal(t, 404, del("sk-a", session))
+	})
+}
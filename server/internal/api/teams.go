@@ -0,0 +1,139 @@
+package api
+
+import (
+	"fmt"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func (h *Handler) ListTeams(c *gin.Context) {
+	var teams []model.Team
+	h.db.Find(&teams)
+	c.JSON(200, teams)
+}
+
+func (h *Handler) CreateTeam(c *gin.Context) {
+	var team model.Team
+	if err := c.ShouldBindJSON(&team); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if team.Name == "" {
+		c.JSON(400, gin.H{"error": "Team name is required"})
+		return
+	}
+	if err := core.ValidateTimezone(team.Timezone); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.db.Create(&team).Error; err != nil {
+		c.JSON(400, gin.H{"error": "Team name already exists"})
+		return
+	}
+	h.recordChange(c, "create", "team", team.ID, team)
+	c.JSON(200, team)
+}
+
+func (h *Handler) UpdateTeam(c *gin.Context) {
+	id := c.Param("id")
+	var team model.Team
+	if err := h.db.First(&team, "id = ?", id).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+	teamID := team.ID
+	if err := c.ShouldBindJSON(&team); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	team.ID = teamID // The URL decides which team is updated, not the body
+	if err := core.ValidateTimezone(team.Timezone); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.db.Save(&team).Error; err != nil {
+		c.JSON(400, gin.H{"error": "Team name already exists"})
+		return
+	}
+	h.recordChange(c, "update", "team", team.ID, team)
+	c.JSON(200, team)
+}
+
+func (h *Handler) DeleteTeam(c *gin.Context) {
+	id := c.Param("id")
+	// Detach member keys so they fall back to their own permissions
+	h.db.Model(&model.ApiKey{}).Where("team_id = ?", id).Update("team_id", 0)
+	if h.db.Where("id = ?", id).Delete(&model.Team{}).RowsAffected > 0 {
+		h.recordChange(c, "delete", "team", id, nil)
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+// GetTeamUsage reports a team's tool calls over the last N days (?days=, default 30),
+// broken down by day, key and tool. Days are those of the team's timezone, at its
+// current UTC offset.
+func (h *Handler) GetTeamUsage(c *gin.Context) {
+	id := c.Param("id")
+	var team model.Team
+	if err := h.db.First(&team, "id = ?", id).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 {
+		c.JSON(400, gin.H{"error": "Invalid days"})
+		return
+	}
+	since := time.Now().AddDate(0, 0, -days)
+	base := h.reads().Model(&model.UsageLog{}).Where("team_id = ? AND created_at >= ?", team.ID, since)
+	loc := h.gateway.Location(team.Timezone)
+	_, offset := time.Now().In(loc).Zone()
+
+	type dayRow struct {
+		Day    string `json:"day"`
+		Calls  int64  `json:"calls"`
+		Errors int64  `json:"errors"`
+	}
+	var byDay []dayRow
+	base.Session(&gorm.Session{}).
+		Select("date(created_at, ?) AS day, COUNT(*) AS calls, SUM(CASE WHEN success THEN 0 ELSE 1 END) AS errors", fmt.Sprintf("%+d seconds", offset)).
+		Group("day").Order("day").Scan(&byDay)
+
+	type keyRow struct {
+		KeyID uint  `json:"key_id"`
+		Calls int64 `json:"calls"`
+	}
+	var byKey []keyRow
+	base.Session(&gorm.Session{}).
+		Select("key_id, COUNT(*) AS calls").
+		Group("key_id").Order("calls desc").Scan(&byKey)
+
+	type toolRow struct {
+		Tool  string `json:"tool"`
+		Calls int64  `json:"calls"`
+	}
+	var byTool []toolRow
+	base.Session(&gorm.Session{}).
+		Select("tool, COUNT(*) AS calls").
+		Group("tool").Order("calls desc").Scan(&byTool)
+
+	var total int64
+	base.Session(&gorm.Session{}).Count(&total)
+
+	c.JSON(200, gin.H{
+		"team_id":          team.ID,
+		"days":             days,
+		"timezone":         loc.String(),
+		"total_calls":      total,
+		"daily_call_quota": team.DailyCallQuota,
+		"by_day":           byDay,
+		"by_key":           byKey,
+		"by_tool":          byTool,
+	})
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestTeams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.Team{}, &model.ApiKey{}, &model.ConfigChange{})
+
+	h := &Handler{db: db, settings: &config.Config{}}
+	r := gin.New()
+	r.POST("/teams", h.CreateTeam)
+	r.PUT("/teams/:id", h.UpdateTeam)
+	r.DELETE("/teams/:id", h.DeleteTeam)
+	do := func(method string, path string, body string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w.Code
+	}
+
+	assert.Equal(t, 200, do("POST", "/teams", `{"name": "ops"}`))
+	assert.Equal(t, 200, do("POST", "/teams", `{"name": "dev"}`))
+
+	t.Run("the id in the body is ignored", func(t *testing.T) {
+		assert.Equal(t, 200, do("PUT", "/teams/1", `{"id": 2, "name": "ops", "daily_call_quota": 10}`))
+		var teams []model.Team
+		db.Order("id").Find(&teams)
+		assert.Equal(t, 10, teams[0].DailyCallQuota)
+		assert.Equal(t, "dev", teams[1].Name)
+		assert.Equal(t, 0, teams[1].DailyCallQuota)
+	})
+
+	t.Run("names are unique among live teams", func(t *testing.T) {
+		assert.Equal(t, 400, do("POST", "/teams", `{"name": "dev"}`))
+		assert.Equal(t, 400, do("PUT", "/teams/1", `{"name": "dev"}`))
+		assert.Equal(t, 200, do("DELETE", "/teams/2", ""))
+		assert.Equal(t, 200, do("POST", "/teams", `{"name": "dev"}`), "the name of a deleted team can be reused")
+	})
+}
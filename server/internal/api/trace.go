@@ -0,0 +1,59 @@
+package api
+
+import (
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetServerTrace enables full message tracing of an upstream for the given number of
+// minutes, or disables it with 0.
+func (h *Handler) SetServerTrace(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return
+	}
+	var req struct {
+		Minutes int `json:"minutes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	d := time.Duration(req.Minutes) * time.Minute
+	if d < 0 || d > core.MaxTraceDuration {
+		c.JSON(400, gin.H{"error": "minutes must be between 0 and 1440"})
+		return
+	}
+
+	path, err := h.gateway.SetTrace(uint(id), d)
+	if err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	result := gin.H{"status": "ok", "file": path}
+	if d > 0 {
+		result["trace_until"] = time.Now().Add(d)
+	}
+	c.JSON(200, result)
+}
+
+// GetServerTrace downloads the trace file of an upstream.
+func (h *Handler) GetServerTrace(c *gin.Context) {
+	var server model.UpstreamServer
+	if err := h.db.First(&server, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+	path := core.TracePath(h.settings.DataDir, server.Name)
+	if _, err := os.Stat(path); err != nil {
+		c.JSON(404, gin.H{"error": "No trace recorded for this server"})
+		return
+	}
+	c.FileAttachment(path, server.Name+".log")
+}
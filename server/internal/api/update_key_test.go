@@ -0,0 +1,136 @@
+package api
+
+import (
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestUpdateKeySigningSecret(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.ApiKey{}, &model.ConfigChange{}, &model.CatalogVersion{})
+	key := model.ApiKey{Key: "sk-test", SigningSecret: "s3cret"}
+	db.Create(&key)
+
+	h := &Handler{db: db, settings: &config.Config{}}
+	r := gin.New()
+	r.PUT("/keys/:id", h.UpdateKey)
+	put := func(body string) string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("PUT", "/keys/1", strings.NewReader(body)))
+		assert.Equal(t, 200, w.Code)
+		var stored model.ApiKey
+		db.First(&stored, key.ID)
+		return stored.SigningSecret
+	}
+
+	assert.Equal(t, "s3cret", put(`{"description": "renamed"}`), "an absent secret is kept")
+	assert.Equal(t, "rotated", put(`{"signing_secret": "rotated"}`))
+	assert.Equal(t, "", put(`{"signing_secret": ""}`), "an empty secret clears it")
+}
+
+func TestUpdateKeyReadOnlyTools(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.ApiKey{}, &model.ConfigChange{}, &model.CatalogVersion{})
+	key := model.ApiKey{Key: "sk-test", ReadOnlyTools: true}
+	db.Create(&key)
+
+	h := &Handler{db: db, settings: &config.Config{}}
+	r := gin.New()
+	r.PUT("/keys/:id", h.UpdateKey)
+	put := func(body string) bool {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("PUT", "/keys/1", strings.NewReader(body)))
+		assert.Equal(t, 200, w.Code)
+		var stored model.ApiKey
+		db.First(&stored, key.ID)
+		return stored.ReadOnlyTools
+	}
+
+	assert.True(t, put(`{"description": "x"}`), "an absent flag is kept")
+	assert.False(t, put(`{"read_only_tools": false}`))
+	assert.True(t, put(`{"read_only_tools": true}`))
+}
+
+func TestUpdateKeyExpiry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.ApiKey{}, &model.ConfigChange{}, &model.CatalogVersion{})
+	expired := time.Now().Add(-time.Hour)
+	key := model.ApiKey{Key: "sk-test", ExpiresAt: &expired}
+	db.Create(&key)
+
+	h := &Handler{db: db, settings: &config.Config{}}
+	r := gin.New()
+	r.PUT("/keys/:id", h.UpdateKey)
+	put := func(body string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("PUT", "/keys/1", strings.NewReader(body)))
+		return w.Code
+	}
+	stored := func() *time.Time {
+		var k model.ApiKey
+		db.First(&k, key.ID)
+		return k.ExpiresAt
+	}
+
+	assert.Equal(t, 200, put(`{"description": "renamed"}`))
+	if assert.NotNil(t, stored(), "an absent expiry is kept") {
+		assert.WithinDuration(t, expired, *stored(), time.Second)
+	}
+
+	assert.Equal(t, 400, put(`{"expires_at": "2000-01-01T00:00:00Z"}`), "a past expiry is rejected")
+	assert.WithinDuration(t, expired, *stored(), time.Second)
+
+	later := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	assert.Equal(t, 200, put(`{"expires_at": "`+later.Format(time.RFC3339)+`"}`))
+	assert.WithinDuration(t, later, *stored(), time.Second)
+
+	assert.Equal(t, 400, put(`{"expires_at": "tomorrow"}`))
+	assert.NotNil(t, stored())
+
+	assert.Equal(t, 200, put(`{"expires_at": null}`))
+	assert.Nil(t, stored(), "null clears the expiry")
+}
+
+func TestUpdateKeyOAuthBinding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.ApiKey{}, &model.ConfigChange{}, &model.CatalogVersion{})
+	key := model.ApiKey{Key: "sk-test", OAuthSubject: "client-1", OAuthScope: "mcp:team-a"}
+	db.Create(&key)
+
+	h := &Handler{db: db, settings: &config.Config{}}
+	r := gin.New()
+	r.PUT("/keys/:id", h.UpdateKey)
+	put := func(body string) model.ApiKey {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("PUT", "/keys/1", strings.NewReader(body)))
+		assert.Equal(t, 200, w.Code)
+		var stored model.ApiKey
+		db.First(&stored, key.ID)
+		return stored
+	}
+
+	stored := put(`{"description": "renamed"}`)
+	assert.Equal(t, "client-1", stored.OAuthSubject, "an absent binding is kept")
+	assert.Equal(t, "mcp:team-a", stored.OAuthScope)
+
+	stored = put(`{"oauth_scope": ""}`)
+	assert.Equal(t, "client-1", stored.OAuthSubject)
+	assert.Equal(t, "", stored.OAuthScope)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"fmt"
+	"one-mcp/internal/model"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// upstreamAuthCallback is where authorization servers redirect to after an
+// admin authorized an upstream.
+const upstreamAuthCallback = "/api/oauth/callback"
+
+// upstreamOAuthServer loads the server of an OAuth admin request.
+func (h *Handler) upstreamOAuthServer(c *gin.Context) (model.UpstreamServer, bool) {
+	var server model.UpstreamServer
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID"})
+		return server, false
+	}
+	if err := h.db.First(&server, id).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return server, false
+	}
+	return server, true
+}
+
+// AuthorizeUpstream starts the OAuth authorization code flow of an upstream
+// and returns the URL to open in a browser to authorize the gateway.
+func (h *Handler) AuthorizeUpstream(c *gin.Context) {
+	server, ok := h.upstreamOAuthServer(c)
+	if !ok {
+		return
+	}
+	if h.settings.PublicURL == "" {
+		c.JSON(400, gin.H{"error": "Set PUBLIC_URL for authorization servers to redirect back to the gateway"})
+		return
+	}
+	redirectURI := strings.TrimSuffix(h.settings.PublicURL, "/") + upstreamAuthCallback
+	authURL, err := h.gateway.UpstreamAuth().StartAuthorization(server, redirectURI)
+	if err != nil {
+		c.JSON(502, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"authorization_url": authURL, "redirect_uri": redirectURI})
+}
+
+// UpstreamAuthCallback completes the authorization of an upstream when the
+// authorization server redirects the admin's browser back. It is not behind
+// admin authentication: the state, known only to the flow started by an
+// admin, authenticates the redirect.
+func (h *Handler) UpstreamAuthCallback(c *gin.Context) {
+	if errCode := c.Query("error"); errCode != "" {
+		h.gateway.UpstreamAuth().CancelAuthorization(c.Query("state"))
+		c.JSON(400, gin.H{"error": fmt.Sprintf("Authorization denied: %s %s", errCode, c.Query("error_description"))})
+		return
+	}
+	if c.Query("state") == "" || c.Query("code") == "" {
+		c.JSON(400, gin.H{"error": "Missing state or code"})
+		return
+	}
+	server, err := h.gateway.UpstreamAuth().CompleteAuthorization(c.Query("state"), c.Query("code"))
+	if err != nil {
+		fmt.Printf("[UpstreamAuth] Authorization failed: %v\n", err)
+		c.JSON(400, gin.H{"error": "Authorization failed: " + err.Error()})
+		return
+	}
+	h.recordChange(c, "authorize", "server", server.ID, nil)
+	h.gateway.ReloadUpstreams()
+	c.JSON(200, gin.H{"status": "ok", "server": server.Name, "message": "Upstream authorized, you can close this window"})
+}
+
+// GetUpstreamAuth returns whether an upstream was authorized, and until when
+// its access token is valid. Tokens are never returned.
+func (h *Handler) GetUpstreamAuth(c *gin.Context) {
+	server, ok := h.upstreamOAuthServer(c)
+	if !ok {
+		return
+	}
+	token := h.gateway.UpstreamAuth().Token(server.ID)
+	if token == nil {
+		c.JSON(200, gin.H{"authorized": false})
+		return
+	}
+	c.JSON(200, gin.H{
+		"authorized":    true,
+		"authorized_at": token.CreatedAt,
+		"expires_at":    token.ExpiresAt,
+		"refreshable":   token.RefreshToken != "",
+		"scope":         token.Scope,
+		"client_id":     token.ClientID,
+	})
+}
+
+// RevokeUpstreamAuth deletes the OAuth tokens of an upstream, which uses its
+// auth_token again.
+func (h *Handler) RevokeUpstreamAuth(c *gin.Context) {
+	server, ok := h.upstreamOAuthServer(c)
+	if !ok {
+		return
+	}
+	h.gateway.UpstreamAuth().Forget(server.ID)
+	h.recordChange(c, "revoke", "server", server.ID, nil)
+	h.gateway.ReloadUpstreams()
+	c.JSON(200, gin.H{"status": "ok"})
+}
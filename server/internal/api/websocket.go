@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"one-mcp/internal/core"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/net/websocket"
+)
+
+// mcpSubprotocol is the WebSocket subprotocol of MCP, chosen when the client offers it.
+const mcpSubprotocol = "mcp"
+
+// HandleWebSocket serves an MCP session over a WebSocket, for clients behind
+// proxies that buffer SSE. Every text frame is one JSON-RPC message or batch in
+// either direction. Authentication, permissions and limits are those of SSE
+// sessions; the session ends with the connection.
+func (h *Handler) HandleWebSocket(c *gin.Context) {
+	apiKey, ok := h.authenticateKey(c)
+	if !ok {
+		return
+	}
+	release, err := h.gateway.Limits().AcquireSession(apiKey.ID)
+	if err != nil {
+		h.limited(c, err)
+		return
+	}
+	defer release()
+
+	server := websocket.Server{
+		// Unlike websocket.Handler, accept clients without an Origin header: the
+		// API key authenticates them, not the page they were loaded from
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			for _, protocol := range config.Protocol {
+				if protocol == mcpSubprotocol {
+					config.Protocol = []string{mcpSubprotocol}
+					return nil
+				}
+			}
+			config.Protocol = nil
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			if h.settings.MaxMessageSize > 0 {
+				ws.MaxPayloadBytes = h.settings.MaxMessageSize
+			}
+
+			caller := h.newCaller(apiKey)
+			sessionID := uuid.New().String()
+			session := &Session{
+				MsgChan: make(chan []byte, h.settings.SessionBufferSize),
+				done:    make(chan struct{}),
+				slots:   make(chan struct{}, h.settings.SessionConcurrency),
+				Caller:  caller,
+			}
+			caller.SessionID = sessionID
+			caller.Notify = session.Send
+			h.gateway.AddSession(caller)
+			fmt.Printf("[Session] Started WebSocket session %s for key %d\n", sessionID, apiKey.ID)
+			defer func() {
+				close(session.done)
+				h.gateway.DropSession(sessionID)
+				fmt.Printf("[Session] WebSocket session %s closed\n", sessionID)
+			}()
+
+			// The only writer of the connection
+			go func() {
+				for {
+					select {
+					case msg := <-session.MsgChan:
+						if websocket.Message.Send(ws, string(msg)) != nil {
+							ws.Close()
+							return
+						}
+					case <-session.done:
+						return
+					}
+				}
+			}()
+
+			for {
+				var frame []byte
+				if err := websocket.Message.Receive(ws, &frame); err != nil {
+					return
+				}
+				h.receiveFrame(sessionID, session, frame)
+			}
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// receiveFrame processes a message received over a WebSocket session. Like
+// messages posted to an SSE session, it is processed in the background and the
+// response delivered through the session, so the connection keeps being read
+// while calls run, e.g. for sampling responses.
+func (h *Handler) receiveFrame(sessionID string, session *Session, frame []byte) {
+	reject := func(id *json.RawMessage, code int, message string) {
+		resp, _ := json.Marshal(&core.JSONRPCMessage{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error:   &core.JSONRPCError{Code: code, Message: message},
+		})
+		session.Caller.Deliver(resp)
+	}
+	if !json.Valid(frame) {
+		null := json.RawMessage("null")
+		reject(&null, -32700, "Parse error")
+		return
+	}
+	if err := h.gateway.Limits().AllowMessage(); err != nil {
+		// Requests get an error; notifications are dropped, as they get no response
+		var probe struct {
+			ID *json.RawMessage `json:"id"`
+		}
+		json.Unmarshal(frame, &probe)
+		if probe.ID != nil {
+			reject(probe.ID, -32000, err.Error())
+		}
+		return
+	}
+
+	var batch []json.RawMessage
+	isBatch := json.Unmarshal(frame, &batch) == nil
+	process := func() { h.processMessage(sessionID, session, frame) }
+	if isBatch {
+		process = func() { h.processBatch(sessionID, session, batch) }
+	}
+
+	bypass := !isBatch && bypassesSlots(frame)
+	if isBatch && len(batch) > 0 {
+		bypass = true
+		for _, entry := range batch {
+			bypass = bypass && bypassesSlots(entry)
+		}
+	}
+	if bypass {
+		process()
+		return
+	}
+
+	go func() {
+		// Batch entries take a slot each as they run (see runBatch)
+		if !isBatch {
+			if !session.acquire() {
+				return
+			}
+			defer session.release()
+		}
+		process()
+	}()
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+	"gorm.io/gorm"
+)
+
+func TestWebSocketTransport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.ApiKey{}, &model.FeatureFlag{})
+	db.Create(&model.ApiKey{Key: "sk-ws"})
+
+	settings := &config.Config{SessionBufferSize: 8, SessionConcurrency: 2, MaxSessions: 1}
+	gateway := core.NewGateway(db, settings)
+	h := &Handler{db: db, gateway: gateway, settings: settings}
+	r := gin.New()
+	r.GET("/mcp/ws", h.HandleWebSocket)
+	server := httptest.NewServer(r)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/mcp/ws"
+
+	dial := func(key string, protocols ...string) (*websocket.Conn, error) {
+		config, _ := websocket.NewConfig(url, "http://localhost")
+		config.Header.Set("Authorization", "Bearer "+key)
+		config.Protocol = protocols
+		return websocket.DialConfig(config)
+	}
+	receive := func(ws *websocket.Conn) string {
+		ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var frame string
+		assert.NoError(t, websocket.Message.Receive(ws, &frame))
+		return frame
+	}
+
+	_, err = dial("sk-unknown")
+	assert.Error(t, err, "the handshake needs a valid key")
+
+	ws, err := dial("sk-ws", "mcp")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mcp"}, ws.Config().Protocol)
+
+	websocket.Message.Send(ws, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{}}}`)
+	assert.Contains(t, receive(ws), `"protocolVersion":"2025-06-18"`)
+	websocket.Message.Send(ws, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	websocket.Message.Send(ws, `[{"jsonrpc":"2.0","id":2,"method":"ping"},{"jsonrpc":"2.0","id":3,"method":"ping"}]`)
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","id":2,"result":{}},{"jsonrpc":"2.0","id":3,"result":{}}]`, receive(ws))
+	websocket.Message.Send(ws, `{"jsonrpc"`)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"Parse error"}}`, receive(ws))
+
+	// Gateway notifications reach the session, e.g. when tool names change
+	assert.Equal(t, 1, gateway.Limits().Stats().Sessions)
+	_, err = gateway.UpdateFlags(map[string]json.RawMessage{"prefix_style": json.RawMessage(`"dot"`)})
+	assert.NoError(t, err)
+	assert.Contains(t, receive(ws), "notifications/tools/list_changed")
+
+	_, err = dial("sk-ws")
+	assert.Error(t, err, "session limits apply")
+
+	ws.Close()
+	assert.Eventually(t, func() bool { return gateway.Limits().Stats().Sessions == 0 }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) ListWorkflows(c *gin.Context) {
+	var workflows []model.Workflow
+	h.db.Order("name").Find(&workflows)
+	c.JSON(200, workflows)
+}
+
+func (h *Handler) CreateWorkflow(c *gin.Context) {
+	var wf model.Workflow
+	if err := c.ShouldBindJSON(&wf); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validateWorkflow(&wf); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.db.Create(&wf).Error; err != nil {
+		c.JSON(400, gin.H{"error": "Workflow name already exists"})
+		return
+	}
+	h.recordChange(c, "create", "workflow", wf.ID, wf)
+	c.JSON(200, wf)
+}
+
+func (h *Handler) UpdateWorkflow(c *gin.Context) {
+	var wf model.Workflow
+	if err := h.db.First(&wf, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+	if err := c.ShouldBindJSON(&wf); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validateWorkflow(&wf); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.db.Save(&wf).Error; err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordChange(c, "update", "workflow", wf.ID, wf)
+	c.JSON(200, wf)
+}
+
+func (h *Handler) DeleteWorkflow(c *gin.Context) {
+	if h.db.Where("id = ?", c.Param("id")).Delete(&model.Workflow{}).RowsAffected > 0 {
+		h.recordChange(c, "delete", "workflow", c.Param("id"), nil)
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+func (h *Handler) validateWorkflow(wf *model.Workflow) error {
+	if !core.ValidWorkflowName(wf.Name) {
+		return fmt.Errorf("Invalid workflow name: use letters, digits, '_' and '-' (no '__')")
+	}
+	if wf.InputSchema != "" {
+		var schema map[string]interface{}
+		if err := json.Unmarshal([]byte(wf.InputSchema), &schema); err != nil {
+			return fmt.Errorf("Invalid input schema: must be a JSON object")
+		}
+	}
+	steps, err := core.ParseWorkflowSteps(wf.Steps)
+	if err != nil {
+		return err
+	}
+	if cycle := core.WorkflowCycle(h.db, wf.ID, wf.Name, steps); cycle != "" {
+		return fmt.Errorf("Workflow cycle: %s", cycle)
+	}
+	return nil
+}
+
+// ListWorkflowRuns returns the latest runs of a workflow, newest first, without
+// their traces. Filters: status (succeeded or failed), limit (default 50, max 500).
+func (h *Handler) ListWorkflowRuns(c *gin.Context) {
+	query := h.reads().Omit("trace").Where("workflow_id = ?", c.Param("id"))
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	runs := []model.WorkflowRun{}
+	query.Order("id desc").Limit(limit).Find(&runs)
+	c.JSON(200, runs)
+}
+
+// GetWorkflowRun returns a run with its execution trace.
+func (h *Handler) GetWorkflowRun(c *gin.Context) {
+	var run model.WorkflowRun
+	if err := h.reads().First(&run, "id = ? AND workflow_id = ?", c.Param("runId"), c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(200, run)
+}
@@ -0,0 +1,739 @@
+package config
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultJWTSecret is used when JWT_SECRET is not set. It is insecure and only
+// meant for local development.
+const DefaultJWTSecret = "one-mcp-secret-key-change-me"
+
+// Config holds every tunable of the gateway. Values are resolved in order of
+// increasing precedence: defaults, .env file, environment variables, command-line flags.
+type Config struct {
+	// Server
+	Port                int
+	DataDir             string
+	DBReplicaPath       string // Read-only replica of the database for stats and history (empty = primary)
+	DBEncryptionKey     string // Key encrypting upstream credentials and secrets in the database (empty = plaintext)
+	DBEncryptionKeyFile string // File holding DBEncryptionKey, e.g. mounted from a KMS or secret manager
+	WebDist             string
+	AllowedOrigins      []string
+	JWTSecret           string
+	DemoUpstream        bool   // Serve the built-in demo upstream
+	Timezone            string // IANA zone of day boundaries and wall-clock times (empty = server local time)
+	PublicURL           string // Base URL clients reach the gateway at, for links it hands out
+
+	// Upstream connections
+	UpstreamTimeout time.Duration // Max wait for an upstream JSON-RPC response
+	HTTPToolTimeout time.Duration // Timeout of HTTP-wrapped tool requests
+	HTTPToolRetries int           // Retries of HTTP-wrapped tool requests answered with 429 or 5xx
+	ReconnectDelay  time.Duration // Delay before reconnecting a failed upstream, doubled after each failed attempt
+	ReconnectMax    time.Duration // Cap on the reconnect delay
+	ReconnectTries  int           // Failed attempts in a row after which an upstream is given up (0 = never)
+	ReconnectProbe  time.Duration // Interval of recovery attempts of given up upstreams (0 = none)
+	StdioStopGrace  time.Duration // Grace period between SIGTERM and SIGKILL when stopping stdio processes
+	InitTimeout     time.Duration // Max time from upstream start to completed initialize
+	PingInterval    time.Duration // Interval of keepalive pings to ready upstreams (0 = disabled)
+	PingMisses      int           // Unanswered pings in a row after which the connection is restarted
+	AsyncTimeout    time.Duration // Max wait for the result of an asynchronous tool call
+	AsyncRetries    int           // Automatic retries of asynchronous calls that fail to reach the upstream
+	MaxMessageSize  int           // Max size of a single upstream message in bytes
+	EgressAllowlist []string      // Hosts, IPs and CIDRs upstream traffic may reach (empty = any)
+
+	// Downstream sessions
+	SessionBufferSize  int           // Buffered messages per SSE session
+	SessionConcurrency int           // Messages processed concurrently per session
+	SSEResumeWindow    time.Duration // How long an SSE session outlives a dropped stream (0 = ends at once)
+	SSEReplaySize      int           // Sent SSE events kept per session for replay after Last-Event-ID
+	SessionIdleTimeout time.Duration // Streamable HTTP sessions end after this long without activity
+	SessionMaxLifetime time.Duration // Streamable HTTP sessions end after this long in any case (0 = unlimited)
+	ToolsPageSize      int           // Tools per tools/list page (0 = all tools in one response)
+
+	// OAuth 2.1 access tokens on the MCP endpoint, besides static API keys
+	OAuthIssuer   string // Authorization server whose tokens are accepted (empty = disabled)
+	OAuthAudience string // Resource identifier tokens must be issued for (empty = PUBLIC_URL/mcp)
+	OAuthJWKSURL  string // Signing keys of the issuer (empty = discovered from its metadata)
+
+	// Fallbacks for MCP clients that cannot send an Authorization: Bearer header
+	KeyQueryParam bool // Accept the API key in the ?key= query parameter
+	KeyBasicAuth  bool // Accept the API key as the password of HTTP Basic auth
+
+	// Offloading of large binary tool result content
+	BlobOffloadSize int           // Base64 size above which image and audio content is served from a URL (0 = disabled)
+	BlobURLTTL      time.Duration // How long an offloaded blob can be fetched
+
+	// Gateway-wide protection limits (0 = unlimited)
+	MaxSessions       int // Concurrent SSE sessions
+	MaxSessionsPerKey int // Concurrent SSE sessions per API key
+	MaxInflightCalls  int // Upstream tool calls in flight
+	MessageRate       int // Incoming messages per second, token bucket refill rate
+	MessageBurst      int // Token bucket size (0 = one second of MessageRate)
+
+	// Composite tools (workflows)
+	WorkflowMaxDepth   int // Max nesting of workflows calling workflows
+	WorkflowMaxSteps   int // Max steps executed by one workflow call, nested steps included
+	WorkflowMaxPayload int // Max size in bytes of a step's arguments or result
+
+	// Key-value memory tools
+	KVEnabled      bool // Serve the memory__kv_* tools
+	KVMaxEntries   int  // Max stored values per API key
+	KVMaxValueSize int  // Max size in bytes of one stored value
+
+	// Fetch tool
+	FetchEnabled      bool          // Serve the fetch__fetch tool
+	FetchTimeout      time.Duration // Max duration of one fetch, redirects included
+	FetchMaxBytes     int           // Content beyond this size is cut off
+	FetchContentTypes []string      // Media types that may be fetched, e.g. "text/*"
+
+	// Content moderation
+	ModerationEndpoint string
+	ModerationKeywords []string
+	ModerationAction   string // "block" or "flag"
+
+	// Traffic mirroring to a staging gateway
+	MirrorURL       string // SSE endpoint of the staging gateway (empty = disabled)
+	MirrorKey       string // API key used on the staging gateway
+	MirrorPercent   int    // Share of incoming messages mirrored, 0-100
+	MirrorAnonymize bool   // Replace string argument values with stable pseudonyms
+
+	// Call history
+	RecordCalls         bool          // Store full request/response of tool calls
+	PersistUpstreamLogs bool          // Store log messages emitted by upstreams
+	CallRetention       time.Duration // Age after which call history is deleted (0 = keep)
+	RecordingRetention  time.Duration // Age after which recorded payloads are deleted (0 = keep)
+
+	// Database pruning
+	ModerationRetention time.Duration // Age after which moderation logs are deleted (0 = keep)
+	DeletedRetention    time.Duration // Age after which soft-deleted rows are purged (0 = keep)
+	PruneInterval       time.Duration // Interval of the background pruning job
+
+	// Billing
+	BillingCurrency string // Currency of tool prices, shown on invoices
+
+	// Error reporting
+	SentryDSN          string
+	ErrorReportWebhook string
+
+	// OpenTelemetry export of per-key usage
+	OTelEndpoint       string        // OTLP/HTTP collector base URL (empty = disabled)
+	OTelHeaders        []string      // "name=value" headers sent to the collector
+	OTelServiceName    string        // service.name resource attribute
+	OTelExportInterval time.Duration // Interval between exports
+
+	// Where values came from, for `config check`
+	EnvFile string
+}
+
+func defaults() *Config {
+	return &Config{
+		Port:                8080,
+		DataDir:             "data",
+		WebDist:             "../web/dist",
+		JWTSecret:           DefaultJWTSecret,
+		UpstreamTimeout:     30 * time.Second,
+		HTTPToolTimeout:     30 * time.Second,
+		HTTPToolRetries:     2,
+		ReconnectDelay:      5 * time.Second,
+		ReconnectMax:        5 * time.Minute,
+		ReconnectProbe:      10 * time.Minute,
+		StdioStopGrace:      5 * time.Second,
+		InitTimeout:         60 * time.Second,
+		PingMisses:          3,
+		AsyncTimeout:        30 * time.Minute,
+		AsyncRetries:        2,
+		MaxMessageSize:      10 * 1024 * 1024,
+		SessionBufferSize:   10,
+		SessionConcurrency:  4,
+		SSEResumeWindow:     time.Minute,
+		SSEReplaySize:       100,
+		SessionIdleTimeout:  30 * time.Minute,
+		BlobURLTTL:          10 * time.Minute,
+		WorkflowMaxDepth:    4,
+		BillingCurrency:     "USD",
+		WorkflowMaxSteps:    50,
+		WorkflowMaxPayload:  1024 * 1024,
+		KVMaxEntries:        1000,
+		KVMaxValueSize:      64 * 1024,
+		FetchTimeout:        15 * time.Second,
+		FetchMaxBytes:       1024 * 1024,
+		FetchContentTypes:   []string{"text/*", "application/json", "application/xml", "application/xhtml+xml"},
+		ModerationAction:    "block",
+		MirrorPercent:       10,
+		MirrorAnonymize:     true,
+		CallRetention:       30 * 24 * time.Hour,
+		RecordingRetention:  7 * 24 * time.Hour,
+		ModerationRetention: 90 * 24 * time.Hour,
+		DeletedRetention:    30 * 24 * time.Hour,
+		PruneInterval:       time.Hour,
+		OTelServiceName:     "one-mcp",
+		OTelExportInterval:  30 * time.Second,
+	}
+}
+
+// Default returns the configuration used when nothing is set, without reading
+// the environment, e.g. for tests.
+func Default() *Config {
+	return defaults()
+}
+
+// Load resolves the configuration from the optional .env file, the environment and
+// the given command-line arguments, then validates it.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("one-mcp", flag.ContinueOnError)
+	envFile := fs.String("env-file", "", "path to a .env file (default: .env if present)")
+	port := fs.Int("port", 0, "HTTP listen port")
+	dataDir := fs.String("data-dir", "", "directory holding the database")
+	webDist := fs.String("web-dist", "", "directory of the built dashboard")
+	demo := fs.Bool("demo", false, "enable the built-in demo upstream")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := defaults()
+
+	path := *envFile
+	if path == "" {
+		path = os.Getenv("ENV_FILE")
+	}
+	if path == "" {
+		if _, err := os.Stat(".env"); err == nil {
+			path = ".env"
+		}
+	}
+	env := environment{}
+	if path != "" {
+		var err error
+		if env, err = loadEnvFile(path); err != nil {
+			return nil, err
+		}
+		cfg.EnvFile = path
+	}
+
+	var errs []string
+	cfg.fromEnv(env, &errs)
+
+	// Explicit flags win over everything else
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Port = *port
+		case "data-dir":
+			cfg.DataDir = *dataDir
+		case "web-dist":
+			cfg.WebDist = *webDist
+		case "demo":
+			cfg.DemoUpstream = *demo
+		}
+	})
+
+	errs = append(errs, cfg.validate()...)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n  - %s", strings.Join(errs, "\n  - "))
+	}
+	return cfg, nil
+}
+
+func (c *Config) fromEnv(env environment, errs *[]string) {
+	envInt(env, "PORT", &c.Port, errs)
+	envString(env, "DATA_DIR", &c.DataDir)
+	envString(env, "DB_REPLICA_PATH", &c.DBReplicaPath)
+	envString(env, "DB_ENCRYPTION_KEY", &c.DBEncryptionKey)
+	envString(env, "DB_ENCRYPTION_KEY_FILE", &c.DBEncryptionKeyFile)
+	envString(env, "WEB_DIST", &c.WebDist)
+	envString(env, "JWT_SECRET", &c.JWTSecret)
+	envList(env, "ALLOWED_ORIGINS", &c.AllowedOrigins)
+	envList(env, "EGRESS_ALLOWLIST", &c.EgressAllowlist)
+	envString(env, "TIMEZONE", &c.Timezone)
+
+	envDuration(env, "UPSTREAM_TIMEOUT", &c.UpstreamTimeout, errs)
+	envDuration(env, "HTTP_TOOL_TIMEOUT", &c.HTTPToolTimeout, errs)
+	envDuration(env, "RECONNECT_DELAY", &c.ReconnectDelay, errs)
+	envDuration(env, "RECONNECT_MAX_DELAY", &c.ReconnectMax, errs)
+	envInt(env, "RECONNECT_MAX_RETRIES", &c.ReconnectTries, errs)
+	envDuration(env, "RECONNECT_PROBE_INTERVAL", &c.ReconnectProbe, errs)
+	envDuration(env, "STDIO_STOP_TIMEOUT", &c.StdioStopGrace, errs)
+	envDuration(env, "UPSTREAM_INIT_TIMEOUT", &c.InitTimeout, errs)
+	envDuration(env, "UPSTREAM_PING_INTERVAL", &c.PingInterval, errs)
+	envInt(env, "UPSTREAM_PING_MISSES", &c.PingMisses, errs)
+	envDuration(env, "ASYNC_TOOL_TIMEOUT", &c.AsyncTimeout, errs)
+	envInt(env, "ASYNC_TOOL_RETRIES", &c.AsyncRetries, errs)
+	envInt(env, "HTTP_TOOL_RETRIES", &c.HTTPToolRetries, errs)
+	envInt(env, "MAX_MESSAGE_SIZE", &c.MaxMessageSize, errs)
+
+	envInt(env, "SESSION_BUFFER_SIZE", &c.SessionBufferSize, errs)
+	envInt(env, "SESSION_CONCURRENCY", &c.SessionConcurrency, errs)
+	envDuration(env, "SSE_RESUME_WINDOW", &c.SSEResumeWindow, errs)
+	envInt(env, "SSE_REPLAY_SIZE", &c.SSEReplaySize, errs)
+	envDuration(env, "SESSION_IDLE_TIMEOUT", &c.SessionIdleTimeout, errs)
+	envDuration(env, "SESSION_MAX_LIFETIME", &c.SessionMaxLifetime, errs)
+	envInt(env, "TOOLS_PAGE_SIZE", &c.ToolsPageSize, errs)
+	envString(env, "PUBLIC_URL", &c.PublicURL)
+	envString(env, "OAUTH_ISSUER", &c.OAuthIssuer)
+	envString(env, "OAUTH_AUDIENCE", &c.OAuthAudience)
+	envString(env, "OAUTH_JWKS_URL", &c.OAuthJWKSURL)
+	envBool(env, "MCP_KEY_QUERY_PARAM", &c.KeyQueryParam, errs)
+	envBool(env, "MCP_KEY_BASIC_AUTH", &c.KeyBasicAuth, errs)
+	envInt(env, "BLOB_OFFLOAD_SIZE", &c.BlobOffloadSize, errs)
+	envDuration(env, "BLOB_URL_TTL", &c.BlobURLTTL, errs)
+
+	envInt(env, "MAX_SESSIONS", &c.MaxSessions, errs)
+	envInt(env, "MAX_SESSIONS_PER_KEY", &c.MaxSessionsPerKey, errs)
+	envInt(env, "MAX_INFLIGHT_CALLS", &c.MaxInflightCalls, errs)
+	envInt(env, "MESSAGE_RATE", &c.MessageRate, errs)
+	envInt(env, "MESSAGE_BURST", &c.MessageBurst, errs)
+
+	envInt(env, "WORKFLOW_MAX_DEPTH", &c.WorkflowMaxDepth, errs)
+	envInt(env, "WORKFLOW_MAX_STEPS", &c.WorkflowMaxSteps, errs)
+	envInt(env, "WORKFLOW_MAX_PAYLOAD", &c.WorkflowMaxPayload, errs)
+
+	envBool(env, "KV_ENABLED", &c.KVEnabled, errs)
+	envInt(env, "KV_MAX_ENTRIES", &c.KVMaxEntries, errs)
+	envInt(env, "KV_MAX_VALUE_SIZE", &c.KVMaxValueSize, errs)
+
+	envBool(env, "FETCH_ENABLED", &c.FetchEnabled, errs)
+	envDuration(env, "FETCH_TIMEOUT", &c.FetchTimeout, errs)
+	envInt(env, "FETCH_MAX_BYTES", &c.FetchMaxBytes, errs)
+	envList(env, "FETCH_CONTENT_TYPES", &c.FetchContentTypes)
+
+	envString(env, "MODERATION_ENDPOINT", &c.ModerationEndpoint)
+	envList(env, "MODERATION_KEYWORDS", &c.ModerationKeywords)
+	envString(env, "MODERATION_ACTION", &c.ModerationAction)
+
+	envString(env, "MIRROR_URL", &c.MirrorURL)
+	envString(env, "MIRROR_KEY", &c.MirrorKey)
+	envInt(env, "MIRROR_PERCENT", &c.MirrorPercent, errs)
+	envBool(env, "MIRROR_ANONYMIZE", &c.MirrorAnonymize, errs)
+
+	envBool(env, "RECORD_CALLS", &c.RecordCalls, errs)
+	envBool(env, "PERSIST_UPSTREAM_LOGS", &c.PersistUpstreamLogs, errs)
+	envBool(env, "DEMO_UPSTREAM", &c.DemoUpstream, errs)
+	envDuration(env, "CALL_RETENTION", &c.CallRetention, errs)
+	envDuration(env, "RECORDING_RETENTION", &c.RecordingRetention, errs)
+	envDuration(env, "MODERATION_RETENTION", &c.ModerationRetention, errs)
+	envDuration(env, "DELETED_RETENTION", &c.DeletedRetention, errs)
+	envDuration(env, "PRUNE_INTERVAL", &c.PruneInterval, errs)
+
+	envString(env, "SENTRY_DSN", &c.SentryDSN)
+	envString(env, "ERROR_REPORT_WEBHOOK", &c.ErrorReportWebhook)
+
+	envString(env, "OTEL_EXPORTER_OTLP_ENDPOINT", &c.OTelEndpoint)
+	envList(env, "OTEL_EXPORTER_OTLP_HEADERS", &c.OTelHeaders)
+	envString(env, "OTEL_SERVICE_NAME", &c.OTelServiceName)
+	envString(env, "BILLING_CURRENCY", &c.BillingCurrency)
+	envDuration(env, "OTEL_EXPORT_INTERVAL", &c.OTelExportInterval, errs)
+}
+
+func (c *Config) validate() []string {
+	var errs []string
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Sprintf("PORT: must be between 1 and 65535, got %d", c.Port))
+	}
+	if c.DataDir == "" {
+		errs = append(errs, "DATA_DIR: must not be empty")
+	}
+	if c.JWTSecret == "" {
+		errs = append(errs, "JWT_SECRET: must not be empty")
+	}
+	if c.DBEncryptionKey != "" && c.DBEncryptionKeyFile != "" {
+		errs = append(errs, "DB_ENCRYPTION_KEY_FILE: cannot be combined with DB_ENCRYPTION_KEY")
+	} else if _, err := c.EncryptionKey(); err != nil {
+		errs = append(errs, "DB_ENCRYPTION_KEY_FILE: "+err.Error())
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		errs = append(errs, fmt.Sprintf("TIMEZONE: unknown zone %q (e.g. Europe/Berlin, UTC)", c.Timezone))
+	}
+	if c.UpstreamTimeout <= 0 {
+		errs = append(errs, "UPSTREAM_TIMEOUT: must be positive")
+	}
+	if c.HTTPToolTimeout <= 0 {
+		errs = append(errs, "HTTP_TOOL_TIMEOUT: must be positive")
+	}
+	if c.ReconnectDelay <= 0 {
+		errs = append(errs, "RECONNECT_DELAY: must be positive")
+	}
+	if c.ReconnectMax < c.ReconnectDelay {
+		errs = append(errs, "RECONNECT_MAX_DELAY: must not be less than RECONNECT_DELAY")
+	}
+	if c.ReconnectTries < 0 {
+		errs = append(errs, "RECONNECT_MAX_RETRIES: must not be negative")
+	}
+	if c.ReconnectProbe < 0 {
+		errs = append(errs, "RECONNECT_PROBE_INTERVAL: must not be negative")
+	}
+	if c.StdioStopGrace < 0 {
+		errs = append(errs, "STDIO_STOP_TIMEOUT: must not be negative")
+	}
+	if c.InitTimeout <= 0 {
+		errs = append(errs, "UPSTREAM_INIT_TIMEOUT: must be positive")
+	}
+	if c.PingInterval < 0 {
+		errs = append(errs, "UPSTREAM_PING_INTERVAL: must not be negative")
+	}
+	if c.PingMisses < 1 {
+		errs = append(errs, "UPSTREAM_PING_MISSES: must be at least 1")
+	}
+	if c.AsyncTimeout <= 0 {
+		errs = append(errs, "ASYNC_TOOL_TIMEOUT: must be positive")
+	}
+	if c.AsyncRetries < 0 {
+		errs = append(errs, "ASYNC_TOOL_RETRIES: must not be negative")
+	}
+	if c.HTTPToolRetries < 0 {
+		errs = append(errs, "HTTP_TOOL_RETRIES: must not be negative")
+	}
+	for _, entry := range c.EgressAllowlist {
+		if strings.Contains(entry, "/") {
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				errs = append(errs, fmt.Sprintf("EGRESS_ALLOWLIST: invalid CIDR %q", entry))
+			}
+		} else if net.ParseIP(entry) == nil && strings.ContainsAny(strings.TrimPrefix(entry, "*."), "*:/ @") {
+			errs = append(errs, fmt.Sprintf("EGRESS_ALLOWLIST: expected a host, IP or CIDR, got %q", entry))
+		}
+	}
+	if c.MaxMessageSize < 64*1024 {
+		errs = append(errs, "MAX_MESSAGE_SIZE: must be at least 65536 bytes")
+	}
+	if c.SessionBufferSize < 1 {
+		errs = append(errs, "SESSION_BUFFER_SIZE: must be at least 1")
+	}
+	if c.SessionConcurrency < 1 {
+		errs = append(errs, "SESSION_CONCURRENCY: must be at least 1")
+	}
+	if c.SSEResumeWindow < 0 || c.SSEReplaySize < 0 {
+		errs = append(errs, "SSE_RESUME_WINDOW, SSE_REPLAY_SIZE: must not be negative")
+	}
+	if c.SessionIdleTimeout <= 0 {
+		errs = append(errs, "SESSION_IDLE_TIMEOUT: must be positive")
+	}
+	if c.SessionMaxLifetime < 0 {
+		errs = append(errs, "SESSION_MAX_LIFETIME: must not be negative")
+	}
+	if c.ToolsPageSize < 0 {
+		errs = append(errs, "TOOLS_PAGE_SIZE: must not be negative")
+	}
+	if c.PublicURL != "" {
+		if u, err := url.Parse(c.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Sprintf("PUBLIC_URL: must be an http or https URL, got %q", c.PublicURL))
+		}
+	}
+	if c.OAuthIssuer != "" {
+		if u, err := url.Parse(c.OAuthIssuer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Sprintf("OAUTH_ISSUER: must be an http or https URL, got %q", c.OAuthIssuer))
+		}
+		if c.PublicURL == "" {
+			errs = append(errs, "OAUTH_ISSUER: requires PUBLIC_URL to advertise the protected resource metadata")
+		}
+	}
+	if c.BlobOffloadSize < 0 {
+		errs = append(errs, "BLOB_OFFLOAD_SIZE: must not be negative")
+	} else if c.BlobOffloadSize > 0 && c.PublicURL == "" {
+		errs = append(errs, "BLOB_OFFLOAD_SIZE: requires PUBLIC_URL to build download links")
+	}
+	if c.BlobURLTTL <= 0 {
+		errs = append(errs, "BLOB_URL_TTL: must be positive")
+	}
+	if c.MaxSessions < 0 || c.MaxSessionsPerKey < 0 || c.MaxInflightCalls < 0 || c.MessageRate < 0 || c.MessageBurst < 0 {
+		errs = append(errs, "MAX_SESSIONS, MAX_SESSIONS_PER_KEY, MAX_INFLIGHT_CALLS, MESSAGE_RATE, MESSAGE_BURST: must not be negative")
+	}
+	if c.WorkflowMaxDepth < 1 || c.WorkflowMaxSteps < 1 || c.WorkflowMaxPayload < 1 {
+		errs = append(errs, "WORKFLOW_MAX_DEPTH, WORKFLOW_MAX_STEPS, WORKFLOW_MAX_PAYLOAD: must be at least 1")
+	}
+	if c.KVMaxEntries < 1 || c.KVMaxValueSize < 1 {
+		errs = append(errs, "KV_MAX_ENTRIES, KV_MAX_VALUE_SIZE: must be at least 1")
+	}
+	if c.FetchTimeout <= 0 {
+		errs = append(errs, "FETCH_TIMEOUT: must be positive")
+	}
+	if c.FetchMaxBytes < 1 {
+		errs = append(errs, "FETCH_MAX_BYTES: must be at least 1")
+	}
+	if c.CallRetention < 0 || c.RecordingRetention < 0 {
+		errs = append(errs, "CALL_RETENTION, RECORDING_RETENTION: must not be negative")
+	}
+	if c.ModerationRetention < 0 || c.DeletedRetention < 0 {
+		errs = append(errs, "MODERATION_RETENTION, DELETED_RETENTION: must not be negative")
+	}
+	if c.PruneInterval <= 0 {
+		errs = append(errs, "PRUNE_INTERVAL: must be positive")
+	}
+	if c.ModerationAction != "block" && c.ModerationAction != "flag" {
+		errs = append(errs, fmt.Sprintf("MODERATION_ACTION: must be \"block\" or \"flag\", got %q", c.ModerationAction))
+	}
+	if c.MirrorPercent < 0 || c.MirrorPercent > 100 {
+		errs = append(errs, "MIRROR_PERCENT: must be between 0 and 100")
+	}
+	for _, h := range c.OTelHeaders {
+		if !strings.Contains(h, "=") {
+			errs = append(errs, fmt.Sprintf("OTEL_EXPORTER_OTLP_HEADERS: expected name=value, got %q", h))
+		}
+	}
+	if c.BillingCurrency == "" {
+		errs = append(errs, "BILLING_CURRENCY: must not be empty")
+	}
+	if c.OTelExportInterval <= 0 {
+		errs = append(errs, "OTEL_EXPORT_INTERVAL: must be positive")
+	}
+	return errs
+}
+
+// OAuthResource returns the resource identifier OAuth access tokens must carry
+// as audience: OAUTH_AUDIENCE, or else the URL of the MCP endpoint.
+func (c *Config) OAuthResource() string {
+	if c.OAuthAudience != "" || c.PublicURL == "" {
+		return c.OAuthAudience
+	}
+	return strings.TrimSuffix(c.PublicURL, "/") + "/mcp"
+}
+
+// Location returns the gateway timezone, the server's local time unless TIMEZONE is set.
+func (c *Config) Location() *time.Location {
+	if c.Timezone == "" {
+		return time.Local
+	}
+	if loc, err := time.LoadLocation(c.Timezone); err == nil {
+		return loc
+	}
+	return time.Local
+}
+
+// Warnings returns non-fatal problems such as insecure defaults.
+func (c *Config) Warnings() []string {
+	var warnings []string
+	if c.JWTSecret == DefaultJWTSecret {
+		warnings = append(warnings, "JWT_SECRET not set, using default insecure key")
+	}
+	if len(c.AllowedOrigins) == 0 {
+		warnings = append(warnings, "ALLOWED_ORIGINS not set, allowing all origins (CORS)")
+	}
+	return warnings
+}
+
+// EncryptionKey returns the database encryption key, reading it from
+// DBEncryptionKeyFile if set. An empty key disables encryption.
+func (c *Config) EncryptionKey() (string, error) {
+	if c.DBEncryptionKeyFile == "" {
+		return c.DBEncryptionKey, nil
+	}
+	data, err := os.ReadFile(c.DBEncryptionKeyFile)
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("%s is empty", c.DBEncryptionKeyFile)
+	}
+	return key, nil
+}
+
+// Summary returns the effective settings for display, with secrets masked.
+func (c *Config) Summary() [][2]string {
+	secret := "(default)"
+	if c.JWTSecret != DefaultJWTSecret {
+		secret = "(set)"
+	}
+	envFile := c.EnvFile
+	if envFile == "" {
+		envFile = "(none)"
+	}
+	return [][2]string{
+		{"ENV_FILE", envFile},
+		{"PORT", strconv.Itoa(c.Port)},
+		{"DATA_DIR", c.DataDir},
+		{"DB_REPLICA_PATH", c.DBReplicaPath},
+		{"DB_ENCRYPTION_KEY", mask(c.DBEncryptionKey)},
+		{"DB_ENCRYPTION_KEY_FILE", c.DBEncryptionKeyFile},
+		{"WEB_DIST", c.WebDist},
+		{"ALLOWED_ORIGINS", strings.Join(c.AllowedOrigins, ",")},
+		{"JWT_SECRET", secret},
+		{"DEMO_UPSTREAM", strconv.FormatBool(c.DemoUpstream)},
+		{"TIMEZONE", c.Location().String()},
+		{"UPSTREAM_TIMEOUT", c.UpstreamTimeout.String()},
+		{"HTTP_TOOL_TIMEOUT", c.HTTPToolTimeout.String()},
+		{"HTTP_TOOL_RETRIES", strconv.Itoa(c.HTTPToolRetries)},
+		{"RECONNECT_DELAY", c.ReconnectDelay.String()},
+		{"RECONNECT_MAX_DELAY", c.ReconnectMax.String()},
+		{"RECONNECT_MAX_RETRIES", strconv.Itoa(c.ReconnectTries)},
+		{"RECONNECT_PROBE_INTERVAL", c.ReconnectProbe.String()},
+		{"STDIO_STOP_TIMEOUT", c.StdioStopGrace.String()},
+		{"UPSTREAM_INIT_TIMEOUT", c.InitTimeout.String()},
+		{"UPSTREAM_PING_INTERVAL", c.PingInterval.String()},
+		{"UPSTREAM_PING_MISSES", strconv.Itoa(c.PingMisses)},
+		{"ASYNC_TOOL_TIMEOUT", c.AsyncTimeout.String()},
+		{"ASYNC_TOOL_RETRIES", strconv.Itoa(c.AsyncRetries)},
+		{"MAX_MESSAGE_SIZE", strconv.Itoa(c.MaxMessageSize)},
+		{"EGRESS_ALLOWLIST", strings.Join(c.EgressAllowlist, ",")},
+		{"SESSION_BUFFER_SIZE", strconv.Itoa(c.SessionBufferSize)},
+		{"SESSION_CONCURRENCY", strconv.Itoa(c.SessionConcurrency)},
+		{"SSE_RESUME_WINDOW", c.SSEResumeWindow.String()},
+		{"SSE_REPLAY_SIZE", strconv.Itoa(c.SSEReplaySize)},
+		{"SESSION_IDLE_TIMEOUT", c.SessionIdleTimeout.String()},
+		{"SESSION_MAX_LIFETIME", c.SessionMaxLifetime.String()},
+		{"TOOLS_PAGE_SIZE", strconv.Itoa(c.ToolsPageSize)},
+		{"PUBLIC_URL", c.PublicURL},
+		{"OAUTH_ISSUER", c.OAuthIssuer},
+		{"OAUTH_AUDIENCE", c.OAuthResource()},
+		{"OAUTH_JWKS_URL", c.OAuthJWKSURL},
+		{"MCP_KEY_QUERY_PARAM", strconv.FormatBool(c.KeyQueryParam)},
+		{"MCP_KEY_BASIC_AUTH", strconv.FormatBool(c.KeyBasicAuth)},
+		{"BLOB_OFFLOAD_SIZE", strconv.Itoa(c.BlobOffloadSize)},
+		{"BLOB_URL_TTL", c.BlobURLTTL.String()},
+		{"MAX_SESSIONS", strconv.Itoa(c.MaxSessions)},
+		{"MAX_SESSIONS_PER_KEY", strconv.Itoa(c.MaxSessionsPerKey)},
+		{"MAX_INFLIGHT_CALLS", strconv.Itoa(c.MaxInflightCalls)},
+		{"MESSAGE_RATE", strconv.Itoa(c.MessageRate)},
+		{"MESSAGE_BURST", strconv.Itoa(c.MessageBurst)},
+		{"WORKFLOW_MAX_DEPTH", strconv.Itoa(c.WorkflowMaxDepth)},
+		{"WORKFLOW_MAX_STEPS", strconv.Itoa(c.WorkflowMaxSteps)},
+		{"WORKFLOW_MAX_PAYLOAD", strconv.Itoa(c.WorkflowMaxPayload)},
+		{"KV_ENABLED", strconv.FormatBool(c.KVEnabled)},
+		{"KV_MAX_ENTRIES", strconv.Itoa(c.KVMaxEntries)},
+		{"KV_MAX_VALUE_SIZE", strconv.Itoa(c.KVMaxValueSize)},
+		{"FETCH_ENABLED", strconv.FormatBool(c.FetchEnabled)},
+		{"FETCH_TIMEOUT", c.FetchTimeout.String()},
+		{"FETCH_MAX_BYTES", strconv.Itoa(c.FetchMaxBytes)},
+		{"FETCH_CONTENT_TYPES", strings.Join(c.FetchContentTypes, ",")},
+		{"MODERATION_ENDPOINT", c.ModerationEndpoint},
+		{"MODERATION_KEYWORDS", strings.Join(c.ModerationKeywords, ",")},
+		{"MODERATION_ACTION", c.ModerationAction},
+		{"MIRROR_URL", c.MirrorURL},
+		{"MIRROR_KEY", mask(c.MirrorKey)},
+		{"MIRROR_PERCENT", strconv.Itoa(c.MirrorPercent)},
+		{"MIRROR_ANONYMIZE", strconv.FormatBool(c.MirrorAnonymize)},
+		{"RECORD_CALLS", strconv.FormatBool(c.RecordCalls)},
+		{"PERSIST_UPSTREAM_LOGS", strconv.FormatBool(c.PersistUpstreamLogs)},
+		{"CALL_RETENTION", c.CallRetention.String()},
+		{"RECORDING_RETENTION", c.RecordingRetention.String()},
+		{"MODERATION_RETENTION", c.ModerationRetention.String()},
+		{"DELETED_RETENTION", c.DeletedRetention.String()},
+		{"PRUNE_INTERVAL", c.PruneInterval.String()},
+		{"BILLING_CURRENCY", c.BillingCurrency},
+		{"SENTRY_DSN", mask(c.SentryDSN)},
+		{"ERROR_REPORT_WEBHOOK", mask(c.ErrorReportWebhook)},
+		{"OTEL_EXPORTER_OTLP_ENDPOINT", c.OTelEndpoint},
+		{"OTEL_EXPORTER_OTLP_HEADERS", mask(strings.Join(c.OTelHeaders, ","))},
+		{"OTEL_SERVICE_NAME", c.OTelServiceName},
+		{"OTEL_EXPORT_INTERVAL", c.OTelExportInterval.String()},
+	}
+}
+
+func mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "(set)"
+}
+
+// environment holds the variables of the .env file. They are only read by
+// the configuration and never exported, so upstream processes, which inherit
+// the process environment, don't receive the gateway's secrets.
+type environment map[string]string
+
+// get returns a variable of the process environment, or else of the .env file.
+func (e environment) get(name string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
+	}
+	return e[name]
+}
+
+// loadEnvFile reads the variables of a KEY=VALUE file.
+func loadEnvFile(path string) (environment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open env file: %v", err)
+	}
+	defer f.Close()
+
+	env := environment{}
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		env[key] = value
+	}
+	return env, scanner.Err()
+}
+
+func envString(env environment, name string, dst *string) {
+	if v := env.get(name); v != "" {
+		*dst = v
+	}
+}
+
+func envList(env environment, name string, dst *[]string) {
+	v := env.get(name)
+	if v == "" {
+		return
+	}
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*dst = items
+}
+
+func envInt(env environment, name string, dst *int, errs *[]string) {
+	v := env.get(name)
+	if v == "" {
+		return
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		*errs = append(*errs, fmt.Sprintf("%s: invalid integer %q", name, v))
+		return
+	}
+	*dst = n
+}
+
+func envBool(env environment, name string, dst *bool, errs *[]string) {
+	v := env.get(name)
+	if v == "" {
+		return
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		*errs = append(*errs, fmt.Sprintf("%s: invalid boolean %q", name, v))
+		return
+	}
+	*dst = b
+}
+
+func envDuration(env environment, name string, dst *time.Duration, errs *[]string) {
+	v := env.get(name)
+	if v == "" {
+		return
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		*errs = append(*errs, fmt.Sprintf("%s: invalid duration %q (e.g. 30s, 2m)", name, v))
+		return
+	}
+	*dst = d
+}
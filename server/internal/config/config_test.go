@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unsetEnv clears variables for the test and restores them afterwards.
+func unsetEnv(t *testing.T, names ...string) {
+	for _, name := range names {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	unsetEnv(t, "ENV_FILE", "PORT", "JWT_SECRET", "UPSTREAM_TIMEOUT", "DATA_DIR")
+	envFile := filepath.Join(t.TempDir(), "one-mcp.env")
+	require.NoError(t, os.WriteFile(envFile, []byte(`# Settings of the test
+JWT_SECRET="from-file"
+PORT=9000
+export UPSTREAM_TIMEOUT='45s'
+
+DATA_DIR = /var/lib/one-mcp
+`), 0o600))
+
+	cfg, err := Load([]string{"--env-file", envFile})
+	require.NoError(t, err)
+	assert.Equal(t, envFile, cfg.EnvFile)
+	assert.Equal(t, "from-file", cfg.JWTSecret, "quotes are removed")
+	assert.Equal(t, 9000, cfg.Port)
+	assert.Equal(t, 45*time.Second, cfg.UpstreamTimeout, "export is allowed")
+	assert.Equal(t, "/var/lib/one-mcp", cfg.DataDir)
+	assert.Equal(t, 5*time.Second, cfg.ReconnectDelay, "unset values keep their default")
+	assert.NotContains(t, cfg.Warnings(), "JWT_SECRET not set, using default insecure key")
+	_, exported := os.LookupEnv("JWT_SECRET")
+	assert.False(t, exported, "the file is not exported to upstream processes")
+
+	// The environment wins over the file, flags over both
+	t.Setenv("PORT", "8000")
+	cfg, err = Load([]string{"--env-file", envFile})
+	require.NoError(t, err)
+	assert.Equal(t, 8000, cfg.Port)
+	cfg, err = Load([]string{"--env-file", envFile, "--port", "7000"})
+	require.NoError(t, err)
+	assert.Equal(t, 7000, cfg.Port)
+}
+
+func TestLoadErrors(t *testing.T) {
+	unsetEnv(t, "ENV_FILE", "PORT", "UPSTREAM_TIMEOUT", "TIMEZONE")
+
+	t.Setenv("PORT", "eighty")
+	t.Setenv("UPSTREAM_TIMEOUT", "soon")
+	t.Setenv("TIMEZONE", "Mars/Olympus")
+	_, err := Load(nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `PORT: invalid integer "eighty"`)
+	assert.Contains(t, err.Error(), `UPSTREAM_TIMEOUT: invalid duration "soon"`)
+	assert.Contains(t, err.Error(), `TIMEZONE: unknown zone "Mars/Olympus"`, "all errors are reported at once")
+
+	t.Setenv("PORT", "70000")
+	t.Setenv("UPSTREAM_TIMEOUT", "0s")
+	t.Setenv("TIMEZONE", "UTC")
+	_, err = Load(nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "PORT: must be between 1 and 65535")
+	assert.Contains(t, err.Error(), "UPSTREAM_TIMEOUT: must be positive")
+
+	envFile := filepath.Join(t.TempDir(), "broken.env")
+	require.NoError(t, os.WriteFile(envFile, []byte("PORT=8080\nJUST_A_WORD\n"), 0o600))
+	_, err = Load([]string{"--env-file", envFile})
+	assert.ErrorContains(t, err, envFile+":2: expected KEY=VALUE")
+
+	_, err = Load([]string{"--env-file", filepath.Join(t.TempDir(), "missing.env")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// listTransport answers tools/list after a delay, or with an error.
+type listTransport struct {
+	client *UpstreamClient
+	delay  time.Duration
+	tools  []map[string]interface{}
+	fail   bool
+	calls  atomic.Int32 // Requests received
+}
+
+func (t *listTransport) Start(ctx context.Context, onMessage func([]byte), onReady func()) error {
+	return nil
+}
+
+func (t *listTransport) Send(payload []byte) error {
+	var req JSONRPCMessage
+	json.Unmarshal(payload, &req)
+	t.calls.Add(1)
+	go func() {
+		time.Sleep(t.delay)
+		resp := JSONRPCMessage{JSONRPC: "2.0", ID: req.ID}
+		if t.fail {
+			resp.Error = &JSONRPCError{Code: -32603, Message: "boom"}
+		} else {
+			resp.Result, _ = json.Marshal(map[string]interface{}{"tools": t.tools})
+		}
+		msg, _ := json.Marshal(resp)
+		t.client.handleMessage(msg)
+	}()
+	return nil
+}
+
+func (t *listTransport) Close() error { return nil }
+
+func TestAggregateToolsStreaming(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&model.MaintenanceWindow{}, &model.Workflow{}, &model.ToolRoute{}))
+	db.Create(&model.Workflow{Name: "wf", Steps: `[{"id":"a","tool":"fast__a"}]`, Enabled: true})
+
+	settings := &config.Config{UpstreamTimeout: time.Second}
+	g := NewGateway(db, settings)
+	selector, _ := ParseToolSelector("", "", 0)
+	upstream := func(id uint, name string, transport *listTransport) *UpstreamClient {
+		client := &UpstreamClient{
+			selector:    selector,
+			Config:      model.UpstreamServer{ID: id, Name: name},
+			settings:    settings,
+			transport:   transport,
+			pendingReqs: make(map[string]chan JSONRPCMessage),
+			ready:       true,
+		}
+		transport.client = client
+		g.upstreams[id] = client
+		return client
+	}
+	fast := upstream(1, "fast", &listTransport{tools: []map[string]interface{}{{"name": "a"}}})
+	upstream(2, "slow", &listTransport{delay: 200 * time.Millisecond, tools: []map[string]interface{}{{"name": "b"}}})
+	broken := upstream(3, "broken", &listTransport{fail: true})
+
+	var events []ServerTools
+	var slowArrived time.Time
+	var fastArrived time.Time
+	started := time.Now()
+	tools, listed := g.StreamAllTools(func(listed ServerTools) {
+		events = append(events, listed)
+		switch listed.Server {
+		case "fast":
+			fastArrived = time.Now()
+		case "slow":
+			slowArrived = time.Now()
+		}
+	})
+
+	assert.Len(t, tools, 3)
+	assert.Equal(t, map[string]bool{"fast": true, "slow": true}, listed, "failed lists are not reported as listed")
+	assert.Len(t, events, 4)
+	assert.Less(t, fastArrived.Sub(started), 150*time.Millisecond, "fast upstream is reported without waiting for the slow one")
+	assert.True(t, fastArrived.Before(slowArrived))
+	assert.Equal(t, WorkflowServer, events[3].Server, "gateway tools come last")
+	for _, listed := range events {
+		switch listed.Server {
+		case "slow":
+			assert.GreaterOrEqual(t, listed.DurationMs, int64(200))
+			assert.Equal(t, "slow__b", listed.Tools[0]["name"])
+		case "broken":
+			assert.Contains(t, listed.Error, "boom")
+			assert.Empty(t, listed.Tools)
+		}
+	}
+
+	assert.Less(t, fast.Status().ListDurationMs, int64(150))
+	assert.Empty(t, fast.Status().ListError)
+	assert.Contains(t, broken.Status().ListError, "boom")
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"encoding/json"
+)
+
+// ToolAnnotations are the behavior hints of an MCP tool. They are declared by the
+// upstream and not verified, so they only restrict keys that opt in to them.
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    *bool  `json:"readOnlyHint,omitempty"`
+	DestructiveHint *bool  `json:"destructiveHint,omitempty"`
+	IdempotentHint  *bool  `json:"idempotentHint,omitempty"`
+	OpenWorldHint   *bool  `json:"openWorldHint,omitempty"`
+}
+
+// ReadOnly reports whether the tool declares that it does not modify its environment.
+func (a ToolAnnotations) ReadOnly() bool {
+	return a.ReadOnlyHint != nil && *a.ReadOnlyHint
+}
+
+// Destructive reports whether the tool may perform destructive updates. Per the
+// specification this is assumed unless declared otherwise, and meaningless for
+// read-only tools.
+func (a ToolAnnotations) Destructive() bool {
+	if a.ReadOnly() {
+		return false
+	}
+	return a.DestructiveHint == nil || *a.DestructiveHint
+}
+
+// parseAnnotations reads the annotations of a tool from a tools/list entry.
+// Malformed annotations count as none.
+func parseAnnotations(tool map[string]interface{}) ToolAnnotations {
+	var annotations ToolAnnotations
+	if raw, ok := tool["annotations"]; ok {
+		if data, err := json.Marshal(raw); err == nil {
+			json.Unmarshal(data, &annotations)
+		}
+	}
+	return annotations
+}
+
+// ToolAnnotations returns the annotations of a tool of the upstream, listing its
+// tools first if the tool has not been seen yet.
+func (c *UpstreamClient) ToolAnnotations(name string) (ToolAnnotations, bool) {
+	c.mu.RLock()
+	annotations, ok := c.annotations[name]
+	c.mu.RUnlock()
+	if ok {
+		return annotations, true
+	}
+
+	tools, err := c.listTools()
+	if err != nil {
+		return ToolAnnotations{}, false
+	}
+	c.selectTools(tools)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	annotations, ok = c.annotations[name]
+	return annotations, ok
+}
+
+// filterReadOnlyTools keeps the tools of a tools/list result that are annotated
+// read-only. Gateway tools (workflows, routes) carry no annotations and are dropped.
+func filterReadOnlyTools(resp *JSONRPCMessage) {
+	if resp == nil || resp.Error != nil {
+		return
+	}
+	var result struct {
+		Tools []map[string]interface{} `json:"tools"`
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return
+	}
+	tools := make([]map[string]interface{}, 0, len(result.Tools))
+	for _, tool := range result.Tools {
+		if parseAnnotations(tool).ReadOnly() {
+			tools = append(tools, tool)
+		}
+	}
+	resp.Result, _ = json.Marshal(map[string]interface{}{"tools": tools})
+}
+
+// WithAnnotationHints returns copies of tools/list entries with the effective
+// read_only and destructive hints added for the admin UI. Tools without
+// annotations get the specification defaults: not read-only, destructive.
+func WithAnnotationHints(tools []map[string]interface{}) []map[string]interface{} {
+	annotated := make([]map[string]interface{}, 0, len(tools))
+	for _, tool := range tools {
+		copied := make(map[string]interface{}, len(tool)+2)
+		for k, v := range tool {
+			copied[k] = v
+		}
+		annotations := parseAnnotations(tool)
+		copied["read_only"] = annotations.ReadOnly()
+		copied["destructive"] = annotations.Destructive()
+		annotated = append(annotated, copied)
+	}
+	return annotated
+}
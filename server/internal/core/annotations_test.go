@@ -0,0 +1,89 @@
+package core
+
+import (
+	"encoding/json"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestToolAnnotations(t *testing.T) {
+	readOnly := parseAnnotations(map[string]interface{}{"annotations": map[string]interface{}{"readOnlyHint": true, "destructiveHint": true}})
+	assert.True(t, readOnly.ReadOnly())
+	assert.False(t, readOnly.Destructive())
+
+	additive := parseAnnotations(map[string]interface{}{"annotations": map[string]interface{}{"destructiveHint": false}})
+	assert.False(t, additive.ReadOnly())
+	assert.False(t, additive.Destructive())
+
+	none := parseAnnotations(map[string]interface{}{"annotations": "bogus"})
+	assert.False(t, none.ReadOnly())
+	assert.True(t, none.Destructive())
+
+	tools := []map[string]interface{}{
+		{"name": "fs__read", "annotations": map[string]interface{}{"readOnlyHint": true}},
+		{"name": "fs__delete"},
+	}
+	hinted := WithAnnotationHints(tools)
+	assert.Equal(t, true, hinted[0]["read_only"])
+	assert.Equal(t, false, hinted[0]["destructive"])
+	assert.Equal(t, true, hinted[1]["destructive"])
+	assert.NotContains(t, tools[0], "read_only")
+
+	result, _ := json.Marshal(map[string]interface{}{"tools": tools})
+	resp := &JSONRPCMessage{JSONRPC: "2.0", Result: result}
+	filterReadOnlyTools(resp)
+	assert.JSONEq(t, `{"tools":[{"name":"fs__read","annotations":{"readOnlyHint":true}}]}`, string(resp.Result))
+}
+
+func TestReadOnlyKey(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&model.UpstreamServer{}, &model.MaintenanceWindow{}, &model.UsageLog{},
+		&model.Workflow{}, &model.WorkflowRun{}, &model.ToolRoute{}))
+	db.Create(&model.Workflow{Name: "wf", Steps: `[{"id":"a","tool":"demo__time"}]`, Enabled: true})
+
+	settings := &config.Config{DemoUpstream: true, UpstreamTimeout: time.Second, InitTimeout: time.Second,
+		ReconnectDelay: time.Second, WorkflowMaxDepth: 2, WorkflowMaxSteps: 5, WorkflowMaxPayload: 1024}
+	g := NewGateway(db, settings)
+	g.ReloadUpstreams()
+	defer g.upstreams[0].Stop()
+	assert.Eventually(t, func() bool { return g.upstreams[0].Status().State == StateReady }, time.Second, 10*time.Millisecond)
+
+	caller := &Caller{ReadOnlyTools: true}
+	handle := func(method string, params interface{}) *JSONRPCMessage {
+		id := json.RawMessage("1")
+		raw, _ := json.Marshal(params)
+		msg, _ := json.Marshal(&JSONRPCMessage{JSONRPC: "2.0", ID: &id, Method: method, Params: raw})
+		resp, err := g.HandleMessage(msg, caller)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	var list struct {
+		Tools []map[string]interface{} `json:"tools"`
+	}
+	json.Unmarshal(handle("tools/list", map[string]interface{}{}).Result, &list)
+	assert.Len(t, list.Tools, 4, "demo tools are read-only, the workflow is dropped")
+	for _, tool := range list.Tools {
+		assert.NotEqual(t, "workflow__wf", tool["name"])
+	}
+
+	resp := handle("tools/call", map[string]interface{}{"name": "demo__echo", "arguments": map[string]interface{}{"text": "hi"}})
+	assert.Nil(t, resp.Error)
+
+	resp = handle("tools/call", map[string]interface{}{"name": "workflow__wf"})
+	assert.Contains(t, resp.Error.Message, "read-only tools")
+
+	// A tool whose upstream drops the hint is refused
+	g.upstreams[0].mu.Lock()
+	g.upstreams[0].annotations["echo"] = ToolAnnotations{}
+	g.upstreams[0].mu.Unlock()
+	resp = handle("tools/call", map[string]interface{}{"name": "demo__echo", "arguments": map[string]interface{}{"text": "hi"}})
+	assert.Contains(t, resp.Error.Message, "read-only tools")
+}
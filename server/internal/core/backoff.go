@@ -0,0 +1,46 @@
+package core
+
+import (
+	"math/rand"
+	"time"
+)
+
+// backoffDelay returns the delay before the next connection attempt after
+// failures failed attempts in a row: RECONNECT_DELAY, doubled after each
+// failure up to RECONNECT_MAX_DELAY, of which a random half is taken off so that
+// upstreams failing together do not reconnect together.
+func backoffDelay(base, max time.Duration, failures int) time.Duration {
+	delay := base
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max && max >= base {
+		delay = max
+	}
+	half := delay / 2
+	return delay - half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// givenUp reports whether the upstream failed more attempts in a row than
+// RECONNECT_MAX_RETRIES allows. Only recovery attempts are made then, every
+// RECONNECT_PROBE_INTERVAL, until one succeeds or the server is saved again.
+func (c *UpstreamClient) givenUp(failures int) bool {
+	return c.settings.ReconnectTries > 0 && failures > c.settings.ReconnectTries
+}
+
+// waitRetry waits d before the next connection attempt, reporting false if the
+// client was stopped meanwhile.
+func (c *UpstreamClient) waitRetry(d time.Duration) bool {
+	c.mu.Lock()
+	c.nextRetry = time.Now().Add(d)
+	c.mu.Unlock()
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-c.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	for failures, full := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 8 * time.Second,
+		8: 10 * time.Second,
+	} {
+		for i := 0; i < 20; i++ {
+			delay := backoffDelay(time.Second, 10*time.Second, failures)
+			assert.GreaterOrEqual(t, delay, full/2, failures)
+			assert.LessOrEqual(t, delay, full, failures)
+		}
+	}
+	assert.LessOrEqual(t, backoffDelay(time.Second, 0, 5), time.Second, "no growth without a cap")
+}
+
+// flakyTransport fails its first attempts, then serves the demo tools.
+type flakyTransport struct {
+	*DemoTransport
+	failures int32
+}
+
+func (t *flakyTransport) Start(ctx context.Context, onMessage func([]byte), onReady func()) error {
+	if atomic.AddInt32(&t.failures, -1) >= 0 {
+		return fmt.Errorf("connection refused")
+	}
+	return t.DemoTransport.Start(ctx, onMessage, onReady)
+}
+
+func TestReconnectPolicy(t *testing.T) {
+	connect := func(failures int32, settings *config.Config) *UpstreamClient {
+		client := NewUpstreamClient(model.UpstreamServer{Name: "flaky", TransportType: "demo"}, settings, nil)
+		client.transport = &flakyTransport{DemoTransport: NewDemoTransport(), failures: failures}
+		client.Start()
+		t.Cleanup(client.Stop)
+		return client
+	}
+	settings := func(probe time.Duration) *config.Config {
+		return &config.Config{
+			UpstreamTimeout: time.Second, InitTimeout: time.Second,
+			ReconnectDelay: time.Millisecond, ReconnectMax: 4 * time.Millisecond,
+			ReconnectTries: 2, ReconnectProbe: probe,
+		}
+	}
+
+	t.Run("Retries Until Connected", func(t *testing.T) {
+		client := connect(2, settings(0))
+		assert.Eventually(t, func() bool { return client.Status().State == StateReady }, 2*time.Second, 5*time.Millisecond)
+		status := client.Status()
+		assert.Equal(t, 0, status.Failures)
+		assert.Equal(t, int64(2), status.Saturation.Reconnects)
+	})
+
+	t.Run("Gives Up After Max Retries", func(t *testing.T) {
+		client := connect(100, settings(0))
+		assert.Eventually(t, func() bool { return client.Status().GaveUp }, 2*time.Second, 5*time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		status := client.Status()
+		assert.Equal(t, StateFailed, status.State)
+		assert.Equal(t, "connection refused", status.LastError)
+		assert.Equal(t, 3, status.Failures, "no attempts after giving up")
+		assert.Nil(t, status.NextRetryAt)
+	})
+
+	t.Run("Recovers On A Probe", func(t *testing.T) {
+		client := connect(4, settings(20*time.Millisecond))
+		assert.Eventually(t, func() bool { return client.Status().GaveUp }, 2*time.Second, time.Millisecond)
+		assert.Eventually(t, func() bool { return client.Status().State == StateReady }, 2*time.Second, 5*time.Millisecond)
+		status := client.Status()
+		assert.False(t, status.GaveUp)
+		assert.Equal(t, 0, status.Failures)
+	})
+}
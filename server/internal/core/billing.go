@@ -0,0 +1,65 @@
+package core
+
+import (
+	"fmt"
+	"one-mcp/internal/model"
+	"strings"
+	"time"
+)
+
+// ValidateToolPrice checks a tool price before it is stored.
+func ValidateToolPrice(price *model.ToolPrice) error {
+	price.Tool = strings.TrimSpace(price.Tool)
+	if price.Tool == "" {
+		return fmt.Errorf("tool must not be empty")
+	}
+	if price.UnitPriceMicros < 0 {
+		return fmt.Errorf("unit_price_micros must not be negative")
+	}
+	return nil
+}
+
+// ReloadPrices refreshes the cached tool prices from the database.
+func (g *Gateway) ReloadPrices() {
+	var prices []model.ToolPrice
+	if err := g.db.Find(&prices).Error; err != nil {
+		fmt.Printf("[Billing] Failed to load prices: %v\n", err)
+		return
+	}
+
+	g.pricesMu.Lock()
+	g.prices = prices
+	g.pricesMu.Unlock()
+}
+
+// priceOf returns the price in micros of one call of a prefixed tool: that of
+// its exact name, or else of the longest matching pattern, 0 if none matches.
+func (g *Gateway) priceOf(tool string) int64 {
+	g.pricesMu.RLock()
+	defer g.pricesMu.RUnlock()
+
+	prices := g.prices
+	var best *model.ToolPrice
+	for i, p := range prices {
+		if p.Tool == tool {
+			return p.UnitPriceMicros
+		}
+		if strings.Contains(p.Tool, "*") && wildcardMatch(p.Tool, tool) && (best == nil || len(p.Tool) > len(best.Tool)) {
+			best = &prices[i]
+		}
+	}
+	if best == nil {
+		return 0
+	}
+	return best.UnitPriceMicros
+}
+
+// MonthRange returns the start of month ("2006-01") in loc and of the month
+// after, ready to be compared with stored timestamps.
+func MonthRange(month string, loc *time.Location) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01", month, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("month must be in the form YYYY-MM")
+	}
+	return storedTime(start), storedTime(start.AddDate(0, 1, 0)), nil
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"encoding/json"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestToolPrices(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&model.ToolPrice{}, &model.UsageLog{}))
+	g := NewGateway(db, &config.Config{})
+	db.Create(&model.ToolPrice{Tool: "github__*", UnitPriceMicros: 1000})
+	db.Create(&model.ToolPrice{Tool: "github__search_*", UnitPriceMicros: 5000})
+	db.Create(&model.ToolPrice{Tool: "github__search_code", UnitPriceMicros: 20000})
+	assert.Equal(t, int64(0), g.priceOf("github__get_issue"), "prices are cached")
+	g.ReloadPrices()
+
+	assert.Equal(t, int64(20000), g.priceOf("github__search_code"), "exact name")
+	assert.Equal(t, int64(5000), g.priceOf("github__search_issues"), "longest pattern")
+	assert.Equal(t, int64(1000), g.priceOf("github__get_issue"))
+	assert.Equal(t, int64(0), g.priceOf("slack__post"), "unpriced")
+
+	caller := &Caller{KeyID: 1, TeamID: 2}
+	ok := &JSONRPCMessage{Result: json.RawMessage(`{"content":[]}`)}
+	failed := &JSONRPCMessage{Result: json.RawMessage(`{"content":[],"isError":true}`)}
+	g.recordUsage(caller, "github", "github__get_issue", nil, ok, nil, time.Now())
+	g.recordUsage(caller, "github", "github__get_issue", nil, failed, nil, time.Now())
+
+	var logs []model.UsageLog
+	db.Order("id").Find(&logs)
+	assert.Equal(t, int64(1000), logs[0].CostMicros)
+	assert.Equal(t, int64(0), logs[1].CostMicros, "failed calls are not billed")
+
+	// Later price changes do not touch recorded costs
+	db.Model(&model.ToolPrice{}).Where("tool = ?", "github__*").Update("unit_price_micros", 3000)
+	g.ReloadPrices()
+	assert.Equal(t, int64(3000), g.priceOf("github__get_issue"))
+	db.First(&logs[0], logs[0].ID)
+	assert.Equal(t, int64(1000), logs[0].CostMicros)
+
+	assert.Error(t, ValidateToolPrice(&model.ToolPrice{Tool: " "}))
+	assert.Error(t, ValidateToolPrice(&model.ToolPrice{Tool: "a", UnitPriceMicros: -1}))
+}
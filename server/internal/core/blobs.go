@@ -0,0 +1,136 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Blob is binary content offloaded from a tool result, served once at its URL.
+type Blob struct {
+	MimeType string
+	Data     []byte
+	KeyID    uint // Key whose call returned the content
+}
+
+// BlobStore holds offloaded content in memory until it is fetched or expires.
+// Tokens are random and single-use, so the URL itself authorizes the download.
+type BlobStore struct {
+	mu    sync.Mutex
+	blobs map[string]*storedBlob
+}
+
+type storedBlob struct {
+	blob   *Blob
+	expiry *time.Timer
+}
+
+func NewBlobStore() *BlobStore {
+	return &BlobStore{blobs: make(map[string]*storedBlob)}
+}
+
+// Put stores blob for ttl and returns its token.
+func (s *BlobStore) Put(blob *Blob, ttl time.Duration) string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[token] = &storedBlob{
+		blob: blob,
+		expiry: time.AfterFunc(ttl, func() {
+			s.mu.Lock()
+			delete(s.blobs, token)
+			s.mu.Unlock()
+		}),
+	}
+	return token
+}
+
+// Take returns the blob with the token and removes it, so every URL works once.
+func (s *BlobStore) Take(token string) (*Blob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.blobs[token]
+	if !ok {
+		return nil, false
+	}
+	stored.expiry.Stop()
+	delete(s.blobs, token)
+	return stored.blob, true
+}
+
+// Blobs returns the store of content offloaded from tool results.
+func (g *Gateway) Blobs() *BlobStore {
+	return g.blobs
+}
+
+// BlobURL returns the download URL of an offloaded blob.
+func (g *Gateway) BlobURL(token string) string {
+	return strings.TrimSuffix(g.settings.PublicURL, "/") + "/mcp/blobs/" + token
+}
+
+// offloadContent replaces image and audio content of a tool result whose base64
+// data exceeds BLOB_OFFLOAD_SIZE with a link to a one-time URL serving it, so
+// large binaries do not travel through the session and clients that cannot
+// handle them. Clients speaking 2025-06-18 get a resource_link, older ones a
+// text block with the URL.
+func (g *Gateway) offloadContent(result json.RawMessage, caller *Caller) json.RawMessage {
+	limit := g.settings.BlobOffloadSize
+	if limit <= 0 || len(result) <= limit {
+		return result
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(result, &decoded); err != nil {
+		return result
+	}
+	content, _ := decoded["content"].([]interface{})
+
+	offloaded := 0
+	for i, item := range content {
+		block, _ := item.(map[string]interface{})
+		kind, _ := block["type"].(string)
+		data, _ := block["data"].(string)
+		if (kind != "image" && kind != "audio") || len(data) <= limit {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			continue
+		}
+		mimeType, _ := block["mimeType"].(string)
+		token := g.blobs.Put(&Blob{MimeType: mimeType, Data: raw, KeyID: caller.KeyID}, g.settings.BlobURLTTL)
+		url := g.BlobURL(token)
+		offloaded++
+
+		if protocolAtLeast(caller.Protocol(), Protocol20250618) {
+			content[i] = map[string]interface{}{
+				"type":     "resource_link",
+				"uri":      url,
+				"name":     fmt.Sprintf("%s-%d", kind, offloaded),
+				"mimeType": mimeType,
+				"size":     len(raw),
+			}
+		} else {
+			content[i] = map[string]interface{}{
+				"type": "text",
+				"text": fmt.Sprintf("The %s content (%s, %d bytes) is too large to include and can be downloaded once within %v from %s", kind, mimeType, len(raw), g.settings.BlobURLTTL, url),
+			}
+		}
+	}
+	if offloaded == 0 {
+		return result
+	}
+	fmt.Printf("[Gateway] Offloaded %d content blocks for key %d\n", offloaded, caller.KeyID)
+	rewritten, err := json.Marshal(decoded)
+	if err != nil {
+		return result
+	}
+	return rewritten
+}
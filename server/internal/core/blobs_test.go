@@ -0,0 +1,73 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"one-mcp/internal/config"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffloadContent(t *testing.T) {
+	settings := &config.Config{PublicURL: "https://gw.example.com/", BlobOffloadSize: 100, BlobURLTTL: time.Minute}
+	g := NewGateway(nil, settings)
+	image := []byte(strings.Repeat("\x89PNG", 50))
+	result, _ := json.Marshal(map[string]interface{}{
+		"content": []interface{}{
+			map[string]interface{}{"type": "text", "text": "chart:"},
+			map[string]interface{}{"type": "image", "mimeType": "image/png", "data": base64.StdEncoding.EncodeToString(image)},
+			map[string]interface{}{"type": "image", "mimeType": "image/png", "data": "aWNvbg=="},
+		},
+	})
+	blocks := func(result json.RawMessage) []map[string]interface{} {
+		var decoded struct {
+			Content []map[string]interface{} `json:"content"`
+		}
+		assert.NoError(t, json.Unmarshal(result, &decoded))
+		return decoded.Content
+	}
+
+	t.Run("Resource Link", func(t *testing.T) {
+		content := blocks(g.offloadContent(result, &Caller{KeyID: 7, ProtocolVersion: Protocol20250618}))
+		assert.Equal(t, "text", content[0]["type"])
+		assert.Equal(t, "image", content[2]["type"], "small content stays inline")
+
+		link := content[1]
+		assert.Equal(t, "resource_link", link["type"])
+		assert.Equal(t, "image/png", link["mimeType"])
+		assert.Equal(t, float64(len(image)), link["size"])
+		uri := link["uri"].(string)
+		assert.True(t, strings.HasPrefix(uri, "https://gw.example.com/mcp/blobs/"), uri)
+
+		token := strings.TrimPrefix(uri, "https://gw.example.com/mcp/blobs/")
+		blob, ok := g.Blobs().Take(token)
+		assert.True(t, ok)
+		assert.Equal(t, image, blob.Data)
+		assert.Equal(t, uint(7), blob.KeyID)
+		_, ok = g.Blobs().Take(token)
+		assert.False(t, ok, "URLs work once")
+	})
+
+	t.Run("Text For Older Clients", func(t *testing.T) {
+		content := blocks(g.offloadContent(result, &Caller{ProtocolVersion: Protocol20250326}))
+		assert.Equal(t, "text", content[1]["type"])
+		assert.Contains(t, content[1]["text"], "https://gw.example.com/mcp/blobs/")
+	})
+
+	t.Run("Disabled Or Small", func(t *testing.T) {
+		settings.BlobOffloadSize = 0
+		assert.Equal(t, string(result), string(g.offloadContent(result, &Caller{})))
+		settings.BlobOffloadSize = 100000
+		assert.Equal(t, string(result), string(g.offloadContent(result, &Caller{})))
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		token := g.Blobs().Put(&Blob{Data: []byte("x")}, 20*time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		_, ok := g.Blobs().Take(token)
+		assert.False(t, ok)
+	})
+}
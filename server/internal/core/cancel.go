@@ -0,0 +1,87 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// errCancelled is returned for upstream calls abandoned because the client
+// cancelled the request they serve.
+var errCancelled = errors.New("request cancelled by the client")
+
+// requestKey identifies a downstream request by session and JSON-RPC ID.
+func requestKey(sessionID string, id json.RawMessage) string {
+	return sessionID + "|" + string(bytes.TrimSpace(id))
+}
+
+// trackRequest registers a downstream request as in flight so the client can
+// cancel it. The returned channel is closed on cancellation; the function ends
+// the tracking.
+func (g *Gateway) trackRequest(sessionID string, id json.RawMessage) (<-chan struct{}, func()) {
+	key := requestKey(sessionID, id)
+	cancel := make(chan struct{})
+
+	g.inflightMu.Lock()
+	if g.inflight == nil {
+		g.inflight = make(map[string]chan struct{})
+	}
+	g.inflight[key] = cancel
+	g.inflightMu.Unlock()
+
+	return cancel, func() {
+		g.inflightMu.Lock()
+		defer g.inflightMu.Unlock()
+		if g.inflight[key] == cancel {
+			delete(g.inflight, key)
+		}
+	}
+}
+
+// requestCancellation returns the channel closed when the client cancels req,
+// or nil if the request is not tracked.
+func (g *Gateway) requestCancellation(caller *Caller, req *JSONRPCMessage) <-chan struct{} {
+	if req.ID == nil {
+		return nil
+	}
+	g.inflightMu.Lock()
+	defer g.inflightMu.Unlock()
+	if cancel, ok := g.inflight[requestKey(caller.SessionID, *req.ID)]; ok {
+		return cancel
+	}
+	return nil
+}
+
+// handleCancelled processes notifications/cancelled from a client. Upstream
+// calls made for the request stop waiting and are cancelled upstream too.
+func (g *Gateway) handleCancelled(req *JSONRPCMessage, caller *Caller) {
+	var params struct {
+		RequestID json.RawMessage `json:"requestId"`
+		Reason    string          `json:"reason"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil || len(params.RequestID) == 0 {
+		return
+	}
+
+	key := requestKey(caller.SessionID, params.RequestID)
+	g.inflightMu.Lock()
+	cancel, ok := g.inflight[key]
+	delete(g.inflight, key)
+	g.inflightMu.Unlock()
+	if !ok {
+		return
+	}
+	fmt.Printf("[Gateway] Session %s cancelled request %s: %s\n", caller.SessionID, string(params.RequestID), params.Reason)
+	close(cancel)
+}
+
+// cancelled reports whether the channel from trackRequest was closed.
+func cancelled(cancel <-chan struct{}) bool {
+	select {
+	case <-cancel:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"encoding/json"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancellationPropagation(t *testing.T) {
+	g := &Gateway{}
+	transport := &recordingTransport{sent: make(chan []byte, 4)}
+	upstream := &UpstreamClient{
+		Config:      model.UpstreamServer{Name: "slow"},
+		transport:   transport,
+		ready:       true,
+		pendingReqs: make(map[string]chan JSONRPCMessage),
+	}
+	caller := &Caller{SessionID: "s1"}
+
+	cancel, done := g.trackRequest("s1", json.RawMessage(`7`))
+	defer done()
+	result := make(chan error, 1)
+	go func() {
+		_, err := upstream.CallAsCancelable("k", "tools/call", map[string]string{"name": "build"}, time.Minute, cancel)
+		result <- err
+	}()
+
+	var call JSONRPCMessage
+	json.Unmarshal(<-transport.sent, &call)
+	assert.Equal(t, "tools/call", call.Method)
+
+	// Cancellations from other sessions or for other requests do nothing
+	g.HandleMessage([]byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7}}`), &Caller{SessionID: "s2"})
+	g.HandleMessage([]byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":8}}`), caller)
+	assert.False(t, cancelled(cancel))
+
+	resp, err := g.HandleMessage([]byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7,"reason":"user abort"}}`), caller)
+	assert.Nil(t, resp)
+	assert.NoError(t, err)
+
+	select {
+	case err := <-result:
+		assert.Equal(t, errCancelled, err)
+	case <-time.After(time.Second):
+		t.Fatal("call still waiting after cancellation")
+	}
+
+	var notice struct {
+		Method string `json:"method"`
+		Params struct {
+			RequestID json.RawMessage `json:"requestId"`
+		} `json:"params"`
+	}
+	json.Unmarshal(<-transport.sent, &notice)
+	assert.Equal(t, "notifications/cancelled", notice.Method)
+	assert.Equal(t, string(*call.ID), string(notice.Params.RequestID))
+
+	// Calls for an already cancelled request are not sent
+	_, err = upstream.CallAsCancelable("k", "tools/call", nil, time.Minute, cancel)
+	assert.Equal(t, errCancelled, err)
+	assert.Empty(t, transport.sent)
+}
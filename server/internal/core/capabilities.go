@@ -0,0 +1,72 @@
+package core
+
+import "encoding/json"
+
+// Capabilities returns the capabilities the upstream declared in its last
+// successful initialize, or nil if it never initialized.
+func (c *UpstreamClient) Capabilities() map[string]json.RawMessage {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.capabilities
+}
+
+// serverCapabilities returns the capabilities announced to a downstream client.
+// Tools are always served by the gateway itself; prompts, resources, logging and
+// completions are only announced if an upstream visible to the caller declared
+// them. Upstreams in maintenance still count, as the session outlives the window.
+// Prompt and resource list changes are not relayed, so listChanged is false.
+// Custom methods registered by plugins are listed as an experimental capability.
+func (g *Gateway) serverCapabilities(caller *Caller, version string) map[string]interface{} {
+	capabilities := map[string]interface{}{
+		"tools": map[string]interface{}{
+			"listChanged": true,
+		},
+	}
+
+	strict := g.Flags().StrictPermissions
+	g.mu.RLock()
+	clients := make([]*UpstreamClient, 0, len(g.upstreams))
+	for _, c := range g.upstreams {
+		if caller.serverVisible(c, strict) {
+			clients = append(clients, c)
+		}
+	}
+	g.mu.RUnlock()
+
+	subscribe := false
+	for _, c := range clients {
+		declared := c.Capabilities()
+		if _, ok := declared["prompts"]; ok {
+			capabilities["prompts"] = map[string]interface{}{"listChanged": false}
+		}
+		if raw, ok := declared["resources"]; ok {
+			var resources struct {
+				Subscribe bool `json:"subscribe"`
+			}
+			json.Unmarshal(raw, &resources)
+			subscribe = subscribe || resources.Subscribe
+			capabilities["resources"] = map[string]interface{}{"listChanged": false, "subscribe": subscribe}
+		}
+		if _, ok := declared["logging"]; ok {
+			capabilities["logging"] = map[string]interface{}{}
+		}
+		// The completions capability was introduced in 2025-03-26. Older upstreams
+		// could not declare it and answered completions for their prompts and resources.
+		_, completions := declared["completions"]
+		if !protocolAtLeast(c.ProtocolVersion(), Protocol20250326) {
+			_, hasPrompts := declared["prompts"]
+			_, hasResources := declared["resources"]
+			completions = hasPrompts || hasResources
+		}
+		if completions && protocolAtLeast(version, Protocol20250326) {
+			capabilities["completions"] = map[string]interface{}{}
+		}
+	}
+	// Custom methods of plugins, see methods.go
+	if names := Methods(); len(names) > 0 {
+		capabilities["experimental"] = map[string]interface{}{
+			"one-mcp/methods": map[string]interface{}{"methods": names},
+		}
+	}
+	return capabilities
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"encoding/json"
+	"one-mcp/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerCapabilities(t *testing.T) {
+	upstream := func(id uint, name, version, capabilities string) *UpstreamClient {
+		c := &UpstreamClient{Config: model.UpstreamServer{ID: id, Name: name}, protocolVersion: version}
+		json.Unmarshal([]byte(capabilities), &c.capabilities)
+		return c
+	}
+	g := &Gateway{upstreams: map[uint]*UpstreamClient{
+		0: upstream(0, "demo", Protocol20250618, `{"tools":{}}`),
+		1: upstream(1, "docs", Protocol20250618, `{"tools":{},"resources":{"subscribe":true,"listChanged":true}}`),
+		2: upstream(2, "legacy", Protocol20241105, `{"prompts":{"listChanged":true}}`),
+		3: upstream(3, "offline", "", ""),
+	}}
+	names := func(capabilities map[string]interface{}) []string {
+		var keys []string
+		for key := range capabilities {
+			keys = append(keys, key)
+		}
+		return keys
+	}
+
+	t.Run("Union Of Upstreams", func(t *testing.T) {
+		capabilities := g.serverCapabilities(&Caller{}, Protocol20250618)
+		assert.ElementsMatch(t, []string{"tools", "resources", "prompts", "completions"}, names(capabilities))
+		assert.Equal(t, map[string]interface{}{"listChanged": false, "subscribe": true}, capabilities["resources"])
+		assert.Equal(t, map[string]interface{}{"listChanged": false}, capabilities["prompts"])
+	})
+
+	t.Run("Only Tools Without Upstream Features", func(t *testing.T) {
+		capabilities := g.serverCapabilities(&Caller{AllowedServers: []string{"0"}}, Protocol20250618)
+		assert.Equal(t, []string{"tools"}, names(capabilities))
+	})
+
+	t.Run("Restricted To Visible Servers", func(t *testing.T) {
+		capabilities := g.serverCapabilities(&Caller{AllowedTools: []string{"legacy__*"}}, Protocol20241105)
+		assert.ElementsMatch(t, []string{"tools", "prompts"}, names(capabilities), "no completions before 2025-03-26")
+	})
+}
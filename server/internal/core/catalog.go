@@ -0,0 +1,275 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"one-mcp/internal/model"
+	"sort"
+	"strings"
+)
+
+// CatalogDelta lists tool changes after a cursor. Cursor is the revision to pass
+// as "since" on the next sync.
+type CatalogDelta struct {
+	Cursor  int64                    `json:"cursor"`
+	Added   []map[string]interface{} `json:"added"`
+	Changed []map[string]interface{} `json:"changed"`
+	Removed []string                 `json:"removed"`
+}
+
+// SyncCatalog records the current aggregated tools in the catalog table, bumping the
+// revision of every tool that was added, changed or removed since the last sync.
+// listed holds the upstreams whose tools/list succeeded, as returned by
+// ListAllTools. The tools of any other running upstream, e.g. one that is not
+// ready, in a maintenance window or failed to list, are kept as they were.
+func (g *Gateway) SyncCatalog(tools []map[string]interface{}, listed map[string]bool) error {
+	g.catalogMu.Lock()
+	defer g.catalogMu.Unlock()
+
+	var entries []model.ToolCatalogEntry
+	if err := g.db.Find(&entries).Error; err != nil {
+		return err
+	}
+
+	var rev int64
+	known := make(map[string]*model.ToolCatalogEntry, len(entries))
+	for i := range entries {
+		known[entries[i].Name] = &entries[i]
+		if entries[i].Revision > rev {
+			rev = entries[i].Revision
+		}
+	}
+
+	seen := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		name, _ := tool["name"].(string)
+		if name == "" {
+			continue
+		}
+		seen[name] = true
+
+		def, _ := json.Marshal(tool)
+		sum := sha256.Sum256(def)
+		hash := hex.EncodeToString(sum[:])
+
+		entry, ok := known[name]
+		if !ok {
+			rev++
+			g.db.Create(&model.ToolCatalogEntry{
+				Name:       name,
+				Hash:       hash,
+				Definition: string(def),
+				CreatedRev: rev,
+				Revision:   rev,
+			})
+			continue
+		}
+		if entry.Hash == hash && !entry.Removed {
+			continue
+		}
+
+		rev++
+		if entry.Removed {
+			// Re-appearing tools count as added again
+			entry.CreatedRev = rev
+		}
+		entry.Hash = hash
+		entry.Definition = string(def)
+		entry.Revision = rev
+		entry.Removed = false
+		g.db.Save(entry)
+	}
+
+	unlisted := g.unlistedUpstreams(listed)
+	for name, entry := range known {
+		if seen[name] || entry.Removed || unlisted(name) {
+			continue
+		}
+		rev++
+		entry.Removed = true
+		entry.Revision = rev
+		g.db.Save(entry)
+	}
+
+	return nil
+}
+
+// unlistedUpstreams returns whether a prefixed tool name belongs to a running
+// upstream that is missing from listed. Tools of deleted servers and of the
+// gateway's own tools are not matched, so their removals are still recorded.
+func (g *Gateway) unlistedUpstreams(listed map[string]bool) func(name string) bool {
+	g.mu.RLock()
+	var servers []string
+	for server, id := range g.upstreamIDs {
+		if _, ok := g.upstreams[id]; ok && !listed[server] {
+			servers = append(servers, server)
+		}
+	}
+	g.mu.RUnlock()
+
+	return func(name string) bool {
+		for _, server := range servers {
+			if strings.HasPrefix(name, server+"__") {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// CatalogDelta returns the tools added, changed or removed after revision since.
+func (g *Gateway) CatalogDelta(since int64) (*CatalogDelta, error) {
+	g.catalogMu.Lock()
+	defer g.catalogMu.Unlock()
+
+	delta := &CatalogDelta{
+		Cursor:  since,
+		Added:   []map[string]interface{}{},
+		Changed: []map[string]interface{}{},
+		Removed: []string{},
+	}
+
+	var entries []model.ToolCatalogEntry
+	if err := g.db.Where("revision > ?", since).Order("revision").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		delta.Cursor = entry.Revision
+		if entry.Removed {
+			// Tools both created and removed after the cursor were never seen by the consumer
+			if entry.CreatedRev <= since {
+				delta.Removed = append(delta.Removed, entry.Name)
+			}
+			continue
+		}
+
+		var tool map[string]interface{}
+		if err := json.Unmarshal([]byte(entry.Definition), &tool); err != nil {
+			continue
+		}
+		if entry.CreatedRev > since {
+			delta.Added = append(delta.Added, tool)
+		} else {
+			delta.Changed = append(delta.Changed, tool)
+		}
+	}
+
+	return delta, nil
+}
+
+// SnapshotCatalog stores the current aggregated tools as a new catalog version.
+func (g *Gateway) SnapshotCatalog(note string) (*model.CatalogVersion, error) {
+	tools, err := g.GetAllTools()
+	if err != nil {
+		return nil, err
+	}
+	def, _ := json.Marshal(tools)
+	version := &model.CatalogVersion{
+		Note:      note,
+		ToolCount: len(tools),
+		Tools:     string(def),
+	}
+	if err := g.db.Create(version).Error; err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// CatalogVersionTools returns the tools of a catalog version by name. Versions are
+// immutable, so they are cached after the first load.
+func (g *Gateway) CatalogVersionTools(id uint) map[string]map[string]interface{} {
+	g.catalogMu.Lock()
+	defer g.catalogMu.Unlock()
+
+	if tools, ok := g.catalogVersions[id]; ok {
+		return tools
+	}
+
+	var version model.CatalogVersion
+	found := g.replica != nil && g.replica.First(&version, id).Error == nil
+	// Without a replica, or when it has not caught up with a new snapshot yet
+	if !found && g.db.First(&version, id).Error != nil {
+		// Deleted or unknown versions expose no tools rather than the live catalog
+		return map[string]map[string]interface{}{}
+	}
+	var list []map[string]interface{}
+	json.Unmarshal([]byte(version.Tools), &list)
+
+	tools := make(map[string]map[string]interface{}, len(list))
+	for _, tool := range list {
+		if name, ok := tool["name"].(string); ok {
+			tools[name] = tool
+		}
+	}
+	if g.catalogVersions == nil {
+		g.catalogVersions = make(map[uint]map[string]map[string]interface{})
+	}
+	g.catalogVersions[id] = tools
+	return tools
+}
+
+// ForgetCatalogVersion drops a deleted version from the cache.
+func (g *Gateway) ForgetCatalogVersion(id uint) {
+	g.catalogMu.Lock()
+	delete(g.catalogVersions, id)
+	g.catalogMu.Unlock()
+}
+
+// handlePinnedToolsList lists the live tools that are part of the pinned catalog
+// version, with the schemas recorded in the snapshot. Tools added upstream after
+// the snapshot stay hidden until the key is promoted.
+func (g *Gateway) handlePinnedToolsList(req *JSONRPCMessage, version uint, hasPermission func(string, string) bool) (*JSONRPCMessage, error) {
+	resp, err := g.handleToolsList(req, hasPermission)
+	if err != nil || resp.Error != nil {
+		return resp, err
+	}
+
+	var result struct {
+		Tools []map[string]interface{} `json:"tools"`
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, err
+	}
+
+	pinned := g.CatalogVersionTools(version)
+	tools := make([]map[string]interface{}, 0, len(result.Tools))
+	for _, tool := range result.Tools {
+		name, _ := tool["name"].(string)
+		if snapshot, ok := pinned[name]; ok {
+			tools = append(tools, snapshot)
+		}
+	}
+
+	resp.Result, _ = json.Marshal(map[string]interface{}{"tools": tools})
+	return resp, nil
+}
+
+// CatalogVersionDiff compares a catalog version with the live tools and returns the
+// names of tools added, changed and removed since the snapshot.
+func CatalogVersionDiff(version map[string]map[string]interface{}, live []map[string]interface{}) map[string][]string {
+	diff := map[string][]string{"added": {}, "changed": {}, "removed": {}}
+	seen := make(map[string]bool, len(live))
+	for _, tool := range live {
+		name, _ := tool["name"].(string)
+		seen[name] = true
+		snapshot, ok := version[name]
+		if !ok {
+			diff["added"] = append(diff["added"], name)
+			continue
+		}
+		a, _ := json.Marshal(snapshot)
+		b, _ := json.Marshal(tool)
+		if string(a) != string(b) {
+			diff["changed"] = append(diff["changed"], name)
+		}
+	}
+	for name := range version {
+		if !seen[name] {
+			diff["removed"] = append(diff["removed"], name)
+		}
+	}
+	sort.Strings(diff["removed"])
+	return diff
+}
@@ -0,0 +1,196 @@
+package core
+
+import (
+	"encoding/json"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestCatalogKeepsToolsOfUnavailableUpstreams(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&model.ToolCatalogEntry{}))
+	g := NewGateway(db, &config.Config{})
+	github := &UpstreamClient{Config: model.UpstreamServer{ID: 1, Name: "github"}, ready: true}
+	g.upstreams[1] = github
+	g.upstreamIDs["github"] = 1
+
+	assert.NoError(t, g.SyncCatalog([]map[string]interface{}{
+		{"name": "github__get_issue"}, {"name": "slack__post"},
+	}, map[string]bool{"github": true}))
+	delta, err := g.CatalogDelta(0)
+	assert.NoError(t, err)
+	cursor := delta.Cursor
+
+	// github disconnects and lists nothing; slack is no longer configured at all
+	github.mu.Lock()
+	github.ready = false
+	github.mu.Unlock()
+	assert.NoError(t, g.SyncCatalog(nil, nil))
+	delta, err = g.CatalogDelta(cursor)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"slack__post"}, delta.Removed, "tools of a disconnected upstream are not removed")
+	cursor = delta.Cursor
+
+	// Back online with the same tools, nothing changed
+	github.mu.Lock()
+	github.ready = true
+	github.mu.Unlock()
+	assert.NoError(t, g.SyncCatalog([]map[string]interface{}{{"name": "github__get_issue"}}, map[string]bool{"github": true}))
+	delta, err = g.CatalogDelta(cursor)
+	assert.NoError(t, err)
+	assert.Empty(t, delta.Added)
+	assert.Empty(t, delta.Changed)
+	assert.Empty(t, delta.Removed)
+}
+
+func TestCatalogKeepsToolsOfUnlistedUpstreams(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(model.All...))
+	settings := &config.Config{UpstreamTimeout: time.Second}
+	g := NewGateway(db, settings)
+	selector, _ := ParseToolSelector("", "", 0)
+	transports := make(map[string]*listTransport)
+	for id, name := range []string{"db", "search"} {
+		transport := &listTransport{tools: []map[string]interface{}{{"name": "t"}}}
+		client := &UpstreamClient{
+			selector:    selector,
+			Config:      model.UpstreamServer{ID: uint(id + 1), Name: name},
+			settings:    settings,
+			transport:   transport,
+			pendingReqs: make(map[string]chan JSONRPCMessage),
+			ready:       true,
+		}
+		transport.client = client
+		g.upstreams[client.Config.ID] = client
+		g.upstreamIDs[name] = client.Config.ID
+		transports[name] = transport
+	}
+
+	sync := func() *CatalogDelta {
+		delta, err := g.CatalogDelta(0)
+		assert.NoError(t, err)
+		tools, listed, err := g.ListAllTools()
+		assert.NoError(t, err)
+		assert.NoError(t, g.SyncCatalog(tools, listed))
+		delta, err = g.CatalogDelta(delta.Cursor)
+		assert.NoError(t, err)
+		return delta
+	}
+	sync()
+
+	t.Run("Maintenance", func(t *testing.T) {
+		now := time.Now()
+		db.Create(&model.MaintenanceWindow{ServerID: 1, StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Hour)})
+		g.ReloadMaintenance()
+		defer func() {
+			db.Where("server_id = ?", 1).Delete(&model.MaintenanceWindow{})
+			g.ReloadMaintenance()
+		}()
+		assert.Empty(t, sync().Removed, "tools of a server in maintenance are not removed")
+	})
+
+	t.Run("List Error", func(t *testing.T) {
+		transports["search"].fail = true
+		defer func() { transports["search"].fail = false }()
+		assert.Empty(t, sync().Removed, "tools of a server that failed to list are not removed")
+	})
+
+	t.Run("Listed Servers Record Removals", func(t *testing.T) {
+		transports["db"].tools = nil
+		assert.Equal(t, []string{"db__t"}, sync().Removed)
+	})
+}
+
+func TestCatalogVersions(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(model.All...))
+	settings := &config.Config{UpstreamTimeout: time.Second}
+	g := NewGateway(db, settings)
+	selector, _ := ParseToolSelector("", "", 0)
+	transport := &listTransport{tools: []map[string]interface{}{{"name": "get_issue", "description": "v1"}}}
+	client := &UpstreamClient{
+		selector:    selector,
+		Config:      model.UpstreamServer{ID: 1, Name: "github"},
+		settings:    settings,
+		transport:   transport,
+		pendingReqs: make(map[string]chan JSONRPCMessage),
+		ready:       true,
+	}
+	transport.client = client
+	g.upstreams[1] = client
+	g.upstreamIDs["github"] = 1
+
+	version, err := g.SnapshotCatalog("before upgrade")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version.ToolCount)
+
+	// The upstream changes a schema and adds a tool
+	transport.tools = []map[string]interface{}{
+		{"name": "get_issue", "description": "v2"},
+		{"name": "create_issue"},
+	}
+	handle := func(caller *Caller, method string, params interface{}) *JSONRPCMessage {
+		id := json.RawMessage("1")
+		raw, _ := json.Marshal(params)
+		msg, _ := json.Marshal(&JSONRPCMessage{JSONRPC: "2.0", ID: &id, Method: method, Params: raw})
+		resp, err := g.HandleMessage(msg, caller)
+		assert.NoError(t, err)
+		return resp
+	}
+	list := func(caller *Caller) map[string]interface{} {
+		var result struct {
+			Tools []map[string]interface{} `json:"tools"`
+		}
+		json.Unmarshal(handle(caller, "tools/list", map[string]interface{}{}).Result, &result)
+		tools := make(map[string]interface{})
+		for _, tool := range result.Tools {
+			tools[tool["name"].(string)] = tool["description"]
+		}
+		return tools
+	}
+
+	t.Run("Pinned Keys See The Snapshot", func(t *testing.T) {
+		assert.Equal(t, map[string]interface{}{"github__get_issue": "v1"}, list(&Caller{CatalogVersion: version.ID}))
+		assert.Equal(t, map[string]interface{}{"github__get_issue": "v2", "github__create_issue": nil}, list(&Caller{}))
+
+		resp := handle(&Caller{CatalogVersion: version.ID}, "tools/call", map[string]interface{}{"name": "github__create_issue"})
+		if assert.NotNil(t, resp.Error) {
+			assert.Equal(t, "Tool not found", resp.Error.Message)
+		}
+	})
+
+	t.Run("Tools Removed Upstream Stay Hidden", func(t *testing.T) {
+		transport.tools = []map[string]interface{}{{"name": "create_issue"}}
+		defer func() {
+			transport.tools = []map[string]interface{}{{"name": "get_issue", "description": "v2"}, {"name": "create_issue"}}
+		}()
+		assert.Empty(t, list(&Caller{CatalogVersion: version.ID}))
+	})
+
+	t.Run("Diff Against Live Tools", func(t *testing.T) {
+		live, err := g.GetAllTools()
+		assert.NoError(t, err)
+		diff := CatalogVersionDiff(g.CatalogVersionTools(version.ID), live)
+		assert.Equal(t, []string{"github__create_issue"}, diff["added"])
+		assert.Equal(t, []string{"github__get_issue"}, diff["changed"])
+		assert.Empty(t, diff["removed"])
+	})
+
+	t.Run("Unknown Versions Expose No Tools", func(t *testing.T) {
+		assert.Empty(t, list(&Caller{CatalogVersion: 99}))
+
+		db.Delete(&model.CatalogVersion{}, version.ID)
+		assert.Len(t, g.CatalogVersionTools(version.ID), 1, "versions are cached")
+		g.ForgetCatalogVersion(version.ID)
+		assert.Empty(t, g.CatalogVersionTools(version.ID))
+	})
+}
@@ -0,0 +1,185 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// coerceArgs converts arguments to the declared parameter types, since models often
+// send numbers and booleans as strings. Unknown arguments are passed through.
+func coerceArgs(params []ToolParameter, args map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		out[k] = v
+	}
+
+	for _, p := range params {
+		v, ok := out[p.Name]
+		if (!ok || v == nil) && p.Default != "" {
+			v, ok = p.Default, true
+		}
+		if !ok || v == nil {
+			if p.Required {
+				return nil, fmt.Errorf("missing required argument %q", p.Name)
+			}
+			continue
+		}
+		coerced, err := coerceValue(p, v)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %v", p.Name, err)
+		}
+		out[p.Name] = coerced
+	}
+	return out, nil
+}
+
+func coerceValue(p ToolParameter, v interface{}) (interface{}, error) {
+	typ := p.Type
+	switch typ {
+	case "string":
+		switch val := v.(type) {
+		case string:
+			return val, nil
+		case float64:
+			// Not %v, whose exponent notation would corrupt IDs such as 12345678
+			return strconv.FormatFloat(val, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(val), nil
+		}
+		return nil, fmt.Errorf("expected a string, got %T", v)
+
+	case "number", "integer":
+		var n float64
+		switch val := v.(type) {
+		case float64:
+			n = val
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot convert %q to a number", val)
+			}
+			n = parsed
+		default:
+			return nil, fmt.Errorf("expected a number, got %T", v)
+		}
+		if typ == "integer" && n != float64(int64(n)) {
+			return nil, fmt.Errorf("expected an integer, got %v", n)
+		}
+		return n, nil
+
+	case "boolean":
+		switch val := v.(type) {
+		case bool:
+			return val, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(val)) {
+			case "true", "1", "yes":
+				return true, nil
+			case "false", "0", "no":
+				return false, nil
+			}
+			return nil, fmt.Errorf("cannot convert %q to a boolean", val)
+		case float64:
+			if val == 0 || val == 1 {
+				return val == 1, nil
+			}
+		}
+		return nil, fmt.Errorf("expected a boolean, got %v", v)
+
+	case "array":
+		if str, ok := v.(string); ok {
+			// Accept a JSON-encoded array, otherwise treat the string as a single element
+			var decoded []interface{}
+			if json.Unmarshal([]byte(str), &decoded) == nil {
+				v = decoded
+			} else {
+				v = []interface{}{str}
+			}
+		}
+		list, ok := v.([]interface{})
+		if !ok {
+			list = []interface{}{v}
+		}
+		if p.Items == nil {
+			return list, nil
+		}
+		out := make([]interface{}, len(list))
+		for i, item := range list {
+			coerced, err := coerceValue(*p.Items, item)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %v", i, err)
+			}
+			out[i] = coerced
+		}
+		return out, nil
+
+	case "object":
+		if str, ok := v.(string); ok {
+			var decoded map[string]interface{}
+			if err := json.Unmarshal([]byte(str), &decoded); err != nil {
+				return nil, fmt.Errorf("cannot convert %q to an object", str)
+			}
+			v = decoded
+		}
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected an object, got %T", v)
+		}
+		return coerceArgs(p.Properties, obj)
+	}
+	return v, nil
+}
+
+// parameterSchema builds the JSON Schema for a parameter, including nested
+// array items and object properties.
+func parameterSchema(p ToolParameter) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type": p.Type,
+	}
+	if p.Description != "" {
+		schema["description"] = p.Description
+	}
+	if p.Type == "array" && p.Items != nil {
+		schema["items"] = parameterSchema(*p.Items)
+	}
+	if p.Type == "object" && len(p.Properties) > 0 {
+		properties := make(map[string]interface{}, len(p.Properties))
+		required := []string{}
+		for _, child := range p.Properties {
+			properties[child.Name] = parameterSchema(child)
+			if child.Required && child.Default == "" {
+				required = append(required, child.Name)
+			}
+		}
+		schema["properties"] = properties
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+	}
+	return schema
+}
+
+// setQueryParam adds an argument to a query string. Arrays become repeated
+// parameters and objects are JSON-encoded.
+func setQueryParam(q url.Values, key string, v interface{}) {
+	q.Del(key)
+	list, ok := v.([]interface{})
+	if !ok {
+		list = []interface{}{v}
+	}
+	for _, item := range list {
+		q.Add(key, queryValue(item))
+	}
+}
+
+func queryValue(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+	return fmt.Sprintf("%v", v)
+}
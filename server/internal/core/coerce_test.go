@@ -0,0 +1,80 @@
+package core
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoerceArgs(t *testing.T) {
+	params := []ToolParameter{
+		{Name: "limit", Type: "number"},
+		{Name: "page", Type: "integer"},
+		{Name: "verbose", Type: "boolean"},
+		{Name: "query", Type: "string", Required: true},
+	}
+
+	args, err := coerceArgs(params, map[string]interface{}{
+		"limit": "5", "page": "2", "verbose": "true", "query": float64(42), "extra": "kept",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"limit": float64(5), "page": float64(2), "verbose": true, "query": "42", "extra": "kept",
+	}, args)
+
+	// Numbers keep their digits: no exponent notation for large or fractional values
+	for number, text := range map[float64]string{12345678: "12345678", 1e21: "1000000000000000000000", 0.125: "0.125", 1234567.5: "1234567.5"} {
+		args, err = coerceArgs(params, map[string]interface{}{"query": number})
+		assert.NoError(t, err)
+		assert.Equal(t, text, args["query"])
+	}
+	args, _ = coerceArgs(params, map[string]interface{}{"query": false})
+	assert.Equal(t, "false", args["query"])
+
+	_, err = coerceArgs(params, map[string]interface{}{"query": "x", "limit": "five"})
+	assert.ErrorContains(t, err, `argument "limit"`)
+
+	_, err = coerceArgs(params, map[string]interface{}{"query": "x", "page": 1.5})
+	assert.ErrorContains(t, err, "integer")
+
+	_, err = coerceArgs(params, map[string]interface{}{})
+	assert.ErrorContains(t, err, `missing required argument "query"`)
+}
+
+func TestCoerceNestedArgs(t *testing.T) {
+	params := []ToolParameter{
+		{Name: "ids", Type: "array", Items: &ToolParameter{Type: "integer"}},
+		{Name: "filter", Type: "object", Properties: []ToolParameter{
+			{Name: "active", Type: "boolean", Required: true},
+			{Name: "sort", Type: "string", Default: "name"},
+		}},
+	}
+
+	args, err := coerceArgs(params, map[string]interface{}{
+		"ids":    []interface{}{"1", float64(2)},
+		"filter": `{"active": "yes"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{float64(1), float64(2)}, args["ids"])
+	assert.Equal(t, map[string]interface{}{"active": true, "sort": "name"}, args["filter"])
+
+	args, err = coerceArgs(params, map[string]interface{}{"ids": "[3, 4]"})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{float64(3), float64(4)}, args["ids"])
+
+	_, err = coerceArgs(params, map[string]interface{}{"ids": []interface{}{"x"}})
+	assert.ErrorContains(t, err, "item 0")
+
+	_, err = coerceArgs(params, map[string]interface{}{"filter": map[string]interface{}{}})
+	assert.ErrorContains(t, err, `missing required argument "active"`)
+
+	schema := parameterSchema(params[1])
+	assert.Equal(t, []string{"active"}, schema["required"])
+	assert.Contains(t, schema["properties"], "sort")
+
+	q := url.Values{}
+	setQueryParam(q, "ids", []interface{}{float64(1), float64(2)})
+	setQueryParam(q, "filter", map[string]interface{}{"active": true})
+	assert.Equal(t, "filter=%7B%22active%22%3Atrue%7D&ids=1&ids=2", q.Encode())
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// handleCompletion forwards completion/complete to the upstream owning the
+// referenced prompt or resource. Upstreams without completion support yield
+// an empty completion, so clients can always offer autocomplete.
+func (g *Gateway) handleCompletion(req *JSONRPCMessage, caller *Caller) (*JSONRPCMessage, error) {
+	var params map[string]interface{}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return nil, err
+	}
+	ref, _ := params["ref"].(map[string]interface{})
+
+	var candidates []*UpstreamClient
+	switch refType, _ := ref["type"].(string); refType {
+	case "ref/prompt":
+		name, _ := ref["name"].(string)
+		client, promptName := g.promptClient(caller, name)
+		if client == nil {
+			return invalidParams(req, "Prompt not found"), nil
+		}
+		// Copy the ref so the caller's params keep the prefixed name
+		upstreamRef := make(map[string]interface{}, len(ref))
+		for k, v := range ref {
+			upstreamRef[k] = v
+		}
+		upstreamRef["name"] = promptName
+		params["ref"] = upstreamRef
+		candidates = []*UpstreamClient{client}
+	case "ref/resource":
+		uri, _ := ref["uri"].(string)
+		candidates = g.resourceCandidates(caller, uri)
+		if len(candidates) == 0 {
+			return invalidParams(req, "Resource not found"), nil
+		}
+	default:
+		return invalidParams(req, fmt.Sprintf("Unsupported ref type %q", refType)), nil
+	}
+
+	defer g.trackProgress(req, caller, params)()
+	for _, c := range candidates {
+		resp, err := c.CallAs(caller.Key(), "completion/complete", params)
+		if err != nil || resp.Error != nil {
+			continue
+		}
+		resp.ID = req.ID
+		return resp, nil
+	}
+
+	resBytes, _ := json.Marshal(map[string]interface{}{
+		"completion": map[string]interface{}{
+			"values":  []string{},
+			"total":   0,
+			"hasMore": false,
+		},
+	})
+	return &JSONRPCMessage{JSONRPC: "2.0", ID: req.ID, Result: resBytes}, nil
+}
+
+func invalidParams(req *JSONRPCMessage, message string) *JSONRPCMessage {
+	return &JSONRPCMessage{
+		JSONRPC: "2.0", ID: req.ID,
+		Error: &JSONRPCError{Code: -32602, Message: message},
+	}
+}
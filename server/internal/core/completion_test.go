@@ -0,0 +1,30 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletionRefs(t *testing.T) {
+	g := &Gateway{upstreams: map[uint]*UpstreamClient{}}
+	complete := func(ref map[string]interface{}) *JSONRPCMessage {
+		params, _ := json.Marshal(map[string]interface{}{
+			"ref":      ref,
+			"argument": map[string]string{"name": "city", "value": "Par"},
+		})
+		resp, err := g.handleCompletion(&JSONRPCMessage{Params: params}, &Caller{})
+		assert.NoError(t, err)
+		return resp
+	}
+
+	resp := complete(map[string]interface{}{"type": "ref/prompt", "name": "missing__prompt"})
+	assert.Equal(t, "Prompt not found", resp.Error.Message)
+
+	resp = complete(map[string]interface{}{"type": "ref/resource", "uri": "file:///x"})
+	assert.Equal(t, "Resource not found", resp.Error.Message)
+
+	resp = complete(map[string]interface{}{"type": "ref/other"})
+	assert.Equal(t, -32602, resp.Error.Code)
+}
@@ -0,0 +1,216 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DemoServer is the name of the built-in demo upstream enabled by DEMO_UPSTREAM.
+const DemoServer = "demo"
+
+// demoTool is a tool served in-process by the demo upstream.
+type demoTool struct {
+	Description string
+	Parameters  []ToolParameter
+	Run         func(args map[string]interface{}) (string, error)
+}
+
+var demoTools = map[string]demoTool{
+	"time": {
+		Description: "Returns the current time, optionally in an IANA time zone.",
+		Parameters: []ToolParameter{
+			{Name: "timezone", Type: "string", Description: "IANA time zone, e.g. Europe/Berlin (default UTC)"},
+		},
+		Run: func(args map[string]interface{}) (string, error) {
+			name, _ := args["timezone"].(string)
+			if name == "" {
+				name = "UTC"
+			}
+			loc, err := time.LoadLocation(name)
+			if err != nil {
+				return "", fmt.Errorf("unknown time zone %q", name)
+			}
+			return time.Now().In(loc).Format(time.RFC3339), nil
+		},
+	},
+	"calculator": {
+		Description: "Applies an arithmetic operation to two numbers.",
+		Parameters: []ToolParameter{
+			{Name: "a", Type: "number", Required: true},
+			{Name: "b", Type: "number", Required: true},
+			{Name: "operation", Type: "string", Required: true, Description: "add, subtract, multiply or divide"},
+		},
+		Run: func(args map[string]interface{}) (string, error) {
+			a, _ := args["a"].(float64)
+			b, _ := args["b"].(float64)
+			var result float64
+			switch op, _ := args["operation"].(string); op {
+			case "add":
+				result = a + b
+			case "subtract":
+				result = a - b
+			case "multiply":
+				result = a * b
+			case "divide":
+				if b == 0 {
+					return "", fmt.Errorf("division by zero")
+				}
+				result = a / b
+			default:
+				return "", fmt.Errorf("unknown operation %q", op)
+			}
+			return fmt.Sprintf("%g", result), nil
+		},
+	},
+	"echo": {
+		Description: "Returns the given text unchanged.",
+		Parameters: []ToolParameter{
+			{Name: "text", Type: "string", Required: true},
+		},
+		Run: func(args map[string]interface{}) (string, error) {
+			text, _ := args["text"].(string)
+			return text, nil
+		},
+	},
+	"weather": {
+		Description: "Returns made-up weather for a city. The data is fake and stable per city.",
+		Parameters: []ToolParameter{
+			{Name: "city", Type: "string", Required: true},
+		},
+		Run: func(args map[string]interface{}) (string, error) {
+			city, _ := args["city"].(string)
+			h := fnv.New32a()
+			h.Write([]byte(strings.ToLower(strings.TrimSpace(city))))
+			seed := h.Sum32()
+			conditions := []string{"sunny", "cloudy", "rainy", "windy", "snowy", "foggy"}
+			result, _ := json.Marshal(map[string]interface{}{
+				"city":          city,
+				"temperature_c": int(seed%45) - 10,
+				"conditions":    conditions[seed%uint32(len(conditions))],
+				"humidity":      20 + int(seed/7%70),
+				"demo":          true,
+			})
+			return string(result), nil
+		},
+	},
+}
+
+// DemoTransport serves the demo tools in-process, so the full client -> gateway ->
+// tool path can be tried without configuring a real upstream.
+type DemoTransport struct {
+	mu        sync.Mutex
+	onMessage func([]byte) // Replaced on every reconnect
+}
+
+func NewDemoTransport() *DemoTransport {
+	return &DemoTransport{}
+}
+
+func (t *DemoTransport) Start(ctx context.Context, onMessage func([]byte), onReady func()) error {
+	t.mu.Lock()
+	t.onMessage = onMessage
+	t.mu.Unlock()
+	if onReady != nil {
+		go onReady()
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (t *DemoTransport) Send(payload []byte) error {
+	var req JSONRPCMessage
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return err
+	}
+
+	switch req.Method {
+	case "initialize":
+		t.reply(req.ID, map[string]interface{}{
+			"protocolVersion": LatestProtocolVersion,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]interface{}{"name": "one-mcp-demo", "version": "1.0.0"},
+		}, nil)
+	case "ping":
+		t.reply(req.ID, map[string]interface{}{}, nil)
+	case "tools/list":
+		t.reply(req.ID, map[string]interface{}{"tools": demoToolList()}, nil)
+	case "tools/call":
+		t.reply(req.ID, callDemoTool(req.Params), nil)
+	default:
+		if req.ID != nil {
+			t.reply(req.ID, nil, &JSONRPCError{Code: -32601, Message: "Method not found"})
+		}
+	}
+	return nil
+}
+
+func (t *DemoTransport) Close() error {
+	return nil
+}
+
+func (t *DemoTransport) reply(id *json.RawMessage, result interface{}, rpcErr *JSONRPCError) {
+	t.mu.Lock()
+	onMessage := t.onMessage
+	t.mu.Unlock()
+	if id == nil || onMessage == nil {
+		return
+	}
+	resp := JSONRPCMessage{JSONRPC: "2.0", ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		resp.Result, _ = json.Marshal(result)
+	}
+	payload, _ := json.Marshal(resp)
+	onMessage(payload)
+}
+
+func demoToolList() []interface{} {
+	names := make([]string, 0, len(demoTools))
+	for name := range demoTools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tools := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		tool := demoTools[name]
+		tools = append(tools, map[string]interface{}{
+			"name":        name,
+			"description": tool.Description,
+			"inputSchema": parameterSchema(ToolParameter{Type: "object", Properties: tool.Parameters}),
+			// Demo tools compute their answer locally and change nothing
+			"annotations": map[string]interface{}{"readOnlyHint": true, "openWorldHint": false},
+		})
+	}
+	return tools
+}
+
+func callDemoTool(paramsRaw json.RawMessage) map[string]interface{} {
+	var params struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	}
+	json.Unmarshal(paramsRaw, &params)
+
+	text, err := "", fmt.Errorf("unknown tool %q", params.Name)
+	if tool, ok := demoTools[params.Name]; ok {
+		var args map[string]interface{}
+		if args, err = coerceArgs(tool.Parameters, params.Arguments); err == nil {
+			text, err = tool.Run(args)
+		}
+	}
+	if err != nil {
+		return map[string]interface{}{
+			"content": []interface{}{map[string]interface{}{"type": "text", "text": err.Error()}},
+			"isError": true,
+		}
+	}
+	return map[string]interface{}{
+		"content": []interface{}{map[string]interface{}{"type": "text", "text": text}},
+	}
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDemoTools(t *testing.T) {
+	call := func(name string, args map[string]interface{}) (string, bool) {
+		params, _ := json.Marshal(map[string]interface{}{"name": name, "arguments": args})
+		result := callDemoTool(params)
+		text := result["content"].([]interface{})[0].(map[string]interface{})["text"].(string)
+		return text, result["isError"] == true
+	}
+
+	text, isErr := call("calculator", map[string]interface{}{"a": "6", "b": 7, "operation": "multiply"})
+	assert.False(t, isErr)
+	assert.Equal(t, "42", text)
+
+	_, isErr = call("calculator", map[string]interface{}{"a": 1, "b": 0, "operation": "divide"})
+	assert.True(t, isErr)
+
+	text, _ = call("echo", map[string]interface{}{"text": "hello"})
+	assert.Equal(t, "hello", text)
+
+	first, _ := call("weather", map[string]interface{}{"city": "Paris"})
+	second, _ := call("weather", map[string]interface{}{"city": "paris"})
+	var a, b map[string]interface{}
+	json.Unmarshal([]byte(first), &a)
+	json.Unmarshal([]byte(second), &b)
+	assert.Equal(t, a["temperature_c"], b["temperature_c"])
+	assert.Equal(t, a["conditions"], b["conditions"])
+	assert.Equal(t, true, a["demo"])
+
+	_, isErr = call("time", map[string]interface{}{"timezone": "Not/AZone"})
+	assert.True(t, isErr)
+
+	text, isErr = call("missing", nil)
+	assert.True(t, isErr)
+	assert.Contains(t, text, "unknown tool")
+
+	assert.Len(t, demoToolList(), len(demoTools))
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// EgressPolicy restricts the hosts the gateway connects to for upstream traffic,
+// from EGRESS_ALLOWLIST. Entries are host names, which may start with "*." to
+// match subdomains, IP addresses or CIDR ranges. A nil policy allows every host.
+type EgressPolicy struct {
+	hosts []string // Lower-case names, "*.example.com" matches subdomains only
+	nets  []*net.IPNet
+}
+
+// ParseEgressPolicy builds the policy of the allowlist entries, nil if there are none.
+func ParseEgressPolicy(entries []string) (*EgressPolicy, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	p := &EgressPolicy{}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(entry), "."))
+		if strings.Contains(entry, "/") {
+			_, n, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q", entry)
+			}
+			p.nets = append(p.nets, n)
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			p.nets = append(p.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		name := strings.TrimPrefix(entry, "*.")
+		if name == "" || strings.ContainsAny(name, "*:/ @") {
+			return nil, fmt.Errorf("invalid host %q", entry)
+		}
+		p.hosts = append(p.hosts, entry)
+	}
+	return p, nil
+}
+
+// egressPolicy returns the policy of the gateway settings. Entries were checked
+// when the configuration was loaded.
+func egressPolicy(entries []string) *EgressPolicy {
+	p, err := ParseEgressPolicy(entries)
+	if err != nil {
+		fmt.Printf("[Egress] Ignoring EGRESS_ALLOWLIST: %v\n", err)
+		return nil
+	}
+	return p
+}
+
+// allowsName reports whether a host name or IP literal is allowed as such.
+func (p *EgressPolicy) allowsName(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if ip := net.ParseIP(host); ip != nil {
+		return p.allowsIP(ip)
+	}
+	for _, allowed := range p.hosts {
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok {
+			if strings.HasSuffix(host, suffix) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *EgressPolicy) allowsIP(ip net.IP) bool {
+	for _, n := range p.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialContext returns the dial function of d, checking every connection: it is
+// allowed if the host name is, or else if the address the name resolved to lies
+// in an allowed range, so DNS cannot point an allowed name at other addresses.
+func (p *EgressPolicy) dialContext(d *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if p == nil {
+		return d.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if p.allowsName(host) {
+			return d.DialContext(ctx, network, addr)
+		}
+		checked := *d
+		checked.Control = func(_, address string, _ syscall.RawConn) error {
+			ip, _, _ := net.SplitHostPort(address)
+			if !p.allowsIP(net.ParseIP(ip)) {
+				return fmt.Errorf("egress to %s (%s) is not allowed by EGRESS_ALLOWLIST", host, ip)
+			}
+			return nil
+		}
+		return checked.DialContext(ctx, network, addr)
+	}
+}
+
+// proxy wraps the proxy function of a transport. Requests through a proxy are
+// checked by their host name alone, since the proxy resolves it.
+func (p *EgressPolicy) proxy(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	if p == nil || proxy == nil {
+		return proxy
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := proxy(req)
+		if err == nil && proxyURL != nil && !p.allowsName(req.URL.Hostname()) {
+			return nil, fmt.Errorf("egress to %s through a proxy is not allowed by EGRESS_ALLOWLIST", req.URL.Hostname())
+		}
+		return proxyURL, err
+	}
+}
+
+// transport returns an HTTP transport enforcing the policy.
+func (p *EgressPolicy) transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = p.dialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+	transport.Proxy = p.proxy(transport.Proxy)
+	return transport
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEgressPolicy(t *testing.T) {
+	for _, entries := range [][]string{{"10.0.0.0/33"}, {"*"}, {"user@host"}, {"host:443"}} {
+		_, err := ParseEgressPolicy(entries)
+		assert.Error(t, err, entries)
+	}
+	p, err := ParseEgressPolicy(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, p, "no entries, no restriction")
+
+	p, err = ParseEgressPolicy([]string{"api.example.com", "*.tools.example.com", "192.0.2.7", "10.0.0.0/8"})
+	assert.NoError(t, err)
+	for host, allowed := range map[string]bool{
+		"api.example.com":       true,
+		"API.example.com.":      true,
+		"example.com":           false,
+		"a.tools.example.com":   true,
+		"tools.example.com":     false,
+		"eviltools.example.com": false,
+		"192.0.2.7":             true,
+		"192.0.2.8":             false,
+		"10.20.30.40":           true,
+	} {
+		assert.Equal(t, allowed, p.allowsName(host), host)
+	}
+
+	t.Run("Proxies", func(t *testing.T) {
+		viaProxy := p.proxy(func(*http.Request) (*url.URL, error) { return url.Parse("http://10.0.0.1:3128") })
+		req, _ := http.NewRequest("GET", "http://internal.example.com/", nil)
+		_, err := viaProxy(req)
+		assert.ErrorContains(t, err, "not allowed by EGRESS_ALLOWLIST", "the proxy cannot vouch for the name")
+		req, _ = http.NewRequest("GET", "http://api.example.com/", nil)
+		proxyURL, err := viaProxy(req)
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.0.1:3128", proxyURL.Host)
+	})
+}
+
+func TestEgressEnforcement(t *testing.T) {
+	srv := httptest.NewServer(endpointServer())
+	defer srv.Close()
+	// Same server, reached by name
+	byName := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+
+	connect := func(allowlist []string, rawURL string) error {
+		settings := &config.Config{MaxMessageSize: 1024, EgressAllowlist: allowlist}
+		return startSSE(NewSSETransport(model.UpstreamServer{Name: "up", URL: rawURL + "/sse"}, settings))
+	}
+	assert.NoError(t, connect(nil, srv.URL))
+	assert.NoError(t, connect([]string{"127.0.0.1"}, srv.URL))
+	assert.NoError(t, connect([]string{"localhost"}, byName))
+	assert.NoError(t, connect([]string{"127.0.0.0/8", "::1"}, byName), "names resolving to allowed ranges")
+	assert.ErrorContains(t, connect([]string{"10.0.0.0/8"}, srv.URL), "not allowed by EGRESS_ALLOWLIST")
+	assert.ErrorContains(t, connect([]string{"api.example.com"}, byName), "not allowed by EGRESS_ALLOWLIST")
+
+	t.Run("HTTP Tools", func(t *testing.T) {
+		tr := NewHTTPTransport(model.UpstreamServer{Name: "web"}, &config.Config{EgressAllowlist: []string{"api.example.com"}}, nil)
+		_, err := tr.Client.Get(srv.URL)
+		assert.ErrorContains(t, err, "not allowed by EGRESS_ALLOWLIST")
+	})
+}
@@ -0,0 +1,212 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSecurityWarnings bounds the warnings kept per upstream; older ones are dropped.
+const maxSecurityWarnings = 20
+
+// Kinds of security warnings.
+const (
+	WarningCertificate = "certificate" // The upstream presented a certificate not seen before
+	WarningAddress     = "address"     // The upstream host resolved to an address not seen before
+	WarningRedirect    = "redirect"    // The upstream sent the gateway to another host
+)
+
+// SecurityWarning reports an unexpected change of an upstream's endpoint, which
+// may mean the upstream was hijacked, e.g. through DNS.
+type SecurityWarning struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Message string    `json:"message"`
+}
+
+// endpointWatch remembers the certificates and addresses an SSE upstream was
+// reached at and warns when a connection uses new ones. The first connection
+// is trusted. With a pinned certificate, connections presenting another one fail.
+// Legitimate changes, e.g. certificate renewals or DNS round-robin, warn too.
+type endpointWatch struct {
+	name string
+	host string // Host of the configured URL
+	pin  string // Pinned SHA-256 of the leaf certificate, hex-encoded, or ""
+
+	mu       sync.Mutex
+	certs    map[string]bool
+	addrs    map[string]bool
+	warnings []SecurityWarning
+}
+
+func newEndpointWatch(name, rawURL, pin string) *endpointWatch {
+	w := &endpointWatch{
+		name:  name,
+		certs: make(map[string]bool),
+		addrs: make(map[string]bool),
+	}
+	if u, err := url.Parse(rawURL); err == nil {
+		w.host = u.Hostname()
+	}
+	w.pin, _ = ParseCertPin(pin)
+	return w
+}
+
+// ParseCertPin normalizes a pinned certificate fingerprint: the SHA-256 of the
+// DER-encoded leaf certificate in hex, optionally colon-separated as printed by
+// openssl. An empty pin is valid and pins nothing.
+func ParseCertPin(pin string) (string, error) {
+	pin = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(pin), ":", ""))
+	if pin == "" {
+		return "", nil
+	}
+	if b, err := hex.DecodeString(pin); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("pinned certificate must be a hex-encoded SHA-256 fingerprint")
+	}
+	return pin, nil
+}
+
+func (w *endpointWatch) warn(kind string, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	fmt.Printf("[SSETransport %s] SECURITY WARNING: %s\n", w.name, message)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.warnings = append(w.warnings, SecurityWarning{Time: time.Now(), Kind: kind, Message: message})
+	if len(w.warnings) > maxSecurityWarnings {
+		w.warnings = w.warnings[len(w.warnings)-maxSecurityWarnings:]
+	}
+}
+
+// seen records value in set and reports whether it is new while set was not
+// empty, i.e. a change since the first connection.
+func (w *endpointWatch) seen(set map[string]bool, value string) (changed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if set[value] {
+		return false
+	}
+	changed = len(set) > 0
+	set[value] = true
+	return changed
+}
+
+// verifyConnection checks the certificate of every TLS connection to the upstream.
+func (w *endpointWatch) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+	sum := sha256.Sum256(cs.PeerCertificates[0].Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+	if w.pin != "" && fingerprint != w.pin {
+		w.warn(WarningCertificate, "%s presented certificate %s, not the pinned %s", cs.ServerName, fingerprint, w.pin)
+		return fmt.Errorf("certificate of %s does not match the pinned certificate", cs.ServerName)
+	}
+	if w.seen(w.certs, fingerprint) {
+		w.warn(WarningCertificate, "%s presented a new certificate %s", cs.ServerName, fingerprint)
+	}
+	return nil
+}
+
+// observeAddr checks the address a new connection to the upstream reached.
+func (w *endpointWatch) observeAddr(host string, addr net.Addr) {
+	ip, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		ip = addr.String()
+	}
+	if w.seen(w.addrs, ip) {
+		w.warn(WarningAddress, "%s resolved to a new address %s", host, ip)
+	}
+}
+
+// checkHost warns when the gateway is sent to another host than the configured
+// one, by a redirect or by the endpoint event.
+func (w *endpointWatch) checkHost(target *url.URL, via string) {
+	if w.host != "" && !strings.EqualFold(target.Hostname(), w.host) {
+		w.warn(WarningRedirect, "%s sent the gateway from %s to %s", via, w.host, target.Host)
+	}
+}
+
+// client returns an HTTP client watching every connection it makes, within the
+// egress policy. tlsConfig, if not nil, holds the client certificate and CAs,
+// and proxyURL, if not nil, replaces the process-wide proxy.
+func (w *endpointWatch) client(egress *EgressPolicy, tlsConfig *tls.Config, proxyURL *url.URL) *http.Client {
+	dial := egress.dialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+	transport := egress.transport()
+	egress.setProxy(transport, proxyURL)
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err == nil {
+			host, _, _ := net.SplitHostPort(addr)
+			w.observeAddr(host, conn.RemoteAddr())
+		}
+		return conn, err
+	}
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	tlsConfig.VerifyConnection = w.verifyConnection
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			w.checkHost(req.URL, "a redirect")
+			return nil
+		},
+	}
+}
+
+// Warnings returns the security warnings recorded so far, oldest first.
+func (w *endpointWatch) Warnings() []SecurityWarning {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]SecurityWarning(nil), w.warnings...)
+}
+
+// keepEndpointHistory carries the certificates, addresses and warnings seen by
+// the client c replaces over to c, so that reloading the configuration does not
+// trust a changed endpoint like a first connection. Only kept for the same URL.
+func (c *UpstreamClient) keepEndpointHistory(old *UpstreamClient) {
+	if old == nil {
+		return
+	}
+	watch, prev := transportWatch(c.transport), transportWatch(old.transport)
+	if watch == nil || prev == nil || c.Config.URL != old.Config.URL {
+		return
+	}
+
+	prev.mu.Lock()
+	defer prev.mu.Unlock()
+	watch.mu.Lock()
+	defer watch.mu.Unlock()
+	for cert := range prev.certs {
+		watch.certs[cert] = true
+	}
+	for addr := range prev.addrs {
+		watch.addrs[addr] = true
+	}
+	watch.warnings = append([]SecurityWarning(nil), prev.warnings...)
+}
+
+// transportWatch returns the endpoint watch of HTTP-based MCP transports, nil
+// for the others.
+func transportWatch(t Transport) *endpointWatch {
+	switch t := t.(type) {
+	case *SSETransport:
+		return t.watch
+	case *StreamableHTTPTransport:
+		return t.watch
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func endpointServer() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: endpoint\ndata: /messages\n\n")
+	})
+}
+
+// startSSE runs the transport until the upstream closes the stream.
+func startSSE(tr *SSETransport) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return tr.Start(ctx, func([]byte) {}, nil)
+}
+
+func TestEndpointWatchPinning(t *testing.T) {
+	srv := httptest.NewTLSServer(endpointServer())
+	defer srv.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	sum := sha256.Sum256(srv.Certificate().Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+	settings := &config.Config{MaxMessageSize: 1024}
+
+	newTransport := func(pin string) *SSETransport {
+		tr := NewSSETransport(model.UpstreamServer{Name: "pinned", URL: srv.URL + "/sse", PinnedCertSHA256: pin}, settings)
+		tr.Client.Transport.(*http.Transport).TLSClientConfig.RootCAs = pool
+		return tr
+	}
+
+	t.Run("Matching Pin", func(t *testing.T) {
+		// openssl prints fingerprints upper-case and colon-separated
+		var pairs []string
+		for i := 0; i < len(fingerprint); i += 2 {
+			pairs = append(pairs, strings.ToUpper(fingerprint[i:i+2]))
+		}
+		tr := newTransport(strings.Join(pairs, ":"))
+		assert.NoError(t, startSSE(tr))
+		assert.Empty(t, tr.SecurityWarnings())
+	})
+
+	t.Run("Mismatched Pin", func(t *testing.T) {
+		tr := newTransport(strings.Repeat("0", 64))
+		assert.ErrorContains(t, startSSE(tr), "does not match the pinned certificate")
+		warnings := tr.SecurityWarnings()
+		assert.Len(t, warnings, 1)
+		assert.Equal(t, WarningCertificate, warnings[0].Kind)
+		assert.Contains(t, warnings[0].Message, fingerprint)
+	})
+
+	t.Run("Pin Without TLS", func(t *testing.T) {
+		plain := httptest.NewServer(endpointServer())
+		defer plain.Close()
+		tr := NewSSETransport(model.UpstreamServer{Name: "plain", URL: plain.URL + "/sse", PinnedCertSHA256: fingerprint}, settings)
+		assert.ErrorContains(t, startSSE(tr), "without TLS")
+	})
+}
+
+func TestEndpointWatchChanges(t *testing.T) {
+	w := newEndpointWatch("up", "https://mcp.example.com/sse", "")
+
+	// The first certificate and address are trusted, known ones stay quiet
+	w.verifyConnection(tls.ConnectionState{ServerName: "mcp.example.com", PeerCertificates: []*x509.Certificate{{Raw: []byte("a")}}})
+	w.verifyConnection(tls.ConnectionState{ServerName: "mcp.example.com", PeerCertificates: []*x509.Certificate{{Raw: []byte("a")}}})
+	assert.Empty(t, w.Warnings())
+	w.verifyConnection(tls.ConnectionState{ServerName: "mcp.example.com", PeerCertificates: []*x509.Certificate{{Raw: []byte("b")}}})
+	assert.Len(t, w.Warnings(), 1)
+	assert.Equal(t, WarningCertificate, w.Warnings()[0].Kind)
+
+	for i := 0; i < maxSecurityWarnings+5; i++ {
+		w.verifyConnection(tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Raw: []byte{byte(i)}}}})
+	}
+	assert.Len(t, w.Warnings(), maxSecurityWarnings, "old warnings are dropped")
+}
+
+func TestEndpointWatchRedirects(t *testing.T) {
+	target := httptest.NewServer(endpointServer())
+	defer target.Close()
+	// Same server, but reached through another host name
+	moved := strings.Replace(target.URL, "127.0.0.1", "localhost", 1)
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, moved+"/sse", http.StatusFound)
+	}))
+	defer origin.Close()
+
+	tr := NewSSETransport(model.UpstreamServer{Name: "moved", URL: origin.URL + "/sse"}, &config.Config{MaxMessageSize: 1024})
+	assert.NoError(t, startSSE(tr))
+	assert.Equal(t, moved+"/messages", tr.Endpoint)
+
+	var kinds []string
+	for _, warning := range tr.SecurityWarnings() {
+		kinds = append(kinds, warning.Kind)
+	}
+	// The redirect, then the endpoint on the other host
+	assert.Equal(t, []string{WarningRedirect, WarningRedirect}, kinds)
+}
+
+func TestKeepEndpointHistory(t *testing.T) {
+	cfg := model.UpstreamServer{Name: "up", TransportType: "sse", URL: "https://mcp.example.com/sse"}
+	settings := &config.Config{}
+	old := NewUpstreamClient(cfg, settings, nil)
+	old.transport.(*SSETransport).watch.seen(old.transport.(*SSETransport).watch.addrs, "192.0.2.1")
+
+	reloaded := NewUpstreamClient(cfg, settings, nil)
+	reloaded.keepEndpointHistory(old)
+	w := reloaded.transport.(*SSETransport).watch
+	assert.False(t, w.seen(w.addrs, "192.0.2.1"))
+	assert.True(t, w.seen(w.addrs, "192.0.2.2"), "a new address after a reload is still a change")
+
+	cfg.URL = "https://elsewhere.example.com/sse"
+	moved := NewUpstreamClient(cfg, settings, nil)
+	moved.keepEndpointHistory(old)
+	w = moved.transport.(*SSETransport).watch
+	assert.False(t, w.seen(w.addrs, "192.0.2.1"), "history is not kept for another URL")
+}
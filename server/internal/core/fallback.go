@@ -0,0 +1,110 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/model"
+	"path"
+)
+
+// ParseToolFallbacks decodes the per-tool fallbacks of an upstream: a JSON object
+// of tool names or glob patterns to server names, e.g. {"search_*": "search-replica"}.
+func ParseToolFallbacks(cfg model.UpstreamServer) (map[string]string, error) {
+	if cfg.ToolFallbacks == "" {
+		return nil, nil
+	}
+	var fallbacks map[string]string
+	if err := json.Unmarshal([]byte(cfg.ToolFallbacks), &fallbacks); err != nil {
+		return nil, fmt.Errorf("invalid tool_fallbacks: %v", err)
+	}
+	for pattern, server := range fallbacks {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid tool fallback pattern %q: %v", pattern, err)
+		}
+		if server == "" || server == cfg.Name {
+			return nil, fmt.Errorf("fallback of %q must be another server", pattern)
+		}
+	}
+	return fallbacks, nil
+}
+
+// ToolFallback returns the server a failed call of the (unprefixed) tool is retried
+// on: its override in tool_fallbacks, like ToolTimeout, or the fallback server.
+func (c *UpstreamClient) ToolFallback(name string) string {
+	if server, ok := matchToolOverride(c.toolFallbacks, name); ok {
+		return server
+	}
+	return c.Config.FallbackServer
+}
+
+// fallbackCause returns why a call answered with resp or failed with err is retried
+// on the fallback: a transport error or timeout, or a JSON-RPC internal or server
+// error (-32603, -32000 to -32099). Results with isError set are the tool's own
+// answer, which the fallback serving the same tool would repeat, and are returned
+// like other JSON-RPC errors such as invalid params.
+func fallbackCause(resp *JSONRPCMessage, err error) error {
+	if err != nil {
+		if err == errCancelled {
+			return nil
+		}
+		return err
+	}
+	if resp.Error != nil && (resp.Error.Code == -32603 || resp.Error.Code <= -32000 && resp.Error.Code >= -32099) {
+		return fmt.Errorf("upstream error %d: %s", resp.Error.Code, resp.Error.Message)
+	}
+	return nil
+}
+
+// callFallback retries a failed call of toolName on the fallback upstream configured for
+// primary, if the caller may call the tool there and it is exposed and not under
+// maintenance. The call is bounded by the fallback's own timeout for the tool and
+// abandoned if the client cancels req. On success the result is annotated in _meta
+// so clients can tell which server served it.
+func (g *Gateway) callFallback(req *JSONRPCMessage, primary *UpstreamClient, caller *Caller, hasPermission func(string, string) bool, toolName string, params map[string]interface{}, cause error) (*JSONRPCMessage, error) {
+	name := primary.ToolFallback(toolName)
+	if name == "" || name == primary.Config.Name {
+		return nil, cause
+	}
+
+	fallback, ok := g.upstream(name)
+	if !ok {
+		fmt.Printf("[Gateway] Fallback %s for upstream %s is not available\n", name, primary.Config.Name)
+		return nil, cause
+	}
+
+	if !fallback.ToolSelected(toolName) {
+		fmt.Printf("[Gateway] Fallback %s does not expose %s\n", name, toolName)
+		return nil, cause
+	}
+	if !hasPermission(fmt.Sprintf("%d", fallback.Config.ID), name+"__"+toolName) {
+		fmt.Printf("[Gateway] Key %d may not call %s on fallback %s\n", caller.KeyID, toolName, name)
+		return nil, cause
+	}
+	if caller.ReadOnlyTools {
+		if annotations, _ := fallback.ToolAnnotations(toolName); !annotations.ReadOnly() {
+			return nil, cause
+		}
+	}
+	if err := g.maintenanceError(fallback); err != nil {
+		fmt.Printf("[Gateway] Fallback %s unavailable: %v\n", name, err)
+		return nil, cause
+	}
+
+	fmt.Printf("[Gateway] Upstream %s failed (%v), retrying on fallback %s\n", primary.Config.Name, cause, name)
+	resp, err := fallback.CallAsCancelable(caller.Key(), "tools/call", params, fallback.ToolTimeout(toolName), g.requestCancellation(caller, req))
+	if err == errCancelled {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v (fallback %s: %v)", cause, name, err)
+	}
+
+	if resp.Result != nil {
+		resp.Result = annotateResult(resp.Result, "one-mcp/fallback", map[string]interface{}{
+			"primary":  primary.Config.Name,
+			"servedBy": name,
+			"reason":   cause.Error(),
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"encoding/json"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestFallback(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.UsageLog{})
+
+	primary := newResultClient("primary", 1, `{"content":[]}`)
+	primary.Config.FallbackServer = "backup"
+	primary.selector = &ToolSelector{}
+	backup := newResultClient("backup", 2, `{"content":[{"type":"text","text":"from backup"}]}`)
+	backup.selector = &ToolSelector{}
+	g := &Gateway{
+		db: db, settings: &config.Config{},
+		upstreams:   map[uint]*UpstreamClient{1: primary, 2: backup},
+		upstreamIDs: map[string]uint{"primary": 1, "backup": 2},
+	}
+	// The primary is under maintenance, so every call falls back
+	window := func(serverID uint) model.MaintenanceWindow {
+		return model.MaintenanceWindow{ServerID: serverID, StartsAt: time.Now().Add(-time.Minute), EndsAt: time.Now().Add(time.Hour)}
+	}
+	g.maintenance = map[uint][]model.MaintenanceWindow{1: {window(1)}}
+	call := func(caller *Caller) *JSONRPCMessage {
+		return g.callUpstreamTool(&JSONRPCMessage{}, caller, g.permissionCheck(caller), primary, "quote", "primary__quote", nil, time.Second)
+	}
+
+	t.Run("Served By Fallback", func(t *testing.T) {
+		resp := call(&Caller{})
+		assert.Nil(t, resp.Error)
+		assert.Contains(t, string(resp.Result), "from backup")
+		assert.Contains(t, string(resp.Result), `"servedBy":"backup"`)
+	})
+
+	t.Run("Denied On Fallback", func(t *testing.T) {
+		resp := call(&Caller{AllowedServers: []string{"1"}})
+		assert.NotNil(t, resp.Error)
+		assert.Contains(t, resp.Error.Message, "maintenance")
+
+		resp = call(&Caller{AllowedTools: []string{"primary__quote"}})
+		assert.NotNil(t, resp.Error)
+	})
+
+	t.Run("Fallback Not Exposing Tool", func(t *testing.T) {
+		backup.selector = &ToolSelector{Exclude: []string{"quote"}}
+		defer func() { backup.selector = &ToolSelector{} }()
+		assert.NotNil(t, call(&Caller{}).Error)
+	})
+
+	t.Run("Fallback Under Maintenance", func(t *testing.T) {
+		g.maintenance[2] = []model.MaintenanceWindow{window(2)}
+		defer delete(g.maintenance, 2)
+		assert.NotNil(t, call(&Caller{}).Error)
+	})
+
+	t.Run("Fallback Tool Timeout", func(t *testing.T) {
+		transport := backup.transport.(*resultTransport)
+		transport.hang = true
+		backup.toolTimeouts = map[string]time.Duration{"quote": 50 * time.Millisecond}
+		defer func() { transport.hang, backup.toolTimeouts = false, nil }()
+
+		started := time.Now()
+		resp := call(&Caller{})
+		if assert.NotNil(t, resp.Error) {
+			assert.Contains(t, resp.Error.Message, "fallback backup: timeout")
+		}
+		assert.Less(t, time.Since(started), 500*time.Millisecond, "bounded by the fallback's tool timeout")
+	})
+
+	t.Run("Upstream Errors", func(t *testing.T) {
+		delete(g.maintenance, 1)
+		defer func() { g.maintenance[1] = []model.MaintenanceWindow{window(1)} }()
+		transport := primary.transport.(*resultTransport)
+		defer func() { transport.rpcErr = nil }()
+
+		transport.rpcErr = &JSONRPCError{Code: -32603, Message: "database unavailable"}
+		resp := call(&Caller{})
+		assert.Nil(t, resp.Error, "internal errors fall back")
+		assert.Contains(t, string(resp.Result), `"reason":"upstream error -32603: database unavailable"`)
+
+		transport.rpcErr = &JSONRPCError{Code: -32001, Message: "overloaded"}
+		assert.Nil(t, call(&Caller{}).Error, "server errors fall back")
+
+		transport.rpcErr = &JSONRPCError{Code: -32602, Message: "missing symbol"}
+		resp = call(&Caller{})
+		assert.Equal(t, "missing symbol", resp.Error.Message, "invalid params are returned")
+
+		transport.rpcErr = &JSONRPCError{Code: -32603, Message: "database unavailable"}
+		backup.selector = &ToolSelector{Exclude: []string{"quote"}}
+		defer func() { backup.selector = &ToolSelector{} }()
+		resp = call(&Caller{})
+		assert.Equal(t, -32603, resp.Error.Code, "the primary's error is kept if the fallback cannot serve")
+	})
+
+	t.Run("Tool Errors", func(t *testing.T) {
+		delete(g.maintenance, 1)
+		defer func() { g.maintenance[1] = []model.MaintenanceWindow{window(1)} }()
+		transport := primary.transport.(*resultTransport)
+		defer func() { transport.result = json.RawMessage(`{"content":[]}`) }()
+
+		transport.result = json.RawMessage(`{"content":[{"type":"text","text":"unknown symbol"}],"isError":true}`)
+		resp := call(&Caller{})
+		assert.Nil(t, resp.Error)
+		assert.True(t, resultIsError(resp.Result), "isError results are the tool's own answer")
+		assert.NotContains(t, string(resp.Result), "from backup")
+	})
+}
+
+func TestToolFallbacks(t *testing.T) {
+	cfg := model.UpstreamServer{Name: "search", FallbackServer: "backup", ToolFallbacks: `{"index_*": "indexer", "index_docs": "docs"}`}
+	fallbacks, err := ParseToolFallbacks(cfg)
+	assert.NoError(t, err)
+	client := &UpstreamClient{Config: cfg, toolFallbacks: fallbacks}
+	assert.Equal(t, "docs", client.ToolFallback("index_docs"))
+	assert.Equal(t, "indexer", client.ToolFallback("index_code"))
+	assert.Equal(t, "backup", client.ToolFallback("query"))
+
+	for _, invalid := range []string{`["backup"]`, `{"[a-": "backup"}`, `{"query": ""}`, `{"query": "search"}`} {
+		cfg.ToolFallbacks = invalid
+		_, err := ParseToolFallbacks(cfg)
+		assert.Error(t, err, invalid)
+	}
+}
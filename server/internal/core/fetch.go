@@ -0,0 +1,239 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"one-mcp/internal/config"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// FetchServer is the server prefix of the fetch tool enabled by FETCH_ENABLED,
+// named like the reference fetch MCP server it replaces. It is not served while
+// an upstream with this name is configured.
+const FetchServer = "fetch"
+
+// fetchMaxRedirects bounds the redirects followed by one fetch.
+const fetchMaxRedirects = 5
+
+// deniedNetworks are the ranges the fetch tool never connects to, besides
+// loopback, private, link-local, multicast and unspecified addresses.
+var deniedNetworks = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",     // "This" network
+		"100.64.0.0/10", // Carrier-grade NAT
+		"192.0.0.0/24",  // IETF protocol assignments
+		"198.18.0.0/15", // Benchmarking
+		"240.0.0.0/4",   // Reserved, and broadcast
+		"64:ff9b::/96",  // NAT64, which embeds IPv4 addresses
+	} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// publicIP reports whether ip is a public address the fetch tool may connect to.
+func publicIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, n := range deniedNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// fetcher performs the requests of the fetch tool. Every connection is checked
+// against allowIP once the host name is resolved, so neither DNS rebinding nor
+// redirects reach internal addresses, and environment proxies are not used.
+type fetcher struct {
+	settings *config.Config
+	client   *http.Client
+}
+
+func newFetcher(settings *config.Config, allowIP func(net.IP) bool) *fetcher {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); !allowIP(ip) {
+				return fmt.Errorf("address %s is not allowed", host)
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		Proxy:               nil,
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     30 * time.Second,
+	}
+	return &fetcher{
+		settings: settings,
+		client: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > fetchMaxRedirects {
+					return fmt.Errorf("stopped after %d redirects", fetchMaxRedirects)
+				}
+				return checkFetchURL(req.URL)
+			},
+		},
+	}
+}
+
+func checkFetchURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("only http and https URLs can be fetched")
+	}
+	if u.User != nil {
+		return fmt.Errorf("URLs with credentials cannot be fetched")
+	}
+	return nil
+}
+
+// contentTypeAllowed matches a media type against FETCH_CONTENT_TYPES, whose
+// entries may end in a wildcard, e.g. "text/*".
+func (f *fetcher) contentTypeAllowed(mediaType string) bool {
+	for _, pattern := range f.settings.FetchContentTypes {
+		if ok, _ := path.Match(strings.ToLower(pattern), mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fetch retrieves rawURL and returns its body as text, cut at FETCH_MAX_BYTES.
+func (f *fetcher) fetch(ctx context.Context, rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %v", err)
+	}
+	if err := checkFetchURL(u); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.settings.FetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "one-mcp-gateway")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !f.contentTypeAllowed(mediaType) {
+		return "", fmt.Errorf("content type %q is not allowed", mediaType)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(f.settings.FetchMaxBytes)+1))
+	if err != nil {
+		return "", err
+	}
+	truncated := len(body) > f.settings.FetchMaxBytes
+	if truncated {
+		body = body[:f.settings.FetchMaxBytes]
+	}
+
+	text := string(body)
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("%s returned %s: %s", resp.Request.URL, resp.Status, text)
+	}
+	if truncated {
+		text += fmt.Sprintf("\n\n[Content truncated at %d bytes]", f.settings.FetchMaxBytes)
+	}
+	return text, nil
+}
+
+var fetchParameters = []ToolParameter{
+	{Name: "url", Type: "string", Required: true, Description: "http or https URL of a public page"},
+}
+
+// fetchServed reports whether the fetch tool is served: FETCH_ENABLED is set and
+// no upstream took its name.
+func (g *Gateway) fetchServed() bool {
+	if g.settings == nil || !g.settings.FetchEnabled {
+		return false
+	}
+	_, taken := g.upstream(FetchServer)
+	return !taken
+}
+
+// fetchToolList returns the fetch tool as a tool definition if the caller may use it.
+func (g *Gateway) fetchToolList(hasPermission func(string, string) bool) []map[string]interface{} {
+	name := FetchServer + "__fetch"
+	if !g.fetchServed() || !hasPermission(FetchServer, name) {
+		return nil
+	}
+	return []map[string]interface{}{{
+		"name":        name,
+		"description": fmt.Sprintf("Fetches a public web page or document and returns its content as text (at most %d bytes).", g.settings.FetchMaxBytes),
+		"inputSchema": parameterSchema(ToolParameter{Type: "object", Properties: fetchParameters}),
+		"annotations": map[string]interface{}{"readOnlyHint": true, "openWorldHint": true},
+	}}
+}
+
+// runFetchTool serves a call of the fetch tool. Refused and failed fetches are
+// tool results with isError set. Like upstream results, fetched content is
+// recorded and then formatted, moderated and signed for the caller.
+func (g *Gateway) runFetchTool(req *JSONRPCMessage, caller *Caller, hasPermission func(string, string) bool, name string, input interface{}) *JSONRPCMessage {
+	if name != "fetch" {
+		return &JSONRPCMessage{
+			JSONRPC: "2.0", ID: req.ID,
+			Error: &JSONRPCError{Code: -32602, Message: "Tool not found"},
+		}
+	}
+	if !hasPermission(FetchServer, FetchServer+"__fetch") {
+		return &JSONRPCMessage{
+			JSONRPC: "2.0", ID: req.ID,
+			Error: &JSONRPCError{Code: -32000, Message: "Permission denied"},
+		}
+	}
+
+	started := time.Now()
+	argMap, _ := input.(map[string]interface{})
+	var text string
+	args, err := coerceArgs(fetchParameters, argMap)
+	if err == nil {
+		rawURL, _ := args["url"].(string)
+		fmt.Printf("[Fetch] Key %d fetching %s\n", caller.KeyID, rawURL)
+		text, err = g.fetcher.fetch(context.Background(), rawURL)
+	}
+	result := map[string]interface{}{
+		"content": []interface{}{map[string]interface{}{"type": "text", "text": text}},
+	}
+	if err != nil {
+		fmt.Printf("[Fetch] Fetch for key %d failed: %v\n", caller.KeyID, err)
+		result = map[string]interface{}{
+			"content": []interface{}{map[string]interface{}{"type": "text", "text": err.Error()}},
+			"isError": true,
+		}
+	}
+	resp := &JSONRPCMessage{JSONRPC: "2.0", ID: req.ID}
+	resp.Result, _ = json.Marshal(result)
+	fullName := FetchServer + "__fetch"
+	g.recordUsage(caller, FetchServer, fullName, input, resp, nil, started)
+	g.processToolResult(resp, caller, fullName, caller.OutputFormat)
+	return resp
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestPublicIP(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0", "::1", "fd00::1", "fe80::1", "::ffff:127.0.0.1", "64:ff9b::a00:1"} {
+		assert.False(t, publicIP(net.ParseIP(addr)), addr)
+	}
+	for _, addr := range []string{"8.8.8.8", "1.1.1.1", "2606:4700::1111"} {
+		assert.True(t, publicIP(net.ParseIP(addr)), addr)
+	}
+}
+
+func TestFetcher(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, "<p>hello</p>")
+		case "/large":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, strings.Repeat("x", 64))
+		case "/binary":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte{0, 1, 2})
+		case "/redirect":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			http.Error(w, "gone", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	settings := &config.Config{FetchTimeout: 5 * time.Second, FetchMaxBytes: 32, FetchContentTypes: []string{"text/*", "application/json"}}
+	f := newFetcher(settings, func(net.IP) bool { return true })
+
+	text, err := f.fetch(context.Background(), srv.URL+"/page")
+	assert.NoError(t, err)
+	assert.Equal(t, "<p>hello</p>", text)
+
+	text, err = f.fetch(context.Background(), srv.URL+"/large")
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("x", 32)+"\n\n[Content truncated at 32 bytes]", text)
+
+	_, err = f.fetch(context.Background(), srv.URL+"/binary")
+	assert.ErrorContains(t, err, `content type "application/octet-stream" is not allowed`)
+	_, err = f.fetch(context.Background(), srv.URL+"/missing")
+	assert.ErrorContains(t, err, "404")
+	_, err = f.fetch(context.Background(), srv.URL+"/redirect")
+	assert.ErrorContains(t, err, "only http and https")
+	_, err = f.fetch(context.Background(), "ftp://example.com/file")
+	assert.ErrorContains(t, err, "only http and https")
+	_, err = f.fetch(context.Background(), strings.Replace(srv.URL, "http://", "http://user:pass@", 1)+"/page")
+	assert.ErrorContains(t, err, "credentials")
+
+	guarded := newFetcher(settings, publicIP)
+	_, err = guarded.fetch(context.Background(), srv.URL+"/page")
+	assert.ErrorContains(t, err, "is not allowed", "loopback is refused after resolution")
+}
+
+func TestFetchTool(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(model.All...))
+	settings := &config.Config{FetchEnabled: true, FetchTimeout: 5 * time.Second, FetchMaxBytes: 1024, FetchContentTypes: []string{"text/*"}}
+	g := NewGateway(db, settings)
+	allowAll := func(string, string) bool { return true }
+
+	tools := g.fetchToolList(allowAll)
+	assert.Len(t, tools, 1)
+	assert.Equal(t, "fetch__fetch", tools[0]["name"])
+	assert.Empty(t, g.fetchToolList(func(string, string) bool { return false }))
+
+	params, _ := json.Marshal(map[string]interface{}{"name": "fetch__fetch", "arguments": map[string]interface{}{"url": "http://127.0.0.1:1/"}})
+	resp, err := g.handleToolCall(&JSONRPCMessage{JSONRPC: "2.0", Method: "tools/call", Params: params}, &Caller{KeyID: 1}, allowAll)
+	assert.NoError(t, err)
+	assert.True(t, resultIsError(resp.Result))
+	assert.Contains(t, resultText(resp.Result), "address 127.0.0.1 is not allowed")
+
+	settings.FetchEnabled = false
+	assert.Empty(t, g.fetchToolList(allowAll))
+	resp, _ = g.handleToolCall(&JSONRPCMessage{JSONRPC: "2.0", Method: "tools/call", Params: params}, &Caller{KeyID: 1}, allowAll)
+	assert.Equal(t, "Server not found", resp.Error.Message, "not served when disabled")
+}
+
+func TestFetchToolResultProcessing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "hello")
+	}))
+	defer srv.Close()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(model.All...))
+	settings := &config.Config{FetchEnabled: true, FetchTimeout: 5 * time.Second, FetchMaxBytes: 1024, FetchContentTypes: []string{"text/*"}}
+	g := NewGateway(db, settings)
+	g.fetcher = newFetcher(settings, func(net.IP) bool { return true })
+	allowAll := func(string, string) bool { return true }
+	team := model.Team{Name: "ops", DailyCallQuota: 1}
+	db.Create(&team)
+	caller := &Caller{KeyID: 1, TeamID: team.ID, SigningSecret: "s3cret"}
+
+	params, _ := json.Marshal(map[string]interface{}{"name": "fetch__fetch", "arguments": map[string]interface{}{"url": srv.URL}})
+	resp, err := g.handleToolCall(&JSONRPCMessage{JSONRPC: "2.0", Method: "tools/call", Params: params}, caller, allowAll)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", resultText(resp.Result))
+	assert.NoError(t, VerifyResultSignature(resp.Result, "s3cret"), "fetched content is signed")
+
+	var usage model.UsageLog
+	assert.NoError(t, db.First(&usage).Error)
+	assert.Equal(t, "fetch__fetch", usage.Tool)
+	assert.Equal(t, team.ID, usage.TeamID)
+	assert.True(t, usage.Success)
+
+	resp, _ = g.handleToolCall(&JSONRPCMessage{JSONRPC: "2.0", Method: "tools/call", Params: params}, caller, allowAll)
+	assert.Contains(t, resp.Error.Message, "daily quota", "fetches count against the team quota")
+}
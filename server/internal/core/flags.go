@@ -0,0 +1,215 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/model"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Prefix styles of the tool names announced to clients. Names are always stored
+// and checked as server__tool; other styles only change what clients see.
+const (
+	PrefixDoubleUnderscore = "double_underscore" // server__tool
+	PrefixDot              = "dot"               // server.tool
+	PrefixSlash            = "slash"             // server/tool
+)
+
+var prefixSeparators = map[string]string{
+	PrefixDoubleUnderscore: "__",
+	PrefixDot:              ".",
+	PrefixSlash:            "/",
+}
+
+// flagsReloadInterval is how often WatchFlags picks up flags changed outside
+// this process, e.g. by another instance sharing the database.
+const flagsReloadInterval = 30 * time.Second
+
+// FeatureFlags are gateway behaviors toggled at runtime through /api/v1/settings.
+type FeatureFlags struct {
+	CacheTools        bool   `json:"cache_tools"`        // Reuse upstream tool listings until the upstream reports a change or reconnects
+	StrictPermissions bool   `json:"strict_permissions"` // Keys without server or tool permissions get nothing instead of everything
+	PrefixStyle       string `json:"prefix_style"`       // Separator between server and tool names shown to clients
+	RecordCalls       bool   `json:"record_calls"`       // Store full request/response of tool calls
+	HealthOrder       bool   `json:"health_order"`       // List tools of healthy, fast upstreams first (see health.go)
+}
+
+var flagNames = map[string]bool{"cache_tools": true, "strict_permissions": true, "prefix_style": true, "record_calls": true, "health_order": true}
+
+// defaultFlags returns the flags used for names without a stored override.
+func (g *Gateway) defaultFlags() FeatureFlags {
+	flags := FeatureFlags{PrefixStyle: PrefixDoubleUnderscore}
+	if g.settings != nil {
+		flags.RecordCalls = g.settings.RecordCalls
+	}
+	return flags
+}
+
+// set parses a stored or submitted value into the named flag.
+func (f *FeatureFlags) set(name string, value json.RawMessage) error {
+	var err error
+	switch name {
+	case "cache_tools":
+		err = json.Unmarshal(value, &f.CacheTools)
+	case "strict_permissions":
+		err = json.Unmarshal(value, &f.StrictPermissions)
+	case "record_calls":
+		err = json.Unmarshal(value, &f.RecordCalls)
+	case "health_order":
+		err = json.Unmarshal(value, &f.HealthOrder)
+	case "prefix_style":
+		var style string
+		if err = json.Unmarshal(value, &style); err == nil {
+			if _, ok := prefixSeparators[style]; !ok {
+				return fmt.Errorf("prefix_style must be %s, %s or %s", PrefixDoubleUnderscore, PrefixDot, PrefixSlash)
+			}
+			f.PrefixStyle = style
+		}
+	default:
+		return fmt.Errorf("unknown flag %q", name)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %v", name, err)
+	}
+	return nil
+}
+
+// Flags returns the current feature flags.
+func (g *Gateway) Flags() FeatureFlags {
+	if f := g.flags.Load(); f != nil {
+		return *f
+	}
+	return g.defaultFlags()
+}
+
+// ReloadFlags refreshes the cached feature flags from the database. Stored values
+// that no longer parse are ignored, keeping the default.
+func (g *Gateway) ReloadFlags() {
+	var stored []model.FeatureFlag
+	if err := g.db.Find(&stored).Error; err != nil {
+		fmt.Printf("[Gateway] Failed to load feature flags: %v\n", err)
+		return
+	}
+	flags := g.defaultFlags()
+	for _, s := range stored {
+		if err := flags.set(s.Name, json.RawMessage(s.Value)); err != nil {
+			fmt.Printf("[Gateway] Ignoring feature flag: %v\n", err)
+		}
+	}
+	if old := g.flags.Swap(&flags); old != nil && *old != flags {
+		fmt.Printf("[Gateway] Feature flags changed: %+v\n", flags)
+	}
+}
+
+// WatchFlags reloads the feature flags periodically in the background.
+func (g *Gateway) WatchFlags() {
+	g.ReloadFlags()
+	go func() {
+		ticker := time.NewTicker(flagsReloadInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			g.ReloadFlags()
+		}
+	}()
+}
+
+// UpdateFlags stores the given flag values and applies them at once. A null value
+// resets the flag to its default. Nothing is stored if any value is invalid.
+func (g *Gateway) UpdateFlags(values map[string]json.RawMessage) (FeatureFlags, error) {
+	flags := g.Flags()
+	for name, value := range values {
+		if string(value) == "null" {
+			if !flagNames[name] {
+				return flags, fmt.Errorf("unknown flag %q", name)
+			}
+			continue
+		}
+		if err := flags.set(name, value); err != nil {
+			return flags, err
+		}
+	}
+
+	err := g.db.Transaction(func(tx *gorm.DB) error {
+		for name, value := range values {
+			if string(value) == "null" {
+				if err := tx.Delete(&model.FeatureFlag{Name: name}).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if err := tx.Save(&model.FeatureFlag{Name: name, Value: string(value)}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return g.Flags(), err
+	}
+	g.ReloadFlags()
+	if _, ok := values["prefix_style"]; ok {
+		// Clients must list the tools again to see the new names
+		g.notifyToolsChanged()
+	}
+	return g.Flags(), nil
+}
+
+// displayToolName converts a server__tool name to the configured prefix style.
+func displayToolName(name string, style string) string {
+	sep, ok := prefixSeparators[style]
+	if !ok || sep == "__" {
+		return name
+	}
+	return strings.Replace(name, "__", sep, 1)
+}
+
+// canonicalToolName converts a tool name in the configured prefix style back to
+// server__tool. Server names may contain the separator, so the longest known
+// server name followed by it wins. Names already in server__tool form are kept.
+func (g *Gateway) canonicalToolName(name string, style string) string {
+	sep, ok := prefixSeparators[style]
+	if !ok || sep == "__" || strings.Contains(name, "__") {
+		return name
+	}
+
+	g.mu.RLock()
+	servers := []string{RouteServer, WorkflowServer, MemoryServer, FetchServer}
+	for server := range g.upstreamIDs {
+		servers = append(servers, server)
+	}
+	g.mu.RUnlock()
+
+	best := ""
+	for _, server := range servers {
+		if strings.HasPrefix(name, server+sep) && len(server) > len(best) {
+			best = server
+		}
+	}
+	if best == "" {
+		return name
+	}
+	return best + "__" + strings.TrimPrefix(name, best+sep)
+}
+
+// renameListedTools applies the prefix style to the tool names of a tools/list result.
+func renameListedTools(resp *JSONRPCMessage, style string) {
+	if resp == nil || resp.Error != nil || style == PrefixDoubleUnderscore {
+		return
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return
+	}
+	tools, _ := result["tools"].([]interface{})
+	for _, t := range tools {
+		if tool, ok := t.(map[string]interface{}); ok {
+			if name, ok := tool["name"].(string); ok {
+				tool["name"] = displayToolName(name, style)
+			}
+		}
+	}
+	resp.Result, _ = json.Marshal(result)
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"encoding/json"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&model.FeatureFlag{}, &model.MaintenanceWindow{}, &model.Workflow{}, &model.ToolRoute{}))
+
+	settings := &config.Config{UpstreamTimeout: time.Second, RecordCalls: true}
+	g := NewGateway(db, settings)
+	g.ReloadFlags()
+	selector, _ := ParseToolSelector("", "", 0)
+	transport := &listTransport{tools: []map[string]interface{}{{"name": "search"}}}
+	client := &UpstreamClient{
+		selector:    selector,
+		Config:      model.UpstreamServer{ID: 1, Name: "my.docs"},
+		settings:    settings,
+		transport:   transport,
+		pendingReqs: make(map[string]chan JSONRPCMessage),
+		ready:       true,
+	}
+	transport.client = client
+	g.upstreams[1] = client
+	g.upstreamIDs["my.docs"] = 1
+
+	update := func(values string) (FeatureFlags, error) {
+		var parsed map[string]json.RawMessage
+		json.Unmarshal([]byte(values), &parsed)
+		return g.UpdateFlags(parsed)
+	}
+	listNames := func(caller *Caller) []string {
+		resp, err := g.HandleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`), caller)
+		assert.NoError(t, err)
+		var result struct {
+			Tools []struct {
+				Name string `json:"name"`
+			} `json:"tools"`
+		}
+		json.Unmarshal(resp.Result, &result)
+		names := []string{}
+		for _, tool := range result.Tools {
+			names = append(names, tool.Name)
+		}
+		return names
+	}
+
+	t.Run("Defaults And Validation", func(t *testing.T) {
+		flags := g.Flags()
+		assert.Equal(t, PrefixDoubleUnderscore, flags.PrefixStyle)
+		assert.True(t, flags.RecordCalls, "record_calls defaults to RECORD_CALLS")
+
+		_, err := update(`{"cache_tools": true, "bogus": true}`)
+		assert.ErrorContains(t, err, `unknown flag "bogus"`)
+		_, err = update(`{"prefix_style": "colon"}`)
+		assert.Error(t, err)
+		_, err = update(`{"strict_permissions": "yes"}`)
+		assert.Error(t, err)
+		assert.Equal(t, flags, g.Flags(), "invalid updates change nothing")
+		var count int64
+		db.Model(&model.FeatureFlag{}).Count(&count)
+		assert.Zero(t, count)
+	})
+
+	t.Run("Tool Cache", func(t *testing.T) {
+		caller := &Caller{KeyID: 1}
+		listNames(caller)
+		listNames(caller)
+		assert.Equal(t, int32(2), transport.calls.Load())
+
+		flags, err := update(`{"cache_tools": true}`)
+		assert.NoError(t, err)
+		assert.True(t, flags.CacheTools)
+		listNames(caller)
+		assert.Equal(t, []string{"my.docs__search"}, listNames(caller), "cached tools are not renamed twice")
+		assert.Equal(t, int32(2), transport.calls.Load())
+
+		g.handleUpstreamNotification(client, &JSONRPCMessage{Method: "notifications/tools/list_changed"})
+		listNames(caller)
+		assert.Equal(t, int32(3), transport.calls.Load(), "list_changed drops the cache")
+	})
+
+	t.Run("Strict Permissions", func(t *testing.T) {
+		unrestricted := &Caller{KeyID: 1}
+		restricted := &Caller{KeyID: 2, AllowedServers: []string{"1"}}
+		_, err := update(`{"strict_permissions": true}`)
+		assert.NoError(t, err)
+		assert.Empty(t, listNames(unrestricted))
+		assert.Len(t, listNames(restricted), 1)
+		assert.False(t, unrestricted.serverVisible(client, true))
+
+		_, err = update(`{"strict_permissions": null}`)
+		assert.NoError(t, err)
+		assert.Len(t, listNames(unrestricted), 1, "null resets to the default")
+	})
+
+	t.Run("Prefix Style", func(t *testing.T) {
+		_, err := update(`{"prefix_style": "slash"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"my.docs/search"}, listNames(&Caller{KeyID: 1}))
+		assert.Equal(t, "my.docs__search", g.canonicalToolName("my.docs/search", PrefixSlash))
+		assert.Equal(t, "my.docs__search", g.canonicalToolName("my.docs__search", PrefixSlash), "canonical names keep working")
+		assert.Equal(t, WorkflowServer+"__a/b", g.canonicalToolName(WorkflowServer+"/a/b", PrefixSlash))
+
+		_, err = update(`{"prefix_style": "dot"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"my.docs.search"}, listNames(&Caller{KeyID: 1}))
+		assert.Equal(t, "my.docs__search", g.canonicalToolName("my.docs.search", PrefixDot), "the longest server name wins")
+	})
+
+	t.Run("Reload From Database", func(t *testing.T) {
+		db.Save(&model.FeatureFlag{Name: "record_calls", Value: "false"})
+		db.Save(&model.FeatureFlag{Name: "prefix_style", Value: `"unknown"`})
+		g.ReloadFlags()
+		flags := g.Flags()
+		assert.False(t, flags.RecordCalls)
+		assert.Equal(t, PrefixDoubleUnderscore, flags.PrefixStyle, "invalid stored values keep the default")
+		assert.True(t, flags.CacheTools)
+	})
+}
@@ -0,0 +1,202 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// Message framing of stdio upstreams
+const (
+	FramingNDJSON        = "ndjson"         // One JSON message per line (default)
+	FramingContentLength = "content-length" // LSP-style "Content-Length: N" header, blank line, body
+)
+
+// ValidateFraming checks the framing and character encoding of a stdio upstream.
+// The encoding is any WHATWG label, e.g. "latin1" or "shift_jis"; empty means UTF-8.
+// Line framing needs an encoding in which a newline is the byte '\n'.
+func ValidateFraming(framing string, charset string) error {
+	switch framing {
+	case "", FramingNDJSON, FramingContentLength:
+	default:
+		return fmt.Errorf("invalid framing %q: must be %s or %s", framing, FramingNDJSON, FramingContentLength)
+	}
+	enc, err := textEncoding(charset)
+	if err != nil {
+		return err
+	}
+	if enc != nil && framing != FramingContentLength {
+		if nl, err := enc.NewEncoder().Bytes([]byte("\n")); err != nil || !bytes.Equal(nl, []byte("\n")) {
+			return fmt.Errorf("encoding %q requires %s framing", charset, FramingContentLength)
+		}
+	}
+	return nil
+}
+
+// textEncoding resolves a charset label, returning nil for UTF-8.
+func textEncoding(charset string) (encoding.Encoding, error) {
+	if charset == "" {
+		return nil, nil
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %q", charset)
+	}
+	if name, _ := htmlindex.Name(enc); name == "utf-8" {
+		return nil, nil
+	}
+	return enc, nil
+}
+
+// messageCodec frames and transcodes the JSON-RPC messages of a stdio upstream.
+type messageCodec struct {
+	framing string
+	enc     encoding.Encoding // nil for UTF-8
+	maxSize int
+}
+
+func newMessageCodec(framing string, charset string, maxSize int) (*messageCodec, error) {
+	if err := ValidateFraming(framing, charset); err != nil {
+		return nil, err
+	}
+	enc, _ := textEncoding(charset)
+	return &messageCodec{framing: framing, enc: enc, maxSize: maxSize}, nil
+}
+
+// encode returns payload as written to the upstream.
+func (m *messageCodec) encode(payload []byte) ([]byte, error) {
+	if m.enc != nil {
+		// Escaped JSON is ASCII, which every supported encoding can represent
+		encoded, err := m.enc.NewEncoder().Bytes(escapeNonASCII(payload))
+		if err != nil {
+			return nil, fmt.Errorf("encoding message: %v", err)
+		}
+		payload = encoded
+	}
+	if m.framing == FramingContentLength {
+		header := fmt.Sprintf("Content-Length: %d\r\n\r\n", len(payload))
+		return append([]byte(header), payload...), nil
+	}
+	if !bytes.HasSuffix(payload, []byte("\n")) {
+		payload = append(payload, '\n')
+	}
+	return payload, nil
+}
+
+// read calls onMessage with every message read from r, in UTF-8, until r ends.
+func (m *messageCodec) read(r io.Reader, onMessage func([]byte)) error {
+	deliver := func(msg []byte) error {
+		if m.enc != nil {
+			decoded, err := m.enc.NewDecoder().Bytes(msg)
+			if err != nil {
+				return fmt.Errorf("decoding message: %v", err)
+			}
+			msg = decoded
+		}
+		onMessage(msg)
+		return nil
+	}
+
+	if m.framing == FramingContentLength {
+		reader := bufio.NewReader(r)
+		for {
+			length, err := readFrameHeader(reader, m.maxSize)
+			if err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+			msg := make([]byte, length)
+			if _, err := io.ReadFull(reader, msg); err != nil {
+				return fmt.Errorf("reading message body: %v", err)
+			}
+			if err := deliver(msg); err != nil {
+				return err
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	// Large buffer just in case
+	buf := make([]byte, 1024*1024)
+	scanner.Buffer(buf, m.maxSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		// Copy buffer because scanner reuses it
+		msg := make([]byte, len(line))
+		copy(msg, line)
+		if err := deliver(msg); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// readFrameHeader reads the header block of a Content-Length frame and returns
+// the body length. Headers other than Content-Length (e.g. Content-Type) are ignored.
+// io.EOF is returned when the stream ends between frames.
+func readFrameHeader(r *bufio.Reader, maxSize int) (int, error) {
+	length := -1
+	for lines := 0; ; lines++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && lines == 0 && line == "" {
+				return 0, io.EOF
+			}
+			return 0, fmt.Errorf("reading message header: %v", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if lines == 0 {
+				continue // Tolerate blank lines between frames
+			}
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return 0, fmt.Errorf("invalid message header %q", line)
+		}
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid Content-Length %q", value)
+			}
+			length = n
+		}
+	}
+	if length < 0 {
+		return 0, fmt.Errorf("message without Content-Length header")
+	}
+	if length > maxSize {
+		return 0, fmt.Errorf("message of %d bytes exceeds MAX_MESSAGE_SIZE", length)
+	}
+	return length, nil
+}
+
+// escapeNonASCII replaces non-ASCII characters of a JSON document with \u escapes.
+// They can only occur inside strings, where the escapes are equivalent.
+func escapeNonASCII(payload []byte) []byte {
+	var out bytes.Buffer
+	for len(payload) > 0 {
+		r, size := utf8.DecodeRune(payload)
+		payload = payload[size:]
+		switch {
+		case r < utf8.RuneSelf:
+			out.WriteByte(byte(r))
+		case r > 0xFFFF:
+			r -= 0x10000
+			fmt.Fprintf(&out, `\u%04x\u%04x`, 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+		default:
+			fmt.Fprintf(&out, `\u%04x`, r)
+		}
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageCodec(t *testing.T) {
+	read := func(codec *messageCodec, input string) ([]string, error) {
+		var msgs []string
+		err := codec.read(strings.NewReader(input), func(msg []byte) { msgs = append(msgs, string(msg)) })
+		return msgs, err
+	}
+
+	t.Run("Content-Length Framing", func(t *testing.T) {
+		codec, err := newMessageCodec(FramingContentLength, "", 1024)
+		assert.NoError(t, err)
+
+		framed, err := codec.encode([]byte(`{"id":1}`))
+		assert.NoError(t, err)
+		assert.Equal(t, "Content-Length: 8\r\n\r\n{\"id\":1}", string(framed))
+
+		msgs, err := read(codec, "Content-Length: 8\r\n\r\n{\"id\":1}content-length: 9\r\nContent-Type: application/json\r\n\r\n{\"id\":22}")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{`{"id":1}`, `{"id":22}`}, msgs)
+
+		_, err = read(codec, "Content-Type: application/json\r\n\r\n{}")
+		assert.ErrorContains(t, err, "without Content-Length")
+		_, err = read(codec, "Content-Length: 4096\r\n\r\n{}")
+		assert.ErrorContains(t, err, "exceeds MAX_MESSAGE_SIZE")
+	})
+
+	t.Run("Line Framing", func(t *testing.T) {
+		codec, err := newMessageCodec("", "", 1024)
+		assert.NoError(t, err)
+
+		framed, _ := codec.encode([]byte(`{"id":1}`))
+		assert.Equal(t, "{\"id\":1}\n", string(framed))
+		msgs, err := read(codec, "{\"id\":1}\n{\"id\":2}\n")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{`{"id":1}`, `{"id":2}`}, msgs)
+	})
+
+	t.Run("Character Encoding", func(t *testing.T) {
+		codec, err := newMessageCodec("", "latin1", 1024)
+		assert.NoError(t, err)
+
+		framed, _ := codec.encode([]byte(`{"text":"café ☕ 🚀"}`))
+		assert.Equal(t, `{"text":"caf\u00e9 \u2615 \ud83d\ude80"}`+"\n", string(framed))
+		var decoded map[string]string
+		json.Unmarshal(framed, &decoded)
+		assert.Equal(t, "café ☕ 🚀", decoded["text"])
+
+		msgs, err := read(codec, "{\"text\":\"caf\xe9\"}\n")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{`{"text":"café"}`}, msgs)
+
+		codec, err = newMessageCodec(FramingContentLength, "utf-16le", 1024)
+		assert.NoError(t, err)
+		framed, _ = codec.encode([]byte(`{}`))
+		assert.True(t, bytes.HasSuffix(framed, []byte("{\x00}\x00")))
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		assert.NoError(t, ValidateFraming(FramingNDJSON, "utf-8"))
+		assert.Error(t, ValidateFraming("xml", ""))
+		assert.Error(t, ValidateFraming("", "klingon"))
+		assert.ErrorContains(t, ValidateFraming("", "utf-16le"), "requires content-length framing")
+	})
+}
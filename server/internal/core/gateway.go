@@ -6,44 +6,375 @@ import (
 	"log"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"one-mcp/internal/config"
 	"one-mcp/internal/model"
+	"one-mcp/internal/report"
 	"gorm.io/gorm"
 )
 
 type Gateway struct {
 	db        *gorm.DB
-	upstreams map[string]*UpstreamClient // map[Name]*Client
-	mu        sync.RWMutex
+	settings  *config.Config
+	upstreams   map[uint]*UpstreamClient // Running clients by server ID (0 = demo)
+	upstreamIDs map[string]uint          // Server ID by name, swapped together with upstreams
+	mu          sync.RWMutex
+	reloadMu    sync.Mutex               // Serializes reloads, which wait for stdio processes outside mu
+
+	limits    *Limits      // Gateway-wide protection limits
+	moderator *Moderator   // Optional content moderation of tool results
+	mirror    *Mirror      // Optional traffic mirroring to a staging gateway
+	secrets   *SecretStore // Secrets referenced by HTTP tool templates
+	auth      *UpstreamAuth // OAuth tokens of upstreams, see upstream_auth.go
+	blobs     *BlobStore   // Content offloaded from tool results, see blobs.go
+	fetcher   *fetcher     // Requests of the fetch tool, see fetch.go
+	replica   *gorm.DB     // Optional read-only replica for tool snapshots
+
+	flags atomic.Pointer[FeatureFlags] // Runtime feature flags, see flags.go
+
+	catalogMu       sync.Mutex                                 // Serializes tool catalog syncs
+	catalogVersions map[uint]map[string]map[string]interface{} // Cached snapshots by version, then tool name
+
+	resourceOwners map[string]string // Upstream name by resource URI, from the last listings
+	resourceMu     sync.Mutex
+
+	subscriptions map[string]*resourceSubscription // Downstream resource subscriptions by URI
+	subMu         sync.Mutex
+
+	clientRequests map[string]*clientRequest // Gateway-initiated requests awaiting a client response, by ID
+	clientReqSeq   int64
+	clientReqMu    sync.Mutex
+
+	inflight   map[string]chan struct{} // Cancellation of downstream requests in flight, by session and ID
+	inflightMu sync.Mutex
+
+	progress    map[string]*progressTarget // Sessions awaiting progress, by gateway progressToken
+	progressSeq int64
+	progressMu  sync.Mutex
+
+	sessions          map[string]*Caller // Live downstream sessions by ID
+	logLevels         map[string]string  // Log level requested by session ID
+	toolsChangedTimer *time.Timer        // Pending tools/list_changed broadcast
+	sessionMu         sync.Mutex
+
+	traces map[uint]time.Time // Message tracing end by server ID, guarded by mu
+
+	quotaPending map[uint]int // Admitted tool calls not yet in the usage log, by team ID
+	quotaMu      sync.Mutex
+
+	maintenance map[uint][]model.MaintenanceWindow // Upcoming and active windows by server ID
+	maintMu     sync.RWMutex
+
+	prices   []model.ToolPrice // Cached tool prices, see ReloadPrices
+	pricesMu sync.RWMutex
 }
 
-func NewGateway(db *gorm.DB) *Gateway {
+func NewGateway(db *gorm.DB, settings *config.Config) *Gateway {
 	g := &Gateway{
-		db:        db,
-		upstreams: make(map[string]*UpstreamClient),
+		db:          db,
+		settings:    settings,
+		upstreams:   make(map[uint]*UpstreamClient),
+		upstreamIDs: make(map[string]uint),
+		secrets:     NewSecretStore(db),
+		auth:        NewUpstreamAuth(db, settings),
+		blobs:       NewBlobStore(),
+		fetcher:     newFetcher(settings, publicIP),
+		traces:      make(map[uint]time.Time),
 	}
+	g.limits = NewLimits(settings.MaxSessions, settings.MaxSessionsPerKey, settings.MaxInflightCalls,
+		float64(settings.MessageRate), settings.MessageBurst)
 	return g
 }
 
+// Limits returns the gateway-wide protection limits.
+func (g *Gateway) Limits() *Limits {
+	return g.limits
+}
+
+// UpstreamAuth returns the OAuth flow and tokens of upstreams.
+func (g *Gateway) UpstreamAuth() *UpstreamAuth {
+	return g.auth
+}
+
+// SetModerator enables content moderation of tool results.
+func (g *Gateway) SetModerator(m *Moderator) {
+	g.moderator = m
+}
+
+// SetMirror enables mirroring of incoming messages to a staging gateway.
+func (g *Gateway) SetMirror(m *Mirror) {
+	g.mirror = m
+}
+
+// SetReadReplica loads tool catalog snapshots from a read-only replica of the database.
+func (g *Gateway) SetReadReplica(replica *gorm.DB) {
+	g.replica = replica
+}
+
+// newUpstreamClient creates a client wired to the gateway's handlers for
+// upstream notifications and requests.
+func (g *Gateway) newUpstreamClient(server model.UpstreamServer) *UpstreamClient {
+	client := NewUpstreamClient(server, g.settings, g.secrets)
+	client.onNotification = g.handleUpstreamNotification
+	client.onRequest = g.handleUpstreamRequest
+	client.onInitialized = g.upstreamInitialized
+	if t, ok := client.transport.(interface{ SetTokenSource(func() (string, error)) }); ok && server.ID != 0 {
+		t.SetTokenSource(func() (string, error) { return g.auth.AccessToken(server.ID) })
+	}
+	if t, ok := client.transport.(*StdioTransport); ok && server.SandboxProfileID != 0 {
+		t.SetSandbox(func() (*model.SandboxProfile, error) {
+			var profile model.SandboxProfile
+			if err := g.db.First(&profile, server.SandboxProfileID).Error; err != nil {
+				return nil, fmt.Errorf("sandbox profile %d: %v", server.SandboxProfileID, err)
+			}
+			return &profile, nil
+		})
+	}
+	return client
+}
+
 func (g *Gateway) ReloadUpstreams() {
+	g.reloadMu.Lock()
+	defer g.reloadMu.Unlock()
+
+	// A stdio server may keep state such as sqlite files, so its process must
+	// have exited before its replacement starts. The previous clients are waited
+	// for once the lock is released, so that stdio processes taking their grace
+	// period to exit do not block the gateway
+	previous := g.stopStdioClients()
+	stopped := g.reloadUpstreams(previous)
+	stopped()
+}
+
+// stopStdioClients removes the stdio clients from the running ones, waits for
+// their processes to exit and returns all clients running before.
+func (g *Gateway) stopStdioClients() (previous map[uint]*UpstreamClient) {
+	g.mu.Lock()
+	previous = g.upstreams
+	stdio := make(map[uint]*UpstreamClient)
+	remaining := make(map[uint]*UpstreamClient)
+	for id, client := range previous {
+		if _, ok := client.transport.(*StdioTransport); ok {
+			stdio[id] = client
+		} else {
+			remaining[id] = client
+		}
+	}
+	remainingIDs := make(map[string]uint)
+	for name, id := range g.upstreamIDs {
+		if _, ok := remaining[id]; ok {
+			remainingIDs[name] = id
+		}
+	}
+	g.upstreams, g.upstreamIDs = remaining, remainingIDs
+	g.mu.Unlock()
+
+	stopClients(stdio)()
+	return previous
+}
+
+// reloadUpstreams swaps in clients for the enabled servers and returns a function
+// waiting for the clients still running to stop. previous holds the clients
+// before the reload, whose endpoint history is kept.
+func (g *Gateway) reloadUpstreams(previous map[uint]*UpstreamClient) (stopped func()) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	
 	// Stop existing
-	for _, client := range g.upstreams {
-		client.Stop()
-	}
-	g.upstreams = make(map[string]*UpstreamClient)
+	stopped = stopClients(g.upstreams)
+	g.upstreams = make(map[uint]*UpstreamClient)
+	g.upstreamIDs = make(map[string]uint)
 	
 	var servers []model.UpstreamServer
 	if err := g.db.Where("enabled = ?", true).Find(&servers).Error; err != nil {
 		log.Printf("Failed to load upstreams: %v", err)
-		return
+		return stopped
 	}
-	
-	for _, server := range servers {
-		client := NewUpstreamClient(server)
+
+	// Both maps are rebuilt here and only published when complete
+	upstreams := make(map[uint]*UpstreamClient)
+	upstreamIDs := make(map[string]uint)
+	routable, errs := routableServers(servers)
+	for _, err := range errs {
+		fmt.Printf("[Gateway] %v\n", err)
+	}
+	for _, server := range routable {
+		client := g.newUpstreamClient(server)
+		client.keepEndpointHistory(previous[server.ID])
+		if until, ok := g.traces[server.ID]; ok && time.Now().Before(until) {
+			client.EnableTrace(TracePath(g.settings.DataDir, server.Name), until)
+		}
 		client.Start()
-		g.upstreams[server.Name] = client
+		upstreams[server.ID] = client
+		upstreamIDs[server.Name] = server.ID
+	}
+
+	if g.settings.DemoUpstream {
+		if _, taken := upstreamIDs[DemoServer]; taken {
+			fmt.Printf("[Gateway] Not starting the demo upstream: a server named %q is configured\n", DemoServer)
+		} else {
+			client := g.newUpstreamClient(model.UpstreamServer{Name: DemoServer, TransportType: "demo", Enabled: true})
+			client.Start()
+			upstreams[0] = client
+			upstreamIDs[DemoServer] = 0
+		}
+	}
+	g.upstreams, g.upstreamIDs = upstreams, upstreamIDs
+
+	// Upstreams may have been added, removed or reconfigured
+	g.notifyToolsChanged()
+
+	g.ReloadMaintenance()
+	g.ReloadPrices()
+	return stopped
+}
+
+// StopUpstreams stops every upstream client, e.g. when shutting down, and
+// waits for their processes to exit.
+func (g *Gateway) StopUpstreams() {
+	g.mu.Lock()
+	stopped := stopClients(g.upstreams)
+	g.upstreams = make(map[uint]*UpstreamClient)
+	g.upstreamIDs = make(map[string]uint)
+	g.mu.Unlock()
+	stopped()
+}
+
+// stopClients stops clients in parallel, so that stdio processes get their
+// grace period together rather than one after the other, and returns a function
+// waiting for them. Callers holding g.mu wait after releasing it.
+func stopClients(clients map[uint]*UpstreamClient) (wait func()) {
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		wg.Add(1)
+		go func(client *UpstreamClient) {
+			defer wg.Done()
+			client.Stop()
+		}(client)
+	}
+	return wg.Wait
+}
+
+// Caller identifies the API key a downstream message is handled on behalf of.
+type Caller struct {
+	KeyID            uint
+	TeamID           uint
+	AllowedServers   []string
+	AllowedTools     []string
+	AllowedPrompts   []string // Patterns of prefixed prompt names (empty = per server access)
+	AllowedResources []string // Patterns of server-prefixed resource URIs (empty = per server access)
+	SigningSecret    string   // Optional HMAC key for signing tool results
+	OutputFormat     string   // Preferred format of gateway-produced results
+	CatalogVersion   uint     // Pinned tool catalog snapshot (0 = live)
+	Roots            []Root   // Static answer to upstream roots/list requests (empty = ask the client)
+
+	DeclineElicitation bool // Decline elicitation requests the client cannot answer
+	ReadOnlyTools      bool // Only tools annotated readOnlyHint can be listed and called
+
+	ProtocolVersion string // Negotiated during initialize; read with Protocol once the session is shared
+
+	SessionID    string                     // Downstream session, empty outside SSE sessions
+	Notify       func(msg []byte) bool      // Writes a message to the session, nil if it cannot receive any; use Deliver
+	Capabilities map[string]json.RawMessage // Declared by the client during initialize
+
+	// negotiated guards ProtocolVersion and Capabilities: messages of a session are
+	// handled concurrently, so initialize may run while others read them.
+	negotiated sync.RWMutex
+	out        outbox // Ordered delivery to Notify (see outbox.go)
+}
+
+// Supports reports whether the client declared the capability during initialize.
+func (c *Caller) Supports(capability string) bool {
+	c.negotiated.RLock()
+	defer c.negotiated.RUnlock()
+	_, ok := c.Capabilities[capability]
+	return ok
+}
+
+// Protocol returns the protocol version negotiated during initialize.
+func (c *Caller) Protocol() string {
+	c.negotiated.RLock()
+	defer c.negotiated.RUnlock()
+	return c.ProtocolVersion
+}
+
+// Negotiated returns the protocol version and client capabilities of initialize.
+func (c *Caller) Negotiated() (string, map[string]json.RawMessage) {
+	c.negotiated.RLock()
+	defer c.negotiated.RUnlock()
+	return c.ProtocolVersion, c.Capabilities
+}
+
+// Negotiate records the protocol version and capabilities of initialize, or
+// restores those of a resumed session.
+func (c *Caller) Negotiate(version string, capabilities map[string]json.RawMessage) {
+	c.negotiated.Lock()
+	defer c.negotiated.Unlock()
+	c.ProtocolVersion = version
+	c.Capabilities = capabilities
+}
+
+// Key returns the identifier used for per-key scheduling and accounting.
+func (c *Caller) Key() string {
+	return fmt.Sprintf("%d", c.KeyID)
+}
+
+// CallerForKey builds the gateway caller for an API key, resolving team default
+// permissions for keys that have none of their own.
+func CallerForKey(db *gorm.DB, apiKey *model.ApiKey) *Caller {
+	allowedServersJSON := apiKey.AllowedServers
+	allowedToolsJSON := apiKey.AllowedTools
+	allowedPromptsJSON := apiKey.AllowedPrompts
+	allowedResourcesJSON := apiKey.AllowedResources
+	if apiKey.TeamID != 0 && allowedServersJSON == "" && allowedToolsJSON == "" &&
+		allowedPromptsJSON == "" && allowedResourcesJSON == "" {
+		var team model.Team
+		if err := db.First(&team, apiKey.TeamID).Error; err == nil {
+			allowedServersJSON = team.AllowedServers
+			allowedToolsJSON = team.AllowedTools
+			allowedPromptsJSON = team.AllowedPrompts
+			allowedResourcesJSON = team.AllowedResources
+		}
+	}
+
+	// Parse permissions
+	var allowedServers []string
+	if allowedServersJSON != "" {
+		json.Unmarshal([]byte(allowedServersJSON), &allowedServers)
+	}
+
+	var allowedTools []string
+	if allowedToolsJSON != "" {
+		json.Unmarshal([]byte(allowedToolsJSON), &allowedTools)
+	}
+
+	var allowedPrompts, allowedResources []string
+	if allowedPromptsJSON != "" {
+		json.Unmarshal([]byte(allowedPromptsJSON), &allowedPrompts)
+	}
+	if allowedResourcesJSON != "" {
+		json.Unmarshal([]byte(allowedResourcesJSON), &allowedResources)
+	}
+
+	roots, err := ParseRoots(apiKey.Roots)
+	if err != nil {
+		fmt.Printf("[Gateway] Ignoring roots of key %d: %v\n", apiKey.ID, err)
+	}
+
+	return &Caller{
+		KeyID:              apiKey.ID,
+		TeamID:             apiKey.TeamID,
+		AllowedServers:     allowedServers,
+		AllowedTools:       allowedTools,
+		AllowedPrompts:     allowedPrompts,
+		AllowedResources:   allowedResources,
+		SigningSecret:      apiKey.SigningSecret,
+		OutputFormat:       apiKey.OutputFormat,
+		CatalogVersion:     apiKey.CatalogVersion,
+		Roots:              roots,
+		DeclineElicitation: apiKey.DeclineElicitation,
+		ReadOnlyTools:      apiKey.ReadOnlyTools,
 	}
 }
 
@@ -86,34 +417,108 @@ func CheckPermission(allowedServerIDs []string, allowedTools []string, srvID str
 	return true
 }
 
-func (g *Gateway) HandleMessage(msg []byte, allowedServerIDs []string, allowedTools []string) (*JSONRPCMessage, error) {
-	fmt.Printf("[Gateway] Received message: %s\n", string(msg))
+// permissionCheck returns the check of the caller's access to a server ID and
+// prefixed tool name.
+func (g *Gateway) permissionCheck(caller *Caller) func(string, string) bool {
+	flags := g.Flags()
+	return func(srvID string, toolName string) bool {
+		if flags.StrictPermissions && caller.unrestricted() {
+			return false
+		}
+		return CheckPermission(caller.AllowedServers, caller.AllowedTools, srvID, toolName)
+	}
+}
+
+func (g *Gateway) HandleMessage(msg []byte, caller *Caller) (resp *JSONRPCMessage, err error) {
 	var req JSONRPCMessage
 	if err := json.Unmarshal(msg, &req); err != nil {
 		fmt.Printf("[Gateway] JSON parse error: %v\n", err)
 		return nil, err
 	}
+	if req.Method == "" && req.ID != nil {
+		// Response to a gateway-initiated request
+		g.handleClientResponse(&req, caller)
+		return nil, nil
+	}
+	fmt.Printf("[Gateway] Received %s from key %d\n", req.Method, caller.KeyID)
+	if g.mirror != nil {
+		g.mirror.Offer(&req)
+	}
+	if req.ID != nil && caller.SessionID != "" {
+		cancel, done := g.trackRequest(caller.SessionID, *req.ID)
+		defer done()
+		// A cancelled request gets no response
+		defer func() {
+			if cancelled(cancel) {
+				resp, err = nil, nil
+			}
+		}()
+	}
 	
 	// Permission check closure to pass down
-	hasPermission := func(srvID string, toolName string) bool {
-		return CheckPermission(allowedServerIDs, allowedTools, srvID, toolName)
-	}
+	flags := g.Flags()
+	hasPermission := g.permissionCheck(caller)
 	
 	switch req.Method {
 	case "initialize":
-		return g.handleInitialize(&req)
+		var params struct {
+			ProtocolVersion string                     `json:"protocolVersion"`
+			Capabilities    map[string]json.RawMessage `json:"capabilities"`
+		}
+		json.Unmarshal(req.Params, &params)
+		caller.Negotiate(negotiateProtocolVersion(params.ProtocolVersion), params.Capabilities)
+		return g.handleInitialize(&req, caller)
 	case "notifications/initialized":
 		return nil, nil
+	case "notifications/cancelled":
+		g.handleCancelled(&req, caller)
+		return nil, nil
 	case "tools/list":
-		return g.handleToolsList(&req, hasPermission)
+		if caller.CatalogVersion != 0 {
+			resp, err = g.handlePinnedToolsList(&req, caller.CatalogVersion, hasPermission)
+		} else {
+			resp, err = g.handleToolsList(&req, hasPermission)
+		}
+		if caller.ReadOnlyTools {
+			filterReadOnlyTools(resp)
+		}
+		var params struct {
+			Cursor string `json:"cursor"`
+		}
+		json.Unmarshal(req.Params, &params)
+		var health map[string]UpstreamHealth
+		if flags.HealthOrder {
+			health = g.serverHealth()
+			annotateDegradedTools(resp, health)
+		}
+		if !paginateTools(resp, params.Cursor, g.settings.ToolsPageSize, health) {
+			return &JSONRPCMessage{
+				JSONRPC: "2.0", ID: req.ID,
+				Error: &JSONRPCError{Code: -32602, Message: "Invalid cursor"},
+			}, nil
+		}
+		renameListedTools(resp, flags.PrefixStyle)
+		return resp, err
 	case "tools/call":
 		// Some clients (like Claude Desktop) might use "callTool" instead of "tools/call"?
 		// No, standard is "tools/call". 
 		// However, let's verify if the request params are coming in correctly.
 		// Sometimes params are nested differently.
-		return g.handleToolCall(&req, hasPermission)
+		return g.handleToolCall(&req, caller, hasPermission)
 	case "callTool": // Legacy or alternative method name handling
-		return g.handleToolCall(&req, hasPermission)
+		return g.handleToolCall(&req, caller, hasPermission)
+	case "prompts/list":
+		return g.handlePromptsList(&req, caller)
+	case "prompts/get":
+		return g.handlePromptsGet(&req, caller)
+	case "resources/list":
+		return g.handleResourcesList(&req, caller)
+	case "resources/read":
+		return g.handleResourceRead(&req, caller)
+	case "resources/subscribe":
+		return g.handleResourceSubscribe(&req, caller)
+	case "resources/unsubscribe":
+		return g.handleResourceUnsubscribe(&req, caller)
 	case "ping":
 		// Handle ping (return pong usually, or empty result)
 		return &JSONRPCMessage{
@@ -122,29 +527,13 @@ func (g *Gateway) HandleMessage(msg []byte, allowedServerIDs []string, allowedTo
 			Result:  json.RawMessage([]byte("{}")),
 		}, nil
 	case "logging/setLevel":
-		// Accept logging level changes but ignore them for now
-		return &JSONRPCMessage{
-			JSONRPC: "2.0",
-			ID:      req.ID,
-			Result:  json.RawMessage([]byte("{}")),
-		}, nil
+		return g.handleSetLevel(&req, caller)
 	case "completion/complete":
-		// TODO: Implement completion forwarding if needed. 
-		// For now, return empty completions to avoid client errors.
-		emptyResult := map[string]interface{}{
-			"completion": map[string]interface{}{
-				"values":  []string{},
-				"total":   0,
-				"hasMore": false,
-			},
-		}
-		resBytes, _ := json.Marshal(emptyResult)
-		return &JSONRPCMessage{
-			JSONRPC: "2.0",
-			ID:      req.ID,
-			Result:  resBytes,
-		}, nil
+		return g.handleCompletion(&req, caller)
 	default:
+		if resp, ok := g.handleCustomMethod(&req, caller); ok {
+			return resp, nil
+		}
 		// Unknown method
 		errResp := &JSONRPCError{Code: -32601, Message: "Method not supported"}
 		return &JSONRPCMessage{
@@ -155,22 +544,11 @@ func (g *Gateway) HandleMessage(msg []byte, allowedServerIDs []string, allowedTo
 	}
 }
 
-func (g *Gateway) handleInitialize(req *JSONRPCMessage) (*JSONRPCMessage, error) {
+func (g *Gateway) handleInitialize(req *JSONRPCMessage, caller *Caller) (*JSONRPCMessage, error) {
+	version := caller.Protocol()
 	result := map[string]interface{}{
-		"protocolVersion": "2024-11-05",
-		"capabilities": map[string]interface{}{
-			"tools": map[string]interface{}{
-				"listChanged": true,
-			},
-			"prompts": map[string]interface{}{
-				"listChanged": false,
-			},
-			"resources": map[string]interface{}{
-				"listChanged": false,
-				"subscribe":   false,
-			},
-			"logging": map[string]interface{}{},
-		},
+		"protocolVersion": version,
+		"capabilities":    g.serverCapabilities(caller, version),
 		"serverInfo": map[string]string{
 			"name":    "one-mcp-gateway",
 			"version": "1.1.1",
@@ -185,6 +563,32 @@ func (g *Gateway) handleInitialize(req *JSONRPCMessage) (*JSONRPCMessage, error)
 }
 
 func (g *Gateway) handleToolsList(req *JSONRPCMessage, hasPermission func(string, string) bool) (*JSONRPCMessage, error) {
+	allTools, _ := g.aggregateTools(hasPermission, nil)
+
+	fmt.Printf("[Gateway] Aggregated %d tools\n", len(allTools))
+	resBytes, _ := json.Marshal(map[string]interface{}{"tools": allTools})
+	return &JSONRPCMessage{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  resBytes,
+	}, nil
+}
+
+// ServerTools is the part of a tool aggregation contributed by one server.
+type ServerTools struct {
+	Server     string                   `json:"server"`
+	Tools      []map[string]interface{} `json:"tools"`
+	DurationMs int64                    `json:"duration_ms"`
+	Error      string                   `json:"error,omitempty"`
+}
+
+// aggregateTools lists the tools of every upstream concurrently, followed by the
+// route and workflow tools. If onServer is set, it is called with the tools of
+// each server as soon as they are known, so partial results can be shown without
+// waiting for the slowest upstream. Calls to onServer are not concurrent. listed
+// holds the upstreams whose tools/list succeeded, which excludes those in a
+// maintenance window.
+func (g *Gateway) aggregateTools(hasPermission func(string, string) bool, onServer func(ServerTools)) (tools []map[string]interface{}, listed map[string]bool) {
 	g.mu.RLock()
 	clients := make([]*UpstreamClient, 0, len(g.upstreams))
 	for _, c := range g.upstreams {
@@ -192,7 +596,18 @@ func (g *Gateway) handleToolsList(req *JSONRPCMessage, hasPermission func(string
 	}
 	g.mu.RUnlock()
 
+	// Servers in a maintenance window are left out of aggregation
+	available := clients[:0]
+	for _, c := range clients {
+		if _, inMaintenance := g.activeMaintenance(c.Config.ID); !inMaintenance {
+			available = append(available, c)
+		}
+	}
+	clients = available
+
+	cacheTools := g.Flags().CacheTools
 	var allTools []map[string]interface{}
+	listed = make(map[string]bool, len(clients))
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 
@@ -200,90 +615,78 @@ func (g *Gateway) handleToolsList(req *JSONRPCMessage, hasPermission func(string
 		wg.Add(1)
 		go func(c *UpstreamClient) {
 			defer wg.Done()
-			
-			var cursor string
-			for {
-				var resp *JSONRPCMessage
-				var err error
-				
-				if cursor == "" {
-					// Try sending nil first (no params)
-					resp, err = c.Call("tools/list", nil)
-				} else {
-					resp, err = c.Call("tools/list", map[string]string{"cursor": cursor})
-				}
-				
-				if err != nil {
-					return
-				}
-				
-				if resp.Error != nil {
-					// Fallback Strategy for strict servers
-					// 1. Try {} (empty object)
-					// 2. Try {"cursor": null} (explicit null cursor)
-					
-					if cursor == "" && resp.Error.Code == -32602 {
-						fmt.Printf("[Gateway] Upstream %s refused nil params, retrying with {}\n", c.Config.Name)
-						resp, err = c.Call("tools/list", map[string]interface{}{})
-						if err == nil && resp.Error != nil && resp.Error.Code == -32602 {
-							fmt.Printf("[Gateway] Upstream %s refused {}, retrying with {\"cursor\": null}\n", c.Config.Name)
-							resp, err = c.Call("tools/list", map[string]interface{}{"cursor": nil})
-						}
-						
-						if err != nil || resp.Error != nil {
-							fmt.Printf("[Gateway] Upstream %s failed all param attempts: %v\n", c.Config.Name, resp.Error)
-							return
-						}
-					} else {
-						fmt.Printf("[Gateway] Upstream %s returned error for tools/list: %v\n", c.Config.Name, resp.Error)
-						return
-					}
-				}
-				
-				var result struct {
-					Tools      []map[string]interface{} `json:"tools"`
-					NextCursor string                   `json:"nextCursor"`
-				}
-				if err := json.Unmarshal(resp.Result, &result); err != nil {
-					return
-				}
+			defer report.Recover("tools/list " + c.Config.Name)
+
+			started := time.Now()
+			upstreamTools, err := c.cachedTools(cacheTools)
+			serverTools := ServerTools{Server: c.Config.Name, Tools: []map[string]interface{}{}, DurationMs: time.Since(started).Milliseconds()}
+			if err != nil {
+				serverTools.Error = err.Error()
+				upstreamTools = nil
+			} else {
+				// Apply per-upstream tool selection
+				upstreamTools = c.selectTools(upstreamTools)
+			}
+
+			// Prefix tool names
+			for _, tool := range upstreamTools {
+				if name, ok := tool["name"].(string); ok {
+					prefixedName := fmt.Sprintf("%s__%s", c.Config.Name, name)
+					srvID := fmt.Sprintf("%d", c.Config.ID)
 
-				// Prefix tool names
-				for _, tool := range result.Tools {
-					if name, ok := tool["name"].(string); ok {
-						prefixedName := fmt.Sprintf("%s__%s", c.Config.Name, name)
-						srvID := fmt.Sprintf("%d", c.Config.ID)
-						
-						// Check Permission
-						if hasPermission(srvID, prefixedName) {
-							tool["name"] = prefixedName
-							mu.Lock()
-							allTools = append(allTools, tool)
-							mu.Unlock()
-						}
+					// Check Permission
+					if hasPermission(srvID, prefixedName) {
+						tool["name"] = prefixedName
+						serverTools.Tools = append(serverTools.Tools, tool)
 					}
 				}
+			}
 
-				if result.NextCursor == "" {
-					break
-				}
-				cursor = result.NextCursor
+			mu.Lock()
+			defer mu.Unlock()
+			allTools = append(allTools, serverTools.Tools...)
+			if serverTools.Error == "" {
+				listed[c.Config.Name] = true
+			}
+			if onServer != nil {
+				onServer(serverTools)
 			}
 		}(client)
 	}
 	wg.Wait()
 
-	fmt.Printf("[Gateway] Aggregated %d tools\n", len(allTools))
-	resBytes, _ := json.Marshal(map[string]interface{}{"tools": allTools})
-	return &JSONRPCMessage{
-		JSONRPC: "2.0",
-		ID:      req.ID,
-		Result:  resBytes,
-	}, nil
+	routes := g.routeTools(allTools, hasPermission)
+	workflows := g.workflowTools(hasPermission)
+	memory := g.memoryToolList(hasPermission)
+	fetch := g.fetchToolList(hasPermission)
+	if onServer != nil {
+		if len(routes) > 0 {
+			onServer(ServerTools{Server: RouteServer, Tools: routes})
+		}
+		if len(workflows) > 0 {
+			onServer(ServerTools{Server: WorkflowServer, Tools: workflows})
+		}
+		if len(memory) > 0 {
+			onServer(ServerTools{Server: MemoryServer, Tools: memory})
+		}
+		if len(fetch) > 0 {
+			onServer(ServerTools{Server: FetchServer, Tools: fetch})
+		}
+	}
+	allTools = append(allTools, routes...)
+	allTools = append(allTools, workflows...)
+	allTools = append(allTools, memory...)
+	return append(allTools, fetch...), listed
+}
+
+// StreamAllTools aggregates every tool without permission checks, like
+// ListAllTools, passing each server's tools to onServer as soon as they are known.
+func (g *Gateway) StreamAllTools(onServer func(ServerTools)) (tools []map[string]interface{}, listed map[string]bool) {
+	allowAll := func(srvID, toolName string) bool { return true }
+	return g.aggregateTools(allowAll, onServer)
 }
 
-func (g *Gateway) handleToolCall(req *JSONRPCMessage, hasPermission func(string, string) bool) (*JSONRPCMessage, error) {
-	fmt.Printf("[Gateway] Handling tool call: %s\n", string(req.Params))
+func (g *Gateway) handleToolCall(req *JSONRPCMessage, caller *Caller, hasPermission func(string, string) bool) (*JSONRPCMessage, error) {
 	
 	var params struct {
 		Name string `json:"name"`
@@ -293,6 +696,8 @@ func (g *Gateway) handleToolCall(req *JSONRPCMessage, hasPermission func(string,
 		fmt.Printf("[Gateway] Failed to parse tool call params: %v\n", err)
 		return nil, err
 	}
+	params.Name = g.canonicalToolName(params.Name, g.Flags().PrefixStyle)
+	fmt.Printf("[Gateway] Handling tool call: %s\n", params.Name)
 
 	// Parse server name from tool name: serverName__toolName
 	parts := strings.SplitN(params.Name, "__", 2)
@@ -306,9 +711,55 @@ func (g *Gateway) handleToolCall(req *JSONRPCMessage, hasPermission func(string,
 	serverName := parts[0]
 	toolName := parts[1]
 
-	g.mu.RLock()
-	client, ok := g.upstreams[serverName]
-	g.mu.RUnlock()
+	if caller.CatalogVersion != 0 {
+		if _, pinned := g.CatalogVersionTools(caller.CatalogVersion)[params.Name]; !pinned {
+			return &JSONRPCMessage{
+				JSONRPC: "2.0", ID: req.ID,
+				Error: &JSONRPCError{Code: -32602, Message: "Tool not found"},
+			}, nil
+		}
+	}
+
+	switch serverName {
+	case WorkflowServer, RouteServer:
+		if caller.ReadOnlyTools {
+			return &JSONRPCMessage{
+				JSONRPC: "2.0", ID: req.ID,
+				Error: &JSONRPCError{Code: -32000, Message: "Permission denied: key is restricted to read-only tools"},
+			}, nil
+		}
+	}
+
+	switch serverName {
+	case WorkflowServer:
+		return g.runWorkflow(req, caller, hasPermission, toolName, params.Args)
+	case RouteServer:
+		return g.runRoute(req, caller, hasPermission, toolName, params.Args)
+	}
+
+	// Workflow and route steps reserve their own quota, gateway tools share
+	// that of upstream calls.
+	releaseQuota, err := g.reserveTeamQuota(caller)
+	if err != nil {
+		return &JSONRPCMessage{
+			JSONRPC: "2.0", ID: req.ID,
+			Error: &JSONRPCError{Code: -32000, Message: err.Error()},
+		}, nil
+	}
+	defer releaseQuota()
+
+	switch serverName {
+	case MemoryServer:
+		if g.memoryServed() {
+			return g.runMemoryTool(req, caller, hasPermission, toolName, params.Args), nil
+		}
+	case FetchServer:
+		if g.fetchServed() {
+			return g.runFetchTool(req, caller, hasPermission, toolName, params.Args), nil
+		}
+	}
+
+	client, ok := g.upstream(serverName)
 
 	if !ok {
 		return &JSONRPCMessage{
@@ -317,6 +768,13 @@ func (g *Gateway) handleToolCall(req *JSONRPCMessage, hasPermission func(string,
 		}, nil
 	}
 
+	if !client.ToolSelected(toolName) {
+		return &JSONRPCMessage{
+			JSONRPC: "2.0", ID: req.ID,
+			Error: &JSONRPCError{Code: -32602, Message: "Tool not found"},
+		}, nil
+	}
+
 	// Check permission
 	srvID := fmt.Sprintf("%d", client.Config.ID)
 	if !hasPermission(srvID, params.Name) {
@@ -327,59 +785,208 @@ func (g *Gateway) handleToolCall(req *JSONRPCMessage, hasPermission func(string,
 		}, nil
 	}
 
-	// Prepare upstream params
+	if caller.ReadOnlyTools {
+		if annotations, _ := client.ToolAnnotations(toolName); !annotations.ReadOnly() {
+			fmt.Printf("[Gateway] Denied %s to read-only key %d\n", params.Name, caller.KeyID)
+			return &JSONRPCMessage{
+				JSONRPC: "2.0", ID: req.ID,
+				Error: &JSONRPCError{Code: -32000, Message: "Permission denied: key is restricted to read-only tools"},
+			}, nil
+		}
+	}
+
+	if client.AsyncTool(toolName) {
+		return g.startAsyncToolCall(req, caller, client, params.Name, params.Args), nil
+	}
+
+	return g.callUpstreamTool(req, caller, hasPermission, client, toolName, params.Name, params.Args, client.ToolTimeout(toolName)), nil
+}
+
+// callUpstreamTool calls toolName on client and post-processes the result: usage
+// accounting, output format, moderation and signing. fullName is the prefixed name.
+// hasPermission decides whether the call may be retried on the fallback server.
+func (g *Gateway) callUpstreamTool(req *JSONRPCMessage, caller *Caller, hasPermission func(string, string) bool, client *UpstreamClient, toolName string, fullName string, args interface{}, timeout time.Duration) *JSONRPCMessage {
 	upstreamParams := map[string]interface{}{
 		"name":      toolName,
-		"arguments": params.Args,
+		"arguments": args,
 	}
-	
-	resp, err := client.Call("tools/call", upstreamParams)
+	if meta := requestMeta(req); meta != nil {
+		upstreamParams["_meta"] = meta
+	}
+
+	if g.limits != nil {
+		release, err := g.limits.AcquireCall()
+		if err != nil {
+			fmt.Printf("[Gateway] Refusing call to %s: %v\n", fullName, err)
+			return err.(*ErrLimited).throttled(req)
+		}
+		defer release()
+	}
+
+	started := time.Now()
+	var resp *JSONRPCMessage
+	err := g.maintenanceError(client)
+	untrack := g.trackProgress(req, caller, upstreamParams)
+	if err == nil {
+		done := client.trackCaller(caller)
+		resp, err = client.CallAsCancelable(caller.Key(), "tools/call", upstreamParams, timeout, g.requestCancellation(caller, req))
+		done()
+		if err != errCancelled {
+			client.health.record(time.Since(started), err != nil || resp.Error != nil)
+		}
+	}
+	if cause := fallbackCause(resp, err); cause != nil && client.ToolFallback(toolName) != "" {
+		fallbackResp, fallbackErr := g.callFallback(req, client, caller, hasPermission, toolName, upstreamParams, cause)
+		// The primary's JSON-RPC error is returned if the fallback could not serve the call
+		if fallbackErr == nil || err != nil {
+			resp, err = fallbackResp, fallbackErr
+		}
+	}
+	untrack()
+	g.recordUsage(caller, client.Config.Name, fullName, args, resp, err, started)
 	if err != nil {
 		fmt.Printf("[Gateway] Upstream call failed: %v\n", err)
 		return &JSONRPCMessage{
 			JSONRPC: "2.0", ID: req.ID,
 			Error: &JSONRPCError{Code: -32000, Message: err.Error()},
-		}, nil
+		}
 	}
 	
 	if resp.Error != nil {
 		fmt.Printf("[Gateway] Upstream returned error: %v\n", resp.Error)
+	} else {
+		g.processToolResult(resp, caller, fullName, resultOutputFormat(client, caller))
 	}
-	
+
 	// Pass through result/error, but ensure ID matches request
 	resp.ID = req.ID
-	return resp, nil
+	return resp
+}
+
+// processToolResult applies the output format, moderation, offloading of large
+// content and signing to the successful result of a call of the tool fullName.
+func (g *Gateway) processToolResult(resp *JSONRPCMessage, caller *Caller, fullName string, format string) {
+	resp.Result = applyOutputFormat(resp.Result, format)
+	if g.moderator != nil {
+		g.moderateResult(resp, caller, fullName)
+	}
+	resp.Result = g.offloadContent(resp.Result, caller)
+	if caller.SigningSecret != "" {
+		resp.Result = signResult(resp.Result, caller.SigningSecret, fullName)
+	}
 }
 
+// UpstreamByID returns the running client of the server with the given ID.
+func (g *Gateway) UpstreamByID(id uint) (*UpstreamClient, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	c, ok := g.upstreams[id]
+	return c, ok
+}
+
+// GetAllTools aggregates every tool without permission checks.
 func (g *Gateway) GetAllTools() ([]map[string]interface{}, error) {
-	// Internal method to fetch all tools for admin UI
-	// Bypass permission checks
-	// Use handleToolsList with a permissive callback
-	
+	tools, _, err := g.ListAllTools()
+	return tools, err
+}
+
+// ListAllTools aggregates every tool for the admin UI without permission
+// checks. listed holds the upstreams whose tools/list succeeded, as returned by
+// aggregateTools.
+func (g *Gateway) ListAllTools() (tools []map[string]interface{}, listed map[string]bool, err error) {
 	allowAll := func(srvID, toolName string) bool { return true }
-	
-	// Construct a fake request
-	idRaw := json.RawMessage([]byte("0"))
-	req := &JSONRPCMessage{
-		JSONRPC: "2.0",
-		ID:      &idRaw,
-		Method:  "tools/list",
-	}
-	
-	resp, err := g.handleToolsList(req, allowAll)
+	tools, listed = g.aggregateTools(allowAll, nil)
+
+	// Round trip through JSON so callers get copies they are free to modify
+	data, err := json.Marshal(tools)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	if resp.Error != nil {
-		return nil, fmt.Errorf("rpc error: %s", resp.Error.Message)
+	if err := json.Unmarshal(data, &tools); err != nil {
+		return nil, nil, err
 	}
-	
-	var result struct {
-		Tools []map[string]interface{} `json:"tools"`
+	return tools, listed, nil
+}
+
+// UpstreamStatuses returns the connection status of every enabled upstream.
+func (g *Gateway) UpstreamStatuses() []UpstreamStatus {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	statuses := make([]UpstreamStatus, 0, len(g.upstreams))
+	for _, client := range g.upstreams {
+		statuses = append(statuses, client.Status())
 	}
-	if err := json.Unmarshal(resp.Result, &result); err != nil {
-		return nil, err
+	return statuses
+}
+
+// UpstreamStatusByID returns the connection status of the server with the given
+// ID, if it is running.
+func (g *Gateway) UpstreamStatusByID(id uint) (UpstreamStatus, bool) {
+	client, ok := g.UpstreamByID(id)
+	if !ok {
+		return UpstreamStatus{}, false
 	}
-	
-	return result.Tools, nil
+	return client.Status(), true
+}
+
+// FilteredToolCounts returns, per server name, how many tools the last listing hid
+// because of tool selection.
+func (g *Gateway) FilteredToolCounts() map[string]int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	counts := make(map[string]int, len(g.upstreams))
+	for _, client := range g.upstreams {
+		counts[client.Config.Name] = client.FilteredCount()
+	}
+	return counts
+}
+
+// SchedulerStats returns fair-scheduler metrics for every upstream with a concurrency limit,
+// keyed by server name and then by API key ID.
+func (g *Gateway) SchedulerStats() map[string]map[string]SchedulerKeyStats {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	stats := make(map[string]map[string]SchedulerKeyStats)
+	for _, client := range g.upstreams {
+		if s := client.SchedulerStats(); s != nil {
+			stats[client.Config.Name] = s
+		}
+	}
+	return stats
+}
+
+// requestMeta returns a copy of the _meta object of a request's params, or nil,
+// to forward it with params the gateway rebuilds for the upstream.
+func requestMeta(req *JSONRPCMessage) map[string]interface{} {
+	var params struct {
+		Meta map[string]interface{} `json:"_meta"`
+	}
+	json.Unmarshal(req.Params, &params)
+	return params.Meta
+}
+
+// annotateResult sets key in the result's _meta object, leaving the result
+// unchanged if it is not a JSON object.
+func annotateResult(result json.RawMessage, key string, value interface{}) json.RawMessage {
+	// Numbers are kept verbatim, not rounded through float64
+	obj, err := decodeObject(result)
+	if err != nil {
+		return result
+	}
+
+	meta, _ := obj["_meta"].(map[string]interface{})
+	if meta == nil {
+		meta = make(map[string]interface{})
+	}
+	meta[key] = value
+	obj["_meta"] = meta
+
+	annotated, err := json.Marshal(obj)
+	if err != nil {
+		return result
+	}
+	return annotated
 }
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"one-mcp/internal/model"
+	"strings"
+)
+
+// reservedHeaders are set by the transports themselves, so upstreams cannot
+// configure them.
+var reservedHeaders = map[string]bool{
+	"Accept":               true,
+	"Connection":           true,
+	"Content-Length":       true,
+	"Content-Type":         true,
+	"Host":                 true,
+	"Last-Event-Id":        true,
+	"Mcp-Protocol-Version": true,
+	"Mcp-Session-Id":       true,
+	"Transfer-Encoding":    true,
+	"Upgrade":              true,
+}
+
+// ParseHeaders parses the custom headers of an upstream, a JSON object of
+// header names to values, e.g. {"X-Api-Key": "..."}. Empty means none.
+func ParseHeaders(raw string) (http.Header, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var values map[string]string
+	if err := json.Unmarshal([]byte(raw), &values); err != nil {
+		return nil, fmt.Errorf("headers must be a JSON object of strings: %v", err)
+	}
+	headers := make(http.Header, len(values))
+	for name, value := range values {
+		if !validHeaderName(name) {
+			return nil, fmt.Errorf("invalid header name %q", name)
+		}
+		if reservedHeaders[http.CanonicalHeaderKey(name)] {
+			return nil, fmt.Errorf("header %s is set by the gateway", http.CanonicalHeaderKey(name))
+		}
+		if strings.ContainsAny(value, "\r\n\x00") {
+			return nil, fmt.Errorf("invalid value of header %s", name)
+		}
+		headers.Set(name, value)
+	}
+	return headers, nil
+}
+
+// upstreamHeaders returns the custom headers of an upstream, ignoring them if
+// they are invalid (they are validated when saved).
+func upstreamHeaders(cfg model.UpstreamServer) http.Header {
+	headers, err := ParseHeaders(cfg.Headers)
+	if err != nil {
+		fmt.Printf("[Upstream %s] Ignoring custom headers: %v\n", cfg.Name, err)
+	}
+	return headers
+}
+
+// applyHeaders adds custom headers to a request to an upstream.
+func applyHeaders(req *http.Request, headers http.Header) {
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+}
+
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
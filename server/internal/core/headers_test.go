@@ -0,0 +1,61 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders("")
+	assert.NoError(t, err)
+	assert.Nil(t, headers)
+
+	headers, err = ParseHeaders(`{"x-api-key": "secret", "Cookie": "a=1"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", headers.Get("X-Api-Key"))
+	assert.Equal(t, "a=1", headers.Get("Cookie"))
+
+	for _, raw := range []string{
+		`["X-Api-Key"]`,
+		`{"X-Count": 1}`,
+		`{"Bad Name": "x"}`,
+		`{"X-Api-Key": "a\r\nX-Injected: 1"}`,
+		`{"mcp-session-id": "forged"}`,
+		`{"Host": "other.example.com"}`,
+	} {
+		_, err := ParseHeaders(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestStreamableHTTPCustomHeaders(t *testing.T) {
+	received := make(chan http.Header, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	cfg := model.UpstreamServer{
+		Name:      "hosted",
+		URL:       srv.URL,
+		AuthToken: "token",
+		Headers:   `{"X-Api-Key": "secret", "Authorization": "Basic ignored"}`,
+	}
+	transport := NewStreamableHTTPTransport(cfg, &config.Config{})
+	go transport.Start(t.Context(), func([]byte) {}, nil)
+	require.Eventually(t, func() bool {
+		return transport.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)) == nil
+	}, time.Second, 10*time.Millisecond)
+
+	headers := <-received
+	assert.Equal(t, "secret", headers.Get("X-Api-Key"))
+	assert.Equal(t, "Bearer token", headers.Get("Authorization"), "the auth token takes precedence")
+}
@@ -0,0 +1,179 @@
+package core
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The health of an upstream is judged on its recent tool calls: the last
+// healthWindow calls not older than healthMaxAge. With fewer than
+// healthMinCalls of them, it is presumed healthy.
+const (
+	healthWindow      = 20
+	healthMaxAge      = 15 * time.Minute
+	healthMinCalls    = 5
+	degradedErrorRate = 0.25 // Share of failed calls from which an upstream is degraded
+)
+
+// latencyTiers are the upper bounds of the average call latency of each rank
+// of healthy upstreams; slower ones get the rank after the last bound.
+var latencyTiers = []time.Duration{250 * time.Millisecond, time.Second, 5 * time.Second}
+
+// rankDegraded is the rank of degraded upstreams, listed last.
+var rankDegraded = len(latencyTiers) + 1
+
+// UpstreamHealth summarizes the recent tool calls of an upstream. Rank orders
+// the tools/list with the health_order flag: lower ranks are listed first.
+type UpstreamHealth struct {
+	Rank         int     `json:"rank"`
+	Degraded     bool    `json:"degraded"`
+	Calls        int     `json:"calls"`          // Recent calls the health is judged on
+	ErrorRate    float64 `json:"error_rate"`     // Share of them that failed
+	AvgLatencyMs int64   `json:"avg_latency_ms"` // Average duration of them
+}
+
+type callOutcome struct {
+	at       time.Time
+	duration time.Duration
+	failed   bool
+}
+
+// callHealth keeps the outcomes of the last tool calls of an upstream.
+type callHealth struct {
+	mu          sync.Mutex
+	recent      [healthWindow]callOutcome
+	next        int
+	count       int
+	lastSuccess time.Time // Kept beyond the window, for the status API
+}
+
+func (h *callHealth) record(duration time.Duration, failed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.recent[h.next] = callOutcome{at: time.Now(), duration: duration, failed: failed}
+	if !failed {
+		h.lastSuccess = h.recent[h.next].at
+	}
+	h.next = (h.next + 1) % healthWindow
+	if h.count < healthWindow {
+		h.count++
+	}
+}
+
+// lastSuccessAt returns when the last successful call ended, or the zero time.
+func (h *callHealth) lastSuccessAt() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastSuccess
+}
+
+// summary judges the calls recorded since healthMaxAge before now. Upstreams
+// that are not ready are degraded whatever their calls.
+func (h *callHealth) summary(now time.Time, ready bool) UpstreamHealth {
+	h.mu.Lock()
+	var health UpstreamHealth
+	var failed int
+	var total time.Duration
+	for i := 0; i < h.count; i++ {
+		outcome := h.recent[i]
+		if now.Sub(outcome.at) > healthMaxAge {
+			continue
+		}
+		health.Calls++
+		total += outcome.duration
+		if outcome.failed {
+			failed++
+		}
+	}
+	h.mu.Unlock()
+
+	if health.Calls > 0 {
+		health.ErrorRate = float64(failed) / float64(health.Calls)
+		health.AvgLatencyMs = (total / time.Duration(health.Calls)).Milliseconds()
+	}
+	switch {
+	case !ready || (health.Calls >= healthMinCalls && health.ErrorRate >= degradedErrorRate):
+		health.Degraded = true
+		health.Rank = rankDegraded
+	case health.Calls >= healthMinCalls:
+		avg := total / time.Duration(health.Calls)
+		health.Rank = len(latencyTiers)
+		for i, bound := range latencyTiers {
+			if avg <= bound {
+				health.Rank = i
+				break
+			}
+		}
+	}
+	return health
+}
+
+// Health returns the health of the upstream judged on its recent tool calls.
+func (c *UpstreamClient) Health() UpstreamHealth {
+	return c.health.summary(time.Now(), c.IsReady())
+}
+
+// serverHealth returns the health of every upstream by server name.
+func (g *Gateway) serverHealth() map[string]UpstreamHealth {
+	g.mu.RLock()
+	clients := make([]*UpstreamClient, 0, len(g.upstreams))
+	for _, c := range g.upstreams {
+		clients = append(clients, c)
+	}
+	g.mu.RUnlock()
+
+	health := make(map[string]UpstreamHealth, len(clients))
+	for _, c := range clients {
+		health[c.Config.Name] = c.Health()
+	}
+	return health
+}
+
+// toolRank returns the rank of a prefixed tool name; tools served by the
+// gateway itself rank first.
+func toolRank(health map[string]UpstreamHealth, name string) int {
+	server, _, _ := strings.Cut(name, "__")
+	return health[server].Rank
+}
+
+// annotateDegradedTools marks the tools of degraded upstreams in a tools/list
+// result with _meta["one-mcp/health"], for clients and agents to avoid them.
+func annotateDegradedTools(resp *JSONRPCMessage, health map[string]UpstreamHealth) {
+	if resp == nil || resp.Error != nil {
+		return
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return
+	}
+	tools, _ := result["tools"].([]interface{})
+	changed := false
+	for _, t := range tools {
+		tool, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := tool["name"].(string)
+		server, _, _ := strings.Cut(name, "__")
+		h, ok := health[server]
+		if !ok || !h.Degraded {
+			continue
+		}
+		meta, _ := tool["_meta"].(map[string]interface{})
+		if meta == nil {
+			meta = map[string]interface{}{}
+		}
+		meta["one-mcp/health"] = map[string]interface{}{
+			"status":       "degraded",
+			"errorRate":    h.ErrorRate,
+			"avgLatencyMs": h.AvgLatencyMs,
+		}
+		tool["_meta"] = meta
+		changed = true
+	}
+	if changed {
+		resp.Result, _ = json.Marshal(result)
+	}
+}
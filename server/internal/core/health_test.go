@@ -0,0 +1,115 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallHealth(t *testing.T) {
+	record := func(h *callHealth, n int, duration time.Duration, failed bool) {
+		for i := 0; i < n; i++ {
+			h.record(duration, failed)
+		}
+	}
+	now := time.Now()
+
+	t.Run("Presumed Healthy", func(t *testing.T) {
+		var h callHealth
+		record(&h, healthMinCalls-1, 10*time.Second, true)
+		health := h.summary(now, true)
+		assert.False(t, health.Degraded)
+		assert.Equal(t, 0, health.Rank)
+		assert.Equal(t, 1.0, health.ErrorRate)
+
+		assert.Equal(t, rankDegraded, h.summary(now, false).Rank, "upstreams that are not ready are degraded")
+	})
+
+	t.Run("Latency Tiers", func(t *testing.T) {
+		for duration, rank := range map[time.Duration]int{
+			100 * time.Millisecond: 0,
+			500 * time.Millisecond: 1,
+			2 * time.Second:        2,
+			30 * time.Second:       3,
+		} {
+			var h callHealth
+			record(&h, healthMinCalls, duration, false)
+			health := h.summary(now, true)
+			assert.Equal(t, rank, health.Rank, duration.String())
+			assert.Equal(t, duration.Milliseconds(), health.AvgLatencyMs)
+		}
+	})
+
+	t.Run("Degraded", func(t *testing.T) {
+		var h callHealth
+		record(&h, 15, 50*time.Millisecond, false)
+		record(&h, 5, 50*time.Millisecond, true)
+		health := h.summary(now, true)
+		assert.True(t, health.Degraded)
+		assert.Equal(t, rankDegraded, health.Rank)
+		assert.Equal(t, 0.25, health.ErrorRate)
+
+		// Only the last calls count, and only recent ones
+		record(&h, healthWindow, 50*time.Millisecond, false)
+		assert.False(t, h.summary(now, true).Degraded)
+		assert.Equal(t, 0, h.summary(now.Add(healthMaxAge+time.Minute), true).Calls)
+	})
+
+	t.Run("Last Success", func(t *testing.T) {
+		var h callHealth
+		assert.True(t, h.lastSuccessAt().IsZero())
+		record(&h, 1, time.Millisecond, false)
+		last := h.lastSuccessAt()
+		assert.False(t, last.IsZero())
+		record(&h, healthWindow, time.Millisecond, true)
+		assert.Equal(t, last, h.lastSuccessAt(), "failed calls leave it, even past the window")
+	})
+}
+
+func TestHealthOrder(t *testing.T) {
+	health := map[string]UpstreamHealth{
+		"fast":  {Rank: 0},
+		"slow":  {Rank: 3},
+		"flaky": {Rank: rankDegraded, Degraded: true, ErrorRate: 0.5, AvgLatencyMs: 120},
+	}
+	list := func() *JSONRPCMessage {
+		result, _ := json.Marshal(map[string]interface{}{"tools": []map[string]interface{}{
+			{"name": "flaky__a", "_meta": map[string]interface{}{"owner": "ops"}},
+			{"name": "slow__a"}, {"name": "fast__b"}, {"name": "memory__kv_get"}, {"name": "fast__a"},
+		}})
+		return &JSONRPCMessage{JSONRPC: "2.0", Result: result}
+	}
+	type listed struct {
+		Tools []struct {
+			Name string                 `json:"name"`
+			Meta map[string]interface{} `json:"_meta"`
+		} `json:"tools"`
+		NextCursor string `json:"nextCursor"`
+	}
+
+	var all []string
+	cursor := ""
+	for pages := 0; pages < 5; pages++ {
+		resp := list()
+		annotateDegradedTools(resp, health)
+		assert.True(t, paginateTools(resp, cursor, 2, health))
+		var page listed
+		json.Unmarshal(resp.Result, &page)
+		for _, tool := range page.Tools {
+			all = append(all, tool.Name)
+			if tool.Name == "flaky__a" {
+				assert.Equal(t, "ops", tool.Meta["owner"])
+				assert.Equal(t, map[string]interface{}{"status": "degraded", "errorRate": 0.5, "avgLatencyMs": 120.0}, tool.Meta["one-mcp/health"])
+			} else {
+				assert.Nil(t, tool.Meta["one-mcp/health"])
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"fast__a", "fast__b", "memory__kv_get", "slow__a", "flaky__a"}, all)
+}
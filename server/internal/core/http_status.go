@@ -0,0 +1,92 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StatusMapping describes how an HTTP status code maps to a tool outcome.
+// "success" returns the body as a normal result, "error" marks the result as an
+// error, and any other outcome (e.g. "not_found") returns a structured result.
+type StatusMapping struct {
+	Outcome string `json:"outcome"`
+	Message string `json:"message,omitempty"` // Returned instead of the response body
+}
+
+// maxRetryDelay caps the wait between retries, including Retry-After values.
+const maxRetryDelay = 30 * time.Second
+
+// statusMapping finds the mapping for a status code, preferring an exact
+// code over its class.
+func (tc ToolConfig) statusMapping(status int) (StatusMapping, bool) {
+	if m, ok := tc.StatusMap[strconv.Itoa(status)]; ok {
+		return m, true
+	}
+	m, ok := tc.StatusMap[fmt.Sprintf("%dxx", status/100)]
+	return m, ok
+}
+
+// statusResult builds the tools/call result for an HTTP response.
+func (tc ToolConfig) statusResult(status int, body string) map[string]interface{} {
+	text := func(s string) []interface{} {
+		return []interface{}{map[string]interface{}{"type": "text", "text": s}}
+	}
+
+	m, ok := tc.statusMapping(status)
+	if !ok {
+		if status >= 400 {
+			return map[string]interface{}{"content": text(fmt.Sprintf("HTTP Error %d: %s", status, body))}
+		}
+		return map[string]interface{}{"content": text(body)}
+	}
+
+	message := body
+	if m.Message != "" {
+		message = m.Message
+	}
+	switch m.Outcome {
+	case "success":
+		return map[string]interface{}{"content": text(message)}
+	case "error":
+		if m.Message == "" {
+			message = fmt.Sprintf("HTTP Error %d: %s", status, body)
+		}
+		return map[string]interface{}{"content": text(message), "isError": true}
+	}
+
+	structured := map[string]interface{}{
+		"status":  status,
+		"outcome": m.Outcome,
+		"message": message,
+	}
+	return map[string]interface{}{
+		"content":           text(fmt.Sprintf("%s (HTTP %d): %s", m.Outcome, status, message)),
+		"structuredContent": structured,
+	}
+}
+
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryDelay honours a Retry-After header (seconds or HTTP date) and otherwise
+// backs off exponentially from 500ms.
+func retryDelay(retryAfter string, attempt int) time.Duration {
+	delay := 500 * time.Millisecond << attempt
+	if retryAfter != "" {
+		if secs, err := strconv.Atoi(retryAfter); err == nil && secs >= 0 {
+			delay = time.Duration(secs) * time.Second
+		} else if at, err := http.ParseTime(retryAfter); err == nil {
+			delay = time.Until(at)
+			if delay < 0 {
+				delay = 0
+			}
+		}
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
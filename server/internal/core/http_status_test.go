@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusResult(t *testing.T) {
+	tc := ToolConfig{StatusMap: map[string]StatusMapping{
+		"404": {Outcome: "not_found", Message: "No such user"},
+		"4xx": {Outcome: "error"},
+		"204": {Outcome: "success", Message: "Deleted"},
+	}}
+
+	res := tc.statusResult(404, "missing")
+	assert.Nil(t, res["isError"])
+	assert.Equal(t, map[string]interface{}{"status": 404, "outcome": "not_found", "message": "No such user"}, res["structuredContent"])
+
+	res = tc.statusResult(400, "bad")
+	assert.Equal(t, true, res["isError"])
+
+	res = tc.statusResult(204, "")
+	assert.Equal(t, "Deleted", res["content"].([]interface{})[0].(map[string]interface{})["text"])
+
+	res = tc.statusResult(500, "boom")
+	assert.Nil(t, res["isError"])
+	assert.Equal(t, "HTTP Error 500: boom", res["content"].([]interface{})[0].(map[string]interface{})["text"])
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, 2*time.Second, retryDelay("2", 0))
+	assert.Equal(t, time.Second, retryDelay("", 1))
+	assert.Equal(t, maxRetryDelay, retryDelay("3600", 0))
+	assert.Equal(t, time.Duration(0), retryDelay(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0))
+}
+
+func TestHTTPToolRetries(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	settings := &config.Config{HTTPToolTimeout: 5 * time.Second, HTTPToolRetries: 2}
+	tr := NewHTTPTransport(model.UpstreamServer{Name: "api", URL: srv.URL}, settings, nil)
+	resp, err := tr.executeHTTPRequest(context.Background(), map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.Status)
+	assert.Equal(t, "ok", resp.Body)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	none := 0
+	tr.ToolConfig.Retries = &none
+	resp, err = tr.executeHTTPRequest(context.Background(), map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Equal(t, 503, resp.Status)
+	assert.Equal(t, 1, calls)
+}
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	h := http.Header{}
+	assert.Nil(t, ParseRateLimit(h, 200, now))
+
+	h.Set("X-RateLimit-Limit", "100")
+	h.Set("X-RateLimit-Remaining", "5")
+	h.Set("X-RateLimit-Reset", "60")
+	rl := ParseRateLimit(h, 200, now)
+	assert.Equal(t, 100, *rl.Limit)
+	assert.Equal(t, 5, *rl.Remaining)
+	assert.Equal(t, now.Add(time.Minute), *rl.Reset)
+	assert.True(t, rl.Low)
+
+	h = http.Header{}
+	h.Set("RateLimit-Remaining", "50;w=60")
+	h.Set("RateLimit-Limit", "100")
+	h.Set("RateLimit-Reset", "1700000300")
+	rl = ParseRateLimit(h, 200, now)
+	assert.Equal(t, 50, *rl.Remaining)
+	assert.Equal(t, now.Add(5*time.Minute), *rl.Reset)
+	assert.False(t, rl.Low)
+
+	h = http.Header{}
+	h.Set("Retry-After", "30")
+	rl = ParseRateLimit(h, 429, now)
+	assert.Equal(t, 30, rl.RetryAfter)
+	assert.True(t, rl.Low)
+}
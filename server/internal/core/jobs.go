@@ -0,0 +1,288 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"one-mcp/internal/model"
+	"one-mcp/internal/report"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobURIPrefix is the resource URI prefix under which asynchronous job results are readable.
+const JobURIPrefix = "one-mcp://jobs/"
+
+// Job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// jobRetryDelay is the delay before the first automatic retry; it grows linearly.
+const jobRetryDelay = 10 * time.Second
+
+// ParseAsyncTools decodes the JSON array of async tool glob patterns.
+func ParseAsyncTools(raw string) ([]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var patterns []string
+	if err := json.Unmarshal([]byte(raw), &patterns); err != nil {
+		return nil, fmt.Errorf("invalid async_tools: %v", err)
+	}
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid async tool pattern %q: %v", p, err)
+		}
+	}
+	return patterns, nil
+}
+
+// AsyncTool reports whether calls to the (unprefixed) tool run asynchronously.
+func (c *UpstreamClient) AsyncTool(name string) bool {
+	for _, p := range c.asyncTools {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// startAsyncToolCall queues the tool call as a job and immediately returns a result
+// carrying the job ID.
+func (g *Gateway) startAsyncToolCall(req *JSONRPCMessage, caller *Caller, client *UpstreamClient, fullName string, args interface{}) *JSONRPCMessage {
+	argsJSON, _ := json.Marshal(args)
+	job := model.AsyncJob{
+		ID:        uuid.New().String(),
+		KeyID:     caller.KeyID,
+		Server:    client.Config.Name,
+		Tool:      fullName,
+		Arguments: string(argsJSON),
+		Status:    JobQueued,
+	}
+	if err := g.db.Create(&job).Error; err != nil {
+		return &JSONRPCMessage{
+			JSONRPC: "2.0", ID: req.ID,
+			Error: &JSONRPCError{Code: -32603, Message: "Failed to create job: " + err.Error()},
+		}
+	}
+	go g.runJob(job.ID)
+
+	uri := JobURIPrefix + job.ID
+	result, _ := json.Marshal(map[string]interface{}{
+		"content": []interface{}{
+			map[string]interface{}{
+				"type": "text",
+				"text": fmt.Sprintf("Started asynchronous job %s. Read the resource %s to get the result.", job.ID, uri),
+			},
+		},
+	})
+	result = annotateResult(result, "jobId", job.ID)
+	return &JSONRPCMessage{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+// ResumeJobs restarts jobs left unfinished by a previous run of the gateway.
+func (g *Gateway) ResumeJobs() {
+	var jobs []model.AsyncJob
+	g.db.Where("status IN ?", []string{JobQueued, JobRunning}).Find(&jobs)
+	for _, job := range jobs {
+		g.db.Model(&model.AsyncJob{}).Where("id = ?", job.ID).Update("status", JobQueued)
+		fmt.Printf("[Jobs] Resuming job %s (%s)\n", job.ID, job.Tool)
+		go g.runJob(job.ID)
+	}
+}
+
+// RetryJob re-runs a failed or cancelled job from scratch.
+func (g *Gateway) RetryJob(id string) error {
+	res := g.db.Model(&model.AsyncJob{}).
+		Where("id = ? AND status IN ?", id, []string{JobFailed, JobCancelled}).
+		Updates(map[string]interface{}{"status": JobQueued, "attempts": 0, "result": "", "error": "", "finished_at": nil})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("only failed or cancelled jobs can be retried")
+	}
+	go g.runJob(id)
+	return nil
+}
+
+// CancelJob cancels a queued or running job. A running upstream call is not
+// interrupted, but its result is discarded.
+func (g *Gateway) CancelJob(id string) error {
+	now := time.Now()
+	res := g.db.Model(&model.AsyncJob{}).
+		Where("id = ? AND status IN ?", id, []string{JobQueued, JobRunning}).
+		Updates(map[string]interface{}{"status": JobCancelled, "finished_at": &now})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("only queued or running jobs can be cancelled")
+	}
+	return nil
+}
+
+// runJob executes a queued job, retrying calls that fail to reach the upstream.
+func (g *Gateway) runJob(id string) {
+	defer report.Recover("async job " + id)
+
+	for {
+		var job model.AsyncJob
+		if err := g.db.First(&job, "id = ?", id).Error; err != nil {
+			return
+		}
+
+		// Claim the job; a concurrent cancel wins
+		claim := g.db.Model(&model.AsyncJob{}).Where("id = ? AND status = ?", id, JobQueued).
+			Updates(map[string]interface{}{"status": JobRunning, "attempts": job.Attempts + 1})
+		if claim.RowsAffected == 0 {
+			return
+		}
+		job.Attempts++
+
+		resp := g.executeJob(&job)
+		retriable := resp.Error != nil && resp.Error.Code == -32000
+		if retriable && job.Attempts <= g.settings.AsyncRetries {
+			fmt.Printf("[Jobs] Job %s attempt %d failed, retrying: %s\n", id, job.Attempts, resp.Error.Message)
+			g.db.Model(&model.AsyncJob{}).Where("id = ? AND status = ?", id, JobRunning).
+				Updates(map[string]interface{}{"status": JobQueued, "error": resp.Error.Message})
+			time.Sleep(time.Duration(job.Attempts) * jobRetryDelay)
+			continue
+		}
+
+		now := time.Now()
+		status := JobSucceeded
+		errMsg := ""
+		if resp.Error != nil {
+			status = JobFailed
+			errMsg = resp.Error.Message
+		} else if resultIsError(resp.Result) {
+			status = JobFailed
+			errMsg = "tool returned isError"
+		}
+		done := g.db.Model(&model.AsyncJob{}).Where("id = ? AND status = ?", id, JobRunning).
+			Updates(map[string]interface{}{"status": status, "result": string(resp.Result), "error": errMsg, "finished_at": &now})
+		if done.RowsAffected == 0 {
+			fmt.Printf("[Jobs] Job %s was cancelled, discarding result\n", id)
+			return
+		}
+		fmt.Printf("[Jobs] Job %s (%s) %s\n", id, job.Tool, status)
+
+		g.db.First(&job, "id = ?", id)
+		client, ok := g.upstream(job.Server)
+		if ok && client.Config.AsyncWebhook != "" {
+			g.deliverJob(client.Config.AsyncWebhook, &job)
+		}
+		return
+	}
+}
+
+// executeJob performs one attempt of a job on behalf of the key that started it.
+func (g *Gateway) executeJob(job *model.AsyncJob) *JSONRPCMessage {
+	fail := func(code int, msg string) *JSONRPCMessage {
+		return &JSONRPCMessage{JSONRPC: "2.0", Error: &JSONRPCError{Code: code, Message: msg}}
+	}
+
+	var apiKey model.ApiKey
+	if err := g.db.First(&apiKey, job.KeyID).Error; err != nil {
+		return fail(-32603, "API key no longer exists")
+	}
+	if apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt) {
+		return fail(-32603, "API key expired")
+	}
+	caller := CallerForKey(g.db, &apiKey)
+
+	client, ok := g.upstream(job.Server)
+	if !ok {
+		return fail(-32000, "Server not found")
+	}
+
+	var args interface{}
+	json.Unmarshal([]byte(job.Arguments), &args)
+	toolName := strings.TrimPrefix(job.Tool, job.Server+"__")
+	idRaw := json.RawMessage(`0`)
+	req := &JSONRPCMessage{JSONRPC: "2.0", ID: &idRaw, Method: "tools/call"}
+	return g.callUpstreamTool(req, caller, g.permissionCheck(caller), client, toolName, job.Tool, args, g.settings.AsyncTimeout)
+}
+
+// JobView renders a job for API consumers, embedding the result as JSON.
+func JobView(job *model.AsyncJob) map[string]interface{} {
+	view := map[string]interface{}{
+		"id":          job.ID,
+		"created_at":  job.CreatedAt,
+		"key_id":      job.KeyID,
+		"tool":        job.Tool,
+		"status":      job.Status,
+		"attempts":    job.Attempts,
+		"finished_at": job.FinishedAt,
+	}
+	if job.Result != "" {
+		view["result"] = json.RawMessage(job.Result)
+	}
+	if job.Error != "" {
+		view["error"] = job.Error
+	}
+	return view
+}
+
+// deliverJob posts a finished job to the upstream's webhook, within EGRESS_ALLOWLIST.
+func (g *Gateway) deliverJob(webhook string, job *model.AsyncJob) {
+	payload, _ := json.Marshal(JobView(job))
+	httpClient := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: egressPolicy(g.settings.EgressAllowlist).transport(),
+	}
+	resp, err := httpClient.Post(webhook, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		fmt.Printf("[Jobs] Failed to deliver job %s to webhook: %v\n", job.ID, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		fmt.Printf("[Jobs] Webhook rejected job %s with status %d\n", job.ID, resp.StatusCode)
+	}
+}
+
+// handleResourceRead serves gateway resources and routes other URIs to the upstreams.
+// Jobs are only readable by the key that started them.
+func (g *Gateway) handleResourceRead(req *JSONRPCMessage, caller *Caller) (*JSONRPCMessage, error) {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	json.Unmarshal(req.Params, &params)
+
+	notFound := &JSONRPCMessage{
+		JSONRPC: "2.0", ID: req.ID,
+		Error: &JSONRPCError{Code: -32002, Message: "Resource not found"},
+	}
+	if !strings.HasPrefix(params.URI, JobURIPrefix) {
+		return g.readUpstreamResource(req, caller, params.URI), nil
+	}
+
+	var job model.AsyncJob
+	err := g.db.First(&job, "id = ?", strings.TrimPrefix(params.URI, JobURIPrefix)).Error
+	if err != nil || job.KeyID != caller.KeyID {
+		return notFound, nil
+	}
+
+	text, _ := json.Marshal(JobView(&job))
+	result, _ := json.Marshal(map[string]interface{}{
+		"contents": []interface{}{
+			map[string]interface{}{
+				"uri":      params.URI,
+				"mimeType": "application/json",
+				"text":     string(text),
+			},
+		},
+	})
+	return &JSONRPCMessage{JSONRPC: "2.0", ID: req.ID, Result: result}, nil
+}
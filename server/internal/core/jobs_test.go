@@ -0,0 +1,201 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestParseAsyncTools(t *testing.T) {
+	patterns, err := ParseAsyncTools(`["export_*", "render"]`)
+	assert.NoError(t, err)
+	client := &UpstreamClient{asyncTools: patterns}
+	assert.True(t, client.AsyncTool("export_csv"))
+	assert.True(t, client.AsyncTool("render"))
+	assert.False(t, client.AsyncTool("get_issue"))
+
+	patterns, err = ParseAsyncTools("")
+	assert.NoError(t, err)
+	assert.Nil(t, patterns)
+	_, err = ParseAsyncTools(`["[a-"]`)
+	assert.Error(t, err)
+	_, err = ParseAsyncTools(`"export_*"`)
+	assert.Error(t, err)
+}
+
+func TestAsyncJobs(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.AsyncJob{}, &model.ApiKey{}, &model.UsageLog{}))
+	key := model.ApiKey{Key: "sk-jobs"}
+	db.Create(&key)
+	caller := &Caller{KeyID: key.ID}
+
+	webhook := make(chan map[string]interface{}, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var job map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&job)
+		webhook <- job
+	}))
+	defer hook.Close()
+
+	g := NewGateway(db, &config.Config{AsyncTimeout: time.Second})
+	client := newResultClient("reports", 1, `{"content":[{"type":"text","text":"done"}]}`)
+	client.selector = &ToolSelector{}
+	client.asyncTools = []string{"export_*"}
+	client.Config.AsyncWebhook = hook.URL
+	register := func() {
+		g.mu.Lock()
+		g.upstreams[1] = client
+		g.upstreamIDs["reports"] = 1
+		g.mu.Unlock()
+	}
+	status := func(id string) func() bool {
+		return func() bool {
+			var job model.AsyncJob
+			db.First(&job, "id = ?", id)
+			return job.Status != JobQueued && job.Status != JobRunning
+		}
+	}
+	load := func(id string) model.AsyncJob {
+		var job model.AsyncJob
+		db.First(&job, "id = ?", id)
+		return job
+	}
+	register()
+
+	t.Run("Runs In The Background", func(t *testing.T) {
+		resp, err := g.HandleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"reports__export_csv","arguments":{"year":2025}}}`), caller)
+		require.NoError(t, err)
+		require.Nil(t, resp.Error)
+		var result struct {
+			Meta map[string]string `json:"_meta"`
+		}
+		json.Unmarshal(resp.Result, &result)
+		id := result.Meta["jobId"]
+		require.NotEmpty(t, id)
+
+		assert.Eventually(t, status(id), 2*time.Second, 10*time.Millisecond)
+		job := load(id)
+		assert.Equal(t, JobSucceeded, job.Status)
+		assert.Equal(t, 1, job.Attempts)
+		assert.JSONEq(t, `{"year":2025}`, job.Arguments)
+
+		select {
+		case delivered := <-webhook:
+			assert.Equal(t, id, delivered["id"])
+			assert.Equal(t, JobSucceeded, delivered["status"])
+		case <-time.After(2 * time.Second):
+			t.Fatal("webhook not called")
+		}
+
+		// The result is a resource of the key that started the job
+		read := func(caller *Caller) *JSONRPCMessage {
+			req := &JSONRPCMessage{Method: "resources/read", Params: json.RawMessage(`{"uri":"` + JobURIPrefix + id + `"}`)}
+			resp, _ := g.handleResourceRead(req, caller)
+			return resp
+		}
+		resp = read(caller)
+		assert.Nil(t, resp.Error)
+		assert.Contains(t, string(resp.Result), "done")
+		assert.NotNil(t, read(&Caller{KeyID: key.ID + 1}).Error)
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		// The server is gone: the job fails once the retries (none here) are used up
+		g.mu.Lock()
+		delete(g.upstreamIDs, "reports")
+		g.mu.Unlock()
+		job := model.AsyncJob{ID: "retry", KeyID: key.ID, Server: "reports", Tool: "reports__export_csv", Status: JobQueued}
+		db.Create(&job)
+		g.runJob(job.ID)
+		job = load(job.ID)
+		assert.Equal(t, JobFailed, job.Status)
+		assert.Equal(t, "Server not found", job.Error)
+		assert.Error(t, g.CancelJob(job.ID), "finished jobs cannot be cancelled")
+
+		register()
+		require.NoError(t, g.RetryJob(job.ID))
+		assert.Eventually(t, status(job.ID), 2*time.Second, 10*time.Millisecond)
+		job = load(job.ID)
+		assert.Equal(t, JobSucceeded, job.Status)
+		assert.Equal(t, 1, job.Attempts, "a retry starts from scratch")
+		assert.Empty(t, job.Error)
+		assert.Error(t, g.RetryJob(job.ID), "succeeded jobs cannot be retried")
+		<-webhook
+	})
+
+	t.Run("Expired Key", func(t *testing.T) {
+		expired := time.Now().Add(-time.Minute)
+		stale := model.ApiKey{Key: "sk-expired", ExpiresAt: &expired}
+		db.Create(&stale)
+		g.settings.AsyncRetries = 3
+		defer func() { g.settings.AsyncRetries = 0 }()
+
+		job := model.AsyncJob{ID: "expired", KeyID: stale.ID, Server: "reports", Tool: "reports__export_csv", Status: JobQueued}
+		db.Create(&job)
+		g.runJob(job.ID)
+		job = load(job.ID)
+		assert.Equal(t, JobFailed, job.Status)
+		assert.Equal(t, "API key expired", job.Error)
+		assert.Equal(t, 1, job.Attempts, "not retried")
+		<-webhook
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		job := model.AsyncJob{ID: "cancel", KeyID: key.ID, Server: "reports", Tool: "reports__export_csv", Status: JobQueued}
+		db.Create(&job)
+		require.NoError(t, g.CancelJob(job.ID))
+		g.runJob(job.ID)
+		job = load(job.ID)
+		assert.Equal(t, JobCancelled, job.Status, "a cancelled job does not run")
+		assert.Equal(t, 0, job.Attempts)
+		assert.NotNil(t, job.FinishedAt)
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		// Left running by a previous run of the gateway
+		job := model.AsyncJob{ID: "resume", KeyID: key.ID, Server: "reports", Tool: "reports__export_csv", Status: JobRunning, Attempts: 1}
+		db.Create(&job)
+		g.ResumeJobs()
+		assert.Eventually(t, status(job.ID), 2*time.Second, 10*time.Millisecond)
+		job = load(job.ID)
+		assert.Equal(t, JobSucceeded, job.Status)
+		assert.Equal(t, 2, job.Attempts)
+		<-webhook
+	})
+}
+
+func TestJobWebhookEgress(t *testing.T) {
+	delivered := make(chan struct{}, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered <- struct{}{}
+	}))
+	defer hook.Close()
+	job := &model.AsyncJob{ID: "job", Tool: "reports__export_csv", Status: JobSucceeded}
+
+	g := NewGateway(nil, &config.Config{EgressAllowlist: []string{"hooks.example.com"}})
+	g.deliverJob(hook.URL, job)
+	select {
+	case <-delivered:
+		t.Fatal("webhook outside EGRESS_ALLOWLIST called")
+	default:
+	}
+
+	g = NewGateway(nil, &config.Config{EgressAllowlist: []string{"127.0.0.1"}})
+	g.deliverJob(hook.URL, job)
+	select {
+	case <-delivered:
+	default:
+		t.Fatal("allowed webhook not called")
+	}
+}
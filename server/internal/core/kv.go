@@ -0,0 +1,288 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-mcp/internal/model"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MemoryServer is the server prefix of the key-value memory tools enabled by
+// KV_ENABLED. Like the demo upstream, they are not served while an upstream
+// with this name is configured.
+const MemoryServer = "memory"
+
+// maxKVNameLength bounds the length of the names values are stored under.
+const maxKVNameLength = 256
+
+// memoryTool is a tool served by the gateway on the KV store, run in the
+// namespace of the calling key.
+type memoryTool struct {
+	Description string
+	Parameters  []ToolParameter
+	ReadOnly    bool
+	Run         func(g *Gateway, keyID uint, args map[string]interface{}) (string, error)
+}
+
+var memoryTools = map[string]memoryTool{
+	"kv_set": {
+		Description: "Stores a text value under a name, replacing any previous value. Values persist across sessions until deleted or expired.",
+		Parameters: []ToolParameter{
+			{Name: "key", Type: "string", Required: true},
+			{Name: "value", Type: "string", Required: true},
+			{Name: "ttl_seconds", Type: "integer", Description: "Delete the value after this many seconds (default: keep)"},
+		},
+		Run: func(g *Gateway, keyID uint, args map[string]interface{}) (string, error) {
+			name, _ := args["key"].(string)
+			value, _ := args["value"].(string)
+			ttl, _ := args["ttl_seconds"].(float64)
+			if err := g.kvSet(keyID, name, value, time.Duration(ttl)*time.Second); err != nil {
+				return "", err
+			}
+			return "Stored " + name, nil
+		},
+	},
+	"kv_get": {
+		Description: "Returns the value stored under a name.",
+		Parameters: []ToolParameter{
+			{Name: "key", Type: "string", Required: true},
+		},
+		ReadOnly: true,
+		Run: func(g *Gateway, keyID uint, args map[string]interface{}) (string, error) {
+			name, _ := args["key"].(string)
+			entry, err := g.kvGet(keyID, name)
+			if err != nil {
+				return "", err
+			}
+			return entry.Value, nil
+		},
+	},
+	"kv_list": {
+		Description: "Lists the stored names, optionally only those starting with a prefix, with their size and expiry.",
+		Parameters: []ToolParameter{
+			{Name: "prefix", Type: "string"},
+		},
+		ReadOnly: true,
+		Run: func(g *Gateway, keyID uint, args map[string]interface{}) (string, error) {
+			prefix, _ := args["prefix"].(string)
+			entries, err := g.kvList(keyID, prefix)
+			if err != nil {
+				return "", err
+			}
+			type listed struct {
+				Key       string     `json:"key"`
+				Size      int        `json:"size"`
+				ExpiresAt *time.Time `json:"expires_at,omitempty"`
+			}
+			keys := make([]listed, 0, len(entries))
+			for _, e := range entries {
+				keys = append(keys, listed{Key: e.Name, Size: len(e.Value), ExpiresAt: e.ExpiresAt})
+			}
+			out, _ := json.Marshal(map[string]interface{}{"keys": keys})
+			return string(out), nil
+		},
+	},
+	"kv_delete": {
+		Description: "Deletes the value stored under a name.",
+		Parameters: []ToolParameter{
+			{Name: "key", Type: "string", Required: true},
+		},
+		Run: func(g *Gateway, keyID uint, args map[string]interface{}) (string, error) {
+			name, _ := args["key"].(string)
+			if err := g.kvDelete(keyID, name); err != nil {
+				return "", err
+			}
+			return "Deleted " + name, nil
+		},
+	},
+}
+
+var errKVNotFound = errors.New("key not found")
+
+// memoryServed reports whether the memory tools are served: KV_ENABLED is set and
+// no upstream took their name.
+func (g *Gateway) memoryServed() bool {
+	if g.settings == nil || !g.settings.KVEnabled {
+		return false
+	}
+	_, taken := g.upstream(MemoryServer)
+	return !taken
+}
+
+// memoryToolList returns the memory tools the caller may use as tool definitions.
+func (g *Gateway) memoryToolList(hasPermission func(string, string) bool) []map[string]interface{} {
+	if !g.memoryServed() {
+		return nil
+	}
+	names := make([]string, 0, len(memoryTools))
+	for name := range memoryTools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tools []map[string]interface{}
+	for _, name := range names {
+		full := MemoryServer + "__" + name
+		if !hasPermission(MemoryServer, full) {
+			continue
+		}
+		tool := memoryTools[name]
+		annotations := map[string]interface{}{"readOnlyHint": tool.ReadOnly, "openWorldHint": false}
+		if !tool.ReadOnly {
+			annotations["idempotentHint"] = true
+			annotations["destructiveHint"] = name == "kv_delete"
+		}
+		tools = append(tools, map[string]interface{}{
+			"name":        full,
+			"description": tool.Description,
+			"inputSchema": parameterSchema(ToolParameter{Type: "object", Properties: tool.Parameters}),
+			"annotations": annotations,
+		})
+	}
+	return tools
+}
+
+// runMemoryTool serves a call of a memory tool. Failures, such as an exceeded
+// quota, are tool results with isError set so agents can react to them. Calls are
+// recorded and their results signed like those of upstream tools.
+func (g *Gateway) runMemoryTool(req *JSONRPCMessage, caller *Caller, hasPermission func(string, string) bool, name string, input interface{}) *JSONRPCMessage {
+	tool, ok := memoryTools[name]
+	if !ok {
+		return &JSONRPCMessage{
+			JSONRPC: "2.0", ID: req.ID,
+			Error: &JSONRPCError{Code: -32602, Message: "Tool not found"},
+		}
+	}
+	if !hasPermission(MemoryServer, MemoryServer+"__"+name) {
+		return &JSONRPCMessage{
+			JSONRPC: "2.0", ID: req.ID,
+			Error: &JSONRPCError{Code: -32000, Message: "Permission denied"},
+		}
+	}
+	if caller.ReadOnlyTools && !tool.ReadOnly {
+		return &JSONRPCMessage{
+			JSONRPC: "2.0", ID: req.ID,
+			Error: &JSONRPCError{Code: -32000, Message: "Permission denied: key is restricted to read-only tools"},
+		}
+	}
+
+	started := time.Now()
+	argMap, _ := input.(map[string]interface{})
+	var text string
+	args, err := coerceArgs(tool.Parameters, argMap)
+	if err == nil {
+		text, err = tool.Run(g, caller.KeyID, args)
+	}
+	result := map[string]interface{}{
+		"content": []interface{}{map[string]interface{}{"type": "text", "text": text}},
+	}
+	if err != nil {
+		fmt.Printf("[KV] %s for key %d failed: %v\n", name, caller.KeyID, err)
+		result = map[string]interface{}{
+			"content": []interface{}{map[string]interface{}{"type": "text", "text": err.Error()}},
+			"isError": true,
+		}
+	}
+	resp := &JSONRPCMessage{JSONRPC: "2.0", ID: req.ID}
+	resp.Result, _ = json.Marshal(result)
+	fullName := MemoryServer + "__" + name
+	g.recordUsage(caller, MemoryServer, fullName, input, resp, nil, started)
+	g.processToolResult(resp, caller, fullName, "")
+	return resp
+}
+
+// liveKVEntries restricts a query to entries that have not expired.
+func liveKVEntries(tx *gorm.DB) *gorm.DB {
+	return tx.Where("expires_at IS NULL OR expires_at > ?", time.Now())
+}
+
+func validKVName(name string) error {
+	if name == "" || len(name) > maxKVNameLength {
+		return fmt.Errorf("key must be 1 to %d bytes", maxKVNameLength)
+	}
+	return nil
+}
+
+// kvSet stores value under name in the namespace of keyID, enforcing
+// KV_MAX_VALUE_SIZE and KV_MAX_ENTRIES. A ttl of 0 keeps the value until deleted.
+func (g *Gateway) kvSet(keyID uint, name string, value string, ttl time.Duration) error {
+	if err := validKVName(name); err != nil {
+		return err
+	}
+	if ttl < 0 {
+		return fmt.Errorf("ttl_seconds must not be negative")
+	}
+	if len(value) > g.settings.KVMaxValueSize {
+		return fmt.Errorf("value exceeds the quota of %d bytes", g.settings.KVMaxValueSize)
+	}
+	var expiresAt *time.Time
+	if ttl > 0 {
+		at := time.Now().Add(ttl)
+		expiresAt = &at
+	}
+
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		var entry model.KVEntry
+		err := tx.Where("key_id = ? AND name = ?", keyID, name).First(&entry).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		expired := entry.ExpiresAt != nil && !entry.ExpiresAt.After(time.Now())
+		if entry.ID == 0 || expired {
+			var count int64
+			if err := liveKVEntries(tx.Model(&model.KVEntry{}).Where("key_id = ?", keyID)).Count(&count).Error; err != nil {
+				return err
+			}
+			if count >= int64(g.settings.KVMaxEntries) {
+				return fmt.Errorf("quota of %d keys reached, delete some first", g.settings.KVMaxEntries)
+			}
+		}
+		entry.KeyID = keyID
+		entry.Name = name
+		entry.Value = value
+		entry.ExpiresAt = expiresAt
+		return tx.Save(&entry).Error
+	})
+}
+
+// kvGet returns the live entry stored under name in the namespace of keyID.
+func (g *Gateway) kvGet(keyID uint, name string) (*model.KVEntry, error) {
+	var entry model.KVEntry
+	err := liveKVEntries(g.db.Where("key_id = ? AND name = ?", keyID, name)).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errKVNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// kvList returns the live entries of keyID whose name starts with prefix, by name.
+func (g *Gateway) kvList(keyID uint, prefix string) ([]model.KVEntry, error) {
+	var entries []model.KVEntry
+	tx := liveKVEntries(g.db.Where("key_id = ?", keyID))
+	if prefix != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)
+		tx = tx.Where(`name LIKE ? ESCAPE '\'`, escaped+"%")
+	}
+	err := tx.Order("name").Find(&entries).Error
+	return entries, err
+}
+
+// kvDelete removes the entry stored under name in the namespace of keyID.
+func (g *Gateway) kvDelete(keyID uint, name string) error {
+	tx := liveKVEntries(g.db.Where("key_id = ? AND name = ?", keyID, name)).Delete(&model.KVEntry{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return errKVNotFound
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"encoding/json"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestMemoryTools(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(model.All...))
+	settings := &config.Config{KVEnabled: true, KVMaxEntries: 2, KVMaxValueSize: 16}
+	g := NewGateway(db, settings)
+	allowAll := func(string, string) bool { return true }
+
+	call := func(keyID uint, tool string, args map[string]interface{}) (string, bool) {
+		params, _ := json.Marshal(map[string]interface{}{"name": "memory__" + tool, "arguments": args})
+		resp, err := g.handleToolCall(&JSONRPCMessage{JSONRPC: "2.0", Method: "tools/call", Params: params}, &Caller{KeyID: keyID}, allowAll)
+		assert.NoError(t, err)
+		return resultText(resp.Result), resultIsError(resp.Result)
+	}
+
+	t.Run("Listing", func(t *testing.T) {
+		var names []string
+		tools, _ := g.aggregateTools(allowAll, nil)
+		for _, tool := range tools {
+			names = append(names, tool["name"].(string))
+		}
+		assert.Equal(t, []string{"memory__kv_delete", "memory__kv_get", "memory__kv_list", "memory__kv_set"}, names)
+
+		settings.KVEnabled = false
+		tools, _ = g.aggregateTools(allowAll, nil)
+		assert.Empty(t, tools)
+		params, _ := json.Marshal(map[string]interface{}{"name": "memory__kv_get", "arguments": map[string]interface{}{"key": "a"}})
+		resp, _ := g.handleToolCall(&JSONRPCMessage{JSONRPC: "2.0", Method: "tools/call", Params: params}, &Caller{KeyID: 1}, allowAll)
+		assert.Equal(t, "Server not found", resp.Error.Message, "not served when disabled")
+		settings.KVEnabled = true
+	})
+
+	t.Run("Namespaces", func(t *testing.T) {
+		text, isError := call(1, "kv_set", map[string]interface{}{"key": "plan", "value": "step 1"})
+		assert.False(t, isError, text)
+		text, _ = call(1, "kv_get", map[string]interface{}{"key": "plan"})
+		assert.Equal(t, "step 1", text)
+
+		text, isError = call(2, "kv_get", map[string]interface{}{"key": "plan"})
+		assert.True(t, isError, "other keys do not see the value")
+		assert.Equal(t, "key not found", text)
+
+		call(1, "kv_set", map[string]interface{}{"key": "plan", "value": "step 2"})
+		text, _ = call(1, "kv_get", map[string]interface{}{"key": "plan"})
+		assert.Equal(t, "step 2", text)
+	})
+
+	t.Run("Quotas", func(t *testing.T) {
+		text, isError := call(3, "kv_set", map[string]interface{}{"key": "big", "value": strings.Repeat("x", 17)})
+		assert.True(t, isError)
+		assert.Contains(t, text, "quota of 16 bytes")
+
+		call(3, "kv_set", map[string]interface{}{"key": "a", "value": "1"})
+		call(3, "kv_set", map[string]interface{}{"key": "b", "value": "2"})
+		text, isError = call(3, "kv_set", map[string]interface{}{"key": "c", "value": "3"})
+		assert.True(t, isError)
+		assert.Contains(t, text, "quota of 2 keys")
+		_, isError = call(3, "kv_set", map[string]interface{}{"key": "a", "value": "replaced"})
+		assert.False(t, isError, "replacing a value needs no new entry")
+
+		_, isError = call(3, "kv_delete", map[string]interface{}{"key": "b"})
+		assert.False(t, isError)
+		_, isError = call(3, "kv_set", map[string]interface{}{"key": "c", "value": "3"})
+		assert.False(t, isError)
+	})
+
+	t.Run("Expiry And Listing", func(t *testing.T) {
+		call(4, "kv_set", map[string]interface{}{"key": "task_1", "value": "x"})
+		call(4, "kv_set", map[string]interface{}{"key": "task_2", "value": "xy", "ttl_seconds": "60"})
+		expired := time.Now().Add(-time.Minute)
+		db.Create(&model.KVEntry{KeyID: 4, Name: "taskX", Value: "old", ExpiresAt: &expired})
+
+		text, _ := call(4, "kv_list", map[string]interface{}{"prefix": "task_"})
+		var listed struct {
+			Keys []struct {
+				Key       string     `json:"key"`
+				Size      int        `json:"size"`
+				ExpiresAt *time.Time `json:"expires_at"`
+			} `json:"keys"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(text), &listed))
+		assert.Len(t, listed.Keys, 2, "expired values and '_' as a wildcard are excluded")
+		assert.Equal(t, "task_2", listed.Keys[1].Key)
+		assert.Equal(t, 2, listed.Keys[1].Size)
+		assert.NotNil(t, listed.Keys[1].ExpiresAt)
+
+		_, isError := call(4, "kv_get", map[string]interface{}{"key": "taskX"})
+		assert.True(t, isError)
+		assert.Equal(t, int64(1), g.Prune()["kv_entries"])
+	})
+
+	t.Run("Read-Only Keys", func(t *testing.T) {
+		params, _ := json.Marshal(map[string]interface{}{"name": "memory__kv_set", "arguments": map[string]interface{}{"key": "a", "value": "1"}})
+		resp, _ := g.handleToolCall(&JSONRPCMessage{JSONRPC: "2.0", Method: "tools/call", Params: params}, &Caller{KeyID: 5, ReadOnlyTools: true}, allowAll)
+		assert.NotNil(t, resp.Error)
+		text, isError := call(5, "kv_list", map[string]interface{}{})
+		assert.False(t, isError)
+		assert.JSONEq(t, `{"keys":[]}`, text)
+	})
+
+	t.Run("Usage, Quota And Signing", func(t *testing.T) {
+		team := model.Team{Name: "agents", DailyCallQuota: 1}
+		db.Create(&team)
+		caller := &Caller{KeyID: 6, TeamID: team.ID, SigningSecret: "s3cret"}
+		params, _ := json.Marshal(map[string]interface{}{"name": "memory__kv_list", "arguments": map[string]interface{}{}})
+
+		resp, _ := g.handleToolCall(&JSONRPCMessage{JSONRPC: "2.0", Method: "tools/call", Params: params}, caller, allowAll)
+		assert.Nil(t, resp.Error)
+		assert.NoError(t, VerifyResultSignature(resp.Result, "s3cret"))
+		var usage model.UsageLog
+		assert.NoError(t, db.Where("key_id = ?", 6).First(&usage).Error)
+		assert.Equal(t, "memory__kv_list", usage.Tool)
+		assert.Equal(t, team.ID, usage.TeamID)
+
+		resp, _ = g.handleToolCall(&JSONRPCMessage{JSONRPC: "2.0", Method: "tools/call", Params: params}, caller, allowAll)
+		assert.Contains(t, resp.Error.Message, "daily quota")
+	})
+}
@@ -0,0 +1,162 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limits protects the gateway as a whole from floods of sessions, messages and
+// upstream calls, e.g. a misbehaving agent reconnecting in a loop. Zero values
+// disable the corresponding limit.
+type Limits struct {
+	MaxSessions       int     // Concurrent downstream sessions
+	MaxSessionsPerKey int     // Concurrent downstream sessions of one API key
+	MaxInflightCalls  int     // Upstream tool calls in flight across all sessions
+	MessageRate       float64 // Sustained incoming messages per second
+	MessageBurst      int     // Messages accepted at once above the sustained rate
+
+	mu          sync.Mutex
+	sessions    int
+	keySessions map[uint]int
+	inflight    int
+	tokens      float64
+	refilled    time.Time
+	rejected    map[string]int64
+}
+
+// ErrLimited is returned when a gateway limit is reached.
+type ErrLimited struct {
+	Limit      string
+	RetryAfter time.Duration
+}
+
+func (e *ErrLimited) Error() string {
+	return fmt.Sprintf("gateway limit reached: %s", e.Limit)
+}
+
+// throttled is the JSON-RPC error answering a request refused by a limit.
+func (e *ErrLimited) throttled(req *JSONRPCMessage) *JSONRPCMessage {
+	data, _ := json.Marshal(map[string]interface{}{"limit": e.Limit, "retryAfterMs": e.RetryAfter.Milliseconds()})
+	return &JSONRPCMessage{
+		JSONRPC: "2.0", ID: req.ID,
+		Error: &JSONRPCError{Code: -32000, Message: e.Error(), Data: data},
+	}
+}
+
+// NewLimits creates the limits; MessageBurst defaults to one second of MessageRate.
+func NewLimits(maxSessions, maxSessionsPerKey, maxInflightCalls int, messageRate float64, messageBurst int) *Limits {
+	if messageBurst <= 0 {
+		messageBurst = int(math.Ceil(messageRate))
+	}
+	return &Limits{
+		MaxSessions:       maxSessions,
+		MaxSessionsPerKey: maxSessionsPerKey,
+		MaxInflightCalls:  maxInflightCalls,
+		MessageRate:       messageRate,
+		MessageBurst:      messageBurst,
+		keySessions:       make(map[uint]int),
+		tokens:            float64(messageBurst),
+		refilled:          time.Now(),
+		rejected:          make(map[string]int64),
+	}
+}
+
+// AcquireSession admits a new downstream session of the key. The returned
+// function releases it.
+func (l *Limits) AcquireSession(keyID uint) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.MaxSessions > 0 && l.sessions >= l.MaxSessions {
+		return nil, l.reject("max_sessions", 5*time.Second)
+	}
+	if l.MaxSessionsPerKey > 0 && l.keySessions[keyID] >= l.MaxSessionsPerKey {
+		return nil, l.reject("max_sessions_per_key", 5*time.Second)
+	}
+	l.sessions++
+	l.keySessions[keyID]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.sessions--
+			if l.keySessions[keyID]--; l.keySessions[keyID] <= 0 {
+				delete(l.keySessions, keyID)
+			}
+		})
+	}, nil
+}
+
+// AcquireCall admits an upstream call. The returned function releases it.
+func (l *Limits) AcquireCall() (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.MaxInflightCalls > 0 && l.inflight >= l.MaxInflightCalls {
+		return nil, l.reject("max_inflight_calls", time.Second)
+	}
+	l.inflight++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.inflight--
+			l.mu.Unlock()
+		})
+	}, nil
+}
+
+// AllowMessage takes a token from the message bucket.
+func (l *Limits) AllowMessage() error {
+	if l.MessageRate <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = math.Min(float64(l.MessageBurst), l.tokens+now.Sub(l.refilled).Seconds()*l.MessageRate)
+	l.refilled = now
+	if l.tokens < 1 {
+		wait := time.Duration((1 - l.tokens) / l.MessageRate * float64(time.Second))
+		return l.reject("message_rate", wait)
+	}
+	l.tokens--
+	return nil
+}
+
+// reject counts a rejection; l.mu must be held.
+func (l *Limits) reject(limit string, retryAfter time.Duration) error {
+	l.rejected[limit]++
+	return &ErrLimited{Limit: limit, RetryAfter: retryAfter}
+}
+
+// LimitStats is the current usage of the gateway limits.
+type LimitStats struct {
+	Sessions         int              `json:"sessions"`
+	MaxSessions      int              `json:"max_sessions"`
+	InflightCalls    int              `json:"inflight_calls"`
+	MaxInflightCalls int              `json:"max_inflight_calls"`
+	Rejected         map[string]int64 `json:"rejected"`
+}
+
+// Stats returns the current usage and rejection counts since startup.
+func (l *Limits) Stats() LimitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rejected := make(map[string]int64, len(l.rejected))
+	for k, v := range l.rejected {
+		rejected[k] = v
+	}
+	return LimitStats{
+		Sessions:         l.sessions,
+		MaxSessions:      l.MaxSessions,
+		InflightCalls:    l.inflight,
+		MaxInflightCalls: l.MaxInflightCalls,
+		Rejected:         rejected,
+	}
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits(t *testing.T) {
+	t.Run("Sessions", func(t *testing.T) {
+		l := NewLimits(3, 2, 0, 0, 0)
+		r1, err := l.AcquireSession(1)
+		assert.NoError(t, err)
+		_, err = l.AcquireSession(1)
+		assert.NoError(t, err)
+
+		_, err = l.AcquireSession(1)
+		assert.Equal(t, "max_sessions_per_key", err.(*ErrLimited).Limit)
+		_, err = l.AcquireSession(2)
+		assert.NoError(t, err)
+		_, err = l.AcquireSession(3)
+		assert.Equal(t, "max_sessions", err.(*ErrLimited).Limit)
+
+		r1()
+		r1() // Releasing twice is harmless
+		_, err = l.AcquireSession(3)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, l.Stats().Sessions)
+		assert.Equal(t, map[string]int64{"max_sessions_per_key": 1, "max_sessions": 1}, l.Stats().Rejected)
+	})
+
+	t.Run("Inflight Calls", func(t *testing.T) {
+		l := NewLimits(0, 0, 1, 0, 0)
+		release, err := l.AcquireCall()
+		assert.NoError(t, err)
+		_, err = l.AcquireCall()
+		assert.Error(t, err)
+		release()
+		_, err = l.AcquireCall()
+		assert.NoError(t, err)
+	})
+
+	t.Run("Message Bucket", func(t *testing.T) {
+		l := NewLimits(0, 0, 0, 10, 2)
+		assert.NoError(t, l.AllowMessage())
+		assert.NoError(t, l.AllowMessage())
+		err := l.AllowMessage()
+		assert.Error(t, err)
+		assert.True(t, err.(*ErrLimited).RetryAfter > 0)
+
+		time.Sleep(120 * time.Millisecond)
+		assert.NoError(t, l.AllowMessage())
+	})
+
+	allowAll := func(string, string) bool { return true }
+	t.Run("Throttled Tool Call", func(t *testing.T) {
+		g := &Gateway{limits: NewLimits(0, 0, 1, 0, 0)}
+		release, _ := g.limits.AcquireCall()
+		defer release()
+
+		resp := g.callUpstreamTool(&JSONRPCMessage{}, &Caller{}, allowAll, &UpstreamClient{}, "build", "ci__build", nil, time.Second)
+		assert.Equal(t, -32000, resp.Error.Code)
+		assert.JSONEq(t, `{"limit":"max_inflight_calls","retryAfterMs":1000}`, string(resp.Error.Data))
+	})
+}
@@ -0,0 +1,153 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/model"
+	"one-mcp/internal/report"
+	"sync"
+)
+
+// logLevels are the MCP log levels (RFC 5424 severities), from least to most severe.
+var logLevels = []string{"debug", "info", "notice", "warning", "error", "critical", "alert", "emergency"}
+
+// logSeverity returns the rank of a level in logLevels, or -1 if it is unknown.
+func logSeverity(level string) int {
+	for i, l := range logLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// LogLevelsAtLeast returns the levels at least as severe as level, or nil if the
+// level is unknown.
+func LogLevelsAtLeast(level string) []string {
+	for i, l := range logLevels {
+		if l == level {
+			return logLevels[i:]
+		}
+	}
+	return nil
+}
+
+// relayLogMessage forwards an upstream's notifications/message to the live
+// sessions that may use the server, with the server name prefixed to the logger
+// like tool names, and persists it if enabled.
+func (g *Gateway) relayLogMessage(c *UpstreamClient, msg *JSONRPCMessage) {
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return
+	}
+	var level, logger string
+	json.Unmarshal(params["level"], &level)
+	json.Unmarshal(params["logger"], &logger)
+
+	prefixed := c.Config.Name
+	if logger != "" {
+		prefixed += "__" + logger
+	}
+	params["logger"], _ = json.Marshal(prefixed)
+	payload, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  msg.Method,
+		"params":  params,
+	})
+
+	// Sessions that set a level only get messages at least as severe
+	severity := logSeverity(level)
+	strict := g.Flags().StrictPermissions
+	g.sessionMu.Lock()
+	var targets []*Caller
+	for id, caller := range g.sessions {
+		if min, ok := g.logLevels[id]; ok && severity >= 0 && severity < logSeverity(min) {
+			continue
+		}
+		if caller.Notify != nil && caller.serverVisible(c, strict) {
+			targets = append(targets, caller)
+		}
+	}
+	g.sessionMu.Unlock()
+	for _, caller := range targets {
+		caller.Deliver(payload)
+	}
+
+	if g.settings != nil && g.settings.PersistUpstreamLogs {
+		entry := model.UpstreamLog{
+			ServerID: c.Config.ID,
+			Server:   c.Config.Name,
+			Level:    level,
+			Logger:   logger,
+			Data:     string(params["data"]),
+		}
+		if err := g.db.Create(&entry).Error; err != nil {
+			fmt.Printf("[Gateway] Failed to store log message from %s: %v\n", c.Config.Name, err)
+		}
+	}
+}
+
+// handleSetLevel records the session's log level and fans it out to the
+// upstreams the session may use.
+func (g *Gateway) handleSetLevel(req *JSONRPCMessage, caller *Caller) (*JSONRPCMessage, error) {
+	var params struct {
+		Level string `json:"level"`
+	}
+	json.Unmarshal(req.Params, &params)
+	if logSeverity(params.Level) < 0 {
+		return invalidParams(req, fmt.Sprintf("Unknown log level %q", params.Level)), nil
+	}
+
+	g.sessionMu.Lock()
+	if g.logLevels == nil {
+		g.logLevels = make(map[string]string)
+	}
+	g.logLevels[caller.SessionID] = params.Level
+	g.sessionMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, client := range g.visibleClients(caller) {
+		wg.Add(1)
+		go func(c *UpstreamClient) {
+			defer wg.Done()
+			defer report.Recover("logging/setLevel " + c.Config.Name)
+			g.applyLogLevel(c)
+		}(client)
+	}
+	wg.Wait()
+	return emptyResult(req), nil
+}
+
+// upstreamLogLevel returns the most verbose level requested by the live sessions
+// that may use the upstream, or "" if none set one. As the upstream is shared,
+// sessions asking for less get the excess filtered out by relayLogMessage.
+func (g *Gateway) upstreamLogLevel(c *UpstreamClient) string {
+	strict := g.Flags().StrictPermissions
+	g.sessionMu.Lock()
+	defer g.sessionMu.Unlock()
+	level := ""
+	for id, l := range g.logLevels {
+		caller, ok := g.sessions[id]
+		if !ok || !caller.serverVisible(c, strict) {
+			continue
+		}
+		if level == "" || logSeverity(l) < logSeverity(level) {
+			level = l
+		}
+	}
+	return level
+}
+
+// applyLogLevel sends the effective log level to the upstream.
+func (g *Gateway) applyLogLevel(c *UpstreamClient) {
+	level := g.upstreamLogLevel(c)
+	if level == "" || !c.IsReady() {
+		return
+	}
+	resp, err := c.Call("logging/setLevel", map[string]string{"level": level})
+	if err != nil {
+		fmt.Printf("[Gateway] Failed to set log level of %s: %v\n", c.Config.Name, err)
+	} else if resp.Error != nil {
+		fmt.Printf("[Gateway] %s did not accept log level %s: %s\n", c.Config.Name, level, resp.Error.Message)
+	}
+}
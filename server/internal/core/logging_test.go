@@ -0,0 +1,118 @@
+package core
+
+import (
+	"encoding/json"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestRelayLogMessage(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&model.UpstreamLog{}))
+
+	g := &Gateway{db: db, settings: &config.Config{PersistUpstreamLogs: true}}
+	upstream := &UpstreamClient{Config: model.UpstreamServer{ID: 3, Name: "fs"}}
+
+	toClient := make(chan []byte, 1)
+	g.AddSession(&Caller{SessionID: "s1", Notify: func(msg []byte) bool {
+		toClient <- msg
+		return true
+	}})
+	g.AddSession(&Caller{SessionID: "s2", AllowedServers: []string{"9"}, Notify: func(msg []byte) bool {
+		t.Error("log message sent to a session without access to the server")
+		return true
+	}})
+
+	g.handleUpstreamNotification(upstream, &JSONRPCMessage{
+		JSONRPC: "2.0", Method: "notifications/message",
+		Params: json.RawMessage(`{"level":"warning","logger":"indexer","data":{"skipped":2}}`),
+	})
+
+	select {
+	case payload := <-toClient:
+		assert.JSONEq(t, `{"jsonrpc":"2.0","method":"notifications/message","params":{"level":"warning","logger":"fs__indexer","data":{"skipped":2}}}`, string(payload))
+	case <-time.After(time.Second):
+		t.Fatal("log message not relayed")
+	}
+
+	var stored model.UpstreamLog
+	assert.NoError(t, db.First(&stored).Error)
+	assert.Equal(t, uint(3), stored.ServerID)
+	assert.Equal(t, "indexer", stored.Logger)
+	assert.JSONEq(t, `{"skipped":2}`, stored.Data)
+
+	assert.Equal(t, []string{"critical", "alert", "emergency"}, LogLevelsAtLeast("critical"))
+	assert.Nil(t, LogLevelsAtLeast("verbose"))
+}
+
+func TestSetLevel(t *testing.T) {
+	transport := &recordingTransport{sent: make(chan []byte, 4)}
+	upstream := &UpstreamClient{
+		Config:      model.UpstreamServer{ID: 1, Name: "fs"},
+		transport:   transport,
+		ready:       true,
+		pendingReqs: make(map[string]chan JSONRPCMessage),
+		settings:    &config.Config{UpstreamTimeout: time.Second},
+	}
+	g := &Gateway{settings: &config.Config{}, upstreams: map[uint]*UpstreamClient{1: upstream}}
+
+	// The upstream acknowledges every setLevel and reports the level it got
+	levels := make(chan string, 4)
+	go func() {
+		for payload := range transport.sent {
+			var req struct {
+				ID     json.RawMessage `json:"id"`
+				Params struct {
+					Level string `json:"level"`
+				} `json:"params"`
+			}
+			json.Unmarshal(payload, &req)
+			levels <- req.Params.Level
+			upstream.handleMessage([]byte(`{"jsonrpc":"2.0","id":` + string(req.ID) + `,"result":{}}`))
+		}
+	}()
+
+	received := map[string]chan []byte{"quiet": make(chan []byte, 4), "verbose": make(chan []byte, 4)}
+	for id, ch := range received {
+		ch := ch
+		g.AddSession(&Caller{SessionID: id, Notify: func(msg []byte) bool {
+			ch <- msg
+			return true
+		}})
+	}
+	setLevel := func(session, level string) *JSONRPCMessage {
+		resp, err := g.HandleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"logging/setLevel","params":{"level":"`+level+`"}}`), &Caller{SessionID: session})
+		assert.NoError(t, err)
+		return resp
+	}
+
+	// The upstream is set to the most verbose level any session asked for
+	assert.Nil(t, setLevel("quiet", "error").Error)
+	assert.Equal(t, "error", <-levels)
+	assert.Nil(t, setLevel("verbose", "debug").Error)
+	assert.Equal(t, "debug", <-levels)
+	assert.Equal(t, -32602, setLevel("quiet", "loud").Error.Code)
+
+	// Each session only gets messages at or above its own level
+	g.handleUpstreamNotification(upstream, &JSONRPCMessage{
+		JSONRPC: "2.0", Method: "notifications/message",
+		Params: json.RawMessage(`{"level":"info","data":"indexing"}`),
+	})
+	select {
+	case <-received["verbose"]:
+	case <-time.After(time.Second):
+		t.Fatal("info message not relayed to the verbose session")
+	}
+	select {
+	case msg := <-received["quiet"]:
+		t.Fatalf("info message relayed to the quiet session: %s", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"fmt"
+	"one-mcp/internal/model"
+	"time"
+)
+
+// ReloadMaintenance refreshes the cached maintenance windows from the database.
+func (g *Gateway) ReloadMaintenance() {
+	var windows []model.MaintenanceWindow
+	if err := g.db.Where("ends_at > ?", time.Now()).Find(&windows).Error; err != nil {
+		fmt.Printf("[Gateway] Failed to load maintenance windows: %v\n", err)
+		return
+	}
+
+	byServer := make(map[uint][]model.MaintenanceWindow)
+	for _, w := range windows {
+		byServer[w.ServerID] = append(byServer[w.ServerID], w)
+	}
+
+	g.maintMu.Lock()
+	g.maintenance = byServer
+	g.maintMu.Unlock()
+}
+
+// activeMaintenance returns the maintenance window the server is currently in, if any.
+func (g *Gateway) activeMaintenance(serverID uint) (*model.MaintenanceWindow, bool) {
+	g.maintMu.RLock()
+	defer g.maintMu.RUnlock()
+
+	now := time.Now()
+	for i, w := range g.maintenance[serverID] {
+		if !now.Before(w.StartsAt) && now.Before(w.EndsAt) {
+			return &g.maintenance[serverID][i], true
+		}
+	}
+	return nil, false
+}
+
+// maintenanceError returns an error describing the active maintenance of client, or nil.
+func (g *Gateway) maintenanceError(client *UpstreamClient) error {
+	w, ok := g.activeMaintenance(client.Config.ID)
+	if !ok {
+		return nil
+	}
+	msg := fmt.Sprintf("Server %s is under maintenance until %s", client.Config.Name, w.EndsAt.In(g.Location(w.Timezone)).Format(time.RFC3339))
+	if w.Reason != "" {
+		msg += ": " + w.Reason
+	}
+	return fmt.Errorf("%s", msg)
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMaintenanceWindows(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.MaintenanceWindow{}, &model.Workflow{}, &model.ToolRoute{}))
+
+	settings := &config.Config{UpstreamTimeout: time.Second, Timezone: "Asia/Tokyo"}
+	g := NewGateway(db, settings)
+	selector, _ := ParseToolSelector("", "", 0)
+	upstream := func(id uint, name string) *UpstreamClient {
+		transport := &listTransport{tools: []map[string]interface{}{{"name": "t"}}}
+		client := &UpstreamClient{
+			selector:    selector,
+			Config:      model.UpstreamServer{ID: id, Name: name},
+			settings:    settings,
+			transport:   transport,
+			pendingReqs: make(map[string]chan JSONRPCMessage),
+			ready:       true,
+		}
+		transport.client = client
+		g.upstreams[id] = client
+		return client
+	}
+	db1 := upstream(1, "db")
+	search := upstream(2, "search")
+
+	now := time.Now()
+	until := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	db.Create(&model.MaintenanceWindow{ServerID: 1, StartsAt: now.Add(-time.Minute), EndsAt: until, Reason: "schema migration"})
+	// Ended and upcoming windows don't apply
+	db.Create(&model.MaintenanceWindow{ServerID: 2, StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour)})
+	db.Create(&model.MaintenanceWindow{ServerID: 2, StartsAt: now.Add(time.Hour), EndsAt: now.Add(2 * time.Hour), Timezone: "UTC"})
+	g.ReloadMaintenance()
+
+	t.Run("Ended Windows Are Not Loaded", func(t *testing.T) {
+		assert.Len(t, g.maintenance[1], 1)
+		assert.Len(t, g.maintenance[2], 1)
+	})
+
+	t.Run("Error Names The End In The Window Zone", func(t *testing.T) {
+		err := g.maintenanceError(db1)
+		require.Error(t, err)
+		assert.Equal(t, "Server db is under maintenance until 2030-01-01T21:00:00+09:00: schema migration", err.Error())
+		assert.NoError(t, g.maintenanceError(search))
+	})
+
+	t.Run("Excluded From Aggregation", func(t *testing.T) {
+		tools, listed := g.StreamAllTools(func(ServerTools) {})
+		var names []string
+		for _, tool := range tools {
+			names = append(names, tool["name"].(string))
+		}
+		assert.Equal(t, []string{"search__t"}, names)
+		assert.Equal(t, map[string]bool{"search": true}, listed)
+	})
+
+	t.Run("Deleted Window Lifted On Reload", func(t *testing.T) {
+		db.Where("server_id = ?", 1).Delete(&model.MaintenanceWindow{})
+		g.ReloadMaintenance()
+		assert.NoError(t, g.maintenanceError(db1))
+	})
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/report"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MethodHandler serves a custom JSON-RPC method registered with RegisterMethod.
+// It returns the result, which is marshalled into the response, or an error:
+// a *MethodError is sent as is, any other error as an internal error. The
+// response to a notification (a message without ID) is dropped.
+type MethodHandler func(g *Gateway, caller *Caller, params json.RawMessage) (interface{}, error)
+
+// MethodError is a JSON-RPC error returned by a MethodHandler, such as -32602
+// for invalid params.
+type MethodError struct {
+	Code    int
+	Message string
+	Data    interface{}
+}
+
+func (e *MethodError) Error() string {
+	return e.Message
+}
+
+// mcpNamespaces are the method prefixes of the MCP specification, which custom
+// methods cannot use.
+var mcpNamespaces = []string{"notifications", "tools", "prompts", "resources", "logging", "completion", "sampling", "roots", "elicitation"}
+
+// methods are the custom methods registered by plugins before gateways are created.
+var methods = struct {
+	sync.RWMutex
+	handlers map[string]MethodHandler
+}{handlers: make(map[string]MethodHandler)}
+
+// RegisterMethod adds a custom JSON-RPC method to every gateway, for plugins to
+// call from init(), e.g. core.RegisterMethod("org/listDatasets", listDatasets).
+// It panics if the name is invalid or already registered.
+func RegisterMethod(name string, handler MethodHandler) {
+	if err := validMethodName(name); err != nil {
+		panic(err)
+	}
+	methods.Lock()
+	defer methods.Unlock()
+	if _, ok := methods.handlers[name]; ok {
+		panic(fmt.Sprintf("method %s is already registered", name))
+	}
+	methods.handlers[name] = handler
+	fmt.Printf("[Plugins] Registered method %s\n", name)
+}
+
+// Methods returns the names of the registered custom methods, sorted.
+func Methods() []string {
+	methods.RLock()
+	defer methods.RUnlock()
+	names := make([]string, 0, len(methods.handlers))
+	for name := range methods.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validMethodName requires custom methods to be namespaced, as in
+// "org/listDatasets", outside the namespaces of MCP, so they cannot shadow
+// methods the gateway serves or may serve in the future.
+func validMethodName(name string) error {
+	prefix, method, ok := strings.Cut(name, "/")
+	if !ok || prefix == "" || method == "" {
+		return fmt.Errorf("method name %q must be of the form namespace/method", name)
+	}
+	for _, reserved := range mcpNamespaces {
+		if prefix == reserved {
+			return fmt.Errorf("method namespace %q is reserved by MCP", prefix)
+		}
+	}
+	return nil
+}
+
+// handleCustomMethod serves req with a registered custom method, returning false
+// if none has its name.
+func (g *Gateway) handleCustomMethod(req *JSONRPCMessage, caller *Caller) (*JSONRPCMessage, bool) {
+	methods.RLock()
+	handler, ok := methods.handlers[req.Method]
+	methods.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	result, err := runMethod(handler, g, req, caller)
+	if req.ID == nil {
+		return nil, true
+	}
+	resp := &JSONRPCMessage{JSONRPC: "2.0", ID: req.ID}
+	if err == nil {
+		resp.Result, err = json.Marshal(result)
+	}
+	if err != nil {
+		methodErr, ok := err.(*MethodError)
+		if !ok {
+			fmt.Printf("[Plugins] %s for key %d failed: %v\n", req.Method, caller.KeyID, err)
+			methodErr = &MethodError{Code: -32603, Message: "Internal error: " + err.Error()}
+		}
+		resp.Result = nil
+		resp.Error = &JSONRPCError{Code: methodErr.Code, Message: methodErr.Message}
+		if methodErr.Data != nil {
+			resp.Error.Data, _ = json.Marshal(methodErr.Data)
+		}
+	}
+	return resp, true
+}
+
+// runMethod runs a custom method handler, turning a panic into an error so a
+// faulty plugin cannot take the gateway down.
+func runMethod(handler MethodHandler, g *Gateway, req *JSONRPCMessage, caller *Caller) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			report.Panic("method "+req.Method, r)
+			result, err = nil, fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(g, caller, req.Params)
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomMethods(t *testing.T) {
+	g := NewGateway(nil, &config.Config{})
+	notified := make(chan uint, 1)
+	RegisterMethod("org/listDatasets", func(g *Gateway, caller *Caller, params json.RawMessage) (interface{}, error) {
+		var args struct {
+			Prefix string `json:"prefix"`
+		}
+		if err := json.Unmarshal(params, &args); err != nil {
+			return nil, &MethodError{Code: -32602, Message: "Invalid params", Data: err.Error()}
+		}
+		return map[string]interface{}{"datasets": []string{args.Prefix + "sales"}, "key": caller.KeyID}, nil
+	})
+	RegisterMethod("org/fail", func(g *Gateway, caller *Caller, params json.RawMessage) (interface{}, error) {
+		return nil, fmt.Errorf("warehouse unavailable")
+	})
+	RegisterMethod("org/panic", func(g *Gateway, caller *Caller, params json.RawMessage) (interface{}, error) {
+		panic("bug")
+	})
+	RegisterMethod("org/notify", func(g *Gateway, caller *Caller, params json.RawMessage) (interface{}, error) {
+		notified <- caller.KeyID
+		return nil, nil
+	})
+	t.Cleanup(func() {
+		methods.Lock()
+		for _, name := range []string{"org/listDatasets", "org/fail", "org/panic", "org/notify"} {
+			delete(methods.handlers, name)
+		}
+		methods.Unlock()
+	})
+	call := func(msg string) *JSONRPCMessage {
+		resp, err := g.HandleMessage([]byte(msg), &Caller{KeyID: 7})
+		assert.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Dispatch", func(t *testing.T) {
+		resp := call(`{"jsonrpc":"2.0","id":1,"method":"org/listDatasets","params":{"prefix":"eu-"}}`)
+		assert.Nil(t, resp.Error)
+		assert.JSONEq(t, `{"datasets":["eu-sales"],"key":7}`, string(resp.Result))
+		assert.Equal(t, "1", string(*resp.ID))
+
+		resp = call(`{"jsonrpc":"2.0","id":2,"method":"org/unknown"}`)
+		assert.Equal(t, -32601, resp.Error.Code, "unregistered methods are still unsupported")
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		resp := call(`{"jsonrpc":"2.0","id":1,"method":"org/listDatasets","params":[1]}`)
+		assert.Equal(t, -32602, resp.Error.Code)
+		assert.NotEmpty(t, resp.Error.Data)
+
+		resp = call(`{"jsonrpc":"2.0","id":2,"method":"org/fail"}`)
+		assert.Equal(t, -32603, resp.Error.Code)
+		assert.Contains(t, resp.Error.Message, "warehouse unavailable")
+		assert.Nil(t, resp.Result)
+
+		resp = call(`{"jsonrpc":"2.0","id":3,"method":"org/panic"}`)
+		assert.Equal(t, -32603, resp.Error.Code, "a panicking plugin does not take the gateway down")
+	})
+
+	t.Run("Notifications", func(t *testing.T) {
+		assert.Nil(t, call(`{"jsonrpc":"2.0","method":"org/notify"}`))
+		assert.Equal(t, uint(7), <-notified)
+	})
+
+	t.Run("Registration", func(t *testing.T) {
+		assert.Panics(t, func() { RegisterMethod("org/fail", nil) }, "duplicate")
+		for _, name := range []string{"ping", "tools/list", "notifications/custom", "/x", "org/"} {
+			assert.Error(t, validMethodName(name), name)
+		}
+		assert.NoError(t, validMethodName("acme/search/v2"))
+		assert.Equal(t, []string{"org/fail", "org/listDatasets", "org/notify", "org/panic"}, Methods())
+
+		capabilities := g.serverCapabilities(&Caller{}, Protocol20250326)
+		assert.Equal(t, map[string]interface{}{
+			"one-mcp/methods": map[string]interface{}{"methods": Methods()},
+		}, capabilities["experimental"])
+	})
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"one-mcp/internal/report"
+	"sync/atomic"
+)
+
+// mirrorQueueSize bounds mirrored messages waiting to be sent; more are dropped so
+// a slow staging gateway never holds up live traffic.
+const mirrorQueueSize = 256
+
+// Mirror copies a sample of incoming MCP messages to a staging gateway, over its
+// own session, for testing configuration changes under real traffic. Responses
+// from the staging gateway are ignored.
+type Mirror struct {
+	client    *UpstreamClient
+	percent   int
+	anonymize bool
+	queue     chan []byte
+	seq       int64
+}
+
+// NewMirror returns a mirror for the configured staging gateway, or nil if
+// mirroring is disabled.
+func NewMirror(settings *config.Config) *Mirror {
+	if settings.MirrorURL == "" || settings.MirrorPercent == 0 {
+		return nil
+	}
+	target := model.UpstreamServer{
+		Name:          "mirror",
+		TransportType: "sse",
+		URL:           settings.MirrorURL,
+		AuthToken:     settings.MirrorKey,
+		Enabled:       true,
+	}
+	return &Mirror{
+		client:    NewUpstreamClient(target, settings, nil),
+		percent:   settings.MirrorPercent,
+		anonymize: settings.MirrorAnonymize,
+		queue:     make(chan []byte, mirrorQueueSize),
+	}
+}
+
+// Start connects to the staging gateway and begins sending mirrored messages.
+func (m *Mirror) Start() {
+	m.client.Start()
+	go func() {
+		defer report.Recover("traffic mirror")
+		for payload := range m.queue {
+			if !m.client.IsReady() {
+				continue
+			}
+			if err := m.client.SendRaw(payload); err != nil {
+				fmt.Printf("[Mirror] Failed to send: %v\n", err)
+			}
+		}
+	}()
+}
+
+// Offer mirrors the message if it falls in the sample. Session handshakes are
+// not mirrored: the mirror holds its own session on the staging gateway.
+func (m *Mirror) Offer(req *JSONRPCMessage) {
+	switch req.Method {
+	case "", "initialize", "notifications/initialized":
+		return
+	}
+	if rand.Intn(100) >= m.percent {
+		return
+	}
+
+	msg := JSONRPCMessage{JSONRPC: "2.0", Method: req.Method, Params: req.Params}
+	if req.ID != nil {
+		// Fresh IDs keep the staging responses from matching the mirror's own calls
+		id := json.RawMessage(fmt.Sprintf(`"mirror-%d"`, atomic.AddInt64(&m.seq, 1)))
+		msg.ID = &id
+	}
+	if m.anonymize && len(req.Params) > 0 {
+		msg.Params = anonymizeParams(req.Params)
+	}
+
+	payload, _ := json.Marshal(msg)
+	select {
+	case m.queue <- payload:
+	default:
+		// Queue full: drop rather than slow down live traffic
+	}
+}
+
+// anonymizeParams replaces the string values under "arguments" with stable
+// pseudonyms, keeping equal values equal so traffic patterns are preserved.
+func anonymizeParams(raw json.RawMessage) json.RawMessage {
+	var params map[string]interface{}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return raw
+	}
+	if args, ok := params["arguments"]; ok {
+		params["arguments"] = anonymizeValue(args)
+	}
+	out, _ := json.Marshal(params)
+	return out
+}
+
+func anonymizeValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		sum := sha256.Sum256([]byte(val))
+		return "anon-" + hex.EncodeToString(sum[:6])
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = anonymizeValue(item)
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = anonymizeValue(item)
+		}
+		return val
+	}
+	return v
+}
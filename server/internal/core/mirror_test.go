@@ -0,0 +1,38 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMirror(t *testing.T) {
+	t.Run("Anonymizes Arguments", func(t *testing.T) {
+		raw := json.RawMessage(`{"name":"crm__lookup","arguments":{"email":"a@b.c","tags":["a@b.c",1],"limit":5}}`)
+		var params map[string]interface{}
+		json.Unmarshal(anonymizeParams(raw), &params)
+
+		args := params["arguments"].(map[string]interface{})
+		assert.Equal(t, "crm__lookup", params["name"])
+		assert.NotEqual(t, "a@b.c", args["email"])
+		assert.Equal(t, args["email"], args["tags"].([]interface{})[0])
+		assert.Equal(t, float64(5), args["limit"])
+	})
+
+	t.Run("Samples And Skips Handshakes", func(t *testing.T) {
+		m := &Mirror{percent: 100, anonymize: true, queue: make(chan []byte, 4)}
+		id := json.RawMessage(`7`)
+		m.Offer(&JSONRPCMessage{JSONRPC: "2.0", Method: "initialize", ID: &id})
+		m.Offer(&JSONRPCMessage{JSONRPC: "2.0", Method: "tools/list", ID: &id})
+		assert.Len(t, m.queue, 1)
+
+		var msg JSONRPCMessage
+		json.Unmarshal(<-m.queue, &msg)
+		assert.Equal(t, `"mirror-1"`, string(*msg.ID))
+
+		m.percent = 0
+		m.Offer(&JSONRPCMessage{JSONRPC: "2.0", Method: "tools/list", ID: &id})
+		assert.Len(t, m.queue, 0)
+	})
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"one-mcp/internal/model"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Moderator checks tool results before they are returned to clients, using an
+// external HTTP endpoint, local keyword rules, or both.
+type Moderator struct {
+	Endpoint string   // Optional: POST {"tool","input"} -> {"flagged","reason"}
+	Keywords []string // Optional: case-insensitive substrings that flag a result
+	Action   string   // "block" withholds flagged results, "flag" only annotates them
+	Client   *http.Client
+}
+
+type ModerationVerdict struct {
+	Flagged bool   `json:"flagged"`
+	Reason  string `json:"reason"`
+}
+
+func NewModerator(endpoint string, keywords []string, action string) *Moderator {
+	if action != "flag" {
+		action = "block"
+	}
+	var kws []string
+	for _, kw := range keywords {
+		if kw = strings.TrimSpace(kw); kw != "" {
+			kws = append(kws, strings.ToLower(kw))
+		}
+	}
+	return &Moderator{
+		Endpoint: endpoint,
+		Keywords: kws,
+		Action:   action,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Check runs keyword rules first, then the HTTP endpoint if configured.
+func (m *Moderator) Check(tool string, text string) (ModerationVerdict, error) {
+	lower := strings.ToLower(text)
+	for _, kw := range m.Keywords {
+		if strings.Contains(lower, kw) {
+			return ModerationVerdict{Flagged: true, Reason: fmt.Sprintf("matched keyword %q", kw)}, nil
+		}
+	}
+
+	if m.Endpoint == "" {
+		return ModerationVerdict{}, nil
+	}
+
+	body, _ := json.Marshal(map[string]string{"tool": tool, "input": text})
+	resp, err := m.Client.Post(m.Endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return ModerationVerdict{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return ModerationVerdict{}, fmt.Errorf("moderation endpoint returned %d", resp.StatusCode)
+	}
+
+	var verdict ModerationVerdict
+	if err := json.NewDecoder(resp.Body).Decode(&verdict); err != nil {
+		return ModerationVerdict{}, fmt.Errorf("invalid moderation response: %v", err)
+	}
+	return verdict, nil
+}
+
+// resultText concatenates the text content blocks of a tools/call result.
+func resultText(result json.RawMessage) string {
+	var parsed struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(result, &parsed); err != nil {
+		return ""
+	}
+	var parts []string
+	for _, block := range parsed.Content {
+		if block.Type == "text" {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// moderateResult runs the moderator over a tool result, recording flagged results
+// for review and withholding them when the action is "block".
+func (g *Gateway) moderateResult(resp *JSONRPCMessage, caller *Caller, toolName string) {
+	text := resultText(resp.Result)
+	if text == "" {
+		return
+	}
+
+	verdict, err := g.moderator.Check(toolName, text)
+	if err != nil {
+		fmt.Printf("[Gateway] Moderation check failed for %s: %v\n", toolName, err)
+		if g.moderator.Action != "block" {
+			return
+		}
+		// Fail closed: a result that could not be checked is not returned.
+		verdict = ModerationVerdict{Flagged: true, Reason: "moderation unavailable: " + err.Error()}
+	}
+	if !verdict.Flagged {
+		return
+	}
+
+	excerpt := truncateUTF8(text, 500)
+	g.db.Create(&model.ModerationLog{
+		KeyID:   caller.KeyID,
+		Tool:    toolName,
+		Action:  g.moderator.Action,
+		Reason:  verdict.Reason,
+		Excerpt: excerpt,
+	})
+	fmt.Printf("[Gateway] Moderation %s result of %s for key %d: %s\n", g.moderator.Action, toolName, caller.KeyID, verdict.Reason)
+
+	if g.moderator.Action == "block" {
+		blocked, _ := json.Marshal(map[string]interface{}{
+			"content": []interface{}{
+				map[string]interface{}{
+					"type": "text",
+					"text": "Tool result withheld by content moderation: " + verdict.Reason,
+				},
+			},
+			"isError": true,
+		})
+		resp.Result = blocked
+		return
+	}
+
+	resp.Result = annotateResult(resp.Result, "one-mcp/moderation", map[string]interface{}{
+		"flagged": true,
+		"reason":  verdict.Reason,
+	})
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a multi-byte character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,21 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncateUTF8(t *testing.T) {
+	assert.Equal(t, "short", truncateUTF8("short", 500))
+	assert.Equal(t, "ab", truncateUTF8("abc", 2))
+	// "é" is two bytes and "€" three: cutting inside them drops the whole character
+	assert.Equal(t, "a", truncateUTF8("aé", 2))
+	assert.Equal(t, "é", truncateUTF8("é€", 4))
+	assert.Equal(t, "é€", truncateUTF8("é€", 5))
+	excerpt := truncateUTF8(strings.Repeat("€", 200), 500)
+	assert.True(t, utf8.ValidString(excerpt))
+	assert.Len(t, excerpt, 498)
+}
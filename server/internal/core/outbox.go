@@ -0,0 +1,72 @@
+package core
+
+import "sync"
+
+// outboxLimit bounds the messages queued for a session; Deliver waits beyond it.
+const outboxLimit = 256
+
+// outbox serializes the messages sent to a session, so they reach it in the order
+// they were produced even when produced by different goroutines.
+type outbox struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    [][]byte
+	draining bool
+	closed   bool // Notify failed: the session is gone
+}
+
+// Deliver queues a message for the session and returns without waiting for it to
+// be written, unless outboxLimit messages are already queued. Messages are
+// delivered in the order Deliver is called. It returns false once the session
+// is closed.
+func (caller *Caller) Deliver(payload []byte) bool {
+	return caller.deliver(payload, true)
+}
+
+// deliver is Deliver that drops the message instead of waiting if wait is false,
+// for advisory messages such as progress.
+func (caller *Caller) deliver(payload []byte, wait bool) bool {
+	o := &caller.out
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.cond == nil {
+		o.cond = sync.NewCond(&o.mu)
+	}
+	for len(o.queue) >= outboxLimit && !o.closed {
+		if !wait {
+			return false
+		}
+		o.cond.Wait()
+	}
+	if o.closed || caller.Notify == nil {
+		return false
+	}
+	o.queue = append(o.queue, payload)
+	if !o.draining {
+		o.draining = true
+		go caller.drain()
+	}
+	return true
+}
+
+// drain writes queued messages to the session one at a time until none are left.
+func (caller *Caller) drain() {
+	o := &caller.out
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for len(o.queue) > 0 {
+		payload := o.queue[0]
+		o.queue = o.queue[1:]
+		o.cond.Broadcast()
+
+		o.mu.Unlock()
+		ok := caller.Notify(payload)
+		o.mu.Lock()
+		if !ok {
+			o.closed = true
+			o.queue = nil
+			o.cond.Broadcast()
+		}
+	}
+	o.draining = false
+}
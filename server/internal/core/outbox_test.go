@@ -0,0 +1,55 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutbox(t *testing.T) {
+	t.Run("Keeps Order", func(t *testing.T) {
+		written := make(chan string, 100)
+		caller := &Caller{Notify: func(msg []byte) bool {
+			time.Sleep(time.Millisecond)
+			written <- string(msg)
+			return true
+		}}
+		for i := 0; i < 20; i++ {
+			assert.True(t, caller.Deliver([]byte(fmt.Sprint(i))))
+		}
+		for i := 0; i < 20; i++ {
+			assert.Equal(t, fmt.Sprint(i), <-written)
+		}
+	})
+
+	t.Run("Full Outbox", func(t *testing.T) {
+		release := make(chan struct{})
+		caller := &Caller{Notify: func(msg []byte) bool {
+			<-release
+			return true
+		}}
+		for i := 0; i <= outboxLimit; i++ {
+			caller.Deliver([]byte("x"))
+		}
+		// Advisory messages are dropped instead of waiting
+		assert.False(t, caller.deliver([]byte("progress"), false))
+
+		delivered := make(chan bool)
+		go func() { delivered <- caller.Deliver([]byte("response")) }()
+		select {
+		case <-delivered:
+			t.Fatal("Deliver did not wait for space")
+		case <-time.After(20 * time.Millisecond):
+		}
+		close(release)
+		assert.True(t, <-delivered)
+	})
+
+	t.Run("Closed Session", func(t *testing.T) {
+		caller := &Caller{Notify: func(msg []byte) bool { return false }}
+		caller.Deliver([]byte("lost"))
+		assert.Eventually(t, func() bool { return !caller.Deliver([]byte("more")) }, time.Second, time.Millisecond)
+	})
+}
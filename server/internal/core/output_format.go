@@ -0,0 +1,84 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Output formats for HTTP-wrapper and other gateway-produced tool results.
+const (
+	OutputText       = "text"       // JSON bodies are returned verbatim as text
+	OutputMarkdown   = "markdown"   // JSON bodies are pretty-printed in a ```json fence
+	OutputStructured = "structured" // JSON objects are also returned as structuredContent
+)
+
+// ValidOutputFormat reports whether f is empty (default) or a known output format.
+func ValidOutputFormat(f string) bool {
+	return f == "" || f == OutputText || f == OutputMarkdown || f == OutputStructured
+}
+
+// resultOutputFormat picks the format for a result of client: the key's preference
+// wins over the tool's configured format. Only gateway-produced results are formatted.
+func resultOutputFormat(client *UpstreamClient, caller *Caller) string {
+	httpTransport, ok := client.transport.(*HTTPTransport)
+	if !ok {
+		return ""
+	}
+	if caller.OutputFormat != "" {
+		return caller.OutputFormat
+	}
+	return httpTransport.ToolConfig.OutputFormat
+}
+
+// applyOutputFormat rewrites the JSON text blocks of a tools/call result in the given
+// format. Numbers and key order are kept as the upstream sent them.
+func applyOutputFormat(result json.RawMessage, format string) json.RawMessage {
+	if format == "" || format == OutputText {
+		return result
+	}
+
+	obj, err := decodeObject(result)
+	if err != nil {
+		return result
+	}
+	content, _ := obj["content"].([]interface{})
+
+	changed := false
+	for _, item := range content {
+		block, ok := item.(map[string]interface{})
+		if !ok || block["type"] != "text" {
+			continue
+		}
+		text, _ := block["text"].(string)
+
+		switch format {
+		case OutputMarkdown:
+			var pretty bytes.Buffer
+			if err := json.Indent(&pretty, []byte(strings.TrimSpace(text)), "", "  "); err != nil {
+				continue
+			}
+			block["text"] = fmt.Sprintf("```json\n%s\n```", pretty.Bytes())
+			changed = true
+		case OutputStructured:
+			// structuredContent must be an object; the text block stays for older clients
+			if !json.Valid([]byte(text)) || len(content) != 1 {
+				continue
+			}
+			if m, err := decodeObject(json.RawMessage(text)); err == nil {
+				obj["structuredContent"] = m
+				changed = true
+			}
+		}
+	}
+
+	if !changed {
+		return result
+	}
+	formatted, err := json.Marshal(obj)
+	if err != nil {
+		return result
+	}
+	return formatted
+}
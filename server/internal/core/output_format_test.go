@@ -0,0 +1,53 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyOutputFormat(t *testing.T) {
+	result := json.RawMessage(`{"content":[{"type":"text","text":"{\"id\":7,\"tags\":[\"a\"]}"}]}`)
+	parse := func(raw json.RawMessage) map[string]interface{} {
+		var obj map[string]interface{}
+		assert.NoError(t, json.Unmarshal(raw, &obj))
+		return obj
+	}
+	text := func(raw json.RawMessage) string {
+		return parse(raw)["content"].([]interface{})[0].(map[string]interface{})["text"].(string)
+	}
+
+	assert.Equal(t, result, applyOutputFormat(result, ""))
+	assert.Equal(t, result, applyOutputFormat(result, OutputText))
+
+	assert.Equal(t, "```json\n{\n  \"id\": 7,\n  \"tags\": [\n    \"a\"\n  ]\n}\n```", text(applyOutputFormat(result, OutputMarkdown)))
+
+	structured := parse(applyOutputFormat(result, OutputStructured))
+	assert.Equal(t, map[string]interface{}{"id": float64(7), "tags": []interface{}{"a"}}, structured["structuredContent"])
+	assert.Equal(t, `{"id":7,"tags":["a"]}`, text(applyOutputFormat(result, OutputStructured)), "the text block stays for older clients")
+
+	// Only JSON objects become structuredContent; other text is left alone
+	list := json.RawMessage(`{"content":[{"type":"text","text":"[1,2]"}]}`)
+	assert.Equal(t, list, applyOutputFormat(list, OutputStructured))
+	plain := json.RawMessage(`{"content":[{"type":"text","text":"not json"}]}`)
+	assert.Equal(t, plain, applyOutputFormat(plain, OutputMarkdown))
+
+	// Large integers keep their precision and objects their key order
+	big := json.RawMessage(`{"content":[{"type":"text","text":"{\"z\":1,\"id\":1234567890123456789}"}]}`)
+	assert.Equal(t, "```json\n{\n  \"z\": 1,\n  \"id\": 1234567890123456789\n}\n```", text(applyOutputFormat(big, OutputMarkdown)))
+	assert.Contains(t, string(applyOutputFormat(big, OutputStructured)), `"structuredContent":{"id":1234567890123456789,"z":1}`)
+}
+
+func TestResultOutputFormat(t *testing.T) {
+	assert.True(t, ValidOutputFormat(""))
+	assert.True(t, ValidOutputFormat(OutputStructured))
+	assert.False(t, ValidOutputFormat("yaml"))
+
+	wrapper := &UpstreamClient{transport: &HTTPTransport{ToolConfig: ToolConfig{OutputFormat: OutputMarkdown}}}
+	assert.Equal(t, OutputMarkdown, resultOutputFormat(wrapper, &Caller{}), "the tool's setting")
+	assert.Equal(t, OutputStructured, resultOutputFormat(wrapper, &Caller{OutputFormat: OutputStructured}), "the key's preference wins")
+
+	proxied := newResultClient("github", 1, `{"content":[]}`)
+	assert.Empty(t, resultOutputFormat(proxied, &Caller{OutputFormat: OutputMarkdown}), "results of MCP upstreams are not formatted")
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// paginateTools orders the tools of a tools/list result by name and cuts the
+// page after cursor. With a page size of 0 every tool is returned at once.
+// The cursor is the encoded name of the last tool of the previous page, so
+// pages stay consistent when tools appear or disappear between requests.
+// With health set, tools are ordered by the rank of their server first, and
+// the cursor carries the rank too ("rank/name"); a tool whose server changes
+// rank between pages may be skipped or listed twice.
+// It returns false if the cursor is invalid.
+func paginateTools(resp *JSONRPCMessage, cursor string, pageSize int, health map[string]UpstreamHealth) bool {
+	var after string
+	afterRank := 0
+	if cursor != "" {
+		name, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil || len(name) == 0 {
+			return false
+		}
+		after = string(name)
+		if rank, rest, ok := strings.Cut(after, "/"); ok && health != nil {
+			if r, err := strconv.Atoi(rank); err == nil {
+				afterRank, after = r, rest
+			}
+		}
+	}
+	if resp == nil || resp.Error != nil {
+		return true
+	}
+
+	var result struct {
+		Tools []map[string]interface{} `json:"tools"`
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return true
+	}
+	name := func(tool map[string]interface{}) string {
+		n, _ := tool["name"].(string)
+		return n
+	}
+	rank := func(tool map[string]interface{}) int {
+		if health == nil {
+			return 0
+		}
+		return toolRank(health, name(tool))
+	}
+	sort.SliceStable(result.Tools, func(i, j int) bool {
+		if ri, rj := rank(result.Tools[i]), rank(result.Tools[j]); ri != rj {
+			return ri < rj
+		}
+		return name(result.Tools[i]) < name(result.Tools[j])
+	})
+
+	start := 0
+	if after != "" {
+		start = sort.Search(len(result.Tools), func(i int) bool {
+			r := rank(result.Tools[i])
+			return r > afterRank || (r == afterRank && name(result.Tools[i]) > after)
+		})
+	}
+	page := map[string]interface{}{"tools": result.Tools[start:]}
+	if pageSize > 0 && len(result.Tools)-start > pageSize {
+		tools := result.Tools[start : start+pageSize]
+		last := tools[len(tools)-1]
+		position := name(last)
+		if health != nil {
+			position = fmt.Sprintf("%d/%s", rank(last), position)
+		}
+		page["tools"] = tools
+		page["nextCursor"] = base64.RawURLEncoding.EncodeToString([]byte(position))
+	}
+	resp.Result, _ = json.Marshal(page)
+	return true
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaginateTools(t *testing.T) {
+	list := func(names ...string) *JSONRPCMessage {
+		tools := make([]map[string]interface{}, 0, len(names))
+		for _, name := range names {
+			tools = append(tools, map[string]interface{}{"name": name})
+		}
+		result, _ := json.Marshal(map[string]interface{}{"tools": tools})
+		return &JSONRPCMessage{JSONRPC: "2.0", Result: result}
+	}
+	page := func(resp *JSONRPCMessage) (names []string, next string) {
+		var result struct {
+			Tools []struct {
+				Name string `json:"name"`
+			} `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		json.Unmarshal(resp.Result, &result)
+		for _, tool := range result.Tools {
+			names = append(names, tool.Name)
+		}
+		return names, result.NextCursor
+	}
+
+	t.Run("Pages In Name Order", func(t *testing.T) {
+		var all []string
+		cursor := ""
+		for pages := 0; pages < 10; pages++ {
+			resp := list("gh__search", "demo__time", "fs__read", "demo__echo", "fs__write")
+			assert.True(t, paginateTools(resp, cursor, 2, nil))
+			names, next := page(resp)
+			assert.LessOrEqual(t, len(names), 2)
+			all = append(all, names...)
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		assert.Equal(t, []string{"demo__echo", "demo__time", "fs__read", "fs__write", "gh__search"}, all)
+	})
+
+	t.Run("Stable When Tools Change", func(t *testing.T) {
+		resp := list("a__1", "a__2", "a__3", "a__4")
+		paginateTools(resp, "", 2, nil)
+		_, next := page(resp)
+
+		// a__2 disappeared and a__0 appeared before the cursor
+		resp = list("a__0", "a__1", "a__3", "a__4")
+		paginateTools(resp, next, 2, nil)
+		names, next := page(resp)
+		assert.Equal(t, []string{"a__3", "a__4"}, names)
+		assert.Empty(t, next)
+	})
+
+	t.Run("Unpaginated And Invalid", func(t *testing.T) {
+		resp := list("b__x", "a__x")
+		assert.True(t, paginateTools(resp, "", 0, nil))
+		names, next := page(resp)
+		assert.Equal(t, []string{"a__x", "b__x"}, names)
+		assert.Empty(t, next)
+
+		assert.False(t, paginateTools(list("a__x"), "not base64!", 2, nil))
+	})
+}
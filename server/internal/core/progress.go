@@ -0,0 +1,84 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+// progressTarget is the downstream session awaiting progress of a forwarded call,
+// with the token the client chose.
+type progressTarget struct {
+	caller *Caller
+	token  json.RawMessage
+}
+
+// trackProgress replaces the client's progressToken in the upstream params with
+// a gateway-unique one, so progress from the upstream can be routed back to the
+// session, under the client's own token. Other _meta fields are left intact.
+// The returned function ends the tracking.
+func (g *Gateway) trackProgress(req *JSONRPCMessage, caller *Caller, upstreamParams map[string]interface{}) func() {
+	var params struct {
+		Meta struct {
+			ProgressToken json.RawMessage `json:"progressToken"`
+		} `json:"_meta"`
+	}
+	json.Unmarshal(req.Params, &params)
+
+	// The client's token means nothing to the upstream
+	meta, _ := upstreamParams["_meta"].(map[string]interface{})
+	delete(meta, "progressToken")
+	if len(params.Meta.ProgressToken) == 0 || caller.Notify == nil {
+		if meta != nil && len(meta) == 0 {
+			delete(upstreamParams, "_meta")
+		}
+		return func() {}
+	}
+
+	token := fmt.Sprintf("one-mcp-progress-%d", atomic.AddInt64(&g.progressSeq, 1))
+	target := &progressTarget{caller: caller, token: params.Meta.ProgressToken}
+	g.progressMu.Lock()
+	if g.progress == nil {
+		g.progress = make(map[string]*progressTarget)
+	}
+	g.progress[token] = target
+	g.progressMu.Unlock()
+	if meta == nil {
+		meta = make(map[string]interface{})
+		upstreamParams["_meta"] = meta
+	}
+	meta["progressToken"] = token
+
+	return func() {
+		g.progressMu.Lock()
+		delete(g.progress, token)
+		g.progressMu.Unlock()
+	}
+}
+
+// forwardProgress relays an upstream's notifications/progress to the session
+// whose call it reports on, restoring the client's progressToken.
+func (g *Gateway) forwardProgress(c *UpstreamClient, msg *JSONRPCMessage) {
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return
+	}
+	var token string
+	json.Unmarshal(params["progressToken"], &token)
+
+	g.progressMu.Lock()
+	defer g.progressMu.Unlock()
+	target, ok := g.progress[token]
+	if !ok {
+		fmt.Printf("[Gateway] Ignoring progress from %s for unknown token %s\n", c.Config.Name, string(params["progressToken"]))
+		return
+	}
+	params["progressToken"] = target.token
+	payload, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  msg.Method,
+		"params":  params,
+	})
+	// Progress is advisory: drop it rather than block the upstream's reader
+	target.caller.deliver(payload, false)
+}
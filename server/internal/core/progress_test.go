@@ -0,0 +1,134 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestProgressForwarding(t *testing.T) {
+	g := &Gateway{}
+	upstream := &UpstreamClient{Config: model.UpstreamServer{Name: "slow"}}
+	toClient := make(chan []byte, 4)
+	caller := &Caller{SessionID: "s1", Notify: func(msg []byte) bool {
+		toClient <- msg
+		return true
+	}}
+
+	req := &JSONRPCMessage{Params: json.RawMessage(`{"name":"slow__build","_meta":{"progressToken":7}}`)}
+	upstreamParams := map[string]interface{}{"name": "build"}
+	untrack := g.trackProgress(req, caller, upstreamParams)
+
+	token := upstreamParams["_meta"].(map[string]interface{})["progressToken"].(string)
+	assert.NotEqual(t, "7", token)
+
+	progress := func(token string, value int) *JSONRPCMessage {
+		params, _ := json.Marshal(map[string]interface{}{"progressToken": token, "progress": value, "total": 10})
+		return &JSONRPCMessage{JSONRPC: "2.0", Method: "notifications/progress", Params: params}
+	}
+	g.handleUpstreamNotification(upstream, progress(token, 1))
+	g.handleUpstreamNotification(upstream, progress("other", 2))
+	g.handleUpstreamNotification(upstream, progress(token, 3))
+
+	for _, want := range []int{1, 3} {
+		select {
+		case payload := <-toClient:
+			assert.JSONEq(t, fmt.Sprintf(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progressToken":7,"progress":%d,"total":10}}`, want), string(payload))
+		case <-time.After(time.Second):
+			t.Fatal("progress not forwarded")
+		}
+	}
+
+	// Progress after the call completed is dropped
+	untrack()
+	g.handleUpstreamNotification(upstream, progress(token, 4))
+	select {
+	case payload := <-toClient:
+		t.Fatalf("unexpected progress %s", payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	t.Run("Keeps Other Meta", func(t *testing.T) {
+		params := map[string]interface{}{"_meta": map[string]interface{}{"progressToken": 7, "client/trace": "abc"}}
+		g.trackProgress(req, caller, params)()
+		meta := params["_meta"].(map[string]interface{})
+		assert.Equal(t, "abc", meta["client/trace"])
+		assert.NotEqual(t, 7, meta["progressToken"])
+	})
+
+	t.Run("Without A Token", func(t *testing.T) {
+		params := map[string]interface{}{}
+		g.trackProgress(&JSONRPCMessage{Params: json.RawMessage(`{"name":"slow__build"}`)}, caller, params)()
+		assert.NotContains(t, params, "_meta")
+	})
+}
+
+// metaTransport records the params of the last request and answers with a
+// result carrying _meta.
+type metaTransport struct {
+	client *UpstreamClient
+	params chan json.RawMessage
+}
+
+func (t *metaTransport) Start(ctx context.Context, onMessage func([]byte), onReady func()) error {
+	return nil
+}
+
+func (t *metaTransport) Send(payload []byte) error {
+	var req JSONRPCMessage
+	json.Unmarshal(payload, &req)
+	if req.ID != nil {
+		t.params <- req.Params
+		result := json.RawMessage(`{"content":[],"_meta":{"upstream/trace":"t-1"}}`)
+		resp, _ := json.Marshal(JSONRPCMessage{JSONRPC: "2.0", ID: req.ID, Result: result})
+		go t.client.handleMessage(resp)
+	}
+	return nil
+}
+
+func (t *metaTransport) Close() error { return nil }
+
+func TestMetaForwarding(t *testing.T) {
+	transport := &metaTransport{params: make(chan json.RawMessage, 1)}
+	client := &UpstreamClient{
+		Config:      model.UpstreamServer{Name: "up"},
+		settings:    &config.Config{UpstreamTimeout: time.Second},
+		transport:   transport,
+		pendingReqs: make(map[string]chan JSONRPCMessage),
+		ready:       true,
+	}
+	transport.client = client
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.UsageLog{})
+	g := &Gateway{db: db, settings: &config.Config{}}
+	allowAll := func(string, string) bool { return true }
+	caller := &Caller{SessionID: "s1", Notify: func([]byte) bool { return true }}
+
+	t.Run("Tool Call", func(t *testing.T) {
+		req := &JSONRPCMessage{Params: json.RawMessage(`{"name":"up__build","arguments":{},"_meta":{"progressToken":"p","client/trace":"c-1"}}`)}
+		resp := g.callUpstreamTool(req, caller, allowAll, client, "build", "up__build", map[string]interface{}{}, time.Second)
+
+		var params struct {
+			Meta map[string]interface{} `json:"_meta"`
+		}
+		json.Unmarshal(<-transport.params, &params)
+		assert.Equal(t, "c-1", params.Meta["client/trace"])
+		assert.Contains(t, params.Meta["progressToken"], "one-mcp-progress-")
+		assert.JSONEq(t, `{"content":[],"_meta":{"upstream/trace":"t-1"}}`, string(resp.Result))
+	})
+
+	t.Run("Untracked Token Is Dropped", func(t *testing.T) {
+		req := &JSONRPCMessage{Params: json.RawMessage(`{"name":"up__build","_meta":{"progressToken":"p"}}`)}
+		g.callUpstreamTool(req, &Caller{}, allowAll, client, "build", "up__build", nil, time.Second)
+		assert.NotContains(t, string(<-transport.params), "_meta")
+	})
+}
@@ -0,0 +1,220 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/report"
+	"strings"
+	"sync"
+)
+
+// listAll fetches every page of a list method (prompts/list, resources/list, ...)
+// from the upstream and returns the items of the given result field. Upstreams that
+// do not support the method yield no items.
+func (c *UpstreamClient) listAll(method string, field string) []map[string]interface{} {
+	var items []map[string]interface{}
+	var cursor string
+	for {
+		var params interface{}
+		if cursor != "" {
+			params = map[string]string{"cursor": cursor}
+		}
+		resp, err := c.Call(method, params)
+		if err != nil || resp.Error != nil {
+			return items
+		}
+
+		var result map[string]json.RawMessage
+		if err := json.Unmarshal(resp.Result, &result); err != nil {
+			return items
+		}
+		var page []map[string]interface{}
+		json.Unmarshal(result[field], &page)
+		items = append(items, page...)
+
+		var next string
+		json.Unmarshal(result["nextCursor"], &next)
+		if next == "" || next == cursor {
+			return items
+		}
+		cursor = next
+	}
+}
+
+// visibleClients returns the upstreams the caller may use for non-tool features,
+// leaving out servers in maintenance. A server is visible if the key is unrestricted,
+// lists the server ID, or is allowed at least one of its tools.
+func (g *Gateway) visibleClients(caller *Caller) []*UpstreamClient {
+	strict := g.Flags().StrictPermissions
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var clients []*UpstreamClient
+	for _, c := range g.upstreams {
+		if _, inMaintenance := g.activeMaintenance(c.Config.ID); inMaintenance {
+			continue
+		}
+		if caller.serverVisible(c, strict) {
+			clients = append(clients, c)
+		}
+	}
+	return clients
+}
+
+// serverVisible reports whether the caller may use the upstream. With strict
+// permissions, keys without server or tool permissions may use none.
+func (caller *Caller) serverVisible(c *UpstreamClient, strict bool) bool {
+	if strict && caller.unrestricted() {
+		return false
+	}
+	if len(caller.AllowedTools) > 0 {
+		for _, t := range caller.AllowedTools {
+			if t == "*" || strings.HasPrefix(t, c.Config.Name+"__") {
+				return true
+			}
+		}
+		return false
+	}
+	if len(caller.AllowedServers) > 0 {
+		srvID := fmt.Sprintf("%d", c.Config.ID)
+		for _, id := range caller.AllowedServers {
+			if id == srvID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// unrestricted reports whether the key has neither server nor tool permissions,
+// which allows everything unless strict permissions are enabled.
+func (caller *Caller) unrestricted() bool {
+	return len(caller.AllowedServers) == 0 && len(caller.AllowedTools) == 0
+}
+
+// PromptAllowed reports whether the caller may use the prefixed prompt name.
+func (caller *Caller) PromptAllowed(name string) bool {
+	return matchAnyPattern(caller.AllowedPrompts, name)
+}
+
+// ResourceAllowed reports whether the caller may use a resource of the server.
+func (caller *Caller) ResourceAllowed(server string, uri string) bool {
+	return matchAnyPattern(caller.AllowedResources, server+"__"+uri)
+}
+
+// matchAnyPattern matches s against patterns in which "*" matches any sequence,
+// including "/". An empty pattern list allows everything.
+func matchAnyPattern(patterns []string, s string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if wildcardMatch(p, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func wildcardMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
+
+// handlePromptsList aggregates prompts from all visible upstreams, prefixing their
+// names with the server name like tools.
+func (g *Gateway) handlePromptsList(req *JSONRPCMessage, caller *Caller) (*JSONRPCMessage, error) {
+	var all []map[string]interface{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, client := range g.visibleClients(caller) {
+		wg.Add(1)
+		go func(c *UpstreamClient) {
+			defer wg.Done()
+			defer report.Recover("prompts/list " + c.Config.Name)
+
+			var allowed []map[string]interface{}
+			for _, prompt := range c.listAll("prompts/list", "prompts") {
+				name, ok := prompt["name"].(string)
+				if !ok {
+					continue
+				}
+				prompt["name"] = c.Config.Name + "__" + name
+				if caller.PromptAllowed(prompt["name"].(string)) {
+					allowed = append(allowed, prompt)
+				}
+			}
+			mu.Lock()
+			all = append(all, allowed...)
+			mu.Unlock()
+		}(client)
+	}
+	wg.Wait()
+
+	if all == nil {
+		all = []map[string]interface{}{}
+	}
+	resBytes, _ := json.Marshal(map[string]interface{}{"prompts": all})
+	return &JSONRPCMessage{JSONRPC: "2.0", ID: req.ID, Result: resBytes}, nil
+}
+
+// handlePromptsGet routes prompts/get to the upstream owning the prefixed prompt name.
+func (g *Gateway) handlePromptsGet(req *JSONRPCMessage, caller *Caller) (*JSONRPCMessage, error) {
+	var params map[string]interface{}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return nil, err
+	}
+	name, _ := params["name"].(string)
+
+	notFound := &JSONRPCMessage{
+		JSONRPC: "2.0", ID: req.ID,
+		Error: &JSONRPCError{Code: -32602, Message: "Prompt not found"},
+	}
+	client, promptName := g.promptClient(caller, name)
+	if client == nil {
+		return notFound, nil
+	}
+
+	params["name"] = promptName
+	defer g.trackProgress(req, caller, params)()
+	resp, err := client.CallAs(caller.Key(), "prompts/get", params)
+	if err != nil {
+		return &JSONRPCMessage{
+			JSONRPC: "2.0", ID: req.ID,
+			Error: &JSONRPCError{Code: -32000, Message: err.Error()},
+		}, nil
+	}
+	resp.ID = req.ID
+	return resp, nil
+}
+
+// promptClient resolves a prefixed prompt name to its visible upstream and the
+// upstream's own prompt name. The client is nil if the caller cannot use it.
+func (g *Gateway) promptClient(caller *Caller, name string) (*UpstreamClient, string) {
+	parts := strings.SplitN(name, "__", 2)
+	if len(parts) != 2 || !caller.PromptAllowed(name) {
+		return nil, ""
+	}
+	for _, c := range g.visibleClients(caller) {
+		if c.Config.Name == parts[0] {
+			return c, parts[1]
+		}
+	}
+	return nil, ""
+}
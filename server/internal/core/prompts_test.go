@@ -0,0 +1,143 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// methodTransport answers each method with the result of its handler, and
+// unknown methods with "Method not found".
+type methodTransport struct {
+	client  *UpstreamClient
+	methods map[string]func(params json.RawMessage) interface{}
+}
+
+func (t *methodTransport) Start(ctx context.Context, onMessage func([]byte), onReady func()) error {
+	return nil
+}
+
+func (t *methodTransport) Send(payload []byte) error {
+	var req JSONRPCMessage
+	json.Unmarshal(payload, &req)
+	if req.ID == nil {
+		return nil
+	}
+	resp := JSONRPCMessage{JSONRPC: "2.0", ID: req.ID}
+	if handler, ok := t.methods[req.Method]; ok {
+		resp.Result, _ = json.Marshal(handler(req.Params))
+	} else {
+		resp.Error = &JSONRPCError{Code: -32601, Message: "Method not found"}
+	}
+	msg, _ := json.Marshal(resp)
+	go t.client.handleMessage(msg)
+	return nil
+}
+
+func (t *methodTransport) Close() error { return nil }
+
+// newMethodGateway returns a gateway whose upstreams, by name, answer with the given methods.
+func newMethodGateway(upstreams map[string]map[string]func(params json.RawMessage) interface{}) *Gateway {
+	g := NewGateway(nil, &config.Config{UpstreamTimeout: time.Second})
+	names := make([]string, 0, len(upstreams))
+	for name := range upstreams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		id := uint(i + 1)
+		transport := &methodTransport{methods: upstreams[name]}
+		client := &UpstreamClient{
+			Config:      model.UpstreamServer{ID: id, Name: name},
+			settings:    g.settings,
+			transport:   transport,
+			pendingReqs: make(map[string]chan JSONRPCMessage),
+			ready:       true,
+		}
+		transport.client = client
+		g.upstreams[id] = client
+		g.upstreamIDs[name] = id
+	}
+	return g
+}
+
+func TestPrompts(t *testing.T) {
+	g := newMethodGateway(map[string]map[string]func(json.RawMessage) interface{}{
+		"github": {
+			// Two pages
+			"prompts/list": func(params json.RawMessage) interface{} {
+				var p struct {
+					Cursor string `json:"cursor"`
+				}
+				json.Unmarshal(params, &p)
+				if p.Cursor == "" {
+					return map[string]interface{}{"prompts": []interface{}{map[string]interface{}{"name": "review"}}, "nextCursor": "2"}
+				}
+				return map[string]interface{}{"prompts": []interface{}{map[string]interface{}{"name": "triage"}}}
+			},
+			"prompts/get": func(params json.RawMessage) interface{} {
+				var p struct {
+					Name string `json:"name"`
+				}
+				json.Unmarshal(params, &p)
+				return map[string]interface{}{"description": "prompt " + p.Name, "messages": []interface{}{}}
+			},
+		},
+		// Does not support prompts
+		"slack": {},
+	})
+	list := func(caller *Caller) []string {
+		resp, err := g.handlePromptsList(&JSONRPCMessage{}, caller)
+		require.NoError(t, err)
+		var result struct {
+			Prompts []struct {
+				Name string `json:"name"`
+			} `json:"prompts"`
+		}
+		json.Unmarshal(resp.Result, &result)
+		names := []string{}
+		for _, p := range result.Prompts {
+			names = append(names, p.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	get := func(caller *Caller, name string) *JSONRPCMessage {
+		resp, err := g.handlePromptsGet(&JSONRPCMessage{Params: json.RawMessage(`{"name":"` + name + `"}`)}, caller)
+		require.NoError(t, err)
+		return resp
+	}
+
+	assert.Equal(t, []string{"github__review", "github__triage"}, list(&Caller{}))
+	assert.Equal(t, []string{}, list(&Caller{AllowedServers: []string{"2"}}), "prompts of other servers are hidden")
+	assert.Equal(t, []string{"github__triage"}, list(&Caller{AllowedPrompts: []string{"github__tri*"}}))
+
+	resp := get(&Caller{}, "github__review")
+	assert.Nil(t, resp.Error)
+	assert.Contains(t, string(resp.Result), `"prompt review"`, "the name is sent without prefix")
+	assert.NotNil(t, get(&Caller{AllowedPrompts: []string{"github__triage"}}, "github__review").Error)
+	assert.NotNil(t, get(&Caller{AllowedServers: []string{"2"}}, "github__review").Error)
+	assert.NotNil(t, get(&Caller{}, "review").Error)
+	assert.NotNil(t, get(&Caller{}, "gitlab__review").Error)
+}
+
+func TestPromptAndResourcePatterns(t *testing.T) {
+	caller := &Caller{
+		AllowedPrompts:   []string{"github__*"},
+		AllowedResources: []string{"fs__file:///docs/*"},
+	}
+	assert.True(t, caller.PromptAllowed("github__summarize_pr"))
+	assert.False(t, caller.PromptAllowed("slack__summarize"))
+	assert.True(t, caller.ResourceAllowed("fs", "file:///docs/guide/intro.md"))
+	assert.False(t, caller.ResourceAllowed("fs", "file:///etc/passwd"))
+
+	// No patterns: per-server access applies
+	assert.True(t, (&Caller{}).PromptAllowed("slack__summarize"))
+}
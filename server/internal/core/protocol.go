@@ -0,0 +1,45 @@
+package core
+
+// MCP protocol revisions
+const (
+	Protocol20241105 = "2024-11-05"
+	Protocol20250326 = "2025-03-26"
+	Protocol20250618 = "2025-06-18"
+
+	// LatestProtocolVersion is offered to upstreams and answered to clients
+	// requesting a version the gateway does not speak.
+	LatestProtocolVersion = Protocol20250618
+)
+
+// supportedProtocolVersions lists the protocol revisions the gateway speaks, newest first.
+var supportedProtocolVersions = []string{Protocol20250618, Protocol20250326, Protocol20241105}
+
+// SupportedProtocolVersion reports whether the gateway speaks a protocol revision.
+func SupportedProtocolVersion(version string) bool {
+	for _, v := range supportedProtocolVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateProtocolVersion picks the version to answer an initialize request
+// with: the requested one if supported, otherwise the latest. A client that
+// does not support the answer is expected to disconnect.
+func negotiateProtocolVersion(requested string) string {
+	if SupportedProtocolVersion(requested) {
+		return requested
+	}
+	return LatestProtocolVersion
+}
+
+// protocolAtLeast reports whether a negotiated version includes the features of
+// revision min. Revisions are dates, so they compare as strings; an empty
+// version means nothing was negotiated and is treated as the oldest revision.
+func protocolAtLeast(version string, min string) bool {
+	if version == "" {
+		version = Protocol20241105
+	}
+	return version >= min
+}
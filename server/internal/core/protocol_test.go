@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProtocolNegotiation(t *testing.T) {
+	initialize := func(version string) (*Caller, map[string]interface{}) {
+		// The upstream declares completions, which older clients do not know
+		upstream := &UpstreamClient{protocolVersion: Protocol20250618,
+			capabilities: map[string]json.RawMessage{"completions": json.RawMessage("{}")}}
+		g := &Gateway{upstreams: map[uint]*UpstreamClient{1: upstream}}
+		caller := &Caller{}
+		resp, err := g.HandleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+version+`","capabilities":{}}}`), caller)
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.Unmarshal(resp.Result, &result)
+		return caller, result
+	}
+
+	t.Run("Echoes A Supported Version", func(t *testing.T) {
+		for _, version := range []string{Protocol20241105, Protocol20250326, Protocol20250618} {
+			caller, result := initialize(version)
+			assert.Equal(t, version, result["protocolVersion"])
+			assert.Equal(t, version, caller.ProtocolVersion)
+		}
+	})
+
+	t.Run("Answers The Latest Otherwise", func(t *testing.T) {
+		caller, result := initialize("1999-01-01")
+		assert.Equal(t, LatestProtocolVersion, result["protocolVersion"])
+		assert.Equal(t, LatestProtocolVersion, caller.ProtocolVersion)
+	})
+
+	t.Run("Gates Capabilities By Version", func(t *testing.T) {
+		_, result := initialize(Protocol20241105)
+		assert.NotContains(t, result["capabilities"], "completions")
+		_, result = initialize(Protocol20250326)
+		assert.Contains(t, result["capabilities"], "completions")
+	})
+
+	t.Run("Concurrent With Other Messages", func(t *testing.T) {
+		g := &Gateway{upstreams: map[uint]*UpstreamClient{}}
+		caller := &Caller{}
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				g.HandleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+Protocol20250618+`","capabilities":{"roots":{}}}}`), caller)
+			}()
+			go func() {
+				defer wg.Done()
+				caller.Supports("roots")
+				caller.Protocol()
+			}()
+		}
+		wg.Wait()
+		assert.True(t, caller.Supports("roots"))
+		assert.Equal(t, Protocol20250618, caller.Protocol())
+	})
+}
+
+// initTransport answers initialize with a fixed protocol version.
+type initTransport struct {
+	version string
+	client  *UpstreamClient
+}
+
+func (t *initTransport) Start(ctx context.Context, onMessage func([]byte), onReady func()) error {
+	return nil
+}
+
+func (t *initTransport) Send(payload []byte) error {
+	var req JSONRPCMessage
+	json.Unmarshal(payload, &req)
+	if req.Method == "initialize" {
+		result, _ := json.Marshal(map[string]interface{}{"protocolVersion": t.version, "capabilities": map[string]interface{}{"logging": map[string]interface{}{}}})
+		resp, _ := json.Marshal(JSONRPCMessage{JSONRPC: "2.0", ID: req.ID, Result: result})
+		go t.client.handleMessage(resp)
+	}
+	return nil
+}
+
+func (t *initTransport) Close() error { return nil }
+
+func TestUpstreamProtocolVersion(t *testing.T) {
+	connect := func(version string) (*UpstreamClient, error) {
+		transport := &initTransport{version: version}
+		client := &UpstreamClient{
+			Config:      model.UpstreamServer{Name: "up"},
+			settings:    &config.Config{UpstreamTimeout: time.Second},
+			transport:   transport,
+			pendingReqs: make(map[string]chan JSONRPCMessage),
+		}
+		transport.client = client
+		return client, client.initialize()
+	}
+
+	client, err := connect(Protocol20250326)
+	assert.NoError(t, err)
+	assert.Equal(t, Protocol20250326, client.ProtocolVersion())
+	assert.Equal(t, Protocol20250326, client.Status().ProtocolVersion)
+	assert.Contains(t, client.Capabilities(), "logging")
+
+	_, err = connect("2023-01-01")
+	assert.ErrorContains(t, err, "unsupported protocol version")
+}
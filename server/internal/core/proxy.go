@@ -0,0 +1,44 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"one-mcp/internal/model"
+)
+
+// ParseProxyURL parses the proxy of an upstream, an http, https, socks5 or
+// socks5h URL, optionally with credentials. Empty means the process-wide
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+func ParseProxyURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL")
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return u, nil
+	}
+	return nil, fmt.Errorf("proxy URL must be http, https, socks5 or socks5h, got %q", u.Scheme)
+}
+
+// upstreamProxy returns the proxy of an upstream, ignoring it if it is invalid
+// (it is validated when saved).
+func upstreamProxy(cfg model.UpstreamServer) *url.URL {
+	proxyURL, err := ParseProxyURL(cfg.ProxyURL)
+	if err != nil {
+		fmt.Printf("[Upstream %s] Ignoring proxy: %v\n", cfg.Name, err)
+	}
+	return proxyURL
+}
+
+// setProxy makes transport use proxyURL, if not nil, instead of the process-wide
+// proxy, within the policy.
+func (p *EgressPolicy) setProxy(transport *http.Transport, proxyURL *url.URL) {
+	if proxyURL != nil {
+		transport.Proxy = p.proxy(http.ProxyURL(proxyURL))
+	}
+}
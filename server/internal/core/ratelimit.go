@@ -0,0 +1,126 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitMeta is the result _meta key carrying the rate-limit state of the
+// API behind an HTTP tool.
+const RateLimitMeta = "one-mcp/rateLimit"
+
+// RateLimit is the quota state reported by an upstream API's response headers.
+type RateLimit struct {
+	Limit      *int       `json:"limit,omitempty"`
+	Remaining  *int       `json:"remaining,omitempty"`
+	Reset      *time.Time `json:"reset,omitempty"`
+	RetryAfter int        `json:"retry_after_seconds,omitempty"`
+	Low        bool       `json:"low"` // Quota exhausted or below 10% of the limit
+	ObservedAt time.Time  `json:"observed_at"`
+}
+
+// epochThreshold separates reset values given as Unix timestamps from values
+// given as seconds until the reset.
+const epochThreshold = 1_000_000_000
+
+// ParseRateLimit reads the X-RateLimit-*, RateLimit-* and Retry-After headers.
+// It returns nil if none are present.
+func ParseRateLimit(h http.Header, status int, now time.Time) *RateLimit {
+	rl := &RateLimit{ObservedAt: now}
+	found := false
+
+	headerInt := func(names ...string) *int {
+		for _, name := range names {
+			v := strings.TrimSpace(h.Get(name))
+			// RateLimit-* values may carry parameters, e.g. "100;w=60"
+			if i := strings.IndexAny(v, ";,"); i >= 0 {
+				v = v[:i]
+			}
+			if n, err := strconv.Atoi(v); err == nil {
+				found = true
+				return &n
+			}
+		}
+		return nil
+	}
+
+	rl.Limit = headerInt("X-RateLimit-Limit", "RateLimit-Limit")
+	rl.Remaining = headerInt("X-RateLimit-Remaining", "RateLimit-Remaining")
+	if reset := headerInt("X-RateLimit-Reset", "RateLimit-Reset"); reset != nil {
+		var at time.Time
+		if *reset >= epochThreshold {
+			at = time.Unix(int64(*reset), 0)
+		} else {
+			at = now.Add(time.Duration(*reset) * time.Second)
+		}
+		rl.Reset = &at
+	}
+	if v := h.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			rl.RetryAfter = secs
+			found = true
+		} else if at, err := http.ParseTime(v); err == nil {
+			rl.RetryAfter = int(at.Sub(now).Seconds())
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	switch {
+	case status == http.StatusTooManyRequests:
+		rl.Low = true
+	case rl.Remaining != nil && *rl.Remaining <= 0:
+		rl.Low = true
+	case rl.Remaining != nil && rl.Limit != nil && *rl.Limit > 0:
+		rl.Low = *rl.Remaining*10 < *rl.Limit
+	}
+	return rl
+}
+
+// observeRateLimit records the rate-limit headers of a response as the latest
+// state of the API.
+func (t *HTTPTransport) observeRateLimit(resp *http.Response) *RateLimit {
+	rl := ParseRateLimit(resp.Header, resp.StatusCode, time.Now())
+	if rl == nil {
+		return nil
+	}
+
+	t.rateLimitMu.Lock()
+	wasLow := t.rateLimit != nil && t.rateLimit.Low
+	t.rateLimit = rl
+	t.rateLimitMu.Unlock()
+
+	if rl.Low && !wasLow {
+		fmt.Printf("[HTTP] %s rate limit nearly exhausted (status %d)\n", t.Config.Name, resp.StatusCode)
+	}
+	return rl
+}
+
+// RateLimit returns the last observed rate-limit state, or nil if unknown.
+func (t *HTTPTransport) RateLimit() *RateLimit {
+	t.rateLimitMu.Lock()
+	defer t.rateLimitMu.Unlock()
+	return t.rateLimit
+}
+
+// RateLimits returns the last observed rate-limit state of every HTTP-wrapped
+// upstream that reported one, keyed by server name.
+func (g *Gateway) RateLimits() map[string]*RateLimit {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	limits := make(map[string]*RateLimit)
+	for _, client := range g.upstreams {
+		if t, ok := client.transport.(*HTTPTransport); ok {
+			if rl := t.RateLimit(); rl != nil {
+				limits[client.Config.Name] = rl
+			}
+		}
+	}
+	return limits
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"fmt"
+	"one-mcp/internal/model"
+	"strings"
+)
+
+// ValidateServerName rejects names that cannot be routed unambiguously: empty
+// names, names reserved by the gateway and names containing the "__" separator
+// between server and tool names.
+func ValidateServerName(name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return fmt.Errorf("server name is required")
+	case ReservedServerName(name):
+		return fmt.Errorf("server name %q is reserved", name)
+	case strings.Contains(name, "__"):
+		return fmt.Errorf("server name %q must not contain \"__\"", name)
+	}
+	return nil
+}
+
+// routableServers returns the servers whose names route to exactly one of them.
+// Servers with invalid names, or sharing a name with another server, are left
+// out with an error each, instead of letting one silently shadow the other.
+func routableServers(servers []model.UpstreamServer) ([]model.UpstreamServer, []error) {
+	byName := make(map[string][]model.UpstreamServer, len(servers))
+	for _, s := range servers {
+		byName[s.Name] = append(byName[s.Name], s)
+	}
+
+	var routable []model.UpstreamServer
+	var errs []error
+	for _, s := range servers {
+		if err := ValidateServerName(s.Name); err != nil {
+			errs = append(errs, fmt.Errorf("server %d not started: %v", s.ID, err))
+			continue
+		}
+		if same := byName[s.Name]; len(same) > 1 {
+			errs = append(errs, fmt.Errorf("server %d not started: name %q is shared by %d servers", s.ID, s.Name, len(same)))
+			continue
+		}
+		routable = append(routable, s)
+	}
+	return routable, errs
+}
+
+// upstream returns the running client of the server with the given name.
+func (g *Gateway) upstream(name string) (*UpstreamClient, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.upstreamLocked(name)
+}
+
+// upstreamLocked is upstream for callers already holding g.mu.
+func (g *Gateway) upstreamLocked(name string) (*UpstreamClient, bool) {
+	id, ok := g.upstreamIDs[name]
+	if !ok {
+		return nil, false
+	}
+	client, ok := g.upstreams[id]
+	return client, ok
+}
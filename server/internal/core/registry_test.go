@@ -0,0 +1,149 @@
+package core
+
+import (
+	"context"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestRoutableServers(t *testing.T) {
+	routable, errs := routableServers([]model.UpstreamServer{
+		{ID: 1, Name: "github"},
+		{ID: 2, Name: "files"},
+		{ID: 3, Name: "files"},
+		{ID: 4, Name: "a__b"},
+		{ID: 5, Name: WorkflowServer},
+	})
+	assert.Len(t, routable, 1)
+	assert.Equal(t, uint(1), routable[0].ID)
+	assert.Len(t, errs, 4)
+	assert.Contains(t, errs[0].Error(), "shared by 2 servers")
+}
+
+func TestReloadUpstreamsRename(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&model.UpstreamServer{}, &model.MaintenanceWindow{}))
+
+	server := model.UpstreamServer{Name: "alpha", TransportType: "demo", Enabled: true}
+	db.Create(&server)
+	g := NewGateway(db, &config.Config{})
+	g.ReloadUpstreams()
+
+	client, ok := g.upstream("alpha")
+	assert.True(t, ok)
+	assert.Equal(t, server.ID, client.Config.ID)
+
+	// Renaming leaves no stale client under the old name
+	db.Model(&server).Update("name", "beta")
+	g.ReloadUpstreams()
+	_, ok = g.upstream("alpha")
+	assert.False(t, ok)
+	client, ok = g.upstream("beta")
+	assert.True(t, ok)
+	byID, _ := g.UpstreamByID(server.ID)
+	assert.Same(t, client, byID)
+
+	// A disabled server stops routing, and re-enabling routes to the same ID
+	db.Model(&server).Update("enabled", false)
+	g.ReloadUpstreams()
+	_, ok = g.upstream("beta")
+	assert.False(t, ok)
+	db.Model(&server).Update("enabled", true)
+	g.ReloadUpstreams()
+	client, ok = g.upstream("beta")
+	assert.True(t, ok)
+	assert.Equal(t, server.ID, client.Config.ID)
+}
+
+// slowStopTransport takes until release is closed to close, like a stdio
+// process using its grace period.
+type slowStopTransport struct {
+	release chan struct{}
+}
+
+func (t *slowStopTransport) Start(ctx context.Context, onMessage func([]byte), onReady func()) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (t *slowStopTransport) Send(payload []byte) error { return nil }
+
+func (t *slowStopTransport) Close() error {
+	<-t.release
+	return nil
+}
+
+func TestStopUpstreamsOutsideLock(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&model.UpstreamServer{}, &model.MaintenanceWindow{}))
+	g := NewGateway(db, &config.Config{})
+
+	for _, stop := range []func(){g.StopUpstreams, g.ReloadUpstreams} {
+		transport := &slowStopTransport{release: make(chan struct{})}
+		ctx, cancel := context.WithCancel(context.Background())
+		g.mu.Lock()
+		g.upstreams[1] = &UpstreamClient{Config: model.UpstreamServer{ID: 1, Name: "slow"}, transport: transport, ctx: ctx, cancel: cancel}
+		g.upstreamIDs["slow"] = 1
+		g.mu.Unlock()
+
+		done := make(chan struct{})
+		go func() {
+			stop()
+			close(done)
+		}()
+		// The gateway stays usable while the old client stops
+		assert.Eventually(t, func() bool {
+			_, ok := g.upstream("slow")
+			return !ok
+		}, time.Second, 10*time.Millisecond)
+		select {
+		case <-done:
+			t.Fatal("returned before the client stopped")
+		default:
+		}
+		close(transport.release)
+		<-done
+	}
+}
+
+func TestReloadUpstreamsStdioExitsBeforeRestart(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stdio processes are killed on Windows")
+	}
+	// The server logs its start, and its stop some time after SIGTERM
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	script := filepath.Join(dir, "server.sh")
+	body := "#!/bin/sh\ntrap 'sleep 0.3; echo stop >> \"$1\"; exit 0' TERM\necho start >> \"$1\"\nwhile true; do sleep 0.05; done\n"
+	assert.NoError(t, os.WriteFile(script, []byte(body), 0o700))
+	lines := func() []string {
+		data, _ := os.ReadFile(log)
+		return strings.Fields(string(data))
+	}
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&model.UpstreamServer{}, &model.MaintenanceWindow{}))
+	db.Create(&model.UpstreamServer{Name: "db", TransportType: "stdio", Command: script, Args: `["` + log + `"]`, Enabled: true})
+	g := NewGateway(db, &config.Config{MaxMessageSize: 1024, StdioStopGrace: 5 * time.Second, UpstreamTimeout: time.Minute, InitTimeout: time.Minute, ReconnectDelay: time.Minute})
+	defer g.StopUpstreams()
+
+	g.ReloadUpstreams()
+	assert.Eventually(t, func() bool { return len(lines()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	g.ReloadUpstreams()
+	assert.Eventually(t, func() bool { return len(lines()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"start", "stop", "start"}, lines(), "the old process exits before the new one starts")
+}
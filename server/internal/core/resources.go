@@ -0,0 +1,106 @@
+package core
+
+import (
+	"encoding/json"
+	"one-mcp/internal/report"
+	"sync"
+)
+
+// ResourceServerMeta is the _meta key naming the upstream a resource comes from.
+const ResourceServerMeta = "one-mcp/server"
+
+// handleResourcesList aggregates resources from all visible upstreams. URIs are kept
+// as is; each resource is attributed to its server in _meta, and the gateway
+// remembers the owner of each URI to route resources/read.
+func (g *Gateway) handleResourcesList(req *JSONRPCMessage, caller *Caller) (*JSONRPCMessage, error) {
+	var all []map[string]interface{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, client := range g.visibleClients(caller) {
+		wg.Add(1)
+		go func(c *UpstreamClient) {
+			defer wg.Done()
+			defer report.Recover("resources/list " + c.Config.Name)
+
+			var allowed []map[string]interface{}
+			for _, resource := range c.listAll("resources/list", "resources") {
+				uri, _ := resource["uri"].(string)
+				if uri == "" || !caller.ResourceAllowed(c.Config.Name, uri) {
+					continue
+				}
+				meta, _ := resource["_meta"].(map[string]interface{})
+				if meta == nil {
+					meta = map[string]interface{}{}
+				}
+				meta[ResourceServerMeta] = c.Config.Name
+				resource["_meta"] = meta
+				allowed = append(allowed, resource)
+				g.rememberResource(uri, c.Config.Name)
+			}
+			mu.Lock()
+			all = append(all, allowed...)
+			mu.Unlock()
+		}(client)
+	}
+	wg.Wait()
+
+	if all == nil {
+		all = []map[string]interface{}{}
+	}
+	resBytes, _ := json.Marshal(map[string]interface{}{"resources": all})
+	return &JSONRPCMessage{JSONRPC: "2.0", ID: req.ID, Result: resBytes}, nil
+}
+
+func (g *Gateway) rememberResource(uri string, server string) {
+	g.resourceMu.Lock()
+	defer g.resourceMu.Unlock()
+	if g.resourceOwners == nil {
+		g.resourceOwners = make(map[string]string)
+	}
+	g.resourceOwners[uri] = server
+}
+
+// readUpstreamResource routes resources/read to the upstream owning the URI. URIs not
+// seen in a listing (e.g. from resource templates) are tried on each visible upstream.
+func (g *Gateway) readUpstreamResource(req *JSONRPCMessage, caller *Caller, uri string) *JSONRPCMessage {
+	params := map[string]interface{}{"uri": uri}
+	if meta := requestMeta(req); meta != nil {
+		params["_meta"] = meta
+	}
+	defer g.trackProgress(req, caller, params)()
+
+	for _, c := range g.resourceCandidates(caller, uri) {
+		resp, err := c.CallAs(caller.Key(), "resources/read", params)
+		if err != nil || resp.Error != nil {
+			continue
+		}
+		resp.ID = req.ID
+		return resp
+	}
+	return &JSONRPCMessage{
+		JSONRPC: "2.0", ID: req.ID,
+		Error: &JSONRPCError{Code: -32002, Message: "Resource not found"},
+	}
+}
+
+// resourceCandidates returns the visible upstreams that may own a URI: the owner
+// from the last listing, or every upstream allowed to serve it if it is unknown.
+func (g *Gateway) resourceCandidates(caller *Caller, uri string) []*UpstreamClient {
+	g.resourceMu.Lock()
+	owner := g.resourceOwners[uri]
+	g.resourceMu.Unlock()
+
+	var candidates []*UpstreamClient
+	for _, c := range g.visibleClients(caller) {
+		if !caller.ResourceAllowed(c.Config.Name, uri) {
+			continue
+		}
+		if c.Config.Name == owner {
+			candidates = append([]*UpstreamClient{c}, candidates...)
+		} else if owner == "" {
+			candidates = append(candidates, c)
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResources(t *testing.T) {
+	// Each server serves its own files and reads any URI it is asked for
+	serve := func(server string, uris ...string) map[string]func(json.RawMessage) interface{} {
+		return map[string]func(json.RawMessage) interface{}{
+			"resources/list": func(json.RawMessage) interface{} {
+				var resources []interface{}
+				for _, uri := range uris {
+					resources = append(resources, map[string]interface{}{"uri": uri, "name": uri})
+				}
+				return map[string]interface{}{"resources": resources}
+			},
+			"resources/read": func(params json.RawMessage) interface{} {
+				var p struct {
+					URI string `json:"uri"`
+				}
+				json.Unmarshal(params, &p)
+				return map[string]interface{}{"contents": []interface{}{map[string]interface{}{"uri": p.URI, "text": "from " + server}}}
+			},
+		}
+	}
+	// IDs follow the names: docs 1, tools 2, wiki 3
+	g := newMethodGateway(map[string]map[string]func(json.RawMessage) interface{}{
+		"docs":  serve("docs", "file:///guide.md", "file:///secret.md"),
+		"wiki":  serve("wiki", "file:///home.md"),
+		"tools": {},
+	})
+	list := func(caller *Caller) map[string]string {
+		resp, err := g.handleResourcesList(&JSONRPCMessage{}, caller)
+		require.NoError(t, err)
+		var result struct {
+			Resources []struct {
+				URI  string            `json:"uri"`
+				Meta map[string]string `json:"_meta"`
+			} `json:"resources"`
+		}
+		json.Unmarshal(resp.Result, &result)
+		owners := map[string]string{}
+		for _, r := range result.Resources {
+			owners[r.URI] = r.Meta[ResourceServerMeta]
+		}
+		return owners
+	}
+	read := func(caller *Caller, uri string) *JSONRPCMessage {
+		resp, err := g.handleResourceRead(&JSONRPCMessage{Params: json.RawMessage(`{"uri":"` + uri + `"}`)}, caller)
+		require.NoError(t, err)
+		return resp
+	}
+
+	restricted := &Caller{AllowedResources: []string{"docs__file:///guide.md", "wiki__*"}}
+	owners := list(restricted)
+	uris := make([]string, 0, len(owners))
+	for uri := range owners {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	assert.Equal(t, []string{"file:///guide.md", "file:///home.md"}, uris)
+	assert.Equal(t, "docs", owners["file:///guide.md"], "resources are attributed to their server")
+	assert.Equal(t, "wiki", owners["file:///home.md"])
+
+	// Listed URIs are read from their owner
+	resp := read(restricted, "file:///home.md")
+	assert.Nil(t, resp.Error)
+	assert.Contains(t, string(resp.Result), "from wiki")
+	assert.NotNil(t, read(&Caller{AllowedResources: []string{"docs__file:///guide.md"}}, "file:///secret.md").Error,
+		"resources the key may not use are not read")
+
+	// Unlisted URIs are tried on the upstreams allowed to serve them
+	resp = read(&Caller{AllowedResources: []string{"docs__*"}}, "file:///template/1")
+	assert.Nil(t, resp.Error)
+	assert.Contains(t, string(resp.Result), "from docs")
+	assert.NotNil(t, read(&Caller{AllowedServers: []string{"2"}}, "file:///home.md").Error, "only visible servers are asked")
+}
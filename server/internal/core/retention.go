@@ -0,0 +1,89 @@
+package core
+
+import (
+	"fmt"
+	"one-mcp/internal/model"
+	"one-mcp/internal/report"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PruneReport counts the rows deleted by a prune run, by table.
+type PruneReport map[string]int64
+
+// StartRetention runs Prune every PRUNE_INTERVAL.
+func (g *Gateway) StartRetention() {
+	go func() {
+		for {
+			func() {
+				defer report.Recover("retention")
+				g.Prune()
+			}()
+			time.Sleep(g.settings.PruneInterval)
+		}
+	}()
+}
+
+// Prune deletes rows older than their configured retention: call history, recorded
+// payloads, finished asynchronous jobs, workflow traces, moderation logs and
+// soft-deleted rows, as well as expired memory tool values. Priced calls are kept
+// at least until the month after theirs has ended.
+func (g *Gateway) Prune() PruneReport {
+	now := time.Now()
+	result := PruneReport{}
+	count := func(table string, tx *gorm.DB) {
+		if tx.Error != nil {
+			fmt.Printf("[Retention] Failed to prune %s: %v\n", table, tx.Error)
+			return
+		}
+		if tx.RowsAffected > 0 {
+			result[table] += tx.RowsAffected
+		}
+	}
+
+	if g.settings.CallRetention > 0 {
+		cutoff := now.Add(-g.settings.CallRetention)
+		// Priced calls stay until their month is no longer the default (previous
+		// month) invoice, so a short retention does not lose billable calls
+		local := now.In(g.Location(""))
+		invoiced := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, local.Location()).AddDate(0, -1, 0)
+		count("usage_logs", g.db.Where("created_at < ? AND (cost_micros = 0 OR created_at < ?)", cutoff, invoiced).Delete(&model.UsageLog{}))
+		count("async_jobs", g.db.Where("finished_at < ?", cutoff).Delete(&model.AsyncJob{}))
+		count("upstream_logs", g.db.Where("created_at < ?", cutoff).Delete(&model.UpstreamLog{}))
+		count("workflow_runs", g.db.Where("created_at < ?", cutoff).Delete(&model.WorkflowRun{}))
+	}
+	if g.settings.RecordingRetention > 0 {
+		count("call_recordings", g.db.Where("created_at < ?", now.Add(-g.settings.RecordingRetention)).Delete(&model.CallRecording{}))
+	}
+	// Blobs no longer referenced by any recording
+	count("content_blobs", g.db.Where("hash NOT IN (?)", g.db.Model(&model.CallRecording{}).Select("response_hash").Where("response_hash <> ''")).Delete(&model.ContentBlob{}))
+
+	// Expired values of the memory tools, already invisible to agents
+	count("kv_entries", g.db.Where("expires_at < ?", now).Delete(&model.KVEntry{}))
+
+	if g.settings.ModerationRetention > 0 {
+		count("moderation_logs", g.db.Where("created_at < ?", now.Add(-g.settings.ModerationRetention)).Delete(&model.ModerationLog{}))
+	}
+	if g.settings.DeletedRetention > 0 {
+		cutoff := now.Add(-g.settings.DeletedRetention)
+		for table, m := range map[string]interface{}{
+			"upstream_servers": &model.UpstreamServer{},
+			"api_keys":         &model.ApiKey{},
+			"teams":            &model.Team{},
+		} {
+			count(table, g.db.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Delete(m))
+		}
+	}
+
+	if len(result) > 0 {
+		fmt.Printf("[Retention] Pruned %v\n", map[string]int64(result))
+	}
+	return result
+}
+
+// Vacuum rebuilds the database file so space freed by pruning is returned to the
+// file system.
+func (g *Gateway) Vacuum() error {
+	return g.db.Exec("VACUUM").Error
+}
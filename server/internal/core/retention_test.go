@@ -0,0 +1,87 @@
+package core
+
+import (
+	"encoding/json"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestPrune(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&model.UsageLog{}, &model.AsyncJob{}, &model.CallRecording{},
+		&model.ContentBlob{}, &model.ModerationLog{}, &model.UpstreamServer{}, &model.ApiKey{}, &model.Team{}))
+
+	old := time.Now().Add(-48 * time.Hour)
+	db.Create(&model.UsageLog{CreatedAt: old})
+	db.Create(&model.UsageLog{})
+	// Priced calls wait for the invoice of their month
+	db.Create(&model.UsageLog{CreatedAt: old, CostMicros: 2500})
+	db.Create(&model.UsageLog{CreatedAt: time.Now().AddDate(0, 0, -70), CostMicros: 2500})
+	db.Create(&model.ModerationLog{CreatedAt: old})
+	db.Create(&model.ContentBlob{Hash: "orphan"})
+
+	gone := model.ApiKey{Key: "sk-gone"}
+	recent := model.ApiKey{Key: "sk-recent"}
+	db.Create(&gone)
+	db.Create(&recent)
+	db.Model(&gone).UpdateColumn("deleted_at", old)
+	db.Delete(&recent)
+
+	g := &Gateway{db: db, settings: &config.Config{
+		CallRetention:       24 * time.Hour,
+		ModerationRetention: 24 * time.Hour,
+		DeletedRetention:    24 * time.Hour,
+	}}
+	assert.Equal(t, PruneReport{"usage_logs": 2, "moderation_logs": 1, "content_blobs": 1, "api_keys": 1}, g.Prune())
+
+	var priced int64
+	db.Model(&model.UsageLog{}).Where("cost_micros > 0").Count(&priced)
+	assert.Equal(t, int64(1), priced)
+
+	var keys int64
+	db.Unscoped().Model(&model.ApiKey{}).Count(&keys)
+	assert.Equal(t, int64(1), keys)
+	assert.NoError(t, g.Vacuum())
+}
+
+func TestPruneKeepsBlobsBeingRecorded(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "one-mcp.db") + "?_pragma=busy_timeout(5000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(model.All...))
+	g := NewGateway(db, &config.Config{RecordCalls: true})
+
+	// Prune right before a recording is written, after its blob: it must not
+	// find the blob unreferenced
+	db.Callback().Create().Before("gorm:create").Register("test:prune", func(tx *gorm.DB) {
+		if tx.Statement.Schema == nil || tx.Statement.Schema.Table != "call_recordings" {
+			return
+		}
+		pruned := make(chan struct{})
+		go func() {
+			g.Prune()
+			close(pruned)
+		}()
+		select {
+		case <-pruned:
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	result, _ := json.Marshal(map[string]string{"text": strings.Repeat("x", dedupThreshold)})
+	g.recordUsage(&Caller{KeyID: 1}, "demo", "demo__echo", nil, &JSONRPCMessage{Result: result}, nil, time.Now())
+
+	var recording model.CallRecording
+	assert.NoError(t, db.First(&recording).Error)
+	assert.NotEmpty(t, recording.ResponseHash)
+	assert.Equal(t, string(result), RecordedResponse(db, &recording))
+}
@@ -0,0 +1,196 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/report"
+	"sync/atomic"
+	"time"
+)
+
+// reverseMethods maps the requests an upstream may send to its client to the
+// client capability they require. They are forwarded to a downstream session.
+var reverseMethods = map[string]string{
+	"sampling/createMessage": "sampling",
+	"elicitation/create":     "elicitation",
+}
+
+// clientRequestTimeout bounds the wait for a downstream client to answer a
+// forwarded request; sampling and elicitation may involve the user.
+const clientRequestTimeout = 5 * time.Minute
+
+// clientRequest is a gateway-initiated request awaiting a client's response.
+type clientRequest struct {
+	sessionID string
+	ch        chan *JSONRPCMessage
+}
+
+// trackCaller records a caller as having a call in flight on the upstream, so
+// requests the upstream sends meanwhile can be routed to it. The returned
+// function ends the tracking.
+func (c *UpstreamClient) trackCaller(caller *Caller) func() {
+	c.callersMu.Lock()
+	c.callers = append(c.callers, caller)
+	c.callersMu.Unlock()
+
+	return func() {
+		c.callersMu.Lock()
+		defer c.callersMu.Unlock()
+		for i, active := range c.callers {
+			if active == caller {
+				c.callers = append(c.callers[:i], c.callers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// activeCaller returns the most recent caller with a call in flight whose session
+// supports the capability, or nil.
+func (c *UpstreamClient) activeCaller(capability string) *Caller {
+	return c.latestCaller(func(caller *Caller) bool {
+		return caller.Notify != nil && caller.Supports(capability)
+	})
+}
+
+// latestCaller returns the most recent caller with a call in flight that matches, or nil.
+func (c *UpstreamClient) latestCaller(match func(*Caller) bool) *Caller {
+	c.callersMu.Lock()
+	defer c.callersMu.Unlock()
+	for i := len(c.callers) - 1; i >= 0; i-- {
+		if caller := c.callers[i]; match(caller) {
+			return caller
+		}
+	}
+	return nil
+}
+
+// respond answers a request sent by the upstream.
+func (c *UpstreamClient) respond(id *json.RawMessage, result json.RawMessage, rpcErr *JSONRPCError) {
+	resp := JSONRPCMessage{JSONRPC: "2.0", ID: id, Result: result, Error: rpcErr}
+	if rpcErr == nil && result == nil {
+		resp.Result = json.RawMessage("{}")
+	}
+	payload, _ := json.Marshal(resp)
+	if err := c.send(payload); err != nil {
+		fmt.Printf("[Upstream %s] Failed to answer request: %v\n", c.Config.Name, err)
+	}
+}
+
+// handleUpstreamRequest forwards a request from an upstream to the downstream
+// session it is working for, and relays the client's answer back.
+func (g *Gateway) handleUpstreamRequest(c *UpstreamClient, req *JSONRPCMessage) {
+	defer report.Recover("request from " + c.Config.Name)
+
+	if req.Method == "ping" {
+		c.respond(req.ID, nil, nil)
+		return
+	}
+	if req.Method == "roots/list" {
+		g.handleRootsList(c, req)
+		return
+	}
+	capability, ok := reverseMethods[req.Method]
+	if !ok {
+		c.respond(req.ID, nil, &JSONRPCError{Code: -32601, Message: "Method not found"})
+		return
+	}
+	caller := c.activeCaller(capability)
+	if caller == nil && req.Method == "elicitation/create" && c.latestCaller(func(caller *Caller) bool { return caller.DeclineElicitation }) != nil {
+		// The key opted to have elicitations its client cannot show declined
+		c.respond(req.ID, json.RawMessage(`{"action":"decline"}`), nil)
+		return
+	}
+	if caller == nil {
+		c.respond(req.ID, nil, &JSONRPCError{
+			Code:    -32603,
+			Message: fmt.Sprintf("No connected client supports %s", capability),
+		})
+		return
+	}
+
+	params := req.Params
+	if req.Method == "sampling/createMessage" {
+		var err error
+		if params, err = c.applySamplingPolicy(params); err != nil {
+			c.respond(req.ID, nil, &JSONRPCError{Code: -32602, Message: err.Error()})
+			return
+		}
+	}
+
+	fmt.Printf("[Gateway] Forwarding %s from %s to session %s\n", req.Method, c.Config.Name, caller.SessionID)
+	resp, err := g.requestClient(caller, req.Method, params)
+	if err != nil {
+		c.respond(req.ID, nil, &JSONRPCError{Code: -32603, Message: err.Error()})
+		return
+	}
+	c.respond(req.ID, resp.Result, resp.Error)
+}
+
+// requestClient sends a request to a downstream session and waits for its response.
+func (g *Gateway) requestClient(caller *Caller, method string, params json.RawMessage) (*JSONRPCMessage, error) {
+	id := fmt.Sprintf("one-mcp-%d", atomic.AddInt64(&g.clientReqSeq, 1))
+	pending := &clientRequest{sessionID: caller.SessionID, ch: make(chan *JSONRPCMessage, 1)}
+
+	g.clientReqMu.Lock()
+	if g.clientRequests == nil {
+		g.clientRequests = make(map[string]*clientRequest)
+	}
+	g.clientRequests[id] = pending
+	g.clientReqMu.Unlock()
+	defer func() {
+		g.clientReqMu.Lock()
+		delete(g.clientRequests, id)
+		g.clientReqMu.Unlock()
+	}()
+
+	rawID, _ := json.Marshal(id)
+	reqID := json.RawMessage(rawID)
+	payload, _ := json.Marshal(&JSONRPCMessage{JSONRPC: "2.0", ID: &reqID, Method: method, Params: params})
+	if !caller.Deliver(payload) {
+		return nil, fmt.Errorf("client session closed")
+	}
+
+	select {
+	case resp := <-pending.ch:
+		if resp == nil {
+			return nil, fmt.Errorf("client session closed")
+		}
+		return resp, nil
+	case <-time.After(clientRequestTimeout):
+		return nil, fmt.Errorf("timed out waiting for the client")
+	}
+}
+
+// handleClientResponse delivers a client's response to the gateway-initiated
+// request it answers. Responses from other sessions are ignored.
+func (g *Gateway) handleClientResponse(resp *JSONRPCMessage, caller *Caller) {
+	var id string
+	json.Unmarshal(*resp.ID, &id)
+
+	g.clientReqMu.Lock()
+	pending, ok := g.clientRequests[id]
+	g.clientReqMu.Unlock()
+	if !ok || pending.sessionID != caller.SessionID {
+		fmt.Printf("[Gateway] Ignoring response to unknown request %s\n", string(*resp.ID))
+		return
+	}
+	select {
+	case pending.ch <- resp:
+	default:
+	}
+}
+
+// cancelClientRequests fails the pending requests of a session that ended.
+func (g *Gateway) cancelClientRequests(sessionID string) {
+	g.clientReqMu.Lock()
+	defer g.clientReqMu.Unlock()
+	for _, pending := range g.clientRequests {
+		if pending.sessionID == sessionID {
+			select {
+			case pending.ch <- nil:
+			default:
+			}
+		}
+	}
+}
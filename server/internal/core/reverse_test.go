@@ -0,0 +1,253 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingTransport captures the payloads sent to an upstream.
+type recordingTransport struct {
+	sent chan []byte
+}
+
+func (t *recordingTransport) Start(ctx context.Context, onMessage func([]byte), onReady func()) error {
+	return nil
+}
+
+func (t *recordingTransport) Send(payload []byte) error {
+	t.sent <- payload
+	return nil
+}
+
+func (t *recordingTransport) Close() error { return nil }
+
+func TestSamplingPassthrough(t *testing.T) {
+	g := &Gateway{}
+	transport := &recordingTransport{sent: make(chan []byte, 4)}
+	upstream := &UpstreamClient{Config: model.UpstreamServer{Name: "llm-tool"}, transport: transport}
+
+	toClient := make(chan []byte, 4)
+	sampler := &Caller{
+		SessionID:    "s1",
+		Capabilities: map[string]json.RawMessage{"sampling": json.RawMessage("{}")},
+		Notify: func(msg []byte) bool {
+			toClient <- msg
+			return true
+		},
+	}
+	plain := &Caller{SessionID: "s2", Notify: func([]byte) bool { return true }}
+
+	t.Run("Routes To The Session With A Call In Flight", func(t *testing.T) {
+		done := upstream.trackCaller(sampler)
+		defer done()
+		defer upstream.trackCaller(plain)()
+
+		upstreamID := json.RawMessage(`42`)
+		go g.handleUpstreamRequest(upstream, &JSONRPCMessage{
+			JSONRPC: "2.0", ID: &upstreamID, Method: "sampling/createMessage",
+			Params: json.RawMessage(`{"messages":[],"maxTokens":10}`),
+		})
+
+		var forwarded JSONRPCMessage
+		json.Unmarshal(<-toClient, &forwarded)
+		assert.Equal(t, "sampling/createMessage", forwarded.Method)
+
+		// A response from another session is ignored
+		g.handleClientResponse(&JSONRPCMessage{ID: forwarded.ID, Result: json.RawMessage(`{}`)}, plain)
+		g.handleClientResponse(&JSONRPCMessage{ID: forwarded.ID, Result: json.RawMessage(`{"role":"assistant"}`)}, sampler)
+
+		select {
+		case payload := <-transport.sent:
+			assert.JSONEq(t, `{"jsonrpc":"2.0","id":42,"result":{"role":"assistant"}}`, string(payload))
+		case <-time.After(time.Second):
+			t.Fatal("response not relayed upstream")
+		}
+	})
+
+	t.Run("No Capable Client", func(t *testing.T) {
+		defer upstream.trackCaller(plain)()
+		upstreamID := json.RawMessage(`43`)
+		g.handleUpstreamRequest(upstream, &JSONRPCMessage{JSONRPC: "2.0", ID: &upstreamID, Method: "sampling/createMessage"})
+
+		var resp JSONRPCMessage
+		json.Unmarshal(<-transport.sent, &resp)
+		assert.Contains(t, resp.Error.Message, "sampling")
+	})
+
+	t.Run("Session Teardown", func(t *testing.T) {
+		defer upstream.trackCaller(sampler)()
+		upstreamID := json.RawMessage(`44`)
+		go g.handleUpstreamRequest(upstream, &JSONRPCMessage{JSONRPC: "2.0", ID: &upstreamID, Method: "sampling/createMessage"})
+		<-toClient
+		g.cancelClientRequests("s1")
+
+		var resp JSONRPCMessage
+		json.Unmarshal(<-transport.sent, &resp)
+		assert.Equal(t, "client session closed", resp.Error.Message)
+	})
+}
+
+func TestSamplingPolicy(t *testing.T) {
+	g := &Gateway{}
+	transport := &recordingTransport{sent: make(chan []byte, 4)}
+	upstream := &UpstreamClient{Config: model.UpstreamServer{
+		Name:                     "llm-tool",
+		SamplingModelPreferences: `{"hints":[{"name":"small-model"}],"costPriority":1}`,
+		SamplingMaxTokens:        100,
+	}, transport: transport}
+	toClient := make(chan []byte, 4)
+	sampler := &Caller{
+		SessionID:    "s1",
+		Capabilities: map[string]json.RawMessage{"sampling": json.RawMessage("{}")},
+		Notify: func(msg []byte) bool {
+			toClient <- msg
+			return true
+		},
+	}
+	defer upstream.trackCaller(sampler)()
+
+	forward := func(params string) map[string]interface{} {
+		upstreamID := json.RawMessage(`1`)
+		go g.handleUpstreamRequest(upstream, &JSONRPCMessage{JSONRPC: "2.0", ID: &upstreamID, Method: "sampling/createMessage", Params: json.RawMessage(params)})
+		var forwarded JSONRPCMessage
+		json.Unmarshal(<-toClient, &forwarded)
+		g.cancelClientRequests("s1")
+		<-transport.sent
+		var decoded map[string]interface{}
+		json.Unmarshal(forwarded.Params, &decoded)
+		return decoded
+	}
+
+	params := forward(`{"messages":[],"maxTokens":5000,"modelPreferences":{"hints":[{"name":"huge-model"}],"intelligencePriority":1}}`)
+	assert.Equal(t, map[string]interface{}{"hints": []interface{}{map[string]interface{}{"name": "small-model"}}, "costPriority": float64(1)}, params["modelPreferences"])
+	assert.Equal(t, float64(100), params["maxTokens"])
+
+	params = forward(`{"messages":[],"maxTokens":50}`)
+	assert.Equal(t, float64(50), params["maxTokens"], "smaller requests are kept")
+
+	t.Run("Validation", func(t *testing.T) {
+		for _, raw := range []string{`[]`, `{"hints":"x"}`, `{"hints":[{}]}`, `{"costPriority":2}`, `{"model":"x"}`} {
+			_, err := ParseModelPreferences(raw)
+			assert.Error(t, err, raw)
+		}
+		prefs, err := ParseModelPreferences("")
+		assert.NoError(t, err)
+		assert.Nil(t, prefs)
+	})
+}
+
+func TestRootsList(t *testing.T) {
+	g := &Gateway{}
+	transport := &recordingTransport{sent: make(chan []byte, 4)}
+	upstream := &UpstreamClient{Config: model.UpstreamServer{Name: "fs"}, transport: transport}
+
+	rootsList := func(id string) string {
+		upstreamID := json.RawMessage(id)
+		go g.handleUpstreamRequest(upstream, &JSONRPCMessage{JSONRPC: "2.0", ID: &upstreamID, Method: "roots/list"})
+		select {
+		case payload := <-transport.sent:
+			return string(payload)
+		case <-time.After(time.Second):
+			t.Fatal("roots/list not answered")
+			return ""
+		}
+	}
+
+	t.Run("Empty Without A Caller", func(t *testing.T) {
+		assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":{"roots":[]}}`, rootsList("1"))
+	})
+
+	t.Run("Static Roots Of The Key", func(t *testing.T) {
+		roots, err := ParseRoots(`[{"uri":"file:///srv/project","name":"project"}]`)
+		assert.NoError(t, err)
+		defer upstream.trackCaller(&Caller{SessionID: "s1", Roots: roots})()
+
+		assert.JSONEq(t, `{"jsonrpc":"2.0","id":2,"result":{"roots":[{"uri":"file:///srv/project","name":"project"}]}}`, rootsList("2"))
+	})
+
+	t.Run("Asks The Client", func(t *testing.T) {
+		toClient := make(chan []byte, 1)
+		client := &Caller{
+			SessionID:    "s2",
+			Capabilities: map[string]json.RawMessage{"roots": json.RawMessage(`{"listChanged":true}`)},
+			Notify: func(msg []byte) bool {
+				toClient <- msg
+				return true
+			},
+		}
+		defer upstream.trackCaller(client)()
+
+		go func() {
+			var forwarded JSONRPCMessage
+			json.Unmarshal(<-toClient, &forwarded)
+			g.handleClientResponse(&JSONRPCMessage{ID: forwarded.ID, Result: json.RawMessage(`{"roots":[{"uri":"file:///home"}]}`)}, client)
+		}()
+		assert.JSONEq(t, `{"jsonrpc":"2.0","id":3,"result":{"roots":[{"uri":"file:///home"}]}}`, rootsList("3"))
+	})
+
+	t.Run("Rejects Non-File Roots", func(t *testing.T) {
+		_, err := ParseRoots(`[{"uri":"https://example.com"}]`)
+		assert.Error(t, err)
+	})
+}
+
+func TestElicitationPassthrough(t *testing.T) {
+	g := &Gateway{}
+	transport := &recordingTransport{sent: make(chan []byte, 4)}
+	upstream := &UpstreamClient{Config: model.UpstreamServer{Name: "deploy"}, transport: transport}
+
+	elicit := func(id string) string {
+		upstreamID := json.RawMessage(id)
+		go g.handleUpstreamRequest(upstream, &JSONRPCMessage{
+			JSONRPC: "2.0", ID: &upstreamID, Method: "elicitation/create",
+			Params: json.RawMessage(`{"message":"Deploy to production?","requestedSchema":{"type":"object","properties":{"confirm":{"type":"boolean"}}}}`),
+		})
+		select {
+		case payload := <-transport.sent:
+			return string(payload)
+		case <-time.After(time.Second):
+			t.Fatal("elicitation/create not answered")
+			return ""
+		}
+	}
+
+	t.Run("Forwarded To A Capable Client", func(t *testing.T) {
+		toClient := make(chan []byte, 1)
+		client := &Caller{
+			SessionID:    "s1",
+			Capabilities: map[string]json.RawMessage{"elicitation": json.RawMessage(`{}`)},
+			Notify: func(msg []byte) bool {
+				toClient <- msg
+				return true
+			},
+		}
+		defer upstream.trackCaller(client)()
+
+		go func() {
+			var forwarded JSONRPCMessage
+			json.Unmarshal(<-toClient, &forwarded)
+			assert.Equal(t, "elicitation/create", forwarded.Method)
+			g.handleClientResponse(&JSONRPCMessage{ID: forwarded.ID, Result: json.RawMessage(`{"action":"accept","content":{"confirm":true}}`)}, client)
+		}()
+		assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":{"action":"accept","content":{"confirm":true}}}`, elicit("1"))
+	})
+
+	t.Run("Error Without A Capable Client", func(t *testing.T) {
+		defer upstream.trackCaller(&Caller{SessionID: "s2", Notify: func([]byte) bool { return true }})()
+
+		var resp JSONRPCMessage
+		json.Unmarshal([]byte(elicit("2")), &resp)
+		assert.Contains(t, resp.Error.Message, "elicitation")
+	})
+
+	t.Run("Declined For Opted-In Keys", func(t *testing.T) {
+		defer upstream.trackCaller(&Caller{SessionID: "s3", DeclineElicitation: true, Notify: func([]byte) bool { return true }})()
+
+		assert.JSONEq(t, `{"jsonrpc":"2.0","id":3,"result":{"action":"decline"}}`, elicit("3"))
+	})
+}
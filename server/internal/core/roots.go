@@ -0,0 +1,58 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Root is a filesystem root exposed to upstreams through roots/list.
+type Root struct {
+	URI  string `json:"uri"`
+	Name string `json:"name,omitempty"`
+}
+
+// ParseRoots decodes the static roots of an API key. Every root must be a
+// file:// URI, as required by the protocol.
+func ParseRoots(raw string) ([]Root, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var roots []Root
+	if err := json.Unmarshal([]byte(raw), &roots); err != nil {
+		return nil, fmt.Errorf("invalid roots: %v", err)
+	}
+	for _, r := range roots {
+		if !strings.HasPrefix(r.URI, "file://") {
+			return nil, fmt.Errorf("invalid root %q: uri must start with file://", r.URI)
+		}
+	}
+	return roots, nil
+}
+
+// handleRootsList answers roots/list from an upstream on behalf of the caller it
+// is working for: with the key's static roots if configured, otherwise by asking
+// the caller's client. Without a capable caller the roots are empty, so the
+// upstream does not wait for an answer that never comes.
+func (g *Gateway) handleRootsList(c *UpstreamClient, req *JSONRPCMessage) {
+	caller := c.latestCaller(func(caller *Caller) bool {
+		return len(caller.Roots) > 0 || (caller.Notify != nil && caller.Supports("roots"))
+	})
+	if caller == nil {
+		c.respond(req.ID, json.RawMessage(`{"roots":[]}`), nil)
+		return
+	}
+	if len(caller.Roots) > 0 {
+		result, _ := json.Marshal(map[string]interface{}{"roots": caller.Roots})
+		c.respond(req.ID, result, nil)
+		return
+	}
+
+	fmt.Printf("[Gateway] Forwarding roots/list from %s to session %s\n", c.Config.Name, caller.SessionID)
+	resp, err := g.requestClient(caller, req.Method, req.Params)
+	if err != nil {
+		c.respond(req.ID, nil, &JSONRPCError{Code: -32603, Message: err.Error()})
+		return
+	}
+	c.respond(req.ID, resp.Result, resp.Error)
+}
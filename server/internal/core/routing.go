@@ -0,0 +1,185 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/model"
+	"strconv"
+	"strings"
+)
+
+// RouteServer is the reserved server prefix under which routed tools are exposed.
+const RouteServer = "route"
+
+// ReservedServerName reports whether name is used by gateway-provided tools and
+// cannot be given to an upstream.
+func ReservedServerName(name string) bool {
+	return name == WorkflowServer || name == RouteServer
+}
+
+// RouteRule dispatches a routed tool call to Tool when the argument matches.
+type RouteRule struct {
+	Argument string   `json:"argument"`
+	Equals   string   `json:"equals,omitempty"`
+	In       []string `json:"in,omitempty"`
+	Tool     string   `json:"tool"` // Prefixed upstream tool name
+}
+
+func (r RouteRule) matches(args map[string]interface{}) bool {
+	v, ok := args[r.Argument]
+	if !ok || v == nil {
+		return false
+	}
+	var value string
+	if f, isFloat := v.(float64); isFloat {
+		// Not %v, whose exponent notation would never match IDs such as 12345678
+		value = strconv.FormatFloat(f, 'f', -1, 64)
+	} else {
+		value = fmt.Sprintf("%v", v)
+	}
+	if len(r.In) > 0 {
+		for _, candidate := range r.In {
+			if candidate == value {
+				return true
+			}
+		}
+		return false
+	}
+	return r.Equals == value
+}
+
+// ParseRouteRules decodes and validates the rules of a tool route.
+func ParseRouteRules(raw string) ([]RouteRule, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var rules []RouteRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, fmt.Errorf("invalid rules: %v", err)
+	}
+	for i, rule := range rules {
+		if rule.Argument == "" {
+			return nil, fmt.Errorf("rule %d: argument is required", i+1)
+		}
+		if err := validateRouteTarget(rule.Tool); err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i+1, err)
+		}
+	}
+	return rules, nil
+}
+
+func validateRouteTarget(tool string) error {
+	parts := strings.SplitN(tool, "__", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("tool must be a prefixed name like server__tool")
+	}
+	if ReservedServerName(parts[0]) {
+		return fmt.Errorf("tool must be an upstream tool")
+	}
+	return nil
+}
+
+// ValidateToolRoute checks a route definition before it is saved.
+func ValidateToolRoute(route *model.ToolRoute) error {
+	if !ValidWorkflowName(route.Name) {
+		return fmt.Errorf("invalid route name: use letters, digits, '_' and '-' (no '__')")
+	}
+	if err := validateRouteTarget(route.DefaultTool); err != nil {
+		return fmt.Errorf("default tool: %v", err)
+	}
+	if route.InputSchema != "" {
+		var schema map[string]interface{}
+		if err := json.Unmarshal([]byte(route.InputSchema), &schema); err != nil {
+			return fmt.Errorf("invalid input schema: must be a JSON object")
+		}
+	}
+	_, err := ParseRouteRules(route.Rules)
+	return err
+}
+
+// routeTools returns the enabled routes as tool definitions. Routes without their own
+// input schema borrow the schema of their default tool from upstreamTools.
+func (g *Gateway) routeTools(upstreamTools []map[string]interface{}, hasPermission func(string, string) bool) []map[string]interface{} {
+	var routes []model.ToolRoute
+	g.db.Where("enabled = ?", true).Find(&routes)
+	if len(routes) == 0 {
+		return nil
+	}
+
+	byName := make(map[string]map[string]interface{}, len(upstreamTools))
+	for _, tool := range upstreamTools {
+		if name, ok := tool["name"].(string); ok {
+			byName[name] = tool
+		}
+	}
+
+	var tools []map[string]interface{}
+	for _, route := range routes {
+		name := RouteServer + "__" + route.Name
+		if !hasPermission(RouteServer, name) {
+			continue
+		}
+		var schema interface{} = map[string]interface{}{"type": "object"}
+		if route.InputSchema != "" {
+			json.Unmarshal([]byte(route.InputSchema), &schema)
+		} else if def, ok := byName[route.DefaultTool]; ok && def["inputSchema"] != nil {
+			schema = def["inputSchema"]
+		}
+		description := route.Description
+		if description == "" {
+			if def, ok := byName[route.DefaultTool]; ok {
+				description, _ = def["description"].(string)
+			}
+		}
+		tools = append(tools, map[string]interface{}{
+			"name":        name,
+			"description": description,
+			"inputSchema": schema,
+		})
+	}
+	return tools
+}
+
+// runRoute dispatches a routed tool call to the first matching rule's tool, or to the
+// default tool. The dispatched call is an ordinary tool call made on behalf of the
+// caller, so the caller also needs permission for the target. The target is added to
+// the result's _meta, and signed results are signed again for the routed tool.
+func (g *Gateway) runRoute(req *JSONRPCMessage, caller *Caller, hasPermission func(string, string) bool, name string, args interface{}) (*JSONRPCMessage, error) {
+	var route model.ToolRoute
+	if err := g.db.Where("name = ? AND enabled = ?", name, true).First(&route).Error; err != nil {
+		return &JSONRPCMessage{
+			JSONRPC: "2.0", ID: req.ID,
+			Error: &JSONRPCError{Code: -32602, Message: "Tool not found"},
+		}, nil
+	}
+	if !hasPermission(RouteServer, RouteServer+"__"+name) {
+		return &JSONRPCMessage{
+			JSONRPC: "2.0", ID: req.ID,
+			Error: &JSONRPCError{Code: -32000, Message: "Permission denied"},
+		}, nil
+	}
+
+	rules, err := ParseRouteRules(route.Rules)
+	if err != nil {
+		return nil, err
+	}
+	argMap, _ := args.(map[string]interface{})
+	target := route.DefaultTool
+	for _, rule := range rules {
+		if rule.matches(argMap) {
+			target = rule.Tool
+			break
+		}
+	}
+
+	fmt.Printf("[Route] %s -> %s\n", name, target)
+	params, _ := json.Marshal(map[string]interface{}{"name": target, "arguments": args})
+	resp, err := g.handleToolCall(&JSONRPCMessage{JSONRPC: "2.0", ID: req.ID, Method: "tools/call", Params: params}, caller, hasPermission)
+	if err == nil && resp != nil && resp.Result != nil {
+		resp.Result = annotateResult(resp.Result, "one-mcp/routedTo", target)
+		if caller.SigningSecret != "" {
+			resp.Result = signResult(resp.Result, caller.SigningSecret, RouteServer+"__"+name)
+		}
+	}
+	return resp, err
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"encoding/json"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestParseRouteRules(t *testing.T) {
+	rules, err := ParseRouteRules(`[{"argument":"region","in":["eu","uk"],"tool":"eu__search"},{"argument":"tier","equals":"1","tool":"fast__search"}]`)
+	assert.NoError(t, err)
+	assert.True(t, rules[0].matches(map[string]interface{}{"region": "uk"}))
+	assert.False(t, rules[0].matches(map[string]interface{}{"region": "us"}))
+	assert.True(t, rules[1].matches(map[string]interface{}{"tier": 1.0}), "values compare as text")
+	assert.False(t, rules[1].matches(map[string]interface{}{}))
+
+	rules, err = ParseRouteRules(`[{"argument":"account","equals":"12345678","tool":"eu__search"},{"argument":"ratio","in":["0.5"],"tool":"eu__search"}]`)
+	assert.NoError(t, err)
+	assert.True(t, rules[0].matches(map[string]interface{}{"account": 12345678.0}), "large numbers are not in exponent notation")
+	assert.True(t, rules[1].matches(map[string]interface{}{"ratio": 0.5}))
+
+	_, err = ParseRouteRules(`[{"tool":"eu__search"}]`)
+	assert.Error(t, err, "argument is required")
+	_, err = ParseRouteRules(`[{"argument":"region","tool":"route__other"}]`)
+	assert.Error(t, err, "routes target upstream tools")
+}
+
+func TestRunRoute(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&model.UpstreamServer{}, &model.MaintenanceWindow{}, &model.UsageLog{}, &model.ToolRoute{}))
+	db.Create(&model.ToolRoute{Name: "search", DefaultTool: "us__search", Enabled: true,
+		Rules: `[{"argument":"region","equals":"eu","tool":"eu__search"}]`})
+
+	g := NewGateway(db, &config.Config{})
+	for id, name := range map[uint]string{1: "us", 2: "eu"} {
+		client := newResultClient(name, id, `{"content":[{"type":"text","text":"from `+name+`"}],"structuredContent":{"score":0.50}}`)
+		client.selector = &ToolSelector{}
+		g.upstreams[id] = client
+		g.upstreamIDs[name] = id
+	}
+	call := func(caller *Caller, args string) *JSONRPCMessage {
+		id := json.RawMessage("1")
+		resp, err := g.handleToolCall(&JSONRPCMessage{ID: &id, Params: json.RawMessage(`{"name":"route__search","arguments":` + args + `}`)}, caller, g.permissionCheck(caller))
+		assert.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Dispatches By Rule", func(t *testing.T) {
+		resp := call(&Caller{}, `{"region":"eu"}`)
+		assert.Contains(t, string(resp.Result), "from eu")
+		assert.Contains(t, string(resp.Result), `"one-mcp/routedTo":"eu__search"`)
+		resp = call(&Caller{}, `{"region":"us"}`)
+		assert.Contains(t, string(resp.Result), "from us")
+	})
+
+	t.Run("Signed After Routing", func(t *testing.T) {
+		resp := call(&Caller{SigningSecret: "s3cret"}, `{"region":"eu"}`)
+		assert.NoError(t, VerifyResultSignature(resp.Result, "s3cret"))
+		assert.Contains(t, string(resp.Result), `"tool":"route__search"`)
+		assert.Contains(t, string(resp.Result), `"score":0.50`)
+	})
+
+	t.Run("Permission Denied", func(t *testing.T) {
+		resp := call(&Caller{AllowedServers: []string{"1", "2"}}, `{"region":"eu"}`)
+		assert.Equal(t, -32000, resp.Error.Code)
+
+		// The caller also needs the target tool
+		resp = call(&Caller{AllowedServers: []string{RouteServer, "1"}}, `{"region":"eu"}`)
+		assert.NotNil(t, resp.Error)
+		assert.Contains(t, string(call(&Caller{AllowedServers: []string{RouteServer, "1"}}, `{}`).Result), "from us")
+	})
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ParseModelPreferences validates the SamplingModelPreferences of an upstream, a
+// JSON object in the form of the MCP modelPreferences, e.g.
+// {"hints":[{"name":"claude-3-5-haiku"}],"costPriority":0.8}. Empty is valid and
+// leaves the preferences of the upstream untouched.
+func ParseModelPreferences(raw string) (map[string]interface{}, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var prefs map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &prefs); err != nil {
+		return nil, fmt.Errorf("sampling_model_preferences must be a JSON object: %v", err)
+	}
+	for field, value := range prefs {
+		switch field {
+		case "hints":
+			hints, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("sampling_model_preferences: hints must be an array")
+			}
+			for _, hint := range hints {
+				h, _ := hint.(map[string]interface{})
+				if name, ok := h["name"].(string); !ok || name == "" {
+					return nil, fmt.Errorf("sampling_model_preferences: every hint needs a name")
+				}
+			}
+		case "costPriority", "speedPriority", "intelligencePriority":
+			if p, ok := value.(float64); !ok || p < 0 || p > 1 {
+				return nil, fmt.Errorf("sampling_model_preferences: %s must be a number from 0 to 1", field)
+			}
+		default:
+			return nil, fmt.Errorf("sampling_model_preferences: unknown field %q", field)
+		}
+	}
+	return prefs, nil
+}
+
+// applySamplingPolicy rewrites the params of a sampling/createMessage request of
+// the upstream before it reaches the client: the configured modelPreferences
+// replace the upstream's own, and maxTokens is capped at SamplingMaxTokens, so an
+// upstream cannot demand arbitrary models or lengths from the client.
+func (c *UpstreamClient) applySamplingPolicy(params json.RawMessage) (json.RawMessage, error) {
+	prefs, err := ParseModelPreferences(c.Config.SamplingModelPreferences)
+	if err != nil {
+		return nil, err
+	}
+	maxTokens := c.Config.SamplingMaxTokens
+	if prefs == nil && maxTokens <= 0 {
+		return params, nil
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(params, &decoded); err != nil || decoded == nil {
+		return nil, fmt.Errorf("invalid sampling request params")
+	}
+	if prefs != nil {
+		decoded["modelPreferences"] = prefs
+	}
+	if maxTokens > 0 {
+		requested, _ := decoded["maxTokens"].(float64)
+		if requested <= 0 || requested > float64(maxTokens) {
+			fmt.Printf("[Upstream %s] Capping sampling maxTokens %v at %d\n", c.Config.Name, decoded["maxTokens"], maxTokens)
+			decoded["maxTokens"] = maxTokens
+		}
+	}
+	return json.Marshal(decoded)
+}
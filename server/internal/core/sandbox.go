@@ -0,0 +1,83 @@
+package core
+
+import (
+	"fmt"
+	"one-mcp/internal/model"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// containerRuntimes are the commands whose "run" takes the options of a
+// sandbox profile directly.
+var containerRuntimes = map[string]bool{"docker": true, "podman": true}
+
+var appArmorNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.\-/]+$`)
+
+// ValidateSandboxProfile checks the settings of a sandbox profile.
+func ValidateSandboxProfile(p model.SandboxProfile) error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("Sandbox profile name is required")
+	}
+	if p.Seccomp != "" && (!filepath.IsAbs(p.Seccomp) || strings.ContainsAny(p.Seccomp, ",\n\r")) {
+		return fmt.Errorf("seccomp must be the absolute path of a seccomp profile")
+	}
+	if p.AppArmor != "" && (!appArmorNamePattern.MatchString(p.AppArmor) || p.AppArmor == "unconfined") {
+		return fmt.Errorf("apparmor must be the name of an AppArmor profile")
+	}
+	return nil
+}
+
+// SandboxCommand returns the command and arguments launching command with args
+// under the profile. The options of "docker run" and "podman run" are added
+// after "run". Other commands run through setpriv for NoNewPrivileges and aa-exec
+// for AppArmor, on Linux; they cannot take a seccomp profile or a read-only
+// filesystem. Profiles that cannot be applied are errors, never ignored.
+func SandboxCommand(p *model.SandboxProfile, command string, args []string) (string, []string, error) {
+	if containerRuntimes[filepath.Base(command)] {
+		run := -1
+		for i, arg := range args {
+			if arg == "run" {
+				run = i
+				break
+			}
+		}
+		if run < 0 {
+			return "", nil, fmt.Errorf("sandbox profiles apply to %s upstreams started with run", filepath.Base(command))
+		}
+		var options []string
+		if p.Seccomp != "" {
+			options = append(options, "--security-opt", "seccomp="+p.Seccomp)
+		}
+		if p.AppArmor != "" {
+			options = append(options, "--security-opt", "apparmor="+p.AppArmor)
+		}
+		if p.ReadOnly {
+			options = append(options, "--read-only")
+		}
+		if p.NoNewPrivileges {
+			options = append(options, "--security-opt", "no-new-privileges")
+		}
+		sandboxed := append(append(append([]string{}, args[:run+1]...), options...), args[run+1:]...)
+		return command, sandboxed, nil
+	}
+
+	if p.Seccomp != "" || p.ReadOnly {
+		return "", nil, fmt.Errorf("seccomp profiles and read-only filesystems apply to docker and podman upstreams only")
+	}
+	if (p.AppArmor != "" || p.NoNewPrivileges) && runtime.GOOS != "linux" {
+		return "", nil, fmt.Errorf("sandbox profiles require Linux")
+	}
+	var wrapper []string
+	if p.NoNewPrivileges {
+		wrapper = append(wrapper, "setpriv", "--no-new-privs")
+	}
+	if p.AppArmor != "" {
+		wrapper = append(wrapper, "aa-exec", "-p", p.AppArmor, "--")
+	}
+	if len(wrapper) == 0 {
+		return command, args, nil
+	}
+	return wrapper[0], append(append(wrapper[1:], command), args...), nil
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandboxCommand(t *testing.T) {
+	assert.Error(t, ValidateSandboxProfile(model.SandboxProfile{}))
+	assert.Error(t, ValidateSandboxProfile(model.SandboxProfile{Name: "a", Seccomp: "relative.json"}))
+	assert.Error(t, ValidateSandboxProfile(model.SandboxProfile{Name: "a", AppArmor: "unconfined"}))
+	assert.NoError(t, ValidateSandboxProfile(model.SandboxProfile{Name: "a", Seccomp: "/etc/one-mcp/seccomp.json", AppArmor: "one-mcp-tools"}))
+
+	strict := &model.SandboxProfile{Seccomp: "/etc/one-mcp/seccomp.json", AppArmor: "one-mcp-tools", ReadOnly: true, NoNewPrivileges: true}
+	command, args, err := SandboxCommand(strict, "/usr/bin/docker", []string{"run", "-i", "--rm", "mcp/fetch"})
+	require.NoError(t, err)
+	assert.Equal(t, "/usr/bin/docker", command)
+	assert.Equal(t, []string{
+		"run",
+		"--security-opt", "seccomp=/etc/one-mcp/seccomp.json",
+		"--security-opt", "apparmor=one-mcp-tools",
+		"--read-only",
+		"--security-opt", "no-new-privileges",
+		"-i", "--rm", "mcp/fetch",
+	}, args)
+
+	_, _, err = SandboxCommand(strict, "podman", []string{"pull", "mcp/fetch"})
+	assert.Error(t, err, "containers must be started with run")
+	_, _, err = SandboxCommand(strict, "npx", []string{"-y", "server"})
+	assert.Error(t, err, "seccomp does not apply to plain processes")
+
+	// A profile that cannot be loaded fails the launch
+	transport := NewStdioTransport(model.UpstreamServer{Name: "tools", Command: "true"}, &config.Config{})
+	transport.SetSandbox(func() (*model.SandboxProfile, error) { return nil, errors.New("sandbox profile 7: record not found") })
+	assert.EqualError(t, transport.Start(context.Background(), func([]byte) {}, nil), "sandbox profile 7: record not found")
+	assert.Nil(t, transport.cmd)
+
+	command, args, err = SandboxCommand(&model.SandboxProfile{AppArmor: "one-mcp-tools", NoNewPrivileges: true}, "npx", []string{"-y", "server"})
+	if runtime.GOOS != "linux" {
+		assert.Error(t, err)
+		return
+	}
+	require.NoError(t, err)
+	assert.Equal(t, "setpriv", command)
+	assert.Equal(t, []string{"--no-new-privs", "aa-exec", "-p", "one-mcp-tools", "--", "npx", "-y", "server"}, args)
+
+	command, args, _ = SandboxCommand(&model.SandboxProfile{}, "npx", []string{"server"})
+	assert.Equal(t, "npx", command)
+	assert.Equal(t, []string{"server"}, args)
+}
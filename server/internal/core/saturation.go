@@ -0,0 +1,42 @@
+package core
+
+import "sync/atomic"
+
+// UpstreamSaturation tells a busy upstream from a failing one: the requests
+// it is working on and the tool calls waiting for it now, and how often
+// requests timed out and the connection was lost since the upstream was
+// (re)configured. The counters restart from zero when upstreams are reloaded.
+type UpstreamSaturation struct {
+	InFlight      int64 `json:"in_flight"`      // Requests sent and awaiting a response
+	Queued        int   `json:"queued"`         // Tool calls waiting for a slot under max_concurrency
+	Limit         int   `json:"limit"`          // max_concurrency, 0 if unlimited
+	Timeouts      int64 `json:"timeouts"`       // Requests unanswered within their timeout
+	QueueTimeouts int64 `json:"queue_timeouts"` // Tool calls that waited for a slot in vain
+	Reconnects    int64 `json:"reconnects"`     // Connection attempts after the first
+}
+
+// loadCounters are the counters behind UpstreamSaturation, updated atomically.
+type loadCounters struct {
+	inflight int64
+	timeouts int64
+	attempts int64
+}
+
+// Saturation returns the current load of the upstream.
+func (c *UpstreamClient) Saturation() UpstreamSaturation {
+	s := UpstreamSaturation{
+		InFlight: atomic.LoadInt64(&c.load.inflight),
+		Timeouts: atomic.LoadInt64(&c.load.timeouts),
+	}
+	if attempts := atomic.LoadInt64(&c.load.attempts); attempts > 1 {
+		s.Reconnects = attempts - 1
+	}
+	if c.sched != nil {
+		s.Limit = c.sched.limit
+		for _, st := range c.sched.Stats() {
+			s.Queued += st.Queued
+			s.QueueTimeouts += st.Timeouts
+		}
+	}
+	return s
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"encoding/json"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpstreamSaturation(t *testing.T) {
+	transport := &recordingTransport{sent: make(chan []byte, 4)}
+	upstream := &UpstreamClient{
+		Config:      model.UpstreamServer{Name: "busy", MaxConcurrency: 1},
+		settings:    &config.Config{UpstreamTimeout: time.Minute},
+		transport:   transport,
+		ready:       true,
+		pendingReqs: make(map[string]chan JSONRPCMessage),
+		sched:       NewFairScheduler(1),
+	}
+
+	// One call holds the only slot while another waits for it
+	result := make(chan error, 2)
+	go func() {
+		_, err := upstream.CallAsTimeout("a", "tools/call", nil, 100*time.Millisecond)
+		result <- err
+	}()
+	var call JSONRPCMessage
+	json.Unmarshal(<-transport.sent, &call)
+	go func() {
+		_, err := upstream.CallAsTimeout("b", "tools/call", nil, time.Minute)
+		result <- err
+	}()
+	assert.Eventually(t, func() bool { return upstream.Saturation().Queued == 1 }, time.Second, 5*time.Millisecond)
+	s := upstream.Saturation()
+	assert.Equal(t, int64(1), s.InFlight)
+	assert.Equal(t, 1, s.Limit)
+
+	// The first call times out, letting the second one through
+	assert.Error(t, <-result)
+	json.Unmarshal(<-transport.sent, &call)
+	s = upstream.Saturation()
+	assert.Equal(t, int64(1), s.Timeouts)
+	assert.Equal(t, 0, s.Queued)
+	assert.Equal(t, int64(1), s.InFlight)
+
+	upstream.failPending()
+	assert.Error(t, <-result)
+	assert.Equal(t, int64(0), upstream.Saturation().InFlight)
+
+	upstream.load.attempts = 3
+	assert.Equal(t, int64(2), upstream.Saturation().Reconnects)
+}
@@ -0,0 +1,188 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StarvationThreshold is the queue wait after which a call is counted as starved.
+const StarvationThreshold = 5 * time.Second
+
+// FairScheduler bounds the number of concurrent calls to an upstream and hands out
+// free slots round-robin across keys, so one busy key cannot starve the others.
+type FairScheduler struct {
+	limit  int
+	mu     sync.Mutex
+	active int
+	queues map[string][]*schedWaiter // per-key FIFO of waiting calls
+	ring   []string                  // keys in round-robin order
+	next   int
+	stats  map[string]*SchedulerKeyStats
+}
+
+type schedWaiter struct {
+	ready    chan struct{}
+	enqueued time.Time
+	granted  bool
+}
+
+// SchedulerKeyStats are the per-key queueing metrics exposed by the stats API.
+type SchedulerKeyStats struct {
+	Queued     int     `json:"queued"`
+	Dispatched int64   `json:"dispatched"`
+	Starved    int64   `json:"starved"`
+	Timeouts   int64   `json:"timeouts"`
+	AvgWaitMs  float64 `json:"avg_wait_ms"`
+	MaxWaitMs  int64   `json:"max_wait_ms"`
+	totalWait  time.Duration
+}
+
+func NewFairScheduler(limit int) *FairScheduler {
+	return &FairScheduler{
+		limit:  limit,
+		queues: make(map[string][]*schedWaiter),
+		stats:  make(map[string]*SchedulerKeyStats),
+	}
+}
+
+// Acquire blocks until a slot is free for key, timeout elapses or cancel is
+// closed, in which case it returns errCancelled. On success the caller must call
+// Release exactly once.
+func (s *FairScheduler) Acquire(key string, timeout time.Duration, cancel <-chan struct{}) error {
+	s.mu.Lock()
+	st := s.keyStats(key)
+	if s.active < s.limit && s.waiting() == 0 {
+		s.active++
+		st.Dispatched++
+		s.mu.Unlock()
+		return nil
+	}
+
+	w := &schedWaiter{ready: make(chan struct{}), enqueued: time.Now()}
+	if len(s.queues[key]) == 0 {
+		s.ring = append(s.ring, key)
+	}
+	s.queues[key] = append(s.queues[key], w)
+	st.Queued++
+	s.mu.Unlock()
+
+	timedOut := false
+	select {
+	case <-w.ready:
+		return nil
+	case <-time.After(timeout):
+		timedOut = true
+	case <-cancel:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w.granted {
+		// Lost the race with dispatch: the slot is ours after all.
+		return nil
+	}
+	s.removeWaiter(key, w)
+	st.Queued--
+	if !timedOut {
+		return errCancelled
+	}
+	st.Timeouts++
+	return fmt.Errorf("timeout waiting for upstream slot")
+}
+
+// Release frees a slot and dispatches the next waiting call in round-robin order.
+func (s *FairScheduler) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+
+	for len(s.ring) > 0 && s.active < s.limit {
+		if s.next >= len(s.ring) {
+			s.next = 0
+		}
+		key := s.ring[s.next]
+		queue := s.queues[key]
+		w := queue[0]
+		s.queues[key] = queue[1:]
+
+		if len(s.queues[key]) == 0 {
+			delete(s.queues, key)
+			s.ring = append(s.ring[:s.next], s.ring[s.next+1:]...)
+		} else {
+			s.next++
+		}
+
+		wait := time.Since(w.enqueued)
+		st := s.keyStats(key)
+		st.Queued--
+		st.Dispatched++
+		st.totalWait += wait
+		if ms := wait.Milliseconds(); ms > st.MaxWaitMs {
+			st.MaxWaitMs = ms
+		}
+		if wait >= StarvationThreshold {
+			st.Starved++
+		}
+
+		s.active++
+		w.granted = true
+		close(w.ready)
+	}
+}
+
+// Stats returns a snapshot of per-key queueing metrics.
+func (s *FairScheduler) Stats() map[string]SchedulerKeyStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]SchedulerKeyStats, len(s.stats))
+	for key, st := range s.stats {
+		snap := *st
+		if snap.Dispatched > 0 {
+			snap.AvgWaitMs = float64(snap.totalWait.Milliseconds()) / float64(snap.Dispatched)
+		}
+		out[key] = snap
+	}
+	return out
+}
+
+func (s *FairScheduler) keyStats(key string) *SchedulerKeyStats {
+	st, ok := s.stats[key]
+	if !ok {
+		st = &SchedulerKeyStats{}
+		s.stats[key] = st
+	}
+	return st
+}
+
+func (s *FairScheduler) waiting() int {
+	n := 0
+	for _, q := range s.queues {
+		n += len(q)
+	}
+	return n
+}
+
+func (s *FairScheduler) removeWaiter(key string, w *schedWaiter) {
+	queue := s.queues[key]
+	for i, qw := range queue {
+		if qw == w {
+			s.queues[key] = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(s.queues[key]) > 0 {
+		return
+	}
+	delete(s.queues, key)
+	for i, k := range s.ring {
+		if k == key {
+			s.ring = append(s.ring[:i], s.ring[i+1:]...)
+			if s.next > i {
+				s.next--
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFairScheduler(t *testing.T) {
+	t.Run("Round Robin Across Keys", func(t *testing.T) {
+		s := NewFairScheduler(1)
+		assert.NoError(t, s.Acquire("busy", time.Second, nil))
+
+		// "busy" queues three calls before "quiet" queues one.
+		order := make(chan string, 4)
+		enqueue := func(key string) {
+			go func() {
+				if s.Acquire(key, 5*time.Second, nil) == nil {
+					order <- key
+				}
+			}()
+			time.Sleep(20 * time.Millisecond)
+		}
+		enqueue("busy")
+		enqueue("busy")
+		enqueue("busy")
+		enqueue("quiet")
+
+		var got []string
+		for i := 0; i < 4; i++ {
+			s.Release()
+			got = append(got, <-order)
+		}
+		// The quiet key is served second, not after the whole busy backlog.
+		assert.Equal(t, []string{"busy", "quiet", "busy", "busy"}, got)
+		s.Release()
+
+		stats := s.Stats()
+		assert.Equal(t, int64(4), stats["busy"].Dispatched)
+		assert.Equal(t, int64(1), stats["quiet"].Dispatched)
+		assert.Equal(t, 0, stats["busy"].Queued)
+	})
+
+	t.Run("Timeout Removes Waiter", func(t *testing.T) {
+		s := NewFairScheduler(1)
+		assert.NoError(t, s.Acquire("a", time.Second, nil))
+		assert.Error(t, s.Acquire("b", 20*time.Millisecond, nil))
+
+		stats := s.Stats()
+		assert.Equal(t, int64(1), stats["b"].Timeouts)
+		assert.Equal(t, 0, stats["b"].Queued)
+
+		// The slot is handed back without anyone waiting.
+		s.Release()
+		assert.NoError(t, s.Acquire("b", 20*time.Millisecond, nil))
+	})
+	t.Run("Cancel Removes Waiter", func(t *testing.T) {
+		s := NewFairScheduler(1)
+		assert.NoError(t, s.Acquire("a", time.Second, nil))
+
+		cancel := make(chan struct{})
+		time.AfterFunc(20*time.Millisecond, func() { close(cancel) })
+		started := time.Now()
+		assert.Equal(t, errCancelled, s.Acquire("b", 5*time.Second, cancel))
+		assert.Less(t, time.Since(started), time.Second)
+
+		stats := s.Stats()
+		assert.Equal(t, int64(0), stats["b"].Timeouts, "cancellations are not timeouts")
+		assert.Equal(t, 0, stats["b"].Queued)
+	})
+}
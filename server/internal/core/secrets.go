@@ -0,0 +1,113 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"one-mcp/internal/model"
+	"strings"
+	"text/template"
+
+	"gorm.io/gorm"
+)
+
+// SecretStore resolves gateway-stored secrets by name.
+type SecretStore struct {
+	db *gorm.DB
+}
+
+func NewSecretStore(db *gorm.DB) *SecretStore {
+	return &SecretStore{db: db}
+}
+
+// Get returns the value of the named secret.
+func (s *SecretStore) Get(name string) (string, error) {
+	if s == nil {
+		return "", fmt.Errorf("secret %q: no secret store configured", name)
+	}
+	var secret model.Secret
+	if err := s.db.Where("name = ?", name).First(&secret).Error; err != nil {
+		return "", fmt.Errorf("secret %q not found", name)
+	}
+	return secret.Value, nil
+}
+
+// toolTemplate renders the templated parts of an HTTP tool request. Templates use Go
+// template syntax with these functions:
+//
+//	{{secret "stripe_key"}}  value of a gateway-stored secret
+//	{{arg "query"}}          value of a tool argument
+//	{{json (arg "query")}}   JSON encoding of a value, for request bodies
+//
+// Templates come only from the tool configuration; argument values are inserted as
+// data and never evaluated, so the model cannot reference secrets. In URLs they are
+// also escaped, so they cannot add path segments or query parameters.
+type toolTemplate struct {
+	secrets *SecretStore
+	args    map[string]interface{}
+	used    []string            // Resolved secret values, redacted from responses
+	escape  func(string) string // Applied to argument values, nil outside URLs
+}
+
+// renderURL renders a URL template, escaping argument values for use in a path
+// segment or query value.
+func (t *toolTemplate) renderURL(text string) (string, error) {
+	t.escape = escapeURLArg
+	defer func() { t.escape = nil }()
+	return t.render(text)
+}
+
+// escapeURLArg escapes s for a path segment or query value alike: spaces become
+// %20 rather than the + that only queries understand.
+func escapeURLArg(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func (t *toolTemplate) render(text string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New("tool").Option("missingkey=zero").Funcs(template.FuncMap{
+		"secret": func(name string) (string, error) {
+			value, err := t.secrets.Get(name)
+			if err != nil {
+				return "", err
+			}
+			t.used = append(t.used, value)
+			return value, nil
+		},
+		"arg": func(name string) interface{} {
+			value := t.args[name]
+			if t.escape == nil {
+				return value
+			}
+			if value == nil {
+				return ""
+			}
+			return t.escape(fmt.Sprint(value))
+		},
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// redact replaces resolved secret values in s.
+func (t *toolTemplate) redact(s string) string {
+	for _, value := range t.used {
+		if len(value) >= 4 {
+			s = strings.ReplaceAll(s, value, "[REDACTED]")
+		}
+	}
+	return s
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"one-mcp/internal/model"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestToolTemplate(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&model.Secret{}))
+	db.Create(&model.Secret{Name: "api_key", Value: "sk-live-123"})
+
+	t.Run("Resolves Secrets And Arguments", func(t *testing.T) {
+		tmpl := &toolTemplate{secrets: NewSecretStore(db), args: map[string]interface{}{"q": `say "hi"`}}
+		out, err := tmpl.render(`{"key": "{{secret "api_key"}}", "q": {{json (arg "q")}}}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"key": "sk-live-123", "q": "say \"hi\""}`, out)
+		assert.Equal(t, "echo [REDACTED]", tmpl.redact("echo sk-live-123"))
+	})
+
+	t.Run("Arguments Are Not Evaluated", func(t *testing.T) {
+		tmpl := &toolTemplate{secrets: NewSecretStore(db), args: map[string]interface{}{"q": `{{secret "api_key"}}`}}
+		out, err := tmpl.render(`{{arg "q"}}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{{secret "api_key"}}`, out)
+		assert.Empty(t, tmpl.used)
+	})
+
+	t.Run("Arguments Are Escaped In URLs", func(t *testing.T) {
+		tmpl := &toolTemplate{secrets: NewSecretStore(db), args: map[string]interface{}{"user": "../admin?role=x&y=1#", "q": "a b", "n": 3.0}}
+		out, err := tmpl.renderURL(`https://api.example.com/users/{{arg "user"}}?q={{arg "q"}}&n={{arg "n"}}&m={{arg "missing"}}&key={{secret "api_key"}}`)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://api.example.com/users/..%2Fadmin%3Frole%3Dx%26y%3D1%23?q=a%20b&n=3&m=&key=sk-live-123", out)
+
+		out, err = tmpl.render(`{{arg "q"}}`)
+		assert.NoError(t, err)
+		assert.Equal(t, "a b", out, "bodies and headers are not escaped")
+	})
+
+	t.Run("Unknown Secret", func(t *testing.T) {
+		tmpl := &toolTemplate{secrets: NewSecretStore(db)}
+		_, err := tmpl.render(`{{secret "missing"}}`)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+)
+
+// ToolSelector decides which of an upstream's tools are exposed by the gateway,
+// based on include/exclude glob patterns and a maximum tool count.
+type ToolSelector struct {
+	Include []string // If non-empty, only matching tools are exposed
+	Exclude []string // Matching tools are never exposed
+	Max     int      // Maximum number of exposed tools (0 = unlimited)
+}
+
+// ParseToolSelector builds a selector from the JSON pattern arrays stored on an upstream.
+func ParseToolSelector(include, exclude string, max int) (*ToolSelector, error) {
+	s := &ToolSelector{Max: max}
+	if include != "" {
+		if err := json.Unmarshal([]byte(include), &s.Include); err != nil {
+			return nil, fmt.Errorf("invalid include_tools: %v", err)
+		}
+	}
+	if exclude != "" {
+		if err := json.Unmarshal([]byte(exclude), &s.Exclude); err != nil {
+			return nil, fmt.Errorf("invalid exclude_tools: %v", err)
+		}
+	}
+	for _, p := range append(append([]string{}, s.Include...), s.Exclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid tool pattern %q: %v", p, err)
+		}
+	}
+	if max < 0 {
+		return nil, fmt.Errorf("max_tools must not be negative")
+	}
+	return s, nil
+}
+
+// Matches reports whether the (unprefixed) tool name passes the include/exclude patterns.
+func (s *ToolSelector) Matches(name string) bool {
+	for _, p := range s.Exclude {
+		if ok, _ := path.Match(p, name); ok {
+			return false
+		}
+	}
+	if len(s.Include) == 0 {
+		return true
+	}
+	for _, p := range s.Include {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
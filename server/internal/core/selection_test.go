@@ -0,0 +1,67 @@
+package core
+
+import (
+	"encoding/json"
+	"one-mcp/internal/model"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestParseToolSelector(t *testing.T) {
+	s, err := ParseToolSelector(`["get_*", "search"]`, `["get_secret*"]`, 0)
+	require.NoError(t, err)
+	assert.True(t, s.Matches("get_issue"))
+	assert.True(t, s.Matches("search"))
+	assert.False(t, s.Matches("get_secret_value"), "exclusion wins")
+	assert.False(t, s.Matches("delete_repo"), "not included")
+
+	s, err = ParseToolSelector("", `["delete_*"]`, 0)
+	require.NoError(t, err)
+	assert.True(t, s.Matches("get_issue"), "everything is included by default")
+	assert.False(t, s.Matches("delete_repo"))
+
+	_, err = ParseToolSelector(`"get_*"`, "", 0)
+	assert.ErrorContains(t, err, "include_tools")
+	_, err = ParseToolSelector("", `["[a-"]`, 0)
+	assert.ErrorContains(t, err, "invalid tool pattern")
+	_, err = ParseToolSelector("", "", -1)
+	assert.Error(t, err)
+}
+
+func TestSelectTools(t *testing.T) {
+	client := newResultClient("github", 1, `{"content":[]}`)
+	client.selector = &ToolSelector{Exclude: []string{"delete_*"}, Max: 2}
+	tools := []map[string]interface{}{
+		{"name": "get_issue"}, {"name": "delete_repo"}, {"name": "search"}, {"name": "create_issue"},
+	}
+
+	selected := client.selectTools(tools)
+	require.Len(t, selected, 2)
+	assert.Equal(t, "get_issue", selected[0]["name"])
+	assert.Equal(t, "search", selected[1]["name"])
+	assert.Equal(t, 2, client.FilteredCount())
+	assert.True(t, client.ToolSelected("search"))
+	assert.False(t, client.ToolSelected("delete_repo"), "excluded")
+	assert.False(t, client.ToolSelected("create_issue"), "over the cap")
+
+	// Hidden tools cannot be called either
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.UsageLog{}))
+	g := &Gateway{db: db, settings: client.settings,
+		upstreams: map[uint]*UpstreamClient{1: client}, upstreamIDs: map[string]uint{"github": 1}}
+	call := func(name string) *JSONRPCMessage {
+		params, _ := json.Marshal(map[string]interface{}{"name": name, "arguments": map[string]interface{}{}})
+		resp, err := g.HandleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":`+string(params)+`}`), &Caller{})
+		require.NoError(t, err)
+		return resp
+	}
+	assert.Nil(t, call("github__search").Error)
+	resp := call("github__create_issue")
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, "Tool not found", resp.Error.Message)
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// toolsChangedDelay coalesces tools/list_changed broadcasts, e.g. while several
+// upstreams reconnect after a reload.
+const toolsChangedDelay = 500 * time.Millisecond
+
+// AddSession registers a live downstream session so it receives gateway
+// notifications.
+func (g *Gateway) AddSession(caller *Caller) {
+	g.sessionMu.Lock()
+	defer g.sessionMu.Unlock()
+	if g.sessions == nil {
+		g.sessions = make(map[string]*Caller)
+	}
+	g.sessions[caller.SessionID] = caller
+}
+
+// DropSession forgets a downstream session that ended, including its resource
+// subscriptions.
+func (g *Gateway) DropSession(sessionID string) {
+	g.sessionMu.Lock()
+	delete(g.sessions, sessionID)
+	delete(g.logLevels, sessionID)
+	g.sessionMu.Unlock()
+
+	g.dropSubscriptions(sessionID)
+	g.cancelClientRequests(sessionID)
+}
+
+// upstreamInitialized runs each time an upstream completes initialize.
+func (g *Gateway) upstreamInitialized(c *UpstreamClient) {
+	g.resubscribeResources(c)
+	g.applyLogLevel(c)
+	g.notifyToolsChanged()
+}
+
+// notifyToolsChanged schedules a notifications/tools/list_changed broadcast to
+// all live sessions. Sessions pinned to a catalog version are skipped, as their
+// tool list does not change.
+func (g *Gateway) notifyToolsChanged() {
+	g.sessionMu.Lock()
+	defer g.sessionMu.Unlock()
+	if g.toolsChangedTimer != nil {
+		return
+	}
+	g.toolsChangedTimer = time.AfterFunc(toolsChangedDelay, func() {
+		g.sessionMu.Lock()
+		g.toolsChangedTimer = nil
+		var targets []*Caller
+		for _, caller := range g.sessions {
+			if caller.CatalogVersion == 0 && caller.Notify != nil {
+				targets = append(targets, caller)
+			}
+		}
+		g.sessionMu.Unlock()
+
+		payload, _ := json.Marshal(&JSONRPCMessage{JSONRPC: "2.0", Method: "notifications/tools/list_changed"})
+		for _, caller := range targets {
+			caller.Deliver(payload)
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolsChangedBroadcast(t *testing.T) {
+	received := make(chan []byte, 4)
+	notify := func(msg []byte) bool {
+		received <- msg
+		return true
+	}
+
+	g := &Gateway{}
+	g.AddSession(&Caller{SessionID: "live", Notify: notify})
+	g.AddSession(&Caller{SessionID: "pinned", CatalogVersion: 3, Notify: notify})
+	g.AddSession(&Caller{SessionID: "gone", Notify: notify})
+	g.DropSession("gone")
+
+	// Bursts are coalesced into one notification per session
+	g.notifyToolsChanged()
+	g.notifyToolsChanged()
+
+	select {
+	case msg := <-received:
+		assert.JSONEq(t, `{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`, string(msg))
+	case <-time.After(2 * time.Second):
+		t.Fatal("notification not sent")
+	}
+	select {
+	case <-received:
+		t.Fatal("expected a single notification")
+	case <-time.After(toolsChangedDelay + 100*time.Millisecond):
+	}
+}
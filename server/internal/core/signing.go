@@ -0,0 +1,135 @@
+package core
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const signatureMetaKey = "one-mcp/signature"
+
+// signResult adds an HMAC-SHA256 signature of the result to its _meta field.
+//
+// The signed message is "<ts>.<tool>.<canonical result>", where the canonical result is
+// the result object without the signature entry, serialized with sorted keys, no
+// insignificant whitespace and no HTML escaping. The result is emitted in that same
+// form, numbers verbatim, so it is exactly what was signed plus the signature.
+func signResult(result json.RawMessage, secret string, tool string) json.RawMessage {
+	obj, err := decodeObject(result)
+	if err != nil {
+		return result
+	}
+
+	ts := time.Now().Unix()
+	sig, err := computeSignature(obj, secret, tool, ts)
+	if err != nil {
+		return result
+	}
+
+	meta, _ := obj["_meta"].(map[string]interface{})
+	if meta == nil {
+		meta = make(map[string]interface{})
+	}
+	meta[signatureMetaKey] = map[string]interface{}{
+		"alg":  "HMAC-SHA256",
+		"tool": tool,
+		"ts":   ts,
+		"sig":  sig,
+	}
+	obj["_meta"] = meta
+	signed, err := encodeCanonical(obj)
+	if err != nil {
+		return result
+	}
+	return signed
+}
+
+// VerifyResultSignature checks a signed tools/call result against secret.
+func VerifyResultSignature(result json.RawMessage, secret string) error {
+	obj, err := decodeObject(result)
+	if err != nil {
+		return err
+	}
+
+	meta, _ := obj["_meta"].(map[string]interface{})
+	sigInfo, _ := meta[signatureMetaKey].(map[string]interface{})
+	if sigInfo == nil {
+		return fmt.Errorf("result is not signed")
+	}
+	tool, _ := sigInfo["tool"].(string)
+	sig, _ := sigInfo["sig"].(string)
+	tsNum, _ := sigInfo["ts"].(json.Number)
+	ts, err := strconv.ParseInt(string(tsNum), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp")
+	}
+
+	expected, err := computeSignature(obj, secret, tool, ts)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+func computeSignature(obj map[string]interface{}, secret string, tool string, ts int64) (string, error) {
+	// Sign a copy without the signature entry itself
+	unsigned := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		unsigned[k] = v
+	}
+	if meta, ok := obj["_meta"].(map[string]interface{}); ok {
+		stripped := make(map[string]interface{}, len(meta))
+		for k, v := range meta {
+			if k != signatureMetaKey {
+				stripped[k] = v
+			}
+		}
+		if len(stripped) > 0 {
+			unsigned["_meta"] = stripped
+		} else {
+			delete(unsigned, "_meta")
+		}
+	}
+
+	canonical, err := encodeCanonical(unsigned)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s.", ts, tool)
+	mac.Write(canonical)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// encodeCanonical serializes a decoded result with sorted keys, no insignificant
+// whitespace and no HTML escaping.
+func encodeCanonical(obj map[string]interface{}) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(obj); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// decodeObject parses a JSON object keeping numbers verbatim, so re-encoding
+// for signing does not alter their representation.
+func decodeObject(raw json.RawMessage) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestResultSigning(t *testing.T) {
+	result := json.RawMessage(`{"content":[{"type":"text","text":"<b>42</b> & 1.50"}],"_meta":{"other":1}}`)
+
+	signed := signResult(result, "s3cret", "srv__tool")
+	assert.NoError(t, VerifyResultSignature(signed, "s3cret"))
+	assert.Error(t, VerifyResultSignature(signed, "wrong"))
+	assert.Error(t, VerifyResultSignature(result, "s3cret"))
+
+	// Tampering with the content invalidates the signature
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(signed, &obj))
+	obj["content"] = []interface{}{map[string]interface{}{"type": "text", "text": "43"}}
+	tampered, _ := json.Marshal(obj)
+	assert.Error(t, VerifyResultSignature(tampered, "s3cret"))
+}
+
+// resultTransport answers every request with a fixed result, or rpcErr if set.
+type resultTransport struct {
+	client *UpstreamClient
+	result json.RawMessage
+	rpcErr *JSONRPCError
+	hang   bool // Never answer
+}
+
+func (t *resultTransport) Start(ctx context.Context, onMessage func([]byte), onReady func()) error {
+	return nil
+}
+
+func (t *resultTransport) Send(payload []byte) error {
+	var req JSONRPCMessage
+	json.Unmarshal(payload, &req)
+	if req.ID != nil && !t.hang {
+		msg := JSONRPCMessage{JSONRPC: "2.0", ID: req.ID, Result: t.result}
+		if t.rpcErr != nil {
+			msg.Result, msg.Error = nil, t.rpcErr
+		}
+		resp, _ := json.Marshal(msg)
+		go t.client.handleMessage(resp)
+	}
+	return nil
+}
+
+func (t *resultTransport) Close() error { return nil }
+
+func newResultClient(name string, id uint, result string) *UpstreamClient {
+	transport := &resultTransport{result: json.RawMessage(result)}
+	client := &UpstreamClient{
+		Config:      model.UpstreamServer{ID: id, Name: name},
+		settings:    &config.Config{UpstreamTimeout: time.Second},
+		transport:   transport,
+		pendingReqs: make(map[string]chan JSONRPCMessage),
+		ready:       true,
+	}
+	transport.client = client
+	return client
+}
+
+func TestSignedNumericResult(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.UsageLog{})
+	g := &Gateway{db: db, settings: &config.Config{}}
+	client := newResultClient("shop", 1, `{"content":[],"structuredContent":{"price":1.50,"big":12345678901234567890,"exp":1e3}}`)
+	allowAll := func(string, string) bool { return true }
+
+	resp := g.callUpstreamTool(&JSONRPCMessage{}, &Caller{SigningSecret: "s3cret"}, allowAll, client, "quote", "shop__quote", nil, time.Second)
+	assert.Nil(t, resp.Error)
+	assert.NoError(t, VerifyResultSignature(resp.Result, "s3cret"))
+	assert.Contains(t, string(resp.Result), `"price":1.50`)
+	assert.Contains(t, string(resp.Result), `"big":12345678901234567890`)
+
+	// Annotating after signing keeps numbers verbatim
+	annotated := annotateResult(resp.Result, "one-mcp/test", "x")
+	assert.Contains(t, string(annotated), `"price":1.50`)
+}
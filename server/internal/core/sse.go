@@ -0,0 +1,118 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// SSEEvent is an event of a text/event-stream.
+type SSEEvent struct {
+	Event string // Event type, "message" when the stream names none
+	Data  string // Data lines joined with "\n"
+	ID    string // Last event ID seen on the stream so far
+	Retry int    // Reconnection time in milliseconds requested by the server, 0 if none
+}
+
+// SSEReader reads events from a text/event-stream as specified by the HTML
+// standard: lines end with CRLF, LF or CR; fields are "name:value" with one
+// optional space after the colon; data fields accumulate; lines starting with
+// a colon are comments; a blank line dispatches the event.
+type SSEReader struct {
+	r       *bufio.Reader
+	maxSize int
+	skipLF  bool // The previous line ended with CR, so a leading LF belongs to it
+	lastID  string
+}
+
+// NewSSEReader returns a reader of the stream r. Events with more than maxSize
+// bytes of data, and lines longer than that, are errors.
+func NewSSEReader(r io.Reader, maxSize int) *SSEReader {
+	return &SSEReader{r: bufio.NewReader(r), maxSize: maxSize}
+}
+
+// Next returns the next event. At the end of the stream it returns io.EOF; an
+// incomplete event at the end is discarded, as the standard requires.
+func (s *SSEReader) Next() (*SSEEvent, error) {
+	var event string
+	var data strings.Builder
+	hasData := false
+	retry := 0
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if line == "" {
+			if !hasData {
+				// Nothing to dispatch; the event type does not carry over
+				event, retry = "", 0
+				continue
+			}
+			if event == "" {
+				event = "message"
+			}
+			return &SSEEvent{Event: event, Data: data.String(), ID: s.lastID, Retry: retry}, nil
+		}
+		if line[0] == ':' {
+			continue
+		}
+
+		name, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch name {
+		case "event":
+			event = value
+		case "data":
+			if data.Len()+len(value)+1 > s.maxSize {
+				return nil, fmt.Errorf("event data exceeds MAX_MESSAGE_SIZE")
+			}
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				s.lastID = value
+			}
+		case "retry":
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+				retry = n
+			}
+		}
+	}
+}
+
+// readLine returns the next line without its terminator.
+func (s *SSEReader) readLine() (string, error) {
+	var line []byte
+	for {
+		b, err := s.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if s.skipLF {
+			s.skipLF = false
+			if b == '\n' {
+				continue
+			}
+		}
+		switch b {
+		case '\r':
+			// Not peeking at the next byte keeps a CR-terminated line from
+			// waiting for more input
+			s.skipLF = true
+			return string(line), nil
+		case '\n':
+			return string(line), nil
+		}
+		if len(line) >= s.maxSize {
+			return "", fmt.Errorf("line exceeds MAX_MESSAGE_SIZE")
+		}
+		line = append(line, b)
+	}
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSEReader(t *testing.T) {
+	readAll := func(stream string) ([]SSEEvent, error) {
+		r := NewSSEReader(strings.NewReader(stream), 1024)
+		var events []SSEEvent
+		for {
+			ev, err := r.Next()
+			if err == io.EOF {
+				return events, nil
+			}
+			if err != nil {
+				return events, err
+			}
+			events = append(events, *ev)
+		}
+	}
+
+	t.Run("Line Endings", func(t *testing.T) {
+		for _, nl := range []string{"\n", "\r\n", "\r"} {
+			stream := "event: endpoint" + nl + "data: /messages" + nl + nl + "data: {}" + nl + nl
+			events, err := readAll(stream)
+			assert.NoError(t, err)
+			assert.Equal(t, []SSEEvent{{Event: "endpoint", Data: "/messages"}, {Event: "message", Data: "{}"}}, events, "%q", nl)
+		}
+	})
+
+	t.Run("Fields", func(t *testing.T) {
+		stream := ": keep-alive\n" +
+			"event:endpoint\ndata:/messages?session=1\n\n" +
+			"id: 7\ndata: {\"a\":\ndata:  1}\nretry: 3000\n\n" +
+			"data\n\n" +
+			"event: ignored\n\n" +
+			"data: incomplete"
+		events, err := readAll(stream)
+		assert.NoError(t, err)
+		assert.Equal(t, []SSEEvent{
+			{Event: "endpoint", Data: "/messages?session=1"},
+			{Event: "message", Data: "{\"a\":\n 1}", ID: "7", Retry: 3000},
+			{Event: "message", Data: "", ID: "7"},
+		}, events)
+	})
+
+	t.Run("Size Limit", func(t *testing.T) {
+		_, err := readAll("data: " + strings.Repeat("x", 600) + "\ndata: " + strings.Repeat("x", 600) + "\n\n")
+		assert.ErrorContains(t, err, "exceeds MAX_MESSAGE_SIZE")
+		_, err = readAll(strings.Repeat("x", 2048) + "\n")
+		assert.ErrorContains(t, err, "exceeds MAX_MESSAGE_SIZE")
+	})
+}
+
+func TestSSETransportFrames(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event:endpoint\r\ndata:/messages\r\n\r\n")
+		fmt.Fprint(w, "event: message\r\ndata: {\"jsonrpc\":\"2.0\",\r\ndata: \"id\":1}\r\n\r\n")
+	}))
+	defer srv.Close()
+
+	tr := NewSSETransport(model.UpstreamServer{Name: "sse", URL: srv.URL + "/sse"}, &config.Config{MaxMessageSize: 1024})
+	var msgs []string
+	ready := make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := tr.Start(ctx, func(msg []byte) { msgs = append(msgs, string(msg)) }, func() { close(ready) })
+	assert.NoError(t, err)
+	<-ready
+	assert.Equal(t, srv.URL+"/messages", tr.Endpoint)
+	assert.Equal(t, []string{"{\"jsonrpc\":\"2.0\",\n\"id\":1}"}, msgs)
+}
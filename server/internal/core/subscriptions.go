@@ -0,0 +1,203 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/report"
+)
+
+// resourceSubscription tracks the downstream sessions subscribed to a resource
+// URI. The gateway holds a single upstream subscription per URI.
+type resourceSubscription struct {
+	server   string             // Owning upstream
+	sessions map[string]*Caller // Subscribed callers by session ID
+}
+
+func (g *Gateway) handleResourceSubscribe(req *JSONRPCMessage, caller *Caller) (*JSONRPCMessage, error) {
+	uri, errResp := subscriptionRequest(req, caller)
+	if errResp != nil {
+		return errResp, nil
+	}
+
+	g.subMu.Lock()
+	if sub, ok := g.subscriptions[uri]; ok {
+		sub.sessions[caller.SessionID] = caller
+		g.subMu.Unlock()
+		return emptyResult(req), nil
+	}
+	g.subMu.Unlock()
+
+	// First subscriber: subscribe on the owning upstream
+	var lastErr *JSONRPCError
+	for _, c := range g.resourceCandidates(caller, uri) {
+		resp, err := c.Call("resources/subscribe", map[string]string{"uri": uri})
+		if err != nil {
+			lastErr = &JSONRPCError{Code: -32603, Message: err.Error()}
+			continue
+		}
+		if resp.Error != nil {
+			lastErr = resp.Error
+			continue
+		}
+
+		g.subMu.Lock()
+		if g.subscriptions == nil {
+			g.subscriptions = make(map[string]*resourceSubscription)
+		}
+		sub, ok := g.subscriptions[uri]
+		if !ok {
+			sub = &resourceSubscription{server: c.Config.Name, sessions: make(map[string]*Caller)}
+			g.subscriptions[uri] = sub
+		}
+		sub.sessions[caller.SessionID] = caller
+		g.subMu.Unlock()
+		fmt.Printf("[Gateway] Session %s subscribed to %s on %s\n", caller.SessionID, uri, c.Config.Name)
+		return emptyResult(req), nil
+	}
+
+	if lastErr == nil {
+		lastErr = &JSONRPCError{Code: -32002, Message: "Resource not found"}
+	}
+	return &JSONRPCMessage{JSONRPC: "2.0", ID: req.ID, Error: lastErr}, nil
+}
+
+func (g *Gateway) handleResourceUnsubscribe(req *JSONRPCMessage, caller *Caller) (*JSONRPCMessage, error) {
+	uri, errResp := subscriptionRequest(req, caller)
+	if errResp != nil {
+		return errResp, nil
+	}
+
+	g.subMu.Lock()
+	server := g.removeSubscriber(uri, caller.SessionID)
+	g.subMu.Unlock()
+	if server != "" {
+		g.unsubscribeUpstream(server, uri)
+	}
+	return emptyResult(req), nil
+}
+
+// dropSubscriptions removes the resource subscriptions of a session that ended,
+// unsubscribing upstream where it was the last subscriber.
+func (g *Gateway) dropSubscriptions(sessionID string) {
+	emptied := make(map[string]string) // URI -> server
+	g.subMu.Lock()
+	for uri := range g.subscriptions {
+		if server := g.removeSubscriber(uri, sessionID); server != "" {
+			emptied[uri] = server
+		}
+	}
+	g.subMu.Unlock()
+
+	for uri, server := range emptied {
+		g.unsubscribeUpstream(server, uri)
+	}
+}
+
+// removeSubscriber removes a session from a subscription. It returns the owning
+// server if no subscribers remain. Callers hold subMu.
+func (g *Gateway) removeSubscriber(uri string, sessionID string) string {
+	sub, ok := g.subscriptions[uri]
+	if !ok {
+		return ""
+	}
+	if _, ok := sub.sessions[sessionID]; !ok {
+		return ""
+	}
+	delete(sub.sessions, sessionID)
+	if len(sub.sessions) > 0 {
+		return ""
+	}
+	delete(g.subscriptions, uri)
+	return sub.server
+}
+
+func (g *Gateway) unsubscribeUpstream(server string, uri string) {
+	client, ok := g.upstream(server)
+	if !ok || !client.IsReady() {
+		return
+	}
+	if _, err := client.Call("resources/unsubscribe", map[string]string{"uri": uri}); err != nil {
+		fmt.Printf("[Gateway] Failed to unsubscribe %s on %s: %v\n", uri, server, err)
+	}
+}
+
+// resubscribeResources restores the upstream subscriptions of a server after it
+// (re)connects.
+func (g *Gateway) resubscribeResources(c *UpstreamClient) {
+	g.subMu.Lock()
+	var uris []string
+	for uri, sub := range g.subscriptions {
+		if sub.server == c.Config.Name {
+			uris = append(uris, uri)
+		}
+	}
+	g.subMu.Unlock()
+
+	for _, uri := range uris {
+		if _, err := c.Call("resources/subscribe", map[string]string{"uri": uri}); err != nil {
+			fmt.Printf("[Upstream %s] Failed to resubscribe %s: %v\n", c.Config.Name, uri, err)
+		}
+	}
+}
+
+// handleUpstreamNotification relays upstream notifications to the downstream
+// sessions interested in them.
+func (g *Gateway) handleUpstreamNotification(c *UpstreamClient, msg *JSONRPCMessage) {
+	defer report.Recover("notification from " + c.Config.Name)
+
+	switch msg.Method {
+	case "notifications/tools/list_changed":
+		c.dropToolCache()
+		g.notifyToolsChanged()
+	case "notifications/progress":
+		g.forwardProgress(c, msg)
+	case "notifications/message":
+		g.relayLogMessage(c, msg)
+	case "notifications/resources/updated":
+		var params struct {
+			URI string `json:"uri"`
+		}
+		json.Unmarshal(msg.Params, &params)
+
+		g.subMu.Lock()
+		var targets []*Caller
+		if sub, ok := g.subscriptions[params.URI]; ok && sub.server == c.Config.Name {
+			for _, caller := range sub.sessions {
+				targets = append(targets, caller)
+			}
+		}
+		g.subMu.Unlock()
+
+		payload, _ := json.Marshal(msg)
+		for _, caller := range targets {
+			if caller.ResourceAllowed(c.Config.Name, params.URI) {
+				caller.Deliver(payload)
+			}
+		}
+	}
+}
+
+// subscriptionRequest validates a resources/subscribe or unsubscribe request and
+// returns its URI, or an error response.
+func subscriptionRequest(req *JSONRPCMessage, caller *Caller) (string, *JSONRPCMessage) {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	json.Unmarshal(req.Params, &params)
+
+	var rpcErr *JSONRPCError
+	switch {
+	case caller.Notify == nil || caller.SessionID == "":
+		rpcErr = &JSONRPCError{Code: -32600, Message: "Resource subscriptions require an SSE session"}
+	case params.URI == "":
+		rpcErr = &JSONRPCError{Code: -32602, Message: "Missing uri"}
+	}
+	if rpcErr != nil {
+		return "", &JSONRPCMessage{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
+	}
+	return params.URI, nil
+}
+
+func emptyResult(req *JSONRPCMessage) *JSONRPCMessage {
+	return &JSONRPCMessage{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage("{}")}
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"encoding/json"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceSubscriptions(t *testing.T) {
+	received := make(chan []byte, 4)
+	subscriber := &Caller{SessionID: "s1", Notify: func(msg []byte) bool {
+		received <- msg
+		return true
+	}}
+	other := &Caller{SessionID: "s2", Notify: func([]byte) bool { return true }}
+
+	g := &Gateway{upstreams: map[uint]*UpstreamClient{}, subscriptions: map[string]*resourceSubscription{
+		"file:///a": {server: "files", sessions: map[string]*Caller{"s1": subscriber, "s2": other}},
+	}}
+	files := &UpstreamClient{Config: model.UpstreamServer{Name: "files"}}
+
+	t.Run("Relays Updates To Subscribers", func(t *testing.T) {
+		params, _ := json.Marshal(map[string]string{"uri": "file:///a"})
+		g.handleUpstreamNotification(files, &JSONRPCMessage{JSONRPC: "2.0", Method: "notifications/resources/updated", Params: params})
+
+		select {
+		case msg := <-received:
+			assert.Contains(t, string(msg), "notifications/resources/updated")
+		case <-time.After(time.Second):
+			t.Fatal("notification not relayed")
+		}
+	})
+
+	t.Run("Ignores Updates From Other Servers", func(t *testing.T) {
+		params, _ := json.Marshal(map[string]string{"uri": "file:///a"})
+		g.handleUpstreamNotification(&UpstreamClient{Config: model.UpstreamServer{Name: "other"}},
+			&JSONRPCMessage{JSONRPC: "2.0", Method: "notifications/resources/updated", Params: params})
+
+		select {
+		case <-received:
+			t.Fatal("unexpected notification")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("Session Teardown", func(t *testing.T) {
+		g.DropSession("s1")
+		assert.Len(t, g.subscriptions["file:///a"].sessions, 1)
+		g.DropSession("s2")
+		assert.NotContains(t, g.subscriptions, "file:///a")
+	})
+
+	t.Run("Requires A Session", func(t *testing.T) {
+		params, _ := json.Marshal(map[string]string{"uri": "file:///a"})
+		resp, _ := g.handleResourceSubscribe(&JSONRPCMessage{Params: params}, &Caller{})
+		assert.Equal(t, -32600, resp.Error.Code)
+	})
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/model"
+	"path"
+	"time"
+)
+
+// ParseToolTimeouts checks the request timeout of an upstream and decodes its
+// per-tool overrides: a JSON object of tool names or glob patterns to seconds,
+// e.g. {"build_*": 600, "lookup": 5}.
+func ParseToolTimeouts(cfg model.UpstreamServer) (map[string]time.Duration, error) {
+	if cfg.RequestTimeout < 0 {
+		return nil, fmt.Errorf("request_timeout must not be negative")
+	}
+	if cfg.ToolTimeouts == "" {
+		return nil, nil
+	}
+	var seconds map[string]int
+	if err := json.Unmarshal([]byte(cfg.ToolTimeouts), &seconds); err != nil {
+		return nil, fmt.Errorf("invalid tool_timeouts: %v", err)
+	}
+	timeouts := make(map[string]time.Duration, len(seconds))
+	for pattern, s := range seconds {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid tool timeout pattern %q: %v", pattern, err)
+		}
+		if s <= 0 {
+			return nil, fmt.Errorf("timeout of %q must be a positive number of seconds", pattern)
+		}
+		timeouts[pattern] = time.Duration(s) * time.Second
+	}
+	return timeouts, nil
+}
+
+// requestTimeout returns how long the upstream may take to answer a request,
+// falling back to UPSTREAM_TIMEOUT.
+func (c *UpstreamClient) requestTimeout() time.Duration {
+	if c.Config.RequestTimeout > 0 {
+		return time.Duration(c.Config.RequestTimeout) * time.Second
+	}
+	return c.settings.UpstreamTimeout
+}
+
+// ToolTimeout returns how long a call to the (unprefixed) tool may take: its
+// override in tool_timeouts, the exact name first and else the longest matching
+// pattern, or the request timeout of the upstream.
+func (c *UpstreamClient) ToolTimeout(name string) time.Duration {
+	if timeout, ok := matchToolOverride(c.toolTimeouts, name); ok {
+		return timeout
+	}
+	return c.requestTimeout()
+}
+
+// matchToolOverride looks up the (unprefixed) tool in overrides keyed by tool
+// name or glob pattern: the exact name first and else the longest matching pattern.
+func matchToolOverride[V any](overrides map[string]V, name string) (V, bool) {
+	if v, ok := overrides[name]; ok {
+		return v, true
+	}
+	best := ""
+	for pattern := range overrides {
+		if ok, _ := path.Match(pattern, name); ok && (len(pattern) > len(best) || len(pattern) == len(best) && pattern < best) {
+			best = pattern
+		}
+	}
+	v, ok := overrides[best]
+	return v, ok && best != ""
+}
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolTimeouts(t *testing.T) {
+	for _, cfg := range []model.UpstreamServer{
+		{RequestTimeout: -1},
+		{ToolTimeouts: `["build"]`},
+		{ToolTimeouts: `{"build": 0}`},
+		{ToolTimeouts: `{"[": 5}`},
+	} {
+		_, err := ParseToolTimeouts(cfg)
+		assert.Error(t, err, cfg.ToolTimeouts)
+	}
+
+	client := NewUpstreamClient(model.UpstreamServer{
+		Name:         "ci",
+		ToolTimeouts: `{"build_*": 600, "build_docs": 60, "*_docs": 90, "lookup": 5}`,
+	}, &config.Config{UpstreamTimeout: 30 * time.Second}, nil)
+	assert.Equal(t, 30*time.Second, client.requestTimeout())
+	for tool, timeout := range map[string]time.Duration{
+		"build_image":  600 * time.Second,
+		"build_docs":   60 * time.Second, // Exact names first
+		"read_docs":    90 * time.Second,
+		"build_x_docs": 600 * time.Second, // Else the longest pattern
+		"lookup":       5 * time.Second,
+		"status":       30 * time.Second,
+	} {
+		assert.Equal(t, timeout, client.ToolTimeout(tool), tool)
+	}
+
+	client = NewUpstreamClient(model.UpstreamServer{Name: "slow", RequestTimeout: 300}, &config.Config{UpstreamTimeout: 30 * time.Second}, nil)
+	require.Nil(t, client.toolTimeouts)
+	assert.Equal(t, 300*time.Second, client.ToolTimeout("anything"))
+}
+
+func TestHTTPToolTimeouts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	call := func(toolTimeouts string) json.RawMessage {
+		cfg := model.UpstreamServer{Name: "reports", URL: srv.URL, ToolConfig: `{"name": "report"}`, ToolTimeouts: toolTimeouts}
+		tr := NewHTTPTransport(cfg, &config.Config{HTTPToolTimeout: 100 * time.Millisecond}, nil)
+		replies := make(chan []byte, 1)
+		tr.onMessage = func(payload []byte) { replies <- payload }
+		id := json.RawMessage(`1`)
+		tr.handleToolCall(&id, json.RawMessage(`{"name": "report", "arguments": {}}`))
+		var resp JSONRPCMessage
+		require.NoError(t, json.Unmarshal(<-replies, &resp))
+		return resp.Result
+	}
+
+	result := call(`{"report": 2}`)
+	assert.False(t, resultIsError(result), "the override outlasts HTTP_TOOL_TIMEOUT")
+	assert.Equal(t, "ok", resultText(result))
+
+	result = call("")
+	assert.True(t, resultIsError(result))
+	assert.Contains(t, resultText(result), "deadline exceeded")
+}
+
+// slowInitTransport is connected until its attempt ends and answers initialize
+// after delay, or never if silent.
+type slowInitTransport struct {
+	client *UpstreamClient
+	delay  time.Duration
+	silent bool
+}
+
+func (t *slowInitTransport) Start(ctx context.Context, onMessage func([]byte), onReady func()) error {
+	go onReady()
+	<-ctx.Done()
+	return nil
+}
+
+func (t *slowInitTransport) Send(payload []byte) error {
+	var req JSONRPCMessage
+	json.Unmarshal(payload, &req)
+	if req.Method != "initialize" || t.silent {
+		return nil
+	}
+	go func() {
+		time.Sleep(t.delay)
+		resp, _ := json.Marshal(JSONRPCMessage{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage(`{"protocolVersion":"2025-06-18","capabilities":{}}`)})
+		t.client.handleMessage(resp)
+	}()
+	return nil
+}
+
+func (t *slowInitTransport) Close() error { return nil }
+
+func TestInitTimeout(t *testing.T) {
+	connect := func(transport *slowInitTransport, initTimeout time.Duration) (*UpstreamClient, context.CancelFunc, <-chan error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		client := &UpstreamClient{
+			Config:      model.UpstreamServer{ID: 1, Name: "slow"},
+			settings:    &config.Config{UpstreamTimeout: 5 * time.Second, InitTimeout: initTimeout},
+			transport:   transport,
+			pendingReqs: make(map[string]chan JSONRPCMessage),
+			ctx:         ctx,
+			cancel:      cancel,
+		}
+		transport.client = client
+		done := make(chan error, 1)
+		go func() { done <- client.runTransport() }()
+		return client, cancel, done
+	}
+
+	t.Run("Ready In Time", func(t *testing.T) {
+		client, cancel, done := connect(&slowInitTransport{delay: 20 * time.Millisecond}, time.Second)
+		defer func() { cancel(); <-done }()
+		assert.Eventually(t, func() bool { return client.Status().State == StateReady }, time.Second, 5*time.Millisecond)
+		status := client.Status()
+		assert.GreaterOrEqual(t, status.InitDurationMs, int64(20), "the start-to-ready time is reported")
+		assert.Empty(t, status.LastError)
+	})
+
+	t.Run("Attempt Aborted After Timeout", func(t *testing.T) {
+		client, cancel, done := connect(&slowInitTransport{silent: true}, 50*time.Millisecond)
+		defer cancel()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("the attempt was not aborted")
+		}
+		status := client.Status()
+		assert.Equal(t, StateFailed, status.State)
+		assert.Equal(t, "initialization timed out after 50ms", status.LastError)
+	})
+
+	t.Run("Server Override", func(t *testing.T) {
+		client := &UpstreamClient{settings: &config.Config{InitTimeout: time.Minute}}
+		assert.Equal(t, time.Minute, client.initTimeout())
+		client.Config.InitTimeout = 5
+		assert.Equal(t, 5*time.Second, client.initTimeout())
+	})
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// wallClockLayouts are the accepted layouts of times given without offset.
+var wallClockLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// ValidateTimezone checks a per-entity timezone override; empty means the gateway's.
+func ValidateTimezone(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("unknown timezone %q (e.g. Europe/Berlin, UTC)", name)
+	}
+	return nil
+}
+
+// Location returns the zone of an entity with the given timezone override,
+// falling back to the gateway TIMEZONE.
+func (g *Gateway) Location(name string) *time.Location {
+	if name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return g.settings.Location()
+}
+
+// ParseLocalTime parses an RFC 3339 time, which carries its own offset, or a
+// wall-clock time such as "2026-03-29 02:30", which is taken in loc. The result
+// is in the server's local time, ready to be stored.
+func ParseLocalTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return storedTime(t), nil
+	}
+	for _, layout := range wallClockLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return storedTime(t), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use RFC 3339 or YYYY-MM-DD HH:MM", value)
+}
+
+// startOfDay returns midnight of the day t falls on in loc.
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// storedTime converts t to the server's local time. Timestamps are stored as text
+// and compared as strings, so times written or compared in queries must use the
+// zone GORM writes CreatedAt in.
+func storedTime(t time.Time) time.Time {
+	return t.In(time.Local)
+}
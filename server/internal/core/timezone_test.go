@@ -0,0 +1,82 @@
+package core
+
+import (
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestParseLocalTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+
+	ts, err := ParseLocalTime("2026-07-01 02:30", berlin)
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-07-01T00:30:00Z", ts.UTC().Format(time.RFC3339))
+
+	ts, err = ParseLocalTime("2026-01-01T02:30:15", berlin)
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-01-01T01:30:15Z", ts.UTC().Format(time.RFC3339))
+
+	// An explicit offset wins over the zone
+	ts, err = ParseLocalTime("2026-07-01T02:30:00-05:00", berlin)
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-07-01T07:30:00Z", ts.UTC().Format(time.RFC3339))
+
+	_, err = ParseLocalTime("tomorrow", berlin)
+	assert.Error(t, err)
+}
+
+func TestTimezones(t *testing.T) {
+	assert.NoError(t, ValidateTimezone(""))
+	assert.NoError(t, ValidateTimezone("Asia/Tokyo"))
+	assert.Error(t, ValidateTimezone("Mars/Olympus"))
+
+	g := &Gateway{settings: &config.Config{Timezone: "Asia/Tokyo"}}
+	assert.Equal(t, "Asia/Tokyo", g.Location("").String())
+	assert.Equal(t, "UTC", g.Location("UTC").String())
+
+	tokyo := g.Location("")
+	// 20:00 UTC is already the next day in Tokyo
+	day := startOfDay(time.Date(2026, 5, 1, 20, 0, 0, 0, time.UTC), tokyo)
+	assert.Equal(t, time.Date(2026, 5, 2, 0, 0, 0, 0, tokyo), day)
+	assert.Equal(t, "2026-05-01T15:00:00Z", day.UTC().Format(time.RFC3339))
+}
+
+func TestTeamQuotaDay(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	db.AutoMigrate(&model.Team{}, &model.UsageLog{})
+	g := &Gateway{db: db, settings: &config.Config{}}
+
+	// The quota day of a team just east of the date line started at most 14h ago,
+	// one just west of it at most 12h ago (in UTC terms the days are 26h apart).
+	east := model.Team{Name: "east", DailyCallQuota: 1, Timezone: "Pacific/Kiritimati"}
+	west := model.Team{Name: "west", DailyCallQuota: 1, Timezone: "Etc/GMT+12"}
+	db.Create(&east)
+	db.Create(&west)
+	used := func(team model.Team, ago time.Duration) {
+		db.Create(&model.UsageLog{CreatedAt: time.Now().Add(-ago), TeamID: team.ID, Tool: "demo__echo", Success: true})
+	}
+
+	checkQuota := func(caller *Caller) error {
+		release, err := g.reserveTeamQuota(caller)
+		release()
+		return err
+	}
+
+	eastStart := startOfDay(time.Now(), g.Location(east.Timezone))
+	used(east, time.Since(eastStart)+time.Minute)
+	assert.NoError(t, checkQuota(&Caller{TeamID: east.ID}))
+	used(east, time.Since(eastStart)/2)
+	assert.ErrorContains(t, checkQuota(&Caller{TeamID: east.ID}), "daily quota")
+
+	westStart := startOfDay(time.Now(), g.Location(west.Timezone))
+	used(west, time.Since(westStart)+time.Minute)
+	assert.NoError(t, checkQuota(&Caller{TeamID: west.ID}))
+}
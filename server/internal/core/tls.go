@@ -0,0 +1,67 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"one-mcp/internal/model"
+	"os"
+	"strings"
+)
+
+// ParseTLSConfig builds the TLS configuration of an upstream behind mutual TLS
+// from its client certificate, key and CA bundle, each PEM or the path of a PEM
+// file. It returns nil if none is set.
+func ParseTLSConfig(cfg model.UpstreamServer) (*tls.Config, error) {
+	if cfg.TLSClientCert == "" && cfg.TLSClientKey == "" && cfg.TLSCA == "" {
+		return nil, nil
+	}
+	config := &tls.Config{}
+	if cfg.TLSClientCert != "" || cfg.TLSClientKey != "" {
+		if cfg.TLSClientCert == "" || cfg.TLSClientKey == "" {
+			return nil, fmt.Errorf("tls_client_cert and tls_client_key must be set together")
+		}
+		certPEM, err := readPEM(cfg.TLSClientCert)
+		if err != nil {
+			return nil, fmt.Errorf("tls_client_cert: %v", err)
+		}
+		keyPEM, err := readPEM(cfg.TLSClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("tls_client_key: %v", err)
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.TLSCA != "" {
+		caPEM, err := readPEM(cfg.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("tls_ca: %v", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("tls_ca: no certificates found")
+		}
+	}
+	return config, nil
+}
+
+// upstreamTLS returns the TLS configuration of an upstream, ignoring it if it
+// is invalid (it is validated when saved, but files may change).
+func upstreamTLS(cfg model.UpstreamServer) *tls.Config {
+	config, err := ParseTLSConfig(cfg)
+	if err != nil {
+		fmt.Printf("[Upstream %s] Ignoring TLS configuration: %v\n", cfg.Name, err)
+	}
+	return config
+}
+
+// readPEM returns value if it is PEM, or else the contents of the file it names.
+func readPEM(value string) ([]byte, error) {
+	if strings.Contains(value, "-----BEGIN") {
+		return []byte(value), nil
+	}
+	return os.ReadFile(strings.TrimSpace(value))
+}
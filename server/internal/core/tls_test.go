@@ -0,0 +1,89 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMutualTLS(t *testing.T) {
+	// A self-signed client certificate, the key of which is stored in a file
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "one-mcp"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	clientCert, _ := x509.ParseCertificate(der)
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	keyFile := filepath.Join(t.TempDir(), "client.key")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	received := make(chan string, 1)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case received <- r.TLS.PeerCertificates[0].Subject.CommonName:
+		default:
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+
+	cfg := model.UpstreamServer{Name: "mtls", URL: srv.URL, TLSClientCert: certPEM, TLSClientKey: keyFile, TLSCA: caPEM}
+	tlsConfig, err := ParseTLSConfig(cfg)
+	require.NoError(t, err)
+	assert.Len(t, tlsConfig.Certificates, 1)
+
+	transport := NewStreamableHTTPTransport(cfg, &config.Config{})
+	go transport.Start(t.Context(), func([]byte) {}, nil)
+	require.Eventually(t, func() bool {
+		return transport.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)) == nil
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "one-mcp", <-received)
+
+	// Without the client certificate, the handshake fails
+	transport = NewStreamableHTTPTransport(model.UpstreamServer{Name: "mtls", URL: srv.URL, TLSCA: caPEM}, &config.Config{})
+	go transport.Start(t.Context(), func([]byte) {}, nil)
+	time.Sleep(20 * time.Millisecond)
+	assert.Error(t, transport.Send([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)))
+
+	for _, invalid := range []model.UpstreamServer{
+		{TLSClientCert: certPEM},
+		{TLSClientCert: certPEM, TLSClientKey: filepath.Join(t.TempDir(), "missing.key")},
+		{TLSClientCert: caPEM, TLSClientKey: keyFile},
+		{TLSCA: "-----BEGIN CERTIFICATE-----\nnot a certificate\n-----END CERTIFICATE-----"},
+	} {
+		_, err := ParseTLSConfig(invalid)
+		assert.Error(t, err)
+	}
+	tlsConfig, err = ParseTLSConfig(model.UpstreamServer{})
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConfig)
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MaxTraceDuration bounds how long message tracing can be enabled at once.
+const MaxTraceDuration = 24 * time.Hour
+
+// TracePath returns the trace file of an upstream.
+func TracePath(dataDir string, server string) string {
+	return filepath.Join(dataDir, "traces", server+".log")
+}
+
+// EnableTrace writes every frame exchanged with the upstream to path until the
+// given time.
+func (c *UpstreamClient) EnableTrace(path string, until time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	c.traceMu.Lock()
+	defer c.traceMu.Unlock()
+	if c.traceFile != nil {
+		c.traceFile.Close()
+	}
+	c.traceFile = f
+	c.traceUntil = until
+	fmt.Fprintf(f, "%s -- tracing enabled until %s\n", time.Now().Format(time.RFC3339Nano), until.Format(time.RFC3339))
+	return nil
+}
+
+// DisableTrace stops message tracing.
+func (c *UpstreamClient) DisableTrace() {
+	c.traceMu.Lock()
+	defer c.traceMu.Unlock()
+	c.closeTrace()
+}
+
+// TraceUntil returns when tracing ends, or the zero time if it is off.
+func (c *UpstreamClient) TraceUntil() time.Time {
+	c.traceMu.Lock()
+	defer c.traceMu.Unlock()
+	if c.traceFile == nil {
+		return time.Time{}
+	}
+	return c.traceUntil
+}
+
+// traceFrame records a frame; direction is ">>" for sent and "<<" for received.
+func (c *UpstreamClient) traceFrame(direction string, frame []byte) {
+	c.traceMu.Lock()
+	defer c.traceMu.Unlock()
+	if c.traceFile == nil {
+		return
+	}
+	now := time.Now()
+	if now.After(c.traceUntil) {
+		c.closeTrace()
+		return
+	}
+	fmt.Fprintf(c.traceFile, "%s %s %s\n", now.Format(time.RFC3339Nano), direction, frame)
+}
+
+func (c *UpstreamClient) closeTrace() {
+	if c.traceFile == nil {
+		return
+	}
+	fmt.Fprintf(c.traceFile, "%s -- tracing disabled\n", time.Now().Format(time.RFC3339Nano))
+	c.traceFile.Close()
+	c.traceFile = nil
+}
+
+// send writes a frame to the transport, tracing it if enabled.
+func (c *UpstreamClient) send(payload []byte) error {
+	c.traceFrame(">>", payload)
+	return c.transport.Send(payload)
+}
+
+// SetTrace enables message tracing of the server for d, or disables it if d is 0.
+// The setting survives upstream reloads. It returns the trace file path.
+func (g *Gateway) SetTrace(id uint, d time.Duration) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	client, ok := g.upstreams[id]
+	if !ok {
+		return "", fmt.Errorf("server is not running")
+	}
+	path := TracePath(g.settings.DataDir, client.Config.Name)
+
+	if d <= 0 {
+		delete(g.traces, id)
+		client.DisableTrace()
+		return path, nil
+	}
+	until := time.Now().Add(d)
+	if err := client.EnableTrace(path, until); err != nil {
+		return "", err
+	}
+	g.traces[id] = until
+	return path, nil
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestTrace(t *testing.T) {
+	dataDir := t.TempDir()
+	g := NewGateway(nil, &config.Config{DataDir: dataDir})
+	client := newResultClient("github", 1, `{"tools":[]}`)
+	g.upstreams[1] = client
+	g.upstreamIDs["github"] = 1
+	read := func() string {
+		data, _ := os.ReadFile(filepath.Join(dataDir, "traces", "github.log"))
+		return string(data)
+	}
+
+	_, err := g.SetTrace(2, time.Minute)
+	assert.Error(t, err, "only running servers can be traced")
+
+	path, err := g.SetTrace(1, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, TracePath(dataDir, "github"), path)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), client.TraceUntil(), time.Second)
+
+	_, err = client.Call("tools/list", nil)
+	require.NoError(t, err)
+	trace := read()
+	assert.Contains(t, trace, `>> {"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	assert.Contains(t, trace, `<< {"jsonrpc":"2.0","id":1,"result":{"tools":[]}}`)
+
+	_, err = g.SetTrace(1, 0)
+	require.NoError(t, err)
+	assert.True(t, client.TraceUntil().IsZero())
+	client.Call("ping", nil)
+	trace = read()
+	assert.True(t, strings.HasSuffix(trace, "tracing disabled\n"), "no frames after tracing is disabled")
+	assert.NotContains(t, trace, "ping")
+
+	// Tracing ends by itself
+	require.NoError(t, client.EnableTrace(path, time.Now().Add(-time.Second)))
+	client.Call("ping", nil)
+	assert.True(t, client.TraceUntil().IsZero())
+	assert.NotContains(t, read(), "ping")
+}
+
+func TestTraceSurvivesReload(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(model.All...))
+	db.Create(&model.UpstreamServer{Name: "demo", TransportType: "demo", Enabled: true})
+	g := NewGateway(db, &config.Config{DataDir: t.TempDir(), UpstreamTimeout: time.Second})
+	g.ReloadUpstreams()
+	t.Cleanup(g.StopUpstreams)
+
+	_, err = g.SetTrace(1, time.Minute)
+	require.NoError(t, err)
+	g.ReloadUpstreams()
+	client, ok := g.UpstreamByID(1)
+	require.True(t, ok)
+	assert.False(t, client.TraceUntil().IsZero(), "the new client keeps tracing")
+
+	_, err = g.SetTrace(1, 0)
+	require.NoError(t, err)
+	g.ReloadUpstreams()
+	client, _ = g.UpstreamByID(1)
+	assert.True(t, client.TraceUntil().IsZero())
+}
@@ -5,13 +5,21 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"one-mcp/internal/config"
 	"one-mcp/internal/model"
 )
 
@@ -32,16 +40,43 @@ type Transport interface {
 // SSETransport implements Transport using Server-Sent Events and HTTP POST
 type SSETransport struct {
 	Config   model.UpstreamServer
+	settings *config.Config
 	Endpoint string // The POST endpoint discovered via SSE
 	Client   *http.Client
 	
 	mu       io.Closer // Used to close the response body of the long-polling GET
+	watch    *endpointWatch // Certificates, addresses and hosts seen (see endpoint_watch.go)
+	headers  http.Header    // Custom headers of the upstream (see headers.go)
+
+	protocolVersion atomic.Value           // Negotiated version, sent as MCP-Protocol-Version
+	tokens          func() (string, error) // OAuth access tokens, if set (see upstream_auth.go)
+}
+
+// SetProtocolVersion sets the version announced on subsequent POSTs, as the
+// protocol requires from 2025-06-18.
+func (t *SSETransport) SetProtocolVersion(version string) {
+	t.protocolVersion.Store(version)
+}
+
+// SetTokenSource makes requests use the OAuth access tokens of tokens, if it
+// returns any, instead of the static AuthToken.
+func (t *SSETransport) SetTokenSource(tokens func() (string, error)) {
+	t.tokens = tokens
+}
+
+// SecurityWarnings returns the unexpected endpoint changes seen so far.
+func (t *SSETransport) SecurityWarnings() []SecurityWarning {
+	return t.watch.Warnings()
 }
 
-func NewSSETransport(cfg model.UpstreamServer) *SSETransport {
+func NewSSETransport(cfg model.UpstreamServer, settings *config.Config) *SSETransport {
+	watch := newEndpointWatch(cfg.Name, cfg.URL, cfg.PinnedCertSHA256)
 	return &SSETransport{
-		Config: cfg,
-		Client: &http.Client{Timeout: 0},
+		Config:   cfg,
+		settings: settings,
+		Client:   watch.client(egressPolicy(settings.EgressAllowlist), upstreamTLS(cfg), upstreamProxy(cfg)),
+		watch:    watch,
+		headers:  upstreamHeaders(cfg),
 	}
 }
 
@@ -53,15 +88,9 @@ func (t *SSETransport) Start(ctx context.Context, onMessage func([]byte), onRead
 	}
 	
 	req.Header.Set("Accept", "text/event-stream")
-	if t.Config.AuthToken != "" {
-		// Sanitize AuthToken to prevent header injection
-		token := strings.Map(func(r rune) rune {
-			if r == '\n' || r == '\r' {
-				return -1
-			}
-			return r
-		}, t.Config.AuthToken)
-		req.Header.Set("Authorization", "Bearer "+token)
+	applyHeaders(req, t.headers)
+	if err := setAuthorization(req, t.Config.AuthToken, t.tokens); err != nil {
+		return err
 	}
 
 	resp, err := t.Client.Do(req)
@@ -73,42 +102,44 @@ func (t *SSETransport) Start(ctx context.Context, onMessage func([]byte), onRead
 	if resp.StatusCode != 200 {
 		return fmt.Errorf("bad status code: %d", resp.StatusCode)
 	}
+	if t.watch.pin != "" && resp.TLS == nil {
+		return fmt.Errorf("certificate pinned but the upstream was reached without TLS")
+	}
 	
 	t.mu = resp.Body
 
-	scanner := bufio.NewScanner(resp.Body)
-	buf := make([]byte, 64*1024)
-	scanner.Buffer(buf, 1024*1024)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "event: endpoint") {
-			if scanner.Scan() {
-				dataLine := scanner.Text()
-				if strings.HasPrefix(dataLine, "data: ") {
-					endpoint := strings.TrimPrefix(dataLine, "data: ")
-					u, err := url.Parse(t.Config.URL)
-					if err == nil {
-						ref, _ := url.Parse(endpoint)
-						t.Endpoint = u.ResolveReference(ref).String()
-					} else {
-						t.Endpoint = endpoint
-					}
-					fmt.Printf("[SSETransport %s] Endpoint discovered: %s\n", t.Config.Name, t.Endpoint)
-					if onReady != nil {
-						go onReady()
-					}
-				}
+	events := NewSSEReader(resp.Body, t.settings.MaxMessageSize)
+	for {
+		ev, err := events.Next()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		switch ev.Event {
+		case "endpoint":
+			endpoint := strings.TrimSpace(ev.Data)
+			// Relative to where the stream was served from, after any redirect
+			u := resp.Request.URL
+			ref, err := url.Parse(endpoint)
+			if err == nil {
+				resolved := u.ResolveReference(ref)
+				t.watch.checkHost(resolved, "the endpoint event")
+				t.Endpoint = resolved.String()
+			} else {
+				t.Endpoint = endpoint
 			}
-		} else if strings.HasPrefix(line, "data: ") {
-			msgStr := strings.TrimPrefix(line, "data: ")
-			if len(msgStr) > 0 {
-				onMessage([]byte(msgStr))
+			fmt.Printf("[SSETransport %s] Endpoint discovered: %s\n", t.Config.Name, t.Endpoint)
+			if onReady != nil {
+				go onReady()
+			}
+		case "message":
+			if len(ev.Data) > 0 {
+				onMessage([]byte(ev.Data))
 			}
 		}
 	}
-	
-	return scanner.Err()
 }
 
 func (t *SSETransport) Send(payload []byte) error {
@@ -116,22 +147,19 @@ func (t *SSETransport) Send(payload []byte) error {
 		return fmt.Errorf("endpoint not yet discovered")
 	}
 
-	fmt.Printf("[SSETransport %s] POST %s Payload: %s\n", t.Config.Name, t.Endpoint, string(payload))
+	fmt.Printf("[SSETransport %s] POST %s (%d bytes)\n", t.Config.Name, t.Endpoint, len(payload))
 
 	req, err := http.NewRequest("POST", t.Endpoint, bytes.NewReader(payload))
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	if t.Config.AuthToken != "" {
-		// Sanitize AuthToken to prevent header injection
-		token := strings.Map(func(r rune) rune {
-			if r == '\n' || r == '\r' {
-				return -1
-			}
-			return r
-		}, t.Config.AuthToken)
-		req.Header.Set("Authorization", "Bearer "+token)
+	if version, _ := t.protocolVersion.Load().(string); protocolAtLeast(version, Protocol20250618) {
+		req.Header.Set("MCP-Protocol-Version", version)
+	}
+	applyHeaders(req, t.headers)
+	if err := setAuthorization(req, t.Config.AuthToken, t.tokens); err != nil {
+		return err
 	}
 	
 	resp, err := t.Client.Do(req)
@@ -155,14 +183,28 @@ func (t *SSETransport) Close() error {
 
 // StdioTransport implements Transport using local process execution
 type StdioTransport struct {
-	Config model.UpstreamServer
-	cmd    *exec.Cmd
-	stdin  io.WriteCloser
+	Config   model.UpstreamServer
+	settings *config.Config
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	codec    *messageCodec
+	sandbox  func() (*model.SandboxProfile, error) // Profile of the process, if set (see sandbox.go)
+
+	mu     sync.Mutex
+	exited chan struct{} // Closed when the current process has exited
+	stop   *sync.Once    // Terminates the current process once (see terminate)
 }
 
-func NewStdioTransport(cfg model.UpstreamServer) *StdioTransport {
+// SetSandbox makes the process launch under the profile sandbox returns, loaded
+// at every launch. If it cannot be loaded or applied, the launch fails.
+func (t *StdioTransport) SetSandbox(sandbox func() (*model.SandboxProfile, error)) {
+	t.sandbox = sandbox
+}
+
+func NewStdioTransport(cfg model.UpstreamServer, settings *config.Config) *StdioTransport {
 	return &StdioTransport{
-		Config: cfg,
+		Config:   cfg,
+		settings: settings,
 	}
 }
 
@@ -179,9 +221,37 @@ func (t *StdioTransport) Start(ctx context.Context, onMessage func([]byte), onRe
 		return err
 	}
 
-	fmt.Printf("[StdioTransport %s] Starting command: %s %v\n", t.Config.Name, t.Config.Command, args)
+	codec, err := newMessageCodec(t.Config.Framing, t.Config.Encoding, t.settings.MaxMessageSize)
+	if err != nil {
+		return err
+	}
+	t.codec = codec
+
+	command := t.Config.Command
+	if t.sandbox != nil {
+		profile, err := t.sandbox()
+		if err != nil {
+			return err
+		}
+		if command, args, err = SandboxCommand(profile, command, args); err != nil {
+			return err
+		}
+		fmt.Printf("[StdioTransport %s] Using sandbox profile %s\n", t.Config.Name, profile.Name)
+	}
+
+	fmt.Printf("[StdioTransport %s] Starting command: %s %v\n", t.Config.Name, command, args)
 	
-	t.cmd = exec.CommandContext(ctx, t.Config.Command, args...)
+	exited, stop := make(chan struct{}), &sync.Once{}
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Dir = t.Config.Cwd
+	// Ending the attempt terminates the process gracefully rather than killing it
+	cmd.Cancel = func() error {
+		go t.terminate(cmd, exited, stop)
+		return nil
+	}
+	t.mu.Lock()
+	t.cmd, t.exited, t.stop = cmd, exited, stop
+	t.mu.Unlock()
 	
 	// Set Environment
 	t.cmd.Env = os.Environ() // Inherit current env
@@ -218,7 +288,12 @@ func (t *StdioTransport) Start(ctx context.Context, onMessage func([]byte), onRe
 		}
 	}()
 
-	if err := t.cmd.Start(); err != nil {
+	// Close reads the process under t.mu, so it either sees it started and waits
+	// for it, or this attempt finds its context cancelled and does not start it
+	t.mu.Lock()
+	err = cmd.Start()
+	t.mu.Unlock()
+	if err != nil {
 		return err
 	}
 
@@ -227,21 +302,14 @@ func (t *StdioTransport) Start(ctx context.Context, onMessage func([]byte), onRe
 	}
 
 	// Read Stdout in this goroutine (blocking)
-	scanner := bufio.NewScanner(stdout)
-	// Large buffer just in case
-	buf := make([]byte, 1024*1024)
-	scanner.Buffer(buf, 10*1024*1024)
-
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		// Copy buffer because scanner reuses it
-		msg := make([]byte, len(line))
-		copy(msg, line)
-
-		onMessage(msg)
+	if err := t.codec.read(stdout, onMessage); err != nil {
+		fmt.Printf("[StdioTransport %s] Failed to read output: %v\n", t.Config.Name, err)
+		go t.terminate(cmd, exited, stop)
 	}
 
-	if err := t.cmd.Wait(); err != nil {
+	err = t.cmd.Wait()
+	close(exited)
+	if err != nil {
 		fmt.Printf("[StdioTransport %s] Process exited with error: %v\n", t.Config.Name, err)
 		return err
 	}
@@ -271,24 +339,75 @@ func ValidateCommand(command string, args []string) error {
 	return nil
 }
 
+// ValidateCwd checks the working directory of a stdio upstream, which must be
+// an existing directory if set.
+func ValidateCwd(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("cwd must be an absolute path")
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("cwd: %v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("cwd: %s is not a directory", dir)
+	}
+	return nil
+}
+
 func (t *StdioTransport) Send(payload []byte) error {
 	if t.stdin == nil {
 		return fmt.Errorf("stdin not open")
 	}
 	
-	// JSON-RPC over stdio is typically line-delimited
-	// Ensure newline
-	if !bytes.HasSuffix(payload, []byte("\n")) {
-		payload = append(payload, '\n')
+	// Line-delimited by default, see framing.go
+	framed, err := t.codec.encode(payload)
+	if err != nil {
+		return err
 	}
 	
-	_, err := t.stdin.Write(payload)
+	_, err = t.stdin.Write(framed)
 	return err
 }
 
+// Close terminates the process and waits for it to exit, see terminate. The
+// attempt's context must be cancelled first, as UpstreamClient.Stop does, so that
+// a process not started yet is not started at all.
 func (t *StdioTransport) Close() error {
-	if t.cmd != nil && t.cmd.Process != nil {
-		return t.cmd.Process.Kill()
+	t.mu.Lock()
+	cmd, exited, stop := t.cmd, t.exited, t.stop
+	started := cmd != nil && cmd.Process != nil
+	t.mu.Unlock()
+	if !started {
+		return nil
 	}
-	return nil
+	return t.terminate(cmd, exited, stop)
+}
+
+// terminate sends the process SIGTERM, so that it can flush its state, and
+// kills it if it has not exited within STDIO_STOP_TIMEOUT (immediately if 0, or
+// on Windows). Only the first call for a process does so; the others wait for it.
+func (t *StdioTransport) terminate(cmd *exec.Cmd, exited <-chan struct{}, stop *sync.Once) error {
+	var err error
+	stop.Do(func() {
+		if grace := t.settings.StdioStopGrace; grace > 0 && runtime.GOOS != "windows" {
+			if cmd.Process.Signal(syscall.SIGTERM) == nil {
+				timer := time.NewTimer(grace)
+				defer timer.Stop()
+				select {
+				case <-exited:
+					return
+				case <-timer.C:
+				}
+				fmt.Printf("[StdioTransport %s] Process did not exit within %s of SIGTERM, killing it\n", t.Config.Name, grace)
+			}
+		}
+		if err = cmd.Process.Kill(); errors.Is(err, os.ErrProcessDone) {
+			err = nil
+		}
+	})
+	return err
 }
@@ -8,7 +8,9 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"one-mcp/internal/config"
 	"one-mcp/internal/model"
+	"sync"
 	"time"
 )
 
@@ -17,41 +19,77 @@ type HTTPTransport struct {
 	Config     model.UpstreamServer
 	ToolConfig ToolConfig
 	Client     *http.Client
-	
+	Secrets    *SecretStore
+	Retries    int
+	Timeout    time.Duration // Of a tool call including retries: request_timeout or HTTP_TOOL_TIMEOUT
+	headers    http.Header   // Custom headers of the server, before the tool's (see headers.go)
+
+	toolTimeouts map[string]time.Duration // Overrides of Timeout from tool_timeouts
+
+	rateLimit   *RateLimit // Last observed rate-limit headers
+	rateLimitMu sync.Mutex
+
 	onMessage func([]byte)
 	onReady   func()
 }
 
 type ToolConfig struct {
-	Name        string          `json:"name"`
-	Description string          `json:"description"`
-	Method      string          `json:"method"` // GET, POST
-	Headers     map[string]string `json:"headers"`
-	Parameters  []ToolParameter `json:"parameters"`
+	Name         string                   `json:"name"`
+	Description  string                   `json:"description"`
+	Method       string                   `json:"method"` // GET, POST
+	Headers      map[string]string        `json:"headers"`
+	Parameters   []ToolParameter          `json:"parameters"`
+	Body         string                   `json:"body,omitempty"`          // Request body template, defaults to the JSON-encoded arguments
+	OutputFormat string                   `json:"output_format,omitempty"` // text, markdown or structured
+	StatusMap    map[string]StatusMapping `json:"status_map,omitempty"`    // Keyed by status code ("404") or class ("5xx")
+	Retries      *int                     `json:"retries,omitempty"`       // Overrides HTTP_TOOL_RETRIES
 }
 
 type ToolParameter struct {
-	Name        string `json:"name"`
-	Type        string `json:"type"` // string, number, boolean
-	Description string `json:"description"`
-	Required    bool   `json:"required"`
-	Default     string `json:"default,omitempty"`
+	Name        string          `json:"name"`
+	Type        string          `json:"type"` // string, number, integer, boolean, array, object
+	Description string          `json:"description"`
+	Required    bool            `json:"required"`
+	Default     string          `json:"default,omitempty"`    // JSON-encoded for arrays and objects
+	Items       *ToolParameter  `json:"items,omitempty"`      // Element schema for arrays
+	Properties  []ToolParameter `json:"properties,omitempty"` // Field schemas for objects
 }
 
-func NewHTTPTransport(cfg model.UpstreamServer) *HTTPTransport {
+func NewHTTPTransport(cfg model.UpstreamServer, settings *config.Config, secrets *SecretStore) *HTTPTransport {
 	var tc ToolConfig
 	if cfg.ToolConfig != "" {
 		json.Unmarshal([]byte(cfg.ToolConfig), &tc)
 	}
+	egress := egressPolicy(settings.EgressAllowlist)
+	transport := egress.transport()
+	egress.setProxy(transport, upstreamProxy(cfg))
+	timeout := settings.HTTPToolTimeout
+	if cfg.RequestTimeout > 0 {
+		timeout = time.Duration(cfg.RequestTimeout) * time.Second
+	}
+	// Invalid overrides are reported by the upstream client
+	toolTimeouts, _ := ParseToolTimeouts(cfg)
 	return &HTTPTransport{
-		Config:     cfg,
-		ToolConfig: tc,
-		Client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		Config:       cfg,
+		ToolConfig:   tc,
+		Secrets:      secrets,
+		Retries:      settings.HTTPToolRetries,
+		Timeout:      timeout,
+		headers:      upstreamHeaders(cfg),
+		toolTimeouts: toolTimeouts,
+		Client:       &http.Client{Transport: transport},
 	}
 }
 
+// toolTimeout returns how long a call of the tool may take, from tool_timeouts
+// like UpstreamClient.ToolTimeout, or else Timeout.
+func (t *HTTPTransport) toolTimeout(name string) time.Duration {
+	if timeout, ok := matchToolOverride(t.toolTimeouts, name); ok {
+		return timeout
+	}
+	return t.Timeout
+}
+
 func (t *HTTPTransport) Start(ctx context.Context, onMessage func([]byte), onReady func()) error {
 	t.onMessage = onMessage
 	t.onReady = onReady
@@ -94,7 +132,7 @@ func (t *HTTPTransport) Send(payload []byte) error {
 	}
 
 	// Unknown method
-	// t.replyError(req.ID, -32601, "Method not found")
+	t.replyError(req.ID, -32601, "Method not found")
 	return nil
 }
 
@@ -143,7 +181,7 @@ func (t *HTTPTransport) replyError(id *json.RawMessage, code int, msg string) {
 func (t *HTTPTransport) handleInitialize(id *json.RawMessage) {
 	// Return standard capabilities
 	result := map[string]interface{}{
-		"protocolVersion": "2024-11-05",
+		"protocolVersion": LatestProtocolVersion,
 		"capabilities": map[string]interface{}{
 			"tools": map[string]interface{}{
 				"listChanged": false,
@@ -173,10 +211,7 @@ func (t *HTTPTransport) handleToolsList(id *json.RawMessage) {
 		// - Actually, if Default is set in our config, the Model doesn't NEED to provide it.
 		// - So if Default != "", we treat it as optional for the Model.
 		
-		prop := map[string]interface{}{
-			"type":        p.Type,
-			"description": p.Description,
-		}
+		prop := parameterSchema(p)
 		if p.Default != "" {
 			prop["default"] = p.Default
 		}
@@ -237,8 +272,25 @@ func (t *HTTPTransport) handleToolCall(id *json.RawMessage, paramsRaw json.RawMe
 		finalArgs[k] = v
 	}
 
+	// 3. Coerce to the declared types
+	finalArgs, err := coerceArgs(t.ToolConfig.Parameters, finalArgs)
+	if err != nil {
+		t.reply(id, map[string]interface{}{
+			"content": []interface{}{
+				map[string]interface{}{
+					"type": "text",
+					"text": fmt.Sprintf("Invalid arguments: %v", err),
+				},
+			},
+			"isError": true,
+		})
+		return
+	}
+
 	// Execute HTTP Request
-	response, err := t.executeHTTPRequest(finalArgs)
+	ctx, cancel := context.WithTimeout(context.Background(), t.toolTimeout(params.Name))
+	defer cancel()
+	response, err := t.executeHTTPRequest(ctx, finalArgs)
 	if err != nil {
 		t.reply(id, map[string]interface{}{
 			"content": []interface{}{
@@ -252,52 +304,82 @@ func (t *HTTPTransport) handleToolCall(id *json.RawMessage, paramsRaw json.RawMe
 		return
 	}
 
-	t.reply(id, map[string]interface{}{
-		"content": []interface{}{
-			map[string]interface{}{
-				"type": "text",
-				"text": response,
-			},
-		},
-	})
+	result := t.ToolConfig.statusResult(response.Status, response.Body)
+	if response.RateLimit != nil {
+		result["_meta"] = map[string]interface{}{RateLimitMeta: response.RateLimit}
+	}
+	t.reply(id, result)
+}
+
+// httpToolResponse is the final response of an HTTP tool request, after retries.
+type httpToolResponse struct {
+	Status    int
+	Body      string
+	RateLimit *RateLimit // nil if the API sent no rate-limit headers
 }
 
-func (t *HTTPTransport) executeHTTPRequest(args map[string]interface{}) (string, error) {
-	targetURL := t.Config.URL
+func (t *HTTPTransport) executeHTTPRequest(ctx context.Context, args map[string]interface{}) (*httpToolResponse, error) {
+	tmpl := &toolTemplate{secrets: t.Secrets, args: args}
+	response, err := t.doHTTPRequest(ctx, tmpl, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s", tmpl.redact(err.Error()))
+	}
+	response.Body = tmpl.redact(response.Body)
+	return response, nil
+}
+
+func (t *HTTPTransport) doHTTPRequest(ctx context.Context, tmpl *toolTemplate, args map[string]interface{}) (*httpToolResponse, error) {
+	targetURL, err := tmpl.renderURL(t.Config.URL)
+	if err != nil {
+		return nil, err
+	}
 	method := t.ToolConfig.Method
 	if method == "" {
 		method = "GET"
 	}
 
 	var req *http.Request
-	var err error
 
 	if method == "GET" {
 		// Append params to Query String
 		u, err := url.Parse(targetURL)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		q := u.Query()
 		for k, v := range args {
-			q.Set(k, fmt.Sprintf("%v", v))
+			setQueryParam(q, k, v)
 		}
 		u.RawQuery = q.Encode()
-		req, err = http.NewRequest("GET", u.String(), nil)
+		req, err = http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	} else {
-		// Send as JSON Body
-		jsonBytes, _ := json.Marshal(args)
-		req, err = http.NewRequest(method, targetURL, bytes.NewReader(jsonBytes))
-		req.Header.Set("Content-Type", "application/json")
+		// Send as JSON Body, or the rendered body template
+		body, _ := json.Marshal(args)
+		if t.ToolConfig.Body != "" {
+			rendered, err := tmpl.render(t.ToolConfig.Body)
+			if err != nil {
+				return nil, err
+			}
+			body = []byte(rendered)
+		}
+		req, err = http.NewRequestWithContext(ctx, method, targetURL, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
 	}
 
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Add configured headers
+	// Add the server's custom headers, then the tool's
+	applyHeaders(req, t.headers)
 	for k, v := range t.ToolConfig.Headers {
-		req.Header.Set(k, v)
+		value, err := tmpl.render(v)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(k, value)
 	}
 	
 	// Add Auth Token if exists
@@ -305,20 +387,39 @@ func (t *HTTPTransport) executeHTTPRequest(args map[string]interface{}) (string,
 		req.Header.Set("Authorization", "Bearer "+t.Config.AuthToken)
 	}
 
-	resp, err := t.Client.Do(req)
-	if err != nil {
-		return "", err
+	retries := t.Retries
+	if t.ToolConfig.Retries != nil {
+		retries = *t.ToolConfig.Retries
 	}
-	defer resp.Body.Close()
 
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			req.Body, _ = req.GetBody()
+		}
+		resp, err := t.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		bodyBytes, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
 
-	if resp.StatusCode >= 400 {
-		return fmt.Sprintf("HTTP Error %d: %s", resp.StatusCode, string(bodyBytes)), nil
+		if retryableStatus(resp.StatusCode) && attempt < retries {
+			delay := retryDelay(resp.Header.Get("Retry-After"), attempt)
+			fmt.Printf("[HTTP] %s returned %d, retrying in %v (%d/%d)\n", t.Config.Name, resp.StatusCode, delay, attempt+1, retries)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		return &httpToolResponse{
+			Status:    resp.StatusCode,
+			Body:      string(bodyBytes),
+			RateLimit: t.observeRateLimit(resp),
+		}, nil
 	}
-
-	return string(bodyBytes), nil
 }
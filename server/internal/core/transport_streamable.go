@@ -0,0 +1,376 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"one-mcp/internal/report"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StreamableHTTPTransport implements Transport with the Streamable HTTP
+// transport of MCP 2025-03-26: every message is POSTed to the single endpoint
+// URL, and the upstream answers requests with a JSON body or an SSE stream
+// carrying the response and the messages that precede it. The session ID the
+// upstream assigns during initialize is sent back on every request. Messages
+// the upstream sends outside of a request arrive on an optional GET stream.
+type StreamableHTTPTransport struct {
+	Config   model.UpstreamServer
+	settings *config.Config
+	Client   *http.Client
+	watch    *endpointWatch // Certificates, addresses and hosts seen (see endpoint_watch.go)
+	headers  http.Header    // Custom headers of the upstream (see headers.go)
+
+	mu        sync.Mutex
+	ctx       context.Context // Current connection attempt
+	onMessage func([]byte)
+	failed    chan error // Ends the connection attempt, e.g. when the session expired
+	sessionID string     // Mcp-Session-Id assigned by the upstream, "" if none
+	listening bool       // The GET stream was opened in this attempt
+	endMu     sync.Mutex // Held while a session is being ended
+
+	protocolVersion atomic.Value           // Negotiated version, sent as MCP-Protocol-Version
+	tokens          func() (string, error) // OAuth access tokens, if set (see upstream_auth.go)
+}
+
+func NewStreamableHTTPTransport(cfg model.UpstreamServer, settings *config.Config) *StreamableHTTPTransport {
+	watch := newEndpointWatch(cfg.Name, cfg.URL, cfg.PinnedCertSHA256)
+	return &StreamableHTTPTransport{
+		Config:   cfg,
+		settings: settings,
+		Client:   watch.client(egressPolicy(settings.EgressAllowlist), upstreamTLS(cfg), upstreamProxy(cfg)),
+		watch:    watch,
+		headers:  upstreamHeaders(cfg),
+	}
+}
+
+// SetProtocolVersion sets the version announced on subsequent requests, as the
+// protocol requires from 2025-06-18.
+func (t *StreamableHTTPTransport) SetProtocolVersion(version string) {
+	t.protocolVersion.Store(version)
+}
+
+// SetTokenSource makes requests use the OAuth access tokens of tokens, if it
+// returns any, instead of the static AuthToken.
+func (t *StreamableHTTPTransport) SetTokenSource(tokens func() (string, error)) {
+	t.tokens = tokens
+}
+
+// SecurityWarnings returns the unexpected endpoint changes seen so far.
+func (t *StreamableHTTPTransport) SecurityWarnings() []SecurityWarning {
+	return t.watch.Warnings()
+}
+
+// SessionID returns the session assigned by the upstream, "" if none.
+func (t *StreamableHTTPTransport) SessionID() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sessionID
+}
+
+// Start has nothing to connect: the transport is ready at once, and the
+// connection attempt lasts until ctx ends or the upstream forgets the session.
+func (t *StreamableHTTPTransport) Start(ctx context.Context, onMessage func([]byte), onReady func()) error {
+	fmt.Printf("[StreamableHTTPTransport %s] Using %s\n", t.Config.Name, t.Config.URL)
+	failed := make(chan error, 1)
+	t.mu.Lock()
+	t.ctx = ctx
+	t.onMessage = onMessage
+	t.failed = failed
+	t.sessionID = ""
+	t.listening = false
+	t.mu.Unlock()
+
+	if onReady != nil {
+		go onReady()
+	}
+	select {
+	case <-ctx.Done():
+		t.endSession()
+		return nil
+	case err := <-failed:
+		return err
+	}
+}
+
+// Send POSTs a message. Requests are sent in the background, as the upstream
+// may take until its response to answer the POST; if the POST fails, the
+// request gets an error response so the caller does not wait for its timeout.
+// Notifications and responses are sent synchronously.
+func (t *StreamableHTTPTransport) Send(payload []byte) error {
+	var msg JSONRPCMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	ctx := t.ctx
+	t.mu.Unlock()
+	if ctx == nil {
+		return fmt.Errorf("transport not started")
+	}
+
+	if msg.ID != nil && msg.Method != "" {
+		go func() {
+			defer report.Recover("upstream " + t.Config.Name + " request")
+			if err := t.post(ctx, payload); err != nil && ctx.Err() == nil {
+				fmt.Printf("[StreamableHTTPTransport %s] %s failed: %v\n", t.Config.Name, msg.Method, err)
+				t.deliverError(*msg.ID, err)
+			}
+		}()
+		return nil
+	}
+	if err := t.post(ctx, payload); err != nil {
+		return err
+	}
+	if msg.Method == "notifications/initialized" {
+		t.openStream(ctx)
+	}
+	return nil
+}
+
+// post sends one message and delivers what the upstream answers with.
+func (t *StreamableHTTPTransport) post(ctx context.Context, payload []byte) error {
+	fmt.Printf("[StreamableHTTPTransport %s] POST %s (%d bytes)\n", t.Config.Name, t.Config.URL, len(payload))
+	req, err := http.NewRequestWithContext(ctx, "POST", t.Config.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	session, err := t.setHeaders(req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := t.checkResponse(resp, session); err != nil {
+		return err
+	}
+	if t.watch.pin != "" && resp.TLS == nil {
+		return fmt.Errorf("certificate pinned but the upstream was reached without TLS")
+	}
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		t.mu.Lock()
+		if t.sessionID == "" {
+			t.sessionID = id
+			fmt.Printf("[StreamableHTTPTransport %s] Session %s started\n", t.Config.Name, id)
+		}
+		t.mu.Unlock()
+	}
+	if resp.StatusCode == http.StatusAccepted {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/event-stream":
+		return t.readEvents(resp.Body, nil)
+	case "application/json":
+		body, err := io.ReadAll(io.LimitReader(resp.Body, int64(t.settings.MaxMessageSize)+1))
+		if err != nil {
+			return err
+		}
+		if len(body) > t.settings.MaxMessageSize {
+			return fmt.Errorf("response exceeds %d bytes", t.settings.MaxMessageSize)
+		}
+		t.deliver(body)
+		return nil
+	default:
+		return fmt.Errorf("unexpected response type %q", resp.Header.Get("Content-Type"))
+	}
+}
+
+// setHeaders adds the custom headers, credentials, session and protocol version to req and
+// returns the session it names.
+func (t *StreamableHTTPTransport) setHeaders(req *http.Request) (string, error) {
+	applyHeaders(req, t.headers)
+	if err := setAuthorization(req, t.Config.AuthToken, t.tokens); err != nil {
+		return "", err
+	}
+	if version, _ := t.protocolVersion.Load().(string); protocolAtLeast(version, Protocol20250618) {
+		req.Header.Set("MCP-Protocol-Version", version)
+	}
+	session := t.SessionID()
+	if session != "" {
+		req.Header.Set("Mcp-Session-Id", session)
+	}
+	return session, nil
+}
+
+// checkResponse turns error statuses into errors. A 404 for a request naming a
+// session means the upstream ended it: the connection attempt fails, and the
+// client reconnects and initializes a new session.
+func (t *StreamableHTTPTransport) checkResponse(resp *http.Response, session string) error {
+	if resp.StatusCode == http.StatusNotFound && session != "" {
+		err := fmt.Errorf("upstream ended session %s", session)
+		t.fail(err)
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("upstream returned error: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// readEvents delivers the messages of an SSE stream until it ends. lastID, if
+// not nil, is updated with the ID of every event, to resume the stream.
+func (t *StreamableHTTPTransport) readEvents(body io.Reader, lastID *string) error {
+	events := NewSSEReader(body, t.settings.MaxMessageSize)
+	for {
+		ev, err := events.Next()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if lastID != nil {
+			*lastID = ev.ID
+		}
+		if ev.Event == "message" && len(ev.Data) > 0 {
+			t.deliver([]byte(ev.Data))
+		}
+	}
+}
+
+// deliver passes a message, or each message of a batch, to the client.
+func (t *StreamableHTTPTransport) deliver(data []byte) {
+	t.mu.Lock()
+	onMessage := t.onMessage
+	t.mu.Unlock()
+
+	var batch []json.RawMessage
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' && json.Unmarshal(trimmed, &batch) == nil {
+		for _, msg := range batch {
+			onMessage(msg)
+		}
+		return
+	}
+	onMessage(data)
+}
+
+// deliverError answers the request with the given ID with an error in place of
+// the upstream.
+func (t *StreamableHTTPTransport) deliverError(id json.RawMessage, err error) {
+	msg, _ := json.Marshal(JSONRPCMessage{
+		JSONRPC: "2.0",
+		ID:      &id,
+		Error:   &JSONRPCError{Code: -32603, Message: "Upstream request failed: " + err.Error()},
+	})
+	t.deliver(msg)
+}
+
+func (t *StreamableHTTPTransport) fail(err error) {
+	t.mu.Lock()
+	failed := t.failed
+	t.mu.Unlock()
+	select {
+	case failed <- err:
+	default:
+	}
+}
+
+// openStream opens the GET stream for the messages the upstream sends outside
+// of requests, such as list changes, once per connection attempt.
+func (t *StreamableHTTPTransport) openStream(ctx context.Context) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.listening {
+		return
+	}
+	t.listening = true
+	go t.listen(ctx)
+}
+
+// listen keeps the GET stream open until ctx ends, resuming after the last
+// event when it drops. Upstreams answering 405 offer no such stream.
+func (t *StreamableHTTPTransport) listen(ctx context.Context) {
+	defer report.Recover("upstream " + t.Config.Name + " stream")
+
+	lastID := ""
+	for ctx.Err() == nil {
+		delay := t.settings.ReconnectDelay
+		req, err := http.NewRequestWithContext(ctx, "GET", t.Config.URL, nil)
+		if err != nil {
+			return
+		}
+		req.Header.Set("Accept", "text/event-stream")
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		session, err := t.setHeaders(req)
+		var resp *http.Response
+		if err == nil {
+			resp, err = t.Client.Do(req)
+		}
+		if err == nil {
+			if resp.StatusCode == http.StatusMethodNotAllowed {
+				resp.Body.Close()
+				fmt.Printf("[StreamableHTTPTransport %s] Upstream offers no stream for its own messages\n", t.Config.Name)
+				return
+			}
+			if err = t.checkResponse(resp, session); err == nil {
+				err = t.readEvents(resp.Body, &lastID)
+			}
+			resp.Body.Close()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fmt.Printf("[StreamableHTTPTransport %s] Stream error: %v. Reopening in %s...\n", t.Config.Name, err, delay)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+	}
+}
+
+// endSession tells the upstream the session is over, if it started one. It
+// returns once the upstream was told, even if another caller is telling it.
+func (t *StreamableHTTPTransport) endSession() {
+	t.endMu.Lock()
+	defer t.endMu.Unlock()
+	t.mu.Lock()
+	session := t.sessionID
+	t.sessionID = ""
+	t.mu.Unlock()
+	if session == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "DELETE", t.Config.URL, nil)
+	if err != nil {
+		return
+	}
+	if _, err := t.setHeaders(req); err != nil {
+		return
+	}
+	req.Header.Set("Mcp-Session-Id", session)
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		fmt.Printf("[StreamableHTTPTransport %s] Failed to end session %s: %v\n", t.Config.Name, session, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+func (t *StreamableHTTPTransport) Close() error {
+	t.endSession()
+	return nil
+}
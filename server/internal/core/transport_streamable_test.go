@@ -0,0 +1,139 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// streamableServer is a minimal upstream speaking Streamable HTTP.
+type streamableServer struct {
+	mu       sync.Mutex
+	seq      int
+	sessions map[string]bool
+	deleted  []string
+}
+
+func (s *streamableServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	session := r.Header.Get("Mcp-Session-Id")
+	known := s.sessions[session]
+	s.mu.Unlock()
+
+	switch r.Method {
+	case "GET":
+		if !known {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "id: 1\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/tools/list_changed\"}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		return
+	case "DELETE":
+		s.mu.Lock()
+		delete(s.sessions, session)
+		s.deleted = append(s.deleted, session)
+		s.mu.Unlock()
+		return
+	}
+
+	var msg JSONRPCMessage
+	json.NewDecoder(r.Body).Decode(&msg)
+	if msg.Method == "initialize" {
+		s.mu.Lock()
+		s.seq++
+		session = fmt.Sprintf("s%d", s.seq)
+		s.sessions[session] = true
+		s.mu.Unlock()
+		w.Header().Set("Mcp-Session-Id", session)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"protocolVersion":"2025-03-26","capabilities":{"tools":{}}}}`, *msg.ID)
+		return
+	}
+	if !known {
+		http.NotFound(w, r)
+		return
+	}
+	switch {
+	case msg.ID == nil || msg.Method == "":
+		w.WriteHeader(http.StatusAccepted)
+	case msg.Method == "tools/list":
+		// Streamed, with a log message before the response
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/message\",\"params\":{\"level\":\"info\",\"data\":\"listing\"}}\n\n")
+		fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"id\":%s,\"result\":{\"tools\":[{\"name\":\"search\",\"inputSchema\":{\"type\":\"object\"}}]}}\n\n", *msg.ID)
+	default:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{}}`, *msg.ID)
+	}
+}
+
+func TestStreamableHTTPTransport(t *testing.T) {
+	upstream := &streamableServer{sessions: make(map[string]bool)}
+	srv := httptest.NewServer(upstream)
+	defer srv.Close()
+
+	settings := &config.Config{
+		UpstreamTimeout: 5 * time.Second, InitTimeout: 5 * time.Second,
+		ReconnectDelay: 10 * time.Millisecond, MaxMessageSize: 64 * 1024,
+	}
+	client := NewUpstreamClient(model.UpstreamServer{Name: "remote", TransportType: "streaminghttp", URL: srv.URL + "/mcp"}, settings, nil)
+	transport, ok := client.transport.(*StreamableHTTPTransport)
+	assert.True(t, ok)
+	notifications := make(chan string, 16)
+	client.onNotification = func(c *UpstreamClient, msg *JSONRPCMessage) { notifications <- msg.Method }
+	ready := func() bool { return client.Status().State == StateReady }
+	client.Start()
+
+	assert.Eventually(t, ready, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "s1", transport.SessionID())
+	assert.Equal(t, "2025-03-26", client.ProtocolVersion())
+
+	t.Run("Messages Outside Requests", func(t *testing.T) {
+		select {
+		case method := <-notifications:
+			assert.Equal(t, "notifications/tools/list_changed", method)
+		case <-time.After(2 * time.Second):
+			t.Fatal("no message from the GET stream")
+		}
+	})
+
+	t.Run("Streamed And JSON Responses", func(t *testing.T) {
+		resp, err := client.Call("tools/list", nil)
+		assert.NoError(t, err)
+		assert.Contains(t, string(resp.Result), `"search"`)
+		assert.Equal(t, "notifications/message", <-notifications, "messages of a streamed response precede it")
+
+		resp, err = client.Call("ping", nil)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{}`, string(resp.Result))
+	})
+
+	t.Run("Expired Session", func(t *testing.T) {
+		upstream.mu.Lock()
+		delete(upstream.sessions, "s1")
+		upstream.mu.Unlock()
+
+		resp, err := client.Call("ping", nil)
+		if err == nil {
+			assert.NotNil(t, resp.Error, "the call fails rather than waiting for its timeout")
+		}
+		assert.Eventually(t, func() bool { return ready() && transport.SessionID() == "s2" }, 2*time.Second, 10*time.Millisecond,
+			"the client initializes a new session")
+	})
+
+	client.Stop()
+	upstream.mu.Lock()
+	defer upstream.mu.Unlock()
+	assert.Equal(t, []string{"s2"}, upstream.deleted, "the session is ended on stop")
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStdioCwd(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o600))
+	assert.NoError(t, ValidateCwd(""))
+	assert.NoError(t, ValidateCwd(dir))
+	assert.Error(t, ValidateCwd("relative/dir"))
+	assert.Error(t, ValidateCwd(file))
+	assert.Error(t, ValidateCwd(filepath.Join(dir, "missing")))
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	var lines []string
+	transport := NewStdioTransport(model.UpstreamServer{Name: "files", Command: "pwd", Cwd: dir}, &config.Config{MaxMessageSize: 1024})
+	require.NoError(t, transport.Start(context.Background(), func(msg []byte) { lines = append(lines, string(msg)) }, nil))
+	want, err := filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+	require.Len(t, lines, 1)
+	got, err := filepath.EvalSymlinks(strings.TrimSpace(lines[0]))
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestStdioGracefulStop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stdio processes are killed on Windows")
+	}
+	// The server writes its marker file on SIGTERM, unless it ignores it
+	script := func(onTerm string) string {
+		path := filepath.Join(t.TempDir(), "server.sh")
+		body := "#!/bin/sh\ntrap '" + onTerm + "' TERM\necho ready\nwhile true; do sleep 0.05; done\n"
+		require.NoError(t, os.WriteFile(path, []byte(body), 0o700))
+		return path
+	}
+	start := func(command string, grace time.Duration) (*StdioTransport, context.CancelFunc, <-chan error) {
+		marker := filepath.Join(t.TempDir(), "flushed")
+		transport := NewStdioTransport(model.UpstreamServer{Name: "db", Command: command, Args: `["` + marker + `"]`},
+			&config.Config{MaxMessageSize: 1024, StdioStopGrace: grace})
+		ctx, cancel := context.WithCancel(context.Background())
+		ready := make(chan struct{}, 1)
+		done := make(chan error, 1)
+		go func() {
+			done <- transport.Start(ctx, func([]byte) { ready <- struct{}{} }, nil)
+		}()
+		select {
+		case <-ready:
+		case <-time.After(5 * time.Second):
+			t.Fatal("server not started")
+		}
+		return transport, cancel, done
+	}
+	flushed := func(transport *StdioTransport) bool {
+		var args []string
+		json.Unmarshal([]byte(transport.Config.Args), &args)
+		_, err := os.Stat(args[0])
+		return err == nil
+	}
+
+	t.Run("Close Waits For A Clean Exit", func(t *testing.T) {
+		transport, cancel, done := start(script(`touch "$1"; exit 0`), 5*time.Second)
+		defer cancel()
+		started := time.Now()
+		assert.NoError(t, transport.Close())
+		assert.Less(t, time.Since(started), 5*time.Second)
+		assert.True(t, flushed(transport))
+		<-done
+	})
+
+	t.Run("Ending The Attempt Terminates", func(t *testing.T) {
+		transport, cancel, done := start(script(`touch "$1"; exit 0`), 5*time.Second)
+		cancel()
+		<-done
+		assert.True(t, flushed(transport))
+	})
+
+	t.Run("Killed After The Grace Period", func(t *testing.T) {
+		transport, cancel, done := start(script(""), 100*time.Millisecond)
+		defer cancel()
+		started := time.Now()
+		assert.NoError(t, transport.Close())
+		assert.GreaterOrEqual(t, time.Since(started), 100*time.Millisecond)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("process not killed")
+		}
+		assert.False(t, flushed(transport))
+	})
+}
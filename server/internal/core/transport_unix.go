@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// UnixTransport talks to an MCP server listening on a Unix domain socket, e.g.
+// a sandboxed sidecar, with the framing and encoding of stdio upstreams.
+type UnixTransport struct {
+	Config   model.UpstreamServer
+	settings *config.Config
+
+	mu    sync.Mutex
+	conn  net.Conn
+	codec *messageCodec
+}
+
+func NewUnixTransport(cfg model.UpstreamServer, settings *config.Config) *UnixTransport {
+	return &UnixTransport{
+		Config:   cfg,
+		settings: settings,
+	}
+}
+
+// ValidateSocketPath checks the socket path of a unix upstream.
+func ValidateSocketPath(path string) error {
+	if path == "" {
+		return fmt.Errorf("socket_path is required")
+	}
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("socket_path must be absolute")
+	}
+	return nil
+}
+
+func (t *UnixTransport) Start(ctx context.Context, onMessage func([]byte), onReady func()) error {
+	if err := ValidateSocketPath(t.Config.SocketPath); err != nil {
+		return err
+	}
+	codec, err := newMessageCodec(t.Config.Framing, t.Config.Encoding, t.settings.MaxMessageSize)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[UnixTransport %s] Connecting to %s...\n", t.Config.Name, t.Config.SocketPath)
+	dialer := net.Dialer{Timeout: 30 * time.Second}
+	conn, err := dialer.DialContext(ctx, "unix", t.Config.SocketPath)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.conn = conn
+	t.codec = codec
+	t.mu.Unlock()
+
+	// End the connection with the attempt
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if onReady != nil {
+		go onReady()
+	}
+
+	err = codec.read(conn, onMessage)
+	conn.Close()
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading socket: %v", err)
+	}
+	return fmt.Errorf("socket closed by the server")
+}
+
+func (t *UnixTransport) Send(payload []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn == nil {
+		return fmt.Errorf("socket not connected")
+	}
+	framed, err := t.codec.encode(payload)
+	if err != nil {
+		return err
+	}
+	_, err = t.conn.Write(framed)
+	return err
+}
+
+func (t *UnixTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn != nil {
+		return t.conn.Close()
+	}
+	return nil
+}
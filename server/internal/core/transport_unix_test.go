@@ -0,0 +1,90 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnixTransport(t *testing.T) {
+	assert.Error(t, ValidateSocketPath(""))
+	assert.Error(t, ValidateSocketPath("run/mcp.sock"))
+	assert.NoError(t, ValidateSocketPath("/run/mcp.sock"))
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	// Socket paths are limited to about 100 bytes, longer than some test temp dirs
+	dir, err := os.MkdirTemp("", "mcp")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "mcp.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// The server echoes one line and hangs up
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, err := bufio.NewReader(conn).ReadBytes('\n')
+		if err == nil {
+			conn.Write(line)
+		}
+	}()
+
+	transport := NewUnixTransport(model.UpstreamServer{Name: "sidecar", SocketPath: socket}, &config.Config{MaxMessageSize: 1024})
+	assert.ErrorContains(t, transport.Send([]byte(`{}`)), "not connected")
+
+	received := make(chan string, 1)
+	ready := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- transport.Start(context.Background(), func(msg []byte) { received <- string(msg) }, func() { close(ready) })
+	}()
+	select {
+	case <-ready:
+	case <-time.After(2 * time.Second):
+		t.Fatal("not connected")
+	}
+	require.NoError(t, transport.Send([]byte(`{"jsonrpc":"2.0","method":"ping","id":1}`)))
+	select {
+	case msg := <-received:
+		assert.JSONEq(t, `{"jsonrpc":"2.0","method":"ping","id":1}`, msg)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message")
+	}
+	select {
+	case err := <-done:
+		assert.ErrorContains(t, err, "closed by the server")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Start did not return")
+	}
+
+	// Ending the attempt closes the connection without an error
+	go listener.Accept()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { done <- transport.Start(ctx, func([]byte) {}, cancel) }()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Start did not return")
+	}
+
+	missing := NewUnixTransport(model.UpstreamServer{Name: "gone", SocketPath: filepath.Join(dir, "missing.sock")}, &config.Config{MaxMessageSize: 1024})
+	assert.Error(t, missing.Start(context.Background(), nil, nil))
+}
@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"os"
 	"time"
+	"one-mcp/internal/config"
 	"one-mcp/internal/model"
+	"one-mcp/internal/report"
 )
 
 // JSONRPC types
@@ -21,12 +24,23 @@ type JSONRPCMessage struct {
 }
 
 type JSONRPCError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
 }
 
+// Upstream connection states reported by the status API
+const (
+	StateConnecting = "connecting"
+	StateReady      = "ready"
+	StateError      = "error"    // The connection ended with an error, waiting to reconnect
+	StateFailed     = "failed"   // Initialization timed out or pings went unanswered
+	StateDisabled   = "disabled" // The server is disabled, so not running
+)
+
 type UpstreamClient struct {
 	Config    model.UpstreamServer
+	settings  *config.Config
 	transport Transport
 	
 	ctx       context.Context
@@ -34,39 +48,117 @@ type UpstreamClient struct {
 	mu        sync.RWMutex
 	ready     bool
 
+	// Connection status
+	state         string
+	lastError     string
+	attemptStart  time.Time
+	initDuration  time.Duration
+	attemptCancel context.CancelFunc // Aborts the current transport attempt
+	initialized   bool               // The current attempt completed initialize
+	failures      int                // Attempts in a row that did not complete initialize (see backoff.go)
+	nextRetry     time.Time          // When the next attempt starts, while waiting for it
+
+	protocolVersion string                     // Negotiated in initialize on the current connection
+	listDuration    time.Duration              // Round trip of the last tools/list
+	listError       string                     // Why the last tools/list failed
+	toolCache       []map[string]interface{}   // Tools of the last listing, nil once stale
+	capabilities    map[string]json.RawMessage // Declared in the last successful initialize
+
 	// Request coordination
 	pendingReqs map[string]chan JSONRPCMessage
 	reqMu       sync.Mutex
 	idCounter   int64
+
+	// Fair scheduling of tool calls across keys (nil if unlimited)
+	sched *FairScheduler
+
+	// Tool selection (include/exclude/cap) and its effect on the last listing
+	selector      *ToolSelector
+	cappedTools   map[string]bool // Tools dropped by the max-tools cap
+	filteredCount int
+
+	annotations map[string]ToolAnnotations // Annotations of the tools of the last listing
+
+	asyncTools []string // Glob patterns of tools called asynchronously
+
+	toolTimeouts  map[string]time.Duration // Per-tool overrides of the request timeout (see timeouts.go)
+	toolFallbacks map[string]string        // Per-tool overrides of the fallback server (see fallback.go)
+
+	health callHealth   // Outcomes of the last tool calls, with its own lock (see health.go)
+	load   loadCounters // Requests in flight, timeouts and reconnects (see saturation.go)
+
+	// Set by the gateway before Start
+	onNotification func(c *UpstreamClient, msg *JSONRPCMessage) // Upstream notifications
+	onRequest      func(c *UpstreamClient, msg *JSONRPCMessage) // Requests from the upstream, e.g. sampling
+	onInitialized  func(c *UpstreamClient)                      // Completed initialize, on every (re)connect
+
+	callers   []*Caller // Callers with calls in flight, oldest first (see reverse.go)
+	callersMu sync.Mutex
+
+	taps   map[chan []byte]struct{} // Attached admin consoles
+	tapsMu sync.Mutex
+
+	// Message tracing (see trace.go)
+	traceFile  *os.File
+	traceUntil time.Time
+	traceMu    sync.Mutex
 }
 
-func NewUpstreamClient(cfg model.UpstreamServer) *UpstreamClient {
+func NewUpstreamClient(cfg model.UpstreamServer, settings *config.Config, secrets *SecretStore) *UpstreamClient {
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	var transport Transport
 	switch cfg.TransportType {
 	case "stdio":
-		transport = NewStdioTransport(cfg)
-	case "sse", "streaminghttp": // Treat streaminghttp as SSE
-		transport = NewSSETransport(cfg)
+		transport = NewStdioTransport(cfg, settings)
+	case "sse":
+		transport = NewSSETransport(cfg, settings)
+	case "streaminghttp":
+		transport = NewStreamableHTTPTransport(cfg, settings)
+	case "unix":
+		transport = NewUnixTransport(cfg, settings)
 	case "http":
-		transport = NewHTTPTransport(cfg)
+		transport = NewHTTPTransport(cfg, settings, secrets)
+	case "demo":
+		transport = NewDemoTransport()
 	default:
 		// Default to SSE for backward compatibility
-		transport = NewSSETransport(cfg)
+		transport = NewSSETransport(cfg, settings)
 	}
 
-	return &UpstreamClient{
+	client := &UpstreamClient{
 		Config:      cfg,
+		settings:    settings,
 		transport:   transport,
 		ctx:         ctx,
 		cancel:      cancel,
+		state:       StateConnecting,
 		pendingReqs: make(map[string]chan JSONRPCMessage),
 	}
+	if cfg.MaxConcurrency > 0 {
+		client.sched = NewFairScheduler(cfg.MaxConcurrency)
+	}
+	selector, err := ParseToolSelector(cfg.IncludeTools, cfg.ExcludeTools, cfg.MaxTools)
+	if err != nil {
+		fmt.Printf("[Upstream %s] Ignoring tool selection: %v\n", cfg.Name, err)
+		selector = &ToolSelector{}
+	}
+	client.selector = selector
+	if client.asyncTools, err = ParseAsyncTools(cfg.AsyncTools); err != nil {
+		fmt.Printf("[Upstream %s] Ignoring async tools: %v\n", cfg.Name, err)
+	}
+	if client.toolTimeouts, err = ParseToolTimeouts(cfg); err != nil {
+		fmt.Printf("[Upstream %s] Ignoring tool timeouts: %v\n", cfg.Name, err)
+	}
+	if client.toolFallbacks, err = ParseToolFallbacks(cfg); err != nil {
+		fmt.Printf("[Upstream %s] Ignoring tool fallbacks: %v\n", cfg.Name, err)
+	}
+	return client
 }
 
 func (c *UpstreamClient) Stop() {
 	c.cancel()
+	c.DisableTrace()
 	c.transport.Close()
 }
 
@@ -82,6 +174,19 @@ func (c *UpstreamClient) IsReady() bool {
 
 // Call performs a synchronous JSON-RPC call to the upstream
 func (c *UpstreamClient) Call(method string, params interface{}) (*JSONRPCMessage, error) {
+	return c.callTimeout(method, params, c.requestTimeout())
+}
+
+func (c *UpstreamClient) callTimeout(method string, params interface{}, timeout time.Duration) (*JSONRPCMessage, error) {
+	return c.callCancelable(method, params, timeout, nil)
+}
+
+// callCancelable is callTimeout that also stops waiting when cancel is closed,
+// telling the upstream with notifications/cancelled.
+func (c *UpstreamClient) callCancelable(method string, params interface{}, timeout time.Duration, cancel <-chan struct{}) (*JSONRPCMessage, error) {
+	if cancelled(cancel) {
+		return nil, errCancelled
+	}
 	if !c.IsReady() && method != "initialize" {
 		return nil, fmt.Errorf("upstream not ready")
 	}
@@ -94,10 +199,8 @@ func (c *UpstreamClient) Call(method string, params interface{}) (*JSONRPCMessag
 	if params != nil {
 		paramsBytes, _ := json.Marshal(params)
 		paramsRaw = paramsBytes
-		fmt.Printf("[Upstream %s] Calling %s with params: %s\n", c.Config.Name, method, string(paramsBytes))
-	} else {
-		fmt.Printf("[Upstream %s] Calling %s without params\n", c.Config.Name, method)
 	}
+	fmt.Printf("[Upstream %s] Calling %s (ID: %s)\n", c.Config.Name, method, idStr)
 	
 	req := JSONRPCMessage{
 		JSONRPC: "2.0",
@@ -118,23 +221,235 @@ func (c *UpstreamClient) Call(method string, params interface{}) (*JSONRPCMessag
 	}()
 
 	payload, _ := json.Marshal(req)
-	if err := c.transport.Send(payload); err != nil {
+	atomic.AddInt64(&c.load.inflight, 1)
+	defer atomic.AddInt64(&c.load.inflight, -1)
+	if err := c.send(payload); err != nil {
 		fmt.Printf("[Upstream %s] Send error: %v\n", c.Config.Name, err)
 		return nil, err
 	}
 
 	select {
 	case resp := <-respChan:
+		if resp.ID == nil {
+			fmt.Printf("[Upstream %s] Connection lost waiting for %s (ID: %s)\n", c.Config.Name, method, idStr)
+			return nil, errConnectionLost
+		}
 		// Log brief response info
 		fmt.Printf("[Upstream %s] Received response for %s (ID: %s)\n", c.Config.Name, method, idStr)
 		if resp.Error != nil {
 			fmt.Printf("[Upstream %s] Response Error: %v\n", c.Config.Name, resp.Error)
 		}
 		return &resp, nil
-	case <-time.After(30 * time.Second):
+	case <-time.After(timeout):
+		atomic.AddInt64(&c.load.timeouts, 1)
 		fmt.Printf("[Upstream %s] Timeout waiting for %s (ID: %s)\n", c.Config.Name, method, idStr)
 		return nil, fmt.Errorf("timeout waiting for upstream response")
+	case <-cancel:
+		fmt.Printf("[Upstream %s] Cancelling %s (ID: %s)\n", c.Config.Name, method, idStr)
+		notice, _ := json.Marshal(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"method":  "notifications/cancelled",
+			"params":  map[string]interface{}{"requestId": idRaw, "reason": "Cancelled by the client"},
+		})
+		if err := c.send(notice); err != nil {
+			fmt.Printf("[Upstream %s] Send error: %v\n", c.Config.Name, err)
+		}
+		return nil, errCancelled
+	}
+}
+
+// CallAs performs Call on behalf of the given key, waiting for a fair-scheduler
+// slot first if the upstream has a concurrency limit.
+func (c *UpstreamClient) CallAs(key string, method string, params interface{}) (*JSONRPCMessage, error) {
+	return c.CallAsTimeout(key, method, params, c.requestTimeout())
+}
+
+// CallAsTimeout is CallAs with a custom response timeout.
+func (c *UpstreamClient) CallAsTimeout(key string, method string, params interface{}, timeout time.Duration) (*JSONRPCMessage, error) {
+	return c.CallAsCancelable(key, method, params, timeout, nil)
+}
+
+// CallAsCancelable is CallAsTimeout that gives up, and cancels the upstream
+// request, when cancel is closed. Time spent waiting for a scheduler slot counts
+// towards the timeout.
+func (c *UpstreamClient) CallAsCancelable(key string, method string, params interface{}, timeout time.Duration, cancel <-chan struct{}) (*JSONRPCMessage, error) {
+	if c.sched == nil {
+		return c.callCancelable(method, params, timeout, cancel)
+	}
+	started := time.Now()
+	if err := c.sched.Acquire(key, timeout, cancel); err != nil {
+		fmt.Printf("[Upstream %s] Key %s could not get a call slot: %v\n", c.Config.Name, key, err)
+		return nil, err
+	}
+	defer c.sched.Release()
+	return c.callCancelable(method, params, timeout-time.Since(started), cancel)
+}
+
+// SchedulerStats returns per-key queueing metrics, or nil if the upstream is unlimited.
+func (c *UpstreamClient) SchedulerStats() map[string]SchedulerKeyStats {
+	if c.sched == nil {
+		return nil
+	}
+	return c.sched.Stats()
+}
+
+// listTools fetches the upstream's tools, recording how long the listing took
+// and whether it failed for the health view.
+func (c *UpstreamClient) listTools() ([]map[string]interface{}, error) {
+	started := time.Now()
+	tools, err := c.fetchTools()
+	c.mu.Lock()
+	c.listDuration = time.Since(started)
+	c.listError = ""
+	c.toolCache = nil
+	if err != nil {
+		c.listError = err.Error()
+	} else {
+		c.toolCache = copyTools(tools)
+	}
+	c.mu.Unlock()
+	return tools, err
+}
+
+// cachedTools returns the tools of the last listing if cache is set and the
+// upstream has not reported a change or reconnected since, listing them otherwise.
+func (c *UpstreamClient) cachedTools(cache bool) ([]map[string]interface{}, error) {
+	if cache {
+		c.mu.RLock()
+		tools := c.toolCache
+		c.mu.RUnlock()
+		if tools != nil {
+			return copyTools(tools), nil
+		}
 	}
+	return c.listTools()
+}
+
+// dropToolCache marks the cached listing as stale.
+func (c *UpstreamClient) dropToolCache() {
+	c.mu.Lock()
+	c.toolCache = nil
+	c.mu.Unlock()
+}
+
+// copyTools copies the tool objects of a listing, which aggregation modifies.
+func copyTools(tools []map[string]interface{}) []map[string]interface{} {
+	copied := make([]map[string]interface{}, len(tools))
+	for i, tool := range tools {
+		copied[i] = make(map[string]interface{}, len(tool))
+		for k, v := range tool {
+			copied[i][k] = v
+		}
+	}
+	return copied
+}
+
+// fetchTools fetches every page of the upstream's tools/list, retrying the first
+// request with other empty params for strict servers.
+func (c *UpstreamClient) fetchTools() ([]map[string]interface{}, error) {
+	var cursor string
+	var tools []map[string]interface{}
+	for {
+		var resp *JSONRPCMessage
+		var err error
+		
+		if cursor == "" {
+			// Try sending nil first (no params)
+			resp, err = c.Call("tools/list", nil)
+		} else {
+			resp, err = c.Call("tools/list", map[string]string{"cursor": cursor})
+		}
+		
+		if err != nil {
+			return nil, err
+		}
+		
+		if resp.Error != nil {
+			// Fallback Strategy for strict servers
+			// 1. Try {} (empty object)
+			// 2. Try {"cursor": null} (explicit null cursor)
+			
+			if cursor == "" && resp.Error.Code == -32602 {
+				fmt.Printf("[Gateway] Upstream %s refused nil params, retrying with {}\n", c.Config.Name)
+				resp, err = c.Call("tools/list", map[string]interface{}{})
+				if err == nil && resp.Error != nil && resp.Error.Code == -32602 {
+					fmt.Printf("[Gateway] Upstream %s refused {}, retrying with {\"cursor\": null}\n", c.Config.Name)
+					resp, err = c.Call("tools/list", map[string]interface{}{"cursor": nil})
+				}
+				
+				if err != nil {
+					return nil, err
+				}
+				if resp.Error != nil {
+					fmt.Printf("[Gateway] Upstream %s failed all param attempts: %v\n", c.Config.Name, resp.Error)
+					return nil, fmt.Errorf("tools/list failed: %s", resp.Error.Message)
+				}
+			} else {
+				fmt.Printf("[Gateway] Upstream %s returned error for tools/list: %v\n", c.Config.Name, resp.Error)
+				return nil, fmt.Errorf("tools/list failed: %s", resp.Error.Message)
+			}
+		}
+		
+		var result struct {
+			Tools      []map[string]interface{} `json:"tools"`
+			NextCursor string                   `json:"nextCursor"`
+		}
+		if err := json.Unmarshal(resp.Result, &result); err != nil {
+			return nil, err
+		}
+
+		tools = append(tools, result.Tools...)
+
+		if result.NextCursor == "" {
+			break
+		}
+		cursor = result.NextCursor
+	}
+	return tools, nil
+}
+
+// selectTools applies the tool selection to one full listing of the upstream's
+// tools and remembers which tools were hidden.
+func (c *UpstreamClient) selectTools(tools []map[string]interface{}) []map[string]interface{} {
+	selected := make([]map[string]interface{}, 0, len(tools))
+	capped := make(map[string]bool)
+	annotations := make(map[string]ToolAnnotations, len(tools))
+	for _, tool := range tools {
+		name, _ := tool["name"].(string)
+		annotations[name] = parseAnnotations(tool)
+		if !c.selector.Matches(name) {
+			continue
+		}
+		if c.selector.Max > 0 && len(selected) >= c.selector.Max {
+			capped[name] = true
+			continue
+		}
+		selected = append(selected, tool)
+	}
+
+	c.mu.Lock()
+	c.cappedTools = capped
+	c.filteredCount = len(tools) - len(selected)
+	c.annotations = annotations
+	c.mu.Unlock()
+	return selected
+}
+
+// ToolSelected reports whether a tool is exposed by the gateway.
+func (c *UpstreamClient) ToolSelected(name string) bool {
+	if !c.selector.Matches(name) {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return !c.cappedTools[name]
+}
+
+// FilteredCount returns how many tools the last listing hid.
+func (c *UpstreamClient) FilteredCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.filteredCount
 }
 
 func (c *UpstreamClient) connectLoop() {
@@ -144,45 +459,298 @@ func (c *UpstreamClient) connectLoop() {
 			return
 		default:
 			fmt.Printf("[Upstream %s] Transport starting...\n", c.Config.Name)
-			err := c.transport.Start(c.ctx, c.handleMessage, c.onTransportReady)
+			err := c.runTransport()
 			
 			c.mu.Lock()
 			c.ready = false
+			if c.initialized {
+				c.failures = 0
+			} else {
+				c.failures++
+			}
+			failures := c.failures
+			if c.state != StateFailed {
+				c.state = StateConnecting
+				if err != nil {
+					c.state = StateError
+					c.lastError = err.Error()
+				} else if failures > 0 {
+					c.state = StateError
+					c.lastError = "connection closed before initialization"
+				}
+			}
+			cause := c.lastError
 			c.mu.Unlock()
-			
-			if err != nil {
-				if c.ctx.Err() == nil {
-					fmt.Printf("[Upstream %s] Transport error: %v. Retrying in 5s...\n", c.Config.Name, err)
-					time.Sleep(5 * time.Second)
+			// Requests sent on the ended connection will never be answered
+			c.failPending()
+			if c.ctx.Err() != nil {
+				return
+			}
+
+			switch {
+			case c.givenUp(failures):
+				c.mu.Lock()
+				c.state = StateFailed
+				c.mu.Unlock()
+				probe := c.settings.ReconnectProbe
+				if failures == c.settings.ReconnectTries+1 {
+					fmt.Printf("[Upstream %s] Giving up after %d failed attempts: %s\n", c.Config.Name, failures, cause)
+					report.Error("upstream "+c.Config.Name, fmt.Errorf("giving up after %d failed attempts: %s", failures, cause))
+				} else {
+					fmt.Printf("[Upstream %s] Recovery attempt failed: %s\n", c.Config.Name, cause)
 				}
-			} else {
+				if probe <= 0 {
+					// Until the server is saved or re-enabled
+					<-c.ctx.Done()
+					return
+				}
+				fmt.Printf("[Upstream %s] Next recovery attempt in %s\n", c.Config.Name, probe)
+				if !c.waitRetry(probe) {
+					return
+				}
+			case err == nil && failures == 0:
 				fmt.Printf("[Upstream %s] Transport stopped normally.\n", c.Config.Name)
-				if c.ctx.Err() == nil {
-					time.Sleep(1 * time.Second)
+				if !c.waitRetry(time.Second) {
+					return
+				}
+			default:
+				delay := backoffDelay(c.settings.ReconnectDelay, c.settings.ReconnectMax, failures)
+				fmt.Printf("[Upstream %s] Transport error: %s. Retrying in %s...\n", c.Config.Name, cause, delay.Round(time.Millisecond))
+				if !c.waitRetry(delay) {
+					return
 				}
 			}
 		}
 	}
 }
 
+// runTransport runs the transport until it ends, turning a panic into an error
+// so that connectLoop keeps reconnecting.
+func (c *UpstreamClient) runTransport() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			report.Panic("upstream "+c.Config.Name, r)
+			err = fmt.Errorf("transport panic: %v", r)
+		}
+	}()
+
+	attemptCtx, attemptCancel := context.WithCancel(c.ctx)
+	defer attemptCancel()
+
+	atomic.AddInt64(&c.load.attempts, 1)
+	c.mu.Lock()
+	c.state = StateConnecting
+	c.attemptStart = time.Now()
+	c.attemptCancel = attemptCancel
+	c.initialized = false
+	c.nextRetry = time.Time{}
+	c.mu.Unlock()
+
+	// Give up on attempts that do not complete initialization in time
+	timeout := c.initTimeout()
+	timer := time.AfterFunc(timeout, func() {
+		c.mu.RLock()
+		state := c.state
+		c.mu.RUnlock()
+		if state == StateConnecting {
+			c.markFailed(fmt.Errorf("initialization timed out after %s", timeout))
+		}
+	})
+	defer timer.Stop()
+
+	if c.settings.PingInterval > 0 {
+		go c.watchdog(attemptCtx)
+	}
+
+	return c.transport.Start(attemptCtx, c.handleMessage, c.onTransportReady)
+}
+
+// watchdog pings the upstream every UPSTREAM_PING_INTERVAL while the connection
+// attempt lasts. After UPSTREAM_PING_MISSES unanswered pings in a row the
+// connection is considered dead and restarted. Any response, even an error,
+// counts as an answer.
+func (c *UpstreamClient) watchdog(ctx context.Context) {
+	defer report.Recover("upstream " + c.Config.Name + " watchdog")
+
+	ticker := time.NewTicker(c.settings.PingInterval)
+	defer ticker.Stop()
+	missed := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		c.mu.RLock()
+		state := c.state
+		c.mu.RUnlock()
+		if state != StateReady {
+			continue
+		}
+
+		if _, err := c.callTimeout("ping", nil, c.settings.PingInterval); err != nil {
+			missed++
+			fmt.Printf("[Upstream %s] Ping failed (%d/%d): %v\n", c.Config.Name, missed, c.settings.PingMisses, err)
+			if missed >= c.settings.PingMisses {
+				c.markFailed(fmt.Errorf("no answer to %d pings", missed))
+				return
+			}
+			continue
+		}
+		missed = 0
+	}
+}
+
+// errConnectionLost fails requests whose connection ended before they were answered.
+var errConnectionLost = fmt.Errorf("upstream connection lost")
+
+// failPending makes every request awaiting a response fail with errConnectionLost,
+// so that callers do not wait for their timeout. They are sent a message without
+// ID, which no upstream response has.
+func (c *UpstreamClient) failPending() {
+	c.reqMu.Lock()
+	defer c.reqMu.Unlock()
+	for _, ch := range c.pendingReqs {
+		select {
+		case ch <- JSONRPCMessage{}:
+		default:
+		}
+	}
+}
+
+// initTimeout returns the upstream's initialization timeout, falling back to the global one.
+func (c *UpstreamClient) initTimeout() time.Duration {
+	if c.Config.InitTimeout > 0 {
+		return time.Duration(c.Config.InitTimeout) * time.Second
+	}
+	return c.settings.InitTimeout
+}
+
+// markFailed records a failed connection attempt and aborts it so connectLoop retries.
+func (c *UpstreamClient) markFailed(err error) {
+	c.mu.Lock()
+	c.state = StateFailed
+	c.lastError = err.Error()
+	cancel := c.attemptCancel
+	c.mu.Unlock()
+
+	fmt.Printf("[Upstream %s] Marked failed: %v\n", c.Config.Name, err)
+	if cancel != nil {
+		cancel()
+	}
+	c.transport.Close()
+}
+
 func (c *UpstreamClient) onTransportReady() {
+	defer report.Recover("upstream " + c.Config.Name + " initialize")
+
 	c.mu.Lock()
 	c.ready = true
 	c.mu.Unlock()
 	
 	fmt.Printf("[Upstream %s] Transport ready. Initializing...\n", c.Config.Name)
-	c.initialize()
+	if err := c.initialize(); err != nil {
+		c.markFailed(err)
+		return
+	}
+
+	c.mu.Lock()
+	c.state = StateReady
+	c.lastError = ""
+	c.initialized = true
+	c.failures = 0
+	c.initDuration = time.Since(c.attemptStart)
+	c.mu.Unlock()
+	fmt.Printf("[Upstream %s] Ready after %s\n", c.Config.Name, c.initDuration)
+
+	if c.onInitialized != nil {
+		c.onInitialized(c)
+	}
+}
+
+// UpstreamStatus is the connection status of an upstream as reported by the status API.
+type UpstreamStatus struct {
+	ID              uint       `json:"id"`
+	Name            string     `json:"name"`
+	State           string     `json:"state"`
+	InitDurationMs  int64      `json:"init_duration_ms"` // Start-to-ready time of the current connection
+	LastError       string     `json:"last_error,omitempty"`
+	ProtocolVersion string     `json:"protocol_version,omitempty"` // Negotiated with the upstream
+	TraceUntil      *time.Time `json:"trace_until,omitempty"`      // Set while message tracing is enabled
+	ListDurationMs  int64      `json:"list_duration_ms"`           // Round trip of the last tools/list, with every page
+	ListError       string     `json:"list_error,omitempty"`       // Why the last tools/list failed
+	LastSuccessAt   *time.Time `json:"last_success_at,omitempty"`  // End of the last successful tool call
+	Failures        int        `json:"failures"`                   // Connection attempts in a row that failed
+	NextRetryAt     *time.Time `json:"next_retry_at,omitempty"`    // Start of the next connection attempt, while waiting for it
+	GaveUp          bool       `json:"gave_up,omitempty"`          // Over RECONNECT_MAX_RETRIES, only recovery attempts are made
+
+	Health           UpstreamHealth     `json:"health"`                      // Judged on the recent tool calls (see health.go)
+	Saturation       UpstreamSaturation `json:"saturation"`                  // Current load, timeouts and reconnects (see saturation.go)
+	SecurityWarnings []SecurityWarning  `json:"security_warnings,omitempty"` // Unexpected endpoint changes (see endpoint_watch.go)
+}
+
+func (c *UpstreamClient) Status() UpstreamStatus {
+	var traceUntil *time.Time
+	if until := c.TraceUntil(); !until.IsZero() && time.Now().Before(until) {
+		traceUntil = &until
+	}
+	var warnings []SecurityWarning
+	if t, ok := c.transport.(interface{ SecurityWarnings() []SecurityWarning }); ok {
+		warnings = t.SecurityWarnings()
+	}
+	health := c.Health()
+	saturation := c.Saturation()
+	var lastSuccess *time.Time
+	if at := c.health.lastSuccessAt(); !at.IsZero() {
+		lastSuccess = &at
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var nextRetry *time.Time
+	if !c.nextRetry.IsZero() {
+		next := c.nextRetry
+		nextRetry = &next
+	}
+	return UpstreamStatus{
+		ID:              c.Config.ID,
+		Name:            c.Config.Name,
+		State:           c.state,
+		InitDurationMs:  c.initDuration.Milliseconds(),
+		LastError:       c.lastError,
+		ProtocolVersion: c.protocolVersion,
+		TraceUntil:      traceUntil,
+		ListDurationMs:  c.listDuration.Milliseconds(),
+		ListError:       c.listError,
+		LastSuccessAt:   lastSuccess,
+		Failures:        c.failures,
+		NextRetryAt:     nextRetry,
+		GaveUp:          c.givenUp(c.failures),
+
+		Health:           health,
+		Saturation:       saturation,
+		SecurityWarnings: warnings,
+	}
 }
 
-func (c *UpstreamClient) initialize() {
+// ProtocolVersion returns the protocol version negotiated with the upstream, or ""
+// before the first initialize completes. Features newer than it must not be used.
+func (c *UpstreamClient) ProtocolVersion() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.protocolVersion
+}
+
+func (c *UpstreamClient) initialize() error {
 	// Send initialize request to upstream to identify ourselves
 	initParams := map[string]interface{}{
-		"protocolVersion": "2024-11-05",
+		"protocolVersion": LatestProtocolVersion,
 		"capabilities": map[string]interface{}{
 			"roots": map[string]interface{}{
 				"listChanged": true,
 			},
-			"sampling": map[string]interface{}{},
+			"sampling":    map[string]interface{}{},
+			"elicitation": map[string]interface{}{},
 		},
 		"clientInfo": map[string]interface{}{
 			"name":    "one-mcp-gateway",
@@ -193,12 +761,30 @@ func (c *UpstreamClient) initialize() {
 	resp, err := c.Call("initialize", initParams)
 	if err != nil {
 		fmt.Printf("[Upstream %s] Initialization failed: %v\n", c.Config.Name, err)
-		return
+		return err
 	}
 	
 	if resp.Error != nil {
 		fmt.Printf("[Upstream %s] Initialization error: %v\n", c.Config.Name, resp.Error)
-		return
+		return fmt.Errorf("initialize: %s", resp.Error.Message)
+	}
+
+	// The upstream answers with the version it will speak, which may be older
+	var result struct {
+		ProtocolVersion string                     `json:"protocolVersion"`
+		Capabilities    map[string]json.RawMessage `json:"capabilities"`
+	}
+	json.Unmarshal(resp.Result, &result)
+	if !SupportedProtocolVersion(result.ProtocolVersion) {
+		return fmt.Errorf("unsupported protocol version %q", result.ProtocolVersion)
+	}
+	c.mu.Lock()
+	c.protocolVersion = result.ProtocolVersion
+	c.capabilities = result.Capabilities
+	c.toolCache = nil // The tools may differ on the new connection
+	c.mu.Unlock()
+	if t, ok := c.transport.(interface{ SetProtocolVersion(string) }); ok {
+		t.SetProtocolVersion(result.ProtocolVersion)
 	}
 	
 	// Send initialized notification
@@ -207,19 +793,31 @@ func (c *UpstreamClient) initialize() {
 		Method:  "notifications/initialized",
 	}
 	payload, _ := json.Marshal(notifyReq)
-	c.transport.Send(payload)
+	c.send(payload)
 	
-	fmt.Printf("[Upstream %s] Initialized successfully\n", c.Config.Name)
+	fmt.Printf("[Upstream %s] Initialized successfully (protocol %s)\n", c.Config.Name, result.ProtocolVersion)
+	return nil
 }
 
 func (c *UpstreamClient) handleMessage(msg []byte) {
-	fmt.Printf("[Upstream %s] Received: %s\n", c.Config.Name, string(msg))
+	c.traceFrame("<<", msg)
 	var resp JSONRPCMessage
 	if err := json.Unmarshal(msg, &resp); err != nil {
 		fmt.Printf("[Upstream %s] Error parsing JSON: %v\n", c.Config.Name, err)
 		return
 	}
 
+	if resp.ID != nil && resp.Method != "" {
+		// Request from the upstream, answered asynchronously
+		c.publishTap(msg)
+		if c.onRequest != nil {
+			go c.onRequest(c, &resp)
+		} else {
+			c.respond(resp.ID, nil, &JSONRPCError{Code: -32601, Message: "Method not found"})
+		}
+		return
+	}
+
 	if resp.ID != nil {
 		// Response to a request
 		var idVal interface{}
@@ -237,6 +835,43 @@ func (c *UpstreamClient) handleMessage(msg []byte) {
 			ch <- resp
 		}
 	} else {
-		// Notification - TODO: forward to gateway if needed
+		// Notification
+		c.publishTap(msg)
+		if resp.Method != "" && c.onNotification != nil {
+			c.onNotification(c, &resp)
+		}
+	}
+}
+
+// Tap subscribes to notifications sent by the upstream, for the admin console.
+// The returned function unsubscribes.
+func (c *UpstreamClient) Tap() (<-chan []byte, func()) {
+	ch := make(chan []byte, 32)
+	c.tapsMu.Lock()
+	if c.taps == nil {
+		c.taps = make(map[chan []byte]struct{})
+	}
+	c.taps[ch] = struct{}{}
+	c.tapsMu.Unlock()
+	return ch, func() {
+		c.tapsMu.Lock()
+		delete(c.taps, ch)
+		c.tapsMu.Unlock()
+	}
+}
+
+func (c *UpstreamClient) publishTap(msg []byte) {
+	c.tapsMu.Lock()
+	defer c.tapsMu.Unlock()
+	for ch := range c.taps {
+		select {
+		case ch <- msg:
+		default: // Slow console, drop
+		}
 	}
 }
+
+// SendRaw sends a message to the upstream as is, for notifications from the admin console.
+func (c *UpstreamClient) SendRaw(payload []byte) error {
+	return c.send(payload)
+}
@@ -0,0 +1,491 @@
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// authorizationTTL is how long an admin has to complete an authorization
+// flow once started.
+const authorizationTTL = 10 * time.Minute
+
+// refreshMargin is how long before they expire access tokens are refreshed.
+const refreshMargin = time.Minute
+
+// UpstreamAuth runs the OAuth 2.1 authorization code flow (with PKCE) for
+// upstreams that require it, as MCP servers do: the authorization server is
+// discovered from the upstream's protected resource metadata (RFC 9728) and
+// its own metadata (RFC 8414), and the gateway registers itself dynamically
+// (RFC 7591) if no client ID is configured. The tokens are stored as an
+// UpstreamToken and refreshed shortly before they expire.
+type UpstreamAuth struct {
+	db     *gorm.DB
+	client *http.Client
+
+	mu        sync.Mutex
+	pending   map[string]*pendingAuthorization // By state
+	tokens    map[uint]*model.UpstreamToken    // Loaded tokens by server ID, nil if none
+	refreshMu map[uint]*sync.Mutex             // Held while the tokens of a server are refreshed
+}
+
+type pendingAuthorization struct {
+	server      model.UpstreamServer
+	redirectURI string
+	verifier    string // PKCE code verifier
+	metadata    authServerMetadata
+	clientID    string
+	secret      string
+	expires     time.Time
+}
+
+// authServerMetadata is the part of the authorization server metadata the
+// flow uses.
+type authServerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	RegistrationEndpoint  string `json:"registration_endpoint"`
+}
+
+// tokenResponse is the answer of the token endpoint, successful or not.
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	Scope            string `json:"scope"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func NewUpstreamAuth(db *gorm.DB, settings *config.Config) *UpstreamAuth {
+	return &UpstreamAuth{
+		db: db,
+		client: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: egressPolicy(settings.EgressAllowlist).transport(),
+		},
+		pending:   make(map[string]*pendingAuthorization),
+		tokens:    make(map[uint]*model.UpstreamToken),
+		refreshMu: make(map[uint]*sync.Mutex),
+	}
+}
+
+// StartAuthorization prepares the authorization of server and returns the
+// URL of the authorization server to open in a browser. The authorization
+// server redirects to redirectURI, whose handler calls CompleteAuthorization.
+func (a *UpstreamAuth) StartAuthorization(server model.UpstreamServer, redirectURI string) (string, error) {
+	if server.TransportType != "sse" && server.TransportType != "streaminghttp" {
+		return "", fmt.Errorf("OAuth is only supported for sse and streaminghttp upstreams")
+	}
+	metadata, err := a.discover(server.URL)
+	if err != nil {
+		return "", err
+	}
+
+	clientID, secret := server.OAuthClientID, server.OAuthClientSecret
+	if clientID == "" {
+		if metadata.RegistrationEndpoint == "" {
+			return "", fmt.Errorf("no OAuth client ID is configured and the authorization server does not support dynamic registration")
+		}
+		if clientID, secret, err = a.register(metadata.RegistrationEndpoint, redirectURI); err != nil {
+			return "", fmt.Errorf("dynamic client registration failed: %v", err)
+		}
+		fmt.Printf("[UpstreamAuth %s] Registered as client %s\n", server.Name, clientID)
+	}
+
+	verifier := randomToken()
+	challenge := sha256.Sum256([]byte(verifier))
+	state := randomToken()
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURI},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+		"resource":              {server.URL},
+	}
+	if server.OAuthScopes != "" {
+		query.Set("scope", server.OAuthScopes)
+	}
+	authURL, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %v", err)
+	}
+	for key, values := range authURL.Query() {
+		if query.Get(key) == "" {
+			query[key] = values
+		}
+	}
+	authURL.RawQuery = query.Encode()
+
+	a.mu.Lock()
+	now := time.Now()
+	for s, p := range a.pending {
+		if now.After(p.expires) {
+			delete(a.pending, s)
+		}
+	}
+	a.pending[state] = &pendingAuthorization{
+		server:      server,
+		redirectURI: redirectURI,
+		verifier:    verifier,
+		metadata:    metadata,
+		clientID:    clientID,
+		secret:      secret,
+		expires:     now.Add(authorizationTTL),
+	}
+	a.mu.Unlock()
+
+	fmt.Printf("[UpstreamAuth %s] Authorization started with %s\n", server.Name, metadata.Issuer)
+	return authURL.String(), nil
+}
+
+// CompleteAuthorization exchanges the code the authorization server
+// redirected with for tokens, stores them and returns the server they are for.
+func (a *UpstreamAuth) CompleteAuthorization(state, code string) (model.UpstreamServer, error) {
+	a.mu.Lock()
+	p, ok := a.pending[state]
+	delete(a.pending, state)
+	a.mu.Unlock()
+	if !ok || time.Now().After(p.expires) {
+		return model.UpstreamServer{}, fmt.Errorf("unknown or expired authorization state")
+	}
+
+	token := &model.UpstreamToken{
+		ServerID:     p.server.ID,
+		TokenURL:     p.metadata.TokenEndpoint,
+		ClientID:     p.clientID,
+		ClientSecret: p.secret,
+		Resource:     p.server.URL,
+	}
+	resp, err := a.requestToken(token, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURI},
+		"code_verifier": {p.verifier},
+	})
+	if err != nil {
+		return p.server, err
+	}
+
+	var existing model.UpstreamToken
+	if a.db.Where("server_id = ?", p.server.ID).First(&existing).Error == nil {
+		token.ID = existing.ID
+		token.CreatedAt = existing.CreatedAt
+	}
+	applyTokenResponse(token, resp)
+	if err := a.db.Save(token).Error; err != nil {
+		return p.server, err
+	}
+	a.mu.Lock()
+	a.tokens[p.server.ID] = token
+	a.mu.Unlock()
+
+	fmt.Printf("[UpstreamAuth %s] Authorized (scope %q)\n", p.server.Name, token.Scope)
+	return p.server, nil
+}
+
+// CancelAuthorization drops a started flow, e.g. when the admin denied it.
+func (a *UpstreamAuth) CancelAuthorization(state string) {
+	a.mu.Lock()
+	delete(a.pending, state)
+	a.mu.Unlock()
+}
+
+// Token returns the stored tokens of a server, nil if it was not authorized.
+func (a *UpstreamAuth) Token(serverID uint) *model.UpstreamToken {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	token, loaded := a.tokens[serverID]
+	if !loaded {
+		var stored model.UpstreamToken
+		if err := a.db.Where("server_id = ?", serverID).First(&stored).Error; err == nil {
+			token = &stored
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		a.tokens[serverID] = token
+	}
+	if token == nil {
+		return nil
+	}
+	copied := *token
+	return &copied
+}
+
+// Forget deletes the tokens of a server, which falls back to its AuthToken.
+func (a *UpstreamAuth) Forget(serverID uint) {
+	a.db.Where("server_id = ?", serverID).Delete(&model.UpstreamToken{})
+	a.mu.Lock()
+	delete(a.tokens, serverID)
+	a.mu.Unlock()
+}
+
+// AccessToken returns a valid access token of a server, refreshing it if it
+// is about to expire, or "" if the server was not authorized.
+func (a *UpstreamAuth) AccessToken(serverID uint) (string, error) {
+	token := a.Token(serverID)
+	if token == nil {
+		return "", nil
+	}
+	if !expiring(token) {
+		return token.AccessToken, nil
+	}
+
+	a.mu.Lock()
+	lock, ok := a.refreshMu[serverID]
+	if !ok {
+		lock = &sync.Mutex{}
+		a.refreshMu[serverID] = lock
+	}
+	a.mu.Unlock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Another call may have refreshed the tokens in the meantime
+	if token = a.Token(serverID); token == nil {
+		return "", nil
+	}
+	if !expiring(token) {
+		return token.AccessToken, nil
+	}
+	if token.RefreshToken == "" {
+		return "", fmt.Errorf("OAuth access token expired: authorize the upstream again")
+	}
+	resp, err := a.requestToken(token, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {token.RefreshToken},
+	})
+	if err != nil {
+		return "", fmt.Errorf("OAuth token refresh failed: %v", err)
+	}
+	applyTokenResponse(token, resp)
+	if err := a.db.Save(token).Error; err != nil {
+		return "", err
+	}
+	a.mu.Lock()
+	a.tokens[serverID] = token
+	a.mu.Unlock()
+	fmt.Printf("[UpstreamAuth] Refreshed the access token of server %d\n", serverID)
+	return token.AccessToken, nil
+}
+
+func expiring(token *model.UpstreamToken) bool {
+	return token.ExpiresAt != nil && time.Until(*token.ExpiresAt) < refreshMargin
+}
+
+// applyTokenResponse stores a token response in token. Refresh tokens are
+// kept unless the authorization server rotated them.
+func applyTokenResponse(token *model.UpstreamToken, resp *tokenResponse) {
+	token.AccessToken = resp.AccessToken
+	if resp.RefreshToken != "" {
+		token.RefreshToken = resp.RefreshToken
+	}
+	token.ExpiresAt = nil
+	if resp.ExpiresIn > 0 {
+		expires := time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+		token.ExpiresAt = &expires
+	}
+	if resp.Scope != "" {
+		token.Scope = resp.Scope
+	}
+}
+
+// requestToken calls the token endpoint with the client credentials of token.
+func (a *UpstreamAuth) requestToken(token *model.UpstreamToken, form url.Values) (*tokenResponse, error) {
+	form.Set("client_id", token.ClientID)
+	if token.ClientSecret != "" {
+		form.Set("client_secret", token.ClientSecret)
+	}
+	if token.Resource != "" {
+		form.Set("resource", token.Resource)
+	}
+	req, err := http.NewRequest("POST", token.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var resp tokenResponse
+	status, err := a.doJSON(req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("%s: %s", resp.Error, resp.ErrorDescription)
+	}
+	if status != http.StatusOK || resp.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint returned %d without an access token", status)
+	}
+	if resp.TokenType != "" && !strings.EqualFold(resp.TokenType, "bearer") {
+		return nil, fmt.Errorf("unsupported token type %q", resp.TokenType)
+	}
+	return &resp, nil
+}
+
+// register registers the gateway as a public client of the authorization
+// server and returns its client ID and secret, if one was issued.
+func (a *UpstreamAuth) register(endpoint, redirectURI string) (string, string, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"client_name":                "one-mcp",
+		"redirect_uris":              []string{redirectURI},
+		"grant_types":                []string{"authorization_code", "refresh_token"},
+		"response_types":             []string{"code"},
+		"token_endpoint_auth_method": "none",
+	})
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var resp struct {
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+	}
+	status, err := a.doJSON(req, &resp)
+	if err != nil {
+		return "", "", err
+	}
+	if status >= 300 || resp.ClientID == "" {
+		return "", "", fmt.Errorf("registration endpoint returned %d without a client ID", status)
+	}
+	return resp.ClientID, resp.ClientSecret, nil
+}
+
+// discover finds the authorization server of an upstream. Upstreams without
+// protected resource metadata are their own authorization server, and those
+// without authorization server metadata use the default endpoints of MCP
+// 2025-03-26: /authorize, /token and /register.
+func (a *UpstreamAuth) discover(upstreamURL string) (authServerMetadata, error) {
+	resource, err := url.Parse(upstreamURL)
+	if err != nil || resource.Host == "" {
+		return authServerMetadata{}, fmt.Errorf("invalid upstream URL %q", upstreamURL)
+	}
+	origin := resource.Scheme + "://" + resource.Host
+
+	issuer := origin
+	var protected struct {
+		AuthorizationServers []string `json:"authorization_servers"`
+	}
+	for _, candidate := range wellKnownURLs(resource, "oauth-protected-resource") {
+		if a.getJSON(candidate, &protected) && len(protected.AuthorizationServers) > 0 {
+			issuer = protected.AuthorizationServers[0]
+			break
+		}
+	}
+
+	issuerURL, err := url.Parse(issuer)
+	if err != nil || issuerURL.Host == "" {
+		return authServerMetadata{}, fmt.Errorf("invalid authorization server %q", issuer)
+	}
+	var metadata authServerMetadata
+	candidates := append(wellKnownURLs(issuerURL, "oauth-authorization-server"), wellKnownURLs(issuerURL, "openid-configuration")...)
+	for _, candidate := range candidates {
+		if a.getJSON(candidate, &metadata) && metadata.AuthorizationEndpoint != "" && metadata.TokenEndpoint != "" {
+			if metadata.Issuer == "" {
+				metadata.Issuer = issuer
+			}
+			return metadata, nil
+		}
+	}
+
+	base := issuerURL.Scheme + "://" + issuerURL.Host
+	return authServerMetadata{
+		Issuer:                issuer,
+		AuthorizationEndpoint: base + "/authorize",
+		TokenEndpoint:         base + "/token",
+		RegistrationEndpoint:  base + "/register",
+	}, nil
+}
+
+// wellKnownURLs returns where a well-known document of u may be: with the
+// path of u appended to the well-known URI first, then at the root.
+func wellKnownURLs(u *url.URL, name string) []string {
+	origin := u.Scheme + "://" + u.Host
+	root := origin + "/.well-known/" + name
+	if path := strings.TrimSuffix(u.Path, "/"); path != "" {
+		return []string{root + path, root}
+	}
+	return []string{root}
+}
+
+// getJSON fetches a JSON document into v and reports whether it succeeded.
+func (a *UpstreamAuth) getJSON(rawURL string, v interface{}) bool {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Accept", "application/json")
+	status, err := a.doJSON(req, v)
+	return err == nil && status == http.StatusOK
+}
+
+// doJSON sends req and decodes the JSON body of the response, whatever its
+// status, into v.
+func (a *UpstreamAuth) doJSON(req *http.Request, v interface{}) (int, error) {
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode < 300 {
+		return resp.StatusCode, fmt.Errorf("invalid JSON response: %v", err)
+	}
+	return resp.StatusCode, nil
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// setAuthorization sets the bearer token of a request to an upstream: its
+// OAuth access token once authorized, or else its static auth token.
+func setAuthorization(req *http.Request, static string, tokens func() (string, error)) error {
+	token := static
+	if tokens != nil {
+		accessToken, err := tokens()
+		if err != nil {
+			return err
+		}
+		if accessToken != "" {
+			token = accessToken
+		}
+	}
+	if token == "" {
+		return nil
+	}
+	// Sanitize the token to prevent header injection
+	token = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, token)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
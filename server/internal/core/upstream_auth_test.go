@@ -0,0 +1,131 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"sync"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestUpstreamAuth(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.UpstreamServer{}, &model.UpstreamToken{}))
+
+	// An MCP server pointing to an authorization server with dynamic registration
+	var mu sync.Mutex
+	var challenge string
+	var forms []url.Values
+	issued := 0
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/.well-known/oauth-protected-resource/mcp", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"authorization_servers": []string{srv.URL + "/auth"}})
+	})
+	mux.HandleFunc("/.well-known/oauth-authorization-server/auth", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 srv.URL + "/auth",
+			"authorization_endpoint": srv.URL + "/auth/authorize?tenant=a",
+			"token_endpoint":         srv.URL + "/auth/token",
+			"registration_endpoint":  srv.URL + "/auth/register",
+		})
+	})
+	mux.HandleFunc("/auth/register", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(201)
+		json.NewEncoder(w).Encode(map[string]string{"client_id": "dyn-client"})
+	})
+	mux.HandleFunc("/auth/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mu.Lock()
+		defer mu.Unlock()
+		forms = append(forms, r.PostForm)
+		if r.PostForm.Get("grant_type") == "authorization_code" {
+			sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+			if r.PostForm.Get("code") != "the-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+				w.WriteHeader(400)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "bad code"})
+				return
+			}
+		}
+		issued++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-" + string(rune('0'+issued)), "token_type": "Bearer",
+			"expires_in": 30, "refresh_token": "refresh-" + string(rune('0'+issued)), "scope": "read",
+		})
+	})
+
+	server := model.UpstreamServer{Name: "saas", TransportType: "streaminghttp", URL: srv.URL + "/mcp", OAuthScopes: "read"}
+	db.Create(&server)
+	auth := NewUpstreamAuth(db, &config.Config{})
+
+	token, err := auth.AccessToken(server.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, token, "servers not authorized use their static token")
+
+	authURL, err := auth.StartAuthorization(server, "https://gw.example.com/api/oauth/callback")
+	require.NoError(t, err)
+	parsed, _ := url.Parse(authURL)
+	query := parsed.Query()
+	assert.Equal(t, "/auth/authorize", parsed.Path)
+	assert.Equal(t, "a", query.Get("tenant"))
+	assert.Equal(t, "dyn-client", query.Get("client_id"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	assert.Equal(t, "read", query.Get("scope"))
+	assert.Equal(t, server.URL, query.Get("resource"))
+	mu.Lock()
+	challenge = query.Get("code_challenge")
+	mu.Unlock()
+
+	_, err = auth.CompleteAuthorization("forged", "the-code")
+	assert.Error(t, err)
+	authorized, err := auth.CompleteAuthorization(query.Get("state"), "the-code")
+	require.NoError(t, err)
+	assert.Equal(t, server.ID, authorized.ID)
+	_, err = auth.CompleteAuthorization(query.Get("state"), "the-code")
+	assert.Error(t, err, "states are used once")
+
+	// Access tokens expiring within refreshMargin are refreshed once
+	token, err = auth.AccessToken(server.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "access-2", token)
+	mu.Lock()
+	assert.Len(t, forms, 2)
+	assert.Equal(t, "refresh_token", forms[1].Get("grant_type"))
+	assert.Equal(t, "refresh-1", forms[1].Get("refresh_token"))
+	assert.Equal(t, "dyn-client", forms[1].Get("client_id"))
+	mu.Unlock()
+
+	var stored model.UpstreamToken
+	db.Where("server_id = ?", server.ID).First(&stored)
+	assert.Equal(t, "refresh-2", stored.RefreshToken)
+	assert.Equal(t, "read", stored.Scope)
+
+	auth.Forget(server.ID)
+	token, _ = auth.AccessToken(server.ID)
+	assert.Empty(t, token)
+
+	t.Run("Authorization Header", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "http://upstream", nil)
+		assert.NoError(t, setAuthorization(req, "static\r\nX-Injected: 1", nil))
+		assert.Equal(t, "Bearer staticX-Injected: 1", req.Header.Get("Authorization"))
+
+		assert.NoError(t, setAuthorization(req, "static", func() (string, error) { return "oauth", nil }))
+		assert.Equal(t, "Bearer oauth", req.Header.Get("Authorization"))
+
+		req.Header.Del("Authorization")
+		assert.NoError(t, setAuthorization(req, "", func() (string, error) { return "", nil }))
+		assert.Empty(t, req.Header.Get("Authorization"))
+	})
+}
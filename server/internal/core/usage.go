@@ -0,0 +1,162 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/model"
+	"one-mcp/internal/telemetry"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// reserveTeamQuota admits a tool call against the daily call quota of the caller's
+// team, or returns an error if the quota is used up. Admitted calls count against
+// the quota until release is called, which must happen once the call's usage is
+// recorded, so concurrent calls cannot overshoot it.
+func (g *Gateway) reserveTeamQuota(caller *Caller) (release func(), err error) {
+	release = func() {}
+	if caller.TeamID == 0 {
+		return release, nil
+	}
+	var team model.Team
+	if err := g.db.First(&team, caller.TeamID).Error; err != nil || team.DailyCallQuota <= 0 {
+		return release, nil
+	}
+
+	g.quotaMu.Lock()
+	defer g.quotaMu.Unlock()
+	dayStart := storedTime(startOfDay(time.Now(), g.Location(team.Timezone)))
+	var used int64
+	g.db.Model(&model.UsageLog{}).Where("team_id = ? AND created_at >= ?", team.ID, dayStart).Count(&used)
+	if used+int64(g.quotaPending[team.ID]) >= int64(team.DailyCallQuota) {
+		return release, fmt.Errorf("team %s exceeded its daily quota of %d calls", team.Name, team.DailyCallQuota)
+	}
+
+	if g.quotaPending == nil {
+		g.quotaPending = make(map[uint]int)
+	}
+	g.quotaPending[team.ID]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.quotaMu.Lock()
+			defer g.quotaMu.Unlock()
+			if g.quotaPending[team.ID]--; g.quotaPending[team.ID] <= 0 {
+				delete(g.quotaPending, team.ID)
+			}
+		})
+	}, nil
+}
+
+// recordUsage writes a usage log entry for a completed tool call and, if call
+// recording is enabled, the full request and response.
+func (g *Gateway) recordUsage(caller *Caller, server string, tool string, args interface{}, resp *JSONRPCMessage, err error, started time.Time) {
+	entry := model.UsageLog{
+		KeyID:      caller.KeyID,
+		TeamID:     caller.TeamID,
+		Server:     server,
+		Tool:       tool,
+		Success:    err == nil && resp != nil && resp.Error == nil && !resultIsError(resp.Result),
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	switch {
+	case err != nil:
+		entry.Error = err.Error()
+	case resp != nil && resp.Error != nil:
+		entry.Error = resp.Error.Message
+	case resp != nil && resultIsError(resp.Result):
+		entry.Error = "tool returned isError"
+	}
+	if entry.Success {
+		entry.CostMicros = g.priceOf(tool)
+	}
+
+	telemetry.RecordToolCall(telemetry.ToolCall{
+		KeyID:    caller.KeyID,
+		TeamID:   caller.TeamID,
+		Server:   server,
+		Tool:     tool,
+		Started:  started,
+		Duration: time.Since(started),
+		Error:    entry.Error,
+	})
+
+	if dbErr := g.db.Create(&entry).Error; dbErr != nil {
+		fmt.Printf("[Gateway] Failed to record usage: %v\n", dbErr)
+		return
+	}
+
+	if !g.Flags().RecordCalls {
+		return
+	}
+	request, _ := json.Marshal(map[string]interface{}{"name": tool, "arguments": args})
+	var response []byte
+	switch {
+	case err != nil:
+		response, _ = json.Marshal(map[string]string{"error": err.Error()})
+	case resp.Error != nil:
+		response, _ = json.Marshal(map[string]interface{}{"error": resp.Error})
+	default:
+		response = resp.Result
+	}
+	recording := model.CallRecording{
+		UsageLogID: entry.ID,
+		Request:    string(request),
+		Response:   string(response),
+	}
+	if len(response) < dedupThreshold {
+		g.db.Create(&recording)
+		return
+	}
+	// The blob and the recording referencing it are written together, so that
+	// pruning never sees the blob unreferenced (see Prune)
+	recording.Response = ""
+	stored := g.db.Transaction(func(tx *gorm.DB) error {
+		hash, err := storeBlob(tx, response)
+		if err != nil {
+			return err
+		}
+		recording.ResponseHash = hash
+		return tx.Create(&recording).Error
+	})
+	if stored != nil {
+		fmt.Printf("[Gateway] Failed to record call: %v\n", stored)
+	}
+}
+
+// dedupThreshold is the size from which recorded responses are stored content-addressed.
+const dedupThreshold = 4 * 1024
+
+// storeBlob stores content once under its SHA-256 hash and returns the hash.
+func storeBlob(db *gorm.DB, content []byte) (string, error) {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	blob := model.ContentBlob{Hash: hash, Size: len(content), Content: string(content)}
+	return hash, db.Clauses(clause.OnConflict{DoNothing: true}).Create(&blob).Error
+}
+
+// RecordedResponse returns the response of a recording, resolving deduplicated content.
+func RecordedResponse(db *gorm.DB, recording *model.CallRecording) string {
+	if recording.ResponseHash == "" {
+		return recording.Response
+	}
+	var blob model.ContentBlob
+	if err := db.First(&blob, "hash = ?", recording.ResponseHash).Error; err != nil {
+		return ""
+	}
+	return blob.Content
+}
+
+// resultIsError reports whether a tools/call result has isError set.
+func resultIsError(result json.RawMessage) bool {
+	var parsed struct {
+		IsError bool `json:"isError"`
+	}
+	json.Unmarshal(result, &parsed)
+	return parsed.IsError
+}
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestTeamQuotaReservation(t *testing.T) {
+	// A file database: every connection of the pool must see the same team
+	dsn := filepath.Join(t.TempDir(), "one-mcp.db") + "?_pragma=busy_timeout(5000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&model.Team{}, &model.UsageLog{}))
+	g := NewGateway(db, &config.Config{})
+	team := model.Team{Name: "ops", DailyCallQuota: 3}
+	db.Create(&team)
+	caller := &Caller{TeamID: team.ID}
+
+	// Calls admitted concurrently count against the quota before they are recorded
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		admitted []func()
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if release, err := g.reserveTeamQuota(caller); err == nil {
+				mu.Lock()
+				admitted = append(admitted, release)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, admitted, 3)
+
+	// Once recorded, the usage log takes over from the reservation
+	db.Create(&model.UsageLog{TeamID: team.ID, Tool: "demo__echo", Success: true})
+	admitted[0]()
+	admitted[0]()
+	_, err = g.reserveTeamQuota(caller)
+	assert.ErrorContains(t, err, "daily quota")
+
+	// A call released without a usage row frees its slot
+	admitted[1]()
+	release, err := g.reserveTeamQuota(caller)
+	assert.NoError(t, err)
+	release()
+	admitted[2]()
+	assert.Empty(t, g.quotaPending)
+
+	release, err = g.reserveTeamQuota(&Caller{})
+	assert.NoError(t, err, "callers without a team are not limited")
+	release()
+}
+
+func TestRecordUsage(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(model.All...))
+	settings := &config.Config{}
+	g := NewGateway(db, settings)
+	caller := &Caller{KeyID: 7, TeamID: 3}
+	result := func(v interface{}) *JSONRPCMessage {
+		raw, _ := json.Marshal(v)
+		return &JSONRPCMessage{Result: raw}
+	}
+	last := func() (model.UsageLog, *model.CallRecording) {
+		var entry model.UsageLog
+		db.Last(&entry)
+		var recording model.CallRecording
+		if db.Where("usage_log_id = ?", entry.ID).First(&recording).Error != nil {
+			return entry, nil
+		}
+		return entry, &recording
+	}
+
+	t.Run("Outcomes", func(t *testing.T) {
+		g.recordUsage(caller, "demo", "demo__echo", nil, result(map[string]string{"text": "hi"}), nil, time.Now())
+		entry, recording := last()
+		assert.True(t, entry.Success)
+		assert.Equal(t, uint(7), entry.KeyID)
+		assert.Equal(t, uint(3), entry.TeamID)
+		assert.Equal(t, "demo__echo", entry.Tool)
+		assert.Nil(t, recording, "nothing is recorded unless enabled")
+
+		g.recordUsage(caller, "demo", "demo__echo", nil, result(map[string]bool{"isError": true}), nil, time.Now())
+		entry, _ = last()
+		assert.False(t, entry.Success)
+		assert.Equal(t, "tool returned isError", entry.Error)
+
+		g.recordUsage(caller, "demo", "demo__echo", nil, &JSONRPCMessage{Error: &JSONRPCError{Code: -32602, Message: "bad args"}}, nil, time.Now())
+		entry, _ = last()
+		assert.Equal(t, "bad args", entry.Error)
+
+		g.recordUsage(caller, "demo", "demo__echo", nil, nil, errors.New("upstream gone"), time.Now())
+		entry, _ = last()
+		assert.False(t, entry.Success)
+		assert.Equal(t, "upstream gone", entry.Error)
+	})
+
+	settings.RecordCalls = true
+
+	t.Run("Recorded Payloads", func(t *testing.T) {
+		args := map[string]interface{}{"text": "hi"}
+		g.recordUsage(caller, "demo", "demo__echo", args, result(map[string]string{"text": "hi"}), nil, time.Now())
+		_, recording := last()
+		if assert.NotNil(t, recording) {
+			assert.JSONEq(t, `{"name": "demo__echo", "arguments": {"text": "hi"}}`, recording.Request)
+			assert.JSONEq(t, `{"text": "hi"}`, recording.Response)
+			assert.Empty(t, recording.ResponseHash)
+		}
+
+		g.recordUsage(caller, "demo", "demo__echo", args, nil, errors.New("upstream gone"), time.Now())
+		_, recording = last()
+		if assert.NotNil(t, recording) {
+			assert.JSONEq(t, `{"error": "upstream gone"}`, recording.Response)
+		}
+	})
+
+	t.Run("Large Responses Stored Once", func(t *testing.T) {
+		large := result(map[string]string{"text": strings.Repeat("x", dedupThreshold)})
+		g.recordUsage(caller, "demo", "demo__echo", nil, large, nil, time.Now())
+		g.recordUsage(caller, "demo", "demo__echo", nil, large, nil, time.Now())
+		_, recording := last()
+		if assert.NotNil(t, recording) {
+			assert.Empty(t, recording.Response)
+			assert.Equal(t, string(large.Result), RecordedResponse(db, recording))
+		}
+		var blobs int64
+		db.Model(&model.ContentBlob{}).Count(&blobs)
+		assert.Equal(t, int64(1), blobs)
+	})
+}
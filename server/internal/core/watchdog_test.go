@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pingTransport answers pings while alive is set.
+type pingTransport struct {
+	client *UpstreamClient
+	alive  bool
+	closed chan struct{}
+}
+
+func (t *pingTransport) Start(ctx context.Context, onMessage func([]byte), onReady func()) error {
+	return nil
+}
+
+func (t *pingTransport) Send(payload []byte) error {
+	var req JSONRPCMessage
+	json.Unmarshal(payload, &req)
+	if req.Method == "ping" && t.alive {
+		resp, _ := json.Marshal(JSONRPCMessage{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage(`{}`)})
+		go t.client.handleMessage(resp)
+	}
+	return nil
+}
+
+func (t *pingTransport) Close() error {
+	close(t.closed)
+	return nil
+}
+
+func TestWatchdog(t *testing.T) {
+	watch := func(alive bool) (*UpstreamClient, *pingTransport) {
+		transport := &pingTransport{alive: alive, closed: make(chan struct{})}
+		client := &UpstreamClient{
+			Config:      model.UpstreamServer{Name: "up"},
+			settings:    &config.Config{PingInterval: 10 * time.Millisecond, PingMisses: 2},
+			transport:   transport,
+			state:       StateReady,
+			ready:       true,
+			pendingReqs: make(map[string]chan JSONRPCMessage),
+		}
+		transport.client = client
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		client.watchdog(ctx)
+		return client, transport
+	}
+
+	t.Run("Answered Pings Keep The Connection", func(t *testing.T) {
+		client, _ := watch(true)
+		assert.Equal(t, StateReady, client.Status().State)
+	})
+
+	t.Run("Missed Pings Restart The Connection", func(t *testing.T) {
+		client, transport := watch(false)
+		status := client.Status()
+		assert.Equal(t, StateFailed, status.State)
+		assert.Equal(t, "no answer to 2 pings", status.LastError)
+		select {
+		case <-transport.closed:
+		default:
+			t.Fatal("transport not closed")
+		}
+	})
+
+	t.Run("Pending Calls Fail When The Connection Ends", func(t *testing.T) {
+		transport := &pingTransport{closed: make(chan struct{})}
+		client := &UpstreamClient{
+			Config:      model.UpstreamServer{Name: "up"},
+			settings:    &config.Config{},
+			transport:   transport,
+			ready:       true,
+			pendingReqs: make(map[string]chan JSONRPCMessage),
+		}
+		go func() {
+			assert.Eventually(t, func() bool {
+				client.reqMu.Lock()
+				defer client.reqMu.Unlock()
+				return len(client.pendingReqs) == 1
+			}, time.Second, time.Millisecond)
+			client.failPending()
+		}()
+
+		started := time.Now()
+		_, err := client.callTimeout("tools/call", nil, time.Minute)
+		assert.Equal(t, errConnectionLost, err)
+		assert.Less(t, time.Since(started), time.Second)
+	})
+}
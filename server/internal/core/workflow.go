@@ -0,0 +1,420 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/model"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WorkflowServer is the reserved server prefix under which composite tools are exposed.
+const WorkflowServer = "workflow"
+
+// WorkflowStep is one tool call of a composite tool. The tool may be another
+// workflow ("workflow__<name>"), as long as no cycle is formed.
+//
+// String argument values are Go templates evaluated against the workflow input and
+// the results of earlier steps, e.g. "{{.input.repo}}" or "{{.steps.issue.json.title}}".
+// An argument of the form {"$from": "steps.issue.json.number"} is replaced by the raw
+// value at that path, preserving its JSON type.
+type WorkflowStep struct {
+	ID        string                 `json:"id"`
+	Tool      string                 `json:"tool"` // Prefixed upstream tool name
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+var workflowNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ParseWorkflowSteps decodes and validates the steps of a workflow definition.
+func ParseWorkflowSteps(raw string) ([]WorkflowStep, error) {
+	var steps []WorkflowStep
+	if err := json.Unmarshal([]byte(raw), &steps); err != nil {
+		return nil, fmt.Errorf("invalid steps: %v", err)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("a workflow needs at least one step")
+	}
+	seen := make(map[string]bool, len(steps))
+	for i, step := range steps {
+		if step.ID == "" || !workflowNamePattern.MatchString(step.ID) {
+			return nil, fmt.Errorf("step %d: id must be non-empty and contain only letters, digits, '_' and '-'", i+1)
+		}
+		if seen[step.ID] {
+			return nil, fmt.Errorf("step %q: duplicate id", step.ID)
+		}
+		seen[step.ID] = true
+		if !strings.Contains(step.Tool, "__") {
+			return nil, fmt.Errorf("step %q: tool must be a prefixed name like server__tool", step.ID)
+		}
+		if nested, ok := strings.CutPrefix(step.Tool, WorkflowServer+"__"); ok && !ValidWorkflowName(nested) {
+			return nil, fmt.Errorf("step %q: invalid workflow name %q", step.ID, nested)
+		}
+		if err := walkWorkflowArgs(step.Arguments, func(s string) error {
+			_, err := template.New("arg").Parse(s)
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("step %q: %v", step.ID, err)
+		}
+	}
+	return steps, nil
+}
+
+// ValidWorkflowName reports whether name can be used as a composite tool name.
+func ValidWorkflowName(name string) bool {
+	return workflowNamePattern.MatchString(name) && !strings.Contains(name, "__")
+}
+
+// WorkflowCycle returns the cycle that saving workflow id (0 for a new one) under
+// name with the given steps would form among the stored workflows, e.g.
+// "a -> b -> a", or "" if there is none. Disabled workflows are included, since
+// they may be enabled later.
+func WorkflowCycle(db *gorm.DB, id uint, name string, steps []WorkflowStep) string {
+	var stored []model.Workflow
+	db.Find(&stored)
+	calls := map[string][]string{name: workflowCalls(steps)}
+	for _, wf := range stored {
+		if wf.ID == id && id != 0 {
+			continue
+		}
+		if steps, err := ParseWorkflowSteps(wf.Steps); err == nil {
+			calls[wf.Name] = workflowCalls(steps)
+		}
+	}
+
+	// Depth-first search from the saved workflow; any cycle reachable from it
+	// must pass through it, since the stored workflows had none before.
+	var path []string
+	var visit func(string) string
+	visit = func(wf string) string {
+		for i, seen := range path {
+			if seen == wf {
+				return strings.Join(append(path[i:], wf), " -> ")
+			}
+		}
+		path = append(path, wf)
+		for _, next := range calls[wf] {
+			if cycle := visit(next); cycle != "" {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		return ""
+	}
+	return visit(name)
+}
+
+// workflowCalls returns the names of the workflows called by steps.
+func workflowCalls(steps []WorkflowStep) []string {
+	var names []string
+	for _, step := range steps {
+		if nested, ok := strings.CutPrefix(step.Tool, WorkflowServer+"__"); ok {
+			names = append(names, nested)
+		}
+	}
+	return names
+}
+
+func walkWorkflowArgs(v interface{}, visit func(string) error) error {
+	switch val := v.(type) {
+	case string:
+		return visit(val)
+	case map[string]interface{}:
+		for _, item := range val {
+			if err := walkWorkflowArgs(item, visit); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range val {
+			if err := walkWorkflowArgs(item, visit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// workflowTools returns the enabled workflows as tool definitions.
+func (g *Gateway) workflowTools(hasPermission func(string, string) bool) []map[string]interface{} {
+	var workflows []model.Workflow
+	g.db.Where("enabled = ?", true).Find(&workflows)
+
+	var tools []map[string]interface{}
+	for _, wf := range workflows {
+		name := WorkflowServer + "__" + wf.Name
+		if !hasPermission(WorkflowServer, name) {
+			continue
+		}
+		schema := map[string]interface{}{"type": "object"}
+		if wf.InputSchema != "" {
+			json.Unmarshal([]byte(wf.InputSchema), &schema)
+		}
+		tools = append(tools, map[string]interface{}{
+			"name":        name,
+			"description": wf.Description,
+			"inputSchema": schema,
+		})
+	}
+	return tools
+}
+
+// WorkflowTraceStep is one executed step in the trace of a workflow run.
+type WorkflowTraceStep struct {
+	Workflow    string    `json:"workflow"` // Workflow the step belongs to
+	Depth       int       `json:"depth"`    // Nesting level, 0 for the called workflow
+	Step        string    `json:"step"`
+	Tool        string    `json:"tool"`
+	StartedAt   time.Time `json:"started_at"`
+	DurationMs  int64     `json:"duration_ms"`
+	ArgsBytes   int       `json:"args_bytes"`
+	ResultBytes int       `json:"result_bytes"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// workflowRun is the state shared by a workflow call and the workflows it calls:
+// the call stack for cycle and depth checks, the step budget and the trace.
+type workflowRun struct {
+	stack []string
+	steps int
+	trace []WorkflowTraceStep
+}
+
+// runWorkflow executes a composite tool. Every step is an ordinary tool call made on
+// behalf of the caller, so permissions, quotas and usage accounting apply per step.
+// Execution stops at the first failing step; the result of the last step is returned.
+// The run is stored with its trace, and its ID added to the result's _meta.
+func (g *Gateway) runWorkflow(req *JSONRPCMessage, caller *Caller, hasPermission func(string, string) bool, name string, input interface{}) (*JSONRPCMessage, error) {
+	var wf model.Workflow
+	if err := g.db.Where("name = ? AND enabled = ?", name, true).First(&wf).Error; err != nil {
+		return &JSONRPCMessage{
+			JSONRPC: "2.0", ID: req.ID,
+			Error: &JSONRPCError{Code: -32602, Message: "Tool not found"},
+		}, nil
+	}
+	if !hasPermission(WorkflowServer, WorkflowServer+"__"+name) {
+		return &JSONRPCMessage{
+			JSONRPC: "2.0", ID: req.ID,
+			Error: &JSONRPCError{Code: -32000, Message: "Permission denied"},
+		}, nil
+	}
+
+	started := time.Now()
+	run := &workflowRun{}
+	resp, runErr := g.executeWorkflow(req, caller, hasPermission, &wf, input, run)
+
+	record := model.WorkflowRun{
+		WorkflowID: wf.ID,
+		Workflow:   wf.Name,
+		KeyID:      caller.KeyID,
+		Status:     "succeeded",
+		Steps:      run.steps,
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	if runErr != nil {
+		record.Status = "failed"
+		record.Error = runErr.Error()
+	}
+	trace, _ := json.Marshal(run.trace)
+	record.Trace = string(trace)
+	if err := g.db.Create(&record).Error; err != nil {
+		fmt.Printf("[Workflow] %s: failed to store run: %v\n", name, err)
+	} else {
+		resp.Result = annotateResult(resp.Result, "workflowRunId", record.ID)
+	}
+	return resp, nil
+}
+
+// executeWorkflow runs the steps of wf within run. It returns the response for the
+// workflow call and, if the workflow failed, the reason.
+func (g *Gateway) executeWorkflow(req *JSONRPCMessage, caller *Caller, hasPermission func(string, string) bool, wf *model.Workflow, input interface{}, run *workflowRun) (*JSONRPCMessage, error) {
+	depth := len(run.stack)
+	run.stack = append(run.stack, wf.Name)
+	defer func() { run.stack = run.stack[:depth] }()
+
+	steps, err := ParseWorkflowSteps(wf.Steps)
+	if err != nil {
+		return workflowError(req, "", err), err
+	}
+
+	data := map[string]interface{}{
+		"input": input,
+		"steps": map[string]interface{}{},
+	}
+	var last *JSONRPCMessage
+	for _, step := range steps {
+		if run.steps >= g.settings.WorkflowMaxSteps {
+			err := fmt.Errorf("step limit of %d reached", g.settings.WorkflowMaxSteps)
+			return workflowError(req, step.ID, err), err
+		}
+		run.steps++
+
+		// The entry is added before running so that nested steps follow it
+		entry := len(run.trace)
+		run.trace = append(run.trace, WorkflowTraceStep{
+			Workflow: wf.Name, Depth: depth, Step: step.ID, Tool: step.Tool, StartedAt: time.Now(),
+		})
+		fail := func(err error) (*JSONRPCMessage, error) {
+			run.trace[entry].DurationMs = time.Since(run.trace[entry].StartedAt).Milliseconds()
+			run.trace[entry].Error = err.Error()
+			return workflowError(req, step.ID, err), fmt.Errorf("step %q: %v", step.ID, err)
+		}
+
+		args, err := resolveWorkflowArgs(step.Arguments, data)
+		if err != nil {
+			return fail(err)
+		}
+		params, _ := json.Marshal(map[string]interface{}{"name": step.Tool, "arguments": args})
+		run.trace[entry].ArgsBytes = len(params)
+		if len(params) > g.settings.WorkflowMaxPayload {
+			return fail(fmt.Errorf("arguments of %d bytes exceed the limit of %d", len(params), g.settings.WorkflowMaxPayload))
+		}
+		stepReq := &JSONRPCMessage{JSONRPC: "2.0", ID: req.ID, Method: "tools/call", Params: params}
+
+		fmt.Printf("[Workflow] %s: running step %s (%s)\n", wf.Name, step.ID, step.Tool)
+		var resp *JSONRPCMessage
+		var nestedErr error // Why a nested workflow failed
+		if name, ok := strings.CutPrefix(step.Tool, WorkflowServer+"__"); ok {
+			nested, err := g.nestedWorkflow(name, hasPermission, run)
+			if err != nil {
+				return fail(err)
+			}
+			resp, nestedErr = g.executeWorkflow(stepReq, caller, hasPermission, nested, args, run)
+		} else if resp, err = g.handleToolCall(stepReq, caller, hasPermission); err != nil {
+			return fail(err)
+		}
+		if resp.Error != nil {
+			return fail(fmt.Errorf("%s", resp.Error.Message))
+		}
+		run.trace[entry].DurationMs = time.Since(run.trace[entry].StartedAt).Milliseconds()
+		run.trace[entry].ResultBytes = len(resp.Result)
+		if resultIsError(resp.Result) {
+			run.trace[entry].Error = resultText(resp.Result)
+			resp.Result = annotateResult(resp.Result, "workflowFailedStep", step.ID)
+			if nestedErr != nil {
+				return resp, fmt.Errorf("step %q: %v", step.ID, nestedErr)
+			}
+			return resp, fmt.Errorf("step %q: tool returned an error", step.ID)
+		}
+		if len(resp.Result) > g.settings.WorkflowMaxPayload {
+			return fail(fmt.Errorf("result of %d bytes exceeds the limit of %d", len(resp.Result), g.settings.WorkflowMaxPayload))
+		}
+
+		data["steps"].(map[string]interface{})[step.ID] = workflowStepData(resp.Result)
+		last = resp
+	}
+
+	return last, nil
+}
+
+// nestedWorkflow loads a workflow called as a step of another one, refusing
+// cycles and nesting deeper than WORKFLOW_MAX_DEPTH.
+func (g *Gateway) nestedWorkflow(name string, hasPermission func(string, string) bool, run *workflowRun) (*model.Workflow, error) {
+	for _, running := range run.stack {
+		if running == name {
+			return nil, fmt.Errorf("workflow cycle: %s -> %s", strings.Join(run.stack, " -> "), name)
+		}
+	}
+	if len(run.stack) >= g.settings.WorkflowMaxDepth {
+		return nil, fmt.Errorf("workflow nesting exceeds the limit of %d", g.settings.WorkflowMaxDepth)
+	}
+
+	var wf model.Workflow
+	if err := g.db.Where("name = ? AND enabled = ?", name, true).First(&wf).Error; err != nil {
+		return nil, fmt.Errorf("workflow %q not found", name)
+	}
+	if !hasPermission(WorkflowServer, WorkflowServer+"__"+name) {
+		return nil, fmt.Errorf("permission denied for workflow %q", name)
+	}
+	return &wf, nil
+}
+
+// workflowStepData exposes a step result to later steps as text, json (the parsed text
+// or structuredContent) and result (the raw tools/call result).
+func workflowStepData(result json.RawMessage) map[string]interface{} {
+	text := resultText(result)
+	var raw map[string]interface{}
+	json.Unmarshal(result, &raw)
+
+	var parsed interface{}
+	if structured, ok := raw["structuredContent"]; ok {
+		parsed = structured
+	} else if json.Unmarshal([]byte(text), &parsed) != nil {
+		parsed = nil
+	}
+	return map[string]interface{}{"text": text, "json": parsed, "result": raw}
+}
+
+func resolveWorkflowArgs(v interface{}, data map[string]interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		if !strings.Contains(val, "{{") {
+			return val, nil
+		}
+		tmpl, err := template.New("arg").Option("missingkey=error").Parse(val)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		return buf.String(), nil
+	case map[string]interface{}:
+		if from, ok := val["$from"].(string); ok && len(val) == 1 {
+			return lookupPath(data, from)
+		}
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			resolved, err := resolveWorkflowArgs(item, data)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = resolved
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			resolved, err := resolveWorkflowArgs(item, data)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// lookupPath resolves a dotted path such as "steps.issue.json.user.login".
+func lookupPath(data interface{}, path string) (interface{}, error) {
+	cur := data
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("path %q: %q is not an object", path, part)
+		}
+		if cur, ok = m[part]; !ok {
+			return nil, fmt.Errorf("path %q: %q not found", path, part)
+		}
+	}
+	return cur, nil
+}
+
+func workflowError(req *JSONRPCMessage, step string, err error) *JSONRPCMessage {
+	text := fmt.Sprintf("Workflow failed: %v", err)
+	if step != "" {
+		text = fmt.Sprintf("Workflow failed at step %q: %v", step, err)
+	}
+	result, _ := json.Marshal(map[string]interface{}{
+		"content": []interface{}{map[string]interface{}{"type": "text", "text": text}},
+		"isError": true,
+	})
+	return &JSONRPCMessage{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-mcp/internal/config"
+	"one-mcp/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestWorkflowArgs(t *testing.T) {
+	result := json.RawMessage(`{"content":[{"type":"text","text":"{\"title\":\"Crash on start\",\"number\":42}"}]}`)
+	data := map[string]interface{}{
+		"input": map[string]interface{}{"channel": "#eng"},
+		"steps": map[string]interface{}{"issue": workflowStepData(result)},
+	}
+
+	args, err := resolveWorkflowArgs(map[string]interface{}{
+		"channel": "{{.input.channel}}",
+		"text":    "Issue: {{.steps.issue.json.title}}",
+		"number":  map[string]interface{}{"$from": "steps.issue.json.number"},
+	}, data)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"channel": "#eng",
+		"text":    "Issue: Crash on start",
+		"number":  float64(42),
+	}, args)
+
+	_, err = resolveWorkflowArgs("{{.steps.missing.text}}", data)
+	assert.Error(t, err)
+}
+
+func TestParseWorkflowSteps(t *testing.T) {
+	_, err := ParseWorkflowSteps(`[{"id":"a","tool":"github__get_issue"},{"id":"b","tool":"slack__post_message"}]`)
+	assert.NoError(t, err)
+
+	_, err = ParseWorkflowSteps(`[]`)
+	assert.Error(t, err)
+	_, err = ParseWorkflowSteps(`[{"id":"a","tool":"github__x"},{"id":"a","tool":"github__y"}]`)
+	assert.Error(t, err, "duplicate ids")
+	_, err = ParseWorkflowSteps(`[{"id":"a","tool":"workflow__other"}]`)
+	assert.NoError(t, err, "nested workflows")
+	_, err = ParseWorkflowSteps(`[{"id":"a","tool":"workflow__bad.name"}]`)
+	assert.Error(t, err, "invalid nested workflow name")
+}
+
+func TestWorkflowCycle(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&model.Workflow{}))
+	db.Create(&model.Workflow{Name: "a", Steps: `[{"id":"s","tool":"workflow__b"}]`})
+	b := model.Workflow{Name: "b", Steps: `[{"id":"s","tool":"demo__echo"}]`}
+	db.Create(&b)
+
+	steps, _ := ParseWorkflowSteps(`[{"id":"s","tool":"workflow__a"}]`)
+	assert.Equal(t, "b -> a -> b", WorkflowCycle(db, b.ID, "b", steps))
+	assert.Equal(t, "c -> c", WorkflowCycle(db, 0, "c", []WorkflowStep{{ID: "s", Tool: "workflow__c"}}))
+	assert.Empty(t, WorkflowCycle(db, 0, "c", steps))
+}
+
+func TestWorkflowGuards(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&model.UpstreamServer{}, &model.MaintenanceWindow{}, &model.UsageLog{},
+		&model.Workflow{}, &model.WorkflowRun{}))
+
+	settings := &config.Config{DemoUpstream: true, UpstreamTimeout: time.Second, InitTimeout: time.Second,
+		ReconnectDelay: time.Second, WorkflowMaxDepth: 2, WorkflowMaxSteps: 3, WorkflowMaxPayload: 200}
+	g := NewGateway(db, settings)
+	g.ReloadUpstreams()
+	defer g.upstreams[0].Stop()
+	assert.Eventually(t, func() bool { return g.upstreams[0].Status().State == StateReady }, time.Second, 10*time.Millisecond)
+
+	echo := `{"id":"%s","tool":"demo__echo","arguments":{"text":"%s"}}`
+	for name, steps := range map[string]string{
+		"inner": "[" + fmt.Sprintf(echo, "say", "{{.input.text}}") + "]",
+		"outer": `[{"id":"nested","tool":"workflow__inner","arguments":{"text":"hi"}},` + fmt.Sprintf(echo, "again", "{{.steps.nested.text}}") + "]",
+		"deep":  `[{"id":"nested","tool":"workflow__outer"}]`,
+		"loop":  `[{"id":"self","tool":"workflow__loop"}]`,
+		"long":  "[" + fmt.Sprintf(echo, "a", "1") + "," + fmt.Sprintf(echo, "b", "2") + "," + fmt.Sprintf(echo, "c", "3") + "," + fmt.Sprintf(echo, "d", "4") + "]",
+		"big":   "[" + fmt.Sprintf(echo, "a", strings.Repeat("x", 300)) + "]",
+	} {
+		db.Create(&model.Workflow{Name: name, Steps: steps, Enabled: true})
+	}
+
+	call := func(name string) (text string, run model.WorkflowRun) {
+		params, _ := json.Marshal(map[string]interface{}{"name": "workflow__" + name, "arguments": map[string]interface{}{}})
+		resp, err := g.handleToolCall(&JSONRPCMessage{JSONRPC: "2.0", Method: "tools/call", Params: params}, &Caller{}, func(string, string) bool { return true })
+		assert.NoError(t, err)
+		db.Last(&run)
+		return resultText(resp.Result), run
+	}
+
+	t.Run("Nested Workflow With Trace", func(t *testing.T) {
+		text, run := call("outer")
+		assert.Equal(t, "hi", text)
+		assert.Equal(t, "succeeded", run.Status)
+		assert.Equal(t, 3, run.Steps)
+
+		var trace []WorkflowTraceStep
+		assert.NoError(t, json.Unmarshal([]byte(run.Trace), &trace))
+		assert.Len(t, trace, 3)
+		assert.Equal(t, []string{"outer/nested", "inner/say", "outer/again"},
+			[]string{trace[0].Workflow + "/" + trace[0].Step, trace[1].Workflow + "/" + trace[1].Step, trace[2].Workflow + "/" + trace[2].Step})
+		assert.Equal(t, 1, trace[1].Depth)
+		assert.NotZero(t, trace[1].ArgsBytes)
+	})
+
+	t.Run("Guards", func(t *testing.T) {
+		for name, want := range map[string]string{
+			"loop": "workflow cycle: loop -> loop",
+			"deep": "nesting exceeds the limit of 2",
+			"long": "step limit of 3 reached",
+			"big":  "exceed the limit of 200",
+		} {
+			text, run := call(name)
+			assert.Contains(t, text, want, name)
+			assert.Equal(t, "failed", run.Status, name)
+			assert.Contains(t, run.Error, want, name)
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package model
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// encryptedPrefix marks column values encrypted with the database encryption key.
+const encryptedPrefix = "enc:v1:"
+
+// encryptedColumns lists the columns holding credentials, by table. Their model
+// fields use the "encrypted" serializer, which only applies when the whole model
+// is written: update them with Save or Updates(struct), never a column map.
+var encryptedColumns = map[string][]string{
+	"upstream_servers": {"auth_token", "env", "headers", "oauth_client_secret", "proxy_url", "tls_client_key"},
+	"api_keys":         {"signing_secret"},
+	"secrets":          {"value"},
+	"upstream_tokens":  {"access_token", "refresh_token", "client_secret"},
+}
+
+var encryption struct {
+	sync.RWMutex
+	aead cipher.AEAD // nil while encryption is disabled
+}
+
+func init() {
+	schema.RegisterSerializer("encrypted", encryptedSerializer{})
+}
+
+// SetEncryptionKey enables encryption of credential columns with AES-256-GCM.
+// The AES key is the SHA-256 of secret, which should be a long random string.
+// An empty secret disables encryption of new values.
+func SetEncryptionKey(secret string) error {
+	encryption.Lock()
+	defer encryption.Unlock()
+	if secret == "" {
+		encryption.aead = nil
+		return nil
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	encryption.aead = aead
+	return nil
+}
+
+func encryptValue(plain string) (string, error) {
+	encryption.RLock()
+	aead := encryption.aead
+	encryption.RUnlock()
+	if aead == nil || plain == "" {
+		return plain, nil
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plain), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptValue returns the plaintext of a stored value. Values without the
+// prefix were stored before encryption was enabled and are returned as is.
+func decryptValue(stored string) (string, error) {
+	if !strings.HasPrefix(stored, encryptedPrefix) {
+		return stored, nil
+	}
+	encryption.RLock()
+	aead := encryption.aead
+	encryption.RUnlock()
+	if aead == nil {
+		return "", fmt.Errorf("column is encrypted but DB_ENCRYPTION_KEY is not set")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedPrefix))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted column")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt column: wrong DB_ENCRYPTION_KEY?")
+	}
+	return string(plain), nil
+}
+
+// encryptedSerializer stores string fields encrypted when a key is set.
+type encryptedSerializer struct{}
+
+func (encryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var stored string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("unexpected value %T in encrypted column %s", dbValue, field.DBName)
+	}
+	plain, err := decryptValue(stored)
+	if err != nil {
+		return fmt.Errorf("%s: %v", field.DBName, err)
+	}
+	return field.Set(ctx, dst, plain)
+}
+
+func (encryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plain, _ := fieldValue.(string)
+	return encryptValue(plain)
+}
+
+// EncryptExisting encrypts credential values stored before encryption was
+// enabled and returns how many were encrypted. It does nothing without a key.
+func EncryptExisting(db *gorm.DB) (int, error) {
+	encryption.RLock()
+	enabled := encryption.aead != nil
+	encryption.RUnlock()
+	if !enabled {
+		return 0, nil
+	}
+
+	count := 0
+	for table, columns := range encryptedColumns {
+		for _, column := range columns {
+			var rows []struct {
+				ID    uint
+				Value string
+			}
+			// Raw table access bypasses the serializer, and includes soft-deleted rows
+			if err := db.Table(table).Select("id, "+column+" AS value").
+				Where(column+" <> '' AND "+column+" NOT LIKE ?", encryptedPrefix+"%").Scan(&rows).Error; err != nil {
+				return count, err
+			}
+			for _, row := range rows {
+				encrypted, err := encryptValue(row.Value)
+				if err != nil {
+					return count, err
+				}
+				if err := db.Table(table).Where("id = ?", row.ID).UpdateColumn(column, encrypted).Error; err != nil {
+					return count, err
+				}
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// EncryptedCount returns how many credential values in the database are encrypted.
+func EncryptedCount(db *gorm.DB) (int64, error) {
+	var total int64
+	for table, columns := range encryptedColumns {
+		for _, column := range columns {
+			var count int64
+			if err := db.Table(table).Where(column+" LIKE ?", encryptedPrefix+"%").Count(&count).Error; err != nil {
+				return total, err
+			}
+			total += count
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestEncryptedColumns(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&UpstreamServer{}, &ApiKey{}, &Secret{}, &UpstreamToken{}))
+	defer SetEncryptionKey("")
+
+	raw := func(table, column string, id uint) string {
+		var value string
+		db.Table(table).Select(column).Where("id = ?", id).Scan(&value)
+		return value
+	}
+
+	// Rows written before encryption was enabled
+	legacy := UpstreamServer{Name: "legacy", AuthToken: "tok-legacy", Env: `{"A":"1"}`}
+	db.Create(&legacy)
+	secret := Secret{Name: "api", Value: "s3cret"}
+	db.Create(&secret)
+	assert.Equal(t, "tok-legacy", raw("upstream_servers", "auth_token", legacy.ID))
+
+	assert.NoError(t, SetEncryptionKey("correct horse battery staple"))
+	count, err := EncryptExisting(db)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	count, _ = EncryptExisting(db)
+	assert.Equal(t, 0, count, "already encrypted values are skipped")
+
+	stored := raw("upstream_servers", "auth_token", legacy.ID)
+	assert.True(t, strings.HasPrefix(stored, encryptedPrefix))
+	assert.NotContains(t, stored, "tok-legacy")
+	assert.True(t, strings.HasPrefix(raw("secrets", "value", secret.ID), encryptedPrefix))
+	encrypted, _ := EncryptedCount(db)
+	assert.Equal(t, int64(3), encrypted)
+
+	// New and updated rows are encrypted, reads are transparent
+	server := UpstreamServer{Name: "new", AuthToken: "tok-new"}
+	db.Create(&server)
+	assert.True(t, strings.HasPrefix(raw("upstream_servers", "auth_token", server.ID), encryptedPrefix))
+	assert.Empty(t, raw("upstream_servers", "env", server.ID), "empty values stay empty")
+	server.AuthToken = "tok-rotated"
+	db.Save(&server)
+	db.Model(&server).Updates(&UpstreamServer{Env: `{"B":"2"}`})
+	assert.True(t, strings.HasPrefix(raw("upstream_servers", "auth_token", server.ID), encryptedPrefix))
+	assert.True(t, strings.HasPrefix(raw("upstream_servers", "env", server.ID), encryptedPrefix))
+
+	var loaded []UpstreamServer
+	assert.NoError(t, db.Order("id").Find(&loaded).Error)
+	assert.Equal(t, "tok-legacy", loaded[0].AuthToken)
+	assert.Equal(t, `{"A":"1"}`, loaded[0].Env)
+	assert.Equal(t, "tok-rotated", loaded[1].AuthToken)
+	var loadedSecret Secret
+	db.First(&loadedSecret, secret.ID)
+	assert.Equal(t, "s3cret", loadedSecret.Value)
+
+	// Without the right key encrypted values cannot be read
+	SetEncryptionKey("wrong")
+	assert.Error(t, db.Find(&loaded).Error)
+	SetEncryptionKey("")
+	assert.ErrorContains(t, db.Find(&loaded).Error, "DB_ENCRYPTION_KEY is not set")
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestDropStaleIndexes(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&Team{}))
+	// As created by versions where team names were unique among deleted teams too
+	assert.NoError(t, db.Exec("CREATE UNIQUE INDEX idx_teams_name ON teams(name)").Error)
+
+	assert.NoError(t, DropStaleIndexes(db))
+	assert.False(t, db.Migrator().HasIndex(&Team{}, "idx_teams_name"))
+	assert.True(t, db.Migrator().HasIndex(&Team{}, "idx_teams_live_name"))
+	assert.NoError(t, DropStaleIndexes(db), "nothing left to drop")
+}
@@ -0,0 +1,260 @@
+package oauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// keysTTL is how long fetched signing keys are used before they are fetched again.
+const keysTTL = time.Hour
+
+// refetchInterval bounds how often an unknown key ID triggers a fetch, so forged
+// tokens cannot make the gateway hammer the authorization server.
+const refetchInterval = time.Minute
+
+// Claims are the parts of a validated access token the gateway uses.
+type Claims struct {
+	Subject string
+	Scopes  []string
+}
+
+// HasScope reports whether the token was granted scope.
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Verifier validates JWT access tokens issued by an OAuth 2.1 authorization
+// server for the gateway: the signature against the issuer's published keys,
+// the issuer, the audience (the gateway's resource identifier) and expiry.
+type Verifier struct {
+	Issuer   string
+	Audience string
+	jwksURL  string // Empty until discovered from the issuer metadata
+	client   *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// NewVerifier returns a verifier for tokens of issuer meant for audience. If
+// jwksURL is empty, it is discovered from the issuer's metadata.
+func NewVerifier(issuer, audience, jwksURL string) *Verifier {
+	return &Verifier{
+		Issuer:   issuer,
+		Audience: audience,
+		jwksURL:  jwksURL,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// LooksLikeJWT reports whether a bearer token has the form of a JWT rather than
+// a static API key.
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Verify validates token and returns its claims.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parsed, err := jwt.Parse(token, v.key,
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(v.Issuer),
+		jwt.WithAudience(v.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+	mapClaims, _ := parsed.Claims.(jwt.MapClaims)
+	claims := &Claims{}
+	claims.Subject, _ = mapClaims.GetSubject()
+
+	// "scope" is a space-separated string (RFC 9068); some servers use "scp"
+	for _, name := range []string{"scope", "scp"} {
+		switch scopes := mapClaims[name].(type) {
+		case string:
+			claims.Scopes = append(claims.Scopes, strings.Fields(scopes)...)
+		case []interface{}:
+			for _, s := range scopes {
+				if str, ok := s.(string); ok {
+					claims.Scopes = append(claims.Scopes, str)
+				}
+			}
+		}
+	}
+	return claims, nil
+}
+
+// key returns the public key a token was signed with, by its key ID.
+func (v *Verifier) key(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key, ok := v.keys[kid]
+	stale := time.Since(v.fetched) > keysTTL
+	if (!ok && time.Since(v.fetched) > refetchInterval) || stale {
+		if err := v.fetchKeys(); err != nil {
+			if !ok {
+				return nil, err
+			}
+			fmt.Printf("[OAuth] Keeping previous signing keys: %v\n", err)
+		}
+		key, ok = v.keys[kid]
+	}
+	if !ok && kid == "" && len(v.keys) == 1 {
+		// Tokens without a key ID are accepted if the issuer has only one key
+		for _, only := range v.keys {
+			key, ok = only, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// fetchKeys loads the issuer's JSON Web Key Set. Called with mu held.
+func (v *Verifier) fetchKeys() error {
+	v.fetched = time.Now()
+	if v.jwksURL == "" {
+		jwksURL, err := v.discover()
+		if err != nil {
+			return err
+		}
+		v.jwksURL = jwksURL
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.getJSON(v.jwksURL, &set); err != nil {
+		return fmt.Errorf("fetching signing keys: %v", err)
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			fmt.Printf("[OAuth] Skipping signing key %q: %v\n", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	v.keys = keys
+	fmt.Printf("[OAuth] Loaded %d signing keys from %s\n", len(keys), v.jwksURL)
+	return nil
+}
+
+// discover finds the JWKS URL in the authorization server metadata (RFC 8414),
+// falling back to OpenID Connect discovery.
+func (v *Verifier) discover() (string, error) {
+	issuer := strings.TrimSuffix(v.Issuer, "/")
+	var lastErr error
+	for _, path := range []string{"/.well-known/oauth-authorization-server", "/.well-known/openid-configuration"} {
+		var metadata struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(issuer+path, &metadata); err != nil {
+			lastErr = err
+			continue
+		}
+		if metadata.JWKSURI != "" {
+			return metadata.JWKSURI, nil
+		}
+		lastErr = fmt.Errorf("%s%s has no jwks_uri", issuer, path)
+	}
+	return "", fmt.Errorf("discovering the signing keys of %s: %v", v.Issuer, lastErr)
+}
+
+func (v *Verifier) getJSON(url string, dst interface{}) error {
+	resp, err := v.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
+
+// jsonWebKey is an RSA or EC public key of a JWKS (RFC 7517).
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
@@ -0,0 +1,96 @@
+package oauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifier(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	var jwksFetches int32
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"issuer": srv.URL, "jwks_uri": srv.URL + "/jwks"})
+		case "/jwks":
+			atomic.AddInt32(&jwksFetches, 1)
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+				"kty": "EC", "kid": "k1", "use": "sig", "crv": "P-256",
+				"x": base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+				"y": base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	v := NewVerifier(srv.URL, "https://gateway.example.com/mcp", "")
+	sign := func(kid string, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		assert.NoError(t, err)
+		return signed
+	}
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss": srv.URL, "aud": "https://gateway.example.com/mcp", "sub": "alice",
+			"exp": time.Now().Add(time.Hour).Unix(), "scope": "mcp:read mcp:write",
+		}
+	}
+
+	claims, err := v.Verify(sign("k1", valid()))
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", claims.Subject)
+	assert.Equal(t, []string{"mcp:read", "mcp:write"}, claims.Scopes)
+	assert.True(t, claims.HasScope("mcp:write"))
+
+	scp := valid()
+	delete(scp, "scope")
+	scp["scp"] = []string{"tools"}
+	claims, err = v.Verify(sign("k1", scp))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tools"}, claims.Scopes)
+
+	for name, mutate := range map[string]func(jwt.MapClaims){
+		"other audience": func(c jwt.MapClaims) { c["aud"] = "https://other.example.com" },
+		"other issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"expired":        func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"no expiry":      func(c jwt.MapClaims) { delete(c, "exp") },
+	} {
+		claims := valid()
+		mutate(claims)
+		_, err := v.Verify(sign("k1", claims))
+		assert.Error(t, err, name)
+	}
+
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodES256, valid()).SignedString(other)
+	_, err = v.Verify(forged)
+	assert.Error(t, err, "signed by another key")
+
+	symmetric, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, valid()).SignedString([]byte("guessable"))
+	_, err = v.Verify(symmetric)
+	assert.ErrorContains(t, err, "signing method HS256 is invalid")
+
+	fetches := atomic.LoadInt32(&jwksFetches)
+	_, err = v.Verify(sign("unknown", valid()))
+	assert.ErrorContains(t, err, "unknown signing key")
+	_, err = v.Verify(sign("unknown", valid()))
+	assert.Error(t, err)
+	assert.Equal(t, fetches, atomic.LoadInt32(&jwksFetches), "unknown key IDs do not refetch within a minute")
+}
@@ -0,0 +1,21 @@
+// Package plugins links build-time plugins into the gateway. A plugin is a Go
+// package that registers custom JSON-RPC methods from init():
+//
+//	func init() {
+//		core.RegisterMethod("org/listDatasets", func(g *core.Gateway, caller *core.Caller, params json.RawMessage) (interface{}, error) {
+//			return map[string]interface{}{"datasets": []string{}}, nil
+//		})
+//	}
+//
+// To enable a plugin, add a file to this package that imports it for its side
+// effects, optionally behind a build tag so it is only linked when asked for:
+//
+//	//go:build orgplugins
+//
+//	package plugins
+//
+//	import _ "example.com/org/mcp-methods"
+//
+// The methods are served to every authenticated key and announced in the
+// experimental capabilities of initialize; handlers check the caller themselves.
+package plugins
@@ -0,0 +1,125 @@
+package report
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// reporter forwards captured panics and errors to an external service.
+// Sentry (via its HTTP store API) and a generic JSON webhook are supported.
+type reporter struct {
+	sentryURL  string // Store endpoint derived from the DSN
+	sentryKey  string
+	webhookURL string
+	client     *http.Client
+}
+
+var active *reporter
+
+// Configure enables error reporting. Both arguments are optional.
+func Configure(sentryDSN string, webhookURL string) error {
+	r := &reporter{
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: 5 * time.Second},
+	}
+	if sentryDSN != "" {
+		// DSN format: https://<key>@<host>/<project>
+		u, err := url.Parse(sentryDSN)
+		if err != nil || u.User == nil {
+			return fmt.Errorf("invalid SENTRY_DSN")
+		}
+		project := strings.Trim(u.Path, "/")
+		if project == "" {
+			return fmt.Errorf("invalid SENTRY_DSN: missing project")
+		}
+		r.sentryKey = u.User.Username()
+		r.sentryURL = fmt.Sprintf("%s://%s/api/%s/store/", u.Scheme, u.Host, project)
+	}
+	if r.sentryURL == "" && r.webhookURL == "" {
+		active = nil
+		return nil
+	}
+	active = r
+	return nil
+}
+
+// Recover captures a panic in the calling goroutine and lets it continue.
+// It must be deferred directly: defer report.Recover("where").
+func Recover(where string) {
+	if r := recover(); r != nil {
+		Panic(where, r)
+	}
+}
+
+// Panic logs and reports a recovered panic value together with the current stack.
+func Panic(where string, value interface{}) {
+	stack := string(debug.Stack())
+	fmt.Printf("[Panic] %s: %v\n%s\n", where, value, stack)
+	send("fatal", where, fmt.Sprintf("panic: %v", value), stack)
+}
+
+// Error reports a non-fatal error.
+func Error(where string, err error) {
+	send("error", where, err.Error(), "")
+}
+
+func send(level, where, message, stack string) {
+	r := active
+	if r == nil {
+		return
+	}
+	go func() {
+		defer func() { recover() }() // Reporting must never take the process down
+
+		eventID := make([]byte, 16)
+		rand.Read(eventID)
+		event := map[string]interface{}{
+			"event_id":  hex.EncodeToString(eventID),
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"level":     level,
+			"platform":  "go",
+			"logger":    where,
+			"message":   message,
+			"extra": map[string]interface{}{
+				"stack": stack,
+			},
+		}
+		payload, _ := json.Marshal(event)
+
+		if r.sentryURL != "" {
+			req, err := http.NewRequest("POST", r.sentryURL, bytes.NewReader(payload))
+			if err == nil {
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=one-mcp/1.0, sentry_key=%s", r.sentryKey))
+				r.post(req)
+			}
+		}
+		if r.webhookURL != "" {
+			req, err := http.NewRequest("POST", r.webhookURL, bytes.NewReader(payload))
+			if err == nil {
+				req.Header.Set("Content-Type", "application/json")
+				r.post(req)
+			}
+		}
+	}()
+}
+
+func (r *reporter) post(req *http.Request) {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		fmt.Printf("[Report] Failed to send error report: %v\n", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		fmt.Printf("[Report] Error report rejected with status %d\n", resp.StatusCode)
+	}
+}
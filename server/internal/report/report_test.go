@@ -0,0 +1,34 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorReachesWebhook(t *testing.T) {
+	events := make(chan map[string]interface{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	defer server.Close()
+	assert.NoError(t, Configure("", server.URL))
+	defer Configure("", "")
+
+	Error("upstream github", errors.New("giving up after 3 failed attempts: connection refused"))
+	select {
+	case event := <-events:
+		assert.Equal(t, "error", event["level"])
+		assert.Equal(t, "upstream github", event["logger"])
+		assert.Equal(t, "giving up after 3 failed attempts: connection refused", event["message"])
+	case <-time.After(2 * time.Second):
+		t.Fatal("no report")
+	}
+}
@@ -0,0 +1,276 @@
+package telemetry
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// exporter sends tool call spans and metrics to an OpenTelemetry collector over
+// OTLP/HTTP with JSON encoding. Every data point carries the API key, team, server
+// and tool, so usage can be attributed per tenant without joining logs.
+type exporter struct {
+	endpoint    string // Collector base URL; /v1/traces and /v1/metrics are appended
+	serviceName string
+	headers     map[string]string
+	client      *http.Client
+	started     time.Time
+
+	mu     sync.Mutex
+	spans  []map[string]interface{}
+	series map[string]*series // Cumulative metrics by attribute set
+}
+
+// series accumulates the call counter and duration histogram of one attribute set.
+type series struct {
+	attrs    []map[string]interface{}
+	count    int64
+	sum      float64
+	buckets  []int64
+	exemplar map[string]interface{} // Latest call, linking the series to its trace
+}
+
+// durationBounds are the histogram bucket bounds of tool call durations, in milliseconds.
+var durationBounds = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000}
+
+// maxPendingSpans bounds the spans buffered between exports; newer spans are dropped.
+const maxPendingSpans = 2048
+
+var active *exporter
+
+// Configure enables export to the collector at endpoint. Headers are "name=value"
+// pairs sent with every request, e.g. for authentication. An empty endpoint
+// disables export.
+func Configure(endpoint string, serviceName string, headers []string, interval time.Duration) error {
+	if endpoint == "" {
+		active = nil
+		return nil
+	}
+	e := &exporter{
+		endpoint:    strings.TrimRight(endpoint, "/"),
+		serviceName: serviceName,
+		headers:     make(map[string]string),
+		client:      &http.Client{Timeout: 10 * time.Second},
+		started:     time.Now(),
+		series:      make(map[string]*series),
+	}
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, "=")
+		if !ok {
+			return fmt.Errorf("invalid OTEL_EXPORTER_OTLP_HEADERS entry %q: expected name=value", h)
+		}
+		e.headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	active = e
+
+	go func() {
+		for range time.Tick(interval) {
+			e.flush()
+		}
+	}()
+	return nil
+}
+
+// ToolCall describes a completed tool call.
+type ToolCall struct {
+	KeyID    uint
+	TeamID   uint
+	Server   string
+	Tool     string // Prefixed tool name
+	Started  time.Time
+	Duration time.Duration
+	Error    string // Empty on success
+}
+
+// RecordToolCall records a span and updates the per-key metrics for a tool call.
+func RecordToolCall(call ToolCall) {
+	if active != nil {
+		active.record(call)
+	}
+}
+
+func (e *exporter) record(call ToolCall) {
+	traceID, spanID := randomHex(16), randomHex(8)
+	outcome := "success"
+	if call.Error != "" {
+		outcome = "error"
+	}
+	attrs := []map[string]interface{}{
+		intAttr("one_mcp.key.id", int64(call.KeyID)),
+		intAttr("one_mcp.team.id", int64(call.TeamID)),
+		stringAttr("mcp.server.name", call.Server),
+		stringAttr("mcp.tool.name", call.Tool),
+		stringAttr("one_mcp.outcome", outcome),
+	}
+	end := call.Started.Add(call.Duration)
+	ms := float64(call.Duration.Microseconds()) / 1000
+
+	span := map[string]interface{}{
+		"traceId":           traceID,
+		"spanId":            spanID,
+		"name":              "tools/call " + call.Tool,
+		"kind":              2, // SPAN_KIND_SERVER
+		"startTimeUnixNano": nanos(call.Started),
+		"endTimeUnixNano":   nanos(end),
+		"attributes":        attrs,
+		"status":            map[string]interface{}{"code": 1},
+	}
+	if call.Error != "" {
+		span["status"] = map[string]interface{}{"code": 2, "message": call.Error}
+	}
+
+	key := fmt.Sprintf("%d|%d|%s|%s|%s", call.KeyID, call.TeamID, call.Server, call.Tool, outcome)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.spans) < maxPendingSpans {
+		e.spans = append(e.spans, span)
+	}
+	s, ok := e.series[key]
+	if !ok {
+		s = &series{attrs: attrs, buckets: make([]int64, len(durationBounds)+1)}
+		e.series[key] = s
+	}
+	s.count++
+	s.sum += ms
+	s.buckets[sort.SearchFloat64s(durationBounds, ms)]++
+	s.exemplar = map[string]interface{}{
+		"timeUnixNano": nanos(end),
+		"asDouble":     ms,
+		"traceId":      traceID,
+		"spanId":       spanID,
+	}
+}
+
+// flush exports the buffered spans and a snapshot of the cumulative metrics.
+func (e *exporter) flush() {
+	e.mu.Lock()
+	spans := e.spans
+	e.spans = nil
+	var calls, durations []map[string]interface{}
+	now := nanos(time.Now())
+	for _, s := range e.series {
+		point := map[string]interface{}{
+			"attributes":        s.attrs,
+			"startTimeUnixNano": nanos(e.started),
+			"timeUnixNano":      now,
+			"exemplars":         []map[string]interface{}{s.exemplar},
+		}
+		counter := copyPoint(point)
+		counter["asInt"] = strconv.FormatInt(s.count, 10)
+		calls = append(calls, counter)
+
+		histogram := copyPoint(point)
+		histogram["count"] = strconv.FormatInt(s.count, 10)
+		histogram["sum"] = s.sum
+		histogram["bucketCounts"] = int64Strings(s.buckets)
+		histogram["explicitBounds"] = durationBounds
+		durations = append(durations, histogram)
+	}
+	e.mu.Unlock()
+
+	resource := map[string]interface{}{
+		"attributes": []map[string]interface{}{stringAttr("service.name", e.serviceName)},
+	}
+	scope := map[string]interface{}{"name": "one-mcp"}
+	if len(spans) > 0 {
+		e.post("/v1/traces", map[string]interface{}{
+			"resourceSpans": []map[string]interface{}{{
+				"resource":   resource,
+				"scopeSpans": []map[string]interface{}{{"scope": scope, "spans": spans}},
+			}},
+		})
+	}
+	if len(calls) > 0 {
+		e.post("/v1/metrics", map[string]interface{}{
+			"resourceMetrics": []map[string]interface{}{{
+				"resource": resource,
+				"scopeMetrics": []map[string]interface{}{{
+					"scope": scope,
+					"metrics": []map[string]interface{}{
+						{
+							"name": "one_mcp.tool.calls", "unit": "{call}",
+							"description": "Tool calls by API key, team, server and tool",
+							"sum": map[string]interface{}{
+								"aggregationTemporality": 2, // cumulative
+								"isMonotonic":            true,
+								"dataPoints":             calls,
+							},
+						},
+						{
+							"name": "one_mcp.tool.duration", "unit": "ms",
+							"description": "Tool call duration by API key, team, server and tool",
+							"histogram": map[string]interface{}{
+								"aggregationTemporality": 2,
+								"dataPoints":             durations,
+							},
+						},
+					},
+				}},
+			}},
+		})
+	}
+}
+
+func (e *exporter) post(path string, body interface{}) {
+	payload, _ := json.Marshal(body)
+	req, err := http.NewRequest("POST", e.endpoint+path, bytes.NewReader(payload))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		fmt.Printf("[Telemetry] Export to %s failed: %v\n", path, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		fmt.Printf("[Telemetry] Export to %s failed: HTTP %d\n", path, resp.StatusCode)
+	}
+}
+
+func stringAttr(key, value string) map[string]interface{} {
+	return map[string]interface{}{"key": key, "value": map[string]interface{}{"stringValue": value}}
+}
+
+// intAttr encodes an integer attribute; OTLP/JSON carries 64-bit integers as strings.
+func intAttr(key string, value int64) map[string]interface{} {
+	return map[string]interface{}{"key": key, "value": map[string]interface{}{"intValue": strconv.FormatInt(value, 10)}}
+}
+
+func nanos(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func int64Strings(values []int64) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strconv.FormatInt(v, 10)
+	}
+	return out
+}
+
+func copyPoint(point map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(point)+4)
+	for k, v := range point {
+		out[k] = v
+	}
+	return out
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
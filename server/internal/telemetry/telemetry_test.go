@@ -0,0 +1,72 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExport(t *testing.T) {
+	received := make(map[string]map[string]interface{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+		body, _ := io.ReadAll(r.Body)
+		var payload map[string]interface{}
+		json.Unmarshal(body, &payload)
+		received[r.URL.Path] = payload
+	}))
+	defer srv.Close()
+
+	assert.NoError(t, Configure(srv.URL, "gateway", []string{"X-Api-Key=secret"}, time.Hour))
+	defer Configure("", "", nil, 0)
+
+	started := time.Now()
+	RecordToolCall(ToolCall{KeyID: 3, TeamID: 1, Server: "github", Tool: "github__search", Started: started, Duration: 40 * time.Millisecond})
+	RecordToolCall(ToolCall{KeyID: 3, TeamID: 1, Server: "github", Tool: "github__search", Started: started, Duration: 60 * time.Millisecond})
+	RecordToolCall(ToolCall{KeyID: 4, Server: "github", Tool: "github__search", Started: started, Duration: time.Second, Error: "timeout"})
+	active.flush()
+
+	spans := dig(received["/v1/traces"], "resourceSpans", 0, "scopeSpans", 0, "spans").([]interface{})
+	assert.Len(t, spans, 3)
+	assert.Equal(t, "tools/call github__search", dig(spans[0], "name"))
+	assert.Equal(t, "timeout", dig(spans[2], "status", "message"))
+
+	metrics := dig(received["/v1/metrics"], "resourceMetrics", 0, "scopeMetrics", 0, "metrics").([]interface{})
+	for _, point := range dig(metrics[1], "histogram", "dataPoints").([]interface{}) {
+		attrs := map[string]string{}
+		for _, a := range dig(point, "attributes").([]interface{}) {
+			value := dig(a, "value").(map[string]interface{})
+			for _, v := range value {
+				attrs[dig(a, "key").(string)] = v.(string)
+			}
+		}
+		if attrs["one_mcp.key.id"] == "3" {
+			assert.Equal(t, "1", attrs["one_mcp.team.id"])
+			assert.Equal(t, "success", attrs["one_mcp.outcome"])
+			assert.Equal(t, "2", dig(point, "count"))
+			assert.Equal(t, 100.0, dig(point, "sum"))
+			assert.NotEmpty(t, dig(point, "exemplars", 0, "traceId"))
+		} else {
+			assert.Equal(t, "4", attrs["one_mcp.key.id"])
+			assert.Equal(t, "error", attrs["one_mcp.outcome"])
+		}
+	}
+}
+
+// dig walks decoded JSON by object keys and array indexes.
+func dig(v interface{}, path ...interface{}) interface{} {
+	for _, p := range path {
+		switch p := p.(type) {
+		case string:
+			v = v.(map[string]interface{})[p]
+		case int:
+			v = v.([]interface{})[p]
+		}
+	}
+	return v
+}
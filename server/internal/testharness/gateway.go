@@ -0,0 +1,290 @@
+package testharness
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-mcp/internal/api"
+	"one-mcp/internal/config"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// Timeout bounds every wait of the harness: for upstreams to be ready and for
+// responses.
+var Timeout = 10 * time.Second
+
+// Gateway is a gateway serving the full HTTP API until the test ends, with a
+// database of its own.
+type Gateway struct {
+	URL      string
+	DB       *gorm.DB
+	Core     *core.Gateway
+	Settings *config.Config
+}
+
+// NewGateway starts a gateway with the default configuration, changed by
+// configure if not nil, and no upstreams.
+func NewGateway(t testing.TB, configure func(*config.Config)) *Gateway {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	settings := config.Default()
+	settings.DataDir = t.TempDir()
+	settings.ReconnectDelay = 100 * time.Millisecond
+	if configure != nil {
+		configure(settings)
+	}
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(settings.DataDir, "one-mcp.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("testharness: open database: %v", err)
+	}
+	if err := db.AutoMigrate(model.All...); err != nil {
+		t.Fatalf("testharness: migrate database: %v", err)
+	}
+
+	gateway := core.NewGateway(db, settings)
+	gateway.ReloadFlags()
+	handler := api.NewHandler(db, gateway, settings)
+	r := gin.New()
+	handler.RegisterRoutes(r)
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(func() {
+		gateway.StopUpstreams()
+		srv.CloseClientConnections()
+		srv.Close()
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return &Gateway{URL: srv.URL, DB: db, Core: gateway, Settings: settings}
+}
+
+// AddUpstream saves the upstream, reloads the upstreams and waits for it to
+// be ready. It returns the upstream with its ID.
+func (g *Gateway) AddUpstream(t testing.TB, server model.UpstreamServer) model.UpstreamServer {
+	t.Helper()
+	server.Enabled = true
+	if err := g.DB.Create(&server).Error; err != nil {
+		t.Fatalf("testharness: save upstream %s: %v", server.Name, err)
+	}
+	g.Core.ReloadUpstreams()
+
+	deadline := time.Now().Add(Timeout)
+	for {
+		client, ok := g.Core.UpstreamByID(server.ID)
+		if ok {
+			status := client.Status()
+			if status.State == core.StateReady {
+				return server
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("testharness: upstream %s not ready: %s %s", server.Name, status.State, status.LastError)
+			}
+		} else if time.Now().After(deadline) {
+			t.Fatalf("testharness: upstream %s not started", server.Name)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// AddKey saves the API key and returns it. Without a Key, a random one is
+// generated.
+func (g *Gateway) AddKey(t testing.TB, key model.ApiKey) string {
+	t.Helper()
+	if key.Key == "" {
+		key.Key = fmt.Sprintf("sk-test-%d", time.Now().UnixNano())
+	}
+	if err := g.DB.Create(&key).Error; err != nil {
+		t.Fatalf("testharness: save key: %v", err)
+	}
+	return key.Key
+}
+
+// Session is a client session over the HTTP+SSE transport.
+type Session struct {
+	t        testing.TB
+	endpoint string // Absolute URL to POST messages to
+	body     io.ReadCloser
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[string]chan *core.JSONRPCMessage
+	events  chan *core.JSONRPCMessage // Notifications and requests from the gateway
+}
+
+// Connect opens an SSE session with the given API key and initializes it.
+func (g *Gateway) Connect(t testing.TB, key string) *Session {
+	t.Helper()
+	req, _ := http.NewRequest("GET", g.URL+"/mcp/sse", nil)
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("testharness: open SSE stream: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		t.Fatalf("testharness: open SSE stream: %d %s", resp.StatusCode, body)
+	}
+
+	s := &Session{
+		t:       t,
+		body:    resp.Body,
+		pending: map[string]chan *core.JSONRPCMessage{},
+		events:  make(chan *core.JSONRPCMessage, 64),
+	}
+	endpoint := make(chan string, 1)
+	go s.read(endpoint)
+	t.Cleanup(s.Close)
+
+	select {
+	case s.endpoint = <-endpoint:
+	case <-time.After(Timeout):
+		t.Fatalf("testharness: no endpoint event")
+	}
+
+	s.Call("initialize", map[string]interface{}{
+		"protocolVersion": core.Protocol20250618,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]interface{}{"name": "testharness", "version": "test"},
+	})
+	s.Notify("notifications/initialized", nil)
+	return s
+}
+
+// read dispatches the events of the stream until it ends.
+func (s *Session) read(endpoint chan<- string) {
+	events := core.NewSSEReader(bufio.NewReader(s.body), 10*1024*1024)
+	for {
+		ev, err := events.Next()
+		if err != nil {
+			return
+		}
+		switch ev.Event {
+		case "endpoint":
+			endpoint <- ev.Data
+		case "message":
+			s.dispatch([]byte(ev.Data))
+		}
+	}
+}
+
+func (s *Session) dispatch(data []byte) {
+	var batch []*core.JSONRPCMessage
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		json.Unmarshal(trimmed, &batch)
+	} else {
+		var msg core.JSONRPCMessage
+		if json.Unmarshal(data, &msg) == nil {
+			batch = append(batch, &msg)
+		}
+	}
+	for _, msg := range batch {
+		if msg.ID != nil && msg.Method == "" {
+			s.mu.Lock()
+			ch, ok := s.pending[string(*msg.ID)]
+			delete(s.pending, string(*msg.ID))
+			s.mu.Unlock()
+			if ok {
+				ch <- msg
+			}
+			continue
+		}
+		select {
+		case s.events <- msg:
+		default:
+		}
+	}
+}
+
+// Call sends a request and returns its response, failing the test if none
+// arrives in time. Errors are returned in the response.
+func (s *Session) Call(method string, params interface{}) *core.JSONRPCMessage {
+	s.t.Helper()
+	s.mu.Lock()
+	s.nextID++
+	id := json.RawMessage(fmt.Sprintf("%d", s.nextID))
+	ch := make(chan *core.JSONRPCMessage, 1)
+	s.pending[string(id)] = ch
+	s.mu.Unlock()
+
+	s.post(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	select {
+	case resp := <-ch:
+		return resp
+	case <-time.After(Timeout):
+		s.t.Fatalf("testharness: no response to %s", method)
+		return nil
+	}
+}
+
+// Notify sends a notification.
+func (s *Session) Notify(method string, params interface{}) {
+	s.t.Helper()
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	s.post(msg)
+}
+
+// Events returns the notifications and requests the gateway sends.
+func (s *Session) Events() <-chan *core.JSONRPCMessage {
+	return s.events
+}
+
+func (s *Session) post(msg interface{}) {
+	s.t.Helper()
+	data, _ := json.Marshal(msg)
+	resp, err := http.Post(s.endpoint, "application/json", bytes.NewReader(data))
+	if err != nil {
+		s.t.Fatalf("testharness: post message: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		s.t.Fatalf("testharness: post message: %d %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	// Keys with sync_messages get the response in the body
+	if resp.StatusCode == http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		s.dispatch(body)
+	}
+}
+
+// Close ends the session.
+func (s *Session) Close() {
+	s.body.Close()
+}
+
+// ToolText returns the text of the first content block of a tools/call
+// response and whether the result is an error.
+func ToolText(resp *core.JSONRPCMessage) (string, bool) {
+	var result struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	if resp == nil || resp.Error != nil || json.Unmarshal(resp.Result, &result) != nil || len(result.Content) == 0 {
+		return "", true
+	}
+	return result.Content[0].Text, result.IsError
+}
@@ -0,0 +1,154 @@
+package testharness
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-mcp/internal/core"
+	"one-mcp/internal/model"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stdioServer is registered before TestMain so the copy of the test binary
+// serving it finds it.
+var stdioServer = &MockServer{Name: "files", Tools: []MockTool{
+	{Name: "read", Call: func(args map[string]interface{}) (string, error) {
+		return fmt.Sprintf("contents of %v", args["path"]), nil
+	}},
+}}
+
+var stdioUpstream = StdioUpstream(stdioServer)
+
+func TestMain(m *testing.M) {
+	Main(m)
+}
+
+func weatherServer() *MockServer {
+	return &MockServer{Name: "weather", Tools: []MockTool{
+		{Name: "forecast", Description: "Forecast for a city"},
+		{Name: "alerts", Call: func(args map[string]interface{}) (string, error) {
+			return "", errors.New("no alerts service")
+		}},
+	}}
+}
+
+func toolNames(t *testing.T, resp *core.JSONRPCMessage) []string {
+	require.Nil(t, resp.Error)
+	var result struct {
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Result, &result))
+	var names []string
+	for _, tool := range result.Tools {
+		names = append(names, tool.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSSEUpstream(t *testing.T) {
+	weather := weatherServer()
+	g := NewGateway(t, nil)
+	g.AddUpstream(t, ServeSSE(t, weather))
+	s := g.Connect(t, g.AddKey(t, model.ApiKey{}))
+
+	names := toolNames(t, s.Call("tools/list", nil))
+	assert.Contains(t, names, "weather__forecast")
+	assert.Contains(t, names, "weather__alerts")
+
+	text, isError := ToolText(s.Call("tools/call", map[string]interface{}{
+		"name": "weather__forecast", "arguments": map[string]interface{}{"city": "Oslo"},
+	}))
+	assert.False(t, isError)
+	assert.JSONEq(t, `{"city":"Oslo"}`, text)
+
+	text, isError = ToolText(s.Call("tools/call", map[string]interface{}{"name": "weather__alerts"}))
+	assert.True(t, isError)
+	assert.Contains(t, text, "no alerts service")
+	assert.Equal(t, []string{"forecast", "alerts"}, weather.Calls())
+
+	resp := s.Call("tools/call", map[string]interface{}{"name": "weather__missing"})
+	assert.NotNil(t, resp.Error)
+}
+
+func TestStdioUpstream(t *testing.T) {
+	g := NewGateway(t, nil)
+	g.AddUpstream(t, stdioUpstream)
+	s := g.Connect(t, g.AddKey(t, model.ApiKey{}))
+
+	assert.Contains(t, toolNames(t, s.Call("tools/list", nil)), "files__read")
+	text, isError := ToolText(s.Call("tools/call", map[string]interface{}{
+		"name": "files__read", "arguments": map[string]interface{}{"path": "/etc/motd"},
+	}))
+	assert.False(t, isError)
+	assert.Equal(t, "contents of /etc/motd", text)
+}
+
+func TestUnixUpstream(t *testing.T) {
+	weather := weatherServer()
+	g := NewGateway(t, nil)
+	g.AddUpstream(t, ServeUnix(t, weather))
+	s := g.Connect(t, g.AddKey(t, model.ApiKey{}))
+
+	assert.Contains(t, toolNames(t, s.Call("tools/list", nil)), "weather__forecast")
+	text, isError := ToolText(s.Call("tools/call", map[string]interface{}{
+		"name": "weather__forecast", "arguments": map[string]interface{}{"city": "Bergen"},
+	}))
+	assert.False(t, isError)
+	assert.JSONEq(t, `{"city":"Bergen"}`, text)
+}
+
+func TestKeyPermissions(t *testing.T) {
+	weather := weatherServer()
+	g := NewGateway(t, nil)
+	allowed := g.AddUpstream(t, ServeSSE(t, weather))
+	g.AddUpstream(t, ServeSSE(t, &MockServer{Name: "billing", Tools: []MockTool{{Name: "charge"}}}))
+
+	key := g.AddKey(t, model.ApiKey{AllowedServers: fmt.Sprintf(`["%d"]`, allowed.ID)})
+	s := g.Connect(t, key)
+
+	names := toolNames(t, s.Call("tools/list", nil))
+	assert.Contains(t, names, "weather__forecast")
+	assert.NotContains(t, names, "billing__charge")
+
+	resp := s.Call("tools/call", map[string]interface{}{"name": "billing__charge"})
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, "Permission denied", resp.Error.Message)
+}
+
+func TestSyncMessages(t *testing.T) {
+	g := NewGateway(t, nil)
+	g.AddUpstream(t, ServeSSE(t, weatherServer()))
+	ping := `{"jsonrpc":"2.0","id":"p","method":"ping"}`
+	postPing := func(url string) (int, string) {
+		resp, err := http.Post(url, "application/json", strings.NewReader(ping))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	// Per request, with ?sync=true
+	s := g.Connect(t, g.AddKey(t, model.ApiKey{}))
+	code, _ := postPing(s.endpoint)
+	assert.Equal(t, 202, code)
+	code, body := postPing(s.endpoint + "&sync=true")
+	assert.Equal(t, 200, code)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":"p","result":{}}`, body)
+
+	// Per key, for every message of its sessions
+	s = g.Connect(t, g.AddKey(t, model.ApiKey{SyncMessages: true}))
+	code, body = postPing(s.endpoint)
+	assert.Equal(t, 200, code)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":"p","result":{}}`, body)
+	assert.Contains(t, toolNames(t, s.Call("tools/list", nil)), "weather__forecast")
+}
@@ -0,0 +1,127 @@
+// Package testharness runs the gateway end to end for integration tests: a
+// Gateway serves the full HTTP API from a temporary database, in front of
+// MockServer upstreams reached over SSE (ServeSSE) or stdio (StdioUpstream),
+// and a Session talks to it over /mcp/sse and /mcp/messages like a client.
+//
+// Tests using stdio upstreams must run Main from their TestMain, as those are
+// served by a copy of the test binary.
+package testharness
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// MockTool is a tool served by a MockServer.
+type MockTool struct {
+	Name        string
+	Description string
+	Call        func(args map[string]interface{}) (string, error) // nil echoes the arguments
+}
+
+// MockServer is a minimal MCP server with a fixed set of tools. Errors
+// returned by a tool become results with isError set.
+type MockServer struct {
+	Name  string
+	Tools []MockTool
+
+	mu    sync.Mutex
+	calls []string
+}
+
+// Calls returns the names of the tools called so far, in order. Calls served
+// by a stdio copy of the server are not seen.
+func (m *MockServer) Calls() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.calls...)
+}
+
+// Handle answers a JSON-RPC message, returning nil for notifications and
+// responses.
+func (m *MockServer) Handle(msg []byte) []byte {
+	var req struct {
+		ID     *json.RawMessage `json:"id"`
+		Method string           `json:"method"`
+		Params json.RawMessage  `json:"params"`
+	}
+	if err := json.Unmarshal(msg, &req); err != nil || req.ID == nil || req.Method == "" {
+		return nil
+	}
+
+	var result interface{}
+	var rpcErr map[string]interface{}
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		result = map[string]interface{}{
+			"protocolVersion": params.ProtocolVersion,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]interface{}{"name": m.Name, "version": "test"},
+		}
+	case "ping":
+		result = map[string]interface{}{}
+	case "tools/list":
+		tools := make([]map[string]interface{}, 0, len(m.Tools))
+		for _, tool := range m.Tools {
+			tools = append(tools, map[string]interface{}{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"inputSchema": map[string]interface{}{"type": "object"},
+			})
+		}
+		result = map[string]interface{}{"tools": tools}
+	case "tools/call":
+		var params struct {
+			Name      string                 `json:"name"`
+			Arguments map[string]interface{} `json:"arguments"`
+		}
+		json.Unmarshal(req.Params, &params)
+		result, rpcErr = m.call(params.Name, params.Arguments)
+	default:
+		rpcErr = map[string]interface{}{"code": -32601, "message": "Method not found"}
+	}
+
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	if rpcErr != nil {
+		resp["error"] = rpcErr
+	} else {
+		resp["result"] = result
+	}
+	data, _ := json.Marshal(resp)
+	return data
+}
+
+func (m *MockServer) call(name string, args map[string]interface{}) (interface{}, map[string]interface{}) {
+	for _, tool := range m.Tools {
+		if tool.Name != name {
+			continue
+		}
+		m.mu.Lock()
+		m.calls = append(m.calls, name)
+		m.mu.Unlock()
+
+		var text string
+		var err error
+		if tool.Call != nil {
+			text, err = tool.Call(args)
+		} else {
+			data, _ := json.Marshal(args)
+			text = string(data)
+		}
+		if err != nil {
+			return map[string]interface{}{
+				"content": []interface{}{map[string]interface{}{"type": "text", "text": err.Error()}},
+				"isError": true,
+			}, nil
+		}
+		return map[string]interface{}{
+			"content": []interface{}{map[string]interface{}{"type": "text", "text": text}},
+		}, nil
+	}
+	return nil, map[string]interface{}{"code": -32602, "message": fmt.Sprintf("Unknown tool %s", name)}
+}
@@ -0,0 +1,155 @@
+package testharness
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"one-mcp/internal/model"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// ServeSSE serves m over the HTTP+SSE transport until the test ends and
+// returns the upstream configuration to reach it.
+func ServeSSE(t testing.TB, m *MockServer) model.UpstreamServer {
+	var mu sync.Mutex
+	sessions := map[string]chan []byte{}
+	seq := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seq++
+		id := fmt.Sprintf("%d", seq)
+		out := make(chan []byte, 64)
+		sessions[id] = out
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			delete(sessions, id)
+			mu.Unlock()
+		}()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "event: endpoint\ndata: /messages?session=%s\n\n", id)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case msg := <-out:
+				fmt.Fprintf(w, "event: message\ndata: %s\n\n", msg)
+				w.(http.Flusher).Flush()
+			}
+		}
+	})
+	mux.HandleFunc("/messages", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		out, ok := sessions[r.URL.Query().Get("session")]
+		mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+		if resp := m.Handle(body); resp != nil {
+			out <- resp
+		}
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		srv.CloseClientConnections()
+		srv.Close()
+	})
+	return model.UpstreamServer{Name: m.Name, TransportType: "sse", URL: srv.URL + "/sse", Enabled: true}
+}
+
+// ServeUnix serves m on a Unix domain socket, one message per line, until the
+// test ends and returns the upstream configuration to reach it.
+func ServeUnix(t testing.TB, m *MockServer) model.UpstreamServer {
+	path := filepath.Join(t.TempDir(), "mcp.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("testharness: listen on %s: %v", path, err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				in := bufio.NewScanner(conn)
+				in.Buffer(make([]byte, 64*1024), 10*1024*1024)
+				for in.Scan() {
+					if resp := m.Handle(in.Bytes()); resp != nil {
+						conn.Write(append(resp, '\n'))
+					}
+				}
+			}()
+		}
+	}()
+	return model.UpstreamServer{Name: m.Name, TransportType: "unix", SocketPath: path, Enabled: true}
+}
+
+// stdioEnv names the registered server a copy of the test binary serves over
+// its standard input and output.
+const stdioEnv = "ONE_MCP_TESTHARNESS_STDIO"
+
+var (
+	stdioServers   = map[string]*MockServer{}
+	stdioServersMu sync.Mutex
+)
+
+// StdioUpstream returns the upstream configuration to reach m over stdio, in
+// a copy of the test binary. It registers m, so it must be called the same way
+// in the copy, e.g. in a package-level variable, before Main runs.
+func StdioUpstream(m *MockServer) model.UpstreamServer {
+	stdioServersMu.Lock()
+	stdioServers[m.Name] = m
+	stdioServersMu.Unlock()
+
+	command, err := os.Executable()
+	if err != nil {
+		command = os.Args[0]
+	}
+	env, _ := json.Marshal(map[string]string{stdioEnv: m.Name})
+	return model.UpstreamServer{Name: m.Name, TransportType: "stdio", Command: command, Env: string(env), Enabled: true}
+}
+
+// Main runs the tests, or, in a copy of the test binary started for a stdio
+// upstream, serves the requested server until its input ends. Call it from
+// TestMain: func TestMain(m *testing.M) { testharness.Main(m) }.
+func Main(m *testing.M) {
+	name := os.Getenv(stdioEnv)
+	if name == "" {
+		os.Exit(m.Run())
+	}
+	stdioServersMu.Lock()
+	server, ok := stdioServers[name]
+	stdioServersMu.Unlock()
+	if !ok {
+		fmt.Fprintf(os.Stderr, "testharness: no stdio server named %q\n", name)
+		os.Exit(2)
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	in.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for in.Scan() {
+		if resp := server.Handle(in.Bytes()); resp != nil {
+			os.Stdout.Write(append(resp, '\n'))
+		}
+	}
+	os.Exit(0)
+}
@@ -1,5 +1,5 @@
 import React, { useEffect, useState } from 'react';
-import { Table, Button, Modal, Form, Input, Switch, message, Popconfirm, Card, Tag, Space, Tooltip, Select, Row, Col, Divider } from 'antd';
+import { Table, Button, Modal, Form, Input, Switch, message, Popconfirm, Card, Tag, Space, Tooltip, Select, Row, Col, Divider, Badge } from 'antd';
 import { PlusOutlined, EditOutlined, DeleteOutlined, SyncOutlined, CheckCircleOutlined, CloseCircleOutlined, CloudServerOutlined, CodeOutlined, ApiOutlined, MinusCircleOutlined } from '@ant-design/icons';
 import axios from 'axios';
 import { useTranslation } from 'react-i18next';
@@ -7,16 +7,34 @@ import { useTranslation } from 'react-i18next';
 interface Server {
   id: number;
   name: string;
-  transport_type: 'sse' | 'stdio' | 'http';
+  transport_type: 'sse' | 'streaminghttp' | 'stdio' | 'unix' | 'http';
   url: string;
   command: string;
+  socket_path?: string;
   args: string;
   env: string;
   tool_config: string;
   auth_token: string;
   enabled: boolean;
+  status?: ServerStatus;
 }
 
+interface ServerStatus {
+  state: 'connecting' | 'ready' | 'error' | 'failed' | 'disabled';
+  last_error?: string;
+  last_success_at?: string;
+  gave_up?: boolean;
+  saturation?: { reconnects: number };
+}
+
+const stateBadges: Record<ServerStatus['state'], 'success' | 'processing' | 'error' | 'default'> = {
+  ready: 'success',
+  connecting: 'processing',
+  error: 'error',
+  failed: 'error',
+  disabled: 'default',
+};
+
 const ServerList: React.FC = () => {
   const { t } = useTranslation();
   const [servers, setServers] = useState<Server[]>([]);
@@ -24,7 +42,7 @@ const ServerList: React.FC = () => {
   const [isModalOpen, setIsModalOpen] = useState(false);
   const [form] = Form.useForm();
   const [editingId, setEditingId] = useState<number | null>(null);
-  const [transportType, setTransportType] = useState<'sse' | 'stdio' | 'http'>('sse');
+  const [transportType, setTransportType] = useState<'sse' | 'streaminghttp' | 'stdio' | 'unix' | 'http'>('sse');
 
   const fetchServers = async () => {
     setLoading(true);
@@ -97,6 +115,26 @@ const ServerList: React.FC = () => {
               : <Tag icon={<CloseCircleOutlined />} color="error">{t('common.stopped')}</Tag>
         )
     },
+    {
+        title: t('server.connection'),
+        dataIndex: 'status',
+        key: 'connection',
+        width: 140,
+        render: (status?: ServerStatus) => {
+            if (!status) return null;
+            const details = [
+                status.gave_up && t('server.gave_up'),
+                status.last_error && `${t('server.last_error')}: ${status.last_error}`,
+                status.last_success_at && `${t('server.last_success')}: ${new Date(status.last_success_at).toLocaleString()}`,
+                status.saturation?.reconnects ? `${t('server.reconnects')}: ${status.saturation.reconnects}` : null,
+            ].filter(Boolean);
+            return (
+                <Tooltip title={details.length ? details.map(d => <div key={d as string}>{d}</div>) : null}>
+                    <Badge status={stateBadges[status.state] || 'default'} text={t(`server.state_${status.state}`)} />
+                </Tooltip>
+            );
+        }
+    },
     { 
         title: t('server.name'), 
         dataIndex: 'name', 
@@ -110,6 +148,7 @@ const ServerList: React.FC = () => {
         width: 120,
         render: (text: string) => {
             if (text === 'stdio') return <Tag color="blue" icon={<CodeOutlined />}>STDIO</Tag>;
+            if (text === 'unix') return <Tag color="purple" icon={<CodeOutlined />}>UNIX</Tag>;
             if (text === 'http') return <Tag color="orange" icon={<ApiOutlined />}>HTTP</Tag>;
             return <Tag color="green" icon={<CloudServerOutlined />}>SSE</Tag>;
         }
@@ -121,6 +160,9 @@ const ServerList: React.FC = () => {
             if (record.transport_type === 'stdio') {
                 return <span style={{ fontFamily: 'monospace', color: '#666' }}>{record.command} {record.args && record.args !== '[]' ? '...' : ''}</span>;
             }
+            if (record.transport_type === 'unix') {
+                return <span style={{ fontFamily: 'monospace', color: '#666' }}>{record.socket_path}</span>;
+            }
             return <span style={{ color: '#666' }}>{record.url}</span>;
         }
     },
@@ -211,15 +253,17 @@ const ServerList: React.FC = () => {
           <Form.Item name="transport_type" label={t('server.transport_type')} rules={[{ required: true }]}>
             <Select size="large" onChange={(val) => setTransportType(val)}>
                 <Select.Option value="sse">SSE (Server-Sent Events)</Select.Option>
+                <Select.Option value="streaminghttp">Streamable HTTP</Select.Option>
                 <Select.Option value="stdio">Stdio (Local Process)</Select.Option>
+                <Select.Option value="unix">Unix Socket (Local Server)</Select.Option>
                 <Select.Option value="http">HTTP / REST API (Single Tool)</Select.Option>
             </Select>
           </Form.Item>
 
-          {transportType === 'sse' && (
+          {(transportType === 'sse' || transportType === 'streaminghttp') && (
             <>
                 <Form.Item name="url" label={t('server.url')} rules={[{ required: true }]} tooltip={t('server.url_tooltip')}>
-                    <Input size="large" placeholder="http://localhost:3000/sse" />
+                    <Input size="large" placeholder={transportType === 'sse' ? 'http://localhost:3000/sse' : 'http://localhost:3000/mcp'} />
                 </Form.Item>
                 <Form.Item name="auth_token" label={t('server.auth_token')} tooltip={t('server.auth_token_tooltip')}>
                     <Input.Password size="large" placeholder="sk-..." />
@@ -238,9 +282,18 @@ const ServerList: React.FC = () => {
                 <Form.Item name="env" label={t('server.env')} tooltip={t('server.env_tooltip')}>
                     <Input.TextArea autoSize={{ minRows: 2, maxRows: 6 }} placeholder='{"GITHUB_TOKEN": "ghp_..."}' />
                 </Form.Item>
+                <Form.Item name="cwd" label={t('server.cwd')} tooltip={t('server.cwd_tooltip')}>
+                    <Input size="large" placeholder="/srv/mcp/files" />
+                </Form.Item>
             </>
           )}
 
+          {transportType === 'unix' && (
+            <Form.Item name="socket_path" label={t('server.socket_path')} rules={[{ required: true }]} tooltip={t('server.socket_path_tooltip')}>
+                <Input size="large" placeholder="/run/mcp/server.sock" />
+            </Form.Item>
+          )}
+
           {transportType === 'http' && (
               <div style={{ background: '#fafafa', padding: 16, borderRadius: 8 }}>
                   <Form.Item name="url" label={t('server.url')} rules={[{ required: true }]}>
@@ -286,6 +339,8 @@ const ServerList: React.FC = () => {
                                         <Select.Option value="string">String</Select.Option>
                                         <Select.Option value="number">Number</Select.Option>
                                         <Select.Option value="boolean">Boolean</Select.Option>
+                                        <Select.Option value="array">Array</Select.Option>
+                                        <Select.Option value="object">Object</Select.Option>
                                     </Select>
                                 </Form.Item>
                                 <Form.Item {...restField} name={[name, 'required']} valuePropName="checked" style={{ width: 40, marginBottom: 0 }}>